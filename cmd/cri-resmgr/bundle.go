@@ -0,0 +1,341 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+const (
+	// bundleCache is the name of the cache snapshot in a support bundle.
+	bundleCache = "cache"
+	// bundleConfig is the name of the configuration file in a support bundle.
+	bundleConfig = "config"
+	// bundlePolicy is the name of the policy state in a support bundle.
+	bundlePolicy = "policy.json"
+	// bundleSysfs is the directory of the topology sysfs tree in a support bundle.
+	bundleSysfs = "sysfs"
+	// redacted replaces values removed from a support bundle.
+	redacted = "<redacted>"
+)
+
+// keptKeyPrefixes are labels and annotations we need for simulation, never redacted.
+var keptKeyPrefixes = []string{
+	kubernetes.ResmgrKeyNamespace,
+	"io.kubernetes.",
+	"kubernetes.io/",
+}
+
+// cacheCommand dumps or loads a support bundle of the cache, configuration and topology.
+func cacheCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing cache command, expecting dump or load")
+	}
+	switch args[0] {
+	case "dump":
+		return dumpBundle(args[1:])
+	case "load":
+		return loadBundle(args[1:])
+	}
+	return fmt.Errorf("unknown cache command %q, expecting dump or load", args[0])
+}
+
+// dumpBundle creates a support bundle for attaching to bug reports.
+//
+// The bundle is a gzipped tarball of a sanitized copy of the cache, the current
+// configuration, the policy state and the sysfs entries describing the hardware
+// topology. The environment, command and arguments of containers are always
+// redacted. Other pod and container labels and annotations than the ones we need
+// for simulation are redacted, too, unless explicitly asked to keep them.
+func dumpBundle(args []string) error {
+	flags := flag.NewFlagSet("cache dump", flag.ExitOnError)
+	cacheDir := flags.String("cache-dir", "/var/lib/cri-resmgr",
+		"directory of the cache to dump")
+	sysfsPath := flags.String("sysfs", system.SysfsRootPath,
+		"path to the sysfs tree to dump hardware topology from")
+	configFile := flags.String("config", "",
+		"configuration file to include in the bundle")
+	keepMetadata := flags.Bool("keep-metadata", false,
+		"keep labels and annotations not used by cri-resmgr or kubelet")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expecting a single archive to dump to")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(*cacheDir, bundleCache))
+	if err != nil {
+		return fmt.Errorf("failed to read cache from %s: %v", *cacheDir, err)
+	}
+	snapshot, state, err := sanitizeCache(data, !*keepMetadata)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %v", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	if err = addBundleEntry(tw, bundleCache, snapshot); err != nil {
+		return err
+	}
+	if err = addBundleEntry(tw, bundlePolicy, state); err != nil {
+		return err
+	}
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read configuration file: %v", err)
+		}
+		if err = addBundleEntry(tw, bundleConfig, data); err != nil {
+			return err
+		}
+	}
+	for _, entry := range system.CaptureSnapshot(*sysfsPath) {
+		name := filepath.Join(bundleSysfs, entry.Path)
+		if entry.Dir {
+			// directories, like the NUMA node links of CPUs, only matter by their presence
+			err = addBundleDir(tw, name)
+		} else {
+			err = addBundleEntry(tw, name, entry.Data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %v", err)
+	}
+	if err = gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %v", err)
+	}
+
+	fmt.Printf("Support bundle written to %s.\n", flags.Arg(0))
+	return nil
+}
+
+// loadBundle unpacks a support bundle, ready for simulation.
+func loadBundle(args []string) error {
+	flags := flag.NewFlagSet("cache load", flag.ExitOnError)
+	dir := flags.String("dir", "", "directory to unpack the bundle into")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expecting a single archive to load")
+	}
+	if *dir == "" {
+		return fmt.Errorf("missing directory to unpack the bundle into")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+		name := filepath.Clean(hdr.Name)
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			return fmt.Errorf("invalid archive entry %q", hdr.Name)
+		}
+		path := filepath.Join(*dir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", path, err)
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %v", name, err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to read archive entry %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+
+	fmt.Printf("Support bundle unpacked to %s, to simulate it run\n", *dir)
+	fmt.Printf("  cri-resmgr simulate -cache-dir %s -sysfs %s",
+		*dir, filepath.Join(*dir, bundleSysfs))
+	if _, err := os.Stat(filepath.Join(*dir, bundleConfig)); err == nil {
+		fmt.Printf(" -config %s", filepath.Join(*dir, bundleConfig))
+	}
+	fmt.Printf("\n")
+
+	return nil
+}
+
+// addBundleEntry adds a file with the given content to a support bundle.
+func addBundleEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	return nil
+}
+
+// addBundleDir adds an empty directory to a support bundle.
+func addBundleDir(tw *tar.Writer, name string) error {
+	hdr := &tar.Header{
+		Name:     name + "/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %v", name, err)
+	}
+	return nil
+}
+
+// sanitizeCache redacts a saved cache, returning it and the policy state extracted from it.
+func sanitizeCache(data []byte, redactMetadata bool) ([]byte, []byte, error) {
+	snapshot := map[string]interface{}{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse cache: %v", err)
+	}
+
+	if containers, ok := snapshot["Containers"].(map[string]interface{}); ok {
+		for _, obj := range containers {
+			c, ok := obj.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			redactValues(c, "Env", nil)
+			redactList(c, "Command")
+			redactList(c, "Args")
+			if redactMetadata {
+				redactValues(c, "Labels", keptKeyPrefixes)
+				redactValues(c, "Annotations", keptKeyPrefixes)
+			}
+		}
+	}
+	if redactMetadata {
+		if pods, ok := snapshot["Pods"].(map[string]interface{}); ok {
+			for _, obj := range pods {
+				if p, ok := obj.(map[string]interface{}); ok {
+					redactValues(p, "Labels", keptKeyPrefixes)
+					redactValues(p, "Annotations", keptKeyPrefixes)
+				}
+			}
+		}
+	}
+
+	policy := map[string]interface{}{
+		"policy": snapshot["PolicyName"],
+		"data":   map[string]interface{}{},
+	}
+	if entries, ok := snapshot["PolicyJSON"].(map[string]interface{}); ok {
+		for key, value := range entries {
+			var decoded interface{}
+			if str, ok := value.(string); ok && json.Unmarshal([]byte(str), &decoded) == nil {
+				policy["data"].(map[string]interface{})[key] = decoded
+			} else {
+				policy["data"].(map[string]interface{})[key] = value
+			}
+		}
+	}
+
+	sanitized, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal sanitized cache: %v", err)
+	}
+	state, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal policy state: %v", err)
+	}
+
+	return sanitized, state, nil
+}
+
+// redactValues redacts the values of a map field of an object, except for keys with given prefixes.
+func redactValues(obj map[string]interface{}, field string, keep []string) {
+	values, ok := obj[field].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key := range values {
+		if hasAnyPrefix(key, keep) {
+			continue
+		}
+		values[key] = redacted
+	}
+}
+
+// redactList redacts all items of a list field of an object.
+func redactList(obj map[string]interface{}, field string) {
+	items, ok := obj[field].([]interface{})
+	if !ok {
+		return
+	}
+	for i := range items {
+		items[i] = redacted
+	}
+}
+
+// hasAnyPrefix checks if a string has any of the given prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
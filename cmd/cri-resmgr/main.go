@@ -18,8 +18,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
-	"time"
+	"syscall"
 
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
@@ -55,6 +56,21 @@ func main() {
 			case "config-help", "help":
 				config.Describe(args[1:]...)
 				os.Exit(0)
+			case "cache":
+				if err := cacheCommand(args[1:]); err != nil {
+					log.Fatal("cache command failed: %v", err)
+				}
+				os.Exit(0)
+			case "topology":
+				if err := topologyCommand(args[1:]); err != nil {
+					log.Fatal("topology command failed: %v", err)
+				}
+				os.Exit(0)
+			case "simulate":
+				if err := simulate(args[1:]); err != nil {
+					log.Fatal("simulation failed: %v", err)
+				}
+				os.Exit(0)
 			default:
 				log.Error("unknown command line arguments: %s", strings.Join(flag.Args(), ","))
 				flag.Usage()
@@ -79,7 +95,10 @@ func main() {
 		log.Fatal("failed to start resource manager: %v", err)
 	}
 
-	for {
-		time.Sleep(15 * time.Second)
-	}
+	// save our state on shutdown, for instance to hand over to a standby instance
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	log.Info("received signal %v, shutting down...", sig)
+	m.Stop()
 }
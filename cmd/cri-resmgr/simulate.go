@@ -0,0 +1,151 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+
+	// pull in all builtin policies
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy/builtin/eda"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy/builtin/none"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy/builtin/static"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy/builtin/static-plus"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy/builtin/static-pools"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy/builtin/topology-aware"
+)
+
+// simulate replays the containers of a saved cache against a policy, offline.
+//
+// The cache is copied to a scratch directory, so the original snapshot is never
+// modified. All policy-specific data is dropped from the copy, then every known
+// container is allocated resources by the policy selected by the configuration,
+// using either the running system or a sysfs snapshot for hardware topology.
+func simulate(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	cacheDir := flags.String("cache-dir", "/var/lib/cri-resmgr",
+		"directory of the cache snapshot to replay")
+	sysfsPath := flags.String("sysfs", "",
+		"path to a (recorded) sysfs tree to use for hardware topology, instead of -fake-topology or the system")
+	configFile := flags.String("config", "",
+		"configuration file to use instead of the one stored in the cache")
+	flags.Parse(args)
+
+	scratch, err := ioutil.TempDir("", "cri-resmgr-simulate.")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	data, err := ioutil.ReadFile(filepath.Join(*cacheDir, "cache"))
+	if err != nil {
+		return fmt.Errorf("failed to read cache snapshot from %s: %v", *cacheDir, err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(scratch, "cache"), data, 0600); err != nil {
+		return fmt.Errorf("failed to copy cache snapshot: %v", err)
+	}
+
+	cch, err := cache.NewCache(cache.Options{CacheDir: scratch})
+	if err != nil {
+		return fmt.Errorf("failed to load cache snapshot: %v", err)
+	}
+
+	switch {
+	case *configFile != "":
+		err = config.SetConfigFromFile(*configFile)
+	case cch.GetConfig() != nil:
+		err = config.SetConfig(cch.GetConfig().Data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to activate configuration: %v", err)
+	}
+
+	if policy.ActivePolicy() == policy.NullPolicy {
+		return fmt.Errorf("no policy to simulate, %s policy is active", policy.NullPolicy)
+	}
+
+	var sys system.System
+	if *sysfsPath != "" {
+		sys, err = system.DiscoverSystemAt(*sysfsPath)
+	} else {
+		sys, err = system.DiscoverSystem()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to discover system topology: %v", err)
+	}
+
+	cch.ResetActivePolicy()
+	cch.SetActivePolicy(policy.ActivePolicy())
+
+	p, err := policy.NewPolicy(cch, &policy.Options{System: sys})
+	if err != nil {
+		return fmt.Errorf("failed to create policy %s: %v", policy.ActivePolicy(), err)
+	}
+	if err = p.Start(nil, nil); err != nil {
+		return fmt.Errorf("failed to start policy %s: %v", policy.ActivePolicy(), err)
+	}
+
+	fmt.Printf("Simulating policy %s with %d containers...\n",
+		policy.ActivePolicy(), len(cch.GetContainers()))
+
+	for _, c := range replayOrder(cch) {
+		if err := p.AllocateResources(c); err != nil {
+			fmt.Printf("  %s: allocation failed: %v\n", c.PrettyName(), err)
+			continue
+		}
+		fmt.Printf("  %s:\n", c.PrettyName())
+		fmt.Printf("    cpuset.cpus: %s\n", c.GetCpusetCpus())
+		fmt.Printf("    cpuset.mems: %s\n", c.GetCpusetMems())
+		fmt.Printf("    cpu.shares: %d\n", c.GetCPUShares())
+		if class := c.GetRDTClass(); class != "" {
+			fmt.Printf("    RDT class: %s\n", class)
+		}
+		if class := c.GetBlockIOClass(); class != "" {
+			fmt.Printf("    block I/O class: %s\n", class)
+		}
+	}
+
+	return nil
+}
+
+// replayOrder returns the live containers to replay, sorted by namespace, pod and name.
+func replayOrder(cch cache.Cache) []cache.Container {
+	containers := []cache.Container{}
+	for _, c := range cch.GetContainers() {
+		switch c.GetState() {
+		case cache.ContainerStateCreated, cache.ContainerStateRunning:
+			containers = append(containers, c)
+		}
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		ci, cj := containers[i], containers[j]
+		if ci.GetNamespace() != cj.GetNamespace() {
+			return ci.GetNamespace() < cj.GetNamespace()
+		}
+		return ci.PrettyName() < cj.PrettyName()
+	})
+
+	return containers
+}
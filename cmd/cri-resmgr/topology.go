@@ -0,0 +1,89 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// topologyCommand dumps or shows a hardware topology snapshot.
+func topologyCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing topology command, expecting dump or show")
+	}
+	switch args[0] {
+	case "dump":
+		return dumpTopology(args[1:])
+	case "show":
+		return showTopology(args[1:])
+	}
+	return fmt.Errorf("unknown topology command %q, expecting dump or show", args[0])
+}
+
+// dumpTopology captures a snapshot of the hardware topology of the node.
+//
+// The snapshot is a gzipped tarball of the sysfs entries used for hardware
+// discovery. It can be given to cri-resmgr with -fake-topology to reproduce
+// the resource allocations of a node without access to its hardware.
+func dumpTopology(args []string) error {
+	flags := flag.NewFlagSet("topology dump", flag.ExitOnError)
+	sysfsPath := flags.String("sysfs", system.SysfsRootPath,
+		"path to the sysfs tree to dump hardware topology from")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expecting a single snapshot file to dump to")
+	}
+
+	if err := system.WriteSnapshot(*sysfsPath, flags.Arg(0)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Topology snapshot written to %s, to use it run\n", flags.Arg(0))
+	fmt.Printf("  cri-resmgr -fake-topology %s ...\n", flags.Arg(0))
+	return nil
+}
+
+// showTopology prints the hardware topology discovered from the system or a snapshot.
+func showTopology(args []string) error {
+	flags := flag.NewFlagSet("topology show", flag.ExitOnError)
+	flags.Parse(args)
+
+	sys, err := system.DiscoverSystem(system.DiscoverCPUTopology, system.DiscoverMemTopology)
+	if err != nil {
+		return fmt.Errorf("failed to discover system topology: %v", err)
+	}
+
+	for _, pkgID := range sys.PackageIDs() {
+		pkg := sys.Package(pkgID)
+		fmt.Printf("package #%d: CPUs %s\n", pkgID, pkg.CPUSet())
+		for _, dieID := range pkg.DieIDs() {
+			fmt.Printf("  die #%d: CPUs %s, nodes %v\n", dieID, pkg.DieCPUSet(dieID), pkg.DieNodeIDs(dieID))
+		}
+		for _, nodeID := range pkg.NodeIDs() {
+			node := sys.Node(nodeID)
+			fmt.Printf("  node #%d: CPUs %s, memory %s, distance %v\n",
+				nodeID, node.CPUSet(), node.MemoryType(), node.Distance())
+		}
+	}
+	if isolated := sys.Isolated(); isolated.Size() > 0 {
+		fmt.Printf("isolated CPUs: %s\n", isolated)
+	}
+
+	return nil
+}
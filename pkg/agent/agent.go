@@ -18,6 +18,9 @@ package agent
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/intel/cri-resource-manager/pkg/log"
 	k8sclient "k8s.io/client-go/kubernetes"
@@ -29,6 +32,9 @@ type getConfigFn func() resmgrConfig
 // resmgrConfig represents cri-resmgr configuration
 type resmgrConfig map[string]string
 
+// podResources represents the resource requirements of pods, JSON-encoded, by pod UID
+type podResources map[string]string
+
 // ResourceManagerAgent is the interface exposed for the CRI Resource Manager Congig Agent
 type ResourceManagerAgent interface {
 	Run() error
@@ -41,6 +47,7 @@ type agent struct {
 	server     agentServer          // gRPC server listening for requests from cri-resource-manager
 	watcher    k8sWatcher           // Watcher monitoring events in K8s cluster
 	updater    configUpdater        // Client sending config updates to cri-resource-manager
+	status     *nodeStatus          // Publisher of cri-resource-manager status as node labels
 }
 
 // NewResourceManagerAgent creates a new instance of ResourceManagerAgent
@@ -59,11 +66,15 @@ func NewResourceManagerAgent() (ResourceManagerAgent, error) {
 		return nil, agentError("failed to initialize watcher instance: %v", err)
 	}
 
-	if a.server, err = newAgentServer(a.cli, a.watcher.GetConfig); err != nil {
+	if opts.nodeStatus {
+		a.status = newNodeStatus(a.cli)
+	}
+
+	if a.server, err = newAgentServer(a.cli, a.watcher.GetConfig, a.status); err != nil {
 		return nil, agentError("failed to initialize gRPC server")
 	}
 
-	if a.updater, err = newConfigUpdater(opts.resmgrSocket); err != nil {
+	if a.updater, err = newConfigUpdater(a.cli, opts.resmgrSocket); err != nil {
 		return nil, agentError("failed to initialize config updater instance: %v", err)
 	}
 
@@ -86,9 +97,28 @@ func (a *agent) Run() error {
 		return agentError("failed to start config updater: %v", err)
 	}
 
+	if a.status != nil {
+		a.status.Start()
+	}
+
+	// clean up our node labels and taints on shutdown
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
 	for {
-		config := <-a.watcher.ConfigChan()
-		a.updater.Update(&config)
+		select {
+		case sig := <-signals:
+			a.Info("received signal %v, shutting down...", sig)
+			if a.status != nil {
+				a.status.Stop()
+			}
+			a.server.Stop()
+			return nil
+		case config := <-a.watcher.ConfigChan():
+			a.updater.Update(&config)
+		case pods := <-a.watcher.PodResourcesChan():
+			a.updater.UpdatePodResources(pods)
+		}
 	}
 }
 
@@ -360,6 +360,266 @@ func (m *GetConfigReply) GetConfig() map[string]string {
 	return nil
 }
 
+type EmitEventRequest struct {
+	Namespace            string   `protobuf:"bytes,1,opt,name=namespace" json:"namespace,omitempty"`
+	PodName              string   `protobuf:"bytes,2,opt,name=pod_name,json=podName" json:"pod_name,omitempty"`
+	PodUid               string   `protobuf:"bytes,3,opt,name=pod_uid,json=podUid" json:"pod_uid,omitempty"`
+	Type                 string   `protobuf:"bytes,4,opt,name=type" json:"type,omitempty"`
+	Reason               string   `protobuf:"bytes,5,opt,name=reason" json:"reason,omitempty"`
+	Message              string   `protobuf:"bytes,6,opt,name=message" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EmitEventRequest) Reset()         { *m = EmitEventRequest{} }
+func (m *EmitEventRequest) String() string { return proto.CompactTextString(m) }
+func (*EmitEventRequest) ProtoMessage()    {}
+func (*EmitEventRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_api_84a67403567a5985, []int{9}
+}
+func (m *EmitEventRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EmitEventRequest.Unmarshal(m, b)
+}
+func (m *EmitEventRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EmitEventRequest.Marshal(b, m, deterministic)
+}
+func (dst *EmitEventRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EmitEventRequest.Merge(dst, src)
+}
+func (m *EmitEventRequest) XXX_Size() int {
+	return xxx_messageInfo_EmitEventRequest.Size(m)
+}
+func (m *EmitEventRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_EmitEventRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EmitEventRequest proto.InternalMessageInfo
+
+func (m *EmitEventRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *EmitEventRequest) GetPodName() string {
+	if m != nil {
+		return m.PodName
+	}
+	return ""
+}
+
+func (m *EmitEventRequest) GetPodUid() string {
+	if m != nil {
+		return m.PodUid
+	}
+	return ""
+}
+
+func (m *EmitEventRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *EmitEventRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *EmitEventRequest) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type EmitEventReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EmitEventReply) Reset()         { *m = EmitEventReply{} }
+func (m *EmitEventReply) String() string { return proto.CompactTextString(m) }
+func (*EmitEventReply) ProtoMessage()    {}
+func (*EmitEventReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_api_84a67403567a5985, []int{10}
+}
+func (m *EmitEventReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EmitEventReply.Unmarshal(m, b)
+}
+func (m *EmitEventReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EmitEventReply.Marshal(b, m, deterministic)
+}
+func (dst *EmitEventReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EmitEventReply.Merge(dst, src)
+}
+func (m *EmitEventReply) XXX_Size() int {
+	return xxx_messageInfo_EmitEventReply.Size(m)
+}
+func (m *EmitEventReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_EmitEventReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EmitEventReply proto.InternalMessageInfo
+
+type UpdateNodeResourceTopologyRequest struct {
+	Zones                string   `protobuf:"bytes,1,opt,name=zones" json:"zones,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateNodeResourceTopologyRequest) Reset()         { *m = UpdateNodeResourceTopologyRequest{} }
+func (m *UpdateNodeResourceTopologyRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateNodeResourceTopologyRequest) ProtoMessage()    {}
+func (*UpdateNodeResourceTopologyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_api_84a67403567a5985, []int{11}
+}
+func (m *UpdateNodeResourceTopologyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateNodeResourceTopologyRequest.Unmarshal(m, b)
+}
+func (m *UpdateNodeResourceTopologyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateNodeResourceTopologyRequest.Marshal(b, m, deterministic)
+}
+func (dst *UpdateNodeResourceTopologyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateNodeResourceTopologyRequest.Merge(dst, src)
+}
+func (m *UpdateNodeResourceTopologyRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdateNodeResourceTopologyRequest.Size(m)
+}
+func (m *UpdateNodeResourceTopologyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateNodeResourceTopologyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateNodeResourceTopologyRequest proto.InternalMessageInfo
+
+func (m *UpdateNodeResourceTopologyRequest) GetZones() string {
+	if m != nil {
+		return m.Zones
+	}
+	return ""
+}
+
+type UpdateNodeResourceTopologyReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateNodeResourceTopologyReply) Reset()         { *m = UpdateNodeResourceTopologyReply{} }
+func (m *UpdateNodeResourceTopologyReply) String() string { return proto.CompactTextString(m) }
+func (*UpdateNodeResourceTopologyReply) ProtoMessage()    {}
+func (*UpdateNodeResourceTopologyReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_api_84a67403567a5985, []int{12}
+}
+func (m *UpdateNodeResourceTopologyReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateNodeResourceTopologyReply.Unmarshal(m, b)
+}
+func (m *UpdateNodeResourceTopologyReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateNodeResourceTopologyReply.Marshal(b, m, deterministic)
+}
+func (dst *UpdateNodeResourceTopologyReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateNodeResourceTopologyReply.Merge(dst, src)
+}
+func (m *UpdateNodeResourceTopologyReply) XXX_Size() int {
+	return xxx_messageInfo_UpdateNodeResourceTopologyReply.Size(m)
+}
+func (m *UpdateNodeResourceTopologyReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateNodeResourceTopologyReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateNodeResourceTopologyReply proto.InternalMessageInfo
+
+type UpdateNodeStatusRequest struct {
+	Policy               string   `protobuf:"bytes,1,opt,name=policy" json:"policy,omitempty"`
+	Mode                 string   `protobuf:"bytes,2,opt,name=mode" json:"mode,omitempty"`
+	MemoryTypes          string   `protobuf:"bytes,3,opt,name=memory_types,json=memoryTypes" json:"memory_types,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateNodeStatusRequest) Reset()         { *m = UpdateNodeStatusRequest{} }
+func (m *UpdateNodeStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateNodeStatusRequest) ProtoMessage()    {}
+func (*UpdateNodeStatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_api_84a67403567a5985, []int{13}
+}
+func (m *UpdateNodeStatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateNodeStatusRequest.Unmarshal(m, b)
+}
+func (m *UpdateNodeStatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateNodeStatusRequest.Marshal(b, m, deterministic)
+}
+func (dst *UpdateNodeStatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateNodeStatusRequest.Merge(dst, src)
+}
+func (m *UpdateNodeStatusRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdateNodeStatusRequest.Size(m)
+}
+func (m *UpdateNodeStatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateNodeStatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateNodeStatusRequest proto.InternalMessageInfo
+
+func (m *UpdateNodeStatusRequest) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+func (m *UpdateNodeStatusRequest) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *UpdateNodeStatusRequest) GetMemoryTypes() string {
+	if m != nil {
+		return m.MemoryTypes
+	}
+	return ""
+}
+
+type UpdateNodeStatusReply struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdateNodeStatusReply) Reset()         { *m = UpdateNodeStatusReply{} }
+func (m *UpdateNodeStatusReply) String() string { return proto.CompactTextString(m) }
+func (*UpdateNodeStatusReply) ProtoMessage()    {}
+func (*UpdateNodeStatusReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_api_84a67403567a5985, []int{14}
+}
+func (m *UpdateNodeStatusReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateNodeStatusReply.Unmarshal(m, b)
+}
+func (m *UpdateNodeStatusReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateNodeStatusReply.Marshal(b, m, deterministic)
+}
+func (dst *UpdateNodeStatusReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateNodeStatusReply.Merge(dst, src)
+}
+func (m *UpdateNodeStatusReply) XXX_Size() int {
+	return xxx_messageInfo_UpdateNodeStatusReply.Size(m)
+}
+func (m *UpdateNodeStatusReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateNodeStatusReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateNodeStatusReply proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterType((*GetNodeRequest)(nil), "v1.GetNodeRequest")
 	proto.RegisterType((*GetNodeReply)(nil), "v1.GetNodeReply")
@@ -372,6 +632,12 @@ func init() {
 	proto.RegisterType((*GetConfigRequest)(nil), "v1.GetConfigRequest")
 	proto.RegisterType((*GetConfigReply)(nil), "v1.GetConfigReply")
 	proto.RegisterMapType((map[string]string)(nil), "v1.GetConfigReply.ConfigEntry")
+	proto.RegisterType((*EmitEventRequest)(nil), "v1.EmitEventRequest")
+	proto.RegisterType((*EmitEventReply)(nil), "v1.EmitEventReply")
+	proto.RegisterType((*UpdateNodeResourceTopologyRequest)(nil), "v1.UpdateNodeResourceTopologyRequest")
+	proto.RegisterType((*UpdateNodeResourceTopologyReply)(nil), "v1.UpdateNodeResourceTopologyReply")
+	proto.RegisterType((*UpdateNodeStatusRequest)(nil), "v1.UpdateNodeStatusRequest")
+	proto.RegisterType((*UpdateNodeStatusReply)(nil), "v1.UpdateNodeStatusReply")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -389,6 +655,9 @@ type AgentClient interface {
 	PatchNode(ctx context.Context, in *PatchNodeRequest, opts ...grpc.CallOption) (*PatchNodeReply, error)
 	UpdateNodeCapacity(ctx context.Context, in *UpdateNodeCapacityRequest, opts ...grpc.CallOption) (*UpdateNodeCapacityReply, error)
 	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigReply, error)
+	EmitEvent(ctx context.Context, in *EmitEventRequest, opts ...grpc.CallOption) (*EmitEventReply, error)
+	UpdateNodeResourceTopology(ctx context.Context, in *UpdateNodeResourceTopologyRequest, opts ...grpc.CallOption) (*UpdateNodeResourceTopologyReply, error)
+	UpdateNodeStatus(ctx context.Context, in *UpdateNodeStatusRequest, opts ...grpc.CallOption) (*UpdateNodeStatusReply, error)
 }
 
 type agentClient struct {
@@ -435,6 +704,33 @@ func (c *agentClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts
 	return out, nil
 }
 
+func (c *agentClient) EmitEvent(ctx context.Context, in *EmitEventRequest, opts ...grpc.CallOption) (*EmitEventReply, error) {
+	out := new(EmitEventReply)
+	err := grpc.Invoke(ctx, "/v1.Agent/EmitEvent", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentClient) UpdateNodeResourceTopology(ctx context.Context, in *UpdateNodeResourceTopologyRequest, opts ...grpc.CallOption) (*UpdateNodeResourceTopologyReply, error) {
+	out := new(UpdateNodeResourceTopologyReply)
+	err := grpc.Invoke(ctx, "/v1.Agent/UpdateNodeResourceTopology", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentClient) UpdateNodeStatus(ctx context.Context, in *UpdateNodeStatusRequest, opts ...grpc.CallOption) (*UpdateNodeStatusReply, error) {
+	out := new(UpdateNodeStatusReply)
+	err := grpc.Invoke(ctx, "/v1.Agent/UpdateNodeStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Agent service
 
 type AgentServer interface {
@@ -442,6 +738,9 @@ type AgentServer interface {
 	PatchNode(context.Context, *PatchNodeRequest) (*PatchNodeReply, error)
 	UpdateNodeCapacity(context.Context, *UpdateNodeCapacityRequest) (*UpdateNodeCapacityReply, error)
 	GetConfig(context.Context, *GetConfigRequest) (*GetConfigReply, error)
+	EmitEvent(context.Context, *EmitEventRequest) (*EmitEventReply, error)
+	UpdateNodeResourceTopology(context.Context, *UpdateNodeResourceTopologyRequest) (*UpdateNodeResourceTopologyReply, error)
+	UpdateNodeStatus(context.Context, *UpdateNodeStatusRequest) (*UpdateNodeStatusReply, error)
 }
 
 func RegisterAgentServer(s *grpc.Server, srv AgentServer) {
@@ -520,6 +819,60 @@ func _Agent_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Agent_EmitEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmitEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).EmitEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.Agent/EmitEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).EmitEvent(ctx, req.(*EmitEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agent_UpdateNodeResourceTopology_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateNodeResourceTopologyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).UpdateNodeResourceTopology(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.Agent/UpdateNodeResourceTopology",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).UpdateNodeResourceTopology(ctx, req.(*UpdateNodeResourceTopologyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agent_UpdateNodeStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateNodeStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).UpdateNodeStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.Agent/UpdateNodeStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).UpdateNodeStatus(ctx, req.(*UpdateNodeStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Agent_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "v1.Agent",
 	HandlerType: (*AgentServer)(nil),
@@ -540,6 +893,18 @@ var _Agent_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetConfig",
 			Handler:    _Agent_GetConfig_Handler,
 		},
+		{
+			MethodName: "EmitEvent",
+			Handler:    _Agent_EmitEvent_Handler,
+		},
+		{
+			MethodName: "UpdateNodeResourceTopology",
+			Handler:    _Agent_UpdateNodeResourceTopology_Handler,
+		},
+		{
+			MethodName: "UpdateNodeStatus",
+			Handler:    _Agent_UpdateNodeStatus_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/agent/api/v1/api.proto",
@@ -548,32 +913,46 @@ var _Agent_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("pkg/agent/api/v1/api.proto", fileDescriptor_api_84a67403567a5985) }
 
 var fileDescriptor_api_84a67403567a5985 = []byte{
-	// 419 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x53, 0x4d, 0xab, 0xd3, 0x50,
-	0x10, 0x7d, 0xb9, 0x7d, 0x1f, 0x66, 0x9e, 0x3e, 0xc3, 0xf0, 0xc0, 0x34, 0x45, 0x29, 0x77, 0x63,
-	0x37, 0xa6, 0xa4, 0x82, 0x9f, 0xb8, 0xd0, 0x52, 0x0a, 0x82, 0x45, 0x02, 0x6e, 0xdc, 0xc8, 0x35,
-	0xbd, 0xb6, 0xa1, 0x6d, 0xee, 0xb5, 0xbd, 0x0d, 0x64, 0xe7, 0x4f, 0x71, 0xeb, 0xbf, 0x94, 0xc9,
-	0x97, 0x69, 0x6a, 0x05, 0x57, 0x9d, 0x9e, 0x99, 0x39, 0x73, 0xcf, 0x9c, 0x09, 0x78, 0x7a, 0xb5,
-	0x18, 0x8a, 0x85, 0x4c, 0xcc, 0x50, 0xe8, 0x78, 0x98, 0x06, 0xf4, 0xe3, 0xeb, 0xad, 0x32, 0x0a,
-	0x59, 0x1a, 0x70, 0x07, 0x6e, 0xa6, 0xd2, 0xcc, 0xd4, 0x5c, 0x86, 0xf2, 0xfb, 0x5e, 0xee, 0x0c,
-	0xe7, 0x70, 0xb7, 0x46, 0xf4, 0x3a, 0x43, 0x84, 0xf3, 0x44, 0xcd, 0xa5, 0x6b, 0xf5, 0xad, 0x81,
-	0x1d, 0xe6, 0x31, 0x9f, 0x80, 0xfd, 0x7e, 0xa7, 0x92, 0x8f, 0xc2, 0x44, 0x4b, 0xbc, 0x01, 0xa6,
-	0x74, 0x99, 0x66, 0x4a, 0x53, 0x83, 0x16, 0x66, 0xe9, 0xb2, 0xa2, 0x81, 0x62, 0xbc, 0x85, 0x8b,
-	0x54, 0xac, 0xf7, 0xd2, 0xed, 0xe4, 0x60, 0xf1, 0x87, 0xbf, 0x06, 0x27, 0xa7, 0x68, 0x8c, 0xc7,
-	0xc7, 0x70, 0xa5, 0x09, 0x93, 0x3b, 0xd7, 0xea, 0x77, 0x06, 0xd7, 0xa3, 0x7b, 0x7e, 0x1a, 0xf8,
-	0xf5, 0xb4, 0xb0, 0xca, 0xd2, 0xcb, 0x1b, 0xcd, 0x7a, 0x9d, 0xf1, 0x5f, 0x16, 0x74, 0x3f, 0xe9,
-	0xb9, 0x30, 0x92, 0xb0, 0xb1, 0xd0, 0x22, 0x8a, 0x4d, 0x56, 0x11, 0x7f, 0x00, 0x88, 0x0a, 0x28,
-	0xae, 0xb9, 0x9f, 0x10, 0xf7, 0xc9, 0x16, 0x7f, 0x5c, 0xd7, 0x4f, 0x12, 0xb3, 0xcd, 0xc2, 0x06,
-	0x81, 0xf7, 0x06, 0xee, 0xb7, 0xd2, 0xe8, 0x40, 0x67, 0x25, 0xb3, 0x72, 0x13, 0x14, 0xfe, 0x91,
-	0xcd, 0x1a, 0xb2, 0x5f, 0xb1, 0x17, 0x16, 0xef, 0xc2, 0x83, 0xbf, 0xcd, 0x25, 0x19, 0x08, 0xce,
-	0x54, 0x9a, 0xb1, 0x4a, 0xbe, 0xc5, 0x8b, 0xca, 0x94, 0x9f, 0x56, 0xee, 0x53, 0x05, 0x92, 0x2f,
-	0x3d, 0xb0, 0xc9, 0x8b, 0x2f, 0x89, 0xd8, 0x54, 0xe6, 0xdc, 0x21, 0x60, 0x26, 0x36, 0x12, 0x9f,
-	0xc1, 0x65, 0x94, 0xd7, 0xba, 0x2c, 0x17, 0xfa, 0x88, 0x84, 0x1e, 0x12, 0xf8, 0x45, 0x5c, 0x28,
-	0x2b, 0xab, 0xbd, 0x97, 0x70, 0xdd, 0x80, 0xff, 0x47, 0xd1, 0xe8, 0x07, 0x83, 0x8b, 0xb7, 0x74,
-	0x68, 0x18, 0xc0, 0x55, 0x79, 0x41, 0x88, 0xe5, 0xdc, 0x86, 0xc3, 0x9e, 0x73, 0x80, 0x91, 0xe2,
-	0x33, 0x7c, 0x0e, 0x76, 0x6d, 0x26, 0xde, 0x52, 0x41, 0xfb, 0x30, 0x3c, 0x6c, 0xa1, 0x45, 0x63,
-	0x08, 0x78, 0xbc, 0x47, 0x7c, 0xf8, 0x4f, 0x5f, 0xbd, 0xde, 0xa9, 0x74, 0xfd, 0x98, 0x7a, 0x55,
-	0xc5, 0x63, 0xda, 0x7e, 0x78, 0x78, 0xbc, 0x4f, 0x7e, 0xf6, 0xee, 0xfc, 0x33, 0x4b, 0x83, 0xaf,
-	0x97, 0xf9, 0xd7, 0xf5, 0xf4, 0x77, 0x00, 0x00, 0x00, 0xff, 0xff, 0xc3, 0xd9, 0x20, 0x97, 0x7b,
-	0x03, 0x00, 0x00,
+	// 641 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x03, 0x95, 0x54, 0x4b, 0x6f, 0xd3, 0x40,
+	0x10, 0x6e, 0xde, 0xcd, 0xa4, 0x14, 0x6b, 0x54, 0x88, 0xe3, 0xf2, 0x8a, 0x11, 0xa2, 0x17, 0x12,
+	0xa5, 0x48, 0x40, 0x41, 0x1c, 0x20, 0x8a, 0x90, 0x10, 0x54, 0xc8, 0xb4, 0x97, 0x5e, 0x22, 0xe3,
+	0x2c, 0x89, 0x69, 0xe2, 0x35, 0xf1, 0x26, 0x92, 0xf9, 0x35, 0x5c, 0xe1, 0xef, 0xf1, 0x07, 0xd8,
+	0xf1, 0xda, 0x8e, 0xe3, 0x34, 0x45, 0x9c, 0x3c, 0x33, 0x3b, 0xaf, 0x6f, 0xe6, 0x1b, 0x83, 0xe1,
+	0x5f, 0x8e, 0xbb, 0xf6, 0x98, 0x79, 0xa2, 0x6b, 0xfb, 0x6e, 0x77, 0xd9, 0xa3, 0x4f, 0xc7, 0x9f,
+	0x73, 0xc1, 0xb1, 0xb8, 0xec, 0x99, 0x1a, 0xec, 0xbf, 0x63, 0xe2, 0x94, 0x8f, 0x98, 0xc5, 0xbe,
+	0x2f, 0x58, 0x20, 0x4c, 0x13, 0xf6, 0x52, 0x8b, 0x3f, 0x0d, 0x11, 0xa1, 0xec, 0x49, 0x45, 0x2f,
+	0x3c, 0x28, 0x1c, 0xd5, 0xad, 0x48, 0x36, 0x07, 0x50, 0x7f, 0x1f, 0x70, 0xef, 0x93, 0x2d, 0x9c,
+	0x09, 0xee, 0x43, 0x91, 0xfb, 0xf1, 0xb3, 0x94, 0x28, 0xc0, 0xb7, 0xc5, 0x44, 0x2f, 0xaa, 0x00,
+	0x92, 0xf1, 0x00, 0x2a, 0x4b, 0x7b, 0xba, 0x60, 0x7a, 0x29, 0x32, 0x2a, 0xc5, 0x7c, 0x05, 0x5a,
+	0x94, 0x22, 0x53, 0x1e, 0x1f, 0x43, 0xcd, 0x27, 0x1b, 0x0b, 0x64, 0xca, 0xd2, 0x51, 0xe3, 0xf8,
+	0x46, 0x67, 0xd9, 0xeb, 0xa4, 0xd5, 0xac, 0xe4, 0x95, 0x3a, 0xcf, 0x04, 0xcb, 0x4e, 0xcd, 0x5f,
+	0x05, 0x68, 0x9d, 0xfb, 0x23, 0x5b, 0x30, 0xb2, 0xf5, 0x6d, 0xdf, 0x76, 0x5c, 0x11, 0x26, 0x89,
+	0x3f, 0x02, 0x38, 0xca, 0xe4, 0xa6, 0xb9, 0x9f, 0x50, 0xee, 0xad, 0x21, 0x9d, 0x7e, 0xea, 0x3f,
+	0xf0, 0xc4, 0x3c, 0xb4, 0x32, 0x09, 0x8c, 0xd7, 0x70, 0x33, 0xf7, 0x8c, 0x1a, 0x94, 0x2e, 0x59,
+	0x18, 0x4f, 0x82, 0xc4, 0x15, 0xec, 0x62, 0x06, 0xf6, 0xcb, 0xe2, 0x8b, 0x82, 0xd9, 0x82, 0xe6,
+	0x55, 0x75, 0x09, 0x06, 0x82, 0x26, 0x17, 0xd0, 0xe7, 0xde, 0x57, 0x77, 0x9c, 0x2c, 0xe5, 0x67,
+	0x21, 0xda, 0x53, 0x62, 0xa4, 0xbd, 0x1c, 0x42, 0x9d, 0x76, 0x31, 0xf4, 0xec, 0x59, 0xb2, 0x9c,
+	0x5d, 0x32, 0x9c, 0x4a, 0x1d, 0x9f, 0x41, 0xd5, 0x89, 0x7c, 0x65, 0x65, 0x02, 0x7a, 0x8f, 0x80,
+	0xae, 0x27, 0xe8, 0x28, 0x59, 0x21, 0x8b, 0xbd, 0x8d, 0x13, 0x68, 0x64, 0xcc, 0xff, 0x85, 0xe8,
+	0x77, 0x01, 0xb4, 0xc1, 0xcc, 0x15, 0x83, 0xa5, 0x24, 0x5b, 0x32, 0xf4, 0x3b, 0xb2, 0x49, 0xd9,
+	0x4f, 0x20, 0xf1, 0x25, 0x4d, 0xae, 0x0c, 0xd8, 0x82, 0x5d, 0x9f, 0x8f, 0x14, 0x02, 0x95, 0xaf,
+	0x26, 0xf5, 0x08, 0x40, 0x13, 0x48, 0x1c, 0x2e, 0xdc, 0x51, 0x4c, 0x99, 0xaa, 0x54, 0xcf, 0xdd,
+	0x11, 0xb1, 0x4b, 0x84, 0x3e, 0xd3, 0xcb, 0x8a, 0x5d, 0x24, 0xe3, 0x6d, 0xa8, 0xce, 0x99, 0x2d,
+	0x29, 0xa2, 0x57, 0x94, 0xaf, 0xd2, 0x50, 0x87, 0x9a, 0x2c, 0x15, 0x48, 0xfa, 0xeb, 0x55, 0x95,
+	0x3e, 0x56, 0x89, 0x3c, 0x99, 0x5e, 0x69, 0xea, 0x27, 0xd0, 0x5e, 0x2d, 0xc4, 0x62, 0x01, 0x5f,
+	0xcc, 0x1d, 0x76, 0xc6, 0x7d, 0x3e, 0xe5, 0xe3, 0x94, 0x43, 0x12, 0xfd, 0x0f, 0xee, 0x45, 0xf4,
+	0x89, 0xd0, 0x47, 0x8a, 0xd9, 0x86, 0xfb, 0xd7, 0x85, 0x52, 0xf6, 0x49, 0x76, 0xdd, 0x9f, 0x85,
+	0x2d, 0x16, 0x41, 0x92, 0x53, 0x36, 0x2f, 0x3d, 0x5d, 0x27, 0x19, 0x73, 0xac, 0x11, 0xd0, 0x19,
+	0xdd, 0x5d, 0x7c, 0x46, 0x24, 0x63, 0x1b, 0xf6, 0x66, 0x6c, 0xc6, 0xe7, 0xe1, 0x90, 0x70, 0x07,
+	0xf1, 0x68, 0x1a, 0xca, 0x76, 0x46, 0x26, 0xb3, 0x09, 0xb7, 0x36, 0x2b, 0xc9, 0x16, 0x8e, 0xff,
+	0x94, 0xa0, 0xf2, 0x86, 0x7e, 0x04, 0xd8, 0x83, 0x5a, 0x7c, 0xe1, 0x88, 0x31, 0x2f, 0x32, 0x17,
+	0x68, 0x68, 0x6b, 0x36, 0xea, 0x7e, 0x07, 0x9f, 0x43, 0x3d, 0x3d, 0x36, 0x3c, 0x20, 0x87, 0xfc,
+	0xe1, 0x1a, 0x98, 0xb3, 0xaa, 0x40, 0x0b, 0x70, 0x93, 0xe7, 0x78, 0xf7, 0xda, 0xbb, 0x33, 0x0e,
+	0xb7, 0x3d, 0xa7, 0xcd, 0xa4, 0x54, 0x56, 0xcd, 0xe4, 0xef, 0xc5, 0xc0, 0x4d, 0xbe, 0xab, 0xc0,
+	0x74, 0xeb, 0x2a, 0x30, 0x4f, 0x58, 0x15, 0x98, 0xa3, 0xc6, 0x0e, 0x7e, 0x03, 0x63, 0xfb, 0x86,
+	0xf1, 0xd1, 0x7a, 0xbb, 0x5b, 0xc8, 0x63, 0x3c, 0xfc, 0x97, 0x9b, 0xaa, 0xf5, 0x01, 0xb4, 0xfc,
+	0x02, 0x31, 0x37, 0x90, 0x35, 0x02, 0x19, 0xad, 0xab, 0x1f, 0xa3, 0x6c, 0x6f, 0xcb, 0x17, 0xf2,
+	0x2f, 0xff, 0xa5, 0x1a, 0xfd, 0xf0, 0x9f, 0xfe, 0x05, 0x80, 0x60, 0xb3, 0xa6, 0x0e, 0x06, 0x00,
+	0x00,
 }
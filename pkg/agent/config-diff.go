@@ -0,0 +1,168 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// configDiff is the difference between two cri-resmgr configurations.
+type configDiff struct {
+	Added    []string            `json:"added,omitempty"`    // added configuration keys
+	Removed  []string            `json:"removed,omitempty"`  // removed configuration keys
+	Modified map[string][]string `json:"modified,omitempty"` // changed lines of modified keys
+}
+
+// diffConfig computes the difference between the running and a proposed configuration.
+func diffConfig(running, proposed *resmgrConfig) *configDiff {
+	d := &configDiff{Modified: map[string][]string{}}
+
+	var cur, next resmgrConfig
+	if running != nil {
+		cur = *running
+	}
+	if proposed != nil {
+		next = *proposed
+	}
+
+	for key, value := range next {
+		prev, ok := cur[key]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, key)
+		case prev != value:
+			d.Modified[key] = diffLines(prev, value)
+		}
+	}
+	for key := range cur {
+		if _, ok := next[key]; !ok {
+			d.Removed = append(d.Removed, key)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+
+	return d
+}
+
+// Empty returns true if there are no differences.
+func (d *configDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// String returns a one-line summary of the differences.
+func (d *configDiff) String() string {
+	if d.Empty() {
+		return "no changes"
+	}
+
+	parts := []string{}
+	if len(d.Added) > 0 {
+		parts = append(parts, "added "+strings.Join(d.Added, ","))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, "removed "+strings.Join(d.Removed, ","))
+	}
+	if len(d.Modified) > 0 {
+		modified := []string{}
+		for _, key := range d.modifiedKeys() {
+			added, removed := 0, 0
+			for _, line := range d.Modified[key] {
+				if strings.HasPrefix(line, "+") {
+					added++
+				} else {
+					removed++
+				}
+			}
+			modified = append(modified, fmt.Sprintf("%s (+%d/-%d lines)", key, added, removed))
+		}
+		parts = append(parts, "modified "+strings.Join(modified, ","))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// Details returns the changed lines of all modified keys.
+func (d *configDiff) Details() string {
+	details := ""
+	for _, key := range d.modifiedKeys() {
+		details += key + ":\n"
+		for _, line := range d.Modified[key] {
+			details += "  " + line + "\n"
+		}
+	}
+	return details
+}
+
+// modifiedKeys returns the modified configuration keys, sorted.
+func (d *configDiff) modifiedKeys() []string {
+	keys := make([]string, 0, len(d.Modified))
+	for key := range d.Modified {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffLines returns the removed and added lines between two texts, prefixed by - and +.
+func diffLines(before, after string) []string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	// longest common subsequence of lines, computed backwards
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+
+	return diff
+}
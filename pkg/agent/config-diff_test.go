@@ -0,0 +1,124 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffConfig(t *testing.T) {
+	running := &resmgrConfig{
+		"policy": "Active: topology-aware\nReservedResources:\n  CPU: 750m",
+		"rdt":    "options:\n  l3:\n    optional: true",
+		"logger": "Debug: resource-manager",
+	}
+	proposed := &resmgrConfig{
+		"policy":   "Active: topology-aware\nReservedResources:\n  CPU: 1",
+		"logger":   "Debug: resource-manager",
+		"blockio":  "Classes: {}",
+		"dump":     "Config: off",
+		"cpu":      "Classes: {}",
+		"pressure": "",
+	}
+
+	d := diffConfig(running, proposed)
+	if expected := []string{"blockio", "cpu", "dump", "pressure"}; !reflect.DeepEqual(d.Added, expected) {
+		t.Errorf("expected added keys %v, got %v", expected, d.Added)
+	}
+	if expected := []string{"rdt"}; !reflect.DeepEqual(d.Removed, expected) {
+		t.Errorf("expected removed keys %v, got %v", expected, d.Removed)
+	}
+	if expected := []string{"-  CPU: 750m", "+  CPU: 1"}; !reflect.DeepEqual(d.Modified["policy"], expected) {
+		t.Errorf("expected policy diff %v, got %v", expected, d.Modified["policy"])
+	}
+	if _, ok := d.Modified["logger"]; ok {
+		t.Errorf("unexpected diff for unchanged logger configuration")
+	}
+
+	summary := d.String()
+	for _, part := range []string{"added blockio,cpu,dump,pressure", "removed rdt", "modified policy (+1/-1 lines)"} {
+		if !strings.Contains(summary, part) {
+			t.Errorf("expected %q in summary %q", part, summary)
+		}
+	}
+	if details := d.Details(); details != "policy:\n  -  CPU: 750m\n  +  CPU: 1\n" {
+		t.Errorf("unexpected details %q", details)
+	}
+}
+
+func TestDiffConfigEmpty(t *testing.T) {
+	cfg := &resmgrConfig{"policy": "Active: none"}
+
+	if d := diffConfig(cfg, cfg); !d.Empty() || d.String() != "no changes" {
+		t.Errorf("expected no changes, got %s", d)
+	}
+	if d := diffConfig(nil, nil); !d.Empty() {
+		t.Errorf("expected no changes between empty configurations, got %s", d)
+	}
+	if d := diffConfig(nil, cfg); !reflect.DeepEqual(d.Added, []string{"policy"}) {
+		t.Errorf("expected policy to be added to the initial configuration, got %s", d)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tcases := []struct {
+		name     string
+		before   string
+		after    string
+		expected []string
+	}{
+		{
+			name:     "identical",
+			before:   "a\nb\nc",
+			after:    "a\nb\nc",
+			expected: []string{},
+		},
+		{
+			name:     "line inserted",
+			before:   "a\nc",
+			after:    "a\nb\nc",
+			expected: []string{"+b"},
+		},
+		{
+			name:     "line removed",
+			before:   "a\nb\nc",
+			after:    "a\nc",
+			expected: []string{"-b"},
+		},
+		{
+			name:     "line changed",
+			before:   "a\nb\nc",
+			after:    "a\nx\nc",
+			expected: []string{"-b", "+x"},
+		},
+		{
+			name:     "trailing lines",
+			before:   "a",
+			after:    "a\nb\nc",
+			expected: []string{"+b", "+c"},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := diffLines(tc.before, tc.after); !reflect.DeepEqual(diff, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, diff)
+			}
+		})
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	core_v1 "k8s.io/api/core/v1"
+	k8sclient "k8s.io/client-go/kubernetes"
 
 	resmgr_v1 "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/config/api/v1"
 	"github.com/intel/cri-resource-manager/pkg/log"
@@ -35,6 +37,8 @@ const (
 	setConfigTimeout = 5 * time.Second
 	// retryTimeout is the timeout after we retry sending configuration updates upon failure
 	retryTimeout = 5 * time.Second
+	// maxEventMessage is the maximum length of configuration update event messages
+	maxEventMessage = 1024
 )
 
 // configUpdater handles sending configuration to cri-resmgr
@@ -42,17 +46,21 @@ type configUpdater interface {
 	Start() error
 	Stop()
 	Update(*resmgrConfig)
+	UpdatePodResources(podResources)
 }
 
 // updater implements configUpdater
 type updater struct {
 	log.Logger
+	cli       *k8sclient.Clientset
 	resmgrCli resmgr_v1.ConfigClient
 	newConfig chan *resmgrConfig
+	newPods   chan podResources
+	lastGood  *resmgrConfig // last configuration successfully activated
 }
 
-func newConfigUpdater(socket string) (configUpdater, error) {
-	u := &updater{Logger: log.NewLogger("config-updater")}
+func newConfigUpdater(cli *k8sclient.Clientset, socket string) (configUpdater, error) {
+	u := &updater{Logger: log.NewLogger("config-updater"), cli: cli}
 
 	c, err := newResmgrCli(opts.resmgrSocket)
 	if err != nil {
@@ -61,6 +69,7 @@ func newConfigUpdater(socket string) (configUpdater, error) {
 	u.resmgrCli = c
 
 	u.newConfig = make(chan *resmgrConfig)
+	u.newPods = make(chan podResources)
 
 	return u, nil
 
@@ -71,6 +80,8 @@ func (u *updater) Start() error {
 	go func() {
 		var pending *resmgrConfig
 		var ratelimit <-chan time.Time
+		var pendingPods podResources
+		var podRatelimit <-chan time.Time
 
 		for {
 			select {
@@ -80,16 +91,30 @@ func (u *updater) Start() error {
 				ratelimit = time.After(rateLimitTimeout)
 
 			case _ = <-ratelimit:
-				mgrErr, err := u.setConfig(pending)
-				if err != nil {
-					u.Error("failed to send configuration update: %v", err)
+				if u.applyConfig(pending) {
+					pending = nil
+					ratelimit = nil
+				} else {
 					ratelimit = time.After(retryTimeout)
+				}
+
+			case pods := <-u.newPods:
+				pendingPods = pods
+				if podRatelimit == nil {
+					podRatelimit = time.After(rateLimitTimeout)
+				}
+
+			case _ = <-podRatelimit:
+				mgrErr, err := u.updatePodResources(pendingPods)
+				if err != nil {
+					u.Error("failed to send pod resource update: %v", err)
+					podRatelimit = time.After(retryTimeout)
 				} else {
 					if mgrErr != nil {
 						u.Error("cri-resmgr error: %v", mgrErr)
 					}
-					pending = nil
-					ratelimit = nil
+					pendingPods = nil
+					podRatelimit = nil
 				}
 			}
 		}
@@ -105,6 +130,76 @@ func (u *updater) Update(c *resmgrConfig) {
 	u.newConfig <- c
 }
 
+func (u *updater) UpdatePodResources(pods podResources) {
+	u.newPods <- pods
+}
+
+// applyConfig sends a configuration update, rolling back if it fails to activate.
+//
+// It returns false if the update could not be sent and needs to be retried.
+func (u *updater) applyConfig(cfg *resmgrConfig) bool {
+	diff := diffConfig(u.lastGood, cfg)
+	u.Info("sending configuration update: %s", diff)
+	if details := diff.Details(); details != "" {
+		u.DebugBlock("  ", "%s", details)
+	}
+
+	mgrErr, err := u.setConfig(cfg)
+	switch {
+	case err != nil:
+		u.Error("failed to send configuration update: %v", err)
+		return false
+	case mgrErr != nil:
+		u.Error("cri-resmgr error: %v", mgrErr)
+		u.rollback(diff, mgrErr)
+	default:
+		u.lastGood = cfg
+		u.report(core_v1.EventTypeNormal, "ConfigApplied",
+			"configuration updated: %s", diff)
+	}
+
+	return true
+}
+
+// rollback reverts cri-resmgr to the last known good configuration after a failed update.
+func (u *updater) rollback(diff *configDiff, mgrErr error) {
+	u.report(core_v1.EventTypeWarning, "ConfigFailed",
+		"configuration update (%s) failed: %v", diff, mgrErr)
+
+	if u.lastGood == nil {
+		u.Warn("no last known good configuration to roll back to")
+		return
+	}
+
+	u.Info("rolling back to last known good configuration...")
+	mgrErr, err := u.setConfig(u.lastGood)
+	switch {
+	case err != nil:
+		u.Error("failed to send configuration rollback: %v", err)
+	case mgrErr != nil:
+		u.Error("cri-resmgr error during rollback: %v", mgrErr)
+		u.report(core_v1.EventTypeWarning, "ConfigRollbackFailed",
+			"rollback to last known good configuration failed: %v", mgrErr)
+	default:
+		u.report(core_v1.EventTypeNormal, "ConfigRolledBack",
+			"rolled back to last known good configuration")
+	}
+}
+
+// report reports the outcome of a configuration update as an event for our node.
+func (u *updater) report(eventType, reason, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage-3] + "..."
+	}
+	if u.cli == nil || nodeName == "" {
+		return
+	}
+	if err := createNodeEvent(u.cli, eventType, reason, message); err != nil {
+		u.Warn("failed to create %s event for node %s: %v", reason, nodeName, err)
+	}
+}
+
 func (u *updater) setConfig(cfg *resmgrConfig) (error, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), setConfigTimeout)
 	defer cancel()
@@ -124,6 +219,25 @@ func (u *updater) setConfig(cfg *resmgrConfig) (error, error) {
 	}
 }
 
+func (u *updater) updatePodResources(pods podResources) (error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), setConfigTimeout)
+	defer cancel()
+
+	req := &resmgr_v1.UpdatePodResourcesRequest{Resources: pods}
+	u.Debug("sending UpdatePodResources request to cri-resmgr")
+
+	reply, err := u.resmgrCli.UpdatePodResources(ctx, req, []grpc.CallOption{grpc.FailFast(false)}...)
+
+	switch {
+	case err != nil:
+		return nil, err
+	case reply.Error != "":
+		return fmt.Errorf("%s", reply.Error), nil
+	default:
+		return nil, nil
+	}
+}
+
 func newResmgrCli(socket string) (resmgr_v1.ConfigClient, error) {
 	dialOpts := []grpc.DialOption{
 		grpc.WithInsecure(),
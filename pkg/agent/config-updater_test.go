@@ -0,0 +1,97 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	resmgr_v1 "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/config/api/v1"
+	"github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// fakeResmgr is a cri-resmgr config client which fails configurations on request.
+type fakeResmgr struct {
+	sent    []resmgrConfig // configurations sent
+	reject  string         // reject configurations with this key
+	unavail bool           // fail to send configurations
+}
+
+func (f *fakeResmgr) SetConfig(ctx context.Context, req *resmgr_v1.SetConfigRequest, opts ...grpc.CallOption) (*resmgr_v1.SetConfigReply, error) {
+	if f.unavail {
+		return nil, fmt.Errorf("cri-resmgr unavailable")
+	}
+	f.sent = append(f.sent, resmgrConfig(req.Config))
+	if _, ok := req.Config[f.reject]; ok {
+		return &resmgr_v1.SetConfigReply{Error: "rejected " + f.reject}, nil
+	}
+	return &resmgr_v1.SetConfigReply{}, nil
+}
+
+func (f *fakeResmgr) UpdatePodResources(ctx context.Context, req *resmgr_v1.UpdatePodResourcesRequest, opts ...grpc.CallOption) (*resmgr_v1.UpdatePodResourcesReply, error) {
+	return &resmgr_v1.UpdatePodResourcesReply{}, nil
+}
+
+func TestApplyConfig(t *testing.T) {
+	f := &fakeResmgr{reject: "bad"}
+	u := &updater{Logger: log.NewLogger("config-updater-test"), resmgrCli: f}
+
+	good := &resmgrConfig{"policy": "Active: topology-aware"}
+	if !u.applyConfig(good) {
+		t.Fatalf("failed to send good configuration")
+	}
+	if u.lastGood != good {
+		t.Errorf("expected activated configuration to be the last known good one")
+	}
+
+	bad := &resmgrConfig{"policy": "Active: topology-aware", "bad": "x"}
+	if !u.applyConfig(bad) {
+		t.Fatalf("failed to send bad configuration")
+	}
+	if u.lastGood != good {
+		t.Errorf("expected failed configuration not to become the last known good one")
+	}
+	if len(f.sent) != 3 {
+		t.Fatalf("expected good, bad and rollback configurations to be sent, got %d", len(f.sent))
+	}
+	if _, ok := f.sent[2]["bad"]; ok || f.sent[2]["policy"] != (*good)["policy"] {
+		t.Errorf("expected rollback to last known good configuration, got %v", f.sent[2])
+	}
+
+	f.unavail = true
+	if u.applyConfig(good) {
+		t.Errorf("expected unsent configuration to be retried")
+	}
+}
+
+func TestApplyConfigWithoutLastGood(t *testing.T) {
+	f := &fakeResmgr{reject: "bad"}
+	u := &updater{Logger: log.NewLogger("config-updater-test"), resmgrCli: f}
+
+	if !u.applyConfig(&resmgrConfig{"bad": "x"}) {
+		t.Fatalf("failed to send bad configuration")
+	}
+	if len(f.sent) != 1 {
+		t.Errorf("expected no rollback without a last known good configuration, got %d sent", len(f.sent))
+	}
+	if u.lastGood != nil {
+		t.Errorf("unexpected last known good configuration %v", *u.lastGood)
+	}
+}
@@ -18,6 +18,8 @@ package agent
 
 import (
 	"flag"
+	"time"
+
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/sockets"
 )
@@ -29,6 +31,13 @@ type options struct {
 	configNs      string
 	configMapName string
 	labelName     string
+	mergeConfig   bool
+	podResources  bool
+
+	// publishing of cri-resmgr status as node labels and taints
+	nodeStatus        bool
+	nodeStatusTimeout time.Duration
+	nodeStatusTaint   string
 }
 
 var opts = options{}
@@ -40,4 +49,9 @@ func init() {
 	flag.StringVar(&opts.configNs, "config-ns", "kube-system", "Kubernetes namespace where to look for config")
 	flag.StringVar(&opts.configMapName, "configmap-name", "cri-resmgr-config", "Name of the K8s ConfigMap to watch")
 	flag.StringVar(&opts.labelName, "label-name", kubernetes.ResmgrKey("group"), "Name of the label used to assign a node to a configuration group.")
+	flag.BoolVar(&opts.mergeConfig, "merge-configmaps", false, "Merge the default, group and node ConfigMaps key by key, with more specific ones taking precedence, instead of using only the most specific one.")
+	flag.BoolVar(&opts.podResources, "pod-resources", false, "Push resource requirements of pods on the node to cri-resmgr, making the resource annotating webhook unnecessary.")
+	flag.BoolVar(&opts.nodeStatus, "node-status", false, "Label the node with the active policy, mode and PMEM availability of cri-resmgr, removing the labels on shutdown.")
+	flag.DurationVar(&opts.nodeStatusTimeout, "node-status-timeout", 2*time.Minute, "Time without status updates from cri-resmgr after which its mode is labeled unknown.")
+	flag.StringVar(&opts.nodeStatusTaint, "node-status-taint", "PreferNoSchedule", "Effect of the taint of nodes where cri-resmgr is not in active mode, empty for no taint.")
 }
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	k8swatch "k8s.io/apimachinery/pkg/watch"
@@ -96,6 +97,115 @@ func patchNodeStatus(cli *k8sclient.Clientset, fields map[string]string) error {
 	return err
 }
 
+// createPodEvent is a helper for creating a k8s Event about a Pod
+func createPodEvent(cli *k8sclient.Clientset, req *agent_v1.EmitEventRequest) error {
+	now := meta_v1.NewTime(time.Now())
+	event := &core_v1.Event{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: req.PodName + ".",
+			Namespace:    req.Namespace,
+		},
+		InvolvedObject: core_v1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  req.Namespace,
+			Name:       req.PodName,
+			UID:        types.UID(req.PodUid),
+		},
+		Type:           req.Type,
+		Reason:         req.Reason,
+		Message:        req.Message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: core_v1.EventSource{
+			Component: "cri-resmgr",
+			Host:      nodeName,
+		},
+	}
+
+	_, err := cli.CoreV1().Events(req.Namespace).Create(event)
+
+	return err
+}
+
+// createNodeEvent creates a K8s Event for our node.
+func createNodeEvent(cli *k8sclient.Clientset, eventType, reason, message string) error {
+	now := meta_v1.NewTime(time.Now())
+	event := &core_v1.Event{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: nodeName + ".",
+			Namespace:    meta_v1.NamespaceDefault,
+		},
+		InvolvedObject: core_v1.ObjectReference{
+			Kind:       "Node",
+			APIVersion: "v1",
+			Name:       nodeName,
+			UID:        types.UID(nodeName),
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: core_v1.EventSource{
+			Component: "cri-resmgr-agent",
+			Host:      nodeName,
+		},
+	}
+
+	_, err := cli.CoreV1().Events(meta_v1.NamespaceDefault).Create(event)
+
+	return err
+}
+
+const (
+	// nrtAPIVersion is the API version of NodeResourceTopology custom resources.
+	nrtAPIVersion = "topology.node.k8s.io/v1alpha1"
+	// nrtKind is the kind of NodeResourceTopology custom resources.
+	nrtKind = "NodeResourceTopology"
+	// nrtAPIPath is the REST API path of NodeResourceTopology custom resources.
+	nrtAPIPath = "/apis/" + nrtAPIVersion + "/noderesourcetopologies"
+)
+
+// updateNodeResourceTopology creates or updates the NodeResourceTopology object of our node.
+func updateNodeResourceTopology(cli *k8sclient.Clientset, zones json.RawMessage) error {
+	rc := cli.Discovery().RESTClient()
+	nrt := map[string]interface{}{}
+
+	raw, err := rc.Get().AbsPath(nrtAPIPath, nodeName).Do().Raw()
+	exists := err == nil
+	switch {
+	case exists:
+		if err := json.Unmarshal(raw, &nrt); err != nil {
+			return agentError("failed to unmarshal NodeResourceTopology %q: %v", nodeName, err)
+		}
+	case errors.IsNotFound(err):
+		nrt["apiVersion"] = nrtAPIVersion
+		nrt["kind"] = nrtKind
+		nrt["metadata"] = map[string]interface{}{"name": nodeName}
+	default:
+		return agentError("failed to get NodeResourceTopology %q: %v", nodeName, err)
+	}
+
+	nrt["topologyPolicies"] = []string{"None"}
+	nrt["zones"] = zones
+
+	data, err := json.Marshal(nrt)
+	if err != nil {
+		return agentError("failed to marshal NodeResourceTopology %q: %v", nodeName, err)
+	}
+
+	if exists {
+		err = rc.Put().AbsPath(nrtAPIPath, nodeName).Body(data).Do().Error()
+	} else {
+		err = rc.Post().AbsPath(nrtAPIPath).Body(data).Do().Error()
+	}
+
+	return err
+}
+
 // watch is a wrapper around the k8s watch.Interface
 type watch struct {
 	parent  *watcher
@@ -181,6 +291,29 @@ func newConfigMapWatch(parent *watcher, name string, ns namespace) *watch {
 	return w
 }
 
+// newPodWatch creates a watch for the k8s Pods running on our node
+func newPodWatch(parent *watcher) *watch {
+	w := newWatch(parent, "Pods", namespace(""),
+		func(ns namespace, name string) (k8swatch.Interface, error) {
+			selector := meta_v1.ListOptions{FieldSelector: "spec.nodeName=" + name}
+			k8w, err := parent.k8sCli.CoreV1().Pods(string(ns)).Watch(selector)
+			if err != nil {
+				return nil, err
+			}
+			return k8w, nil
+		},
+		func(ns namespace, name string) (interface{}, error) {
+			selector := meta_v1.ListOptions{FieldSelector: "spec.nodeName=" + name}
+			pods, err := parent.k8sCli.CoreV1().Pods(string(ns)).List(selector)
+			if err != nil {
+				return nil, err
+			}
+			return pods, nil
+		})
+	w.Start(nodeName)
+	return w
+}
+
 func (w *watch) Name() string {
 	ns, name := w.ns, w.name
 	if ns != "" {
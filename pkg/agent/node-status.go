@@ -0,0 +1,276 @@
+/*
+Copyright 2019 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
+	"github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// modeActive is the mode of a cri-resmgr processing requests with its policy.
+	modeActive = "active"
+	// modeUnknown is the mode of a cri-resmgr we haven't heard from recently.
+	modeUnknown = "unknown"
+	// memoryTypePMEM is the memory type of persistent memory.
+	memoryTypePMEM = "pmem"
+)
+
+var (
+	// labelPolicy is the node label for the active cri-resmgr policy.
+	labelPolicy = kubernetes.ResmgrKey("policy")
+	// labelMode is the node label for the mode cri-resmgr operates in.
+	labelMode = kubernetes.ResmgrKey("mode")
+	// labelPMEM is the node label for the availability of persistent memory.
+	labelPMEM = kubernetes.ResmgrKey("pmem")
+	// taintDegraded is the key of the node taint for a cri-resmgr not in active mode.
+	taintDegraded = kubernetes.ResmgrKey("degraded")
+)
+
+// nodeStatus publishes the status of cri-resmgr as labels and a taint of our node.
+type nodeStatus struct {
+	log.Logger
+	sync.Mutex
+	cli    k8sclient.Interface // K8s client
+	labels map[string]string   // labels we have set
+	mode   string              // last published mode
+	last   time.Time           // time of the last status update
+	stop   chan struct{}       // channel for stopping staleness checks
+}
+
+// newNodeStatus creates a new node status publisher.
+func newNodeStatus(cli k8sclient.Interface) *nodeStatus {
+	return &nodeStatus{
+		Logger: log.NewLogger("node-status"),
+		cli:    cli,
+		labels: map[string]string{},
+	}
+}
+
+// Start starts checking for cri-resmgr status updates going stale.
+func (s *nodeStatus) Start() {
+	s.stop = make(chan struct{})
+	stop := s.stop
+	go func() {
+		ticker := time.NewTicker(opts.nodeStatusTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case _ = <-stop:
+				return
+			case _ = <-ticker.C:
+				s.checkStale()
+			}
+		}
+	}()
+}
+
+// Stop stops checking for stale updates and removes all labels and taints we set.
+func (s *nodeStatus) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.Info("removing cri-resmgr status labels and taints from node %s", nodeName)
+	remove := map[string]*string{}
+	for key := range s.labels {
+		remove[key] = nil
+	}
+	if err := s.patchLabels(remove); err != nil {
+		s.Error("failed to remove node labels: %v", err)
+	} else {
+		s.labels = map[string]string{}
+	}
+	if err := s.setTaint(""); err != nil {
+		s.Error("failed to remove node taint: %v", err)
+	}
+}
+
+// Update publishes the given cri-resmgr status.
+func (s *nodeStatus) Update(policy, mode, memoryTypes string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.last = time.Now()
+
+	pmem := "false"
+	for _, t := range strings.Split(memoryTypes, ",") {
+		if strings.TrimSpace(t) == memoryTypePMEM {
+			pmem = "true"
+		}
+	}
+
+	labels := map[string]string{
+		labelPolicy: policy,
+		labelMode:   mode,
+		labelPMEM:   pmem,
+	}
+	return s.publish(labels)
+}
+
+// checkStale marks the status unknown if cri-resmgr has not updated it recently.
+func (s *nodeStatus) checkStale() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.last.IsZero() || s.mode == modeUnknown || time.Since(s.last) < opts.nodeStatusTimeout {
+		return
+	}
+
+	s.Warn("no status update from cri-resmgr in %v, marking mode %s", time.Since(s.last), modeUnknown)
+	labels := map[string]string{}
+	for key, value := range s.labels {
+		labels[key] = value
+	}
+	labels[labelMode] = modeUnknown
+	if err := s.publish(labels); err != nil {
+		s.Error("%v", err)
+	}
+}
+
+// publish updates the node labels and taint to reflect the given status labels.
+func (s *nodeStatus) publish(labels map[string]string) error {
+	changed := map[string]*string{}
+	for key, value := range labels {
+		if key == labelPolicy && value == "" {
+			continue
+		}
+		if old, ok := s.labels[key]; !ok || old != value {
+			v := value
+			changed[key] = &v
+		}
+	}
+	if len(changed) > 0 {
+		if err := s.patchLabels(changed); err != nil {
+			return agentError("failed to update node labels: %v", err)
+		}
+		for key, value := range changed {
+			s.labels[key] = *value
+			s.Info("node label %s=%s", key, *value)
+		}
+	}
+
+	mode := labels[labelMode]
+	if mode != s.mode {
+		taint := ""
+		if mode != modeActive {
+			taint = mode
+		}
+		if err := s.setTaint(taint); err != nil {
+			return agentError("failed to update node taint: %v", err)
+		}
+		s.mode = mode
+	}
+
+	return nil
+}
+
+// patchLabels sets or, for nil values, removes the given labels of our node.
+func (s *nodeStatus) patchLabels(labels map[string]*string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": labels,
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return agentError("failed to marshal label patch: %v", err)
+	}
+	_, err = s.cli.CoreV1().Nodes().Patch(nodeName, types.MergePatchType, data)
+	return err
+}
+
+// setTaint sets our taint of the node with the given value, or removes it for "".
+//
+// The current taints are always read from the node, so that a taint left
+// behind by a previous instance, for instance one that crashed, gets removed
+// or updated. Conflicting concurrent updates of the node are retried.
+func (s *nodeStatus) setTaint(value string) error {
+	effect := core_v1.TaintEffect(opts.nodeStatusTaint)
+	if effect == "" {
+		return nil
+	}
+
+	nodes := s.cli.CoreV1().Nodes()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nodes.Get(nodeName, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		taints, changed := updateTaints(node.Spec.Taints, value, effect)
+		if !changed {
+			return nil
+		}
+		node.Spec.Taints = taints
+
+		if _, err := nodes.Update(node); err != nil {
+			return err
+		}
+
+		if value != "" {
+			s.Info("node tainted %s=%s:%s", taintDegraded, value, effect)
+		} else {
+			s.Info("node taint %s removed", taintDegraded)
+		}
+		return nil
+	})
+}
+
+// updateTaints sets our taint among the given ones, or removes it for "", returning whether this changed them.
+func updateTaints(taints []core_v1.Taint, value string, effect core_v1.TaintEffect) ([]core_v1.Taint, bool) {
+	updated := []core_v1.Taint{}
+	changed, found := false, false
+	for _, t := range taints {
+		switch {
+		case t.Key != taintDegraded:
+			updated = append(updated, t)
+		case value != "" && !found && t.Value == value && t.Effect == effect:
+			updated = append(updated, t)
+			found = true
+		default:
+			changed = true
+		}
+	}
+	if value != "" && !found {
+		updated = append(updated, core_v1.Taint{
+			Key:    taintDegraded,
+			Value:  value,
+			Effect: effect,
+		})
+		changed = true
+	}
+	return updated, changed
+}
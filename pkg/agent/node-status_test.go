@@ -0,0 +1,181 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"testing"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newNodeStatusTest creates a node status publisher for a fake node with the given taints.
+func newNodeStatusTest(t *testing.T, taints ...core_v1.Taint) (*nodeStatus, *fake.Clientset, func()) {
+	savedName, savedTaint := nodeName, opts.nodeStatusTaint
+	nodeName, opts.nodeStatusTaint = "test-node", string(core_v1.TaintEffectNoSchedule)
+
+	cli := fake.NewSimpleClientset(&core_v1.Node{
+		ObjectMeta: meta_v1.ObjectMeta{Name: nodeName},
+		Spec:       core_v1.NodeSpec{Taints: taints},
+	})
+	return newNodeStatus(cli), cli, func() {
+		nodeName, opts.nodeStatusTaint = savedName, savedTaint
+	}
+}
+
+// nodeTaints returns the current taints of the fake node.
+func nodeTaints(t *testing.T, cli *fake.Clientset) []core_v1.Taint {
+	node, err := cli.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	return node.Spec.Taints
+}
+
+// degradedTaint returns the value of our taint of the fake node, if any.
+func degradedTaint(t *testing.T, cli *fake.Clientset) (string, bool) {
+	for _, taint := range nodeTaints(t, cli) {
+		if taint.Key == taintDegraded {
+			return taint.Value, true
+		}
+	}
+	return "", false
+}
+
+func TestUpdateRemovesStaleTaint(t *testing.T) {
+	other := core_v1.Taint{Key: "other", Value: "x", Effect: core_v1.TaintEffectNoExecute}
+	stale := core_v1.Taint{Key: taintDegraded, Value: modeUnknown, Effect: core_v1.TaintEffectNoSchedule}
+	s, cli, cleanup := newNodeStatusTest(t, other, stale)
+	defer cleanup()
+
+	if err := s.Update("topology-aware", modeActive, "dram,pmem"); err != nil {
+		t.Fatalf("failed to update status: %v", err)
+	}
+	if value, ok := degradedTaint(t, cli); ok {
+		t.Errorf("expected stale taint to be removed, got %s", value)
+	}
+	if taints := nodeTaints(t, cli); len(taints) != 1 || taints[0] != other {
+		t.Errorf("expected other taints to be kept, got %v", taints)
+	}
+
+	node, _ := cli.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	for key, expected := range map[string]string{
+		labelPolicy: "topology-aware",
+		labelMode:   modeActive,
+		labelPMEM:   "true",
+	} {
+		if value := node.Labels[key]; value != expected {
+			t.Errorf("expected node label %s=%s, got %q", key, expected, value)
+		}
+	}
+}
+
+func TestUpdateTaint(t *testing.T) {
+	s, cli, cleanup := newNodeStatusTest(t)
+	defer cleanup()
+
+	if err := s.Update("topology-aware", "failsafe", ""); err != nil {
+		t.Fatalf("failed to update status: %v", err)
+	}
+	if value, ok := degradedTaint(t, cli); !ok || value != "failsafe" {
+		t.Errorf("expected node tainted failsafe, got %q", value)
+	}
+
+	updates := 0
+	cli.PrependReactor("update", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		updates++
+		return false, nil, nil
+	})
+
+	if err := s.setTaint("failsafe"); err != nil {
+		t.Fatalf("failed to set taint: %v", err)
+	}
+	if updates != 0 {
+		t.Errorf("expected no node update for an unchanged taint, got %d", updates)
+	}
+
+	s.last = time.Now().Add(-2 * opts.nodeStatusTimeout)
+	s.checkStale()
+	if value, _ := degradedTaint(t, cli); value != modeUnknown {
+		t.Errorf("expected node tainted %s for a stale status, got %q", modeUnknown, value)
+	}
+
+	s.Stop()
+	if value, ok := degradedTaint(t, cli); ok {
+		t.Errorf("expected taint to be removed on stop, got %s", value)
+	}
+}
+
+func TestSetTaintRetriesOnConflict(t *testing.T) {
+	s, cli, cleanup := newNodeStatusTest(t)
+	defer cleanup()
+
+	conflicts := 2
+	cli.PrependReactor("update", "nodes", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			conflicts--
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "nodes"}, nodeName, nil)
+		}
+		return false, nil, nil
+	})
+
+	if err := s.setTaint("failsafe"); err != nil {
+		t.Fatalf("failed to set taint despite retries: %v", err)
+	}
+	if conflicts != 0 {
+		t.Errorf("expected conflicting updates to be retried")
+	}
+	if value, ok := degradedTaint(t, cli); !ok || value != "failsafe" {
+		t.Errorf("expected node tainted failsafe, got %q", value)
+	}
+}
+
+func TestUpdateTaints(t *testing.T) {
+	effect := core_v1.TaintEffectNoSchedule
+	ours := core_v1.Taint{Key: taintDegraded, Value: "failsafe", Effect: effect}
+	other := core_v1.Taint{Key: "other", Effect: effect}
+
+	tcases := []struct {
+		name    string
+		taints  []core_v1.Taint
+		value   string
+		changed bool
+		count   int
+	}{
+		{name: "remove absent", taints: []core_v1.Taint{other}, count: 1},
+		{name: "remove present", taints: []core_v1.Taint{other, ours}, changed: true, count: 1},
+		{name: "set absent", taints: []core_v1.Taint{other}, value: "failsafe", changed: true, count: 2},
+		{name: "set present", taints: []core_v1.Taint{ours, other}, value: "failsafe", count: 2},
+		{name: "set other value", taints: []core_v1.Taint{ours}, value: modeUnknown, changed: true, count: 1},
+		{name: "set duplicated", taints: []core_v1.Taint{ours, ours}, value: "failsafe", changed: true, count: 1},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			taints, changed := updateTaints(tc.taints, tc.value, effect)
+			if changed != tc.changed || len(taints) != tc.count {
+				t.Errorf("expected %d taints, changed %v, got %v, changed %v",
+					tc.count, tc.changed, taints, changed)
+			}
+		})
+	}
+}
@@ -45,14 +45,16 @@ type server struct {
 	cli       *k8sclient.Clientset // client for accessing k8s api
 	server    *grpc.Server         // gRPC server instance
 	getConfig getConfigFn          // Getter function for current config
+	status    *nodeStatus          // node status publisher, nil if disabled
 }
 
 // newAgentServer creates new agentServer instance.
-func newAgentServer(cli *k8sclient.Clientset, getFn getConfigFn) (agentServer, error) {
+func newAgentServer(cli *k8sclient.Clientset, getFn getConfigFn, status *nodeStatus) (agentServer, error) {
 	s := &server{
 		Logger:    log.NewLogger("server"),
 		cli:       cli,
 		getConfig: getFn,
+		status:    status,
 	}
 
 	return s, nil
@@ -82,6 +84,7 @@ func (s *server) Start(socket string) error {
 		Logger:    s.Logger,
 		cli:       s.cli,
 		getConfig: s.getConfig,
+		status:    s.status,
 	}
 	v1.RegisterAgentServer(s.server, gs)
 
@@ -106,6 +109,7 @@ type grpcServer struct {
 	log.Logger
 	cli       *k8sclient.Clientset
 	getConfig getConfigFn
+	status    *nodeStatus
 }
 
 // GetNode gets K8s node object.
@@ -195,3 +199,61 @@ func (g *grpcServer) GetConfig(ctx context.Context, req *v1.GetConfigRequest) (*
 	}
 	return rpl, nil
 }
+
+// EmitEvent emits a K8s Event for a pod.
+func (g *grpcServer) EmitEvent(ctx context.Context, req *v1.EmitEventRequest) (*v1.EmitEventReply, error) {
+	g.Debug("received EmitEventRequest: %v", req)
+	rpl := &v1.EmitEventReply{}
+
+	switch req.Type {
+	case core_v1.EventTypeNormal, core_v1.EventTypeWarning:
+	default:
+		return rpl, agentError("invalid event type '%s'", req.Type)
+	}
+	if req.Namespace == "" || req.PodName == "" {
+		return rpl, agentError("incomplete pod reference %s/%s in event", req.Namespace, req.PodName)
+	}
+
+	if err := createPodEvent(g.cli, req); err != nil {
+		return rpl, agentError("failed to create event for pod %s/%s: %v",
+			req.Namespace, req.PodName, err)
+	}
+
+	return rpl, nil
+}
+
+// UpdateNodeResourceTopology updates the NodeResourceTopology object of the node.
+func (g *grpcServer) UpdateNodeResourceTopology(ctx context.Context, req *v1.UpdateNodeResourceTopologyRequest) (*v1.UpdateNodeResourceTopologyReply, error) {
+	g.Debug("received UpdateNodeResourceTopologyRequest: %v", req)
+	rpl := &v1.UpdateNodeResourceTopologyReply{}
+
+	zones := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(req.Zones), &zones); err != nil {
+		return rpl, agentError("invalid topology zones: %v", err)
+	}
+
+	if err := updateNodeResourceTopology(g.cli, json.RawMessage(req.Zones)); err != nil {
+		return rpl, agentError("failed to update node resource topology: %v", err)
+	}
+
+	return rpl, nil
+}
+
+// UpdateNodeStatus publishes the status of cri-resmgr as node labels and taints.
+func (g *grpcServer) UpdateNodeStatus(ctx context.Context, req *v1.UpdateNodeStatusRequest) (*v1.UpdateNodeStatusReply, error) {
+	g.Debug("received UpdateNodeStatusRequest: %v", req)
+	rpl := &v1.UpdateNodeStatusReply{}
+
+	if g.status == nil {
+		return rpl, nil
+	}
+	if req.Mode == "" {
+		return rpl, agentError("missing mode in node status update")
+	}
+
+	if err := g.status.Update(req.Policy, req.Mode, req.MemoryTypes); err != nil {
+		return rpl, agentError("failed to update node status: %v", err)
+	}
+
+	return rpl, nil
+}
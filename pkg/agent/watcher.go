@@ -17,6 +17,8 @@ limitations under the License.
 package agent
 
 import (
+	"encoding/json"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,9 +31,11 @@ import (
 
 type cachedConfig struct {
 	sync.RWMutex
-	nodeCfg  *resmgrConfig // node-specific configuration
-	groupCfg *resmgrConfig // group-specific configuration
-	group    string        // group name, "" for default
+	nodeCfg    *resmgrConfig // node-specific configuration
+	groupCfg   *resmgrConfig // group-specific configuration
+	group      string        // group name, "" for default
+	defaultCfg *resmgrConfig // default configuration, if merged
+	merge      bool          // merge configurations instead of picking one
 }
 
 // k8sWatcher is our interface to K8s control plane watcher
@@ -44,6 +48,14 @@ type k8sWatcher interface {
 	ConfigChan() <-chan resmgrConfig
 	// Get up-to-date config
 	GetConfig() resmgrConfig
+	// Get a chan through which to receive pod resource updates
+	PodResourcesChan() <-chan podResources
+}
+
+// podResourceRequirements are the resource requirements of the containers of a pod.
+type podResourceRequirements struct {
+	InitContainers map[string]core_v1.ResourceRequirements `json:"initContainers"`
+	Containers     map[string]core_v1.ResourceRequirements `json:"containers"`
 }
 
 // watcher implements k8sWatcher
@@ -54,6 +66,9 @@ type watcher struct {
 	currentConfig cachedConfig         // Current cri-resmgr config, cached
 
 	configChan chan resmgrConfig // Chan for sending config updates
+
+	pods       podResources      // Resource requirements of pods on our node
+	podResChan chan podResources // Chan for sending pod resource updates
 }
 
 // newK8sWatcher creates a new K8sWatcher instance
@@ -62,8 +77,10 @@ func newK8sWatcher(k8sCli *k8sclient.Clientset) (k8sWatcher, error) {
 		Logger:        log.NewLogger("watcher"),
 		k8sCli:        k8sCli,
 		stop:          make(chan struct{}, 1),
-		currentConfig: cachedConfig{},
+		currentConfig: cachedConfig{merge: opts.mergeConfig},
 		configChan:    make(chan resmgrConfig, 1),
+		pods:          make(podResources),
+		podResChan:    make(chan podResources, 1),
 	}
 
 	return w, nil
@@ -92,6 +109,11 @@ func (w *watcher) ConfigChan() <-chan resmgrConfig {
 	return w.configChan
 }
 
+// PodResourcesChan returns the chan for pod resource updates
+func (w *watcher) PodResourcesChan() <-chan podResources {
+	return w.podResChan
+}
+
 // GetConfig returns the current cri-resmgr configuration
 func (w *watcher) GetConfig() resmgrConfig {
 	cfg, kind := w.currentConfig.get()
@@ -106,6 +128,43 @@ func (w *watcher) sendConfig() {
 	w.configChan <- cfg
 }
 
+// sendPodResources sends the current resource requirements of pods.
+func (w *watcher) sendPodResources() {
+	pods := make(podResources, len(w.pods))
+	for uid, resources := range w.pods {
+		pods[uid] = resources
+	}
+	w.Debug("pushing resources of %d pods to client", len(pods))
+	w.podResChan <- pods
+}
+
+// updatePod updates the resource requirements of a pod, returning true if they changed.
+func (w *watcher) updatePod(pod *core_v1.Pod) bool {
+	r := podResourceRequirements{
+		InitContainers: map[string]core_v1.ResourceRequirements{},
+		Containers:     map[string]core_v1.ResourceRequirements{},
+	}
+	for _, c := range pod.Spec.InitContainers {
+		r.InitContainers[c.Name] = c.Resources
+	}
+	for _, c := range pod.Spec.Containers {
+		r.Containers[c.Name] = c.Resources
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		w.Error("failed to marshal resources of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return false
+	}
+
+	uid := string(pod.UID)
+	if w.pods[uid] == string(data) {
+		return false
+	}
+	w.pods[uid] = string(data)
+	return true
+}
+
 func (w *watcher) watch() error {
 	nodew := newNodeWatch(w)
 	group := ""
@@ -120,7 +179,21 @@ func (w *watcher) watch() error {
 	}
 
 	cfgw := newConfigMapWatch(w, opts.configMapName+".node."+nodeName, namespace(opts.configNs))
-	grpw := newConfigMapWatch(w, groupMapName(group), namespace(opts.configNs))
+	grpw := newConfigMapWatch(w, w.groupWatchName(group), namespace(opts.configNs))
+
+	// with merging, the default ConfigMap is watched separately from the group one
+	dflName := ""
+	if opts.mergeConfig {
+		dflName = groupMapName("")
+	}
+	dflw := newConfigMapWatch(w, dflName, namespace(opts.configNs))
+
+	var podw *watch
+	var podEvents <-chan k8swatch.Event
+	if opts.podResources {
+		podw = newPodWatch(w)
+		podEvents = podw.ResultChan()
+	}
 
 	w.Info("watcher running")
 	w.sendConfig()
@@ -132,6 +205,10 @@ func (w *watcher) watch() error {
 			nodew.Stop()
 			cfgw.Stop()
 			grpw.Stop()
+			dflw.Stop()
+			if podw != nil {
+				podw.Stop()
+			}
 			return nil
 
 		case e, ok := <-nodew.ResultChan():
@@ -143,7 +220,10 @@ func (w *watcher) watch() error {
 					if group != label {
 						group = label
 						w.Info("configuration group is set to '%s'", group)
-						grpw.Start(groupMapName(group))
+						grpw.Start(w.groupWatchName(group))
+						if opts.mergeConfig && group == "" && w.currentConfig.setGroup(group, nil) {
+							w.sendConfig()
+						}
 					}
 				case k8swatch.Deleted:
 					w.Warn("Hmm, our node got removed...")
@@ -185,6 +265,40 @@ func (w *watcher) watch() error {
 				}
 				continue
 			}
+
+		case e, ok := <-dflw.ResultChan():
+			if ok {
+				switch e.Type {
+				case k8swatch.Added, k8swatch.Modified:
+					w.Info("default ConfigMap updated")
+					cm := e.Object.(*core_v1.ConfigMap)
+					w.currentConfig.setDefault(&cm.Data)
+					w.sendConfig()
+				case k8swatch.Deleted, SyntheticMissing:
+					w.Info("default ConfigMap deleted")
+					w.currentConfig.setDefault(nil)
+					w.sendConfig()
+				}
+				continue
+			}
+
+		case e, ok := <-podEvents:
+			if ok {
+				pod, isPod := e.Object.(*core_v1.Pod)
+				if !isPod {
+					continue
+				}
+				switch e.Type {
+				case k8swatch.Added, k8swatch.Modified:
+					if w.updatePod(pod) {
+						w.sendPodResources()
+					}
+				case k8swatch.Deleted:
+					delete(w.pods, string(pod.UID))
+					w.sendPodResources()
+				}
+				continue
+			}
 		}
 
 		// shouln't be necessary, but just in case avoid spinning on a closed channel
@@ -200,11 +314,23 @@ func groupMapName(group string) string {
 	return opts.configMapName + ".group." + group
 }
 
+// groupWatchName returns the ConfigMap to watch for the given group.
+func (w *watcher) groupWatchName(group string) string {
+	if opts.mergeConfig && group == "" {
+		return ""
+	}
+	return groupMapName(group)
+}
+
 // get is a helper method for getting the config data
 func (c *cachedConfig) get() (resmgrConfig, string) {
 	c.RLock()
 	defer c.RUnlock()
 
+	if c.merge {
+		return c.merged()
+	}
+
 	var cfg *resmgrConfig
 	var kind string
 
@@ -230,6 +356,34 @@ func (c *cachedConfig) get() (resmgrConfig, string) {
 	return *cfg, kind
 }
 
+// merged returns the default, group and node configuration merged key by key.
+func (c *cachedConfig) merged() (resmgrConfig, string) {
+	cfg := resmgrConfig{}
+	kinds := []string{}
+
+	for _, layer := range []struct {
+		kind string
+		cfg  *resmgrConfig
+	}{
+		{"default", c.defaultCfg},
+		{"group " + c.group, c.groupCfg},
+		{"node", c.nodeCfg},
+	} {
+		if layer.cfg == nil {
+			continue
+		}
+		for key, value := range *layer.cfg {
+			cfg[key] = value
+		}
+		kinds = append(kinds, layer.kind)
+	}
+
+	if len(kinds) == 0 {
+		return cfg, "empty merged"
+	}
+	return cfg, "merged " + strings.Join(kinds, "+")
+}
+
 // set node-specific configuration
 func (c *cachedConfig) setNode(data *map[string]string) bool {
 	c.Lock()
@@ -246,5 +400,14 @@ func (c *cachedConfig) setGroup(group string, data *map[string]string) bool {
 
 	c.groupCfg = (*resmgrConfig)(data)
 	c.group = group
-	return c.nodeCfg == nil
+	return c.nodeCfg == nil || c.merge
+}
+
+// set default configuration, used only when merging
+func (c *cachedConfig) setDefault(data *map[string]string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	c.defaultCfg = (*resmgrConfig)(data)
+	return true
 }
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agent
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCachedConfig(t *testing.T) {
+	dflt := map[string]string{"policy": "default policy", "rdt": "default rdt"}
+	group := map[string]string{"rdt": "group rdt", "blockio": "group blockio"}
+	node := map[string]string{"blockio": "node blockio"}
+
+	tcases := []struct {
+		name     string
+		merge    bool
+		group    string
+		kind     string
+		expected resmgrConfig
+	}{
+		{
+			name:     "node takes precedence",
+			group:    "foo",
+			kind:     "node",
+			expected: resmgrConfig(node),
+		},
+		{
+			name:  "merged",
+			merge: true,
+			group: "foo",
+			kind:  "merged default+group foo+node",
+			expected: resmgrConfig{
+				"policy":  "default policy",
+				"rdt":     "group rdt",
+				"blockio": "node blockio",
+			},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &cachedConfig{merge: tc.merge}
+			if tc.merge {
+				c.setDefault(&dflt)
+			}
+			c.setGroup(tc.group, &group)
+			c.setNode(&node)
+
+			cfg, kind := c.get()
+			if kind != tc.kind {
+				t.Errorf("expected %s configuration, got %s", tc.kind, kind)
+			}
+			if !reflect.DeepEqual(cfg, tc.expected) {
+				t.Errorf("expected configuration %v, got %v", tc.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestCachedConfigUpdates(t *testing.T) {
+	group := map[string]string{"rdt": "group rdt"}
+	node := map[string]string{"blockio": "node blockio"}
+
+	c := &cachedConfig{}
+	c.setNode(&node)
+	if c.setGroup("foo", &group) {
+		t.Errorf("expected group update to be shadowed by the node configuration")
+	}
+	c.setNode(nil)
+	if cfg, kind := c.get(); kind != "group foo" || cfg["rdt"] != "group rdt" {
+		t.Errorf("expected group configuration without a node one, got %s %v", kind, cfg)
+	}
+
+	m := &cachedConfig{merge: true}
+	m.setNode(&node)
+	if !m.setGroup("foo", &group) {
+		t.Errorf("expected group update to take effect when merging")
+	}
+	if cfg, kind := (&cachedConfig{merge: true}).get(); kind != "empty merged" || len(cfg) != 0 {
+		t.Errorf("expected empty merged configuration, got %s %v", kind, cfg)
+	}
+}
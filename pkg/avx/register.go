@@ -12,7 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build !noavx
+//go:build linux && !noavx
+// +build linux,!noavx
 
 package avx
 
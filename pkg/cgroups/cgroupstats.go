@@ -87,6 +87,44 @@ type GlobalNumaStats struct {
 	OtherNode     int64
 }
 
+// PressureAverages is one line of a pressure stall information file.
+type PressureAverages struct {
+	Avg10  float64 // percentage of time stalled over the last 10 seconds
+	Avg60  float64 // percentage of time stalled over the last 60 seconds
+	Avg300 float64 // percentage of time stalled over the last 300 seconds
+	Total  int64   // total time stalled, in microseconds
+}
+
+// Pressure has parsed contents of a cgroup v2 pressure stall information file.
+type Pressure struct {
+	Some PressureAverages // some tasks stalled
+	Full PressureAverages // all non-idle tasks stalled
+}
+
+// CPUStat has parsed contents of a cgroup v2 cpu.stat file.
+type CPUStat struct {
+	UsageUsec     int64 // total CPU time consumed, in microseconds
+	UserUsec      int64 // CPU time consumed in user mode, in microseconds
+	SystemUsec    int64 // CPU time consumed in system mode, in microseconds
+	NrPeriods     int64 // number of enforcement periods elapsed
+	NrThrottled   int64 // number of periods the cgroup was throttled
+	ThrottledUsec int64 // total time throttled, in microseconds
+}
+
+// IODeviceStat has the parsed statistics of a single device in a cgroup v2 io.stat file.
+type IODeviceStat struct {
+	Major int
+	Minor int
+	Stats map[string]int64
+}
+
+// IOStat has parsed contents of a cgroup v2 io.stat file.
+type IOStat struct {
+	Devices    []*IODeviceStat
+	ReadBytes  int64 // bytes read from all devices
+	WriteBytes int64 // bytes written to all devices
+}
+
 func readCgroupFileLines(filePath string) ([]string, error) {
 
 	f, err := ioutil.ReadFile(filePath)
@@ -498,3 +536,160 @@ func GetGlobalNumaStats() (map[int]GlobalNumaStats, error) {
 
 	return result, nil
 }
+
+// GetPressure returns the pressure stall information of a cgroup v2 for a resource.
+//
+// The resource is one of cpu, memory, or io.
+func GetPressure(cgroupPath, resource string) (Pressure, error) {
+
+	// File looks like this:
+	//
+	// some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+	// full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+
+	lines, err := readCgroupFileLines(path.Join(cgroupPath, resource+".pressure"))
+	if err != nil {
+		return Pressure{}, err
+	}
+
+	result := Pressure{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return Pressure{}, fmt.Errorf("error parsing pressure line '%s'", line)
+		}
+
+		var avgs *PressureAverages
+		switch fields[0] {
+		case "some":
+			avgs = &result.Some
+		case "full":
+			avgs = &result.Full
+		default:
+			return Pressure{}, fmt.Errorf("error parsing pressure line '%s'", line)
+		}
+
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return Pressure{}, fmt.Errorf("error parsing pressure field '%s'", field)
+			}
+			switch kv[0] {
+			case "avg10":
+				avgs.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				avgs.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				avgs.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				avgs.Total, err = strconv.ParseInt(kv[1], 10, 64)
+			}
+			if err != nil {
+				return Pressure{}, fmt.Errorf("error parsing pressure field '%s': %v", field, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// GetCPUStat returns the parsed CPU statistics of a cgroup v2.
+func GetCPUStat(cgroupPath string) (CPUStat, error) {
+
+	// File looks like this:
+	//
+	// usage_usec 3723082
+	// user_usec 2456599
+	// system_usec 1266483
+	// nr_periods 0
+	// nr_throttled 0
+	// throttled_usec 0
+
+	lines, err := readCgroupFileLines(path.Join(cgroupPath, "cpu.stat"))
+	if err != nil {
+		return CPUStat{}, err
+	}
+
+	result := CPUStat{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return CPUStat{}, fmt.Errorf("error parsing cpu.stat line '%s'", line)
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return CPUStat{}, fmt.Errorf("error parsing cpu.stat line '%s': %v", line, err)
+		}
+		switch fields[0] {
+		case "usage_usec":
+			result.UsageUsec = value
+		case "user_usec":
+			result.UserUsec = value
+		case "system_usec":
+			result.SystemUsec = value
+		case "nr_periods":
+			result.NrPeriods = value
+		case "nr_throttled":
+			result.NrThrottled = value
+		case "throttled_usec":
+			result.ThrottledUsec = value
+		}
+	}
+
+	return result, nil
+}
+
+// GetMemoryCurrent returns the current memory usage of a cgroup v2, in bytes.
+func GetMemoryCurrent(cgroupPath string) (int64, error) {
+	return readCgroupSingleNumber(path.Join(cgroupPath, "memory.current"))
+}
+
+// GetIOStat returns the parsed I/O statistics of a cgroup v2.
+func GetIOStat(cgroupPath string) (IOStat, error) {
+
+	// File looks like this:
+	//
+	// 8:16 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	// 8:0 rbytes=90430464 wbytes=299008000 rios=8950 wios=1252 dbytes=50331648 dios=3021
+
+	entry := path.Join(cgroupPath, "io.stat")
+	lines, err := readCgroupFileLines(entry)
+	if err != nil {
+		return IOStat{}, err
+	}
+
+	result := IOStat{Devices: make([]*IODeviceStat, 0, len(lines))}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		majmin := strings.Split(fields[0], ":")
+		if len(majmin) != 2 {
+			return IOStat{}, fmt.Errorf("error parsing file %s", entry)
+		}
+		major, err := strconv.Atoi(majmin[0])
+		if err != nil {
+			return IOStat{}, err
+		}
+		minor, err := strconv.Atoi(majmin[1])
+		if err != nil {
+			return IOStat{}, err
+		}
+
+		dev := &IODeviceStat{Major: major, Minor: minor, Stats: make(map[string]int64)}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return IOStat{}, fmt.Errorf("error parsing io.stat field '%s'", field)
+			}
+			value, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return IOStat{}, fmt.Errorf("error parsing io.stat field '%s': %v", field, err)
+			}
+			dev.Stats[kv[0]] = value
+		}
+		result.ReadBytes += dev.Stats["rbytes"]
+		result.WriteBytes += dev.Stats["wbytes"]
+		result.Devices = append(result.Devices, dev)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetPressure(t *testing.T) {
+	tcs := []struct {
+		name     string
+		content  string // content of cpu.pressure, none if empty
+		err      bool
+		expected Pressure
+	}{
+		{
+			name: "some and full",
+			content: "some avg10=12.50 avg60=3.00 avg300=0.25 total=1000\n" +
+				"full avg10=1.50 avg60=0.50 avg300=0.00 total=200\n",
+			expected: Pressure{
+				Some: PressureAverages{Avg10: 12.5, Avg60: 3.0, Avg300: 0.25, Total: 1000},
+				Full: PressureAverages{Avg10: 1.5, Avg60: 0.5, Total: 200},
+			},
+		},
+		{
+			name:    "some only",
+			content: "some avg10=0.10 avg60=0.20 avg300=0.30 total=42\n",
+			expected: Pressure{
+				Some: PressureAverages{Avg10: 0.1, Avg60: 0.2, Avg300: 0.3, Total: 42},
+			},
+		},
+		{
+			name: "missing file",
+			err:  true,
+		},
+		{
+			name:    "unknown line",
+			content: "most avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			err:     true,
+		},
+		{
+			name:    "missing fields",
+			content: "some avg10=0.00 avg60=0.00 total=0\n",
+			err:     true,
+		},
+		{
+			name:    "malformed field",
+			content: "some avg10 avg60=0.00 avg300=0.00 total=0\n",
+			err:     true,
+		},
+		{
+			name:    "malformed value",
+			content: "some avg10=high avg60=0.00 avg300=0.00 total=0\n",
+			err:     true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "pressure-test")
+			if err != nil {
+				t.Fatalf("failed to create test directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			if tc.content != "" {
+				file := filepath.Join(dir, "cpu.pressure")
+				if err := ioutil.WriteFile(file, []byte(tc.content), 0644); err != nil {
+					t.Fatalf("failed to write %s: %v", file, err)
+				}
+			}
+
+			pressure, err := GetPressure(dir, "cpu")
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if pressure != tc.expected {
+				t.Errorf("expected pressure %+v, got %+v", tc.expected, pressure)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"os"
+)
+
+// KubepodsDirs are the top-level directories of pod cgroups, cgroupfs and systemd style.
+var KubepodsDirs = []string{"kubepods", "kubepods.slice"}
+
+// IsKubepodsDir checks if a top-level cgroup directory name is that of pods.
+func IsKubepodsDir(name string) bool {
+	for _, dir := range KubepodsDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDir checks if the given path is an existing directory.
+func IsDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// IsFile checks if the given path is an existing regular file.
+func IsFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// cgroupRootDir is the usual mount point of the cgroup hierarchies.
+	cgroupRootDir = "/sys/fs/cgroup"
+	// systemdSliceSuffix is the suffix of systemd slice units.
+	systemdSliceSuffix = ".slice"
+)
+
+// containerDirRegexp matches cgroup directory names of containers and pod sandboxes.
+//
+// With the cgroupfs driver the directory is named after the container ID. With
+// the systemd driver it is a scope unit with a runtime-specific prefix, such as
+// cri-containerd-<ID>.scope, crio-<ID>.scope, or docker-<ID>.scope.
+var containerDirRegexp = regexp.MustCompile(`^(?:[a-z][a-z0-9-]*-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// resolver resolves container IDs to their cgroup directories.
+type resolver struct {
+	sync.Mutex
+	root     string            // hierarchy used for resolution
+	dirs     map[string]string // container ID to cgroup directory, relative to root
+	started  int               // number of scans started
+	done     int               // number of scans finished
+	scanning chan struct{}     // closed once the scan in progress finishes
+}
+
+// containers is our container cgroup resolver.
+var containers = &resolver{}
+
+// ResolveCgroupDir returns the cgroup directory of a container or pod sandbox.
+//
+// The directory is relative to the cgroup hierarchy mount point, and it is the
+// same in all hierarchies. Resolved directories are cached and revalidated on
+// every lookup. If a container is not found, the hierarchy is rescanned, which
+// also drops directories of containers no longer around. Concurrent lookups of
+// containers not found share a single rescan.
+func ResolveCgroupDir(containerID string) (string, error) {
+	return containers.resolve(containerID)
+}
+
+// ResolveCgroupPath returns the cgroup directory of a container in the given hierarchy.
+func ResolveCgroupPath(hierarchyDir, containerID string) (string, error) {
+	dir, err := containers.resolve(containerID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(hierarchyDir, dir), nil
+}
+
+// ExpandCgroupParent expands a pod cgroup parent to a directory.
+//
+// With the systemd driver the cgroup parent is a slice unit name, for instance
+// kubepods-burstable-pod<UID>.slice, with its nesting encoded in the name. This
+// is expanded to kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<UID>.slice.
+// Cgroup parents of the cgroupfs driver are directories already and are returned as such.
+func ExpandCgroupParent(parent string) string {
+	if !strings.HasSuffix(parent, systemdSliceSuffix) || strings.Contains(parent, "/") {
+		return parent
+	}
+
+	unit := strings.TrimSuffix(parent, systemdSliceSuffix)
+	if unit == "" || unit == "-" {
+		return "/"
+	}
+
+	dir, prefix := "", ""
+	for _, part := range strings.Split(unit, "-") {
+		if part == "" {
+			return parent
+		}
+		prefix += part
+		dir = filepath.Join(dir, prefix+systemdSliceSuffix)
+		prefix += "-"
+	}
+
+	return "/" + dir
+}
+
+// resolve resolves a container ID to its cgroup directory.
+func (r *resolver) resolve(id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("can't resolve cgroup directory without container ID")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.root == "" {
+		r.root = referenceHierarchy()
+	}
+
+	if dir, ok := r.dirs[id]; ok {
+		if IsDir(filepath.Join(r.root, dir)) {
+			return dir, nil
+		}
+		delete(r.dirs, id)
+	}
+
+	// rescan, or join a scan in progress, until found or scanned after this lookup
+	want := r.started + 1
+	for r.done < want {
+		if r.scanning == nil {
+			r.scan()
+		} else {
+			scanning := r.scanning
+			r.Unlock()
+			<-scanning
+			r.Lock()
+		}
+		if dir, ok := r.dirs[id]; ok {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to find cgroup directory of container %s in %s", id, r.root)
+}
+
+// scan rebuilds the mapping of container IDs to cgroup directories.
+//
+// It must be called with the resolver locked. The lock is released while the
+// hierarchy is being walked.
+func (r *resolver) scan() {
+	r.started++
+	scan, root := r.started, r.root
+	r.scanning = make(chan struct{})
+
+	r.Unlock()
+	dirs := scanDirs(root)
+	r.Lock()
+
+	r.dirs = dirs
+	r.done = scan
+	close(r.scanning)
+	r.scanning = nil
+}
+
+// scanDirs walks a cgroup hierarchy for the directories of containers.
+func scanDirs(root string) map[string]string {
+	dirs := make(map[string]string)
+
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		match := containerDirRegexp.FindStringSubmatch(info.Name())
+		if match == nil {
+			return nil
+		}
+		if dir, err := filepath.Rel(root, path); err == nil {
+			dirs[match[1]] = "/" + dir
+		}
+		// containers have no nested cgroups of interest
+		return filepath.SkipDir
+	})
+
+	return dirs
+}
+
+// referenceHierarchy returns the cgroup hierarchy used for resolving container directories.
+func referenceHierarchy() string {
+	if IsFile(filepath.Join(cgroupRootDir, "cgroup.controllers")) {
+		return cgroupRootDir
+	}
+	if cpuset := filepath.Join(cgroupRootDir, "cpuset"); IsDir(cpuset) {
+		return cpuset
+	}
+	return V2path
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// containerID returns a fake 64-character container ID.
+func containerID(c string) string {
+	return strings.Repeat(c, 64)
+}
+
+// setupHierarchy creates a fake cgroup hierarchy with the given directories.
+func setupHierarchy(t *testing.T, dirs ...string) string {
+	root, err := ioutil.TempDir("", "resolver-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	return root
+}
+
+func TestExpandCgroupParent(t *testing.T) {
+	tcases := []struct {
+		parent   string
+		expected string
+	}{
+		{
+			parent:   "kubepods-burstable-pod1234.slice",
+			expected: "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice",
+		},
+		{
+			parent:   "kubepods.slice",
+			expected: "/kubepods.slice",
+		},
+		{
+			parent:   "-.slice",
+			expected: "/",
+		},
+		{
+			parent:   "/kubepods/burstable/pod1234",
+			expected: "/kubepods/burstable/pod1234",
+		},
+		{
+			parent:   "/kubepods.slice/kubepods-pod1234.slice",
+			expected: "/kubepods.slice/kubepods-pod1234.slice",
+		},
+		{
+			parent:   "kubepods--pod1234.slice",
+			expected: "kubepods--pod1234.slice",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.parent, func(t *testing.T) {
+			if dir := ExpandCgroupParent(tc.parent); dir != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, dir)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cgroupfs := "/kubepods/burstable/pod1234/" + containerID("a")
+	systemd := "/kubepods.slice/kubepods-pod5678.slice/cri-containerd-" + containerID("b") + ".scope"
+	crio := "/kubepods.slice/kubepods-pod5678.slice/crio-" + containerID("c") + ".scope"
+
+	root := setupHierarchy(t, cgroupfs, systemd, crio, "/system.slice/docker.service")
+	defer os.RemoveAll(root)
+
+	r := &resolver{root: root}
+	for id, expected := range map[string]string{
+		containerID("a"): cgroupfs,
+		containerID("b"): systemd,
+		containerID("c"): crio,
+	} {
+		dir, err := r.resolve(id)
+		if err != nil {
+			t.Errorf("failed to resolve %s: %v", id, err)
+			continue
+		}
+		if dir != expected {
+			t.Errorf("expected %s to resolve to %q, got %q", id, expected, dir)
+		}
+	}
+	if r.started != 1 {
+		t.Errorf("expected a single scan for resolving known containers, got %d", r.started)
+	}
+
+	if _, err := r.resolve(containerID("d")); err == nil {
+		t.Errorf("unexpectedly resolved unknown container")
+	}
+	if _, err := r.resolve(""); err == nil {
+		t.Errorf("unexpectedly resolved empty container ID")
+	}
+}
+
+func TestResolveRemoved(t *testing.T) {
+	dir := "/kubepods/pod1234/" + containerID("a")
+	root := setupHierarchy(t, dir)
+	defer os.RemoveAll(root)
+
+	r := &resolver{root: root}
+	if _, err := r.resolve(containerID("a")); err != nil {
+		t.Fatalf("failed to resolve container: %v", err)
+	}
+
+	// a container moved to another directory is found by a rescan
+	moved := "/kubepods/pod5678/" + containerID("a")
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(root, moved)), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(moved), err)
+	}
+	if err := os.Rename(filepath.Join(root, dir), filepath.Join(root, moved)); err != nil {
+		t.Fatalf("failed to move %s: %v", dir, err)
+	}
+	if resolved, err := r.resolve(containerID("a")); err != nil || resolved != moved {
+		t.Errorf("expected moved container to resolve to %q, got %q (%v)", moved, resolved, err)
+	}
+
+	// a removed container is dropped
+	if err := os.Remove(filepath.Join(root, moved)); err != nil {
+		t.Fatalf("failed to remove %s: %v", moved, err)
+	}
+	if _, err := r.resolve(containerID("a")); err == nil {
+		t.Errorf("unexpectedly resolved removed container")
+	}
+	if _, ok := r.dirs[containerID("a")]; ok {
+		t.Errorf("expected removed container to be dropped")
+	}
+}
+
+func TestResolveConcurrent(t *testing.T) {
+	ids := []string{}
+	dirs := []string{}
+	for _, c := range "0123456789abcdef" {
+		id := containerID(string(c))
+		ids = append(ids, id)
+		dirs = append(dirs, "/kubepods/pod"+string(c)+"/"+id)
+	}
+	root := setupHierarchy(t, dirs...)
+	defer os.RemoveAll(root)
+
+	r := &resolver{root: root}
+	wg := sync.WaitGroup{}
+	results := make(chan error, len(ids))
+	for i := range ids {
+		wg.Add(1)
+		go func(id, expected string) {
+			defer wg.Done()
+			dir, err := r.resolve(id)
+			if err == nil && dir != expected {
+				err = fmt.Errorf("%s resolved to %q, expected %q", id, dir, expected)
+			}
+			results <- err
+		}(ids[i], dirs[i])
+	}
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		if err != nil {
+			t.Errorf("failed to resolve container concurrently: %v", err)
+		}
+	}
+	if r.started != 1 || r.scanning != nil {
+		t.Errorf("expected concurrent lookups to share a single scan, got %d", r.started)
+	}
+}
@@ -105,10 +105,6 @@ var (
 	log = logger.NewLogger("cgroupstats")
 )
 
-const (
-	kubepodsDir = "kubepods.slice"
-)
-
 type collector struct {
 }
 
@@ -282,39 +278,41 @@ func walkCgroups() []string {
 	containerDirs := []string{}
 
 	cpuset := filepath.Join(cgroupRoot, "cpuset")
-	filepath.Walk(filepath.Join(cpuset, kubepodsDir),
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				if os.IsNotExist(err) {
+	for _, kubepods := range cgroups.KubepodsDirs {
+		filepath.Walk(filepath.Join(cpuset, kubepods),
+			func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				if !info.IsDir() {
 					return nil
 				}
-				return err
-			}
-			if !info.IsDir() {
-				return nil
-			}
 
-			dir := info.Name()
-			if !strings.HasSuffix(dir, ".scope") {
-				return nil
-			}
+				dir := info.Name()
+				if !strings.HasSuffix(dir, ".scope") {
+					return nil
+				}
 
-			switch {
-			case strings.HasPrefix(dir, "cri-containerd-"):
-				break
-			case strings.HasPrefix(dir, "crio-"):
-				break
-			case strings.HasPrefix(dir, "docker-"):
-				break
-			default:
-				return filepath.SkipDir
-			}
+				switch {
+				case strings.HasPrefix(dir, "cri-containerd-"):
+					break
+				case strings.HasPrefix(dir, "crio-"):
+					break
+				case strings.HasPrefix(dir, "docker-"):
+					break
+				default:
+					return filepath.SkipDir
+				}
 
-			path = strings.TrimPrefix(path, cpuset+"/")
-			containerDirs = append(containerDirs, path)
+				path = strings.TrimPrefix(path, cpuset+"/")
+				containerDirs = append(containerDirs, path)
 
-			return nil
-		})
+				return nil
+			})
+	}
 
 	return containerDirs
 }
@@ -15,8 +15,12 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/ghodss/yaml"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -28,6 +32,9 @@ const (
 // GetConfigFn is used to query a module for its default configuration.
 type GetConfigFn func() interface{}
 
+// ValidateFn is used to check a candidate module configuration before it is applied.
+type ValidateFn func(interface{}) error
+
 // NotifyFn is used to notify a module about configuration changes.
 type NotifyFn func(Event, Source) error
 
@@ -64,7 +71,9 @@ type Module struct {
 	children    map[string]*Module // modules nested under this module
 	getdefault  GetConfigFn        // getter for default configuration
 	notifiers   []NotifyFn         // update notification callbacks
+	validators  []ValidateFn       // data validation callbacks
 	noValidate  bool               // omit data validation
+	fragment    bool               // only notify about changes to own configuration
 }
 
 // main is the root of our configuration.
@@ -142,6 +151,11 @@ func setconfig(data Data, source Source) error {
 		return err
 	}
 
+	a, err := newActivation(UpdateEvent, source)
+	if err != nil {
+		return configError("pre-update configuration snapshot failed: %v", err)
+	}
+
 	log.Info("applying configuration...")
 	err = main.configure(data, false)
 	if err != nil {
@@ -150,7 +164,10 @@ func setconfig(data Data, source Source) error {
 	}
 
 	log.Info("activating configuration...")
-	err = main.notify(UpdateEvent, source)
+	err = main.notify(a)
+	if err == nil {
+		err = a.rejection()
+	}
 	if err != nil {
 		log.Error("configuration rejected: %v", err)
 		revertconfig(snapshot, true)
@@ -162,7 +179,13 @@ func setconfig(data Data, source Source) error {
 
 // revertconfig reverts configuration using a previously taken snapshot
 func revertconfig(snapshot Data, notify bool) {
-	err := main.configure(snapshot, true)
+	a, err := newActivation(RevertEvent, ConfigBackup)
+	if err != nil {
+		log.Error("failed to revert configuration: %v", err)
+		return
+	}
+
+	err = main.configure(snapshot, true)
 	if err != nil {
 		log.Error("failed to rever configuration: %v", err)
 	}
@@ -171,7 +194,7 @@ func revertconfig(snapshot Data, notify bool) {
 		return
 	}
 
-	err = main.notify(RevertEvent, ConfigBackup)
+	err = main.notify(a)
 	if err != nil {
 		log.Error("reverted configuration rejected: %v", err)
 	}
@@ -276,16 +299,29 @@ func (m *Module) apply(cfg Data) error {
 }
 
 // notify notifies this module and its children about a configuration change.
-func (m *Module) notify(event Event, source Source) error {
+func (m *Module) notify(a *activation) error {
 	for _, child := range m.children {
-		if err := child.notify(event, source); err != nil {
+		if err := child.notify(a); err != nil {
 			return err
 		}
 	}
 
+	if len(m.notifiers) == 0 {
+		return nil
+	}
+	if m.fragment && !a.isChanged(m) {
+		log.Debug("module %s: configuration unchanged, skipping notification", m.path)
+		return nil
+	}
+
 	for _, fn := range m.notifiers {
-		if err := fn(event, source); err != nil {
-			return configError("module %s rejected %v configuration: %v", m.path, event, err)
+		if err := fn(a.event, a.source); err != nil {
+			err = configError("module %s rejected %v configuration: %v", m.path, a.event, err)
+			if m.fragment && a.event == UpdateEvent {
+				a.reject(m, err)
+				return nil
+			}
+			return err
 		}
 	}
 
@@ -314,12 +350,15 @@ func (m *Module) check() {
 	}
 }
 
-// validate checks that each field of data refers to either module data or a submodule.
+// validate checks that each field of data refers to either module data or a submodule,
+// and that module data passes any module-specific validation. All problems found are
+// reported in the returned error.
 func (m *Module) validate(data Data) error {
 	log.Debug("validating data for module %s...", m.path)
 
 	modcfg, subcfg := data.split(m.hasChild)
 	fields := map[string]struct{}{}
+	errs := []string{}
 
 	if m.isImplicit() {
 		if len(modcfg) > 0 {
@@ -327,12 +366,14 @@ func (m *Module) validate(data Data) error {
 			for name := range modcfg {
 				names = append(names, name)
 			}
+			sort.Strings(names)
 			if !m.noValidate {
-				return configError("implicit module %s: given configuration data %s",
+				errs = append(errs, fmt.Sprintf("implicit module %s: given configuration data %s",
+					m.path, strings.Join(names, ",")))
+			} else {
+				log.Error("implicit module %s: given configuration date %s",
 					m.path, strings.Join(names, ","))
 			}
-			log.Error("implicit module %s: given configuration date %s",
-				m.path, strings.Join(names, ","))
 		}
 	} else {
 		ptr := reflect.ValueOf(m.ptr).Elem()
@@ -343,12 +384,22 @@ func (m *Module) validate(data Data) error {
 		}
 	}
 
+	unknown := false
 	for field := range modcfg {
 		if _, ok := fields[field]; !ok {
 			if !m.noValidate {
-				return configError("module %s: given unknown configuration data %s", m.path, field)
+				errs = append(errs, fmt.Sprintf("module %s: given unknown configuration data %s",
+					m.path, field))
+				unknown = true
+			} else {
+				log.Error("module %s: given unknown configuration data %s", m.path, field)
 			}
-			log.Error("module %s: given unknown configuration data %s", m.path, field)
+		}
+	}
+
+	if !unknown {
+		if err := m.validateData(modcfg); err != nil {
+			errs = append(errs, err.Error())
 		}
 	}
 
@@ -356,12 +407,12 @@ func (m *Module) validate(data Data) error {
 	for name, child := range m.children {
 		childcfg, err := subcfg.pick(name, true)
 		if err != nil {
-			return configError("module %s: failed to pick configuration for child %s: %v",
-				m.path, child.path, err)
+			errs = append(errs, fmt.Sprintf("module %s: failed to pick configuration for child %s: %v",
+				m.path, child.path, err))
+			continue
 		}
-		err = child.validate(childcfg)
-		if err != nil {
-			return err
+		if err = child.validate(childcfg); err != nil {
+			errs = append(errs, strings.TrimPrefix(err.Error(), errorPrefix))
 		}
 	}
 
@@ -370,8 +421,43 @@ func (m *Module) validate(data Data) error {
 		for name := range subcfg {
 			unconsumed = append(unconsumed, name)
 		}
-		return configError("module %s: no child corresponding to data %s",
-			m.path, strings.Join(unconsumed, ","))
+		sort.Strings(unconsumed)
+		errs = append(errs, fmt.Sprintf("module %s: no child corresponding to data %s",
+			m.path, strings.Join(unconsumed, ",")))
+	}
+
+	if len(errs) > 0 {
+		return configError("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// validateData checks that data can be decoded as module data and runs module validators.
+func (m *Module) validateData(modcfg Data) error {
+	if m.isImplicit() {
+		return nil
+	}
+
+	obj := m.getdefault()
+	if len(modcfg) > 0 {
+		raw, err := json.Marshal(modcfg)
+		if err != nil {
+			return fmt.Errorf("module %s: failed to marshal configuration: %v", m.path, err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		if !m.noValidate {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(obj); err != nil {
+			return fmt.Errorf("module %s: invalid configuration: %v", m.path, err)
+		}
+	}
+
+	for _, fn := range m.validators {
+		if err := fn(obj); err != nil {
+			return fmt.Errorf("module %s: invalid configuration: %v", m.path, err)
+		}
 	}
 
 	return nil
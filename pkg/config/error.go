@@ -18,7 +18,10 @@ import (
 	"fmt"
 )
 
+// errorPrefix is the prefix of all configuration-specific errors.
+const errorPrefix = "config error: "
+
 // configError creates a formatted configuration-specific error.
 func configError(format string, args ...interface{}) error {
-	return fmt.Errorf("config error: "+format, args...)
+	return fmt.Errorf(errorPrefix+format, args...)
 }
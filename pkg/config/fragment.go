@@ -0,0 +1,99 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// activation tracks the activation of a configuration update or rollback.
+//
+// Modules declared as fragments are only notified if their own configuration
+// changed. Rejections of an update by fragments are collected, letting all
+// other fragments see the update, and the whole update is then rolled back,
+// so that the active configuration always matches one accepted in full.
+type activation struct {
+	event    Event            // event to notify modules about
+	source   Source           // source of the configuration
+	previous map[*Module]Data // configuration of fragments before the update
+	rejected []*Module        // fragments which rejected the update
+	errors   []string         // errors of rejected fragments
+}
+
+// newActivation creates a new activation, recording the current configuration of fragments.
+func newActivation(event Event, source Source) (*activation, error) {
+	a := &activation{
+		event:    event,
+		source:   source,
+		previous: map[*Module]Data{},
+	}
+	if err := main.saveFragments(a.previous); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// saveFragments saves the current configuration of this module and its children, if fragments.
+func (m *Module) saveFragments(saved map[*Module]Data) error {
+	if m.fragment && !m.isImplicit() {
+		data, err := DataFromObject(m.ptr)
+		if err != nil {
+			return configError("module %s: failed to save configuration fragment: %v", m.path, err)
+		}
+		saved[m] = data
+	}
+	for _, child := range m.children {
+		if err := child.saveFragments(saved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isChanged checks if the configuration of a module changed during the activation.
+func (a *activation) isChanged(m *Module) bool {
+	previous, ok := a.previous[m]
+	if !ok {
+		return true
+	}
+	current, err := DataFromObject(m.ptr)
+	if err != nil {
+		return true
+	}
+	before, err := json.Marshal(previous)
+	if err != nil {
+		return true
+	}
+	after, err := json.Marshal(current)
+	if err != nil {
+		return true
+	}
+	return string(before) != string(after)
+}
+
+// reject records a fragment rejecting the configuration.
+func (a *activation) reject(m *Module, err error) {
+	a.rejected = append(a.rejected, m)
+	a.errors = append(a.errors, strings.TrimPrefix(err.Error(), errorPrefix))
+}
+
+// rejection returns the combined error of all fragments rejecting the configuration, if any.
+func (a *activation) rejection() error {
+	if len(a.rejected) == 0 {
+		return nil
+	}
+	return configError("%s", strings.Join(a.errors, "; "))
+}
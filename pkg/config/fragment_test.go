@@ -0,0 +1,143 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fragmentConfig is the configuration of a test fragment.
+type fragmentConfig struct {
+	Value int
+}
+
+// testFragment is a test fragment recording its notifications.
+type testFragment struct {
+	cfg    *fragmentConfig
+	events []Event // notified events
+	values []int   // configured values at notification
+	reject bool    // reject updates
+}
+
+// notify is the notifier of a test fragment.
+func (f *testFragment) notify(event Event, source Source) error {
+	f.events = append(f.events, event)
+	f.values = append(f.values, f.cfg.Value)
+	if f.reject && event == UpdateEvent {
+		return fmt.Errorf("rejected value %d", f.cfg.Value)
+	}
+	return nil
+}
+
+// reset clears the recorded notifications of a test fragment.
+func (f *testFragment) reset() {
+	f.events, f.values = nil, nil
+}
+
+// registerTestFragment registers a test fragment with the given path.
+func registerTestFragment(path string) *testFragment {
+	f := &testFragment{cfg: &fragmentConfig{}}
+	Register(path, "test fragment "+path, f.cfg,
+		func() interface{} { return &fragmentConfig{} },
+		WithNotify(f.notify), WithFragment())
+	return f
+}
+
+var (
+	fragmentA = registerTestFragment("fragment-test.a")
+	fragmentB = registerTestFragment("fragment-test.b")
+)
+
+// setFragments sets the configuration of the test fragments.
+func setFragments(a, b int) error {
+	return SetConfig(map[string]string{
+		"fragment-test": fmt.Sprintf("a:\n  Value: %d\nb:\n  Value: %d\n", a, b),
+	})
+}
+
+func TestFragmentNotifications(t *testing.T) {
+	fragmentA.reject, fragmentB.reject = false, false
+	if err := setFragments(1, 1); err != nil {
+		t.Fatalf("failed to set initial configuration: %v", err)
+	}
+
+	fragmentA.reset()
+	fragmentB.reset()
+	if err := setFragments(2, 1); err != nil {
+		t.Fatalf("failed to update configuration: %v", err)
+	}
+	if !reflect.DeepEqual(fragmentA.events, []Event{UpdateEvent}) {
+		t.Errorf("expected changed fragment to be notified once, got %v", fragmentA.events)
+	}
+	if len(fragmentB.events) != 0 {
+		t.Errorf("expected unchanged fragment not to be notified, got %v", fragmentB.events)
+	}
+}
+
+func TestFragmentRejection(t *testing.T) {
+	fragmentA.reject, fragmentB.reject = false, false
+	if err := setFragments(1, 1); err != nil {
+		t.Fatalf("failed to set initial configuration: %v", err)
+	}
+
+	fragmentA.reset()
+	fragmentB.reset()
+	fragmentB.reject = true
+	defer func() { fragmentB.reject = false }()
+
+	if err := setFragments(2, 2); err == nil {
+		t.Fatalf("expected configuration to be rejected")
+	}
+
+	// both fragments see the update, then both are rolled back
+	if !reflect.DeepEqual(fragmentA.events, []Event{UpdateEvent, RevertEvent}) ||
+		!reflect.DeepEqual(fragmentA.values, []int{2, 1}) {
+		t.Errorf("expected accepting fragment to be updated then reverted, got %v %v",
+			fragmentA.events, fragmentA.values)
+	}
+	if !reflect.DeepEqual(fragmentB.events, []Event{UpdateEvent, RevertEvent}) ||
+		!reflect.DeepEqual(fragmentB.values, []int{2, 1}) {
+		t.Errorf("expected rejecting fragment to be updated then reverted, got %v %v",
+			fragmentB.events, fragmentB.values)
+	}
+	if fragmentA.cfg.Value != 1 || fragmentB.cfg.Value != 1 {
+		t.Errorf("expected all fragments rolled back, got a=%d, b=%d",
+			fragmentA.cfg.Value, fragmentB.cfg.Value)
+	}
+}
+
+func TestFragmentRejectionsCollected(t *testing.T) {
+	fragmentA.reject, fragmentB.reject = false, false
+	if err := setFragments(1, 1); err != nil {
+		t.Fatalf("failed to set initial configuration: %v", err)
+	}
+
+	fragmentA.reject, fragmentB.reject = true, true
+	defer func() { fragmentA.reject, fragmentB.reject = false, false }()
+
+	err := setFragments(3, 3)
+	if err == nil {
+		t.Fatalf("expected configuration to be rejected")
+	}
+	for _, module := range []string{"fragment-test.a", "fragment-test.b"} {
+		expected := "module " + module + " rejected update configuration: rejected value 3"
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected rejection of %s reported, got %v", module, err)
+		}
+	}
+}
@@ -42,6 +42,37 @@ func WithoutDataValidation() Option {
 	})
 }
 
+// WithFragment specifies that the module is a configuration fragment of its own.
+// A fragment is only notified about updates and rollbacks which change its own
+// configuration. If it rejects an update, the other fragments are still notified
+// to collect all rejections before the whole update is rolled back.
+func WithFragment() Option {
+	return newFuncOption(func(o interface{}) error {
+		switch o.(type) {
+		case *Module:
+			m := o.(*Module)
+			m.fragment = true
+		default:
+			return configError("WithFragment is not valid for object of type %T", o)
+		}
+		return nil
+	})
+}
+
+// WithValidator specifies a function to check configuration data before it is applied.
+func WithValidator(fn ValidateFn) Option {
+	return newFuncOption(func(o interface{}) error {
+		switch o.(type) {
+		case *Module:
+			m := o.(*Module)
+			m.validators = append(m.validators, fn)
+		default:
+			return configError("WithValidator is not valid for object of type %T", o)
+		}
+		return nil
+	})
+}
+
 // Option is the generic interface for any option applicable to a Module or Config.
 type Option interface {
 	apply(interface{}) error
@@ -18,8 +18,7 @@ import (
 	"fmt"
 	"sort"
 
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
-
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 	"github.com/intel/cri-resource-manager/pkg/sysfs"
 )
@@ -18,8 +18,9 @@ import (
 	"fmt"
 	"testing"
 
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 	//"github.com/google/go-cmp/cmp"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 )
 
 func TestCPUAllocator(t *testing.T) {
@@ -70,3 +71,33 @@ func TestCPUAllocator(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkAllocateCpus(b *testing.B) {
+	// Mock system discovery failure
+	system = sysfsSingleton{sys: nil, err: fmt.Errorf("mock sysfs discovery error")}
+
+	for _, bc := range []struct {
+		cpus int
+		cnt  int
+	}{
+		{cpus: 64, cnt: 2},
+		{cpus: 448, cnt: 2},
+		{cpus: 448, cnt: 64},
+	} {
+		b.Run(fmt.Sprintf("%d-of-%d-cpus", bc.cnt, bc.cpus), func(b *testing.B) {
+			all := cpuset.NewCPUSet()
+			for id := 0; id < bc.cpus; id++ {
+				all = all.Union(cpuset.NewCPUSet(id))
+			}
+			preferred := cpuset.NewCPUSet(0, 1, bc.cpus/2, bc.cpus-1)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				from := all.Clone()
+				if _, err := allocateCpus(&from, bc.cnt, preferred); err != nil {
+					b.Fatalf("failed to allocate %d CPUs: %v", bc.cnt, err)
+				}
+			}
+		})
+	}
+}
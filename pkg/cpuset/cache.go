@@ -0,0 +1,55 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuset
+
+import (
+	"sync"
+)
+
+const (
+	// maxParsed is the maximum number of parsed CPU list strings we cache.
+	maxParsed = 4096
+)
+
+// parseCache caches the results of parsing CPU list strings.
+//
+// Since sets are immutable, cached sets can be handed out as such. Once the
+// cache is full it is simply flushed, the working set of distinct cpusets
+// on a node being normally far below the cache size.
+type parseCache struct {
+	sync.RWMutex
+	sets map[string]CPUSet
+}
+
+// Our cache of parsed CPU list strings.
+var parsed = &parseCache{sets: make(map[string]CPUSet)}
+
+// get looks up the set parsed from the given string.
+func (c *parseCache) get(s string) (CPUSet, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	set, ok := c.sets[s]
+	return set, ok
+}
+
+// put caches the set parsed from the given string.
+func (c *parseCache) put(s string, set CPUSet) {
+	c.Lock()
+	defer c.Unlock()
+	if len(c.sets) >= maxParsed {
+		c.sets = make(map[string]CPUSet)
+	}
+	c.sets[s] = set
+}
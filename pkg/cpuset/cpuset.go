@@ -0,0 +1,365 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpuset implements immutable sets of CPUs as bitsets.
+//
+// The API is a drop-in replacement for k8s.io/kubernetes/pkg/kubelet/cm/cpuset,
+// whose map-based sets get expensive on machines with hundreds of CPUs. Set
+// operations here work a machine word (64 CPUs) at a time, and the results of
+// parsing CPU list strings are cached, since the same few cpusets tend to get
+// parsed over and over again.
+package cpuset
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	// wordBits is the number of CPUs in a bitset word.
+	wordBits = 64
+)
+
+// CPUSet is an immutable set of CPUs.
+//
+// The zero value is an empty set. Sets never have trailing zero words, so two
+// sets are equal if their words are.
+type CPUSet struct {
+	words []uint64
+}
+
+// Builder is a mutable builder for CPUSet.
+type Builder struct {
+	words []uint64
+	done  bool
+}
+
+// NewBuilder returns a new, empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Add adds the given CPUs to the set being built. Negative CPUs are ignored.
+// Calls after Result has been called are ignored.
+func (b *Builder) Add(cpus ...int) {
+	if b.done {
+		return
+	}
+	for _, cpu := range cpus {
+		b.words = setBit(b.words, cpu)
+	}
+}
+
+// addRange adds the CPUs in the range [start, end] to the set being built.
+func (b *Builder) addRange(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end < start {
+		return
+	}
+	b.words = grow(b.words, end/wordBits+1)
+	for w := start / wordBits; w <= end/wordBits; w++ {
+		mask := ^uint64(0)
+		if w == start/wordBits {
+			mask &= ^uint64(0) << uint(start%wordBits)
+		}
+		if w == end/wordBits {
+			mask &= ^uint64(0) >> uint(wordBits-1-end%wordBits)
+		}
+		b.words[w] |= mask
+	}
+}
+
+// Result returns the built set. The Builder can't be used after this.
+func (b *Builder) Result() CPUSet {
+	b.done = true
+	return CPUSet{words: trim(b.words)}
+}
+
+// NewCPUSet returns a new set of the given CPUs. Negative CPUs are ignored.
+func NewCPUSet(cpus ...int) CPUSet {
+	b := NewBuilder()
+	b.Add(cpus...)
+	return b.Result()
+}
+
+// Size returns the number of CPUs in the set.
+func (s CPUSet) Size() int {
+	size := 0
+	for _, w := range s.words {
+		size += bits.OnesCount64(w)
+	}
+	return size
+}
+
+// IsEmpty returns true if there are no CPUs in the set.
+func (s CPUSet) IsEmpty() bool {
+	return len(s.words) == 0
+}
+
+// Contains returns true if the set contains the given CPU.
+func (s CPUSet) Contains(cpu int) bool {
+	if cpu < 0 || cpu/wordBits >= len(s.words) {
+		return false
+	}
+	return s.words[cpu/wordBits]&(1<<uint(cpu%wordBits)) != 0
+}
+
+// Equals returns true if the two sets contain the same CPUs.
+func (s CPUSet) Equals(s2 CPUSet) bool {
+	if len(s.words) != len(s2.words) {
+		return false
+	}
+	for i, w := range s.words {
+		if w != s2.words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns a new set of the CPUs in this set for which predicate is true.
+func (s CPUSet) Filter(predicate func(int) bool) CPUSet {
+	b := NewBuilder()
+	s.forEach(func(cpu int) {
+		if predicate(cpu) {
+			b.Add(cpu)
+		}
+	})
+	return b.Result()
+}
+
+// FilterNot returns a new set of the CPUs in this set for which predicate is false.
+func (s CPUSet) FilterNot(predicate func(int) bool) CPUSet {
+	return s.Filter(func(cpu int) bool { return !predicate(cpu) })
+}
+
+// IsSubsetOf returns true if all CPUs in this set are also in the other one.
+func (s CPUSet) IsSubsetOf(s2 CPUSet) bool {
+	if len(s.words) > len(s2.words) {
+		return false
+	}
+	for i, w := range s.words {
+		if w&^s2.words[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Union returns a new set of the CPUs in either this or the other set.
+func (s CPUSet) Union(s2 CPUSet) CPUSet {
+	a, b := s.words, s2.words
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(b) == 0 {
+		return CPUSet{words: a}
+	}
+	words := make([]uint64, len(a))
+	copy(words, a)
+	for i, w := range b {
+		words[i] |= w
+	}
+	return CPUSet{words: words}
+}
+
+// UnionAll returns a new set of the CPUs in this or any of the other sets.
+func (s CPUSet) UnionAll(s2 []CPUSet) CPUSet {
+	n := len(s.words)
+	for _, o := range s2 {
+		if len(o.words) > n {
+			n = len(o.words)
+		}
+	}
+	words := make([]uint64, n)
+	copy(words, s.words)
+	for _, o := range s2 {
+		for i, w := range o.words {
+			words[i] |= w
+		}
+	}
+	return CPUSet{words: trim(words)}
+}
+
+// Intersection returns a new set of the CPUs in both this and the other set.
+func (s CPUSet) Intersection(s2 CPUSet) CPUSet {
+	n := len(s.words)
+	if len(s2.words) < n {
+		n = len(s2.words)
+	}
+	words := make([]uint64, n)
+	for i := range words {
+		words[i] = s.words[i] & s2.words[i]
+	}
+	return CPUSet{words: trim(words)}
+}
+
+// Difference returns a new set of the CPUs in this but not in the other set.
+func (s CPUSet) Difference(s2 CPUSet) CPUSet {
+	words := make([]uint64, len(s.words))
+	copy(words, s.words)
+	for i := 0; i < len(words) && i < len(s2.words); i++ {
+		words[i] &^= s2.words[i]
+	}
+	return CPUSet{words: trim(words)}
+}
+
+// ToSlice returns a slice of the CPUs in the set, in ascending order.
+func (s CPUSet) ToSlice() []int {
+	cpus := make([]int, 0, s.Size())
+	s.forEach(func(cpu int) {
+		cpus = append(cpus, cpu)
+	})
+	return cpus
+}
+
+// ToSliceNoSort returns a slice of the CPUs in the set. It is the same as ToSlice.
+func (s CPUSet) ToSliceNoSort() []int {
+	return s.ToSlice()
+}
+
+// String returns the set in Linux CPU list format, for instance "0-5,34,46-48".
+func (s CPUSet) String() string {
+	if s.IsEmpty() {
+		return ""
+	}
+
+	var sb strings.Builder
+	start, end := -1, -1
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.Itoa(start))
+		if end != start {
+			sb.WriteByte('-')
+			sb.WriteString(strconv.Itoa(end))
+		}
+	}
+	s.forEach(func(cpu int) {
+		if cpu == end+1 && start >= 0 {
+			end = cpu
+			return
+		}
+		flush()
+		start, end = cpu, cpu
+	})
+	flush()
+
+	return sb.String()
+}
+
+// Clone returns a copy of the set. Since sets are immutable, this is the set itself.
+func (s CPUSet) Clone() CPUSet {
+	return s
+}
+
+// MustParse parses a CPU list string, panicking on errors.
+func MustParse(s string) CPUSet {
+	set, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// Parse parses a CPU list string, for instance "0-5,34,46-48", into a set.
+func Parse(s string) (CPUSet, error) {
+	if s == "" {
+		return CPUSet{}, nil
+	}
+	if set, ok := parsed.get(s); ok {
+		return set, nil
+	}
+
+	b := NewBuilder()
+	for _, r := range strings.Split(s, ",") {
+		boundaries := strings.Split(r, "-")
+		switch len(boundaries) {
+		case 1:
+			cpu, err := strconv.Atoi(boundaries[0])
+			if err != nil {
+				return NewCPUSet(), err
+			}
+			b.Add(cpu)
+		case 2:
+			start, err := strconv.Atoi(boundaries[0])
+			if err != nil {
+				return NewCPUSet(), err
+			}
+			end, err := strconv.Atoi(boundaries[1])
+			if err != nil {
+				return NewCPUSet(), err
+			}
+			b.addRange(start, end)
+		}
+	}
+
+	set := b.Result()
+	parsed.put(s, set)
+
+	return set, nil
+}
+
+// forEach calls fn for each CPU in the set, in ascending order.
+func (s CPUSet) forEach(fn func(int)) {
+	for i, w := range s.words {
+		for w != 0 {
+			bit := bits.TrailingZeros64(w)
+			fn(i*wordBits + bit)
+			w &= w - 1
+		}
+	}
+}
+
+// setBit sets the bit of the given CPU, growing words as necessary.
+func setBit(words []uint64, cpu int) []uint64 {
+	if cpu < 0 {
+		return words
+	}
+	words = grow(words, cpu/wordBits+1)
+	words[cpu/wordBits] |= 1 << uint(cpu%wordBits)
+	return words
+}
+
+// grow grows words to at least n words.
+func grow(words []uint64, n int) []uint64 {
+	if len(words) >= n {
+		return words
+	}
+	if cap(words) >= n {
+		return words[:n]
+	}
+	grown := make([]uint64, n)
+	copy(grown, words)
+	return grown
+}
+
+// trim drops trailing zero words.
+func trim(words []uint64) []uint64 {
+	n := len(words)
+	for n > 0 && words[n-1] == 0 {
+		n--
+	}
+	if n == 0 {
+		return nil
+	}
+	return words[:n]
+}
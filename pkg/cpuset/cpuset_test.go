@@ -0,0 +1,236 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuset
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	k8scpuset "k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+)
+
+func TestParseAndString(t *testing.T) {
+	tcases := []struct {
+		name    string
+		input   string
+		cpus    []int
+		output  string
+		invalid bool
+	}{
+		{name: "empty", input: "", cpus: []int{}, output: ""},
+		{name: "single", input: "5", cpus: []int{5}, output: "5"},
+		{name: "range", input: "0-3", cpus: []int{0, 1, 2, 3}, output: "0-3"},
+		{name: "mixed", input: "0-2,7,9-10", cpus: []int{0, 1, 2, 7, 9, 10}, output: "0-2,7,9-10"},
+		{name: "unsorted", input: "9,1,2,0", cpus: []int{0, 1, 2, 9}, output: "0-2,9"},
+		{name: "word boundary", input: "62-65", cpus: []int{62, 63, 64, 65}, output: "62-65"},
+		{name: "big", input: "0,511", cpus: []int{0, 511}, output: "0,511"},
+		{name: "full word", input: "64-127", output: "64-127"},
+		{name: "invalid", input: "0-x", invalid: true},
+		{name: "invalid single", input: "a", invalid: true},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			set, err := Parse(tc.input)
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("expected error parsing %q, got %s", tc.input, set)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", tc.input, err)
+			}
+			if tc.cpus != nil && !reflect.DeepEqual(set.ToSlice(), tc.cpus) {
+				t.Errorf("expected CPUs %v, got %v", tc.cpus, set.ToSlice())
+			}
+			if set.String() != tc.output {
+				t.Errorf("expected %q, got %q", tc.output, set.String())
+			}
+			again, _ := Parse(tc.input)
+			if !again.Equals(set) {
+				t.Errorf("expected cached parse of %q to be %s, got %s", tc.input, set, again)
+			}
+		})
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := MustParse("0-5,64,130")
+	b := MustParse("4-7,130,200")
+
+	tcases := []struct {
+		name     string
+		result   CPUSet
+		expected string
+	}{
+		{name: "union", result: a.Union(b), expected: "0-7,64,130,200"},
+		{name: "union all", result: a.UnionAll([]CPUSet{b, NewCPUSet(300)}), expected: "0-7,64,130,200,300"},
+		{name: "intersection", result: a.Intersection(b), expected: "4-5,130"},
+		{name: "difference", result: a.Difference(b), expected: "0-3,64"},
+		{name: "reverse difference", result: b.Difference(a), expected: "6-7,200"},
+		{name: "filter", result: a.Filter(func(cpu int) bool { return cpu%2 == 0 }), expected: "0,2,4,64,130"},
+		{name: "filter not", result: a.FilterNot(func(cpu int) bool { return cpu%2 == 0 }), expected: "1,3,5"},
+		{name: "trimmed difference", result: b.Difference(MustParse("130-200")), expected: "4-7"},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.result.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, tc.result.String())
+			}
+			if !tc.result.Equals(MustParse(tc.expected)) {
+				t.Errorf("expected %s to equal parsed %q", tc.result, tc.expected)
+			}
+		})
+	}
+
+	if a.Size() != 8 {
+		t.Errorf("expected size 8 for %s, got %d", a, a.Size())
+	}
+	if !a.Contains(64) || a.Contains(63) || a.Contains(1000) || a.Contains(-1) {
+		t.Errorf("unexpected membership results for %s", a)
+	}
+	if !MustParse("4-5").IsSubsetOf(a) || b.IsSubsetOf(a) || !NewCPUSet().IsSubsetOf(a) {
+		t.Errorf("unexpected subset results for %s", a)
+	}
+	if !a.Intersection(MustParse("300")).IsEmpty() {
+		t.Errorf("expected empty intersection")
+	}
+	if !(CPUSet{}).Equals(NewCPUSet()) {
+		t.Errorf("expected zero value to equal empty set")
+	}
+}
+
+func TestCompatibility(t *testing.T) {
+	inputs := []string{"", "0", "0-63", "1-3,5,64-70,511", "100-200,7,8,9"}
+	for _, a := range inputs {
+		for _, b := range inputs {
+			s1, s2 := MustParse(a), MustParse(b)
+			k1, k2 := k8scpuset.MustParse(a), k8scpuset.MustParse(b)
+			checks := map[string][2]string{
+				"union":        {s1.Union(s2).String(), k1.Union(k2).String()},
+				"intersection": {s1.Intersection(s2).String(), k1.Intersection(k2).String()},
+				"difference":   {s1.Difference(s2).String(), k1.Difference(k2).String()},
+				"subset":       {fmt.Sprint(s1.IsSubsetOf(s2)), fmt.Sprint(k1.IsSubsetOf(k2))},
+				"equals":       {fmt.Sprint(s1.Equals(s2)), fmt.Sprint(k1.Equals(k2))},
+				"size":         {fmt.Sprint(s1.Size()), fmt.Sprint(k1.Size())},
+			}
+			for op, results := range checks {
+				if results[0] != results[1] {
+					t.Errorf("%q %s %q: expected %s, got %s", a, op, b, results[1], results[0])
+				}
+			}
+		}
+	}
+}
+
+// bigMachine returns the CPU lists of the packages of a 4-socket, 512-thread machine.
+//
+// Each package has 64 cores with 2 hyperthreads, the second hyperthreads of all
+// cores enumerated after the first ones, as on typical Xeon machines.
+func bigMachine() []string {
+	packages := []string{}
+	for pkg := 0; pkg < 4; pkg++ {
+		packages = append(packages, fmt.Sprintf("%d-%d,%d-%d",
+			pkg*64, pkg*64+63, 256+pkg*64, 256+pkg*64+63))
+	}
+	return packages
+}
+
+// containerCPUs returns the CPU lists of containers spread over a big machine.
+func containerCPUs() []string {
+	cpus := []string{}
+	for i := 0; i < 256; i += 4 {
+		cpus = append(cpus, fmt.Sprintf("%d-%d,%d-%d", i, i+3, 256+i, 256+i+3))
+	}
+	return cpus
+}
+
+func BenchmarkParse(b *testing.B) {
+	inputs := append(bigMachine(), containerCPUs()...)
+	b.Run("bitset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, s := range inputs {
+				MustParse(s)
+			}
+		}
+	})
+	b.Run("k8s", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, s := range inputs {
+				k8scpuset.MustParse(s)
+			}
+		}
+	})
+}
+
+func BenchmarkString(b *testing.B) {
+	machine := bigMachine()
+	set := MustParse(machine[0]).UnionAll([]CPUSet{MustParse(machine[2])})
+	kset := k8scpuset.MustParse(machine[0]).Union(k8scpuset.MustParse(machine[2]))
+	b.Run("bitset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = set.String()
+		}
+	})
+	b.Run("k8s", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = kset.String()
+		}
+	})
+}
+
+// BenchmarkRebalance mimics the set operations of a rebalancing pass, finding
+// the free CPUs of each package after taking out the CPUs of all containers.
+func BenchmarkRebalance(b *testing.B) {
+	machine, containers := bigMachine(), containerCPUs()
+
+	b.Run("bitset", func(b *testing.B) {
+		packages := []CPUSet{}
+		for _, s := range machine {
+			packages = append(packages, MustParse(s))
+		}
+		for i := 0; i < b.N; i++ {
+			for _, pkg := range packages {
+				free := pkg
+				for _, s := range containers {
+					cpus := MustParse(s)
+					if !cpus.Intersection(pkg).IsEmpty() {
+						free = free.Difference(cpus)
+					}
+				}
+				_ = free.Size()
+			}
+		}
+	})
+	b.Run("k8s", func(b *testing.B) {
+		packages := []k8scpuset.CPUSet{}
+		for _, s := range machine {
+			packages = append(packages, k8scpuset.MustParse(s))
+		}
+		for i := 0; i < b.N; i++ {
+			for _, pkg := range packages {
+				free := pkg
+				for _, s := range containers {
+					cpus := k8scpuset.MustParse(s)
+					if !cpus.Intersection(pkg).IsEmpty() {
+						free = free.Difference(cpus)
+					}
+				}
+				_ = free.Size()
+			}
+		}
+	})
+}
@@ -15,6 +15,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"time"
@@ -35,6 +36,10 @@ type Options struct {
 	ImageSocket string
 	// RuntimeSocket is the socket path for the CRI runtime service.
 	RuntimeSocket string
+	// ReconnectMaxDelay bounds the backoff between reconnection attempts, 0 for the gRPC default.
+	ReconnectMaxDelay time.Duration
+	// WaitForReady is how long requests are held while a lost connection comes back, 0 to fail fast.
+	WaitForReady time.Duration
 }
 
 // ConnectOptions contains options for connecting to the server.
@@ -57,6 +62,8 @@ type Client interface {
 	HasRuntimeService() bool
 	// HasImageService checks if the client is configured with image services.
 	HasImageService() bool
+	// ResetBackoff makes connections being re-established retry immediately.
+	ResetBackoff()
 
 	// We expose full image and runtime client services.
 	api.ImageServiceClient
@@ -161,6 +168,16 @@ func (c *client) CheckConnection(options ConnectOptions) error {
 	return clientError("client connections are down")
 }
 
+// ResetBackoff makes connections being re-established retry immediately.
+func (c *client) ResetBackoff() {
+	if c.icc != nil {
+		c.icc.ResetConnectBackoff()
+	}
+	if c.rcc != nil && c.rcc != c.icc {
+		c.rcc.ResetConnectBackoff()
+	}
+}
+
 // HasRuntimeService checks if the client is configured with runtime services.
 func (c *client) HasRuntimeService() bool {
 	return c.options.RuntimeSocket != "" && c.options.RuntimeSocket != DontConnect
@@ -187,6 +204,12 @@ func (c *client) connect(kind, socket string, options ConnectOptions) (*grpc.Cli
 		grpc.WithDialer(func(socket string, timeout time.Duration) (net.Conn, error) {
 			return net.DialTimeout("unix", socket, timeout)
 		}))
+	if c.options.ReconnectMaxDelay > 0 {
+		dialOpts = append(dialOpts, grpc.WithBackoffMaxDelay(c.options.ReconnectMaxDelay))
+	}
+	if c.options.WaitForReady > 0 {
+		dialOpts = append(dialOpts, grpc.WithUnaryInterceptor(c.waitForReady))
+	}
 
 	if options.Wait {
 		c.Info("waiting for %s on socket %s...", kind, socket)
@@ -202,6 +225,29 @@ func (c *client) connect(kind, socket string, options ConnectOptions) (*grpc.Cli
 	return cc, nil
 }
 
+// waitForReady lets requests wait a bounded time for a lost connection to come back.
+//
+// Requests sent while the connection is being re-established are held until it
+// is ready again, for at most the configured time, instead of failing right away.
+// Requests on a ready connection are not affected.
+func (c *client) waitForReady(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if state := cc.GetState(); state != connectivity.Ready {
+		c.Debug("connection %s, waiting up to %v for %s", state, c.options.WaitForReady, method)
+		cc.ResetConnectBackoff()
+		wctx, cancel := context.WithTimeout(ctx, c.options.WaitForReady)
+		for state == connectivity.Idle || state == connectivity.Connecting ||
+			state == connectivity.TransientFailure {
+			if !cc.WaitForStateChange(wctx, state) {
+				break
+			}
+			state = cc.GetState()
+		}
+		cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
 // Return a formatted client-specific error.
 func clientError(format string, args ...interface{}) error {
 	return fmt.Errorf("cri/client: "+format, args...)
@@ -0,0 +1,151 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// dialUnix creates a non-blocking gRPC client connection to a unix socket.
+func dialUnix(t *testing.T, socket string) *grpc.ClientConn {
+	cc, err := grpc.Dial(socket, grpc.WithInsecure(),
+		grpc.WithDialer(func(socket string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", socket, timeout)
+		}))
+	if err != nil {
+		t.Fatalf("failed to dial %s: %v", socket, err)
+	}
+	return cc
+}
+
+// serveUnix starts a gRPC server on a unix socket.
+func serveUnix(t *testing.T, socket string) *grpc.Server {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Errorf("failed to listen on %s: %v", socket, err)
+		return grpc.NewServer()
+	}
+	srv := grpc.NewServer()
+	go srv.Serve(l)
+	return srv
+}
+
+func TestWaitForReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tcs := []struct {
+		name    string
+		wait    time.Duration
+		serve   time.Duration // delay before starting the server, negative for none
+		min     time.Duration // minimum time the request is held
+		max     time.Duration // maximum time the request is held
+		state   connectivity.State
+		started bool // whether the server is started before the request
+	}{
+		{
+			name:  "runtime stays down",
+			wait:  300 * time.Millisecond,
+			serve: -1,
+			min:   250 * time.Millisecond,
+			max:   2 * time.Second,
+		},
+		{
+			name:  "runtime comes back",
+			wait:  10 * time.Second,
+			serve: 100 * time.Millisecond,
+			max:   5 * time.Second,
+			state: connectivity.Ready,
+		},
+		{
+			name:    "runtime up",
+			wait:    10 * time.Second,
+			max:     time.Second,
+			state:   connectivity.Ready,
+			started: true,
+		},
+	}
+
+	for i, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			socket := filepath.Join(dir, "socket"+strconv.Itoa(i))
+			if tc.started {
+				srv := serveUnix(t, socket)
+				defer srv.Stop()
+			}
+
+			cc := dialUnix(t, socket)
+			defer cc.Close()
+
+			if tc.started {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				for s := cc.GetState(); s != connectivity.Ready; s = cc.GetState() {
+					if !cc.WaitForStateChange(ctx, s) {
+						break
+					}
+				}
+				cancel()
+			}
+			if tc.serve > 0 {
+				stop := make(chan struct{})
+				defer close(stop)
+				go func() {
+					time.Sleep(tc.serve)
+					srv := serveUnix(t, socket)
+					<-stop
+					srv.Stop()
+				}()
+			}
+
+			c := &client{
+				Logger:  logger.NewLogger("cri/client"),
+				options: Options{WaitForReady: tc.wait},
+			}
+			state := connectivity.Shutdown
+			invoker := func(ctx context.Context, method string, req, reply interface{},
+				cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				state = cc.GetState()
+				return nil
+			}
+
+			start := time.Now()
+			if err := c.waitForReady(context.Background(), "/test", nil, nil, cc, invoker); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			held := time.Since(start)
+			if held < tc.min || held > tc.max {
+				t.Errorf("expected request to be held between %v and %v, held %v", tc.min, tc.max, held)
+			}
+			if tc.state == connectivity.Ready && state != connectivity.Ready {
+				t.Errorf("expected request to be sent on a ready connection, got %v", state)
+			}
+		})
+	}
+}
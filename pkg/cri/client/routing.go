@@ -0,0 +1,340 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	api "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// RoutingOptions contains the configurable options of a runtime-routing client.
+type RoutingOptions struct {
+	// Primary is the options for the primary client, used by default.
+	Primary Options
+	// SecondaryRuntimeSocket is the socket path for the secondary runtime service.
+	SecondaryRuntimeSocket string
+	// SecondaryRuntimeHandlers are the runtime handlers served by the secondary runtime.
+	SecondaryRuntimeHandlers []string
+}
+
+// routingClient relays runtime requests to one of two runtimes.
+//
+// Pod sandboxes are routed by their runtime handler. Requests for a pod, or
+// a container in a pod, are routed to the runtime that runs the pod. Listing
+// requests are sent to both runtimes with the results merged. Image requests
+// and any requests for unknown pods or containers go to the primary runtime.
+type routingClient struct {
+	Client                            // primary client, also the default route
+	logger.Logger                     // our logger instance
+	sync.Mutex                        // protects the routing tables
+	secondary     Client              // secondary runtime client
+	handlers      map[string]struct{} // runtime handlers of the secondary runtime
+	pods          map[string]struct{} // pod sandboxes in the secondary runtime
+	containers    map[string]struct{} // containers in the secondary runtime
+}
+
+// NewRoutingClient creates a client relaying runtime requests to two runtimes.
+func NewRoutingClient(options RoutingOptions) (Client, error) {
+	primary, err := NewClient(options.Primary)
+	if err != nil {
+		return nil, err
+	}
+	if options.SecondaryRuntimeSocket == "" || options.SecondaryRuntimeSocket == DontConnect {
+		return primary, nil
+	}
+
+	secondary, err := NewClient(Options{
+		ImageSocket:       DontConnect,
+		RuntimeSocket:     options.SecondaryRuntimeSocket,
+		ReconnectMaxDelay: options.Primary.ReconnectMaxDelay,
+		WaitForReady:      options.Primary.WaitForReady,
+	})
+	if err != nil {
+		return nil, clientError("failed to create secondary runtime client: %v", err)
+	}
+
+	c := &routingClient{
+		Client:     primary,
+		Logger:     logger.NewLogger("cri/client"),
+		secondary:  secondary,
+		handlers:   make(map[string]struct{}),
+		pods:       make(map[string]struct{}),
+		containers: make(map[string]struct{}),
+	}
+	for _, handler := range options.SecondaryRuntimeHandlers {
+		c.handlers[handler] = struct{}{}
+	}
+
+	return c, nil
+}
+
+// Connect connects both runtimes and learns which pods the secondary one runs.
+func (c *routingClient) Connect(options ConnectOptions) error {
+	if err := c.Client.Connect(options); err != nil {
+		return err
+	}
+	if err := c.secondary.Connect(options); err != nil {
+		c.Client.Close()
+		return clientError("secondary runtime: %v", err)
+	}
+	return c.syncRoutes()
+}
+
+// Close closes the connections to both runtimes.
+func (c *routingClient) Close() {
+	c.Client.Close()
+	c.secondary.Close()
+}
+
+// CheckConnection checks the connections to both runtimes.
+func (c *routingClient) CheckConnection(options ConnectOptions) error {
+	if err := c.Client.CheckConnection(options); err != nil {
+		return err
+	}
+	if err := c.secondary.CheckConnection(options); err != nil {
+		return clientError("secondary runtime: %v", err)
+	}
+	return nil
+}
+
+// ResetBackoff makes connections to both runtimes being re-established retry immediately.
+func (c *routingClient) ResetBackoff() {
+	c.Client.ResetBackoff()
+	c.secondary.ResetBackoff()
+}
+
+// syncRoutes rebuilds the routing tables from the secondary runtime.
+func (c *routingClient) syncRoutes() error {
+	ctx := context.Background()
+
+	pods, err := c.secondary.ListPodSandbox(ctx, &api.ListPodSandboxRequest{})
+	if err != nil {
+		return clientError("failed to list secondary runtime pods: %v", err)
+	}
+	containers, err := c.secondary.ListContainers(ctx, &api.ListContainersRequest{})
+	if err != nil {
+		return clientError("failed to list secondary runtime containers: %v", err)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.pods = make(map[string]struct{})
+	for _, pod := range pods.Items {
+		c.pods[pod.Id] = struct{}{}
+	}
+	c.containers = make(map[string]struct{})
+	for _, container := range containers.Containers {
+		c.containers[container.Id] = struct{}{}
+	}
+
+	c.Info("secondary runtime has %d pods, %d containers", len(c.pods), len(c.containers))
+
+	return nil
+}
+
+// forHandler returns the client for the given runtime handler.
+func (c *routingClient) forHandler(handler string) Client {
+	if _, ok := c.handlers[handler]; ok {
+		return c.secondary
+	}
+	return c.Client
+}
+
+// forPod returns the client for the given pod sandbox.
+func (c *routingClient) forPod(id string) Client {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.pods[id]; ok {
+		return c.secondary
+	}
+	return c.Client
+}
+
+// forContainer returns the client for the given container.
+func (c *routingClient) forContainer(id string) Client {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.containers[id]; ok {
+		return c.secondary
+	}
+	return c.Client
+}
+
+// setRoute updates the routing table for a pod or container.
+func (c *routingClient) setRoute(table map[string]struct{}, id string, secondary bool) {
+	c.Lock()
+	defer c.Unlock()
+	if secondary {
+		table[id] = struct{}{}
+	} else {
+		delete(table, id)
+	}
+}
+
+func (c *routingClient) RunPodSandbox(ctx context.Context, in *api.RunPodSandboxRequest,
+	opts ...grpc.CallOption) (*api.RunPodSandboxResponse, error) {
+	cli := c.forHandler(in.RuntimeHandler)
+	rpl, err := cli.RunPodSandbox(ctx, in, opts...)
+	if err == nil && cli == c.secondary {
+		c.Debug("pod sandbox %s (handler %q) routed to secondary runtime",
+			rpl.PodSandboxId, in.RuntimeHandler)
+		c.setRoute(c.pods, rpl.PodSandboxId, true)
+	}
+	return rpl, err
+}
+
+func (c *routingClient) StopPodSandbox(ctx context.Context, in *api.StopPodSandboxRequest,
+	opts ...grpc.CallOption) (*api.StopPodSandboxResponse, error) {
+	return c.forPod(in.PodSandboxId).StopPodSandbox(ctx, in, opts...)
+}
+
+func (c *routingClient) RemovePodSandbox(ctx context.Context, in *api.RemovePodSandboxRequest,
+	opts ...grpc.CallOption) (*api.RemovePodSandboxResponse, error) {
+	rpl, err := c.forPod(in.PodSandboxId).RemovePodSandbox(ctx, in, opts...)
+	if err == nil {
+		c.setRoute(c.pods, in.PodSandboxId, false)
+	}
+	return rpl, err
+}
+
+func (c *routingClient) PodSandboxStatus(ctx context.Context, in *api.PodSandboxStatusRequest,
+	opts ...grpc.CallOption) (*api.PodSandboxStatusResponse, error) {
+	return c.forPod(in.PodSandboxId).PodSandboxStatus(ctx, in, opts...)
+}
+
+func (c *routingClient) ListPodSandbox(ctx context.Context, in *api.ListPodSandboxRequest,
+	opts ...grpc.CallOption) (*api.ListPodSandboxResponse, error) {
+	rpl, err := c.Client.ListPodSandbox(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	more, err := c.secondary.ListPodSandbox(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rpl.Items = append(rpl.Items, more.Items...)
+	return rpl, nil
+}
+
+func (c *routingClient) CreateContainer(ctx context.Context, in *api.CreateContainerRequest,
+	opts ...grpc.CallOption) (*api.CreateContainerResponse, error) {
+	cli := c.forPod(in.PodSandboxId)
+	rpl, err := cli.CreateContainer(ctx, in, opts...)
+	if err == nil && cli == c.secondary {
+		c.setRoute(c.containers, rpl.ContainerId, true)
+	}
+	return rpl, err
+}
+
+func (c *routingClient) StartContainer(ctx context.Context, in *api.StartContainerRequest,
+	opts ...grpc.CallOption) (*api.StartContainerResponse, error) {
+	return c.forContainer(in.ContainerId).StartContainer(ctx, in, opts...)
+}
+
+func (c *routingClient) StopContainer(ctx context.Context, in *api.StopContainerRequest,
+	opts ...grpc.CallOption) (*api.StopContainerResponse, error) {
+	return c.forContainer(in.ContainerId).StopContainer(ctx, in, opts...)
+}
+
+func (c *routingClient) RemoveContainer(ctx context.Context, in *api.RemoveContainerRequest,
+	opts ...grpc.CallOption) (*api.RemoveContainerResponse, error) {
+	rpl, err := c.forContainer(in.ContainerId).RemoveContainer(ctx, in, opts...)
+	if err == nil {
+		c.setRoute(c.containers, in.ContainerId, false)
+	}
+	return rpl, err
+}
+
+func (c *routingClient) ListContainers(ctx context.Context, in *api.ListContainersRequest,
+	opts ...grpc.CallOption) (*api.ListContainersResponse, error) {
+	rpl, err := c.Client.ListContainers(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	more, err := c.secondary.ListContainers(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rpl.Containers = append(rpl.Containers, more.Containers...)
+	return rpl, nil
+}
+
+func (c *routingClient) ContainerStatus(ctx context.Context, in *api.ContainerStatusRequest,
+	opts ...grpc.CallOption) (*api.ContainerStatusResponse, error) {
+	return c.forContainer(in.ContainerId).ContainerStatus(ctx, in, opts...)
+}
+
+func (c *routingClient) UpdateContainerResources(ctx context.Context, in *api.UpdateContainerResourcesRequest,
+	opts ...grpc.CallOption) (*api.UpdateContainerResourcesResponse, error) {
+	return c.forContainer(in.ContainerId).UpdateContainerResources(ctx, in, opts...)
+}
+
+func (c *routingClient) ReopenContainerLog(ctx context.Context, in *api.ReopenContainerLogRequest,
+	opts ...grpc.CallOption) (*api.ReopenContainerLogResponse, error) {
+	return c.forContainer(in.ContainerId).ReopenContainerLog(ctx, in, opts...)
+}
+
+func (c *routingClient) ExecSync(ctx context.Context, in *api.ExecSyncRequest,
+	opts ...grpc.CallOption) (*api.ExecSyncResponse, error) {
+	return c.forContainer(in.ContainerId).ExecSync(ctx, in, opts...)
+}
+
+func (c *routingClient) Exec(ctx context.Context, in *api.ExecRequest,
+	opts ...grpc.CallOption) (*api.ExecResponse, error) {
+	return c.forContainer(in.ContainerId).Exec(ctx, in, opts...)
+}
+
+func (c *routingClient) Attach(ctx context.Context, in *api.AttachRequest,
+	opts ...grpc.CallOption) (*api.AttachResponse, error) {
+	return c.forContainer(in.ContainerId).Attach(ctx, in, opts...)
+}
+
+func (c *routingClient) PortForward(ctx context.Context, in *api.PortForwardRequest,
+	opts ...grpc.CallOption) (*api.PortForwardResponse, error) {
+	return c.forPod(in.PodSandboxId).PortForward(ctx, in, opts...)
+}
+
+func (c *routingClient) ContainerStats(ctx context.Context, in *api.ContainerStatsRequest,
+	opts ...grpc.CallOption) (*api.ContainerStatsResponse, error) {
+	return c.forContainer(in.ContainerId).ContainerStats(ctx, in, opts...)
+}
+
+func (c *routingClient) ListContainerStats(ctx context.Context, in *api.ListContainerStatsRequest,
+	opts ...grpc.CallOption) (*api.ListContainerStatsResponse, error) {
+	rpl, err := c.Client.ListContainerStats(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	more, err := c.secondary.ListContainerStats(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	rpl.Stats = append(rpl.Stats, more.Stats...)
+	return rpl, nil
+}
+
+func (c *routingClient) UpdateRuntimeConfig(ctx context.Context, in *api.UpdateRuntimeConfigRequest,
+	opts ...grpc.CallOption) (*api.UpdateRuntimeConfigResponse, error) {
+	if _, err := c.secondary.UpdateRuntimeConfig(ctx, in, opts...); err != nil {
+		c.Warn("failed to update secondary runtime config: %v", err)
+	}
+	return c.Client.UpdateRuntimeConfig(ctx, in, opts...)
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	api "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+const (
+	// healthRetryMin is the initial delay between health checks while the runtime is down.
+	healthRetryMin = 250 * time.Millisecond
+)
+
+// HealthEvent notifies about the runtime connection going down or coming back.
+type HealthEvent struct {
+	// Healthy tells whether the runtime is reachable.
+	Healthy bool
+	// Since is the time the runtime went down.
+	Since time.Time
+	// Error is the error of the failed health check, for an unhealthy runtime.
+	Error error
+}
+
+// health is the state of our runtime connection health checks.
+type health struct {
+	stop    chan struct{} // channel for stopping health checks
+	healthy bool          // whether the last check succeeded
+	since   time.Time     // time the runtime went down
+}
+
+// startHealthCheck starts periodic health checks of the runtime connection.
+//
+// The runtime is checked by querying its version. Once a check fails, the
+// runtime is considered down, and it is checked again with an exponentially
+// increasing delay, starting from healthRetryMin and bounded by the check
+// interval, each time kicking any pending reconnection attempt to proceed
+// immediately, until the runtime is reachable again.
+func (r *relay) startHealthCheck() {
+	if r.options.HealthCheckInterval <= 0 || !r.client.HasRuntimeService() {
+		return
+	}
+
+	r.health = &health{
+		stop:    make(chan struct{}),
+		healthy: true,
+	}
+	healthMetrics.setUp(true)
+
+	h := r.health
+	interval := r.options.HealthCheckInterval
+	go func() {
+		retry := time.Duration(0)
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case _ = <-h.stop:
+				return
+			case _ = <-timer.C:
+			}
+			if r.checkHealth(h) {
+				retry = 0
+				timer.Reset(interval)
+				continue
+			}
+			r.client.ResetBackoff()
+			retry = nextHealthRetry(retry, interval)
+			timer.Reset(retry)
+		}
+	}()
+}
+
+// nextHealthRetry returns the delay before checking a down runtime again.
+func nextHealthRetry(retry, interval time.Duration) time.Duration {
+	if retry == 0 {
+		return healthRetryMin
+	}
+	if retry *= 2; retry > interval {
+		return interval
+	}
+	return retry
+}
+
+// stopHealthCheck stops health checks of the runtime connection.
+func (r *relay) stopHealthCheck() {
+	if r.health != nil {
+		close(r.health.stop)
+		r.health = nil
+	}
+}
+
+// checkHealth checks the runtime connection, returning whether it is up.
+func (r *relay) checkHealth(h *health) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), r.options.HealthCheckInterval)
+	defer cancel()
+
+	_, err := r.client.Version(ctx, &api.VersionRequest{}, grpc.WaitForReady(false))
+
+	switch {
+	case err != nil && h.healthy:
+		h.healthy = false
+		h.since = time.Now()
+		r.Error("runtime connection lost: %v", err)
+		healthMetrics.disconnected()
+		r.notifyHealth(&HealthEvent{Healthy: false, Since: h.since, Error: err})
+	case err == nil && !h.healthy:
+		h.healthy = true
+		r.Info("runtime connection restored after %v", time.Since(h.since))
+		healthMetrics.setUp(true)
+		r.notifyHealth(&HealthEvent{Healthy: true, Since: h.since})
+	case err != nil:
+		r.Debug("runtime still down: %v", err)
+	}
+
+	return err == nil
+}
+
+// notifyHealth passes a health event to the configured notifier, if any.
+func (r *relay) notifyHealth(e *HealthEvent) {
+	if r.options.HealthNotify != nil {
+		r.options.HealthNotify(e)
+	}
+}
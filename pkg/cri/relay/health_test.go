@@ -0,0 +1,158 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	api "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// fakeClient is a runtime client failing version queries with scripted errors.
+type fakeClient struct {
+	client.Client
+	sync.Mutex
+	errs   []error // errors of subsequent version queries, nil for success
+	checks int     // number of version queries
+	resets int     // number of backoff resets
+}
+
+func (c *fakeClient) HasRuntimeService() bool { return true }
+
+func (c *fakeClient) Version(context.Context, *api.VersionRequest, ...grpc.CallOption) (*api.VersionResponse, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.checks++
+	if len(c.errs) == 0 {
+		return &api.VersionResponse{}, nil
+	}
+	err := c.errs[0]
+	c.errs = c.errs[1:]
+	return &api.VersionResponse{}, err
+}
+
+func (c *fakeClient) ResetBackoff() {
+	c.Lock()
+	defer c.Unlock()
+	c.resets++
+}
+
+// newHealthTestRelay creates a relay with a fake client, passing health events to a channel.
+func newHealthTestRelay(interval time.Duration, errs ...error) (*relay, *fakeClient, chan *HealthEvent) {
+	events := make(chan *HealthEvent, 16)
+	c := &fakeClient{errs: errs}
+	r := &relay{
+		Logger: logger.NewLogger("cri/relay"),
+		options: Options{
+			HealthCheckInterval: interval,
+			HealthNotify:        func(e *HealthEvent) { events <- e },
+		},
+		client: c,
+	}
+	return r, c, events
+}
+
+func TestNextHealthRetry(t *testing.T) {
+	interval := 2 * time.Second
+	retry := time.Duration(0)
+	for _, expected := range []time.Duration{
+		healthRetryMin,
+		2 * healthRetryMin,
+		4 * healthRetryMin,
+		interval,
+		interval,
+	} {
+		if retry = nextHealthRetry(retry, interval); retry != expected {
+			t.Errorf("expected retry after %v, got %v", expected, retry)
+		}
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	down := fmt.Errorf("connection refused")
+	r, _, events := newHealthTestRelay(time.Second, nil, down, down, nil)
+	h := &health{healthy: true}
+
+	for i, tc := range []struct {
+		healthy bool
+		event   *HealthEvent // expected event, if any
+	}{
+		{healthy: true},
+		{healthy: false, event: &HealthEvent{Healthy: false, Error: down}},
+		{healthy: false},
+		{healthy: true, event: &HealthEvent{Healthy: true}},
+	} {
+		if healthy := r.checkHealth(h); healthy != tc.healthy || h.healthy != tc.healthy {
+			t.Errorf("check #%d: expected healthy %v, got %v", i, tc.healthy, healthy)
+		}
+
+		select {
+		case e := <-events:
+			switch {
+			case tc.event == nil:
+				t.Errorf("check #%d: unexpected event %v", i, *e)
+			case e.Healthy != tc.event.Healthy || e.Error != tc.event.Error:
+				t.Errorf("check #%d: expected event %v, got %v", i, *tc.event, *e)
+			case !e.Since.Equal(h.since):
+				t.Errorf("check #%d: expected event since %v, got %v", i, h.since, e.Since)
+			}
+		default:
+			if tc.event != nil {
+				t.Errorf("check #%d: expected event %v, got none", i, *tc.event)
+			}
+		}
+	}
+}
+
+func TestHealthCheckReconnect(t *testing.T) {
+	down := fmt.Errorf("connection refused")
+	r, c, events := newHealthTestRelay(20*time.Millisecond, down, down, down)
+	r.startHealthCheck()
+	defer r.stopHealthCheck()
+
+	for _, healthy := range []bool{false, true} {
+		select {
+		case e := <-events:
+			if e.Healthy != healthy {
+				t.Fatalf("expected event with healthy %v, got %v", healthy, *e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event with healthy %v", healthy)
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.resets != 3 {
+		t.Errorf("expected backoff to be reset on each of 3 failed checks, got %d", c.resets)
+	}
+}
+
+func TestHealthCheckDisabled(t *testing.T) {
+	r, _, _ := newHealthTestRelay(0)
+	r.startHealthCheck()
+	if r.health != nil {
+		t.Errorf("expected no health checks with a zero interval")
+	}
+	r.stopHealthCheck()
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+// healthMetricsCollector exports the health of the runtime connection.
+type healthMetricsCollector struct {
+	up          prometheus.Gauge
+	disconnects prometheus.Counter
+}
+
+// Our runtime connection health metrics collector.
+var healthMetrics = &healthMetricsCollector{
+	up: prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cri_resmgr_runtime_connection_up",
+			Help: "Whether the runtime was reachable at the last health check.",
+		},
+	),
+	disconnects: prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cri_resmgr_runtime_disconnects_total",
+			Help: "Number of times the runtime connection was found to be down.",
+		},
+	),
+}
+
+// setUp sets the runtime connection status.
+func (c *healthMetricsCollector) setUp(up bool) {
+	if up {
+		c.up.Set(1)
+	} else {
+		c.up.Set(0)
+	}
+}
+
+// disconnected records the runtime connection going down.
+func (c *healthMetricsCollector) disconnected() {
+	c.setUp(false)
+	c.disconnects.Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (c *healthMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.up.Describe(ch)
+	c.disconnects.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *healthMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.up.Collect(ch)
+	c.disconnects.Collect(ch)
+}
+
+func init() {
+	err := metrics.RegisterCollector("relay", func() (prometheus.Collector, error) {
+		return healthMetrics, nil
+	})
+	if err != nil {
+		logger.Get("cri/relay").Error("failed to register relay metrics collector: %v", err)
+	}
+}
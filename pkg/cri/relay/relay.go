@@ -17,6 +17,7 @@ package relay
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/intel/cri-resource-manager/pkg/cri/client"
 	"github.com/intel/cri-resource-manager/pkg/cri/server"
@@ -36,6 +37,18 @@ type Options struct {
 	ImageSocket string
 	// RuntimeSocket is the socket path for the (real) CRI runtime services.
 	RuntimeSocket string
+	// SecondaryRuntimeSocket is the socket path for an optional secondary runtime.
+	SecondaryRuntimeSocket string
+	// SecondaryRuntimeHandlers are the runtime handlers to relay to the secondary runtime.
+	SecondaryRuntimeHandlers []string
+	// HealthCheckInterval is the interval of runtime health checks, 0 to disable them.
+	HealthCheckInterval time.Duration
+	// ReconnectMaxDelay bounds the backoff between runtime reconnection attempts.
+	ReconnectMaxDelay time.Duration
+	// WaitForRuntime is how long requests are held while the runtime is down, 0 to fail fast.
+	WaitForRuntime time.Duration
+	// HealthNotify is called with a HealthEvent when the runtime goes down or comes back.
+	HealthNotify func(*HealthEvent)
 }
 
 // Relay is the interface we expose for controlling our CRI relay.
@@ -59,6 +72,7 @@ type relay struct {
 	options    Options       // relay options
 	client     client.Client // relay CRI client
 	server     server.Server // relay CRI server
+	health     *health       // runtime connection health, if checked
 }
 
 // NewRelay creates a new relay instance.
@@ -70,11 +84,17 @@ func NewRelay(options Options) (Relay, error) {
 		options: options,
 	}
 
-	cltopts := client.Options{
-		ImageSocket:   r.options.ImageSocket,
-		RuntimeSocket: r.options.RuntimeSocket,
+	cltopts := client.RoutingOptions{
+		Primary: client.Options{
+			ImageSocket:       r.options.ImageSocket,
+			RuntimeSocket:     r.options.RuntimeSocket,
+			ReconnectMaxDelay: r.options.ReconnectMaxDelay,
+			WaitForReady:      r.options.WaitForRuntime,
+		},
+		SecondaryRuntimeSocket:   r.options.SecondaryRuntimeSocket,
+		SecondaryRuntimeHandlers: r.options.SecondaryRuntimeHandlers,
 	}
-	if r.client, err = client.NewClient(cltopts); err != nil {
+	if r.client, err = client.NewRoutingClient(cltopts); err != nil {
 		return nil, relayError("failed to create relay client: %v", err)
 	}
 
@@ -115,11 +135,14 @@ func (r *relay) Start() error {
 		return relayError("failed to start relay: %v", err)
 	}
 
+	r.startHealthCheck()
+
 	return nil
 }
 
 // Stop stops the relay.
 func (r *relay) Stop() {
+	r.stopHealthCheck()
 	r.client.Close()
 	r.server.Stop()
 }
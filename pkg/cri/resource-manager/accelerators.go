@@ -0,0 +1,277 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+const (
+	// keyAccelerators is the pod annotation key for requesting accelerator work queues.
+	keyAccelerators = "accelerators"
+	// vfioContainerDevice is the VFIO container device needed for using VFIO groups.
+	vfioContainerDevice = "/dev/vfio/vfio"
+)
+
+// acceleratorOptions captures our configurable accelerator work queue assignment.
+type acceleratorOptions struct {
+	// RequireLocal rejects containers if no work queue is local to their memory nodes.
+	RequireLocal bool `json:",omitempty"`
+	// Permissions are the device permissions for assigned work queues.
+	Permissions string `json:",omitempty"`
+}
+
+// workQueueRequest is a request for a single work queue of an accelerator type.
+type workQueueRequest struct {
+	kind system.AcceleratorType
+	mode system.WorkQueueMode
+}
+
+// Our accelerator configuration.
+var accelerators = defaultAcceleratorOptions().(*acceleratorOptions)
+
+// defaultAcceleratorOptions returns a new acceleratorOptions instance, all initialized to defaults.
+func defaultAcceleratorOptions() interface{} {
+	return &acceleratorOptions{Permissions: "rw"}
+}
+
+// workQueues are the accelerator work queues last discovered, by device node.
+var workQueues = map[string]*system.WorkQueue{}
+
+// nodeWorkQueues discovers the accelerator work queues currently present in the node.
+//
+// Work queues are rediscovered for every container requesting any, since
+// accelerators can be hotplugged and their work queues reconfigured any time.
+func (m *resmgr) nodeWorkQueues() []*system.WorkQueue {
+	sys, err := system.DiscoverSystem(system.DiscoverWorkQueues)
+	if err != nil {
+		m.Error("failed to discover accelerator work queues: %v", err)
+		return nil
+	}
+
+	queues := sys.WorkQueues()
+	current := make(map[string]*system.WorkQueue, len(queues))
+	for _, wq := range queues {
+		current[wq.DevPath] = wq
+		if old, ok := workQueues[wq.DevPath]; !ok || *old != *wq {
+			m.Info("discovered accelerator work queue %s", wq)
+		}
+	}
+	for path, wq := range workQueues {
+		if _, ok := current[path]; !ok {
+			m.Info("accelerator work queue %s is gone", wq)
+		}
+	}
+	workQueues = current
+
+	return queues
+}
+
+// workQueueRequests returns the work queues requested for a container.
+//
+// The annotation is a comma-separated list of accelerator types, each with
+// an optional =dedicated or =shared mode, shared by default. For instance
+// "dsa=dedicated,iaa" requests a dedicated DSA and a shared IAA work queue.
+// A type can be listed more than once to request several work queues. The
+// annotation can also be a map of container names to such lists.
+func workQueueRequests(c cache.Container) ([]workQueueRequest, error) {
+	pod, ok := c.GetPod()
+	if !ok {
+		return nil, nil
+	}
+	value, ok := pod.GetResmgrAnnotation(keyAccelerators)
+	if !ok {
+		return nil, nil
+	}
+
+	if strings.Contains(value, ":") {
+		preferences := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+			return nil, resmgrError("invalid %s annotation %q: %v", keyAccelerators, value, err)
+		}
+		if value, ok = preferences[c.GetName()]; !ok {
+			return nil, nil
+		}
+	}
+
+	return parseWorkQueueRequests(value)
+}
+
+// parseWorkQueueRequests parses a comma-separated list of work queue requests.
+func parseWorkQueueRequests(value string) ([]workQueueRequest, error) {
+	requests := []workQueueRequest{}
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		split := strings.SplitN(item, "=", 2)
+		kind, err := system.ParseAcceleratorType(split[0])
+		if err != nil {
+			return nil, resmgrError("invalid work queue request %q: %v", item, err)
+		}
+		mode := system.WorkQueueShared
+		if len(split) == 2 {
+			if mode, err = system.ParseWorkQueueMode(split[1]); err != nil {
+				return nil, resmgrError("invalid work queue request %q: %v", item, err)
+			}
+		}
+		if kind == system.AcceleratorQAT && mode == system.WorkQueueShared {
+			return nil, resmgrError("invalid work queue request %q: QAT only has dedicated queues", item)
+		}
+		requests = append(requests, workQueueRequest{kind: kind, mode: mode})
+	}
+	return requests, nil
+}
+
+// workQueueUsage returns the number of active containers using each work queue.
+func (m *resmgr) workQueueUsage() map[string]int {
+	usage := map[string]int{}
+	for _, c := range m.cache.GetContainers() {
+		switch c.GetState() {
+		case cache.ContainerStateExited, cache.ContainerStateStale:
+			continue
+		}
+		for _, path := range assignedWorkQueues(c) {
+			usage[path]++
+		}
+	}
+	return usage
+}
+
+// assignedWorkQueues returns the device nodes of the work queues assigned to a container.
+func assignedWorkQueues(c cache.Container) []string {
+	value, ok := c.GetTag(cache.TagWorkQueues)
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// assignWorkQueues assigns the work queues requested by a container.
+//
+// Dedicated work queues are assigned only to a single active container, while
+// shared work queues are spread among their users. Work queues local to the
+// memory nodes of the container are preferred. The device nodes of assigned
+// work queues are added to the container and recorded in a container tag, to
+// be exported with the rest of the resource data of the container.
+func (m *resmgr) assignWorkQueues(c cache.Container) error {
+	requests, err := workQueueRequests(c)
+	if err != nil || len(requests) == 0 {
+		return err
+	}
+
+	mems, err := cpuset.Parse(c.GetCpusetMems())
+	if err != nil {
+		mems = cpuset.NewCPUSet()
+	}
+
+	usage := m.workQueueUsage()
+	queues := m.nodeWorkQueues()
+	assigned := []*system.WorkQueue{}
+
+	for _, req := range requests {
+		candidates := []*system.WorkQueue{}
+		for _, wq := range queues {
+			if wq.Type != req.kind || wq.Mode != req.mode {
+				continue
+			}
+			if req.mode == system.WorkQueueDedicated && usage[wq.DevPath] > 0 {
+				continue
+			}
+			if accelerators.RequireLocal && !mems.IsEmpty() && !mems.Contains(int(wq.Node)) {
+				continue
+			}
+			candidates = append(candidates, wq)
+		}
+		if len(candidates) == 0 {
+			return resmgrError("no %s %s work queue available for %s",
+				req.mode, req.kind, c.PrettyName())
+		}
+
+		sort.SliceStable(candidates, func(i, j int) bool {
+			li, lj := mems.Contains(int(candidates[i].Node)), mems.Contains(int(candidates[j].Node))
+			if li != lj {
+				return li
+			}
+			return usage[candidates[i].DevPath] < usage[candidates[j].DevPath]
+		})
+
+		wq := candidates[0]
+		usage[wq.DevPath]++
+		assigned = append(assigned, wq)
+	}
+
+	paths := []string{}
+	for _, wq := range assigned {
+		c.InsertDevice(&cache.Device{
+			Container:   wq.DevPath,
+			Host:        wq.DevPath,
+			Permissions: accelerators.Permissions,
+		})
+		if wq.Type == system.AcceleratorQAT {
+			c.InsertDevice(&cache.Device{
+				Container:   vfioContainerDevice,
+				Host:        vfioContainerDevice,
+				Permissions: accelerators.Permissions,
+			})
+		}
+		paths = append(paths, wq.DevPath)
+		m.Info("%s: assigned accelerator work queue %s", c.PrettyName(), wq)
+	}
+	c.SetTag(cache.TagWorkQueues, strings.Join(paths, ","))
+
+	return nil
+}
+
+// releaseWorkQueues releases the work queues assigned to a container.
+func (m *resmgr) releaseWorkQueues(c cache.Container) {
+	paths := assignedWorkQueues(c)
+	if len(paths) == 0 {
+		return
+	}
+	for _, path := range paths {
+		c.DeleteDevice(path)
+	}
+	c.DeleteTag(cache.TagWorkQueues)
+	m.Info("%s: released accelerator work queues %s", c.PrettyName(), strings.Join(paths, ","))
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.accelerators", acceleratorsHelp, accelerators,
+		defaultAcceleratorOptions)
+}
+
+var acceleratorsHelp = `
+Accelerator work queue assignment.
+
+Containers request DSA, IAA or QAT work queues with the accelerators pod
+annotation, and get the device nodes of the assigned work queues added at
+creation. Dedicated work queues are given to a single container at a time,
+shared ones are spread among their users. Work queues local to the memory
+nodes of the container are preferred. With RequireLocal set, containers are
+rejected if no local work queue is available. For instance:
+
+  RequireLocal: true
+  Permissions: rw
+`
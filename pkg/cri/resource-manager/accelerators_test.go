@@ -0,0 +1,57 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"reflect"
+	"testing"
+
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+func TestParseWorkQueueRequests(t *testing.T) {
+	dsaShared := workQueueRequest{kind: system.AcceleratorDSA, mode: system.WorkQueueShared}
+	dsaDedicated := workQueueRequest{kind: system.AcceleratorDSA, mode: system.WorkQueueDedicated}
+	iaaShared := workQueueRequest{kind: system.AcceleratorIAA, mode: system.WorkQueueShared}
+	qatDedicated := workQueueRequest{kind: system.AcceleratorQAT, mode: system.WorkQueueDedicated}
+
+	tcases := []struct {
+		name     string
+		value    string
+		expected []workQueueRequest
+		err      bool
+	}{
+		{name: "empty", value: "", expected: []workQueueRequest{}},
+		{name: "shared by default", value: "dsa", expected: []workQueueRequest{dsaShared}},
+		{name: "modes", value: "dsa=dedicated,iaa=shared",
+			expected: []workQueueRequest{dsaDedicated, iaaShared}},
+		{name: "repeated, spaces", value: " dsa , DSA ,", expected: []workQueueRequest{dsaShared, dsaShared}},
+		{name: "dedicated QAT", value: "qat=dedicated", expected: []workQueueRequest{qatDedicated}},
+		{name: "shared QAT", value: "qat", err: true},
+		{name: "unknown type", value: "dsa,gpu", err: true},
+		{name: "unknown mode", value: "iaa=exclusive", err: true},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests, err := parseWorkQueueRequests(tc.value)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if err == nil && !reflect.DeepEqual(requests, tc.expected) {
+				t.Errorf("expected requests %v, got %v", tc.expected, requests)
+			}
+		})
+	}
+}
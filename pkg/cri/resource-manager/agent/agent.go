@@ -51,6 +51,33 @@ type Interface interface {
 	RemoveTaints([]core_v1.Taint, time.Duration) error
 
 	FindTaintIndex([]core_v1.Taint, *core_v1.Taint) (int, bool)
+
+	EmitPodEvent(*PodEvent, time.Duration) error
+	UpdateNodeResourceTopology(string, time.Duration) error
+	UpdateNodeStatus(*NodeStatus, time.Duration) error
+}
+
+// NodeStatus describes the status of cri-resource-manager to publish for the node.
+type NodeStatus struct {
+	// Policy is the name of the active policy.
+	Policy string
+	// Mode is the mode of operation: active, degraded or passthrough.
+	Mode string
+	// MemoryTypes are the types of memory present in the node.
+	MemoryTypes []string
+}
+
+// PodEvent describes a Kubernetes Event to emit for a pod.
+type PodEvent struct {
+	// Namespace, Name and UID identify the pod.
+	Namespace string
+	Name      string
+	UID       string
+	// Type is the event type, core_v1.EventTypeNormal or core_v1.EventTypeWarning.
+	Type string
+	// Reason and Message describe the event.
+	Reason  string
+	Message string
 }
 
 // agentInterface implements Interface
@@ -141,6 +168,58 @@ func (a *agentInterface) GetConfig(timeout time.Duration) (*config.RawConfig, er
 	return &config.RawConfig{NodeName: rpl.NodeName, Data: rpl.Config}, nil
 }
 
+func (a *agentInterface) EmitPodEvent(event *PodEvent, timeout time.Duration) error {
+	ctx, cancel, callOpts := prepareCall(timeout)
+	defer cancel()
+
+	req := &agent_v1.EmitEventRequest{
+		Namespace: event.Namespace,
+		PodName:   event.Name,
+		PodUid:    event.UID,
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+	}
+	_, err := a.cli.EmitEvent(ctx, req, callOpts...)
+	if err != nil {
+		return agentError("failed to emit event for pod %s/%s: %v",
+			event.Namespace, event.Name, err)
+	}
+	return nil
+}
+
+// UpdateNodeResourceTopology updates the node resource topology with the given JSON-encoded zones.
+func (a *agentInterface) UpdateNodeResourceTopology(zones string, timeout time.Duration) error {
+	ctx, cancel, callOpts := prepareCall(timeout)
+	defer cancel()
+
+	req := &agent_v1.UpdateNodeResourceTopologyRequest{
+		Zones: zones,
+	}
+	_, err := a.cli.UpdateNodeResourceTopology(ctx, req, callOpts...)
+	if err != nil {
+		return agentError("failed to update node resource topology: %v", err)
+	}
+	return nil
+}
+
+// UpdateNodeStatus publishes the status of cri-resource-manager as node labels and taints.
+func (a *agentInterface) UpdateNodeStatus(status *NodeStatus, timeout time.Duration) error {
+	ctx, cancel, callOpts := prepareCall(timeout)
+	defer cancel()
+
+	req := &agent_v1.UpdateNodeStatusRequest{
+		Policy:      status.Policy,
+		Mode:        status.Mode,
+		MemoryTypes: strings.Join(status.MemoryTypes, ","),
+	}
+	_, err := a.cli.UpdateNodeStatus(ctx, req, callOpts...)
+	if err != nil {
+		return agentError("failed to update node status: %v", err)
+	}
+	return nil
+}
+
 const (
 	// PatchAdd specifies an add operation.
 	PatchAdd string = "add"
@@ -0,0 +1,159 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// kubeletAnnotationPrefix is the prefix of the annotations kubelet reads back from the runtime.
+const kubeletAnnotationPrefix = "io.kubernetes."
+
+// checkAnnotationPatterns checks the annotation drop and digest patterns for errors.
+func checkAnnotationPatterns() error {
+	for _, patterns := range []stringList{opt.DropAnnotations, opt.DigestAnnotations} {
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return resmgrError("invalid annotation pattern %q: %v", pattern, err)
+			}
+			if matchesKubeletAnnotations(pattern) {
+				return resmgrError("annotation pattern %q matches kubelet %s* annotations",
+					pattern, kubeletAnnotationPrefix)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesKubeletAnnotations checks if a pattern can match annotations of kubelet.
+//
+// Kubelet keeps container metadata, for instance the container hash and restart
+// count, in io.kubernetes.* annotations and reads them back from the container
+// status. Kubelet annotation keys have no '/', which no wildcard matches.
+func matchesKubeletAnnotations(pattern string) bool {
+	if strings.Contains(pattern, "/") {
+		return false
+	}
+	i := strings.IndexAny(pattern, `*?[\`)
+	if i < 0 {
+		return strings.HasPrefix(pattern, kubeletAnnotationPrefix)
+	}
+	prefix := pattern[:i]
+	return strings.HasPrefix(prefix, kubeletAnnotationPrefix) ||
+		strings.HasPrefix(kubeletAnnotationPrefix, prefix)
+}
+
+// filterAnnotations returns annotations with dropped ones stripped and rewritten ones digested.
+//
+// The given annotations are never modified. If no annotation needs to be
+// dropped or rewritten, the original annotations are returned. Annotations of
+// kubelet are never dropped or rewritten.
+func filterAnnotations(annotations map[string]string) (map[string]string, bool) {
+	if len(opt.DropAnnotations) == 0 && len(opt.DigestAnnotations) == 0 && opt.MaxAnnotationSize <= 0 {
+		return annotations, false
+	}
+
+	var filtered map[string]string
+	for key, value := range annotations {
+		if strings.HasPrefix(key, kubeletAnnotationPrefix) {
+			continue
+		}
+		drop, digest := matchAnnotation(opt.DropAnnotations, key), matchAnnotation(opt.DigestAnnotations, key)
+		if !drop && !digest && (opt.MaxAnnotationSize <= 0 || len(value) <= opt.MaxAnnotationSize) {
+			continue
+		}
+		if filtered == nil {
+			filtered = make(map[string]string, len(annotations))
+			for k, v := range annotations {
+				filtered[k] = v
+			}
+		}
+		if drop {
+			delete(filtered, key)
+		} else {
+			filtered[key] = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(value)))
+		}
+	}
+
+	if filtered == nil {
+		return annotations, false
+	}
+	return filtered, true
+}
+
+// matchAnnotation checks if an annotation key matches any of the given patterns.
+func matchAnnotation(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPodRequest returns the pod creation request to forward to the runtime.
+//
+// The original request, which is used to populate the cache, is left intact.
+func filterPodRequest(req *criapi.RunPodSandboxRequest) *criapi.RunPodSandboxRequest {
+	if req.Config == nil {
+		return req
+	}
+	annotations, changed := filterAnnotations(req.Config.Annotations)
+	if !changed {
+		return req
+	}
+
+	cfg := *req.Config
+	cfg.Annotations = annotations
+	fwd := *req
+	fwd.Config = &cfg
+
+	return &fwd
+}
+
+// filterContainerRequest returns the container creation request to forward to the runtime.
+//
+// The original request, which is used to populate the cache, is left intact.
+func filterContainerRequest(req *criapi.CreateContainerRequest) *criapi.CreateContainerRequest {
+	fwd := *req
+	changed := false
+
+	if req.Config != nil {
+		if annotations, ok := filterAnnotations(req.Config.Annotations); ok {
+			cfg := *req.Config
+			cfg.Annotations = annotations
+			fwd.Config = &cfg
+			changed = true
+		}
+	}
+	if req.SandboxConfig != nil {
+		if annotations, ok := filterAnnotations(req.SandboxConfig.Annotations); ok {
+			cfg := *req.SandboxConfig
+			cfg.Annotations = annotations
+			fwd.SandboxConfig = &cfg
+			changed = true
+		}
+	}
+
+	if !changed {
+		return req
+	}
+	return &fwd
+}
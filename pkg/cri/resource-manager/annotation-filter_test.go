@@ -0,0 +1,169 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"testing"
+
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// setAnnotationFilter sets the annotation filter options, returning a function to restore them.
+func setAnnotationFilter(drop, digest []string, maxSize int) func() {
+	savedDrop, savedDigest, savedSize := opt.DropAnnotations, opt.DigestAnnotations, opt.MaxAnnotationSize
+	opt.DropAnnotations, opt.DigestAnnotations, opt.MaxAnnotationSize = drop, digest, maxSize
+	return func() {
+		opt.DropAnnotations, opt.DigestAnnotations, opt.MaxAnnotationSize = savedDrop, savedDigest, savedSize
+	}
+}
+
+func TestCheckAnnotationPatterns(t *testing.T) {
+	tcs := []struct {
+		name    string
+		pattern string
+		err     bool
+	}{
+		{name: "literal", pattern: "intel.com/resources"},
+		{name: "wildcard", pattern: "*.example.com/*"},
+		{name: "similar prefix", pattern: "io.k8s.*"},
+		{name: "literal, prefix of kubelet keys", pattern: "io"},
+		{name: "invalid", pattern: "intel.com/[", err: true},
+		{name: "kubelet key", pattern: "io.kubernetes.container.hash", err: true},
+		{name: "kubelet keys", pattern: "io.kubernetes.*", err: true},
+		{name: "prefix of kubelet keys", pattern: "io.*", err: true},
+		{name: "any key", pattern: "*", err: true},
+		{name: "any suffix", pattern: "*.hash", err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setAnnotationFilter(nil, []string{tc.pattern}, 0)()
+			if err := checkAnnotationPatterns(); (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestFilterAnnotations(t *testing.T) {
+	digest := func(value string) string {
+		return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(value)))
+	}
+	annotations := map[string]string{
+		"intel.com/resources":          `{"cpu": "2"}`,
+		"example.com/config":           "config",
+		"example.com/large":            "a large value",
+		"io.kubernetes.container.hash": "0123456789abcdef",
+	}
+
+	tcs := []struct {
+		name     string
+		drop     []string
+		digest   []string
+		maxSize  int
+		changed  bool
+		expected map[string]string
+	}{
+		{
+			name:     "no filtering",
+			expected: annotations,
+		},
+		{
+			name:     "nothing matches",
+			drop:     []string{"other.com/*"},
+			expected: annotations,
+		},
+		{
+			name:    "drop and digest",
+			drop:    []string{"intel.com/*"},
+			digest:  []string{"example.com/config"},
+			changed: true,
+			expected: map[string]string{
+				"example.com/config":           digest("config"),
+				"example.com/large":            "a large value",
+				"io.kubernetes.container.hash": "0123456789abcdef",
+			},
+		},
+		{
+			name:    "digest large values, kubelet ones left intact",
+			maxSize: 10,
+			changed: true,
+			expected: map[string]string{
+				"intel.com/resources":          digest(`{"cpu": "2"}`),
+				"example.com/config":           "config",
+				"example.com/large":            digest("a large value"),
+				"io.kubernetes.container.hash": "0123456789abcdef",
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setAnnotationFilter(tc.drop, tc.digest, tc.maxSize)()
+			filtered, changed := filterAnnotations(annotations)
+			if changed != tc.changed {
+				t.Errorf("expected changed %v, got %v", tc.changed, changed)
+			}
+			if !reflect.DeepEqual(filtered, tc.expected) {
+				t.Errorf("expected annotations %v, got %v", tc.expected, filtered)
+			}
+			if len(annotations) != 4 || annotations["intel.com/resources"] != `{"cpu": "2"}` {
+				t.Errorf("original annotations modified: %v", annotations)
+			}
+		})
+	}
+}
+
+func TestFilterContainerRequest(t *testing.T) {
+	defer setAnnotationFilter([]string{"intel.com/*"}, nil, 0)()
+
+	req := &criapi.CreateContainerRequest{
+		PodSandboxId: "pod",
+		Config: &criapi.ContainerConfig{
+			Annotations: map[string]string{"example.com/config": "config"},
+		},
+		SandboxConfig: &criapi.PodSandboxConfig{
+			Annotations: map[string]string{"intel.com/resources": "{}", "example.com/pod": "pod"},
+		},
+	}
+
+	fwd := filterContainerRequest(req)
+	if fwd == req {
+		t.Fatalf("expected a filtered copy of the request")
+	}
+	if fwd.Config != req.Config {
+		t.Errorf("expected unfiltered container config to be shared")
+	}
+	if _, ok := fwd.SandboxConfig.Annotations["intel.com/resources"]; ok {
+		t.Errorf("expected pod annotation to be dropped, got %v", fwd.SandboxConfig.Annotations)
+	}
+	if fwd.PodSandboxId != "pod" || fwd.SandboxConfig.Annotations["example.com/pod"] != "pod" {
+		t.Errorf("expected the rest of the request to be intact, got %v", fwd)
+	}
+	if _, ok := req.SandboxConfig.Annotations["intel.com/resources"]; !ok {
+		t.Errorf("original request modified: %v", req.SandboxConfig.Annotations)
+	}
+
+	req.SandboxConfig.Annotations = map[string]string{"example.com/pod": "pod"}
+	if fwd = filterContainerRequest(req); fwd != req {
+		t.Errorf("expected the original request when nothing is filtered")
+	}
+
+	pod := &criapi.RunPodSandboxRequest{}
+	if filterPodRequest(pod) != pod {
+		t.Errorf("expected a pod request without config to be forwarded as is")
+	}
+}
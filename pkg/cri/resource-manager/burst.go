@@ -0,0 +1,266 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"os"
+
+	core_v1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// burstOptions captures our configurable CPU burst detection.
+type burstOptions struct {
+	// CPUPressure is the CPU pressure (% of time stalled) above which containers burst, by QoS class.
+	CPUPressure map[core_v1.PodQOSClass]float64 `json:",omitempty"`
+	// ReclaimPressure is the CPU pressure above which Guaranteed containers reclaim lent CPUs.
+	ReclaimPressure float64 `json:",omitempty"`
+	// Hysteresis is the fraction (0 - 1) of the limits below which a container calms down.
+	Hysteresis float64 `json:",omitempty"`
+	// Samples is the number of consecutive samples needed to flag or unflag a container.
+	Samples int `json:",omitempty"`
+}
+
+// burstState is the detection state of a single container.
+type burstState struct {
+	burst   hysteresis // state for bursting
+	reclaim hysteresis // state for reclaiming lent CPUs
+}
+
+// burstStates is the detection state of containers, by cache ID.
+type burstStates map[string]*burstState
+
+// Our CPU burst detection configuration.
+var burst = defaultBurstOptions().(*burstOptions)
+
+// defaultBurstOptions returns a new burstOptions instance, all initialized to defaults.
+func defaultBurstOptions() interface{} {
+	return &burstOptions{
+		CPUPressure:     make(map[core_v1.PodQOSClass]float64),
+		ReclaimPressure: 5,
+		Hysteresis:      0.5,
+		Samples:         2,
+	}
+}
+
+// enabled checks if CPU burst detection is enabled.
+func (o *burstOptions) enabled() bool {
+	for _, limit := range o.CPUPressure {
+		if limit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// burstLimit returns the CPU pressure limit for bursting containers of a QoS class, 0 if none.
+func (o *burstOptions) burstLimit(qos core_v1.PodQOSClass) float64 {
+	return o.CPUPressure[qos]
+}
+
+// reclaimLimit returns the CPU pressure limit for reclaiming lent CPUs by a QoS class, 0 if none.
+func (o *burstOptions) reclaimLimit(qos core_v1.PodQOSClass) float64 {
+	if qos != core_v1.PodQOSGuaranteed {
+		return 0
+	}
+	return o.ReclaimPressure
+}
+
+// processBurst processes the CPU pressure of containers, flagging bursting ones.
+//
+// A container is flagged to burst once the average 10 second CPU pressure of
+// its cgroup stays above the limit configured for its QoS class for the configured
+// number of consecutive samples. Similarly, a Guaranteed container is flagged to
+// reclaim its CPUs once its CPU pressure stays above the reclaim limit. Flags are
+// cleared once the pressure stays below the limit scaled down by the hysteresis
+// factor for the same number of samples. Flagged containers are tagged with
+// cache.TagCPUBurst and cache.TagCPUReclaim, and rebalancing is requested for
+// policies to lend idle exclusive CPUs to bursting shared containers, or to
+// clamp those back to their shared CPUs when the owners need them.
+func (m *resmgr) processBurst() {
+	if !burst.enabled() {
+		m.burst = nil
+		changed := false
+		for _, c := range m.cache.GetContainers() {
+			if _, ok := c.DeleteTag(cache.TagCPUBurst); ok {
+				changed = true
+			}
+			if _, ok := c.DeleteTag(cache.TagCPUReclaim); ok {
+				changed = true
+			}
+		}
+		if changed {
+			m.requestRebalance("CPU burst disabled")
+		}
+		return
+	}
+
+	if m.burst == nil {
+		m.burst = make(burstStates)
+	}
+
+	changed := false
+	current := map[string]struct{}{}
+	for _, c := range m.cache.GetContainers() {
+		if c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		qos := c.GetQOSClass()
+		burstLimit, reclaimLimit := burst.burstLimit(qos), burst.reclaimLimit(qos)
+		if burstLimit <= 0 && reclaimLimit <= 0 {
+			continue
+		}
+		pressure, ok := m.cpuPressure(c)
+		if !ok {
+			continue
+		}
+
+		id := c.GetCacheID()
+		current[id] = struct{}{}
+		s, ok := m.burst[id]
+		if !ok {
+			s = &burstState{}
+			m.burst[id] = s
+		}
+
+		if m.updateBurstFlag(c, cache.TagCPUBurst, &s.burst, pressure, burstLimit) {
+			changed = true
+		}
+		if m.updateBurstFlag(c, cache.TagCPUReclaim, &s.reclaim, pressure, reclaimLimit) {
+			changed = true
+		}
+	}
+
+	for id := range m.burst {
+		if _, ok := current[id]; !ok {
+			delete(m.burst, id)
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	m.cache.Save()
+	m.requestRebalance("CPU burst")
+}
+
+// updateBurstFlag updates a burst detection flag of a container, returning whether it changed.
+func (m *resmgr) updateBurstFlag(c cache.Container, tag string, h *hysteresis,
+	pressure, limit float64) bool {
+	_, flagged := c.GetTag(tag)
+
+	if limit <= 0 {
+		*h = hysteresis{}
+		if flagged {
+			c.DeleteTag(tag)
+			return true
+		}
+		return false
+	}
+
+	if !h.update(flagged, pressure > limit, pressure <= burst.Hysteresis*limit, burst.Samples) {
+		return false
+	}
+
+	if !flagged {
+		evtlog.Info("container %s CPU pressure %s exceeds limit %s, tagging %s",
+			c.PrettyName(), formatPressure(pressure), formatPressure(limit), tag)
+		c.SetTag(tag, formatPressure(pressure))
+	} else {
+		evtlog.Info("container %s CPU pressure %s calmed down, untagging %s",
+			c.PrettyName(), formatPressure(pressure), tag)
+		c.DeleteTag(tag)
+	}
+
+	return true
+}
+
+// cpuPressure returns the average 10 second CPU pressure of a container.
+func (m *resmgr) cpuPressure(c cache.Container) (float64, bool) {
+	dir, err := cgroups.ResolveCgroupPath(cgroupRoot, c.GetID())
+	if err != nil {
+		return 0, false
+	}
+	psi, err := cgroups.GetPressure(dir, "cpu")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			m.Debug("failed to read CPU pressure of %s: %v", c.PrettyName(), err)
+		}
+		return 0, false
+	}
+	return psi.Some.Avg10, true
+}
+
+// validateBurstOptions checks the CPU burst detection configuration.
+func validateBurstOptions(cfg interface{}) error {
+	o, ok := cfg.(*burstOptions)
+	if !ok {
+		return resmgrError("invalid CPU burst configuration %T", cfg)
+	}
+	for qos, limit := range o.CPUPressure {
+		switch qos {
+		case core_v1.PodQOSGuaranteed, core_v1.PodQOSBurstable, core_v1.PodQOSBestEffort:
+		default:
+			return resmgrError("invalid CPU burst QoS class %q", qos)
+		}
+		if limit < 0 || limit > 100 {
+			return resmgrError("invalid %s CPU burst pressure limit %f, expecting 0 - 100",
+				qos, limit)
+		}
+	}
+	if o.ReclaimPressure < 0 || o.ReclaimPressure > 100 {
+		return resmgrError("invalid CPU reclaim pressure limit %f, expecting 0 - 100",
+			o.ReclaimPressure)
+	}
+	if o.Hysteresis <= 0 || o.Hysteresis > 1 {
+		return resmgrError("invalid CPU burst hysteresis %f, expecting 0 < h <= 1", o.Hysteresis)
+	}
+	if o.Samples < 1 {
+		return resmgrError("invalid CPU burst sample count %d, expecting >= 1", o.Samples)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.burst", burstHelp, burst, defaultBurstOptions,
+		config.WithValidator(validateBurstOptions))
+}
+
+var burstHelp = `
+CPU bursting of shared containers onto idle exclusive CPUs.
+
+The average 10 second cpu pressure (the percentage of time some tasks were
+stalled waiting for a CPU) of containers is read from their cgroups (v2) at
+every metrics poll. Containers of the QoS classes listed in CPUPressure with
+a pressure above the limit of their class for Samples consecutive polls are
+tagged to burst. Policies let bursting containers without exclusive CPUs
+use the idle exclusive CPUs of other containers in their pool, those whose
+owners are using at most half of them, by expanding their cpuset. Guaranteed containers with a pressure above ReclaimPressure are tagged
+to reclaim their CPUs, and bursting containers are clamped back off them.
+Tags are removed once the pressure stays below the limits scaled by Hysteresis
+for the same number of polls. Changes trigger rebalancing. For instance:
+
+  CPUPressure:
+    Burstable: 20
+    BestEffort: 40
+  ReclaimPressure: 5
+  Hysteresis: 0.5
+  Samples: 2
+`
@@ -0,0 +1,59 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+func TestUpdateBurstFlag(t *testing.T) {
+	saved := *burst
+	defer func() { *burst = saved }()
+	burst.Hysteresis = 0.5
+	burst.Samples = 2
+
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+	c := createInstance(t, m, cfg, 0)
+	h := &hysteresis{}
+
+	for i, tc := range []struct {
+		pressure float64
+		limit    float64
+		changed  bool
+		flagged  bool
+	}{
+		{pressure: 30, limit: 20},
+		{pressure: 10, limit: 20},
+		{pressure: 30, limit: 20},
+		{pressure: 30, limit: 20, changed: true, flagged: true},
+		{pressure: 15, limit: 20, flagged: true},
+		{pressure: 5, limit: 20, flagged: true},
+		{pressure: 5, limit: 20, changed: true},
+		{pressure: 30, limit: 20},
+		{pressure: 30, limit: 20, changed: true, flagged: true},
+		{pressure: 30, limit: 0, changed: true},
+		{pressure: 30, limit: 0},
+	} {
+		changed := m.updateBurstFlag(c, cache.TagCPUBurst, h, tc.pressure, tc.limit)
+		_, flagged := c.GetTag(cache.TagCPUBurst)
+		if changed != tc.changed || flagged != tc.flagged {
+			t.Errorf("sample #%d: expected changed %v, flagged %v, got %v, %v",
+				i, tc.changed, tc.flagged, changed, flagged)
+		}
+	}
+}
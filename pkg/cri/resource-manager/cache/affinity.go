@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/ghodss/yaml"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
+	"sort"
 	"strings"
 )
 
@@ -35,10 +36,17 @@ type simpleAffinity map[string][]string
 type podContainerAffinity map[string][]*Affinity
 
 // Affinity specifies a single container affinity.
+//
+// A container matches the affinity if it is in scope, in one of the listed
+// namespaces (if any), and matches the match expression, all the match labels,
+// and all the match expressions (if any) of the affinity.
 type Affinity struct {
-	Scope  *Expression `json:"scope,omitempty"`  // scope for evaluating this affinity
-	Match  *Expression `json:"match"`            // affinity expression
-	Weight int32       `json:"weight,omitempty"` // (optional) weight for this affinity
+	Scope            *Expression       `json:"scope,omitempty"`            // scope for evaluating this affinity
+	Match            *Expression       `json:"match,omitempty"`            // affinity expression
+	MatchLabels      map[string]string `json:"matchLabels,omitempty"`      // keys with required values
+	MatchExpressions []*Expression     `json:"matchExpressions,omitempty"` // further required expressions
+	Namespaces       []string          `json:"namespaces,omitempty"`       // namespaces to match
+	Weight           int32             `json:"weight,omitempty"`           // (optional) weight for this affinity
 }
 
 // Expression is used to describe a criteria to select objects within a domain.
@@ -80,13 +88,60 @@ func (a *Affinity) Validate() error {
 		return cacheError("invalid affinity scope: %v", err)
 	}
 
-	if err := a.Match.Validate(); err != nil {
-		return cacheError("invalid affinity match: %v", err)
+	if a.Match == nil && len(a.MatchLabels) == 0 && len(a.MatchExpressions) == 0 {
+		return cacheError("invalid affinity, no match criteria given")
+	}
+
+	if a.Match != nil {
+		if err := a.Match.Validate(); err != nil {
+			return cacheError("invalid affinity match: %v", err)
+		}
+	}
+
+	for _, e := range a.MatchExpressions {
+		if err := e.Validate(); err != nil {
+			return cacheError("invalid affinity match expression: %v", err)
+		}
 	}
 
 	return nil
 }
 
+// Matches checks if the given container matches the affinity, ignoring its scope.
+func (a *Affinity) Matches(c Container) bool {
+	if len(a.Namespaces) > 0 {
+		found := false
+		for _, ns := range a.Namespaces {
+			if ns == c.GetNamespace() || ns == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if a.Match != nil && !a.Match.Evaluate(c) {
+		return false
+	}
+
+	for key, value := range a.MatchLabels {
+		e := &Expression{Key: key, Op: Equals, Values: []string{value}}
+		if !e.Evaluate(c) {
+			return false
+		}
+	}
+
+	for _, e := range a.MatchExpressions {
+		if !e.Evaluate(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Validate checks the expression for (obvious) invalidity.
 func (e *Expression) Validate() error {
 	if e == nil {
@@ -111,7 +166,7 @@ func (e *Expression) Validate() error {
 func (cch *cache) EvaluateAffinity(a *Affinity) map[string]int32 {
 	results := make(map[string]int32)
 	for _, c := range cch.FilterScope(a.Scope) {
-		if a.Match.Evaluate(c) {
+		if a.Matches(c) {
 			id := c.GetCacheID()
 			results[id] += a.Weight
 		}
@@ -119,6 +174,124 @@ func (cch *cache) EvaluateAffinity(a *Affinity) map[string]int32 {
 	return results
 }
 
+// EvaluateContainerAffinity evaluates the effective affinity of a container.
+//
+// The result is the sum of the weights of all affinities of the container, by
+// the matching other containers. If affinities for another container conflict,
+// IOW both pull and push it, the sum of the weights decides.
+//
+// Additionally, affinity cycles with conflicting constraints are detected. If
+// the container has affinity towards two containers which in turn have affinity
+// to each other, the signs of these three affinities must agree: a container
+// cannot be both pulled close to two containers which push each other apart.
+// For such cycles the weakest affinity of the container is dropped, preferring
+// to keep the affinity for the container with the lower cache ID in a tie.
+func (cch *cache) EvaluateContainerAffinity(c Container) map[string]int32 {
+	id := c.GetCacheID()
+	pull := make(map[string]int32)
+	push := make(map[string]int32)
+
+	for _, a := range c.GetAffinity() {
+		for oid, w := range cch.EvaluateAffinity(a) {
+			switch {
+			case oid == id:
+			case w > 0:
+				pull[oid] += w
+			case w < 0:
+				push[oid] += w
+			}
+		}
+	}
+
+	result := make(map[string]int32)
+	for oid, w := range pull {
+		result[oid] += w
+	}
+	for oid, w := range push {
+		if p, ok := pull[oid]; ok {
+			cch.Warn("%s: conflicting affinity (%d) and anti-affinity (%d) for %s, using %d",
+				c.PrettyName(), p, w, oid, p+w)
+		}
+		result[oid] += w
+	}
+
+	ids := make([]string, 0, len(result))
+	for oid, w := range result {
+		if w == 0 {
+			delete(result, oid)
+			continue
+		}
+		ids = append(ids, oid)
+	}
+	sort.Strings(ids)
+
+	for i, xid := range ids {
+		for _, yid := range ids[i+1:] {
+			wx, okx := result[xid]
+			wy, oky := result[yid]
+			if !okx || !oky {
+				continue
+			}
+
+			x, _ := cch.LookupContainer(xid)
+			y, _ := cch.LookupContainer(yid)
+			if x == nil || y == nil {
+				continue
+			}
+
+			wxy := cch.pairAffinity(x, y) + cch.pairAffinity(y, x)
+			if wxy == 0 || sign(wx)*sign(wy)*sign(wxy) > 0 {
+				continue
+			}
+
+			drop, weakest := yid, abs(wy)
+			if abs(wx) < abs(wy) {
+				drop, weakest = xid, abs(wx)
+			}
+			if abs(wxy) < weakest {
+				continue
+			}
+
+			cch.Warn("%s: conflicting affinity cycle with %s (%d), %s (%d), and between them (%d), "+
+				"dropping affinity for %s", c.PrettyName(), x.PrettyName(), wx, y.PrettyName(), wy,
+				wxy, drop)
+			delete(result, drop)
+		}
+	}
+
+	return result
+}
+
+// pairAffinity returns the sum of the weights of the affinities of c which match o.
+func (cch *cache) pairAffinity(c, o Container) int32 {
+	w := int32(0)
+	for _, a := range c.GetAffinity() {
+		if a.Scope.Evaluate(o) && a.Matches(o) {
+			w += a.Weight
+		}
+	}
+	return w
+}
+
+// sign returns the sign of an affinity weight.
+func sign(w int32) int32 {
+	switch {
+	case w < 0:
+		return -1
+	case w > 0:
+		return 1
+	}
+	return 0
+}
+
+// abs returns the absolute value of an affinity weight.
+func abs(w int32) int32 {
+	if w < 0 {
+		return -w
+	}
+	return w
+}
+
 // FilterScope returns the containers selected by the scope expression.
 func (cch *cache) FilterScope(scope *Expression) []Container {
 	cch.Debug("calculating scope %s", scope.String())
@@ -257,8 +430,26 @@ func (a *Affinity) String() string {
 	if a.Weight < 0 {
 		kind = "anti-"
 	}
+	match := []string{}
+	if a.Match != nil {
+		match = append(match, a.Match.String())
+	}
+	keys := make([]string, 0, len(a.MatchLabels))
+	for key := range a.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		match = append(match, fmt.Sprintf("<%s %s %s>", key, Equals, a.MatchLabels[key]))
+	}
+	for _, e := range a.MatchExpressions {
+		match = append(match, e.String())
+	}
+	if len(a.Namespaces) > 0 {
+		match = append(match, "<namespace "+string(In)+" "+strings.Join(a.Namespaces, ",")+">")
+	}
 	return fmt.Sprintf("<%saffinity: scope %s %s => %d>",
-		kind, a.Scope.String(), a.Match.String(), a.Weight)
+		kind, a.Scope.String(), strings.Join(match, " && "), a.Weight)
 }
 
 // String returns the expression as a string.
@@ -306,7 +497,11 @@ func (pca *podContainerAffinity) parseFull(pod *pod, value string, weight int32)
 
 		for _, a := range pa {
 			if a.Scope == nil {
-				a.Scope = podScope
+				if len(a.Namespaces) > 0 {
+					a.Scope = &Expression{Op: AlwaysTrue}
+				} else {
+					a.Scope = podScope
+				}
 			}
 			if a.Weight == 0 {
 				a.Weight = weight
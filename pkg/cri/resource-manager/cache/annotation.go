@@ -0,0 +1,122 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	resapi "k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// DecodeAnnotation decodes an annotation value into the object pointed to by objPtr.
+//
+// Besides the basic string, boolean and integer types, it natively decodes
+// time.Duration ("1m30s"), resource.Quantity ("1.5Gi"), cpuset.CPUSet ("0-3,8"),
+// and []string values, given either as a JSON array or as a comma-separated list.
+// Any other types are decoded as JSON.
+func DecodeAnnotation(data []byte, objPtr interface{}) error {
+	return decodeAnnotation(data, objPtr, false)
+}
+
+// DecodeAnnotationStrict decodes an annotation value like DecodeAnnotation, but
+// rejects JSON values with fields unknown to the target object and trailing data.
+func DecodeAnnotationStrict(data []byte, objPtr interface{}) error {
+	return decodeAnnotation(data, objPtr, true)
+}
+
+// decodeAnnotation decodes an annotation value, optionally in strict mode.
+func decodeAnnotation(data []byte, objPtr interface{}, strict bool) error {
+	var err error
+
+	value := string(data)
+	switch ptr := objPtr.(type) {
+	case *string:
+		*ptr = value
+	case *bool:
+		*ptr, err = strconv.ParseBool(value)
+	case *int:
+		var i int64
+		i, err = strconv.ParseInt(value, 0, 0)
+		*ptr = int(i)
+	case *uint:
+		var i uint64
+		i, err = strconv.ParseUint(value, 0, 0)
+		*ptr = uint(i)
+	case *int64:
+		*ptr, err = strconv.ParseInt(value, 0, 64)
+	case *uint64:
+		*ptr, err = strconv.ParseUint(value, 0, 64)
+	case *time.Duration:
+		*ptr, err = time.ParseDuration(strings.TrimSpace(value))
+	case *resapi.Quantity:
+		*ptr, err = resapi.ParseQuantity(strings.TrimSpace(value))
+	case *cpuset.CPUSet:
+		*ptr, err = cpuset.Parse(strings.TrimSpace(value))
+	case *[]string:
+		*ptr, err = decodeStringSlice(value, strict)
+	default:
+		err = decodeJSON(data, objPtr, strict)
+	}
+
+	if err != nil {
+		return cacheError("invalid %T annotation value %q: %v", objPtr, value, err)
+	}
+
+	return nil
+}
+
+// decodeStringSlice decodes a JSON array or a comma-separated list of strings.
+func decodeStringSlice(value string, strict bool) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") {
+		slice := []string{}
+		if err := decodeJSON([]byte(value), &slice, strict); err != nil {
+			return nil, err
+		}
+		return slice, nil
+	}
+
+	slice := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			slice = append(slice, item)
+		}
+	}
+	return slice, nil
+}
+
+// decodeJSON unmarshals JSON data, rejecting unknown fields in strict mode.
+func decodeJSON(data []byte, objPtr interface{}, strict bool) error {
+	if !strict {
+		return json.Unmarshal(data, objPtr)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(objPtr); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("trailing data after JSON value")
+	}
+	return nil
+}
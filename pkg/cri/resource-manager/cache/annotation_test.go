@@ -0,0 +1,143 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	resapi "k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+func TestDecodeAnnotation(t *testing.T) {
+	type object struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+
+	var (
+		duration time.Duration
+		quantity resapi.Quantity
+		cpus     cpuset.CPUSet
+		strings  []string
+		obj      object
+	)
+
+	cases := []struct {
+		name     string
+		value    string
+		objPtr   interface{}
+		strict   bool
+		expected interface{}
+		fail     bool
+	}{
+		{
+			name:     "duration",
+			value:    "1m30s",
+			objPtr:   &duration,
+			expected: 90 * time.Second,
+		},
+		{
+			name:   "invalid duration",
+			value:  "90",
+			objPtr: &duration,
+			fail:   true,
+		},
+		{
+			name:     "quantity",
+			value:    "1536Mi",
+			objPtr:   &quantity,
+			expected: int64(1536 * 1024 * 1024),
+		},
+		{
+			name:     "cpuset",
+			value:    "0-3,8",
+			objPtr:   &cpus,
+			expected: "0-3,8",
+		},
+		{
+			name:     "comma-separated strings",
+			value:    "a, b,,c",
+			objPtr:   &strings,
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "JSON strings",
+			value:    `["a", "b,c"]`,
+			objPtr:   &strings,
+			expected: []string{"a", "b,c"},
+		},
+		{
+			name:     "JSON object with unknown field",
+			value:    `{"name": "foo", "count": 1, "color": "red"}`,
+			objPtr:   &obj,
+			expected: object{Name: "foo", Count: 1},
+		},
+		{
+			name:   "strict JSON object with unknown field",
+			value:  `{"name": "foo", "count": 1, "color": "red"}`,
+			objPtr: &obj,
+			strict: true,
+			fail:   true,
+		},
+		{
+			name:   "strict JSON object with trailing data",
+			value:  `{"name": "foo"} {"count": 1}`,
+			objPtr: &obj,
+			strict: true,
+			fail:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			if tc.strict {
+				err = DecodeAnnotationStrict([]byte(tc.value), tc.objPtr)
+			} else {
+				err = DecodeAnnotation([]byte(tc.value), tc.objPtr)
+			}
+			if tc.fail {
+				if err == nil {
+					t.Errorf("expected decoding %q to fail", tc.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to decode %q: %v", tc.value, err)
+			}
+
+			var decoded interface{}
+			switch ptr := tc.objPtr.(type) {
+			case *time.Duration:
+				decoded = *ptr
+			case *resapi.Quantity:
+				decoded = ptr.Value()
+			case *cpuset.CPUSet:
+				decoded = ptr.String()
+			case *[]string:
+				decoded = *ptr
+			case *object:
+				decoded = *ptr
+			}
+			if diff := cmp.Diff(tc.expected, decoded); diff != "" {
+				t.Errorf("unexpected result for %q (-expected, +got):\n%s", tc.value, diff)
+			}
+		})
+	}
+}
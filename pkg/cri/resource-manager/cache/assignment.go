@@ -0,0 +1,172 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/topology"
+)
+
+const (
+	// ProviderCheckpoint marks topology hints imported from a checkpointed assignment.
+	ProviderCheckpoint = "checkpoint"
+	// keyResourceAssignment is the pod annotation for checkpointed container assignments.
+	keyResourceAssignment = "resource-assignment"
+)
+
+// ResourceAssignment is the portable resource assignment of a container.
+//
+// Assignments are meant to be checkpointed together with containers which
+// are migrated between nodes, for instance using CRIU. They can be imported
+// on the target node to ask for equivalent placement of the container.
+type ResourceAssignment struct {
+	// Container is the name of the container the assignment was exported for.
+	Container string `json:"container,omitempty"`
+	// CpusetCpus is the cpuset.cpus of the container.
+	CpusetCpus string `json:"cpusetCpus,omitempty"`
+	// CpusetMems is the cpuset.mems of the container.
+	CpusetMems string `json:"cpusetMems,omitempty"`
+	// CPUShares is the CFS CPU shares of the container.
+	CPUShares int64 `json:"cpuShares,omitempty"`
+	// CPUQuota is the CFS CPU quota of the container.
+	CPUQuota int64 `json:"cpuQuota,omitempty"`
+	// CPUPeriod is the CFS CPU period of the container.
+	CPUPeriod int64 `json:"cpuPeriod,omitempty"`
+	// MemoryLimit is the memory limit of the container in bytes.
+	MemoryLimit int64 `json:"memoryLimit,omitempty"`
+	// RDTClass is the RDT class of the container.
+	RDTClass string `json:"rdtClass,omitempty"`
+	// BlockIOClass is the block I/O class of the container.
+	BlockIOClass string `json:"blockioClass,omitempty"`
+	// NetworkClass is the network class of the container.
+	NetworkClass string `json:"networkClass,omitempty"`
+}
+
+// ExportResourceAssignment serializes the resource assignment of the container.
+func (c *container) ExportResourceAssignment() ([]byte, error) {
+	ra := &ResourceAssignment{
+		Container:    c.Name,
+		CpusetCpus:   c.GetCpusetCpus(),
+		CpusetMems:   c.GetCpusetMems(),
+		CPUShares:    c.GetCPUShares(),
+		CPUQuota:     c.GetCPUQuota(),
+		CPUPeriod:    c.GetCPUPeriod(),
+		MemoryLimit:  c.GetMemoryLimit(),
+		RDTClass:     c.GetRDTClass(),
+		BlockIOClass: c.GetBlockIOClass(),
+		NetworkClass: c.GetNetworkClass(),
+	}
+
+	data, err := json.Marshal(ra)
+	if err != nil {
+		return nil, cacheError("%s: failed to export resource assignment: %v",
+			c.PrettyName(), err)
+	}
+
+	return data, nil
+}
+
+// ImportResourceAssignment imports a serialized resource assignment for the container.
+//
+// The cpuset of the assignment is attached to the container as a topology hint
+// and its RDT, block I/O and network classes are set as the preferred classes. Policies
+// still make the final decision about the actual placement of the container.
+// Malformed assignments are rejected, and so are stale ones: assignments
+// exported for another container, or imported once the container has been
+// created, when its placement has already been decided.
+func (c *container) ImportResourceAssignment(data []byte) error {
+	ra := &ResourceAssignment{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(ra); err != nil {
+		return cacheError("%s: failed to import resource assignment: %v",
+			c.PrettyName(), err)
+	}
+	if err := ra.validate(); err != nil {
+		return cacheError("%s: invalid resource assignment: %v", c.PrettyName(), err)
+	}
+	if ra.Container != "" && ra.Container != c.Name {
+		return cacheError("%s: stale resource assignment, exported for container %s",
+			c.PrettyName(), ra.Container)
+	}
+	if c.State != ContainerStateCreating {
+		return cacheError("%s: stale resource assignment, container already created",
+			c.PrettyName())
+	}
+
+	if ra.CpusetCpus != "" || ra.CpusetMems != "" {
+		c.TopologyHints = topology.MergeTopologyHints(c.TopologyHints, topology.Hints{
+			ProviderCheckpoint: topology.Hint{
+				Provider: ProviderCheckpoint,
+				CPUs:     ra.CpusetCpus,
+				NUMAs:    ra.CpusetMems,
+			},
+		})
+	}
+	if ra.RDTClass != "" {
+		c.SetRDTClass(ra.RDTClass)
+	}
+	if ra.BlockIOClass != "" {
+		c.SetBlockIOClass(ra.BlockIOClass)
+	}
+	if ra.NetworkClass != "" {
+		c.SetNetworkClass(ra.NetworkClass)
+	}
+
+	c.cache.Info("%s: imported resource assignment %s", c.PrettyName(), string(data))
+
+	return nil
+}
+
+// validate checks that the assignment is well-formed.
+func (ra *ResourceAssignment) validate() error {
+	if _, err := cpuset.Parse(ra.CpusetCpus); err != nil {
+		return fmt.Errorf("invalid cpuset.cpus %q: %v", ra.CpusetCpus, err)
+	}
+	if _, err := cpuset.Parse(ra.CpusetMems); err != nil {
+		return fmt.Errorf("invalid cpuset.mems %q: %v", ra.CpusetMems, err)
+	}
+	if ra.CPUShares < 0 || ra.CPUQuota < -1 || ra.CPUPeriod < 0 || ra.MemoryLimit < 0 {
+		return fmt.Errorf("negative CPU or memory resources")
+	}
+	return nil
+}
+
+// importAnnotatedAssignment imports any checkpointed assignment annotated for the container.
+func (c *container) importAnnotatedAssignment() {
+	pod, ok := c.GetPod()
+	if !ok {
+		return
+	}
+
+	assignments := map[string]*ResourceAssignment{}
+	ok, err := pod.GetResmgrAnnotationObject(keyResourceAssignment, &assignments, nil)
+	if err != nil {
+		c.cache.Error("%s: invalid resource assignment annotation: %v", c.PrettyName(), err)
+		return
+	}
+	if !ok || assignments[c.Name] == nil {
+		return
+	}
+
+	data, _ := json.Marshal(assignments[c.Name])
+	if err := c.ImportResourceAssignment(data); err != nil {
+		c.cache.Error("%v", err)
+	}
+}
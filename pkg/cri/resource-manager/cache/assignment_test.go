@@ -0,0 +1,160 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func TestResourceAssignmentRoundTrip(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	srcPod := &fakePod{name: "src-pod"}
+	if _, err := createFakePod(cch, srcPod); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+	src, err := createFakeContainer(cch, &fakeContainer{
+		fakePod: srcPod,
+		name:    "ctr",
+		resources: cri.LinuxContainerResources{
+			CpuShares:          1024,
+			CpuQuota:           200000,
+			CpuPeriod:          100000,
+			MemoryLimitInBytes: 1 << 30,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	src.SetCpusetCpus("2-3")
+	src.SetCpusetMems("0")
+	src.SetRDTClass("gold")
+	src.SetBlockIOClass("throttled")
+	src.SetNetworkClass("bulk")
+
+	data, err := src.ExportResourceAssignment()
+	if err != nil {
+		t.Fatalf("failed to export resource assignment: %v", err)
+	}
+
+	dstPod := &fakePod{name: "dst-pod"}
+	if _, err := createFakePod(cch, dstPod); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+	dst, err := createFakeContainer(cch, &fakeContainer{fakePod: dstPod, name: "ctr"})
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	if err := dst.ImportResourceAssignment(data); err != nil {
+		t.Fatalf("failed to import resource assignment: %v", err)
+	}
+
+	hint, ok := dst.GetTopologyHints()[ProviderCheckpoint]
+	if !ok {
+		t.Fatalf("expected a %s topology hint", ProviderCheckpoint)
+	}
+	if hint.CPUs != "2-3" || hint.NUMAs != "0" {
+		t.Errorf("expected hint with CPUs 2-3 and NUMAs 0, got %+v", hint)
+	}
+	if class := dst.GetRDTClass(); class != "gold" {
+		t.Errorf("expected RDT class gold, got %q", class)
+	}
+	if class := dst.GetBlockIOClass(); class != "throttled" {
+		t.Errorf("expected block I/O class throttled, got %q", class)
+	}
+	if class := dst.GetNetworkClass(); class != "bulk" {
+		t.Errorf("expected network class bulk, got %q", class)
+	}
+
+	reexported, err := dst.ExportResourceAssignment()
+	if err != nil {
+		t.Fatalf("failed to re-export resource assignment: %v", err)
+	}
+	if err := dst.ImportResourceAssignment(reexported); err != nil {
+		t.Errorf("failed to re-import resource assignment: %v", err)
+	}
+}
+
+func TestResourceAssignmentRejected(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+
+	tcases := []struct {
+		name    string
+		data    string
+		running bool
+	}{
+		{
+			name: "invalid JSON",
+			data: `{"cpusetCpus": "0-1"`,
+		},
+		{
+			name: "unknown field",
+			data: `{"cpusetCpus": "0-1", "gpus": "0"}`,
+		},
+		{
+			name: "malformed cpuset",
+			data: `{"cpusetCpus": "1-x"}`,
+		},
+		{
+			name: "malformed memory nodes",
+			data: `{"cpusetMems": "0,,"}`,
+		},
+		{
+			name: "negative memory limit",
+			data: `{"memoryLimit": -1}`,
+		},
+		{
+			name: "exported for another container",
+			data: `{"container": "other", "cpusetCpus": "0-1"}`,
+		},
+		{
+			name:    "container already running",
+			data:    `{"container": "ctr", "cpusetCpus": "0-1"}`,
+			running: true,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: "ctr"})
+			if err != nil {
+				t.Fatalf("failed to create container: %v", err)
+			}
+			if tc.running {
+				c.UpdateState(ContainerStateRunning)
+			}
+			if err := c.ImportResourceAssignment([]byte(tc.data)); err == nil {
+				t.Errorf("expected assignment %s to be rejected", tc.data)
+			}
+			if _, ok := c.GetTopologyHints()[ProviderCheckpoint]; ok {
+				t.Errorf("unexpected %s topology hint from rejected assignment", ProviderCheckpoint)
+			}
+		})
+	}
+}
@@ -23,11 +23,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/config"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 	"github.com/intel/cri-resource-manager/pkg/topology"
@@ -40,9 +41,43 @@ const (
 	RDT = "rdt"
 	// BlockIO marks changes that can be applied by the BlockIO controller.
 	BlockIO = "blockio"
+	// Network marks changes that can be applied by the network controller.
+	Network = "network"
+	// Devices marks changes that can be applied by the devices controller.
+	Devices = "devices"
 
 	// TagAVX512 tags containers that use AVX512 instructions.
 	TagAVX512 = "AVX512"
+	// TagRDTLLCOccupancy tags containers with the LLC occupancy of their RDT class.
+	TagRDTLLCOccupancy = "rdt/llc-occupancy"
+	// TagRDTMemBandwidth tags containers with the memory bandwidth of their RDT class.
+	TagRDTMemBandwidth = "rdt/mem-bandwidth"
+	// TagLLCMissRate tags containers with their last-level cache miss rate.
+	TagLLCMissRate = "perf/llc-miss-rate"
+	// TagMemBandwidth tags containers with their memory bandwidth estimated from LLC misses.
+	TagMemBandwidth = "perf/mem-bandwidth"
+	// TagNoisyNeighbor tags containers detected to be noisy neighbors.
+	TagNoisyNeighbor = "noisy-neighbor"
+	// TagPool tags containers with the name of the pool the policy allocated them from.
+	TagPool = "policy/pool"
+	// TagPressure tags containers in pools under sustained resource pressure with the resources.
+	TagPressure = "psi/pressure"
+	// TagCPUBurst tags containers with spiking CPU pressure, let to burst onto idle exclusive CPUs.
+	TagCPUBurst = "psi/cpu-burst"
+	// TagCPUReclaim tags containers under CPU pressure, reclaiming exclusive CPUs lent for bursting.
+	TagCPUReclaim = "psi/cpu-reclaim"
+	// TagWorkQueues tags containers with the device nodes of their assigned accelerator work queues.
+	TagWorkQueues = "accelerator/work-queues"
+	// TagMemoryPinning tags containers with their memory pinning mode, if it is not strict.
+	TagMemoryPinning = "memory/pinning"
+
+	// MemoryPinningStrict confines the memory of a container to its memory nodes.
+	MemoryPinningStrict = "strict"
+	// MemoryPinningPreferred prefers the memory nodes of a container, falling back to others.
+	MemoryPinningPreferred = "preferred"
+	// MemoryPinningSpreadCache confines the memory of a container to its memory nodes,
+	// spreading its page cache and slab allocations evenly over them.
+	MemoryPinningSpreadCache = "spread-cache"
 )
 
 // PodState is the pod state in the runtime.
@@ -65,6 +100,21 @@ type PodResourceRequirements struct {
 	Containers map[string]v1.ResourceRequirements `json:"containers"`
 }
 
+// copy returns a deep copy of the resource requirements.
+func (r *PodResourceRequirements) copy() *PodResourceRequirements {
+	c := &PodResourceRequirements{
+		InitContainers: make(map[string]v1.ResourceRequirements, len(r.InitContainers)),
+		Containers:     make(map[string]v1.ResourceRequirements, len(r.Containers)),
+	}
+	for name, res := range r.InitContainers {
+		c.InitContainers[name] = *res.DeepCopy()
+	}
+	for name, res := range r.Containers {
+		c.Containers[name] = *res.DeepCopy()
+	}
+	return c
+}
+
 // Pod is the exposed interface from a cached pod.
 type Pod interface {
 	// GetInitContainers returns the init containers of the pod.
@@ -114,6 +164,8 @@ type Pod interface {
 		decode func([]byte, interface{}) error) (bool, error)
 	// GetCgroupParentDir returns the pods cgroup parent directory.
 	GetCgroupParentDir() string
+	// GetRuntimeHandler returns the runtime handler (RuntimeClass) of the pod.
+	GetRuntimeHandler() string
 	// GetPodResourceRequirements returns container resource requirements if the
 	// necessary associated annotation put in place by the CRI resource manager
 	// webhook was found.
@@ -122,6 +174,8 @@ type Pod interface {
 	GetContainerAffinity(string) []*Affinity
 	// ScopeExpression returns an affinity expression for defining this pod as the scope.
 	ScopeExpression() *Expression
+	// IsIgnored returns true if the pod is annotated to be left unmanaged.
+	IsIgnored() bool
 }
 
 // A cached pod.
@@ -138,6 +192,9 @@ type pod struct {
 	CgroupParent string            // cgroup parent directory
 	containers   map[string]string // container name to ID map
 
+	RuntimeHandler string // runtime handler (RuntimeClass) of the pod
+	Attempt        uint32 // sandbox creation attempt
+
 	Resources *PodResourceRequirements // annotated resource requirements
 	Affinity  *podContainerAffinity    // annotated container affinity
 }
@@ -166,6 +223,8 @@ type Container interface {
 	PrettyName() string
 	// GetPod returns the pod of the container.
 	GetPod() (Pod, bool)
+	// IsIgnored returns true if the container is left unmanaged, as annotated for its pod.
+	IsIgnored() bool
 	// GetID returns the ID of the container.
 	GetID() string
 	// GetPodID returns the pod ID of the container.
@@ -279,6 +338,13 @@ type Container interface {
 
 	// SetLinuxResources sets the Linux-specific resource request of the container.
 	SetLinuxResources(*cri.LinuxContainerResources)
+	// UpdateResources updates the container for an in-place resource resize,
+	// returning whether its resource requirements changed.
+	UpdateResources(*cri.LinuxContainerResources) bool
+	// SnapshotResources takes a snapshot of the resources of the container.
+	SnapshotResources() *ResourceSnapshot
+	// RestoreResources restores the resources of the container from a snapshot.
+	RestoreResources(*ResourceSnapshot)
 	// SetCPUPeriod sets the CFS CPU period of the container.
 	SetCPUPeriod(int64)
 	// SetCPUQuota sets the CFS CPU quota of the container.
@@ -307,6 +373,40 @@ type Container interface {
 	// GetBlockIOClass returns the BlockIO class for this container.
 	GetBlockIOClass() string
 
+	// SetNetworkClass assigns this container to the given network class.
+	SetNetworkClass(string)
+	// GetNetworkClass returns the network class for this container.
+	GetNetworkClass() string
+
+	// GetRestartCount returns the number of times the container has been restarted.
+	GetRestartCount() int
+	// GetLastExit returns how the previous instance of the container exited, if known.
+	GetLastExit() *ContainerExit
+	// SetLastExit sets how the previous instance of the container exited.
+	SetLastExit(*ContainerExit)
+	// GetOOMKills returns the number of OOM kills in the container, across restarts.
+	GetOOMKills() int
+	// SetOOMKills sets the number of OOM kills in the container, across restarts.
+	SetOOMKills(int)
+
+	// SetPolicyEntry sets a policy-private data entry with the given schema tag.
+	SetPolicyEntry(key, schema string, obj interface{}) error
+	// GetPolicyEntry decodes a policy-private data entry with the given schema tag.
+	GetPolicyEntry(key, schema string, ptr interface{}) (bool, error)
+	// DeletePolicyEntry deletes a policy-private data entry.
+	DeletePolicyEntry(key string)
+	// GetPolicyEntries returns a copy of all policy-private data entries.
+	GetPolicyEntries() map[string]PolicyEntry
+
+	// GetRequestedLinuxResources returns the Linux resources as requested by the kubelet.
+	GetRequestedLinuxResources() *cri.LinuxContainerResources
+	// GetSentLinuxResources returns the Linux resources last sent to the runtime.
+	GetSentLinuxResources() *cri.LinuxContainerResources
+	// SetSentLinuxResources records the Linux resources sent to the runtime.
+	SetSentLinuxResources(*cri.LinuxContainerResources)
+	// GetLinuxResourceDiffs returns the differences between requested and sent Linux resources.
+	GetLinuxResourceDiffs() []ResourceDiff
+
 	// SetCRIRequest sets the current pending CRI request of the container.
 	SetCRIRequest(req interface{}) error
 	// GetCRIRequest returns the current pending CRI request of the container.
@@ -334,6 +434,11 @@ type Container interface {
 	SetTag(string, string) (string, bool)
 	// DeleteTag deletes the given tag, returning its deleted value.
 	DeleteTag(string) (string, bool)
+
+	// ExportResourceAssignment serializes the resource assignment of the container.
+	ExportResourceAssignment() ([]byte, error)
+	// ImportResourceAssignment imports a serialized resource assignment for the container.
+	ImportResourceAssignment([]byte) error
 }
 
 // A cached container.
@@ -357,17 +462,39 @@ type container struct {
 	TopologyHints topology.Hints     // Set of topology hints for all containers within Pod
 	Tags          map[string]string  // container tags (local dynamic labels)
 
-	Resources v1.ResourceRequirements      // container resources (from webhook annotation)
-	LinuxReq  *cri.LinuxContainerResources // used to estimate Resources if we lack annotations
-	req       *interface{}                 // pending CRI request
+	Resources         v1.ResourceRequirements      // container resources (from webhook annotation)
+	LinuxReq          *cri.LinuxContainerResources // used to estimate Resources if we lack annotations
+	RequestedLinuxReq *cri.LinuxContainerResources // Linux resources as requested by the kubelet
+	SentLinuxReq      *cri.LinuxContainerResources // Linux resources last sent to the runtime
+	req               *interface{}                 // pending CRI request
 
 	RDTClass     string              // RDT class this container is assigned to.
 	BlockIOClass string              // Block I/O class this container is assigned to.
+	NetworkClass string              // Network class this container is assigned to.
 	pending      map[string]struct{} // controllers with pending changes for this container
 
+	RestartCount int            // number of times the container has been restarted
+	LastExit     *ContainerExit // exit of the previous instance of the container
+	OOMKills     int            // number of OOM kills in the container, across restarts
+
+	PolicyData map[string]*PolicyEntry // policy-private data entries
+
 	prettyName string // cached PrettyName()
 }
 
+// ContainerExit describes how a container exited.
+type ContainerExit struct {
+	// Reason is the brief reason of the exit, for instance OOMKilled.
+	Reason string `json:",omitempty"`
+	// ExitCode is the exit code of the container.
+	ExitCode int32
+	// FinishedAt is the time the container exited, in nanoseconds since the epoch.
+	FinishedAt int64 `json:",omitempty"`
+}
+
+// ContainerExitOOMKilled is the exit reason of containers killed for running out of memory.
+const ContainerExitOOMKilled = "OOMKilled"
+
 // MountType is a propagation type.
 type MountType int32
 
@@ -427,6 +554,8 @@ type Cache interface {
 	DeletePod(id string) Pod
 	// LookupPod looks up a pod in the cache.
 	LookupPod(id string) (Pod, bool)
+	// LookupPodByUID looks up a pod by its UID and sandbox creation attempt.
+	LookupPodByUID(uid string, attempt uint32) (Pod, bool)
 	// InsertContainer inserts a container into the cache, using a runtime request or reply.
 	InsertContainer(msg interface{}) (Container, error)
 	// UpdateContainerID updates a containers runtime id.
@@ -437,6 +566,8 @@ type Cache interface {
 	LookupContainer(id string) (Container, bool)
 	// LookupContainerByCgroup looks up a container for the given cgroup path.
 	LookupContainerByCgroup(path string) (Container, bool)
+	// LookupContainerByName looks up a container by pod ID, name and restart count.
+	LookupContainerByName(podID, name string, restarts int) (Container, bool)
 
 	// GetPendingContainers returs all containers with pending changes.
 	GetPendingContainers() []Container
@@ -455,6 +586,9 @@ type Cache interface {
 	FilterScope(*Expression) []Container
 	// EvaluateAffinity evaluates the given affinity against all known in-scope containers
 	EvaluateAffinity(*Affinity) map[string]int32
+	// EvaluateContainerAffinity evaluates the effective affinity of the container
+	// towards all other containers, resolving any conflicts deterministically.
+	EvaluateContainerAffinity(Container) map[string]int32
 	// AddImplicitAffinities adds a set of implicit affinities (added to all containers).
 	AddImplicitAffinities(map[string]*ImplicitAffinity) error
 
@@ -462,12 +596,18 @@ type Cache interface {
 	GetActivePolicy() string
 	// SetActivePolicy updates the name of the active policy stored in the cache.
 	SetActivePolicy(string) error
+	// ResetActivePolicy clears the active policy and any policy-specific data from the cache.
+	ResetActivePolicy() error
 
 	// SetPolicyEntry sets the policy entry for a key.
 	SetPolicyEntry(string, interface{})
 	// GetPolicyEntry gets the policy entry for a key.
 	GetPolicyEntry(string, interface{}) bool
 
+	// SetPodResources sets the resource requirements of pods, by pod UID, pushed by the agent.
+	// It returns the containers with changed resource requirements.
+	SetPodResources(map[string]*PodResourceRequirements) []Container
+
 	// SetConfig caches the given configuration.
 	SetConfig(*config.RawConfig) error
 	// GetConfig returns the current/cached configuration.
@@ -475,6 +615,10 @@ type Cache interface {
 
 	// Save requests a cache save.
 	Save() error
+	// Flush writes any pending asynchronous cache save.
+	Flush() error
+	// Reload discards the state of the cache and reloads the last saved one.
+	Reload() error
 
 	// Refresh requests purging old entries and creating new ones.
 	Refresh(rpl interface{}) ([]Pod, []Pod, []Container, []Container)
@@ -511,6 +655,10 @@ type cache struct {
 	pending map[string]struct{} // cache IDs of containers with pending changes
 
 	implicit map[string]*ImplicitAffinity // implicit affinities
+
+	podResources map[string]*PodResourceRequirements // pod resources from the agent, by pod UID
+
+	writer *writeBehind // asynchronous writer for write-behind saves, if any
 }
 
 // Make sure cache implements Cache.
@@ -520,6 +668,8 @@ var _ Cache = &cache{}
 type Options struct {
 	// CacheDir is the directory the cache should save its state in.
 	CacheDir string
+	// WriteBehind is the maximum delay of asynchronous saves, 0 for synchronous ones.
+	WriteBehind time.Duration
 }
 
 // NewCache instantiates a new cache. Load it from the given path if it exists.
@@ -534,6 +684,8 @@ func NewCache(options Options) (Cache, error) {
 		policyData: make(map[string]interface{}),
 		PolicyJSON: make(map[string]string),
 		implicit:   make(map[string]*ImplicitAffinity),
+
+		podResources: make(map[string]*PodResourceRequirements),
 	}
 
 	if err := os.MkdirAll(options.CacheDir, 0700); err != nil {
@@ -545,9 +697,32 @@ func NewCache(options Options) (Cache, error) {
 		return nil, err
 	}
 
+	if options.WriteBehind > 0 {
+		cch.writer = newWriteBehind(cch.Logger, cch.filePath, options.WriteBehind)
+	}
+
 	return cch, nil
 }
 
+// DiscardCache moves an existing, presumably unusable, saved cache out of the way.
+// The path of the moved cache file is returned, or "" if there was no cache file.
+func DiscardCache(options Options) (string, error) {
+	path := filepath.Join(options.CacheDir, "cache")
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", cacheError("failed to discard cache %s: %v", path, err)
+	}
+
+	discarded := path + ".discarded"
+	if err := os.Rename(path, discarded); err != nil {
+		return "", cacheError("failed to discard cache %s: %v", path, err)
+	}
+
+	return discarded, nil
+}
+
 // GetActivePolicy returns the name of the active policy stored in the cache.
 func (cch *cache) GetActivePolicy() string {
 	return cch.PolicyName
@@ -559,6 +734,20 @@ func (cch *cache) SetActivePolicy(policy string) error {
 	return cch.Save()
 }
 
+// ResetActivePolicy clears the active policy and any policy-specific data from the cache.
+func (cch *cache) ResetActivePolicy() error {
+	cch.Warn("resetting active policy %q and all policy-specific data...", cch.PolicyName)
+
+	cch.PolicyName = ""
+	cch.policyData = make(map[string]interface{})
+	cch.PolicyJSON = make(map[string]string)
+	for _, c := range cch.Containers {
+		c.PolicyData = nil
+	}
+
+	return cch.Save()
+}
+
 // SetConfig caches the given configuration.
 func (cch *cache) SetConfig(cfg *config.RawConfig) error {
 	old := cch.Cfg
@@ -578,11 +767,21 @@ func (cch *cache) GetConfig() *config.RawConfig {
 }
 
 // Derive cache id using pod uid, or allocate a new unused local cache id.
+//
+// Restarted containers get their restart count appended to the id, so they
+// don't collide with their previous, possibly not yet removed, instance.
 func (cch *cache) createCacheID(c *container) string {
 	if pod, ok := c.cache.LookupPod(c.PodID); ok {
 		uid := pod.GetUID()
 		if uid != "" {
-			return uid + ":" + c.Name
+			id := uid + ":" + c.Name
+			if c.RestartCount > 0 {
+				id += ":" + strconv.Itoa(c.RestartCount)
+			}
+			if _, taken := cch.Containers[id]; !taken {
+				return id
+			}
+			cch.Warn("cache id %s of container %s is already taken", id, c.Name)
 		}
 	}
 
@@ -641,6 +840,19 @@ func (cch *cache) LookupPod(id string) (Pod, bool) {
 	return p, ok
 }
 
+// Look up a pod by its UID and sandbox creation attempt.
+func (cch *cache) LookupPodByUID(uid string, attempt uint32) (Pod, bool) {
+	if uid == "" {
+		return nil, false
+	}
+	for _, p := range cch.Pods {
+		if p.UID == uid && p.Attempt == attempt {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
 // Insert a container into the cache.
 func (cch *cache) InsertContainer(msg interface{}) (Container, error) {
 	var err error
@@ -720,6 +932,19 @@ func (cch *cache) LookupContainer(id string) (Container, bool) {
 	return c, ok
 }
 
+// LookupContainerByName looks up a container by pod ID, name and restart count.
+func (cch *cache) LookupContainerByName(podID, name string, restarts int) (Container, bool) {
+	for id, c := range cch.Containers {
+		if id != c.CacheID {
+			continue
+		}
+		if c.PodID == podID && c.Name == name && c.RestartCount == restarts {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
 // LookupContainerByCgroup looks up the container for the given cgroup path.
 func (cch *cache) LookupContainerByCgroup(path string) (Container, bool) {
 	cch.Debug("resolving %s to a container...", path)
@@ -791,13 +1016,15 @@ func (cch *cache) RefreshPods(msg *cri.ListPodSandboxResponse) ([]Pod, []Pod, []
 	}
 
 	for id, c := range cch.Containers {
+		// containers are indexed by both cache and runtime ID, check them once
+		if id != c.CacheID {
+			continue
+		}
 		if _, ok := valid[c.PodID]; !ok {
 			cch.Debug("purging container %s of stale pod %s...", c.CacheID, c.PodID)
 			cch.DeleteContainer(c.CacheID)
 			c.State = ContainerStateStale
-			if id == c.CacheID {
-				containers = append(containers, c)
-			}
+			containers = append(containers, c)
 		}
 	}
 
@@ -830,13 +1057,15 @@ func (cch *cache) RefreshContainers(msg *cri.ListContainersResponse) ([]Containe
 	}
 
 	for id, c := range cch.Containers {
+		// containers are indexed by both cache and runtime ID, check them once
+		if id != c.CacheID {
+			continue
+		}
 		if _, ok := valid[c.ID]; !ok {
 			cch.Debug("purging stale container %s (state: %v)...", c.CacheID, c.GetState())
 			cch.DeleteContainer(c.CacheID)
 			c.State = ContainerStateStale
-			if id == c.CacheID {
-				del = append(del, c)
-			}
+			del = append(del, c)
 		}
 	}
 
@@ -934,6 +1163,57 @@ func (cch *cache) SetPolicyEntry(key string, obj interface{}) {
 	}
 }
 
+// SetPodResources sets the resource requirements of pods, by pod UID, pushed by the agent.
+//
+// The requirements are used for pods without webhook resource annotations.
+// Such pods already known to the cache, and their containers, are updated.
+// The containers with changed resource requirements are returned, for the
+// caller to reallocate their resources.
+func (cch *cache) SetPodResources(resources map[string]*PodResourceRequirements) []Container {
+	cch.podResources = resources
+	if cch.podResources == nil {
+		cch.podResources = make(map[string]*PodResourceRequirements)
+	}
+
+	updated := false
+	changed := []Container{}
+	for _, p := range cch.Pods {
+		if _, ok := p.GetAnnotation(KeyResourceAnnotation); ok {
+			continue
+		}
+		r, ok := cch.podResources[p.UID]
+		if !ok || p.UID == "" {
+			continue
+		}
+		p.Resources = r.copy()
+		for _, c := range cch.Containers {
+			if c.PodID != p.ID {
+				continue
+			}
+			res, ok := p.Resources.InitContainers[c.Name]
+			if !ok {
+				res, ok = p.Resources.Containers[c.Name]
+			}
+			if !ok {
+				continue
+			}
+			if resourceListsEqual(c.Resources.Requests, res.Requests) &&
+				resourceListsEqual(c.Resources.Limits, res.Limits) {
+				continue
+			}
+			c.Resources = res
+			changed = append(changed, c)
+		}
+		updated = true
+	}
+
+	if updated {
+		cch.Save()
+	}
+
+	return changed
+}
+
 // Get the policy entry for a key.
 func (cch *cache) GetPolicyEntry(key string, ptr interface{}) bool {
 
@@ -1209,6 +1489,11 @@ func (cch *cache) Save() error {
 		return cacheError("failed to save cache: %v", err)
 	}
 
+	if cch.writer != nil {
+		cch.writer.queue(data)
+		return nil
+	}
+
 	if err = ioutil.WriteFile(cch.filePath, data, 0644); err != nil {
 		return cacheError("failed to write cache to file '%s': %v", cch.filePath, err)
 	}
@@ -1216,10 +1501,29 @@ func (cch *cache) Save() error {
 	return nil
 }
 
+// Flush writes any pending asynchronous save of the cache.
+func (cch *cache) Flush() error {
+	if cch.writer == nil {
+		return nil
+	}
+	return cch.writer.flush()
+}
+
+// Reload discards the state of the cache and reloads the last saved one.
+func (cch *cache) Reload() error {
+	if cch.writer != nil {
+		cch.writer.discard()
+	}
+	return cch.Load()
+}
+
 // Load loads the last saved state of the cache.
 func (cch *cache) Load() error {
 	cch.Debug("loading cache from file '%s'...", cch.filePath)
 
+	// a leftover journal is an interrupted write, the cache file is still intact
+	os.Remove(cch.filePath + journalSuffix)
+
 	data, err := ioutil.ReadFile(cch.filePath)
 
 	switch {
@@ -18,12 +18,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	resapi "k8s.io/apimachinery/pkg/api/resource"
 	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 	kubetypes "k8s.io/kubernetes/pkg/kubelet/types"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
 )
 
 var nextFakePodID = 1
@@ -46,6 +51,7 @@ type fakeContainer struct {
 	labels      map[string]string
 	annotations map[string]string
 	resources   cri.LinuxContainerResources
+	attempt     uint32
 }
 
 func createTmpCache() (Cache, string, error) {
@@ -113,7 +119,8 @@ func createFakeContainer(cch Cache, fc *fakeContainer) (Container, error) {
 		PodSandboxId: fc.fakePod.id,
 		Config: &cri.ContainerConfig{
 			Metadata: &cri.ContainerMetadata{
-				Name: fc.name,
+				Name:    fc.name,
+				Attempt: fc.attempt,
 			},
 			Labels:      fc.labels,
 			Annotations: fc.annotations,
@@ -210,3 +217,618 @@ func TestLookupContainerByCgroup(t *testing.T) {
 		}
 	}
 }
+
+func TestContainerPolicyEntry(t *testing.T) {
+	type grant struct {
+		CPUs  string
+		Share int
+	}
+
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	if err := cch.SetActivePolicy("test"); err != nil {
+		t.Fatalf("failed to set active policy: %v", err)
+	}
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: "container1"})
+	if err != nil {
+		t.Fatalf("failed to create fake container: %v", err)
+	}
+
+	set := grant{CPUs: "2-3", Share: 500}
+	if err := c.SetPolicyEntry("grant", "grant/v1", set); err != nil {
+		t.Fatalf("failed to set policy entry: %v", err)
+	}
+	if err := cch.Save(); err != nil {
+		t.Fatalf("failed to save cache: %v", err)
+	}
+
+	// check that the entry survives a reload of the cache
+	cch, err = NewCache(Options{CacheDir: dir})
+	if err != nil {
+		t.Fatalf("failed to reload cache: %v", err)
+	}
+	c, ok := cch.LookupContainer(c.GetCacheID())
+	if !ok {
+		t.Fatalf("failed to look up container after reload")
+	}
+
+	get := grant{}
+	found, err := c.GetPolicyEntry("grant", "grant/v1", &get)
+	if !found || err != nil {
+		t.Errorf("failed to get policy entry: found %v, error %v", found, err)
+	}
+	if get != set {
+		t.Errorf("expected policy entry %+v, got %+v", set, get)
+	}
+
+	if found, err = c.GetPolicyEntry("grant", "grant/v2", &get); !found || err == nil {
+		t.Errorf("expected schema mismatch error, got found %v, error %v", found, err)
+	}
+	if found, _ = c.GetPolicyEntry("missing", "grant/v1", &get); found {
+		t.Errorf("unexpectedly found missing policy entry")
+	}
+
+	if err := cch.SetActivePolicy("other"); err != nil {
+		t.Fatalf("failed to set active policy: %v", err)
+	}
+	if found, _ = c.GetPolicyEntry("grant", "grant/v1", &get); found {
+		t.Errorf("unexpectedly found policy entry of another policy")
+	}
+}
+
+func TestContainerUpdateResources(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	fc := &fakeContainer{
+		fakePod: fp,
+		name:    "container1",
+		resources: cri.LinuxContainerResources{
+			CpuShares:          1024,
+			CpuQuota:           100000,
+			CpuPeriod:          100000,
+			MemoryLimitInBytes: 1 << 30,
+		},
+	}
+	c, err := createFakeContainer(cch, fc)
+	if err != nil {
+		t.Fatalf("failed to create fake container: %v", err)
+	}
+
+	unchanged := fc.resources
+	if c.UpdateResources(&unchanged) {
+		t.Errorf("unexpected resource change for an identical update")
+	}
+
+	resized := fc.resources
+	resized.CpuShares = 2048
+	resized.CpuQuota = 200000
+	if !c.UpdateResources(&resized) {
+		t.Fatalf("expected resource change for resize")
+	}
+
+	resources := c.GetResourceRequirements()
+	if cpu := resources.Requests[v1.ResourceCPU]; cpu.MilliValue() != 2000 {
+		t.Errorf("expected CPU request 2000m, got %s", cpu.String())
+	}
+	if cpu := resources.Limits[v1.ResourceCPU]; cpu.MilliValue() != 2000 {
+		t.Errorf("expected CPU limit 2000m, got %s", cpu.String())
+	}
+	if shares := c.GetCPUShares(); shares != 2048 {
+		t.Errorf("expected CPU shares 2048, got %d", shares)
+	}
+}
+
+func TestContainerRestoreResources(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	fc := &fakeContainer{
+		fakePod: fp,
+		name:    "container1",
+		resources: cri.LinuxContainerResources{
+			CpuShares:          1024,
+			CpuQuota:           100000,
+			CpuPeriod:          100000,
+			MemoryLimitInBytes: 1 << 30,
+		},
+	}
+	c, err := createFakeContainer(cch, fc)
+	if err != nil {
+		t.Fatalf("failed to create fake container: %v", err)
+	}
+
+	original := c.GetResourceRequirements()
+	qos := c.GetQOSClass()
+	snapshot := c.SnapshotResources()
+
+	resized := fc.resources
+	resized.CpuShares = 2048
+	resized.CpuQuota = 200000
+	if !c.UpdateResources(&resized) {
+		t.Fatalf("expected resource change for resize")
+	}
+
+	c.RestoreResources(snapshot)
+
+	resources := c.GetResourceRequirements()
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		if o, r := original.Requests[name], resources.Requests[name]; o.Cmp(r) != 0 {
+			t.Errorf("expected restored %s request %s, got %s", name, o.String(), r.String())
+		}
+		if o, r := original.Limits[name], resources.Limits[name]; o.Cmp(r) != 0 {
+			t.Errorf("expected restored %s limit %s, got %s", name, o.String(), r.String())
+		}
+	}
+	if shares := c.GetCPUShares(); shares != 1024 {
+		t.Errorf("expected restored CPU shares 1024, got %d", shares)
+	}
+	if quota := c.GetCPUQuota(); quota != 100000 {
+		t.Errorf("expected restored CPU quota 100000, got %d", quota)
+	}
+	if restored := c.GetQOSClass(); restored != qos {
+		t.Errorf("expected restored QoS class %s, got %s", qos, restored)
+	}
+}
+
+func TestSetPodResources(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	containers := map[string]Container{}
+	for _, name := range []string{"container1", "container2"} {
+		fc := &fakeContainer{
+			fakePod: fp,
+			name:    name,
+			resources: cri.LinuxContainerResources{
+				CpuShares:          1024,
+				MemoryLimitInBytes: 1 << 30,
+			},
+		}
+		c, err := createFakeContainer(cch, fc)
+		if err != nil {
+			t.Fatalf("failed to create fake container: %v", err)
+		}
+		containers[name] = c
+	}
+	unchanged := containers["container2"].GetResourceRequirements()
+
+	resources := map[string]*PodResourceRequirements{
+		fp.uid: {
+			Containers: map[string]v1.ResourceRequirements{
+				"container1": {
+					Requests: v1.ResourceList{v1.ResourceCPU: resapi.MustParse("2")},
+					Limits:   v1.ResourceList{v1.ResourceCPU: resapi.MustParse("2")},
+				},
+			},
+		},
+	}
+
+	changed := cch.SetPodResources(resources)
+	if len(changed) != 1 || changed[0].GetName() != "container1" {
+		names := []string{}
+		for _, c := range changed {
+			names = append(names, c.GetName())
+		}
+		t.Fatalf("expected container1 to change, got %v", names)
+	}
+	cpu := containers["container1"].GetResourceRequirements().Requests[v1.ResourceCPU]
+	if cpu.Cmp(resapi.MustParse("2")) != 0 {
+		t.Errorf("expected CPU request 2 for container1, got %s", cpu.String())
+	}
+	cpu = containers["container2"].GetResourceRequirements().Requests[v1.ResourceCPU]
+	if expected := unchanged.Requests[v1.ResourceCPU]; cpu.Cmp(expected) != 0 {
+		t.Errorf("expected CPU request %s for container2, got %s", expected.String(), cpu.String())
+	}
+
+	if changed = cch.SetPodResources(resources); len(changed) != 0 {
+		t.Errorf("expected no changes for unchanged resources, got %d", len(changed))
+	}
+}
+
+func TestContainerRestartTracking(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: "container1", attempt: 2})
+	if err != nil {
+		t.Fatalf("failed to create fake container: %v", err)
+	}
+
+	if count := c.GetRestartCount(); count != 2 {
+		t.Errorf("expected restart count 2, got %d", count)
+	}
+	if exit := c.GetLastExit(); exit != nil {
+		t.Errorf("expected no last exit, got %+v", *exit)
+	}
+
+	exit := &ContainerExit{Reason: ContainerExitOOMKilled, ExitCode: 137, FinishedAt: 1000}
+	c.SetLastExit(exit)
+	c.SetOOMKills(3)
+	if err := cch.Save(); err != nil {
+		t.Fatalf("failed to save cache: %v", err)
+	}
+
+	// check that the statistics survive a reload of the cache
+	reloaded, err := NewCache(Options{CacheDir: dir})
+	if err != nil {
+		t.Fatalf("failed to reload cache: %v", err)
+	}
+	c, ok := reloaded.LookupContainer(c.GetCacheID())
+	if !ok {
+		t.Fatalf("failed to look up container after reload")
+	}
+	if count := c.GetRestartCount(); count != 2 {
+		t.Errorf("expected restart count 2 after reload, got %d", count)
+	}
+	if got := c.GetLastExit(); got == nil || *got != *exit {
+		t.Errorf("expected last exit %+v after reload, got %+v", *exit, got)
+	}
+	if kills := c.GetOOMKills(); kills != 3 {
+		t.Errorf("expected 3 OOM kills after reload, got %d", kills)
+	}
+}
+
+func TestContainerCacheID(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+
+	tcases := []struct {
+		name    string
+		attempt uint32
+		id      string // expected cache id, or empty for a local one
+	}{
+		{name: "first instance", attempt: 0, id: fp.uid + ":container1"},
+		{name: "restarted instance", attempt: 1, id: fp.uid + ":container1:1"},
+		{name: "restarted again", attempt: 2, id: fp.uid + ":container1:2"},
+		{name: "colliding instance", attempt: 2},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: "container1", attempt: tc.attempt})
+			if err != nil {
+				t.Fatalf("failed to create fake container: %v", err)
+			}
+			id := c.GetCacheID()
+			switch {
+			case tc.id == "" && !strings.HasPrefix(id, "cache:"):
+				t.Errorf("expected a local cache id, got %q", id)
+			case tc.id != "" && id != tc.id:
+				t.Errorf("expected cache id %q, got %q", tc.id, id)
+			}
+		})
+	}
+}
+
+func TestLookupByName(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	p, err := createFakePod(cch, fp)
+	if err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+
+	if found, ok := cch.LookupPodByUID(fp.uid, 0); !ok || found.GetID() != p.GetID() {
+		t.Errorf("expected to find pod %s by UID %s", p.GetID(), fp.uid)
+	}
+	if _, ok := cch.LookupPodByUID(fp.uid, 1); ok {
+		t.Errorf("unexpectedly found pod by UID %s with another attempt", fp.uid)
+	}
+	if _, ok := cch.LookupPodByUID("", 0); ok {
+		t.Errorf("unexpectedly found pod by empty UID")
+	}
+
+	containers := []Container{}
+	for attempt := uint32(0); attempt < 2; attempt++ {
+		c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: "container1", attempt: attempt})
+		if err != nil {
+			t.Fatalf("failed to create fake container: %v", err)
+		}
+		containers = append(containers, c)
+	}
+
+	for restarts, c := range containers {
+		found, ok := cch.LookupContainerByName(fp.id, "container1", restarts)
+		if !ok || found.GetID() != c.GetID() {
+			t.Errorf("expected to find container %s by name and restart count %d",
+				c.GetID(), restarts)
+		}
+	}
+	if _, ok := cch.LookupContainerByName(fp.id, "container1", 2); ok {
+		t.Errorf("unexpectedly found container with restart count 2")
+	}
+	if _, ok := cch.LookupContainerByName(fp.id, "container2", 0); ok {
+		t.Errorf("unexpectedly found container with another name")
+	}
+}
+
+func TestContainerLinuxResourceDiffs(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	fc := &fakeContainer{
+		fakePod: fp,
+		name:    "container1",
+		resources: cri.LinuxContainerResources{
+			CpuShares:  1024,
+			CpusetCpus: "0-7",
+		},
+	}
+	c, err := createFakeContainer(cch, fc)
+	if err != nil {
+		t.Fatalf("failed to create fake container: %v", err)
+	}
+
+	if diffs := c.GetLinuxResourceDiffs(); len(diffs) != 0 {
+		t.Errorf("expected no diffs before sending, got %v", diffs)
+	}
+
+	c.SetCpusetCpus("2-3")
+	c.SetSentLinuxResources(c.GetLinuxResources())
+
+	if cpus := c.GetRequestedLinuxResources().GetCpusetCpus(); cpus != "0-7" {
+		t.Errorf("expected requested cpuset 0-7, got %q", cpus)
+	}
+	diffs := c.GetLinuxResourceDiffs()
+	expected := []ResourceDiff{{Field: "cpuset_cpus", Requested: "0-7", Sent: "2-3"}}
+	if len(diffs) != len(expected) || diffs[0] != expected[0] {
+		t.Errorf("expected diffs %v, got %v", expected, diffs)
+	}
+}
+
+func TestEvaluateContainerAffinity(t *testing.T) {
+	cch, dir, err := createTmpCache()
+	if err != nil {
+		t.Fatalf("failed: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	fp := &fakePod{
+		name: "pod1",
+		annotations: map[string]string{
+			kubernetes.ResmgrKey(keyAffinity): `
+client:
+- matchLabels:
+    app: "server"
+  weight: 2
+- matchLabels:
+    app: "proxy"
+  weight: 1
+`,
+			kubernetes.ResmgrKey(keyAntiAffinity): `
+server:
+- matchLabels:
+    app: "proxy"
+  weight: 5
+`,
+		},
+	}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+
+	containers := map[string]Container{}
+	for _, name := range []string{"client", "server", "proxy"} {
+		c, err := createFakeContainer(cch, &fakeContainer{
+			fakePod: fp,
+			name:    name,
+			labels: map[string]string{
+				"app":                   name,
+				kubernetes.PodNameLabel: fp.name,
+			},
+		})
+		if err != nil {
+			t.Fatalf("failed to create fake container %s: %v", name, err)
+		}
+		containers[name] = c
+	}
+
+	// client is pulled towards both server and proxy, which push each other
+	// apart: the weakest affinity of client, towards proxy, should get dropped.
+	affinity := cch.EvaluateContainerAffinity(containers["client"])
+	if _, ok := affinity[containers["proxy"].GetCacheID()]; ok {
+		t.Errorf("expected cyclic affinity towards proxy to be dropped, got %v", affinity)
+	}
+	expected := map[string]int32{containers["server"].GetCacheID(): 2}
+	if len(affinity) != len(expected) {
+		t.Fatalf("expected affinity %v, got %v", expected, affinity)
+	}
+	for id, w := range expected {
+		if affinity[id] != w {
+			t.Errorf("expected affinity %v, got %v", expected, affinity)
+		}
+	}
+
+	// server is only pushed away from proxy
+	affinity = cch.EvaluateContainerAffinity(containers["server"])
+	if w := affinity[containers["proxy"].GetCacheID()]; len(affinity) != 1 || w != -5 {
+		t.Errorf("expected anti-affinity -5 for proxy, got %v", affinity)
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatalf("failed to create cache directory: %v", err)
+	}
+	defer removeTmpCache(dir)
+
+	cch, err := NewCache(Options{CacheDir: dir, WriteBehind: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	fp := &fakePod{name: "pod1"}
+	if _, err := createFakePod(cch, fp); err != nil {
+		t.Fatalf("failed to create fake pod: %v", err)
+	}
+	c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: "container1"})
+	if err != nil {
+		t.Fatalf("failed to create fake container: %v", err)
+	}
+	if err := cch.Save(); err != nil {
+		t.Fatalf("failed to save cache: %v", err)
+	}
+
+	// nothing should be written before the write-behind interval or a flush
+	if _, err := os.Stat(filepath.Join(dir, "cache")); !os.IsNotExist(err) {
+		t.Errorf("expected no cache file before flushing, got error %v", err)
+	}
+
+	if err := cch.Flush(); err != nil {
+		t.Fatalf("failed to flush cache: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cache"+journalSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expected no leftover journal after flushing, got error %v", err)
+	}
+
+	reloaded, err := NewCache(Options{CacheDir: dir})
+	if err != nil {
+		t.Fatalf("failed to reload cache: %v", err)
+	}
+	if _, ok := reloaded.LookupContainer(c.GetCacheID()); !ok {
+		t.Errorf("failed to look up container after reload")
+	}
+}
+
+func BenchmarkCreateContainer(b *testing.B) {
+	for _, bc := range []struct {
+		name        string
+		writeBehind time.Duration
+	}{
+		{name: "synchronous"},
+		{name: "write-behind", writeBehind: time.Second},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "cache-bench")
+			if err != nil {
+				b.Fatalf("failed to create cache directory: %v", err)
+			}
+			defer removeTmpCache(dir)
+
+			cch, err := NewCache(Options{CacheDir: dir, WriteBehind: bc.writeBehind})
+			if err != nil {
+				b.Fatalf("failed to create cache: %v", err)
+			}
+			fp := &fakePod{name: "pod1"}
+			if _, err := createFakePod(cch, fp); err != nil {
+				b.Fatalf("failed to create fake pod: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				name := fmt.Sprintf("container%d", i)
+				if _, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: name}); err != nil {
+					b.Fatalf("failed to create fake container: %v", err)
+				}
+				if err := cch.Save(); err != nil {
+					b.Fatalf("failed to save cache: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			if err := cch.Flush(); err != nil {
+				b.Fatalf("failed to flush cache: %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkInsertContainer(b *testing.B) {
+	for _, containers := range []int{0, 100, 500} {
+		b.Run(fmt.Sprintf("%d-containers", containers), func(b *testing.B) {
+			cch, dir, err := createTmpCache()
+			if err != nil {
+				b.Fatalf("failed to create cache: %v", err)
+			}
+			defer removeTmpCache(dir)
+
+			// populate the cache with containers, 10 per pod
+			var fp *fakePod
+			for i := 0; i < containers; i++ {
+				if i%10 == 0 {
+					fp = &fakePod{name: fmt.Sprintf("pod%d", i/10)}
+					if _, err := createFakePod(cch, fp); err != nil {
+						b.Fatalf("failed to create fake pod: %v", err)
+					}
+				}
+				name := fmt.Sprintf("container%d", i)
+				if _, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: name}); err != nil {
+					b.Fatalf("failed to create fake container: %v", err)
+				}
+			}
+
+			fp = &fakePod{name: "bench"}
+			if _, err := createFakePod(cch, fp); err != nil {
+				b.Fatalf("failed to create fake pod: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				name := fmt.Sprintf("bench%d", i)
+				c, err := createFakeContainer(cch, &fakeContainer{fakePod: fp, name: name})
+				if err != nil {
+					b.Fatalf("failed to create fake container: %v", err)
+				}
+				cch.DeleteContainer(c.GetCacheID())
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cachetest provides a cache with synthetic pods and containers for unit testing.
+//
+// Pods and containers are created through the same CRI requests the resource
+// manager relays, so tests get the real Pod and Container implementations of
+// the cache, with resource requirements, QoS class, labels and annotations
+// set up the way they would be for pods created by the kubelet.
+package cachetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+	kubetypes "k8s.io/kubernetes/pkg/kubelet/types"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
+)
+
+// Cache is a cache in a temporary directory, for creating synthetic pods in.
+type Cache struct {
+	cache.Cache
+	sync.Mutex
+	dir    string // temporary cache directory
+	nextID int    // next id for pods and containers
+}
+
+// NewCache creates a new cache in a temporary directory.
+func NewCache() (*Cache, error) {
+	dir, err := ioutil.TempDir("", "cachetest")
+	if err != nil {
+		return nil, fmt.Errorf("cachetest: failed to create cache directory: %v", err)
+	}
+	cch, err := cache.NewCache(cache.Options{CacheDir: dir})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cachetest: failed to create cache: %v", err)
+	}
+	return &Cache{Cache: cch, dir: dir, nextID: 1}, nil
+}
+
+// Close removes the temporary directory of the cache.
+func (c *Cache) Close() {
+	os.RemoveAll(c.dir)
+}
+
+// Pod is a builder for a synthetic pod and its containers.
+type Pod struct {
+	name           string
+	namespace      string
+	labels         map[string]string
+	annotations    map[string]string
+	runtimeHandler string
+	init           []*Container
+	containers     []*Container
+}
+
+// Container is a builder for a synthetic container.
+type Container struct {
+	name        string
+	image       string
+	requests    v1.ResourceList
+	limits      v1.ResourceList
+	labels      map[string]string
+	annotations map[string]string
+	envs        map[string]string
+	devices     []*cri.Device
+	mounts      []*cri.Mount
+}
+
+// NewPod returns a builder for a pod with the given name in the default namespace.
+func NewPod(name string) *Pod {
+	return &Pod{
+		name:        name,
+		namespace:   v1.NamespaceDefault,
+		labels:      map[string]string{},
+		annotations: map[string]string{},
+	}
+}
+
+// Namespace sets the namespace of the pod.
+func (p *Pod) Namespace(namespace string) *Pod {
+	p.namespace = namespace
+	return p
+}
+
+// Label sets a label of the pod.
+func (p *Pod) Label(key, value string) *Pod {
+	p.labels[key] = value
+	return p
+}
+
+// Annotation sets an annotation of the pod.
+func (p *Pod) Annotation(key, value string) *Pod {
+	p.annotations[key] = value
+	return p
+}
+
+// ResmgrAnnotation sets an annotation of the pod in the cri-resource-manager namespace.
+func (p *Pod) ResmgrAnnotation(key, value string) *Pod {
+	return p.Annotation(kubernetes.ResmgrKey(key), value)
+}
+
+// RuntimeHandler sets the runtime handler (RuntimeClass) of the pod.
+func (p *Pod) RuntimeHandler(handler string) *Pod {
+	p.runtimeHandler = handler
+	return p
+}
+
+// InitContainer adds an init container to the pod.
+func (p *Pod) InitContainer(c *Container) *Pod {
+	p.init = append(p.init, c)
+	return p
+}
+
+// Container adds a container to the pod.
+func (p *Pod) Container(c *Container) *Pod {
+	p.containers = append(p.containers, c)
+	return p
+}
+
+// NewContainer returns a builder for a container with the given name.
+func NewContainer(name string) *Container {
+	return &Container{
+		name:        name,
+		image:       "busybox:latest",
+		requests:    v1.ResourceList{},
+		limits:      v1.ResourceList{},
+		labels:      map[string]string{},
+		annotations: map[string]string{},
+		envs:        map[string]string{},
+	}
+}
+
+// Image sets the image of the container.
+func (c *Container) Image(image string) *Container {
+	c.image = image
+	return c
+}
+
+// Request sets a resource request of the container, for instance Request("cpu", "500m").
+func (c *Container) Request(name v1.ResourceName, quantity string) *Container {
+	c.requests[name] = resource.MustParse(quantity)
+	return c
+}
+
+// Limit sets a resource limit of the container, for instance Limit("memory", "1Gi").
+func (c *Container) Limit(name v1.ResourceName, quantity string) *Container {
+	c.limits[name] = resource.MustParse(quantity)
+	return c
+}
+
+// Guaranteed sets equal CPU and memory requests and limits for the container.
+func (c *Container) Guaranteed(cpu, memory string) *Container {
+	return c.Request(v1.ResourceCPU, cpu).Limit(v1.ResourceCPU, cpu).
+		Request(v1.ResourceMemory, memory).Limit(v1.ResourceMemory, memory)
+}
+
+// Label sets a label of the container.
+func (c *Container) Label(key, value string) *Container {
+	c.labels[key] = value
+	return c
+}
+
+// Annotation sets an annotation of the container.
+func (c *Container) Annotation(key, value string) *Container {
+	c.annotations[key] = value
+	return c
+}
+
+// Env sets an environment variable of the container.
+func (c *Container) Env(key, value string) *Container {
+	c.envs[key] = value
+	return c
+}
+
+// Device adds a device to the container.
+func (c *Container) Device(host, container, permissions string) *Container {
+	c.devices = append(c.devices, &cri.Device{
+		HostPath:      host,
+		ContainerPath: container,
+		Permissions:   permissions,
+	})
+	return c
+}
+
+// Mount adds a mount to the container.
+func (c *Container) Mount(host, container string, readonly bool) *Container {
+	c.mounts = append(c.mounts, &cri.Mount{
+		HostPath:      host,
+		ContainerPath: container,
+		Readonly:      readonly,
+	})
+	return c
+}
+
+// CreatePod creates a pod and its containers in the cache.
+//
+// The containers are created in the order they were added to the pod, init
+// containers first, and are left in the created state.
+func (c *Cache) CreatePod(p *Pod) (cache.Pod, []cache.Container, error) {
+	c.Lock()
+	id := c.nextID
+	c.nextID++
+	c.Unlock()
+
+	podID := fmt.Sprintf("pod-%4.4d", id)
+	uid := fmt.Sprintf("pod-uid-%4.4d", id)
+	qos := p.qosClass()
+
+	resources, err := json.Marshal(p.resources())
+	if err != nil {
+		return nil, nil, fmt.Errorf("cachetest: failed to encode resources of %s: %v", p.name, err)
+	}
+
+	labels := copyMap(p.labels)
+	labels[kubetypes.KubernetesPodUIDLabel] = uid
+	labels[kubetypes.KubernetesPodNameLabel] = p.name
+	labels[kubetypes.KubernetesPodNamespaceLabel] = p.namespace
+	annotations := copyMap(p.annotations)
+	annotations[cache.KeyResourceAnnotation] = string(resources)
+
+	req := &cri.RunPodSandboxRequest{
+		Config: &cri.PodSandboxConfig{
+			Metadata: &cri.PodSandboxMetadata{
+				Name:      p.name,
+				Uid:       uid,
+				Namespace: p.namespace,
+			},
+			Labels:      labels,
+			Annotations: annotations,
+			Linux: &cri.LinuxPodSandboxConfig{
+				CgroupParent: cgroupParent(qos, uid),
+			},
+		},
+		RuntimeHandler: p.runtimeHandler,
+	}
+	pod := c.InsertPod(podID, req)
+	if pod == nil {
+		return nil, nil, fmt.Errorf("cachetest: failed to create pod %s", p.name)
+	}
+
+	containers := []cache.Container{}
+	for _, ctr := range append(append([]*Container{}, p.init...), p.containers...) {
+		created, err := c.createContainer(podID, req.Config, ctr)
+		if err != nil {
+			return nil, nil, err
+		}
+		containers = append(containers, created)
+	}
+
+	return pod, containers, nil
+}
+
+// createContainer creates a container of a pod in the cache.
+func (c *Cache) createContainer(podID string, podCfg *cri.PodSandboxConfig, ctr *Container) (cache.Container, error) {
+	c.Lock()
+	id := c.nextID
+	c.nextID++
+	c.Unlock()
+
+	envs := []*cri.KeyValue{}
+	for key, value := range ctr.envs {
+		envs = append(envs, &cri.KeyValue{Key: key, Value: value})
+	}
+
+	req := &cri.CreateContainerRequest{
+		PodSandboxId: podID,
+		Config: &cri.ContainerConfig{
+			Metadata: &cri.ContainerMetadata{
+				Name: ctr.name,
+			},
+			Image:       &cri.ImageSpec{Image: ctr.image},
+			Labels:      copyMap(ctr.labels),
+			Annotations: copyMap(ctr.annotations),
+			Envs:        envs,
+			Devices:     ctr.devices,
+			Mounts:      ctr.mounts,
+			Linux: &cri.LinuxContainerConfig{
+				Resources: ctr.linuxResources(),
+			},
+		},
+		SandboxConfig: podCfg,
+	}
+
+	created, err := c.InsertContainer(req)
+	if err != nil {
+		return nil, fmt.Errorf("cachetest: failed to create container %s: %v", ctr.name, err)
+	}
+	rpl := &cri.CreateContainerResponse{ContainerId: fmt.Sprintf("container-%4.4d", id)}
+	if _, err := c.UpdateContainerID(created.GetCacheID(), rpl); err != nil {
+		return nil, fmt.Errorf("cachetest: failed to set ID of container %s: %v", ctr.name, err)
+	}
+
+	return created, nil
+}
+
+// resources returns the resource requirements of the containers of a pod.
+func (p *Pod) resources() *cache.PodResourceRequirements {
+	r := &cache.PodResourceRequirements{
+		InitContainers: map[string]v1.ResourceRequirements{},
+		Containers:     map[string]v1.ResourceRequirements{},
+	}
+	for _, c := range p.init {
+		r.InitContainers[c.name] = c.resources()
+	}
+	for _, c := range p.containers {
+		r.Containers[c.name] = c.resources()
+	}
+	return r
+}
+
+// qosClass returns the QoS class of a pod, like the kubelet determines it.
+func (p *Pod) qosClass() v1.PodQOSClass {
+	all := append(append([]*Container{}, p.init...), p.containers...)
+	guaranteed, besteffort := true, true
+	for _, c := range all {
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			req, hasReq := c.requests[name]
+			lim, hasLim := c.limits[name]
+			if hasReq || hasLim {
+				besteffort = false
+			}
+			if !hasLim || (hasReq && req.Cmp(lim) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case besteffort:
+		return v1.PodQOSBestEffort
+	case guaranteed && len(all) > 0:
+		return v1.PodQOSGuaranteed
+	}
+	return v1.PodQOSBurstable
+}
+
+// resources returns the resource requirements of a container.
+//
+// Like with the kubelet, a missing request defaults to the limit.
+func (c *Container) resources() v1.ResourceRequirements {
+	r := v1.ResourceRequirements{
+		Requests: v1.ResourceList{},
+		Limits:   v1.ResourceList{},
+	}
+	for name, qty := range c.limits {
+		r.Limits[name] = qty.DeepCopy()
+		r.Requests[name] = qty.DeepCopy()
+	}
+	for name, qty := range c.requests {
+		r.Requests[name] = qty.DeepCopy()
+	}
+	return r
+}
+
+// linuxResources returns the Linux resources of a container, like the kubelet sets them.
+func (c *Container) linuxResources() *cri.LinuxContainerResources {
+	r := c.resources()
+	lr := &cri.LinuxContainerResources{}
+	if cpu, ok := r.Requests[v1.ResourceCPU]; ok {
+		lr.CpuShares = cache.MilliCPUToShares(int(cpu.MilliValue()))
+	} else {
+		lr.CpuShares = cache.MilliCPUToShares(0)
+	}
+	if cpu, ok := r.Limits[v1.ResourceCPU]; ok {
+		lr.CpuQuota, lr.CpuPeriod = cache.MilliCPUToQuota(cpu.MilliValue())
+	}
+	if mem, ok := r.Limits[v1.ResourceMemory]; ok {
+		lr.MemoryLimitInBytes = mem.Value()
+	}
+	return lr
+}
+
+// cgroupParent returns the cgroupfs-style cgroup parent of a pod.
+func cgroupParent(qos v1.PodQOSClass, uid string) string {
+	switch qos {
+	case v1.PodQOSBurstable:
+		return "/kubepods/burstable/pod" + uid
+	case v1.PodQOSBestEffort:
+		return "/kubepods/besteffort/pod" + uid
+	}
+	return "/kubepods/pod" + uid
+}
+
+// copyMap returns a copy of a string map.
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for key, value := range m {
+		c[key] = value
+	}
+	return c
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachetest
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestCreatePod(t *testing.T) {
+	cch, err := NewCache()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer cch.Close()
+
+	tcases := []struct {
+		name string
+		pod  *Pod
+		qos  v1.PodQOSClass
+	}{
+		{
+			name: "guaranteed",
+			pod:  NewPod("guaranteed").Container(NewContainer("ctr").Guaranteed("2", "1Gi")),
+			qos:  v1.PodQOSGuaranteed,
+		},
+		{
+			name: "burstable",
+			pod: NewPod("burstable").
+				Container(NewContainer("ctr0").Guaranteed("1", "100Mi")).
+				Container(NewContainer("ctr1").Request(v1.ResourceCPU, "500m")),
+			qos: v1.PodQOSBurstable,
+		},
+		{
+			name: "besteffort",
+			pod:  NewPod("besteffort").Container(NewContainer("ctr")),
+			qos:  v1.PodQOSBestEffort,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod, containers, err := cch.CreatePod(tc.pod)
+			if err != nil {
+				t.Fatalf("failed to create pod: %v", err)
+			}
+			if qos := pod.GetQOSClass(); qos != tc.qos {
+				t.Errorf("expected QoS class %s, got %s", tc.qos, qos)
+			}
+			if len(containers) != len(tc.pod.containers) {
+				t.Fatalf("expected %d containers, got %d", len(tc.pod.containers), len(containers))
+			}
+			for _, c := range containers {
+				if _, ok := cch.LookupContainer(c.GetID()); !ok {
+					t.Errorf("container %s not found by ID %s", c.GetName(), c.GetID())
+				}
+				if p, ok := c.GetPod(); !ok || p.GetID() != pod.GetID() {
+					t.Errorf("container %s not found in pod %s", c.GetName(), pod.GetName())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cachetest
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// FakeCache is a cache with only a fixed set of containers, and their pods.
+//
+// It is meant for tests using fake containers, which implement only the parts
+// of cache.Container the code under test uses. Other cache functions panic.
+type FakeCache struct {
+	cache.Cache
+	Containers []cache.Container
+}
+
+// GetContainers returns the containers of the cache.
+func (c *FakeCache) GetContainers() []cache.Container {
+	return c.Containers
+}
+
+// LookupPod looks up the pod with the given ID among the pods of the containers.
+func (c *FakeCache) LookupPod(id string) (cache.Pod, bool) {
+	for _, ctr := range c.Containers {
+		if pod, ok := ctr.GetPod(); ok && pod.GetID() == id {
+			return pod, true
+		}
+	}
+	return nil, false
+}
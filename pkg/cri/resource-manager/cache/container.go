@@ -51,6 +51,7 @@ func (c *container) fromCreateRequest(req *cri.CreateContainerRequest) error {
 	c.Name = meta.Name
 	c.Namespace = podMeta.Namespace
 	c.State = ContainerStateCreating
+	c.RestartCount = int(meta.Attempt)
 	c.Image = cfg.GetImage().GetImage()
 	c.Command = cfg.Command
 	c.Args = cfg.Args
@@ -88,6 +89,8 @@ func (c *container) fromCreateRequest(req *cri.CreateContainerRequest) error {
 		}
 	}
 
+	c.TopologyHints = topology.MergeTopologyHints(c.TopologyHints, c.getNetworkHints())
+
 	c.Tags = make(map[string]string)
 
 	// if we get more than one hint, check that there are no duplicates
@@ -96,6 +99,7 @@ func (c *container) fromCreateRequest(req *cri.CreateContainerRequest) error {
 	// }
 
 	c.LinuxReq = cfg.GetLinux().GetResources()
+	c.RequestedLinuxReq = copyLinuxResources(c.LinuxReq)
 
 	if p, _ := c.cache.Pods[c.PodID]; p != nil && p.Resources != nil {
 		if r, ok := p.Resources.InitContainers[c.Name]; ok {
@@ -106,11 +110,13 @@ func (c *container) fromCreateRequest(req *cri.CreateContainerRequest) error {
 	}
 
 	if len(c.Resources.Requests) == 0 && len(c.Resources.Limits) == 0 {
-		c.Resources = estimateComputeResources(c.LinuxReq)
+		c.Resources = EstimateComputeResources(c.LinuxReq)
 	}
 
 	c.TopologyHints = topology.MergeTopologyHints(c.TopologyHints, getKubeletHint(c.GetCpusetCpus(), c.GetCpusetMems()))
 
+	c.importAnnotatedAssignment()
+
 	return nil
 }
 
@@ -130,6 +136,7 @@ func (c *container) fromListResponse(lrc *cri.Container) error {
 
 	c.ID = lrc.Id
 	c.Name = meta.Name
+	c.RestartCount = int(meta.Attempt)
 	c.Namespace = p.Namespace
 	c.State = ContainerState(int32(lrc.State))
 	c.Image = lrc.GetImage().GetImage()
@@ -165,6 +172,14 @@ func (c *container) GetPod() (Pod, bool) {
 	return pod, found
 }
 
+func (c *container) IsIgnored() bool {
+	pod, ok := c.GetPod()
+	if !ok {
+		return false
+	}
+	return pod.IsIgnored()
+}
+
 func (c *container) GetID() string {
 	return c.ID
 }
@@ -486,12 +501,14 @@ func (c *container) InsertDevice(d *Device) {
 	}
 	c.Devices[d.Container] = d
 	c.markPending(CRI)
+	c.markPending(Devices)
 }
 
 func (c *container) DeleteDevice(path string) {
 	if _, ok := c.Devices[path]; ok {
 		delete(c.Devices, path)
 		c.markPending(CRI)
+		c.markPending(Devices)
 	}
 }
 
@@ -553,6 +570,91 @@ func (c *container) SetLinuxResources(req *cri.LinuxContainerResources) {
 	c.markPending(CRI)
 }
 
+// UpdateResources updates the container for an in-place resource resize.
+//
+// The CPU request and limit and the memory limit are updated from the given CRI
+// request. The memory request cannot be reliably estimated from it, so that is
+// left intact. If the requirements change, the pod QoS class is recalculated.
+func (c *container) UpdateResources(req *cri.LinuxContainerResources) bool {
+	if req == nil {
+		return false
+	}
+
+	c.RequestedLinuxReq = copyLinuxResources(req)
+
+	resources, changed := UpdatedResources(c.Resources, req)
+	if !changed {
+		return false
+	}
+
+	c.Resources = resources
+
+	lnx := &cri.LinuxContainerResources{}
+	if c.LinuxReq != nil {
+		*lnx = *c.LinuxReq
+	}
+	lnx.CpuShares = req.CpuShares
+	lnx.CpuQuota = req.CpuQuota
+	lnx.CpuPeriod = req.CpuPeriod
+	lnx.MemoryLimitInBytes = req.MemoryLimitInBytes
+	c.SetLinuxResources(lnx)
+
+	if p, ok := c.cache.Pods[c.PodID]; ok {
+		if p.Resources != nil {
+			if _, ok := p.Resources.InitContainers[c.Name]; ok {
+				p.Resources.InitContainers[c.Name] = resources
+			} else if _, ok := p.Resources.Containers[c.Name]; ok {
+				p.Resources.Containers[c.Name] = resources
+			}
+		}
+		p.QOSClass = ""
+	}
+
+	return true
+}
+
+// ResourceSnapshot is a snapshot of the resources of a container, for undoing an update.
+type ResourceSnapshot struct {
+	resources v1.ResourceRequirements
+	linuxReq  *cri.LinuxContainerResources
+	requested *cri.LinuxContainerResources
+	qosClass  v1.PodQOSClass
+}
+
+// SnapshotResources takes a snapshot of the resources of the container.
+func (c *container) SnapshotResources() *ResourceSnapshot {
+	s := &ResourceSnapshot{
+		resources: v1.ResourceRequirements{
+			Requests: c.Resources.Requests.DeepCopy(),
+			Limits:   c.Resources.Limits.DeepCopy(),
+		},
+		linuxReq:  copyLinuxResources(c.LinuxReq),
+		requested: copyLinuxResources(c.RequestedLinuxReq),
+	}
+	if p, ok := c.cache.Pods[c.PodID]; ok {
+		s.qosClass = p.QOSClass
+	}
+	return s
+}
+
+// RestoreResources restores the resources of the container from a snapshot.
+func (c *container) RestoreResources(s *ResourceSnapshot) {
+	c.Resources = s.resources
+	c.RequestedLinuxReq = copyLinuxResources(s.requested)
+	c.SetLinuxResources(copyLinuxResources(s.linuxReq))
+
+	if p, ok := c.cache.Pods[c.PodID]; ok {
+		if p.Resources != nil {
+			if _, ok := p.Resources.InitContainers[c.Name]; ok {
+				p.Resources.InitContainers[c.Name] = s.resources
+			} else if _, ok := p.Resources.Containers[c.Name]; ok {
+				p.Resources.Containers[c.Name] = s.resources
+			}
+		}
+		p.QOSClass = s.qosClass
+	}
+}
+
 func (c *container) SetCPUPeriod(value int64) {
 	if c.LinuxReq == nil {
 		c.LinuxReq = &cri.LinuxContainerResources{}
@@ -692,6 +794,15 @@ func (c *container) GetBlockIOClass() string {
 	return c.BlockIOClass
 }
 
+func (c *container) SetNetworkClass(class string) {
+	c.NetworkClass = class
+	c.markPending(Network)
+}
+
+func (c *container) GetNetworkClass() string {
+	return c.NetworkClass
+}
+
 func (c *container) SetCRIRequest(req interface{}) error {
 	if c.req != nil {
 		return cacheError("can't set pending container request: another pending")
@@ -798,6 +909,26 @@ func (c *container) HasPending(controller string) bool {
 	return pending
 }
 
+func (c *container) GetRestartCount() int {
+	return c.RestartCount
+}
+
+func (c *container) GetLastExit() *ContainerExit {
+	return c.LastExit
+}
+
+func (c *container) SetLastExit(exit *ContainerExit) {
+	c.LastExit = exit
+}
+
+func (c *container) GetOOMKills() int {
+	return c.OOMKills
+}
+
+func (c *container) SetOOMKills(count int) {
+	c.OOMKills = count
+}
+
 func (c *container) GetTag(key string) (string, bool) {
 	value, ok := c.Tags[key]
 	return value, ok
@@ -0,0 +1,58 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// ExclusiveCPUs returns the CPUs of containers not in the cpuset of any other
+// container, by container cache ID. Only containers which exist in the runtime,
+// i.e. ones not exited or stale, are considered. Containers without any such
+// CPU are left out of the result.
+func ExclusiveCPUs(containers []Container) map[string]cpuset.CPUSet {
+	users := map[int]int{}
+	cpus := map[string]cpuset.CPUSet{}
+
+	for _, c := range containers {
+		switch c.GetState() {
+		case ContainerStateExited, ContainerStateStale:
+			continue
+		}
+		cset, err := cpuset.Parse(c.GetCpusetCpus())
+		if err != nil || cset.IsEmpty() {
+			continue
+		}
+		for _, cpu := range cset.ToSlice() {
+			users[cpu]++
+		}
+		cpus[c.GetCacheID()] = cset
+	}
+
+	exclusive := map[string]cpuset.CPUSet{}
+	for id, cset := range cpus {
+		own := []int{}
+		for _, cpu := range cset.ToSlice() {
+			if users[cpu] == 1 {
+				own = append(own, cpu)
+			}
+		}
+		if len(own) > 0 {
+			exclusive[id] = cpuset.NewCPUSet(own...)
+		}
+	}
+
+	return exclusive
+}
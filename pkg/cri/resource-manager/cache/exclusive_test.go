@@ -0,0 +1,54 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func TestExclusiveCPUs(t *testing.T) {
+	newContainer := func(id, cpus string, state ContainerState) Container {
+		return &container{
+			CacheID:  id,
+			State:    state,
+			LinuxReq: &cri.LinuxContainerResources{CpusetCpus: cpus},
+		}
+	}
+	containers := []Container{
+		newContainer("exclusive", "0-1", ContainerStateRunning),
+		newContainer("partial", "2-4", ContainerStateRunning),
+		newContainer("shared-1", "4-7", ContainerStateCreated),
+		newContainer("shared-2", "5-7", ContainerStateRunning),
+		newContainer("exited", "0-1", ContainerStateExited),
+		newContainer("unpinned", "", ContainerStateRunning),
+	}
+
+	expected := map[string]string{
+		"exclusive": "0-1",
+		"partial":   "2-3",
+	}
+
+	exclusive := ExclusiveCPUs(containers)
+	if len(exclusive) != len(expected) {
+		t.Errorf("expected exclusive CPUs %v, got %v", expected, exclusive)
+	}
+	for id, cpus := range expected {
+		if got, ok := exclusive[id]; !ok || got.String() != cpus {
+			t.Errorf("expected exclusive CPUs %s for %s, got %v", cpus, id, got)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/topology"
+)
+
+const (
+	// annotation key Multus uses for the network attachments of a pod
+	keyMultusNetworks = "k8s.v1.cni.cncf.io/networks"
+	// annotation key for specifying the host network interfaces used by a pod
+	keyNetworkInterfaces = "network-interfaces"
+	// environment variable prefix of PCI devices allocated by the SR-IOV device plugin
+	pciDeviceEnvPrefix = "PCIDEVICE_"
+)
+
+// multusNetwork is a network attachment in the JSON syntax of the Multus annotation.
+type multusNetwork struct {
+	Name string `json:"name"`
+}
+
+// getNetworkInterfaces returns the host network interfaces referenced by the pod.
+//
+// Interfaces are listed explicitly with the network-interfaces annotation in
+// our namespace, as a comma-separated list. Additionally, network attachments
+// of Multus are checked for host network interfaces of the same name, with
+// the usual naming of NetworkAttachmentDefinitions after the master interface
+// of macvlan and similar networks.
+func (p *pod) getNetworkInterfaces() []string {
+	names := []string{}
+
+	p.GetResmgrAnnotationObject(keyNetworkInterfaces, &names, nil)
+
+	value, ok := p.GetAnnotation(keyMultusNetworks)
+	if !ok {
+		return names
+	}
+	if networks := []multusNetwork{}; json.Unmarshal([]byte(value), &networks) == nil {
+		for _, n := range networks {
+			names = append(names, n.Name)
+		}
+		return names
+	}
+	for _, name := range strings.Split(value, ",") {
+		// strip namespace/ prefix and @interface suffix
+		name = strings.TrimSpace(name)
+		if idx := strings.LastIndex(name, "/"); idx >= 0 {
+			name = name[idx+1:]
+		}
+		if idx := strings.Index(name, "@"); idx >= 0 {
+			name = name[:idx]
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// getNetworkHints returns topology hints for the host network devices of the container.
+//
+// PCI devices allocated by the SR-IOV device plugin, typically virtual functions
+// of a NIC, are passed to the container in PCIDEVICE_* environment variables.
+// Other network interfaces are looked up by name among the host interfaces.
+func (c *container) getNetworkHints() topology.Hints {
+	hints := topology.Hints{}
+
+	for key, value := range c.Env {
+		if !strings.HasPrefix(key, pciDeviceEnvPrefix) {
+			continue
+		}
+		for _, address := range strings.Split(value, ",") {
+			address = strings.TrimSpace(address)
+			h, err := topology.NewPCIDeviceHints(address)
+			if err != nil {
+				c.cache.Debug("%s: no topology hints for PCI device %s: %v",
+					c.PrettyName(), address, err)
+				continue
+			}
+			hints = topology.MergeTopologyHints(hints, h)
+		}
+	}
+
+	if p, ok := c.cache.Pods[c.PodID]; ok {
+		for _, name := range p.getNetworkInterfaces() {
+			h, err := topology.NewNetworkInterfaceHints(name)
+			if err != nil {
+				c.cache.Debug("%s: no topology hints for network interface %s: %v",
+					c.PrettyName(), name, err)
+				continue
+			}
+			hints = topology.MergeTopologyHints(hints, h)
+		}
+	}
+
+	return hints
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// journalSuffix is the suffix of the journal file snapshots are first written to.
+	journalSuffix = ".journal"
+)
+
+// writeBehind saves cache snapshots asynchronously.
+//
+// Snapshots are queued and written periodically in the background, so at most
+// the changes of the last interval can be lost in a crash. Only the latest
+// snapshot is kept, intermediate ones are never written. Each snapshot is first
+// written and synced to a journal file which is then atomically renamed over
+// the cache file, so a crash never leaves behind a partially written cache.
+type writeBehind struct {
+	sync.Mutex               // protects data
+	logger.Logger            // cache logger instance
+	path          string     // cache file path
+	data          []byte     // latest unsaved snapshot, nil if none
+	writing       sync.Mutex // serializes writes
+}
+
+// newWriteBehind creates an asynchronous cache writer with the given write interval.
+func newWriteBehind(log logger.Logger, path string, interval time.Duration) *writeBehind {
+	w := &writeBehind{
+		Logger: log,
+		path:   path,
+	}
+	go w.run(interval)
+	return w
+}
+
+// queue queues the given snapshot for writing, replacing any unsaved one.
+func (w *writeBehind) queue(data []byte) {
+	w.Lock()
+	defer w.Unlock()
+	w.data = data
+}
+
+// discard drops any unsaved snapshot.
+func (w *writeBehind) discard() {
+	w.queue(nil)
+}
+
+// run periodically writes the latest unsaved snapshot.
+func (w *writeBehind) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := w.flush(); err != nil {
+			w.Error("%v", err)
+		}
+	}
+}
+
+// flush writes the latest unsaved snapshot, if any.
+func (w *writeBehind) flush() error {
+	w.writing.Lock()
+	defer w.writing.Unlock()
+
+	w.Lock()
+	data := w.data
+	w.data = nil
+	w.Unlock()
+
+	if data == nil {
+		return nil
+	}
+
+	w.Debug("writing cache to file '%s'...", w.path)
+	if err := writeJournaled(w.path, data); err != nil {
+		w.Lock()
+		if w.data == nil {
+			w.data = data
+		}
+		w.Unlock()
+		return cacheError("failed to write cache to file '%s': %v", w.path, err)
+	}
+
+	return nil
+}
+
+// writeJournaled writes data to a journal file and renames it to the given path.
+func writeJournaled(path string, data []byte) error {
+	journal := path + journalSuffix
+
+	file, err := os.OpenFile(journal, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(journal)
+		return err
+	}
+
+	return os.Rename(journal, path)
+}
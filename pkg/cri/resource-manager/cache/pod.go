@@ -15,7 +15,6 @@
 package cache
 
 import (
-	"encoding/json"
 	"strconv"
 
 	v1 "k8s.io/api/core/v1"
@@ -28,6 +27,8 @@ import (
 const (
 	// KeyResourceAnnotation is the annotation key our webhook uses.
 	KeyResourceAnnotation = "intel.com/resources"
+	// KeyIgnore is the annotation key for opting a pod out of resource management.
+	KeyIgnore = "ignore"
 )
 
 // Create a pod from a run request.
@@ -44,13 +45,16 @@ func (p *pod) fromRunRequest(req *cri.RunPodSandboxRequest) error {
 	p.containers = make(map[string]string)
 	p.Name = meta.Name
 	p.Namespace = meta.Namespace
+	p.UID = meta.Uid
 	p.State = PodState(int32(PodStateReady))
 	p.Labels = cfg.Labels
 	p.Annotations = cfg.Annotations
 	p.CgroupParent = cfg.GetLinux().GetCgroupParent()
+	p.RuntimeHandler = req.RuntimeHandler
+	p.Attempt = meta.Attempt
 
-	p.parseResourceAnnotations()
 	p.extractLabels()
+	p.parseResourceAnnotations()
 
 	return nil
 }
@@ -65,12 +69,15 @@ func (p *pod) fromListResponse(pod *cri.PodSandbox) error {
 	p.containers = make(map[string]string)
 	p.Name = meta.Name
 	p.Namespace = meta.Namespace
+	p.UID = meta.Uid
 	p.State = PodState(int32(pod.State))
 	p.Labels = pod.Labels
 	p.Annotations = pod.Annotations
+	p.RuntimeHandler = pod.RuntimeHandler
+	p.Attempt = meta.Attempt
 
-	p.parseResourceAnnotations()
 	p.extractLabels()
+	p.parseResourceAnnotations()
 
 	return nil
 }
@@ -228,27 +235,7 @@ func (p *pod) GetAnnotationObject(key string, objPtr interface{},
 	}
 
 	// decode with type-specific default decoder
-	switch objPtr.(type) {
-	case *string:
-		*objPtr.(*string) = value
-	case *bool:
-		*objPtr.(*bool), err = strconv.ParseBool(value)
-	case *int:
-		var i int64
-		i, err = strconv.ParseInt(value, 0, 0)
-		*objPtr.(*int) = int(i)
-	case *uint:
-		var i uint64
-		i, err = strconv.ParseUint(value, 0, 0)
-		*objPtr.(*uint) = uint(i)
-	case *int64:
-		*objPtr.(*int64), err = strconv.ParseInt(value, 0, 64)
-	case *uint64:
-		*objPtr.(*uint64), err = strconv.ParseUint(value, 0, 64)
-	default:
-		err = json.Unmarshal([]byte(value), objPtr)
-	}
-
+	err = DecodeAnnotation([]byte(value), objPtr)
 	if err != nil {
 		p.cache.Error("failed to decode annotation %s (%s): %v", key, value, err)
 	}
@@ -277,6 +264,11 @@ func (p *pod) GetCgroupParentDir() string {
 	return p.CgroupParent
 }
 
+// Get the runtime handler of a pod.
+func (p *pod) GetRuntimeHandler() string {
+	return p.RuntimeHandler
+}
+
 // Get the resource requirements of a pod.
 func (p *pod) GetPodResourceRequirements() PodResourceRequirements {
 	if p.Resources == nil {
@@ -287,7 +279,12 @@ func (p *pod) GetPodResourceRequirements() PodResourceRequirements {
 }
 
 // Extract oft-used data (currently only k8s uid) from pod labels.
+//
+// The uid label is only a fallback for a uid missing from the pod metadata.
 func (p *pod) extractLabels() {
+	if p.UID != "" {
+		return
+	}
 	uid, ok := p.GetLabel(kubetypes.KubernetesPodUIDLabel)
 	if !ok {
 		p.cache.Warn("can't find (k8s) uid label for pod %s", p.ID)
@@ -296,9 +293,17 @@ func (p *pod) extractLabels() {
 }
 
 // Parse per container resource requirements from webhook annotations.
+//
+// Without a webhook annotation we fall back to any resource requirements
+// pushed to us by the agent for the pod.
 func (p *pod) parseResourceAnnotations() {
 	p.Resources = &PodResourceRequirements{}
-	p.GetAnnotationObject(KeyResourceAnnotation, p.Resources, nil)
+	if ok, _ := p.GetAnnotationObject(KeyResourceAnnotation, p.Resources, nil); ok {
+		return
+	}
+	if r, ok := p.cache.podResources[p.UID]; ok && p.UID != "" {
+		p.Resources = r.copy()
+	}
 }
 
 // Determine the QoS class of the pod.
@@ -354,6 +359,20 @@ func (p *pod) GetContainerAffinity(name string) []*Affinity {
 	return (*p.Affinity)[name]
 }
 
+// IsIgnored returns true if the pod is annotated to be left unmanaged.
+func (p *pod) IsIgnored() bool {
+	value, ok := p.GetResmgrAnnotation(KeyIgnore)
+	if !ok {
+		return false
+	}
+	ignore, err := strconv.ParseBool(value)
+	if err != nil {
+		p.cache.Error("pod %s: invalid annotation %s = %q: %v", p.Name, KeyIgnore, value, err)
+		return false
+	}
+	return ignore
+}
+
 // ScopeExpression returns an affinity expression for defining this pod as the scope.
 func (p *pod) ScopeExpression() *Expression {
 	return &Expression{
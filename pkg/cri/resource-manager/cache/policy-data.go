@@ -0,0 +1,99 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/json"
+)
+
+// PolicyEntry is a versioned piece of policy-private data of a container.
+//
+// Entries are stored JSON-encoded together with the container, so they survive
+// restarts. Each entry is tagged with the policy which set it and a schema tag
+// identifying the type and version of the data, for instance "cpu-grant/v1".
+// Entries set by another policy are ignored (and dropped) on lookup and entries
+// with a mismatching schema are reported as errors, so that policies can detect
+// and migrate old data instead of misinterpreting it.
+type PolicyEntry struct {
+	// Policy is the name of the policy which set the entry.
+	Policy string `json:"policy"`
+	// Schema is the schema tag of the entry data.
+	Schema string `json:"schema"`
+	// Data is the JSON-encoded entry data.
+	Data json.RawMessage `json:"data"`
+}
+
+// SetPolicyEntry sets a policy-private data entry for the container.
+func (c *container) SetPolicyEntry(key, schema string, obj interface{}) error {
+	data, err := marshalEntry(obj)
+	if err != nil {
+		return cacheError("%s: failed to marshal policy entry %q (%s): %v",
+			c.PrettyName(), key, schema, err)
+	}
+
+	if c.PolicyData == nil {
+		c.PolicyData = make(map[string]*PolicyEntry)
+	}
+	c.PolicyData[key] = &PolicyEntry{
+		Policy: c.cache.PolicyName,
+		Schema: schema,
+		Data:   json.RawMessage(data),
+	}
+
+	c.cache.Debug("%s: policy entry %q (%s) set to %s", c.PrettyName(), key, schema, string(data))
+
+	return nil
+}
+
+// GetPolicyEntry decodes a policy-private data entry of the container.
+func (c *container) GetPolicyEntry(key, schema string, ptr interface{}) (bool, error) {
+	entry, ok := c.PolicyData[key]
+	if !ok {
+		return false, nil
+	}
+
+	if entry.Policy != c.cache.PolicyName {
+		c.cache.Warn("%s: dropping stale policy entry %q of policy %q",
+			c.PrettyName(), key, entry.Policy)
+		delete(c.PolicyData, key)
+		return false, nil
+	}
+
+	if entry.Schema != schema {
+		return true, cacheError("%s: policy entry %q has schema %q, expected %q",
+			c.PrettyName(), key, entry.Schema, schema)
+	}
+
+	if err := unmarshalEntry([]byte(entry.Data), ptr); err != nil {
+		return true, cacheError("%s: failed to unmarshal policy entry %q (%s): %v",
+			c.PrettyName(), key, schema, err)
+	}
+
+	return true, nil
+}
+
+// DeletePolicyEntry deletes a policy-private data entry of the container.
+func (c *container) DeletePolicyEntry(key string) {
+	delete(c.PolicyData, key)
+}
+
+// GetPolicyEntries returns a copy of all policy-private data entries of the container.
+func (c *container) GetPolicyEntries() map[string]PolicyEntry {
+	entries := make(map[string]PolicyEntry, len(c.PolicyData))
+	for key, entry := range c.PolicyData {
+		entries[key] = *entry
+	}
+	return entries
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+// ResourceDiff is a difference between the requested and sent Linux resources of a container.
+type ResourceDiff struct {
+	// Field is the name of the differing CRI LinuxContainerResources field.
+	Field string `json:"field"`
+	// Requested is the value of the field as requested by the kubelet.
+	Requested string `json:"requested"`
+	// Sent is the value of the field as last sent to the runtime.
+	Sent string `json:"sent"`
+}
+
+// GetRequestedLinuxResources returns the Linux resources of the container as requested by the kubelet.
+func (c *container) GetRequestedLinuxResources() *cri.LinuxContainerResources {
+	return copyLinuxResources(c.RequestedLinuxReq)
+}
+
+// GetSentLinuxResources returns the Linux resources of the container last sent to the runtime.
+func (c *container) GetSentLinuxResources() *cri.LinuxContainerResources {
+	return copyLinuxResources(c.SentLinuxReq)
+}
+
+// SetSentLinuxResources records the Linux resources of the container sent to the runtime.
+func (c *container) SetSentLinuxResources(req *cri.LinuxContainerResources) {
+	c.SentLinuxReq = copyLinuxResources(req)
+}
+
+// GetLinuxResourceDiffs returns the differences between the requested and sent Linux resources.
+//
+// If nothing has been sent for the container yet, there are no differences.
+func (c *container) GetLinuxResourceDiffs() []ResourceDiff {
+	if c.SentLinuxReq == nil {
+		return nil
+	}
+
+	req, sent := c.RequestedLinuxReq, c.SentLinuxReq
+	fields := []struct {
+		name      string
+		requested string
+		sent      string
+	}{
+		{"cpu_period", itoa(req.GetCpuPeriod()), itoa(sent.GetCpuPeriod())},
+		{"cpu_quota", itoa(req.GetCpuQuota()), itoa(sent.GetCpuQuota())},
+		{"cpu_shares", itoa(req.GetCpuShares()), itoa(sent.GetCpuShares())},
+		{"memory_limit_in_bytes", itoa(req.GetMemoryLimitInBytes()), itoa(sent.GetMemoryLimitInBytes())},
+		{"oom_score_adj", itoa(req.GetOomScoreAdj()), itoa(sent.GetOomScoreAdj())},
+		{"cpuset_cpus", req.GetCpusetCpus(), sent.GetCpusetCpus()},
+		{"cpuset_mems", req.GetCpusetMems(), sent.GetCpusetMems()},
+	}
+
+	diffs := []ResourceDiff{}
+	for _, f := range fields {
+		if f.requested != f.sent {
+			diffs = append(diffs, ResourceDiff{Field: f.name, Requested: f.requested, Sent: f.sent})
+		}
+	}
+	return diffs
+}
+
+// copyLinuxResources returns a copy of the given Linux resources.
+func copyLinuxResources(req *cri.LinuxContainerResources) *cri.LinuxContainerResources {
+	if req == nil {
+		return nil
+	}
+	cp := *req
+	return &cp
+}
+
+// itoa formats an int64 resource value.
+func itoa(value int64) string {
+	return strconv.FormatInt(value, 10)
+}
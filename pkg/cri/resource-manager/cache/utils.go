@@ -41,8 +41,8 @@ const (
 
 var memoryCapacity int64
 
-// estimateComputeResources calculates resource requests/limits from a CRI request.
-func estimateComputeResources(lnx *cri.LinuxContainerResources) corev1.ResourceRequirements {
+// EstimateComputeResources calculates resource requests/limits from a CRI request.
+func EstimateComputeResources(lnx *cri.LinuxContainerResources) corev1.ResourceRequirements {
 	resources := corev1.ResourceRequirements{
 		Requests: corev1.ResourceList{},
 		Limits:   corev1.ResourceList{},
@@ -79,6 +79,55 @@ func estimateComputeResources(lnx *cri.LinuxContainerResources) corev1.ResourceR
 	return resources
 }
 
+// UpdatedResources returns resource requirements updated by the CPU and memory of
+// CRI resources, and whether this changes them. Other resources, like the memory
+// request, which CRI resources only carry as an estimate, are kept.
+func UpdatedResources(current corev1.ResourceRequirements,
+	lnx *cri.LinuxContainerResources) (corev1.ResourceRequirements, bool) {
+	estimate := EstimateComputeResources(lnx)
+	resources := corev1.ResourceRequirements{
+		Requests: current.Requests.DeepCopy(),
+		Limits:   current.Limits.DeepCopy(),
+	}
+	if resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	if resources.Limits == nil {
+		resources.Limits = corev1.ResourceList{}
+	}
+	updateResource(resources.Requests, estimate.Requests, corev1.ResourceCPU)
+	updateResource(resources.Limits, estimate.Limits, corev1.ResourceCPU)
+	updateResource(resources.Limits, estimate.Limits, corev1.ResourceMemory)
+
+	changed := !resourceListsEqual(resources.Requests, current.Requests) ||
+		!resourceListsEqual(resources.Limits, current.Limits)
+
+	return resources, changed
+}
+
+// updateResource updates or removes the named resource in a list from another one.
+func updateResource(list, from corev1.ResourceList, name corev1.ResourceName) {
+	if qty, ok := from[name]; ok {
+		list[name] = qty
+	} else {
+		delete(list, name)
+	}
+}
+
+// resourceListsEqual checks if the given resource lists are equal.
+func resourceListsEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, qa := range a {
+		qb, ok := b[name]
+		if !ok || qa.Cmp(qb) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // SharesToMilliCPU converts CFS CPU shares to milliCPU.
 func SharesToMilliCPU(shares int64) int64 {
 	if shares == minShares {
@@ -113,10 +113,93 @@ func (m *SetConfigReply) GetError() string {
 	return ""
 }
 
+type UpdatePodResourcesRequest struct {
+	// Key-value map of pod UIDs to resource requirements of their containers
+	Resources            map[string]string `protobuf:"bytes,1,rep,name=resources,proto3" json:"resources,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *UpdatePodResourcesRequest) Reset()         { *m = UpdatePodResourcesRequest{} }
+func (m *UpdatePodResourcesRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdatePodResourcesRequest) ProtoMessage()    {}
+func (*UpdatePodResourcesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d9bc9cf5b527561, []int{2}
+}
+
+func (m *UpdatePodResourcesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdatePodResourcesRequest.Unmarshal(m, b)
+}
+func (m *UpdatePodResourcesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdatePodResourcesRequest.Marshal(b, m, deterministic)
+}
+func (m *UpdatePodResourcesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdatePodResourcesRequest.Merge(m, src)
+}
+func (m *UpdatePodResourcesRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdatePodResourcesRequest.Size(m)
+}
+func (m *UpdatePodResourcesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdatePodResourcesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdatePodResourcesRequest proto.InternalMessageInfo
+
+func (m *UpdatePodResourcesRequest) GetResources() map[string]string {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+type UpdatePodResourcesReply struct {
+	// If not empty, indicate an error that happened while trying to update pod resources.
+	Error                string   `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UpdatePodResourcesReply) Reset()         { *m = UpdatePodResourcesReply{} }
+func (m *UpdatePodResourcesReply) String() string { return proto.CompactTextString(m) }
+func (*UpdatePodResourcesReply) ProtoMessage()    {}
+func (*UpdatePodResourcesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2d9bc9cf5b527561, []int{3}
+}
+
+func (m *UpdatePodResourcesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdatePodResourcesReply.Unmarshal(m, b)
+}
+func (m *UpdatePodResourcesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdatePodResourcesReply.Marshal(b, m, deterministic)
+}
+func (m *UpdatePodResourcesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdatePodResourcesReply.Merge(m, src)
+}
+func (m *UpdatePodResourcesReply) XXX_Size() int {
+	return xxx_messageInfo_UpdatePodResourcesReply.Size(m)
+}
+func (m *UpdatePodResourcesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdatePodResourcesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdatePodResourcesReply proto.InternalMessageInfo
+
+func (m *UpdatePodResourcesReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*SetConfigRequest)(nil), "v1.SetConfigRequest")
 	proto.RegisterMapType((map[string]string)(nil), "v1.SetConfigRequest.ConfigEntry")
 	proto.RegisterType((*SetConfigReply)(nil), "v1.SetConfigReply")
+	proto.RegisterType((*UpdatePodResourcesRequest)(nil), "v1.UpdatePodResourcesRequest")
+	proto.RegisterMapType((map[string]string)(nil), "v1.UpdatePodResourcesRequest.ResourcesEntry")
+	proto.RegisterType((*UpdatePodResourcesReply)(nil), "v1.UpdatePodResourcesReply")
 }
 
 func init() {
@@ -124,23 +207,27 @@ func init() {
 }
 
 var fileDescriptor_2d9bc9cf5b527561 = []byte{
-	// 243 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x32, 0x28, 0xc8, 0x4e, 0xd7,
+	// 316 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe3, 0x32, 0x28, 0xc8, 0x4e, 0xd7,
 	0x4f, 0x2e, 0xca, 0xd4, 0x2f, 0x4a, 0x2d, 0xce, 0x2f, 0x2d, 0x4a, 0x4e, 0xd5, 0xcd, 0x4d, 0xcc,
 	0x4b, 0x4c, 0x4f, 0x2d, 0xd2, 0x4f, 0xce, 0xcf, 0x4b, 0xcb, 0x4c, 0xd7, 0x4f, 0x2c, 0xc8, 0xd4,
 	0x2f, 0x33, 0x04, 0x51, 0x7a, 0x05, 0x45, 0xf9, 0x25, 0xf9, 0x42, 0x4c, 0x65, 0x86, 0x4a, 0x4b,
 	0x18, 0xb9, 0x04, 0x82, 0x53, 0x4b, 0x9c, 0xc1, 0x4a, 0x82, 0x52, 0x0b, 0x4b, 0x53, 0x8b, 0x4b,
 	0x84, 0xa4, 0xb9, 0x38, 0xf3, 0xf2, 0x53, 0x52, 0xe3, 0xf3, 0x12, 0x73, 0x53, 0x25, 0x18, 0x15,
-	0x18, 0x35, 0x38, 0x83, 0x38, 0x40, 0x02, 0x7e, 0x89, 0xb9, 0xa9, 0x42, 0x16, 0x5c, 0x6c, 0x10,
-	0x03, 0x25, 0x98, 0x14, 0x98, 0x35, 0xb8, 0x8d, 0x14, 0xf4, 0xca, 0x0c, 0xf5, 0xd0, 0x8d, 0xd0,
-	0x83, 0xf0, 0x5c, 0xf3, 0x4a, 0x8a, 0x2a, 0x83, 0xa0, 0xea, 0xa5, 0x2c, 0xb9, 0xb8, 0x91, 0x84,
-	0x85, 0x04, 0xb8, 0x98, 0xb3, 0x53, 0x2b, 0xa1, 0xe6, 0x83, 0x98, 0x42, 0x22, 0x5c, 0xac, 0x65,
-	0x89, 0x39, 0xa5, 0xa9, 0x12, 0x4c, 0x60, 0x31, 0x08, 0xc7, 0x8a, 0xc9, 0x82, 0x51, 0x49, 0x8d,
-	0x8b, 0x0f, 0xc9, 0x8a, 0x82, 0x1c, 0xb0, 0xda, 0xd4, 0xa2, 0xa2, 0xfc, 0x22, 0xa8, 0x7e, 0x08,
-	0xc7, 0xc8, 0x91, 0x8b, 0x0d, 0xa2, 0x48, 0xc8, 0x9c, 0x8b, 0x13, 0xae, 0x43, 0x48, 0x04, 0x9b,
-	0x1b, 0xa5, 0x84, 0xd0, 0x44, 0x0b, 0x72, 0x2a, 0x95, 0x18, 0x9c, 0x58, 0xa2, 0x98, 0xca, 0x0c,
-	0x93, 0xd8, 0xc0, 0x41, 0x64, 0x0c, 0x08, 0x00, 0x00, 0xff, 0xff, 0xb6, 0x6e, 0xc6, 0x28, 0x56,
-	0x01, 0x00, 0x00,
+	0x18, 0x35, 0x38, 0x83, 0x38, 0x40, 0x02, 0x7e, 0x40, 0xbe, 0x90, 0x05, 0x17, 0x1b, 0xc4, 0x40,
+	0x09, 0x26, 0x05, 0x66, 0x0d, 0x6e, 0x23, 0x05, 0xbd, 0x32, 0x43, 0x3d, 0x74, 0x23, 0xf4, 0x20,
+	0x3c, 0xd7, 0xbc, 0x92, 0xa2, 0xca, 0x20, 0xa8, 0x7a, 0x29, 0x4b, 0x2e, 0x6e, 0x24, 0x61, 0x21,
+	0x01, 0x2e, 0xe6, 0xec, 0xd4, 0x4a, 0xa8, 0xf9, 0x20, 0xa6, 0x90, 0x08, 0x17, 0x6b, 0x59, 0x62,
+	0x4e, 0x69, 0x2a, 0xd0, 0x64, 0x90, 0x18, 0x84, 0x63, 0xc5, 0x64, 0xc1, 0xa8, 0xa4, 0xc6, 0xc5,
+	0x87, 0x64, 0x45, 0x41, 0x0e, 0x58, 0x6d, 0x6a, 0x51, 0x51, 0x7e, 0x11, 0x54, 0x3f, 0x84, 0xa3,
+	0xb4, 0x94, 0x91, 0x4b, 0x32, 0xb4, 0x20, 0x25, 0xb1, 0x24, 0x35, 0x20, 0x3f, 0x25, 0x08, 0x1a,
+	0x10, 0xc5, 0x30, 0x7f, 0x79, 0x71, 0x71, 0xc2, 0x02, 0xa7, 0x18, 0xa8, 0x0f, 0xe4, 0x7a, 0x1d,
+	0x90, 0xeb, 0x71, 0xea, 0xd0, 0x83, 0x0b, 0x40, 0x7c, 0x82, 0xd0, 0x2e, 0x65, 0xc3, 0xc5, 0x87,
+	0x2a, 0x49, 0x92, 0x7f, 0xf4, 0xb9, 0xc4, 0xb1, 0x59, 0x8a, 0xd3, 0x63, 0x46, 0x53, 0x19, 0xb9,
+	0xd8, 0x20, 0xde, 0x17, 0x32, 0xe7, 0xe2, 0x84, 0x87, 0x85, 0x90, 0x08, 0xb6, 0xd0, 0x97, 0x12,
+	0x42, 0x13, 0x05, 0x9a, 0xab, 0xc4, 0x20, 0x14, 0xc4, 0x25, 0x84, 0x69, 0xa9, 0x90, 0x2c, 0xde,
+	0x10, 0x90, 0x92, 0xc6, 0x25, 0x0d, 0x36, 0xd3, 0x89, 0x25, 0x0a, 0x98, 0x8a, 0x92, 0xd8, 0xc0,
+	0x09, 0xca, 0x18, 0x00, 0x61, 0x6b, 0x7b, 0x61, 0x84, 0x02, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -156,6 +243,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type ConfigClient interface {
 	SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigReply, error)
+	UpdatePodResources(ctx context.Context, in *UpdatePodResourcesRequest, opts ...grpc.CallOption) (*UpdatePodResourcesReply, error)
 }
 
 type configClient struct {
@@ -175,9 +263,19 @@ func (c *configClient) SetConfig(ctx context.Context, in *SetConfigRequest, opts
 	return out, nil
 }
 
+func (c *configClient) UpdatePodResources(ctx context.Context, in *UpdatePodResourcesRequest, opts ...grpc.CallOption) (*UpdatePodResourcesReply, error) {
+	out := new(UpdatePodResourcesReply)
+	err := c.cc.Invoke(ctx, "/v1.Config/UpdatePodResources", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ConfigServer is the server API for Config service.
 type ConfigServer interface {
 	SetConfig(context.Context, *SetConfigRequest) (*SetConfigReply, error)
+	UpdatePodResources(context.Context, *UpdatePodResourcesRequest) (*UpdatePodResourcesReply, error)
 }
 
 // UnimplementedConfigServer can be embedded to have forward compatible implementations.
@@ -187,6 +285,9 @@ type UnimplementedConfigServer struct {
 func (*UnimplementedConfigServer) SetConfig(ctx context.Context, req *SetConfigRequest) (*SetConfigReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetConfig not implemented")
 }
+func (*UnimplementedConfigServer) UpdatePodResources(ctx context.Context, req *UpdatePodResourcesRequest) (*UpdatePodResourcesReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePodResources not implemented")
+}
 
 func RegisterConfigServer(s *grpc.Server, srv ConfigServer) {
 	s.RegisterService(&_Config_serviceDesc, srv)
@@ -210,6 +311,24 @@ func _Config_SetConfig_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Config_UpdatePodResources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePodResourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConfigServer).UpdatePodResources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.Config/UpdatePodResources",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConfigServer).UpdatePodResources(ctx, req.(*UpdatePodResourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Config_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "v1.Config",
 	HandlerType: (*ConfigServer)(nil),
@@ -218,6 +337,10 @@ var _Config_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SetConfig",
 			Handler:    _Config_SetConfig_Handler,
 		},
+		{
+			MethodName: "UpdatePodResources",
+			Handler:    _Config_UpdatePodResources_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pkg/cri/resource-manager/config/api/v1/api.proto",
@@ -33,6 +33,9 @@ import (
 // SetConfigCb is a callback function for SetConfig request
 type SetConfigCb func(*RawConfig) error
 
+// UpdatePodResourcesCb is a callback function for UpdatePodResources request
+type UpdatePodResourcesCb func(map[string]string) error
+
 // Server is the interface for our gRPC server.
 type Server interface {
 	Start(string) error
@@ -45,13 +48,15 @@ type server struct {
 	sync.Mutex               // lock for gRPC server against concurrent per-request goroutines.
 	server      *grpc.Server // gRPC server instance
 	setConfigCb SetConfigCb
+	podResCb    UpdatePodResourcesCb
 }
 
 // NewConfigServer creates new Server instance.
-func NewConfigServer(cb SetConfigCb) (Server, error) {
+func NewConfigServer(cb SetConfigCb, podResCb UpdatePodResourcesCb) (Server, error) {
 	s := &server{
 		Logger:      log.NewLogger("config-server"),
 		setConfigCb: cb,
+		podResCb:    podResCb,
 	}
 	return s, nil
 }
@@ -115,6 +120,25 @@ func (s *server) SetConfig(ctx context.Context, req *v1.SetConfigRequest) (*v1.S
 	return reply, nil
 }
 
+// UpdatePodResources updates the resource requirements of pods on the node.
+func (s *server) UpdatePodResources(ctx context.Context, req *v1.UpdatePodResourcesRequest) (*v1.UpdatePodResourcesReply, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Debug("REQUEST: resources of %d pods", len(req.Resources))
+
+	reply := &v1.UpdatePodResourcesReply{}
+	if s.podResCb == nil {
+		reply.Error = "pod resource updates not supported"
+		return reply, nil
+	}
+	if err := s.podResCb(req.Resources); err != nil {
+		reply.Error = fmt.Sprintf("failed to update pod resources: %v", err)
+	}
+
+	return reply, nil
+}
+
 func serverError(format string, args ...interface{}) error {
 	return fmt.Errorf(format, args...)
 }
@@ -0,0 +1,447 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+	"github.com/intel/cri-resource-manager/pkg/rdt"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// ConsistencyPath is the HTTP path for checking and repairing cache consistency.
+	ConsistencyPath = "/consistency"
+
+	// missingInCache is the kind of runtime pods and containers not in the cache.
+	missingInCache = "missing"
+	// staleInCache is the kind of cached pods and containers not in the runtime.
+	staleInCache = "stale"
+	// stateMismatch is the kind of containers with a different state in the cache and runtime.
+	stateMismatch = "state"
+	// cpusetMismatch is the kind of containers with a different cpuset in the cache and cgroup.
+	cpusetMismatch = "cpuset"
+	// resctrlMismatch is the kind of containers with tasks outside of their RDT class.
+	resctrlMismatch = "resctrl"
+
+	// resctrlGroupPrefix is the prefix of resctrl groups of RDT classes.
+	resctrlGroupPrefix = "cri-resmgr."
+	// resctrlRootClass is the RDT class of the root resctrl group.
+	resctrlRootClass = "SYSTEM_DEFAULT"
+	// resctrlMBLimitSeparator separates the class and the limit of memory bandwidth limited groups.
+	resctrlMBLimitSeparator = ".mb-"
+)
+
+// getContainerCpuset returns the cgroup cpuset of a container, overridable for testing.
+var getContainerCpuset = utils.GetContainerCpuset
+
+// getContainerPids returns the processes of a container, overridable for testing.
+var getContainerPids = utils.GetProcessInContainer
+
+// resctrlMountPath returns the mount point of the resctrl filesystem, overridable for testing.
+var resctrlMountPath = rdt.ResctrlMountPath
+
+// discrepancy is a difference between the cache and the runtime or kernel state.
+type discrepancy struct {
+	Kind     string `json:"kind"`
+	Subject  string `json:"subject"`
+	Cached   string `json:"cached,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Repaired bool   `json:"repaired,omitempty"`
+}
+
+// consistencyReport is the result of a consistency check.
+type consistencyReport struct {
+	Repair        bool           `json:"repair"`
+	Error         string         `json:"error,omitempty"`
+	Discrepancies []*discrepancy `json:"discrepancies"`
+}
+
+// setupConsistencyEndpoint sets up the HTTP endpoint for checking cache consistency.
+func (m *resmgr) setupConsistencyEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, cache consistency checking disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, ConsistencyPath, http.HandlerFunc(m.serveConsistency))
+
+	return nil
+}
+
+// serveConsistency reports discrepancies for GET, and also repairs them for POST requests.
+func (m *resmgr) serveConsistency(w http.ResponseWriter, req *http.Request) {
+	var report *consistencyReport
+
+	switch req.Method {
+	case http.MethodGet:
+		report = m.checkConsistency(false)
+	case http.MethodPost:
+		report = m.checkConsistency(true)
+	default:
+		http.Error(w, "unsupported method "+req.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		m.Error("failed to serve consistency report: %v", err)
+	}
+}
+
+// checkConsistency cross-checks the cache against the runtime and kernel state.
+//
+// The cache is compared against the pods and containers known to the runtime,
+// and the cpusets and resctrl groups of running containers against the kernel.
+// Found discrepancies are exported as metrics. If repair is set, the cache is
+// resynchronized with the runtime and the resources of containers with a
+// mismatching kernel state are reapplied. Nothing is repaired in pass-through
+// mode, which is left only by pushing a new configuration.
+func (m *resmgr) checkConsistency(repair bool) *consistencyReport {
+	m.Lock()
+	defer m.Unlock()
+
+	ctx := context.Background()
+	report := &consistencyReport{Repair: repair}
+
+	runtime, err := m.checkRuntimeConsistency(ctx)
+	if err != nil {
+		m.Error("consistency: %v", err)
+		report.Error = err.Error()
+	}
+	kernel, mismatched := m.checkKernelConsistency()
+
+	report.Discrepancies = append(runtime, kernel...)
+	consistencyMetrics.update(report.Discrepancies)
+
+	for _, d := range report.Discrepancies {
+		m.Warn("consistency: %s %s (cached: %q, actual: %q)", d.Kind, d.Subject, d.Cached, d.Actual)
+	}
+
+	if !repair || len(report.Discrepancies) == 0 {
+		return report
+	}
+
+	if m.failsafe.isTripped() {
+		m.Warn("consistency: not repairing in pass-through mode")
+		report.Error = "not repairing in pass-through mode"
+		return report
+	}
+
+	if len(runtime) > 0 {
+		if err := m.repairRuntimeConsistency(ctx); err != nil {
+			m.Error("consistency: failed to resynchronize with runtime: %v", err)
+			report.Error = err.Error()
+		} else {
+			markRepaired(runtime, nil)
+		}
+	}
+
+	if len(kernel) > 0 {
+		for _, c := range mismatched {
+			c.SetCpusetCpus(c.GetCpusetCpus())
+			if class := c.GetRDTClass(); class != "" {
+				c.SetRDTClass(class)
+			}
+		}
+		if err := m.runPostUpdateHooksFor(ctx, "consistency", m.cache.GetPendingContainers()); err != nil {
+			m.Error("consistency: failed to reapply container resources: %v", err)
+			report.Error = err.Error()
+		} else {
+			remaining, _ := m.checkKernelConsistency()
+			markRepaired(kernel, remaining)
+		}
+	}
+
+	for _, d := range report.Discrepancies {
+		if d.Repaired {
+			consistencyMetrics.repairs.WithLabelValues(d.Kind).Inc()
+		}
+	}
+
+	m.cache.Save()
+
+	return report
+}
+
+// checkRuntimeConsistency compares cached pods and containers against the runtime.
+func (m *resmgr) checkRuntimeConsistency(ctx context.Context) ([]*discrepancy, error) {
+	if !m.relay.Client().HasRuntimeService() {
+		return nil, nil
+	}
+
+	found := []*discrepancy{}
+
+	pods, err := m.relay.Client().ListPodSandbox(ctx, &criapi.ListPodSandboxRequest{})
+	if err != nil {
+		return nil, resmgrError("failed to list pods: %v", err)
+	}
+	cached := map[string]struct{}{}
+	for _, p := range m.cache.GetPods() {
+		cached[p.GetID()] = struct{}{}
+	}
+	for _, p := range pods.Items {
+		if _, ok := cached[p.Id]; ok {
+			delete(cached, p.Id)
+			continue
+		}
+		if p.State == criapi.PodSandboxState_SANDBOX_READY {
+			found = append(found, &discrepancy{Kind: missingInCache, Subject: "pod " + p.Id})
+		}
+	}
+	for id := range cached {
+		found = append(found, &discrepancy{Kind: staleInCache, Subject: "pod " + id})
+	}
+
+	containers, err := m.relay.Client().ListContainers(ctx, &criapi.ListContainersRequest{})
+	if err != nil {
+		return found, resmgrError("failed to list containers: %v", err)
+	}
+	actual := map[string]*criapi.Container{}
+	for _, c := range containers.Containers {
+		actual[c.Id] = c
+	}
+	for _, c := range m.cache.GetContainers() {
+		switch c.GetState() {
+		case cache.ContainerStateCreating, cache.ContainerStateStale:
+			continue
+		}
+		rc, ok := actual[c.GetID()]
+		if !ok {
+			found = append(found, &discrepancy{Kind: staleInCache,
+				Subject: "container " + c.PrettyName()})
+			continue
+		}
+		delete(actual, c.GetID())
+		if state := cache.ContainerState(rc.State); state != c.GetState() {
+			found = append(found, &discrepancy{Kind: stateMismatch,
+				Subject: "container " + c.PrettyName(),
+				Cached:  criapi.ContainerState(c.GetState()).String(), Actual: rc.State.String()})
+		}
+	}
+	for id, rc := range actual {
+		if rc.State == criapi.ContainerState_CONTAINER_RUNNING {
+			found = append(found, &discrepancy{Kind: missingInCache, Subject: "container " + id})
+		}
+	}
+
+	return found, nil
+}
+
+// checkKernelConsistency compares the cpusets and resctrl groups of running containers against the cache.
+func (m *resmgr) checkKernelConsistency() ([]*discrepancy, []cache.Container) {
+	found := []*discrepancy{}
+	mismatched := []cache.Container{}
+	tasks, classes := resctrlTasks()
+
+	for _, c := range m.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		mismatch := false
+
+		if cached := c.GetCpusetCpus(); cached != "" {
+			if actual, _, err := getContainerCpuset(c.GetID()); err == nil {
+				if !sameCPUs(cached, actual) {
+					found = append(found, &discrepancy{Kind: cpusetMismatch,
+						Subject: "container " + c.PrettyName(), Cached: cached, Actual: actual})
+					mismatch = true
+				}
+			}
+		}
+
+		// classes without a resctrl group are reported by the RDT controller
+		if class := c.GetRDTClass(); class != "" && classes[class] {
+			if pids, err := getContainerPids(c.GetID()); err == nil {
+				for _, pid := range pids {
+					actual, ok := tasks[pid]
+					if ok && actual == class {
+						continue
+					}
+					if !ok {
+						actual = "no class"
+					}
+					found = append(found, &discrepancy{Kind: resctrlMismatch,
+						Subject: "container " + c.PrettyName(), Cached: class,
+						Actual: "task " + pid + " in " + actual})
+					mismatch = true
+					break
+				}
+			}
+		}
+
+		if mismatch {
+			mismatched = append(mismatched, c)
+		}
+	}
+
+	return found, mismatched
+}
+
+// repairRuntimeConsistency resynchronizes the cache and the policy with the runtime.
+func (m *resmgr) repairRuntimeConsistency(ctx context.Context) error {
+	add, del, err := m.syncWithCRI(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.policy.Sync(add, del); err != nil {
+		return resmgrError("failed to resynchronize policy: %v", err)
+	}
+	return m.queuePostReleaseHooks("consistency")
+}
+
+// markRepaired marks discrepancies repaired, unless they still remain after the repair.
+func markRepaired(discrepancies, remaining []*discrepancy) {
+	left := map[string]struct{}{}
+	for _, d := range remaining {
+		left[d.Kind+"/"+d.Subject] = struct{}{}
+	}
+	for _, d := range discrepancies {
+		if _, ok := left[d.Kind+"/"+d.Subject]; !ok {
+			d.Repaired = true
+		}
+	}
+}
+
+// sameCPUs checks if two cpuset strings contain the same CPUs.
+func sameCPUs(a, b string) bool {
+	ca, err := cpuset.Parse(a)
+	if err != nil {
+		return a == b
+	}
+	cb, err := cpuset.Parse(b)
+	if err != nil {
+		return a == b
+	}
+	return ca.Equals(cb)
+}
+
+// resctrlTasks returns the RDT classes of tasks and the classes with a resctrl group.
+//
+// Tasks of the root resctrl group are in the SYSTEM_DEFAULT class, and tasks
+// of the memory bandwidth limited groups of a class in the class itself. Tasks
+// of resctrl groups not created by us are in no class. Nil maps are returned if
+// resctrl is not available.
+func resctrlTasks() (map[string]string, map[string]bool) {
+	path, err := resctrlMountPath()
+	if err != nil {
+		return nil, nil
+	}
+	dirs, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, nil
+	}
+
+	groups := map[string]string{"": resctrlRootClass}
+	for _, dir := range dirs {
+		if !dir.IsDir() || !strings.HasPrefix(dir.Name(), resctrlGroupPrefix) {
+			continue
+		}
+		class := strings.TrimPrefix(dir.Name(), resctrlGroupPrefix)
+		if idx := strings.LastIndex(class, resctrlMBLimitSeparator); idx > 0 {
+			class = class[:idx]
+		}
+		groups[dir.Name()] = class
+	}
+
+	tasks := map[string]string{}
+	classes := map[string]bool{}
+	for dir, class := range groups {
+		data, err := ioutil.ReadFile(filepath.Join(path, dir, "tasks"))
+		if err != nil {
+			continue
+		}
+		classes[class] = true
+		for _, pid := range strings.Fields(string(data)) {
+			tasks[pid] = class
+		}
+	}
+	return tasks, classes
+}
+
+// consistencyMetricsCollector exports the results of consistency checks.
+type consistencyMetricsCollector struct {
+	discrepancies *prometheus.GaugeVec
+	repairs       *prometheus.CounterVec
+}
+
+// Our consistency metrics collector.
+var consistencyMetrics = &consistencyMetricsCollector{
+	discrepancies: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cri_resmgr_consistency_discrepancies",
+			Help: "Number of discrepancies found by the last consistency check, by kind.",
+		},
+		[]string{"kind"},
+	),
+	repairs: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cri_resmgr_consistency_repairs_total",
+			Help: "Number of repaired discrepancies, by kind.",
+		},
+		[]string{"kind"},
+	),
+}
+
+// update updates the discrepancy metrics with the results of a check.
+func (c *consistencyMetricsCollector) update(discrepancies []*discrepancy) {
+	counts := map[string]float64{
+		missingInCache:  0,
+		staleInCache:    0,
+		stateMismatch:   0,
+		cpusetMismatch:  0,
+		resctrlMismatch: 0,
+	}
+	for _, d := range discrepancies {
+		counts[d.Kind]++
+	}
+	for kind, count := range counts {
+		c.discrepancies.WithLabelValues(kind).Set(count)
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *consistencyMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.discrepancies.Describe(ch)
+	c.repairs.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *consistencyMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.discrepancies.Collect(ch)
+	c.repairs.Collect(ch)
+}
+
+func init() {
+	err := metrics.RegisterCollector("consistency", func() (prometheus.Collector, error) {
+		return consistencyMetrics, nil
+	})
+	if err != nil {
+		logger.Get("resource-manager").Error("failed to register consistency metrics collector: %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mkResctrlGroup creates a resctrl group directory with the given tasks.
+func mkResctrlGroup(t *testing.T, path, tasks string) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "tasks"), []byte(tasks), 0644); err != nil {
+		t.Fatalf("failed to create tasks of %s: %v", path, err)
+	}
+}
+
+// setupResctrl sets up a fake resctrl filesystem with a group per class and some tasks.
+func setupResctrl(t *testing.T) func() {
+	root, err := ioutil.TempDir("", "consistency-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	mkResctrlGroup(t, root, "1\n2\n")
+	mkResctrlGroup(t, filepath.Join(root, "cri-resmgr.Guaranteed"), "10\n11\n")
+	mkResctrlGroup(t, filepath.Join(root, "cri-resmgr.Burstable"), "20\n")
+	mkResctrlGroup(t, filepath.Join(root, "cri-resmgr.Burstable.mb-10pct"), "21\n")
+	mkResctrlGroup(t, filepath.Join(root, "foreign"), "30\n")
+
+	saved := resctrlMountPath
+	resctrlMountPath = func() (string, error) { return root, nil }
+	return func() {
+		resctrlMountPath = saved
+		os.RemoveAll(root)
+	}
+}
+
+func TestResctrlTasks(t *testing.T) {
+	defer setupResctrl(t)()
+
+	tasks, classes := resctrlTasks()
+
+	expected := map[string]string{
+		"1":  "SYSTEM_DEFAULT",
+		"2":  "SYSTEM_DEFAULT",
+		"10": "Guaranteed",
+		"11": "Guaranteed",
+		"20": "Burstable",
+		"21": "Burstable",
+	}
+	if len(tasks) != len(expected) {
+		t.Errorf("expected tasks %v, got %v", expected, tasks)
+	}
+	for pid, class := range expected {
+		if tasks[pid] != class {
+			t.Errorf("expected task %s in class %q, got %q", pid, class, tasks[pid])
+		}
+	}
+	for _, class := range []string{"SYSTEM_DEFAULT", "Guaranteed", "Burstable"} {
+		if !classes[class] {
+			t.Errorf("expected class %s to be found, got %v", class, classes)
+		}
+	}
+	if len(classes) != 3 {
+		t.Errorf("expected 3 classes, got %v", classes)
+	}
+}
+
+func TestCheckKernelConsistency(t *testing.T) {
+	defer setupResctrl(t)()
+
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+
+	savedCpuset, savedPids := getContainerCpuset, getContainerPids
+	defer func() { getContainerCpuset, getContainerPids = savedCpuset, savedPids }()
+
+	tcs := []struct {
+		name     string
+		class    string
+		cpus     string
+		actual   string
+		pids     []string
+		kinds    []string
+		mismatch bool
+	}{
+		{name: "consistent", class: "Guaranteed", cpus: "0-1", actual: "0,1", pids: []string{"10", "11"}},
+		{name: "root class", class: "SYSTEM_DEFAULT", pids: []string{"1", "2"}},
+		{name: "limited group", class: "Burstable", pids: []string{"20", "21"}},
+		{name: "unknown class", class: "BestEffort", pids: []string{"1"}},
+		{name: "cpuset", cpus: "0-1", actual: "0-3", kinds: []string{cpusetMismatch}, mismatch: true},
+		{name: "wrong class", class: "Guaranteed", pids: []string{"10", "20"},
+			kinds: []string{resctrlMismatch}, mismatch: true},
+		{name: "foreign group", class: "Burstable", pids: []string{"30"},
+			kinds: []string{resctrlMismatch}, mismatch: true},
+		{name: "both", class: "SYSTEM_DEFAULT", cpus: "2", actual: "3", pids: []string{"10"},
+			kinds: []string{cpusetMismatch, resctrlMismatch}, mismatch: true},
+	}
+
+	for i, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			c := createInstance(t, m, cfg, uint32(i))
+			defer m.cache.DeleteContainer(c.GetCacheID())
+			c.SetCpusetCpus(tc.cpus)
+			c.SetRDTClass(tc.class)
+
+			getContainerCpuset = func(id string) (string, string, error) {
+				if id != c.GetID() || tc.actual == "" {
+					return "", "", fmt.Errorf("no cpuset for %s", id)
+				}
+				return tc.actual, "", nil
+			}
+			getContainerPids = func(id string) ([]string, error) {
+				if id != c.GetID() {
+					return nil, fmt.Errorf("no processes for %s", id)
+				}
+				return tc.pids, nil
+			}
+
+			found, mismatched := m.checkKernelConsistency()
+			if len(found) != len(tc.kinds) {
+				t.Fatalf("expected discrepancies %v, got %d", tc.kinds, len(found))
+			}
+			for j, d := range found {
+				if d.Kind != tc.kinds[j] {
+					t.Errorf("expected discrepancy %s, got %s (%s)", tc.kinds[j], d.Kind, d.Actual)
+				}
+			}
+			if (len(mismatched) > 0) != tc.mismatch {
+				t.Errorf("expected mismatch %v, got %d mismatched containers", tc.mismatch,
+					len(mismatched))
+			}
+		})
+	}
+}
+
+func TestMarkRepaired(t *testing.T) {
+	found := []*discrepancy{
+		{Kind: cpusetMismatch, Subject: "container a"},
+		{Kind: resctrlMismatch, Subject: "container a"},
+		{Kind: cpusetMismatch, Subject: "container b"},
+	}
+	remaining := []*discrepancy{
+		{Kind: resctrlMismatch, Subject: "container a"},
+	}
+
+	markRepaired(found, remaining)
+
+	for i, repaired := range []bool{true, false, true} {
+		if found[i].Repaired != repaired {
+			t.Errorf("expected %s %s repaired %v, got %v", found[i].Kind, found[i].Subject,
+				repaired, found[i].Repaired)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/stats"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+// Our collector of container cgroup statistics, provided to policies.
+var containerStats = stats.NewCollector(cgroupRoot)
+
+// sampleStats takes a new sample of the cgroup statistics of all containers.
+//
+// Only the containers to sample are listed with the resource manager locked.
+// Their cgroups are read without holding the lock, not to block CRI requests.
+func (m *resmgr) sampleStats() {
+	m.Lock()
+	targets := stats.Targets(m.cache.GetContainers())
+	m.Unlock()
+
+	containerStats.Sample(targets)
+}
+
+func init() {
+	err := metrics.RegisterCollector("container-stats", func() (prometheus.Collector, error) {
+		return containerStats, nil
+	})
+	if err != nil {
+		logger.Get("resource-manager").Error("failed to register container stats collector: %v", err)
+	}
+}
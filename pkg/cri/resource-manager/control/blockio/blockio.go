@@ -16,23 +16,49 @@ package blockio
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
 	"github.com/intel/cri-resource-manager/pkg/config"
 	"github.com/intel/cri-resource-manager/pkg/cri/client"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
-	"github.com/intel/cri-resource-manager/pkg/utils"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 )
 
 const (
 	// BlockIOController is the name of the block I/O controller.
 	BlockIOController = cache.BlockIO
+	// cgroupRoot is the mount point of the cgroup hierarchies.
+	cgroupRoot = "/sys/fs/cgroup"
+	// blkioDir is the cgroup v1 blkio hierarchy, relative to cgroupRoot.
+	blkioDir = "blkio"
+	// ioMax is the cgroup v2 I/O throttling control.
+	ioMax = "io.max"
 )
 
 // blockio encapsulates the runtime state of our block I/O enforcment/controller.
 type blockio struct {
-	cache cache.Cache // resource manager cache
+	cache     cache.Cache                      // resource manager cache
+	root      string                           // cgroup mount point
+	v2        bool                             // whether the unified cgroup v2 hierarchy is in use
+	devices   []*system.BlockDevice            // discovered block devices
+	classDevs map[string][]*system.BlockDevice // block devices of classes
+	limited   map[string][]*system.BlockDevice // throttled devices, by container cache ID
+}
+
+// throttleFiles are the cgroup v1 blkio throttling controls, with the io.max key of the same limit.
+var throttleFiles = []struct {
+	key  string
+	file string
+}{
+	{"rbps", "blkio.throttle.read_bps_device"},
+	{"wbps", "blkio.throttle.write_bps_device"},
+	{"riops", "blkio.throttle.read_iops_device"},
+	{"wiops", "blkio.throttle.write_iops_device"},
 }
 
 // Our singleton block I/O controller instance.
@@ -41,18 +67,44 @@ var singleton *blockio
 // Our logger instance.
 var log logger.Logger = logger.NewLogger(BlockIOController)
 
+// writeEntry writes a cgroup entry, overridable for testing.
+var writeEntry = func(path, value string) error {
+	return ioutil.WriteFile(path, []byte(value), 0644)
+}
+
+// resolveCgroupPath resolves the cgroup directory of a container, overridable for testing.
+var resolveCgroupPath = cgroups.ResolveCgroupPath
+
 // getBlockIOController returns our singleton block I/O controller instance.
 func getBlockIOController() control.Controller {
 	if singleton == nil {
-		singleton = &blockio{}
+		singleton = &blockio{
+			root:    cgroupRoot,
+			limited: make(map[string][]*system.BlockDevice),
+		}
 	}
 	return singleton
 }
 
 // Start initializes the controller for enforcing decisions.
 func (ctl *blockio) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(BlockIOController); err != nil {
+		return err
+	}
+
+	_, err := os.Stat(filepath.Join(ctl.root, "cgroup.controllers"))
+	ctl.v2 = err == nil
 	ctl.cache = cache
 
+	if err := ctl.discoverDevices(); err != nil {
+		return err
+	}
+	classDevs, err := ctl.resolveDevices(opt)
+	if err != nil {
+		return err
+	}
+	ctl.classDevs = classDevs
+
 	return nil
 }
 
@@ -70,6 +122,19 @@ func (ctl *blockio) PreStartHook(c cache.Container) error {
 	return nil
 }
 
+// FenceStartHook checks that the block I/O class of a container about to start has its devices.
+func (ctl *blockio) FenceStartHook(c cache.Container) error {
+	class := ctl.BlockIOClass(c)
+	if _, ok := opt.ClassDevices[class]; !ok {
+		return nil
+	}
+	if len(ctl.ClassDevices(class)) == 0 {
+		return blockioError("no devices found for block I/O class %s of container %s",
+			class, c.PrettyName())
+	}
+	return nil
+}
+
 // PostStartHook is the block I/O controller post-start hook.
 func (ctl *blockio) PostStartHook(c cache.Container) error {
 	// Notes:
@@ -99,27 +164,55 @@ func (ctl *blockio) PostUpdateHook(c cache.Container) error {
 
 // PostStop is the block I/O controller post-stop hook.
 func (ctl *blockio) PostStopHook(c cache.Container) error {
+	delete(ctl.limited, c.GetCacheID())
 	return nil
 }
 
 // assign assigns the container to the given block I/O class.
+//
+// The limits of the class are applied to the cgroup of the container for each
+// device of the class. Limits of devices the container was throttled on before,
+// but which are not among the devices of the class, are lifted.
 func (ctl *blockio) assign(c cache.Container, class string) error {
 	if class == "" {
 		return nil
 	}
 
-	pod, ok := c.GetPod()
-	if !ok {
-		return blockioError("failed to get Pod for %s", c.PrettyName())
+	limits := opt.ClassLimits[class]
+	devices := ctl.ClassDevices(class)
+	if limits == nil {
+		devices = nil
 	}
+	previous := ctl.limited[c.GetCacheID()]
 
-	pids, err := utils.GetProcessInContainer(pod.GetCgroupParentDir(), c.GetID())
-	if err != nil {
-		return blockioError("failed to get process list of %s: %v", c.PrettyName(), err)
+	if len(devices) > 0 || len(previous) > 0 {
+		dir, err := ctl.cgroupDir(c)
+		if err != nil {
+			return blockioError("failed to find cgroup directory of %s: %v", c.PrettyName(), err)
+		}
+		for _, dev := range previous {
+			if hasDevice(devices, dev) {
+				continue
+			}
+			if err := ctl.throttle(dir, dev, nil); err != nil {
+				return blockioError("%s: failed to lift limits on device %s: %v",
+					c.PrettyName(), dev.Name, err)
+			}
+			log.Debug("  - lifted block I/O limits of %s on device %s", c.PrettyName(), dev.Name)
+		}
+		for _, dev := range devices {
+			if err := ctl.throttle(dir, dev, limits); err != nil {
+				return blockioError("%s: failed to set limits of class %s on device %s: %v",
+					c.PrettyName(), class, dev.Name, err)
+			}
+			log.Debug("  - block I/O class %s applied to device %s", class, dev)
+		}
 	}
 
-	for _, pid := range pids {
-		log.Info(" *** should assign pid %s to block I/O class %s...", pid, class)
+	if len(devices) > 0 {
+		ctl.limited[c.GetCacheID()] = devices
+	} else {
+		delete(ctl.limited, c.GetCacheID())
 	}
 
 	log.Info("container %s assigned to class %s", c.PrettyName(), class)
@@ -127,10 +220,71 @@ func (ctl *blockio) assign(c cache.Container, class string) error {
 	return nil
 }
 
+// cgroupDir returns the blkio (v1) or unified (v2) cgroup directory of a container.
+func (ctl *blockio) cgroupDir(c cache.Container) (string, error) {
+	if ctl.v2 {
+		return resolveCgroupPath(ctl.root, c.GetID())
+	}
+	return resolveCgroupPath(filepath.Join(ctl.root, blkioDir), c.GetID())
+}
+
+// throttle sets the throttling limits of a cgroup on a device, nil limits lifting them.
+func (ctl *blockio) throttle(dir string, dev *system.BlockDevice, limits *classLimits) error {
+	if limits == nil {
+		limits = &classLimits{}
+	}
+	values := map[string]uint64{
+		"rbps":  limits.ReadBps,
+		"wbps":  limits.WriteBps,
+		"riops": limits.ReadIOPS,
+		"wiops": limits.WriteIOPS,
+	}
+
+	if ctl.v2 {
+		line := dev.DevNode()
+		for _, t := range throttleFiles {
+			value := "max"
+			if v := values[t.key]; v > 0 {
+				value = fmt.Sprintf("%d", v)
+			}
+			line += " " + t.key + "=" + value
+		}
+		return writeEntry(filepath.Join(dir, ioMax), line)
+	}
+
+	// in cgroup v1 a zero limit removes the rule for the device
+	for _, t := range throttleFiles {
+		value := fmt.Sprintf("%s %d", dev.DevNode(), values[t.key])
+		if err := writeEntry(filepath.Join(dir, t.file), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasDevice checks if a device is among the given ones.
+func hasDevice(devices []*system.BlockDevice, dev *system.BlockDevice) bool {
+	for _, d := range devices {
+		if d.Major == dev.Major && d.Minor == dev.Minor {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerClass returns the effective block I/O class of a container.
+func (ctl *blockio) ContainerClass(c cache.Container) string {
+	return ctl.BlockIOClass(c)
+}
+
 // BlockIOClass determines the effective block I/O class for a container.
 func (ctl *blockio) BlockIOClass(c cache.Container) string {
 	cclass := c.GetBlockIOClass()
 	if cclass == "" {
+		if rc, ok := control.GetClassManager().ResourceClass(c); ok && rc.BlockIO != "" {
+			log.Debug("block I/O class for %s (resource class): %q", c.PrettyName(), rc.BlockIO)
+			return rc.BlockIO
+		}
 		cclass = string(c.GetQOSClass())
 	}
 
@@ -149,6 +303,25 @@ func (ctl *blockio) BlockIOClass(c cache.Container) string {
 // configNotify is our runtime configuration notification callback.
 func (ctl *blockio) configNotify(event config.Event, source config.Source) error {
 	log.Info("configuration updated")
+
+	if ctl.devices == nil {
+		return nil
+	}
+	classDevs, err := ctl.resolveDevices(opt)
+	if err != nil {
+		return err
+	}
+	ctl.classDevs = classDevs
+
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.assign(c, ctl.BlockIOClass(c)); err != nil {
+			log.Error("failed to update block I/O limits of %s: %v", c.PrettyName(), err)
+		}
+	}
+
 	return nil
 }
 
@@ -160,4 +333,5 @@ func blockioError(format string, args ...interface{}) error {
 // Register us as a controller.
 func init() {
 	control.Register(BlockIOController, "Block I/O controller", getBlockIOController())
+	control.GetClassManager().AddNotify(getBlockIOController().(*blockio).configNotify)
 }
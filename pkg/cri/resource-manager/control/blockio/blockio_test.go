@@ -0,0 +1,204 @@
+// Copyright 2019 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockio
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// fakeContainer is a container with only its identity set.
+type fakeContainer struct {
+	cache.Container
+	id string
+}
+
+func (c *fakeContainer) GetID() string                  { return c.id }
+func (c *fakeContainer) GetCacheID() string             { return "cache-" + c.id }
+func (c *fakeContainer) PrettyName() string             { return "pod/" + c.id }
+func (c *fakeContainer) GetState() cache.ContainerState { return cache.ContainerStateRunning }
+
+// recordWrites overrides cgroup writes and resolution, recording the written entries.
+func recordWrites() (map[string][]string, func()) {
+	writes := map[string][]string{}
+	savedWrite, savedResolve := writeEntry, resolveCgroupPath
+	writeEntry = func(path, value string) error {
+		writes[path] = append(writes[path], value)
+		return nil
+	}
+	resolveCgroupPath = func(hierarchyDir, containerID string) (string, error) {
+		return filepath.Join(hierarchyDir, "kubepods", containerID), nil
+	}
+	return writes, func() { writeEntry, resolveCgroupPath = savedWrite, savedResolve }
+}
+
+func TestThrottle(t *testing.T) {
+	writes, restore := recordWrites()
+	defer restore()
+
+	dev := &system.BlockDevice{Name: "sda", Major: 8, Minor: 0}
+	limits := &classLimits{ReadBps: 1048576, WriteIOPS: 100}
+
+	tcases := []struct {
+		name     string
+		v2       bool
+		limits   *classLimits
+		expected map[string]string
+	}{
+		{
+			name:   "cgroup v1 limits",
+			limits: limits,
+			expected: map[string]string{
+				"blkio.throttle.read_bps_device":   "8:0 1048576",
+				"blkio.throttle.write_bps_device":  "8:0 0",
+				"blkio.throttle.read_iops_device":  "8:0 0",
+				"blkio.throttle.write_iops_device": "8:0 100",
+			},
+		},
+		{
+			name: "cgroup v1 lifted limits",
+			expected: map[string]string{
+				"blkio.throttle.read_bps_device":   "8:0 0",
+				"blkio.throttle.write_bps_device":  "8:0 0",
+				"blkio.throttle.read_iops_device":  "8:0 0",
+				"blkio.throttle.write_iops_device": "8:0 0",
+			},
+		},
+		{
+			name:   "cgroup v2 limits",
+			v2:     true,
+			limits: limits,
+			expected: map[string]string{
+				"io.max": "8:0 rbps=1048576 wbps=max riops=max wiops=100",
+			},
+		},
+		{
+			name: "cgroup v2 lifted limits",
+			v2:   true,
+			expected: map[string]string{
+				"io.max": "8:0 rbps=max wbps=max riops=max wiops=max",
+			},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			for path := range writes {
+				delete(writes, path)
+			}
+			ctl := &blockio{v2: tc.v2}
+			if err := ctl.throttle("/cgroup", dev, tc.limits); err != nil {
+				t.Fatalf("failed to set limits: %v", err)
+			}
+			if len(writes) != len(tc.expected) {
+				t.Errorf("expected %d entries written, got %v", len(tc.expected), writes)
+			}
+			for file, value := range tc.expected {
+				path := filepath.Join("/cgroup", file)
+				if len(writes[path]) != 1 || writes[path][0] != value {
+					t.Errorf("expected %q written to %s, got %v", value, file, writes[path])
+				}
+			}
+		})
+	}
+}
+
+func TestAssign(t *testing.T) {
+	writes, restore := recordWrites()
+	defer restore()
+
+	saved := opt
+	defer func() { opt = saved }()
+	opt = &options{
+		ClassLimits: map[string]*classLimits{
+			"fast": {ReadBps: 1000},
+			"slow": {ReadBps: 100},
+		},
+	}
+
+	devices := testDevices()
+	ctl := &blockio{
+		root: "/cgroup",
+		v2:   true,
+		classDevs: map[string][]*system.BlockDevice{
+			"fast":      devices[0:2],
+			"slow":      devices[1:3],
+			"unlimited": devices,
+		},
+		limited: make(map[string][]*system.BlockDevice),
+	}
+	c := &fakeContainer{id: "ctr"}
+	ioMaxPath := "/cgroup/kubepods/ctr/io.max"
+
+	if err := ctl.assign(c, "fast"); err != nil {
+		t.Fatalf("failed to assign class: %v", err)
+	}
+	expected := []string{
+		"259:0 rbps=1000 wbps=max riops=max wiops=max",
+		"259:1 rbps=1000 wbps=max riops=max wiops=max",
+	}
+	checkWrites(t, writes[ioMaxPath], expected)
+
+	// nvme0n1 is lifted, nvme1n1 and sda are limited
+	delete(writes, ioMaxPath)
+	if err := ctl.assign(c, "slow"); err != nil {
+		t.Fatalf("failed to assign class: %v", err)
+	}
+	expected = []string{
+		"259:0 rbps=max wbps=max riops=max wiops=max",
+		"259:1 rbps=100 wbps=max riops=max wiops=max",
+		"8:0 rbps=100 wbps=max riops=max wiops=max",
+	}
+	checkWrites(t, writes[ioMaxPath], expected)
+
+	// a class without limits lifts all of them
+	delete(writes, ioMaxPath)
+	if err := ctl.assign(c, "unlimited"); err != nil {
+		t.Fatalf("failed to assign class: %v", err)
+	}
+	expected = []string{
+		"259:1 rbps=max wbps=max riops=max wiops=max",
+		"8:0 rbps=max wbps=max riops=max wiops=max",
+	}
+	checkWrites(t, writes[ioMaxPath], expected)
+	if _, ok := ctl.limited[c.GetCacheID()]; ok {
+		t.Errorf("expected no limited devices left for %s", c.PrettyName())
+	}
+
+	// nothing to write for an unlimited container
+	delete(writes, ioMaxPath)
+	if err := ctl.assign(c, "unlimited"); err != nil {
+		t.Fatalf("failed to assign class: %v", err)
+	}
+	if len(writes) != 0 {
+		t.Errorf("expected no writes, got %v", writes)
+	}
+}
+
+func checkWrites(t *testing.T, writes, expected []string) {
+	t.Helper()
+	if len(writes) != len(expected) {
+		t.Errorf("expected writes %q, got %q", expected, writes)
+		return
+	}
+	for i := range writes {
+		if writes[i] != expected[i] {
+			t.Errorf("expected writes %q, got %q", expected, writes)
+			return
+		}
+	}
+}
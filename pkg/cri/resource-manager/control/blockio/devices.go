@@ -0,0 +1,136 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockio
+
+import (
+	"path/filepath"
+	"strings"
+
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// deviceSelector selects block devices by their properties.
+//
+// Name, Type and Model are shell glob patterns, an empty pattern matching any
+// device. If NUMANode is set, only devices attached to that node are selected.
+type deviceSelector struct {
+	// Name is a pattern for the kernel name of the device, for instance sd*.
+	Name string `json:",omitempty"`
+	// Type is a pattern for the device type: nvme, sata, scsi, virtio, or other.
+	Type string `json:",omitempty"`
+	// Model is a pattern for the device model.
+	Model string `json:",omitempty"`
+	// NUMANode is the NUMA node the device is attached to.
+	NUMANode *int `json:",omitempty"`
+}
+
+// validate checks the patterns of a device selector.
+func (sel *deviceSelector) validate() error {
+	for _, pattern := range []string{sel.Name, sel.Type, sel.Model} {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return blockioError("invalid device pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matches checks if a device is selected by the selector.
+func (sel *deviceSelector) matches(dev *system.BlockDevice) bool {
+	if !globMatch(sel.Name, dev.Name) {
+		return false
+	}
+	if !globMatch(sel.Type, string(dev.Type)) {
+		return false
+	}
+	if !globMatch(sel.Model, dev.Model) {
+		return false
+	}
+	if sel.NUMANode != nil && system.ID(*sel.NUMANode) != dev.Node {
+		return false
+	}
+	return true
+}
+
+// globMatch matches a value against an optional glob pattern.
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, value)
+	return ok
+}
+
+// discoverDevices discovers the block devices of the system.
+func (ctl *blockio) discoverDevices() error {
+	sys, err := system.DiscoverSystem(system.DiscoverBlockDevices)
+	if err != nil {
+		return blockioError("failed to discover block devices: %v", err)
+	}
+
+	ctl.devices = sys.BlockDevices()
+	for _, dev := range ctl.devices {
+		log.Info("discovered block device %s", dev)
+	}
+
+	return nil
+}
+
+// resolveDevices resolves the device groups of all block I/O classes.
+func (ctl *blockio) resolveDevices(o *options) (map[string][]*system.BlockDevice, error) {
+	for group, selectors := range o.DeviceGroups {
+		for _, sel := range selectors {
+			if err := sel.validate(); err != nil {
+				return nil, blockioError("device group %s: %v", group, err)
+			}
+		}
+	}
+
+	resolved := make(map[string][]*system.BlockDevice)
+	for class, groups := range o.ClassDevices {
+		devices := []*system.BlockDevice{}
+		seen := map[string]struct{}{}
+		for _, group := range groups {
+			selectors, ok := o.DeviceGroups[group]
+			if !ok {
+				return nil, blockioError("class %s refers to unknown device group %s",
+					class, group)
+			}
+			for _, dev := range ctl.devices {
+				if _, ok := seen[dev.Name]; ok {
+					continue
+				}
+				for _, sel := range selectors {
+					if sel.matches(dev) {
+						devices = append(devices, dev)
+						seen[dev.Name] = struct{}{}
+						break
+					}
+				}
+			}
+		}
+		if len(devices) == 0 {
+			log.Warn("no block devices found for class %s (device groups %s)",
+				class, strings.Join(groups, ", "))
+		}
+		resolved[class] = devices
+	}
+
+	return resolved, nil
+}
+
+// ClassDevices returns the block devices a block I/O class applies to.
+func (ctl *blockio) ClassDevices(class string) []*system.BlockDevice {
+	return ctl.classDevs[class]
+}
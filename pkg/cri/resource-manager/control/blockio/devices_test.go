@@ -0,0 +1,191 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockio
+
+import (
+	"testing"
+
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// testDevices returns a set of block devices for testing.
+func testDevices() []*system.BlockDevice {
+	return []*system.BlockDevice{
+		{Name: "nvme0n1", Major: 259, Minor: 0, Node: 0, Type: system.BlockDeviceNVMe, Model: "Fast NVMe"},
+		{Name: "nvme1n1", Major: 259, Minor: 1, Node: 1, Type: system.BlockDeviceNVMe, Model: "Fast NVMe"},
+		{Name: "sda", Major: 8, Minor: 0, Node: -1, Type: system.BlockDeviceSATA, Model: "ST1000DM003"},
+		{Name: "sdb", Major: 8, Minor: 16, Node: 0, Type: system.BlockDeviceSCSI, Model: "ST600MM0006"},
+		{Name: "vda", Major: 252, Minor: 0, Node: -1, Type: system.BlockDeviceVirtio},
+	}
+}
+
+func node(id int) *int {
+	return &id
+}
+
+func TestDeviceSelector(t *testing.T) {
+	tcases := []struct {
+		name     string
+		selector deviceSelector
+		expected []string
+	}{
+		{
+			name:     "empty selector matches all",
+			expected: []string{"nvme0n1", "nvme1n1", "sda", "sdb", "vda"},
+		},
+		{
+			name:     "by type",
+			selector: deviceSelector{Type: "nvme"},
+			expected: []string{"nvme0n1", "nvme1n1"},
+		},
+		{
+			name:     "by name glob",
+			selector: deviceSelector{Name: "sd*"},
+			expected: []string{"sda", "sdb"},
+		},
+		{
+			name:     "by model glob",
+			selector: deviceSelector{Model: "ST*"},
+			expected: []string{"sda", "sdb"},
+		},
+		{
+			name:     "by type and model",
+			selector: deviceSelector{Type: "scsi", Model: "ST*"},
+			expected: []string{"sdb"},
+		},
+		{
+			name:     "by NUMA node",
+			selector: deviceSelector{NUMANode: node(0)},
+			expected: []string{"nvme0n1", "sdb"},
+		},
+		{
+			name:     "by type and NUMA node",
+			selector: deviceSelector{Type: "nvme", NUMANode: node(1)},
+			expected: []string{"nvme1n1"},
+		},
+		{
+			name:     "no match",
+			selector: deviceSelector{Type: "virtio", Model: "ST*"},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			matched := []string{}
+			for _, dev := range testDevices() {
+				if tc.selector.matches(dev) {
+					matched = append(matched, dev.Name)
+				}
+			}
+			if len(matched) != len(tc.expected) {
+				t.Fatalf("expected devices %v, got %v", tc.expected, matched)
+			}
+			for i := range matched {
+				if matched[i] != tc.expected[i] {
+					t.Errorf("expected devices %v, got %v", tc.expected, matched)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestResolveDevices(t *testing.T) {
+	tcases := []struct {
+		name     string
+		groups   map[string][]*deviceSelector
+		classes  map[string][]string
+		expected map[string][]string
+		invalid  bool
+	}{
+		{
+			name: "classes with overlapping groups",
+			groups: map[string][]*deviceSelector{
+				"fast":  {{Type: "nvme"}},
+				"local": {{NUMANode: node(0)}},
+				"slow":  {{Type: "sata"}, {Type: "scsi", Model: "ST*"}},
+			},
+			classes: map[string][]string{
+				"class1": {"fast"},
+				"class2": {"fast", "local", "slow"},
+				"class3": {"slow"},
+			},
+			expected: map[string][]string{
+				"class1": {"nvme0n1", "nvme1n1"},
+				"class2": {"nvme0n1", "nvme1n1", "sdb", "sda"},
+				"class3": {"sda", "sdb"},
+			},
+		},
+		{
+			name: "class without devices",
+			groups: map[string][]*deviceSelector{
+				"none": {{Type: "other"}},
+			},
+			classes: map[string][]string{
+				"class1": {"none"},
+			},
+			expected: map[string][]string{
+				"class1": {},
+			},
+		},
+		{
+			name: "unknown device group",
+			groups: map[string][]*deviceSelector{
+				"fast": {{Type: "nvme"}},
+			},
+			classes: map[string][]string{
+				"class1": {"fast", "slow"},
+			},
+			invalid: true,
+		},
+		{
+			name: "invalid pattern",
+			groups: map[string][]*deviceSelector{
+				"broken": {{Model: "ST[0-9"}},
+			},
+			invalid: true,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctl := &blockio{devices: testDevices()}
+			resolved, err := ctl.resolveDevices(&options{DeviceGroups: tc.groups, ClassDevices: tc.classes})
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("expected resolving to fail, got %v", resolved)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to resolve devices: %v", err)
+			}
+			if len(resolved) != len(tc.expected) {
+				t.Errorf("expected %d classes, got %d", len(tc.expected), len(resolved))
+			}
+			for class, names := range tc.expected {
+				devices := resolved[class]
+				if len(devices) != len(names) {
+					t.Errorf("expected devices %v for %s, got %v", names, class, devices)
+					continue
+				}
+				for i, dev := range devices {
+					if dev.Name != names[i] {
+						t.Errorf("expected devices %v for %s, got %v", names, class, devices)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -18,10 +18,11 @@ var configHelp = `
 Resource Manager Block I/O enforcement controller.
 
 The Block I/O controller enforces container policy block I/O decisions
-using the blkio cgroup pseudo-filesystem. It takes the assigned block I/O
-class of a container and puts all processes in the containers' cgroup to
-that class. If the container is not assigned to any block I/O class by a
-policy, the block I/O controller will use the QOS class of the container.
+using the blkio (cgroup v1) or io (cgroup v2) cgroup controls. It takes the
+assigned block I/O class of a container and sets the throttling limits of
+that class on the devices of the class in the cgroup of the container. If the
+container is not assigned to any block I/O class by a policy, the block I/O
+controller will use the QOS class of the container.
 
 The controller can be configured to map the containers' assigned class to
 a real block I/O class before the blkio-level assignment takes place.
@@ -35,4 +36,36 @@ Here is a sample configuration fragment to set up a class mapping for the
       Burstable: class2
       BestEffort: class3
       "*": class4
+
+Block I/O classes apply to groups of block devices. Devices are selected
+into named groups by their kernel name, type (nvme, sata, scsi, virtio, or
+other) and model using glob patterns, and optionally by the NUMA node they
+are attached to. This keeps the configuration valid across nodes with
+different device node numbering. Here is a sample fragment defining two
+device groups and the groups each block I/O class applies to.
+
+  blockio:
+    DeviceGroups:
+      fast:
+        - Type: nvme
+          NUMANode: 0
+      slow:
+        - Type: sata
+        - Model: "ST*"
+    ClassDevices:
+      class1: [ fast ]
+      class2: [ fast, slow ]
+
+The throttling limits of each class are given in bytes and operations per
+second, 0 or an omitted limit meaning no limit. The limits apply to each
+device of the class separately. Here is a sample fragment limiting the
+bandwidth and operations of class2.
+
+  blockio:
+    ClassLimits:
+      class2:
+        ReadBps: 104857600
+        WriteBps: 52428800
+        ReadIOPS: 2000
+        WriteIOPS: 1000
 `
@@ -22,6 +22,24 @@ import (
 type options struct {
 	// Class is a assigned to actual RDT class map.
 	Classes map[string]string `json:",omitempty"`
+	// DeviceGroups are named groups of block devices, selected by device properties.
+	DeviceGroups map[string][]*deviceSelector `json:",omitempty"`
+	// ClassDevices lists the device groups each block I/O class applies to.
+	ClassDevices map[string][]string `json:",omitempty"`
+	// ClassLimits are the throttling limits of block I/O classes on their devices.
+	ClassLimits map[string]*classLimits `json:",omitempty"`
+}
+
+// classLimits are the throttling limits of a block I/O class, 0 for no limit.
+type classLimits struct {
+	// ReadBps is the read bandwidth limit, in bytes per second.
+	ReadBps uint64 `json:",omitempty"`
+	// WriteBps is the write bandwidth limit, in bytes per second.
+	WriteBps uint64 `json:",omitempty"`
+	// ReadIOPS is the limit of read operations per second.
+	ReadIOPS uint64 `json:",omitempty"`
+	// WriteIOPS is the limit of write operations per second.
+	WriteIOPS uint64 `json:",omitempty"`
 }
 
 // Our runtime configuration.
@@ -29,11 +47,17 @@ var opt = defaultOptions().(*options)
 
 // defaultOptions returns a new options instance, all initialized to defaults.
 func defaultOptions() interface{} {
-	return &options{Classes: make(map[string]string)}
+	return &options{
+		Classes:      make(map[string]string),
+		DeviceGroups: make(map[string][]*deviceSelector),
+		ClassDevices: make(map[string][]string),
+		ClassLimits:  make(map[string]*classLimits),
+	}
 }
 
 // Register us for configuration handling.
 func init() {
 	config.Register("resource-manager.blockio", configHelp, opt, defaultOptions,
-		config.WithNotify(getBlockIOController().(*blockio).configNotify))
+		config.WithNotify(getBlockIOController().(*blockio).configNotify),
+		config.WithFragment())
 }
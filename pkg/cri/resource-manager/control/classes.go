@@ -0,0 +1,202 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// ResourceClassKey is the annotation key for the unified resource class of a pod.
+	ResourceClassKey = "qos.resources.intel.com/class"
+)
+
+// ResourceClass is a set of per-controller settings selected by a single class name.
+type ResourceClass struct {
+	// RDT is the RDT class of containers in this class.
+	RDT string `json:",omitempty"`
+	// BlockIO is the block I/O class of containers in this class.
+	BlockIO string `json:",omitempty"`
+	// CPU is the CPU (frequency) class of containers in this class.
+	CPU string `json:",omitempty"`
+	// Scheduling is the scheduling class of containers in this class.
+	Scheduling string `json:",omitempty"`
+}
+
+// ClassManager maps unified resource classes to per-controller settings.
+type ClassManager interface {
+	// ClassOf returns the name of the unified resource class of a container, if any.
+	ClassOf(cache.Container) (string, bool)
+	// ResourceClass returns the unified resource class of a container, if any.
+	ResourceClass(cache.Container) (*ResourceClass, bool)
+	// AddNotify registers a callback for updates of the unified resource classes.
+	AddNotify(config.NotifyFn)
+}
+
+// classOptions captures the configuration of unified resource classes.
+type classOptions struct {
+	// Classes maps unified resource class names to per-controller settings.
+	Classes map[string]*ResourceClass `json:",omitempty"`
+	// Default is the class of containers without an annotated class, if any.
+	Default string `json:",omitempty"`
+}
+
+// classManager is our ClassManager implementation.
+type classManager struct {
+	notify []config.NotifyFn // callbacks of controllers using resource classes
+}
+
+// Our unified resource class configuration.
+var classOpt = defaultClassOptions().(*classOptions)
+
+// Our singleton class manager.
+var classes = &classManager{}
+
+// GetClassManager returns the unified resource class manager.
+func GetClassManager() ClassManager {
+	return classes
+}
+
+// ClassOf returns the name of the unified resource class of a container, if any.
+func (m *classManager) ClassOf(c cache.Container) (string, bool) {
+	if pod, ok := c.GetPod(); ok {
+		if value, ok := pod.GetAnnotation(ResourceClassKey); ok {
+			if class, ok := m.parseClass(c, value); ok {
+				return class, true
+			}
+		}
+	}
+
+	if classOpt.Default != "" {
+		return classOpt.Default, true
+	}
+	return "", false
+}
+
+// ResourceClass returns the unified resource class of a container, if any.
+func (m *classManager) ResourceClass(c cache.Container) (*ResourceClass, bool) {
+	name, ok := m.ClassOf(c)
+	if !ok {
+		return nil, false
+	}
+
+	class, ok := classOpt.Classes[name]
+	if !ok {
+		log.Error("%s: ignoring unknown resource class %s", c.PrettyName(), name)
+		return nil, false
+	}
+	return class, true
+}
+
+// AddNotify registers a callback for updates of the unified resource classes.
+//
+// Controllers using unified resource classes register a callback to reapply
+// the classes of their containers, which might have changed with the update.
+func (m *classManager) AddNotify(fn config.NotifyFn) {
+	m.notify = append(m.notify, fn)
+}
+
+// configNotify is our configuration update notification callback.
+func (m *classManager) configNotify(event config.Event, source config.Source) error {
+	log.Info("resource class configuration updated")
+	for _, fn := range m.notify {
+		if err := fn(event, source); err != nil {
+			log.Error("failed to reapply updated resource classes: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseClass parses an annotated class, either for the whole pod or per container.
+func (m *classManager) parseClass(c cache.Container, value string) (string, bool) {
+	class := strings.TrimSpace(value)
+	if strings.Contains(value, ":") {
+		perContainer := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &perContainer); err != nil {
+			log.Error("%s: failed to parse annotation %s = '%s': %v",
+				c.PrettyName(), ResourceClassKey, value, err)
+			return "", false
+		}
+		var ok bool
+		if class, ok = perContainer[c.GetName()]; !ok {
+			return "", false
+		}
+		class = strings.TrimSpace(class)
+	}
+	return class, class != ""
+}
+
+// defaultClassOptions returns a new classOptions instance, all initialized to defaults.
+func defaultClassOptions() interface{} {
+	return &classOptions{Classes: make(map[string]*ResourceClass)}
+}
+
+// validateClassOptions checks the configured unified resource classes.
+func validateClassOptions(cfg interface{}) error {
+	o, ok := cfg.(*classOptions)
+	if !ok {
+		return controlError("invalid resource class configuration type %T", cfg)
+	}
+	for name, class := range o.Classes {
+		if class == nil {
+			return controlError("resource class %s has no settings", name)
+		}
+	}
+	if o.Default != "" {
+		if _, ok := o.Classes[o.Default]; !ok {
+			return controlError("unknown default resource class %s", o.Default)
+		}
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.classes", classesHelp, classOpt, defaultClassOptions,
+		config.WithValidator(validateClassOptions), config.WithNotify(classes.configNotify))
+}
+
+const classesHelp = `
+Unified resource classes.
+
+A unified resource class maps a single class name to the classes of several
+controllers, so that a pod needs only one annotation instead of one for each
+controller, kept in sync. Pods select their class with the annotation
+
+  qos.resources.intel.com/class: <class>
+
+or, per container, with a map of container names to classes. Each class can
+set the RDT, block I/O, CPU and scheduling classes of its containers.
+A class set explicitly by the policy or with a controller-specific annotation
+takes precedence over the one from the unified resource class. Default is the
+class of containers without an annotated class.
+
+Here is a sample configuration fragment defining two classes:
+
+  classes:
+    Classes:
+      gold:
+        RDT: high-prio
+        BlockIO: high-prio
+        CPU: turbo
+      bronze:
+        RDT: low-prio
+        BlockIO: throttled
+`
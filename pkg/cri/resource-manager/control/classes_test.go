@@ -0,0 +1,181 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakePod is a pod with only annotations.
+type fakePod struct {
+	cache.Pod
+	annotations map[string]string
+}
+
+func (p *fakePod) GetAnnotation(key string) (string, bool) {
+	value, ok := p.annotations[key]
+	return value, ok
+}
+
+// classContainer is a named container of a pod, if any.
+type classContainer struct {
+	cache.Container
+	name string
+	pod  *fakePod
+}
+
+func (c *classContainer) GetName() string    { return c.name }
+func (c *classContainer) PrettyName() string { return "pod/" + c.name }
+func (c *classContainer) GetPod() (cache.Pod, bool) {
+	if c.pod == nil {
+		return nil, false
+	}
+	return c.pod, true
+}
+
+// newClassContainer creates a container of a pod with the given class annotation, if any.
+func newClassContainer(name string, annotation ...string) *classContainer {
+	pod := &fakePod{annotations: map[string]string{}}
+	if len(annotation) > 0 {
+		pod.annotations[ResourceClassKey] = annotation[0]
+	}
+	return &classContainer{name: name, pod: pod}
+}
+
+// setClassOptions sets the unified resource class configuration, returning a function to restore it.
+func setClassOptions(o *classOptions) func() {
+	saved := *classOpt
+	*classOpt = *o
+	return func() { *classOpt = saved }
+}
+
+func TestParseClass(t *testing.T) {
+	m := &classManager{}
+	tcs := []struct {
+		name  string
+		value string
+		class string
+		ok    bool
+	}{
+		{name: "pod class", value: "gold", class: "gold", ok: true},
+		{name: "pod class with spaces", value: "  gold\n", class: "gold", ok: true},
+		{name: "empty", value: "  ", ok: false},
+		{name: "per container", value: "ctr: gold\nother: bronze\n", class: "gold", ok: true},
+		{name: "per container, JSON", value: `{"other": "bronze", "ctr": " silver "}`, class: "silver", ok: true},
+		{name: "per container, not listed", value: "other: bronze", ok: false},
+		{name: "per container, empty", value: "ctr: ''", ok: false},
+		{name: "per container, invalid", value: "ctr: [gold", ok: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			class, ok := m.parseClass(newClassContainer("ctr"), tc.value)
+			if ok != tc.ok || class != tc.class {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tc.class, tc.ok, class, ok)
+			}
+		})
+	}
+}
+
+func TestClassOf(t *testing.T) {
+	defer setClassOptions(&classOptions{
+		Classes: map[string]*ResourceClass{
+			"gold":   {RDT: "high-prio", BlockIO: "high-prio"},
+			"bronze": {RDT: "low-prio"},
+		},
+	})()
+
+	m := &classManager{}
+	tcs := []struct {
+		name      string
+		container *classContainer
+		dflt      string
+		class     string
+		ok        bool
+		rdt       string
+	}{
+		{name: "annotated", container: newClassContainer("ctr", "gold"), class: "gold", ok: true,
+			rdt: "high-prio"},
+		{name: "not annotated", container: newClassContainer("ctr")},
+		{name: "not annotated, default", container: newClassContainer("ctr"), dflt: "bronze",
+			class: "bronze", ok: true, rdt: "low-prio"},
+		{name: "annotated for other container, default", container: newClassContainer("ctr", "other: gold"),
+			dflt: "bronze", class: "bronze", ok: true, rdt: "low-prio"},
+		{name: "no pod", container: &classContainer{name: "ctr"}},
+		{name: "unknown class", container: newClassContainer("ctr", "platinum"), class: "platinum", ok: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			classOpt.Default = tc.dflt
+			class, ok := m.ClassOf(tc.container)
+			if ok != tc.ok || class != tc.class {
+				t.Errorf("expected class (%q, %v), got (%q, %v)", tc.class, tc.ok, class, ok)
+			}
+			rc, ok := m.ResourceClass(tc.container)
+			switch {
+			case tc.rdt == "" && ok:
+				t.Errorf("expected no resource class, got %v", *rc)
+			case tc.rdt != "" && !ok:
+				t.Errorf("expected resource class with RDT class %s, got none", tc.rdt)
+			case tc.rdt != "" && rc.RDT != tc.rdt:
+				t.Errorf("expected RDT class %s, got %s", tc.rdt, rc.RDT)
+			}
+		})
+	}
+}
+
+func TestValidateClassOptions(t *testing.T) {
+	gold := &ResourceClass{RDT: "high-prio"}
+	tcs := []struct {
+		name string
+		opts *classOptions
+		err  bool
+	}{
+		{name: "valid", opts: &classOptions{Classes: map[string]*ResourceClass{"gold": gold}, Default: "gold"}},
+		{name: "unknown default", opts: &classOptions{Classes: map[string]*ResourceClass{"gold": gold},
+			Default: "bronze"}, err: true},
+		{name: "empty class", opts: &classOptions{Classes: map[string]*ResourceClass{"gold": nil}}, err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateClassOptions(tc.opts); (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestClassesNotify(t *testing.T) {
+	m := &classManager{}
+	called := []string{}
+	m.AddNotify(func(config.Event, config.Source) error {
+		called = append(called, "failing")
+		return fmt.Errorf("failed")
+	})
+	m.AddNotify(func(config.Event, config.Source) error {
+		called = append(called, "other")
+		return nil
+	})
+
+	if err := m.configNotify(config.UpdateEvent, config.ConfigFile); err != nil {
+		t.Errorf("unexpected notification error: %v", err)
+	}
+	if len(called) != 2 || called[0] != "failing" || called[1] != "other" {
+		t.Errorf("expected all callbacks to be notified, got %v", called)
+	}
+}
@@ -28,16 +28,28 @@ import (
 type Control interface {
 	// StartStopControllers starts/stops all controllers according to configuration.
 	StartStopControllers(cache.Cache, client.Client) error
+	// StopControllers stops all running controllers.
+	StopControllers()
 	// PreCreateHooks runs the pre-create hooks of all registered controllers.
 	RunPreCreateHooks(cache.Container) error
 	// RunPreStartHooks runs the pre-start hooks of all registered controllers.
 	RunPreStartHooks(cache.Container) error
+	// RunFenceStartHooks runs the start fence hooks of all registered controllers.
+	RunFenceStartHooks(cache.Container) error
 	// RunPostStartHooks runs the post-start hooks of all registered controllers.
 	RunPostStartHooks(cache.Container) error
 	// RunPostUpdateHooks runs the post-update hooks of all registered controllers.
 	RunPostUpdateHooks(cache.Container) error
 	// RunPostStopHooks runs the post-stop hooks of all registered controllers.
 	RunPostStopHooks(cache.Container) error
+	// RunPostRunPodHooks runs the post-run pod hooks of all registered controllers.
+	RunPostRunPodHooks(cache.Pod) error
+	// RunPreImagePullHooks runs the pre-pull image hooks of all registered controllers.
+	RunPreImagePullHooks(string) error
+	// RunPostImagePullHooks runs the post-pull image hooks of all registered controllers.
+	RunPostImagePullHooks(string) error
+	// ContainerClasses returns the effective classes of a container, by controller.
+	ContainerClasses(cache.Container) map[string]string
 }
 
 // Controller is the interface all resource controllers must implement.
@@ -58,6 +70,33 @@ type Controller interface {
 	PostStopHook(cache.Container) error
 }
 
+// PodHooks is implemented by controllers which act on the creation of pod sandboxes.
+type PodHooks interface {
+	// PostRunPodHook is the controller's hook for newly created pod sandboxes.
+	PostRunPodHook(cache.Pod) error
+}
+
+// FenceHooks is implemented by controllers which can verify their resource programming.
+type FenceHooks interface {
+	// FenceStartHook checks that all resources of a container about to be started
+	// have been successfully set up by the controller.
+	FenceStartHook(cache.Container) error
+}
+
+// ClassHooks is implemented by controllers which assign containers to classes.
+type ClassHooks interface {
+	// ContainerClass returns the effective class of a container, empty if none.
+	ContainerClass(cache.Container) string
+}
+
+// ImageHooks is implemented by controllers which act on image pulls.
+type ImageHooks interface {
+	// PreImagePullHook is the controller's hook for image pulls about to start.
+	PreImagePullHook(string) error
+	// PostImagePullHook is the controller's hook for finished image pulls.
+	PostImagePullHook(string) error
+}
+
 // control encapsulates our controller-agnostic runtime state.
 type control struct {
 	cache       cache.Cache   // resource manager cache
@@ -78,9 +117,13 @@ type controller struct {
 const (
 	precreate  = "pre-create"
 	prestart   = "pre-start"
+	fencestart = "fence-start"
 	poststart  = "post-start"
 	postupdate = "post-update"
 	poststop   = "post-stop"
+	postrunpod = "post-run-pod"
+	prepull    = "pre-image-pull"
+	postpull   = "post-image-pull"
 )
 
 // All registered controllers.
@@ -155,6 +198,17 @@ func (c *control) StartStopControllers(cache cache.Cache, client client.Client)
 	return nil
 }
 
+// StopControllers stops all running controllers, for instance when shutting down.
+func (c *control) StopControllers() {
+	for _, controller := range c.controllers {
+		if controller.running {
+			log.Info("stopping controller %s...", controller.name)
+			controller.c.Stop()
+			controller.running = false
+		}
+	}
+}
+
 // RunPreCreateHooks runs all registered controllers' PreCreate hooks.
 func (c *control) RunPreCreateHooks(container cache.Container) error {
 	for _, controller := range c.controllers {
@@ -175,6 +229,44 @@ func (c *control) RunPreStartHooks(container cache.Container) error {
 	return nil
 }
 
+// RunFenceStartHooks runs all registered controllers' FenceStart hooks.
+//
+// Fence hook failures of required controllers, or of any running controller
+// with strict fencing, fail the hooks to prevent starting a container with only
+// partially applied resource assignments.
+func (c *control) RunFenceStartHooks(container cache.Container) error {
+	if container.IsIgnored() {
+		return nil
+	}
+
+	failed := []string{}
+	for _, controller := range c.controllers {
+		hooks, ok := controller.c.(FenceHooks)
+		if !ok || controller.mode == Disabled || !controller.running {
+			continue
+		}
+
+		log.Debug("running %s %s hook for container %s", controller.name, fencestart,
+			container.PrettyName())
+
+		if err := hooks.FenceStartHook(container); err != nil {
+			if controller.mode == Required || opt.StrictFencing {
+				failed = append(failed, fmt.Sprintf("%s: %v", controller.name, err))
+				continue
+			}
+			log.Warn("%s %s hook failed for container %s: %v", controller.name, fencestart,
+				container.PrettyName(), err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return controlError("resources of container %s not fully set up: %s",
+			container.PrettyName(), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
 // RunPostStartHooks runs all registered controllers' PostStart hooks.
 func (c *control) RunPostStartHooks(container cache.Container) error {
 	for _, controller := range c.controllers {
@@ -205,12 +297,99 @@ func (c *control) RunPostStopHooks(container cache.Container) error {
 	return nil
 }
 
+// RunPostRunPodHooks runs all registered controllers' PostRunPod hooks.
+func (c *control) RunPostRunPodHooks(pod cache.Pod) error {
+	if pod.IsIgnored() {
+		return nil
+	}
+	for _, controller := range c.controllers {
+		hooks, ok := controller.c.(PodHooks)
+		if !ok {
+			continue
+		}
+		fn := func() error { return hooks.PostRunPodHook(pod) }
+		if err := c.runOtherHook(controller, postrunpod, "pod "+pod.GetName(), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPreImagePullHooks runs all registered controllers' PreImagePull hooks.
+func (c *control) RunPreImagePullHooks(image string) error {
+	for _, controller := range c.controllers {
+		hooks, ok := controller.c.(ImageHooks)
+		if !ok {
+			continue
+		}
+		fn := func() error { return hooks.PreImagePullHook(image) }
+		if err := c.runOtherHook(controller, prepull, "image "+image, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunPostImagePullHooks runs all registered controllers' PostImagePull hooks.
+func (c *control) RunPostImagePullHooks(image string) error {
+	for _, controller := range c.controllers {
+		hooks, ok := controller.c.(ImageHooks)
+		if !ok {
+			continue
+		}
+		fn := func() error { return hooks.PostImagePullHook(image) }
+		if err := c.runOtherHook(controller, postpull, "image "+image, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContainerClasses returns the effective classes of a container, by running controller.
+func (c *control) ContainerClasses(container cache.Container) map[string]string {
+	classes := map[string]string{}
+	for _, controller := range c.controllers {
+		hooks, ok := controller.c.(ClassHooks)
+		if !ok || controller.mode == Disabled || !controller.running {
+			continue
+		}
+		if class := hooks.ContainerClass(container); class != "" {
+			classes[controller.name] = class
+		}
+	}
+	return classes
+}
+
+// runOtherHook executes the given non-container hook according to the controller settings.
+func (c *control) runOtherHook(controller *controller, hook, subject string, fn func() error) error {
+	if controller.mode == Disabled || !controller.running {
+		return nil
+	}
+
+	log.Debug("running %s %s hook for %s", controller.name, hook, subject)
+
+	if err := fn(); err != nil {
+		if controller.mode == Required {
+			return controlError("%s %s hook failed: %v", controller.name, hook, err)
+		}
+		log.Error("%s %s hook failed: %v", controller.name, hook, err)
+	}
+
+	return nil
+}
+
 // runhook executes the given container hook according to the controller settings
 func (c *control) runhook(controller *controller, hook string, container cache.Container) error {
 	if controller.mode == Disabled || !controller.running {
 		return nil
 	}
 
+	if container.IsIgnored() {
+		log.Debug("skipping %s %s hook for ignored container %s", controller.name, hook,
+			container.PrettyName())
+		return nil
+	}
+
 	var fn func(cache.Container) error
 
 	switch hook {
@@ -0,0 +1,112 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakeContainer is a container with only its name and ignored state set.
+type fakeContainer struct {
+	cache.Container
+	ignored bool
+}
+
+func (c *fakeContainer) PrettyName() string { return "pod/ctr" }
+func (c *fakeContainer) IsIgnored() bool    { return c.ignored }
+
+// fakeController is a controller with a fence hook that fails on demand.
+type fakeController struct {
+	Controller
+	fail   bool
+	called bool
+}
+
+func (c *fakeController) FenceStartHook(cache.Container) error {
+	c.called = true
+	if c.fail {
+		return fmt.Errorf("resources not set up")
+	}
+	return nil
+}
+
+func TestRunFenceStartHooks(t *testing.T) {
+	saved := opt.StrictFencing
+	defer func() { opt.StrictFencing = saved }()
+
+	tcs := []struct {
+		name    string
+		mode    mode
+		running bool
+		fail    bool
+		strict  bool
+		ignored bool
+		called  bool
+		err     bool
+	}{
+		{name: "required, success", mode: Required, running: true, called: true},
+		{name: "required, failure", mode: Required, running: true, fail: true, called: true, err: true},
+		{name: "relaxed, failure", mode: Relaxed, running: true, fail: true, called: true},
+		{name: "relaxed, strict failure", mode: Relaxed, running: true, fail: true, strict: true,
+			called: true, err: true},
+		{name: "disabled", mode: Disabled, running: true, fail: true, strict: true},
+		{name: "not running", mode: Relaxed, fail: true, strict: true},
+		{name: "ignored container", mode: Required, running: true, fail: true, ignored: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			opt.StrictFencing = tc.strict
+			fc := &fakeController{fail: tc.fail}
+			c := &control{
+				controllers: []*controller{
+					{name: "fake", c: fc, mode: tc.mode, running: tc.running},
+				},
+			}
+			err := c.RunFenceStartHooks(&fakeContainer{ignored: tc.ignored})
+			if fc.called != tc.called {
+				t.Errorf("expected hook called %v, got %v", tc.called, fc.called)
+			}
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestRecheckInterval(t *testing.T) {
+	tcases := []struct {
+		interval RecheckInterval
+		duration time.Duration
+		invalid  bool
+	}{
+		{interval: "", duration: 0},
+		{interval: "10s", duration: 10 * time.Second},
+		{interval: "0", duration: 0},
+		{interval: "ten seconds", duration: 0, invalid: true},
+	}
+	for _, tc := range tcases {
+		if d := tc.interval.Duration(); d != tc.duration {
+			t.Errorf("expected interval %q to be %v, got %v", tc.interval, tc.duration, d)
+		}
+		if err := tc.interval.Validate(); (err != nil) != tc.invalid {
+			t.Errorf("expected interval %q invalid: %v, got error %v", tc.interval, tc.invalid, err)
+		}
+	}
+}
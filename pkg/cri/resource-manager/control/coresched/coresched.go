@@ -0,0 +1,346 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coresched
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// CoreSchedController is the name of the core scheduling controller.
+	CoreSchedController = "core-scheduling"
+	// keyCoreScheduling is the annotation key for requesting SMT-safe isolation of a pod.
+	keyCoreScheduling = "core-scheduling"
+)
+
+// tracked is a container of an isolated pod.
+type tracked struct {
+	name string              // pretty name of the container
+	id   string              // container ID
+	pod  *cookie             // core scheduling cookie of the pod
+	pids map[string]struct{} // processes already assigned the cookie
+}
+
+// cookie is the core scheduling cookie of an isolated pod.
+type cookie struct {
+	name   string // name of the pod
+	owner  int    // a process known to carry the cookie, 0 if none yet
+	shared int    // number of containers sharing the cookie
+}
+
+// schedctl encapsulates the runtime state of our core scheduling controller.
+type schedctl struct {
+	sync.Mutex
+	cache      cache.Cache         // resource manager cache
+	containers map[string]*tracked // containers of isolated pods, by cache ID
+	cookies    map[string]*cookie  // cookies of isolated pods, by pod ID
+	stop       chan struct{}       // channel to stop rechecking
+}
+
+// Our singleton core scheduling controller instance.
+var singleton *schedctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(CoreSchedController)
+
+// getCoreSchedController returns our singleton core scheduling controller instance.
+func getCoreSchedController() control.Controller {
+	if singleton == nil {
+		singleton = &schedctl{
+			containers: make(map[string]*tracked),
+			cookies:    make(map[string]*cookie),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *schedctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(CoreSchedController); err != nil {
+		return err
+	}
+	if err := probeCoreSched(); err != nil {
+		return coreSchedError("core scheduling not supported: %v", err)
+	}
+
+	ctl.cache = cache
+	ctl.startRecheck()
+
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *schedctl) Stop() {
+	ctl.stopRecheck()
+}
+
+// PreCreateHook is the core scheduling controller pre-create hook.
+func (ctl *schedctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the core scheduling controller pre-start hook.
+func (ctl *schedctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the core scheduling controller post-start hook.
+func (ctl *schedctl) PostStartHook(c cache.Container) error {
+	return ctl.isolate(c)
+}
+
+// PostUpdateHook is the core scheduling controller post-update hook.
+func (ctl *schedctl) PostUpdateHook(c cache.Container) error {
+	if c.GetState() != cache.ContainerStateRunning {
+		return nil
+	}
+	return ctl.isolate(c)
+}
+
+// PostStopHook is the core scheduling controller post-stop hook.
+func (ctl *schedctl) PostStopHook(c cache.Container) error {
+	ctl.Lock()
+	defer ctl.Unlock()
+	ctl.forget(c.GetCacheID())
+	return nil
+}
+
+// isolate assigns the core scheduling cookie of its pod to the processes of a container.
+func (ctl *schedctl) isolate(c cache.Container) error {
+	if !isIsolated(c) {
+		return nil
+	}
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	t, ok := ctl.containers[c.GetCacheID()]
+	if !ok || t.id != c.GetID() {
+		ctl.forget(c.GetCacheID())
+		podID := c.GetPodID()
+		pc, ok := ctl.cookies[podID]
+		if !ok {
+			name := podID
+			if pod, ok := c.GetPod(); ok {
+				name = pod.GetName()
+			}
+			pc = &cookie{name: name}
+			ctl.cookies[podID] = pc
+		}
+		pc.shared++
+		t = &tracked{
+			name: c.PrettyName(),
+			id:   c.GetID(),
+			pod:  pc,
+			pids: make(map[string]struct{}),
+		}
+		ctl.containers[c.GetCacheID()] = t
+	}
+
+	return ctl.apply(t)
+}
+
+// forget stops tracking a container, dropping the cookie of its pod once unused.
+func (ctl *schedctl) forget(id string) {
+	t, ok := ctl.containers[id]
+	if !ok {
+		return
+	}
+	delete(ctl.containers, id)
+	t.pod.shared--
+	if t.pod.shared > 0 {
+		return
+	}
+	for podID, pc := range ctl.cookies {
+		if pc == t.pod {
+			delete(ctl.cookies, podID)
+		}
+	}
+}
+
+// apply assigns the cookie of the pod to any new processes of a tracked container.
+func (ctl *schedctl) apply(t *tracked) error {
+	pids, err := utils.GetProcessInContainer(t.id)
+	if err != nil {
+		return coreSchedError("%s: failed to get processes: %v", t.name, err)
+	}
+
+	seen := make(map[string]struct{}, len(pids))
+	for _, pid := range pids {
+		if _, ok := t.pids[pid]; ok {
+			seen[pid] = struct{}{}
+			continue
+		}
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			continue
+		}
+		if err := ctl.assign(t.pod, id); err != nil {
+			if isProcessGone(err) {
+				continue
+			}
+			return coreSchedError("%s: failed to set core scheduling cookie of process %d: %v",
+				t.name, id, err)
+		}
+		seen[pid] = struct{}{}
+		log.Debug("%s: process %d assigned core scheduling cookie of pod %s",
+			t.name, id, t.pod.name)
+	}
+
+	if len(t.pids) == 0 && len(seen) > 0 {
+		log.Info("%s: isolated with core scheduling cookie of pod %s", t.name, t.pod.name)
+	}
+	t.pids = seen
+
+	return nil
+}
+
+// assign assigns the cookie of a pod to a process, creating the cookie if necessary.
+func (ctl *schedctl) assign(pc *cookie, pid int) error {
+	if pc.owner != 0 {
+		err := shareCookie(pc.owner, pid)
+		if err == nil || !isProcessGone(err) || pid == pc.owner {
+			return err
+		}
+		// the owner is gone, pick a new one among the other processes of the pod
+		pc.owner = 0
+		for _, t := range ctl.containers {
+			if t.pod != pc {
+				continue
+			}
+			for p := range t.pids {
+				if id, err := strconv.Atoi(p); err == nil && shareCookie(id, pid) == nil {
+					pc.owner = id
+					return nil
+				}
+			}
+		}
+	}
+
+	// no process of the pod carries the cookie any more, create a new one
+	if err := createCookie(pid); err != nil {
+		return err
+	}
+	pc.owner = pid
+	return nil
+}
+
+// isIsolated checks if a container belongs to a pod annotated for SMT-safe isolation.
+func isIsolated(c cache.Container) bool {
+	pod, ok := c.GetPod()
+	if !ok {
+		return opt.Default
+	}
+	value, ok := pod.GetResmgrAnnotation(keyCoreScheduling)
+	if !ok {
+		return opt.Default
+	}
+	isolate, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		log.Error("%s: invalid annotation %s = %q: %v", c.PrettyName(),
+			keyCoreScheduling, value, err)
+		return opt.Default
+	}
+	return isolate
+}
+
+// startRecheck starts periodically assigning cookies to new processes of containers.
+//
+// Processes forked within a container inherit the cookie of their parent, but
+// processes started by the runtime, for instance with exec, do not. We check
+// for such new processes periodically and assign them the cookie, too.
+func (ctl *schedctl) startRecheck() {
+	ctl.stopRecheck()
+	interval := opt.RecheckInterval.Duration()
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	ctl.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case _ = <-stop:
+				return
+			case _ = <-ticker.C:
+				ctl.recheck()
+			}
+		}
+	}()
+}
+
+// stopRecheck stops periodically assigning cookies to new processes of containers.
+func (ctl *schedctl) stopRecheck() {
+	if ctl.stop != nil {
+		close(ctl.stop)
+		ctl.stop = nil
+	}
+}
+
+// recheck assigns cookies to any new processes of tracked containers.
+func (ctl *schedctl) recheck() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for _, t := range ctl.containers {
+		if err := ctl.apply(t); err != nil {
+			log.Debug("%v", err)
+		}
+	}
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *schedctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	if ctl.cache == nil {
+		return nil
+	}
+	ctl.startRecheck()
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.isolate(c); err != nil {
+			log.Error("failed to isolate %s: %v", c.PrettyName(), err)
+		}
+	}
+
+	return nil
+}
+
+// coreSchedError returns a controller-specific formatted error.
+func coreSchedError(format string, args ...interface{}) error {
+	return fmt.Errorf("core-scheduling: "+format, args...)
+}
+
+// init registers this controller.
+func init() {
+	control.Register(CoreSchedController, "core scheduling controller", getCoreSchedController())
+}
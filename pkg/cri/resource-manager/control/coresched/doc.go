@@ -0,0 +1,46 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coresched
+
+var configHelp = `
+Resource Manager core scheduling controller.
+
+The core scheduling controller isolates pods from each other on SMT (hyper-
+threaded) cores using Linux core scheduling. Every pod annotated with
+
+  cri-resource-manager.intel.com/core-scheduling: "true"
+
+gets a core scheduling cookie of its own, shared by all processes of all of
+its containers. The kernel never runs tasks with different cookies, or tasks
+with a cookie and tasks without one, simultaneously on the hyperthreads of the
+same core. This keeps the threads of untrusted pods from sharing a core with
+those of trusted ones, without disabling SMT for the whole node.
+
+Setting Default to true isolates every pod which is not explicitly annotated
+with "false".
+
+Processes forked within a container inherit the cookie, but ones started by
+the runtime, for instance with exec, do not. The processes of containers are
+periodically rechecked, with RecheckInterval, and any new ones are assigned
+the cookie of their pod, too. Setting RecheckInterval to 0 disables this.
+
+Core scheduling requires Linux 5.14 or later with CONFIG_SCHED_CORE. If it is
+not available, the controller fails to start.
+
+Here is a sample configuration fragment to isolate all pods by default:
+
+  core-scheduling:
+    Default: true
+`
@@ -0,0 +1,68 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coresched
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// prctl(2) core scheduling commands, missing from our version of x/sys/unix.
+const (
+	prSchedCore          = 62
+	prSchedCoreGet       = 0
+	prSchedCoreCreate    = 1
+	prSchedCoreShareTo   = 2
+	prSchedCoreShareFrom = 3
+
+	pidTypePID  = 0
+	pidTypeTGID = 1
+)
+
+// probeCoreSched checks if the kernel supports core scheduling.
+func probeCoreSched() error {
+	var cookie uint64
+	return unix.Prctl(prSchedCore, prSchedCoreGet, 0, pidTypePID, uintptr(unsafe.Pointer(&cookie)))
+}
+
+// createCookie creates a new core scheduling cookie for all threads of a process.
+func createCookie(pid int) error {
+	return unix.Prctl(prSchedCore, prSchedCoreCreate, uintptr(pid), pidTypeTGID, 0)
+}
+
+// shareCookie assigns the core scheduling cookie of one process to all threads of another.
+//
+// The kernel can only share a cookie from another task to the calling thread and
+// from the calling thread to another task. We do this on a dedicated OS thread,
+// which is never unlocked and therefore discarded together with the cookie.
+func shareCookie(from, to int) error {
+	errC := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		err := unix.Prctl(prSchedCore, prSchedCoreShareFrom, uintptr(from), pidTypePID, 0)
+		if err == nil {
+			err = unix.Prctl(prSchedCore, prSchedCoreShareTo, uintptr(to), pidTypeTGID, 0)
+		}
+		errC <- err
+	}()
+	return <-errC
+}
+
+// isProcessGone checks if an error is caused by a process no longer existing.
+func isProcessGone(err error) bool {
+	return err == unix.ESRCH
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package coresched
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// probeCoreSched checks if the kernel supports core scheduling.
+func probeCoreSched() error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
+
+// createCookie creates a new core scheduling cookie for all threads of a process.
+func createCookie(pid int) error {
+	return probeCoreSched()
+}
+
+// shareCookie assigns the core scheduling cookie of one process to all threads of another.
+func shareCookie(from, to int) error {
+	return probeCoreSched()
+}
+
+// isProcessGone checks if an error is caused by a process no longer existing.
+func isProcessGone(err error) bool {
+	return false
+}
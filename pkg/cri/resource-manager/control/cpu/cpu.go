@@ -0,0 +1,291 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpu
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+const (
+	// CPUController is the name of the CPU class controller.
+	CPUController = "cpu"
+	// keyCPUClass is the annotation key for requesting a CPU class.
+	keyCPUClass = "cpu-class"
+)
+
+// cpuctl encapsulates the runtime state of our CPU class controller.
+type cpuctl struct {
+	sync.Mutex
+	cache   cache.Cache    // resource manager cache
+	system  system.System  // system topology
+	classes map[int]string // CPUs we have applied a class to
+}
+
+// Our singleton CPU class controller instance.
+var singleton *cpuctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(CPUController)
+
+// getCPUController returns our singleton CPU class controller instance.
+func getCPUController() control.Controller {
+	if singleton == nil {
+		singleton = &cpuctl{
+			classes: make(map[int]string),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *cpuctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(CPUController); err != nil {
+		return err
+	}
+
+	sys, err := system.DiscoverSystem()
+	if err != nil {
+		return cpuError("failed to discover system topology: %v", err)
+	}
+
+	ctl.cache = cache
+	ctl.system = sys
+	ctl.update()
+
+	return nil
+}
+
+// Stop shuts down the controller, restoring the defaults of all CPUs we have altered.
+func (ctl *cpuctl) Stop() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for id := range ctl.classes {
+		ctl.reset(id)
+	}
+}
+
+// PreCreateHook is the CPU class controller pre-create hook.
+func (ctl *cpuctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the CPU class controller pre-start hook.
+func (ctl *cpuctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the CPU class controller post-start hook.
+func (ctl *cpuctl) PostStartHook(c cache.Container) error {
+	ctl.update()
+	return nil
+}
+
+// PostUpdateHook is the CPU class controller post-update hook.
+func (ctl *cpuctl) PostUpdateHook(c cache.Container) error {
+	ctl.update()
+	return nil
+}
+
+// PostStopHook is the CPU class controller post-stop hook.
+func (ctl *cpuctl) PostStopHook(c cache.Container) error {
+	ctl.update()
+	return nil
+}
+
+// update brings the classes of all CPUs in sync with the containers they are granted to.
+//
+// A class is applied only to CPUs exclusively granted to a container requesting
+// it. CPUs which are no longer exclusively granted to such a container, for
+// instance because the container stopped or its CPUs were returned to a shared
+// pool, are reset to their defaults.
+func (ctl *cpuctl) update() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	if ctl.cache == nil {
+		return
+	}
+
+	wanted := ctl.wantedClasses()
+
+	for id := range ctl.classes {
+		if _, ok := wanted[id]; !ok {
+			ctl.reset(id)
+		}
+	}
+	for id, class := range wanted {
+		if ctl.classes[id] != class {
+			ctl.apply(id, class)
+		}
+	}
+}
+
+// wantedClasses returns the classes for CPUs exclusively granted to containers with a class.
+func (ctl *cpuctl) wantedClasses() map[int]string {
+	containers := ctl.cache.GetContainers()
+	exclusive := cache.ExclusiveCPUs(containers)
+	wanted := map[int]string{}
+
+	for _, c := range containers {
+		cpus, ok := exclusive[c.GetCacheID()]
+		if !ok || c.IsIgnored() {
+			continue
+		}
+		if class, ok := cpuClassOf(c); ok {
+			for _, id := range cpus.ToSlice() {
+				wanted[id] = class
+			}
+		}
+	}
+
+	return wanted
+}
+
+// apply applies the frequency limits and energy-performance preference of a class to a CPU.
+func (ctl *cpuctl) apply(id int, class string) {
+	cpu := ctl.system.CPU(system.ID(id))
+	if cpu == nil {
+		return
+	}
+	cc := opt.Classes[class]
+	freq := cpu.FrequencyRange()
+
+	min, max := cc.MinFreq, cc.MaxFreq
+	if min == 0 {
+		min = freq.Min()
+	}
+	if max == 0 {
+		max = freq.Max()
+	}
+	if err := cpu.SetFrequencyLimits(1000*min, 1000*max); err != nil {
+		log.Error("failed to set frequency limits of CPU #%d for class %s: %v", id, class, err)
+	}
+	if cc.EnergyPerformancePreference != "" {
+		err := cpu.SetEnergyPerformancePreference(cc.EnergyPerformancePreference)
+		if err != nil {
+			log.Error("failed to set energy-performance preference of CPU #%d for class %s: %v",
+				id, class, err)
+		}
+	}
+
+	log.Info("CPU #%d set to class %s", id, class)
+	ctl.classes[id] = class
+}
+
+// reset restores the default frequency limits and energy-performance preference of a CPU.
+func (ctl *cpuctl) reset(id int) {
+	delete(ctl.classes, id)
+
+	cpu := ctl.system.CPU(system.ID(id))
+	if cpu == nil {
+		return
+	}
+	freq := cpu.FrequencyRange()
+
+	if err := cpu.SetFrequencyLimits(1000*freq.Min(), 1000*freq.Max()); err != nil {
+		log.Error("failed to reset frequency limits of CPU #%d: %v", id, err)
+	}
+	if epp := cpu.EnergyPerformancePreference(); epp != "" {
+		if err := cpu.SetEnergyPerformancePreference(epp); err != nil {
+			log.Error("failed to reset energy-performance preference of CPU #%d: %v", id, err)
+		}
+	}
+
+	log.Info("CPU #%d reset to defaults", id)
+}
+
+// cpuClassOf returns the CPU class annotated for the container, if any.
+func cpuClassOf(c cache.Container) (string, bool) {
+	pod, ok := c.GetPod()
+	if !ok {
+		return "", false
+	}
+	value, ok := pod.GetResmgrAnnotation(keyCPUClass)
+	if !ok {
+		return resourceClassCPU(c)
+	}
+
+	class := strings.TrimSpace(value)
+	if strings.Contains(value, ":") {
+		preferences := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+			log.Error("%s: failed to parse annotation %s = '%s': %v",
+				c.PrettyName(), keyCPUClass, value, err)
+			return "", false
+		}
+		if class, ok = preferences[c.GetName()]; !ok {
+			return "", false
+		}
+	}
+
+	if _, ok := opt.Classes[class]; !ok {
+		log.Error("%s: ignoring unknown annotated CPU class %s", c.PrettyName(), class)
+		return "", false
+	}
+
+	return class, true
+}
+
+// resourceClassCPU returns the CPU class of the unified resource class of the container, if any.
+func resourceClassCPU(c cache.Container) (string, bool) {
+	rc, ok := control.GetClassManager().ResourceClass(c)
+	if !ok || rc.CPU == "" {
+		return "", false
+	}
+	if _, ok := opt.Classes[rc.CPU]; !ok {
+		log.Error("%s: ignoring unknown CPU class %s of resource class", c.PrettyName(), rc.CPU)
+		return "", false
+	}
+	return rc.CPU, true
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *cpuctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	// force reapplying classes, their settings might have changed
+	ctl.Lock()
+	for id := range ctl.classes {
+		ctl.classes[id] = ""
+	}
+	ctl.Unlock()
+
+	ctl.update()
+
+	return nil
+}
+
+// cpuError returns a controller-specific formatted error.
+func cpuError(format string, args ...interface{}) error {
+	return fmt.Errorf("cpu: "+format, args...)
+}
+
+// init registers this controller.
+func init() {
+	control.Register(CPUController, "CPU class controller", getCPUController())
+	control.GetClassManager().AddNotify(getCPUController().(*cpuctl).configNotify)
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpu
+
+var configHelp = `
+Resource Manager CPU class controller.
+
+The CPU class controller applies the frequency scaling limits and the energy-
+performance preference of named CPU classes to the CPUs of containers. A class
+is requested per pod or per container with the cri-resource-manager.intel.com/cpu-class
+annotation, either with a single class for all containers of the pod or with a
+map of container names to classes.
+
+A class is only applied to CPUs granted exclusively to the container. Shared
+CPUs are left untouched. Once a CPU is no longer exclusive to a container with
+a class, for instance because the container stopped or the CPU was returned to
+a shared pool, its frequency limits are reset to the full supported range and
+its energy-performance preference to what it was when we started.
+
+Frequencies are given in kHz, 0 meaning the lowest or highest supported one.
+An empty EnergyPerformancePreference leaves the preference as is.
+
+Here is a sample configuration fragment defining a performance and a powersave
+class:
+
+  cpu:
+    Classes:
+      performance:
+        MinFreq: 2800000
+        EnergyPerformancePreference: performance
+      powersave:
+        MaxFreq: 1600000
+        EnergyPerformancePreference: power
+`
@@ -0,0 +1,66 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpu
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// cpuClass is the frequency scaling and energy-performance settings of a CPU class.
+type cpuClass struct {
+	// MinFreq is the minimum frequency (kHz), 0 for the lowest supported one.
+	MinFreq uint64 `json:",omitempty"`
+	// MaxFreq is the maximum frequency (kHz), 0 for the highest supported one.
+	MaxFreq uint64 `json:",omitempty"`
+	// EnergyPerformancePreference is the energy-performance preference, empty to leave as is.
+	EnergyPerformancePreference string `json:",omitempty"`
+}
+
+// options captures our configurable parameters.
+type options struct {
+	// Classes defines the CPU classes containers can request.
+	Classes map[string]cpuClass `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{Classes: make(map[string]cpuClass)}
+}
+
+// validateOptions checks that all configured CPU classes are valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return cpuError("invalid configuration type %T", cfg)
+	}
+	for name, class := range o.Classes {
+		if class.MinFreq != 0 && class.MaxFreq != 0 && class.MinFreq > class.MaxFreq {
+			return cpuError("invalid CPU class %s: MinFreq %d > MaxFreq %d",
+				name, class.MinFreq, class.MaxFreq)
+		}
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.cpu", configHelp, opt, defaultOptions,
+		config.WithNotify(getCPUController().(*cpuctl).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
@@ -16,7 +16,13 @@ package cri
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/client"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
@@ -28,14 +34,23 @@ import (
 const (
 	// CRIController is the name of this controller.
 	CRIController = cache.CRI
+	// cgroupRoot is the mount point of the cgroup hierarchies.
+	cgroupRoot = "/sys/fs/cgroup"
+	// cpusetDir is the cgroup v1 cpuset hierarchy, relative to cgroupRoot.
+	cpusetDir = "cpuset"
 )
 
 // crictl encapsulated the runtime state of our CRI enforcement/controller.
 type crictl struct {
 	cache  cache.Cache
 	client client.Client
+	root   string // cgroup mount point
+	v2     bool   // whether the unified cgroup v2 hierarchy is in use
 }
 
+// resolveCgroupPath resolves the cgroup directory of a container, overridable for testing.
+var resolveCgroupPath = cgroups.ResolveCgroupPath
+
 // Our logger instance.
 var log logger.Logger = logger.NewLogger(CRIController)
 
@@ -45,7 +60,7 @@ var singleton *crictl
 // getCRIController returns our singleton CRI controller instance.
 func getCRIController() control.Controller {
 	if singleton == nil {
-		singleton = &crictl{}
+		singleton = &crictl{root: cgroupRoot}
 	}
 	return singleton
 }
@@ -55,6 +70,9 @@ func (ctl *crictl) Start(cache cache.Cache, client client.Client) error {
 	ctl.cache = cache
 	ctl.client = client
 
+	_, err := os.Stat(filepath.Join(ctl.root, "cgroup.controllers"))
+	ctl.v2 = err == nil
+
 	return nil
 }
 
@@ -98,6 +116,76 @@ func (ctl *crictl) PreStartHook(c cache.Container) error {
 	return nil
 }
 
+// FenceStartHook checks that the resources of a container about to start have been set up.
+//
+// No CRI resource updates may be pending for the container, and the cpuset of
+// its cgroup must match the one assigned to it, if the runtime has created the
+// cgroup already.
+func (ctl *crictl) FenceStartHook(c cache.Container) error {
+	if c.HasPending(CRIController) {
+		return criError("resource updates of %s not sent to runtime", c.PrettyName())
+	}
+	if request, ok := c.GetCRIRequest(); ok {
+		return criError("resource update of %s (%T) still pending", c.PrettyName(), request)
+	}
+	return ctl.checkCpuset(c)
+}
+
+// checkCpuset checks that the cgroup cpuset of a container matches the assigned one.
+func (ctl *crictl) checkCpuset(c cache.Container) error {
+	var dir string
+	var err error
+
+	if ctl.v2 {
+		dir, err = resolveCgroupPath(ctl.root, c.GetID())
+	} else {
+		dir, err = resolveCgroupPath(filepath.Join(ctl.root, cpusetDir), c.GetID())
+	}
+	if err != nil {
+		log.Debug("no cgroup of %s to check yet", c.PrettyName())
+		return nil
+	}
+
+	for _, entry := range []struct {
+		file     string
+		assigned string
+	}{
+		{"cpuset.cpus", c.GetCpusetCpus()},
+		{"cpuset.mems", c.GetCpusetMems()},
+	} {
+		if entry.assigned == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.file))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return criError("failed to check %s of %s: %v", entry.file, c.PrettyName(), err)
+		}
+		value := strings.TrimSpace(string(data))
+		if ctl.v2 && value == "" {
+			// an empty cgroup v2 cpuset is inherited from the parent, the runtime left it unset
+			return criError("%s of %s is not set, expected %s", entry.file, c.PrettyName(),
+				entry.assigned)
+		}
+		expected, err := cpuset.Parse(entry.assigned)
+		if err != nil {
+			return criError("invalid %s %q of %s: %v", entry.file, entry.assigned, c.PrettyName(), err)
+		}
+		actual, err := cpuset.Parse(value)
+		if err != nil {
+			return criError("invalid %s %q in cgroup of %s: %v", entry.file, value, c.PrettyName(), err)
+		}
+		if !actual.Equals(expected) {
+			return criError("%s of %s is %s, expected %s", entry.file, c.PrettyName(), value,
+				entry.assigned)
+		}
+	}
+
+	return nil
+}
+
 // PostStartHook is the CRI controller post-start hook.
 func (ctl *crictl) PostStartHook(c cache.Container) error {
 	return nil
@@ -0,0 +1,145 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakeContainer is a container with only its identity and cpuset set.
+type fakeContainer struct {
+	cache.Container
+	id   string
+	cpus string
+	mems string
+}
+
+func (c *fakeContainer) GetID() string                      { return c.id }
+func (c *fakeContainer) PrettyName() string                 { return "pod/" + c.id }
+func (c *fakeContainer) GetCpusetCpus() string              { return c.cpus }
+func (c *fakeContainer) GetCpusetMems() string              { return c.mems }
+func (c *fakeContainer) HasPending(string) bool             { return false }
+func (c *fakeContainer) GetCRIRequest() (interface{}, bool) { return nil, false }
+
+func TestFenceStartHook(t *testing.T) {
+	saved := resolveCgroupPath
+	defer func() { resolveCgroupPath = saved }()
+
+	tcs := []struct {
+		name    string
+		v2      bool
+		created bool   // whether the container cgroup exists
+		cpus    string // cpuset.cpus in the cgroup
+		mems    string // cpuset.mems in the cgroup
+		ctr     *fakeContainer
+		err     bool
+	}{
+		{
+			name: "no cgroup yet",
+			ctr:  &fakeContainer{id: "ctr", cpus: "0-3", mems: "0"},
+		},
+		{
+			name:    "v1 match",
+			created: true,
+			cpus:    "0-3\n",
+			mems:    "0\n",
+			ctr:     &fakeContainer{id: "ctr", cpus: "0,1,2,3", mems: "0"},
+		},
+		{
+			name:    "v1 cpu mismatch",
+			created: true,
+			cpus:    "0-7\n",
+			mems:    "0\n",
+			ctr:     &fakeContainer{id: "ctr", cpus: "0-3", mems: "0"},
+			err:     true,
+		},
+		{
+			name:    "v1 mem mismatch",
+			created: true,
+			cpus:    "0-3\n",
+			mems:    "0-1\n",
+			ctr:     &fakeContainer{id: "ctr", cpus: "0-3", mems: "0"},
+			err:     true,
+		},
+		{
+			name:    "nothing assigned",
+			created: true,
+			cpus:    "0-7\n",
+			mems:    "0-1\n",
+			ctr:     &fakeContainer{id: "ctr"},
+		},
+		{
+			name:    "v2 match",
+			v2:      true,
+			created: true,
+			cpus:    "4-5\n",
+			mems:    "1\n",
+			ctr:     &fakeContainer{id: "ctr", cpus: "4-5", mems: "1"},
+		},
+		{
+			name:    "v2 unset",
+			v2:      true,
+			created: true,
+			cpus:    "\n",
+			mems:    "\n",
+			ctr:     &fakeContainer{id: "ctr", cpus: "4-5", mems: "1"},
+			err:     true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "cri-test")
+			if err != nil {
+				t.Fatalf("failed to create test directory: %v", err)
+			}
+			defer os.RemoveAll(root)
+
+			hierarchy := filepath.Join(root, cpusetDir)
+			if tc.v2 {
+				hierarchy = root
+			}
+			dir := filepath.Join(hierarchy, "kubepods", tc.ctr.id)
+			if tc.created {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatalf("failed to create cgroup directory: %v", err)
+				}
+				for file, value := range map[string]string{"cpuset.cpus": tc.cpus, "cpuset.mems": tc.mems} {
+					if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+						t.Fatalf("failed to write %s: %v", file, err)
+					}
+				}
+			}
+			resolveCgroupPath = func(hierarchyDir, id string) (string, error) {
+				if !tc.created || hierarchyDir != hierarchy || id != tc.ctr.id {
+					return "", fmt.Errorf("cgroup of %s not found in %s", id, hierarchyDir)
+				}
+				return dir, nil
+			}
+
+			ctl := &crictl{root: root, v2: tc.v2}
+			err = ctl.FenceStartHook(tc.ctr)
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
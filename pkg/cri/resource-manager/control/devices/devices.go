@@ -0,0 +1,276 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devices
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// DevicesController is the name of the devices controller.
+	DevicesController = cache.Devices
+)
+
+// cgroup modes we can enforce device access with
+const (
+	modeNone      = ""
+	modeDevicesV1 = "devices"
+)
+
+// deviceRule is a devices cgroup rule for a single device node.
+type deviceRule struct {
+	Type        string // device type, c or b
+	Major       int64  // device major number
+	Minor       int64  // device minor number
+	Permissions string // access permissions, some combination of rwm
+}
+
+// devices encapsulates the runtime state of our devices controller.
+type devices struct {
+	cache   cache.Cache                       // resource manager cache
+	mode    string                            // enforcement mode, devices cgroup or none
+	v1Root  string                            // cgroup v1 mount point
+	applied map[string]map[string]*deviceRule // applied rules, by container ID and host path
+}
+
+// Our singleton devices controller instance.
+var singleton *devices
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(DevicesController)
+
+// resolveCgroupDir resolves the cgroup directory of a container, overridable for testing.
+var resolveCgroupDir = cgroups.ResolveCgroupDir
+
+// getDevicesController returns our singleton devices controller instance.
+func getDevicesController() control.Controller {
+	if singleton == nil {
+		singleton = &devices{
+			v1Root:  "/sys/fs/cgroup",
+			applied: make(map[string]map[string]*deviceRule),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *devices) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(DevicesController); err != nil {
+		return err
+	}
+
+	// Device access on cgroup v2 is enforced by an eBPF program the runtime
+	// attaches to the cgroup of the container. Updating it would need replacing
+	// that program, which is not supported.
+	if !cgroups.IsDir(filepath.Join(ctl.v1Root, "devices")) {
+		return devicesError("no devices cgroup found, device access of running containers " +
+			"can't be updated with cgroup v2 only")
+	}
+
+	ctl.cache = cache
+	ctl.mode = modeDevicesV1
+
+	ctl.setBaselines()
+
+	log.Info("managing device access using %s", ctl.mode)
+
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *devices) Stop() {
+	ctl.mode = modeNone
+	ctl.applied = make(map[string]map[string]*deviceRule)
+}
+
+// PreCreateHook is the devices controller pre-create hook.
+func (ctl *devices) PreCreateHook(c cache.Container) error {
+	// Devices inserted before creation are passed to the runtime by the CRI controller.
+	c.ClearPending(DevicesController)
+	return nil
+}
+
+// PreStartHook is the devices controller pre-start hook.
+func (ctl *devices) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the devices controller post-start hook.
+func (ctl *devices) PostStartHook(c cache.Container) error {
+	ctl.setBaseline(c)
+	c.ClearPending(DevicesController)
+	return nil
+}
+
+// PostUpdateHook is the devices controller post-update hook.
+func (ctl *devices) PostUpdateHook(c cache.Container) error {
+	if !c.HasPending(DevicesController) {
+		return nil
+	}
+	if c.GetState() != cache.ContainerStateRunning {
+		return nil
+	}
+	if err := ctl.update(c); err != nil {
+		return err
+	}
+	c.ClearPending(DevicesController)
+	return nil
+}
+
+// PostStopHook is the devices controller post-stop hook.
+func (ctl *devices) PostStopHook(c cache.Container) error {
+	delete(ctl.applied, c.GetID())
+	return nil
+}
+
+// setBaselines records the devices of all running containers, set up by the runtime.
+func (ctl *devices) setBaselines() {
+	for _, c := range ctl.cache.GetContainers() {
+		if c.GetState() == cache.ContainerStateRunning {
+			ctl.setBaseline(c)
+		}
+	}
+}
+
+// setBaseline records the devices of a container as ones the runtime has set up.
+func (ctl *devices) setBaseline(c cache.Container) {
+	applied := make(map[string]*deviceRule)
+	for _, dev := range c.GetDevices() {
+		rule, err := deviceRuleFor(&dev)
+		if err != nil {
+			log.Warn("%s: %v", c.PrettyName(), err)
+			continue
+		}
+		applied[dev.Host] = rule
+	}
+	ctl.applied[c.GetID()] = applied
+}
+
+// update grants access to new and revokes access from removed devices of a container.
+func (ctl *devices) update(c cache.Container) error {
+	if ctl.mode == modeNone {
+		return nil
+	}
+
+	applied, ok := ctl.applied[c.GetID()]
+	if !ok {
+		applied = make(map[string]*deviceRule)
+		ctl.applied[c.GetID()] = applied
+	}
+
+	current := make(map[string]*cache.Device)
+	for _, dev := range c.GetDevices() {
+		d := dev
+		current[d.Host] = &d
+	}
+
+	allow := map[string]*deviceRule{}
+	deny := map[string]*deviceRule{}
+	for host, dev := range current {
+		rule, err := deviceRuleFor(dev)
+		if err != nil {
+			return devicesError("%s: %v", c.PrettyName(), err)
+		}
+		if old, ok := applied[host]; ok {
+			if *old == *rule {
+				continue
+			}
+			deny[host] = old
+		}
+		allow[host] = rule
+	}
+	for host, rule := range applied {
+		if _, ok := current[host]; !ok {
+			deny[host] = rule
+		}
+	}
+
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	rel, err := resolveCgroupDir(c.GetID())
+	if err != nil {
+		return devicesError("failed to find devices cgroup of %s: %v", c.PrettyName(), err)
+	}
+	dir := filepath.Join(ctl.v1Root, "devices", rel)
+
+	for host, rule := range deny {
+		if err := writeRule(filepath.Join(dir, "devices.deny"), rule); err != nil {
+			return devicesError("%s: failed to revoke access to %s: %v", c.PrettyName(), host, err)
+		}
+		delete(applied, host)
+		log.Info("%s: revoked access to device %s (%s)", c.PrettyName(), host, rule)
+	}
+	for host, rule := range allow {
+		if err := writeRule(filepath.Join(dir, "devices.allow"), rule); err != nil {
+			return devicesError("%s: failed to grant access to %s: %v", c.PrettyName(), host, err)
+		}
+		applied[host] = rule
+		log.Info("%s: granted access to device %s (%s)", c.PrettyName(), host, rule)
+	}
+
+	return nil
+}
+
+// deviceRuleFor creates the devices cgroup rule for a container device.
+func deviceRuleFor(dev *cache.Device) (*deviceRule, error) {
+	devType, major, minor, err := deviceNumber(dev.Host)
+	if err != nil {
+		return nil, err
+	}
+	perms := dev.Permissions
+	if perms == "" {
+		perms = opt.DefaultPermissions
+	}
+	if strings.Trim(perms, "rwm") != "" {
+		return nil, devicesError("invalid permissions %q for device %s", perms, dev.Host)
+	}
+	return &deviceRule{
+		Type:        devType,
+		Major:       major,
+		Minor:       minor,
+		Permissions: perms,
+	}, nil
+}
+
+// String returns the rule in the format of the devices cgroup.
+func (r *deviceRule) String() string {
+	return fmt.Sprintf("%s %d:%d %s", r.Type, r.Major, r.Minor, r.Permissions)
+}
+
+// writeRule writes a device rule to the given devices cgroup entry.
+func writeRule(entry string, rule *deviceRule) error {
+	return ioutil.WriteFile(entry, []byte(rule.String()), 0644)
+}
+
+// devicesError creates a devices-controller-specific formatted error message.
+func devicesError(format string, args ...interface{}) error {
+	return fmt.Errorf("devices: "+format, args...)
+}
+
+// Register us as a controller.
+func init() {
+	control.Register(DevicesController, "Device access controller", getDevicesController())
+}
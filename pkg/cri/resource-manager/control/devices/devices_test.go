@@ -0,0 +1,162 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devices
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakeContainer is a running container with a set of devices.
+type fakeContainer struct {
+	cache.Container
+	devices []cache.Device
+}
+
+func (c *fakeContainer) GetID() string                  { return "ctr-id" }
+func (c *fakeContainer) PrettyName() string             { return "pod/ctr" }
+func (c *fakeContainer) GetState() cache.ContainerState { return cache.ContainerStateRunning }
+func (c *fakeContainer) GetDevices() []cache.Device     { return c.devices }
+
+// newDevicesTest creates a devices controller with a fake devices cgroup.
+func newDevicesTest(t *testing.T) (*devices, string, func()) {
+	root, err := ioutil.TempDir("", "devices-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	dir := filepath.Join(root, "devices", "kubepods", "ctr-id")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		os.RemoveAll(root)
+		t.Fatalf("failed to create devices cgroup: %v", err)
+	}
+
+	saved := resolveCgroupDir
+	resolveCgroupDir = func(id string) (string, error) {
+		return filepath.Join("kubepods", id), nil
+	}
+
+	ctl := &devices{
+		mode:    modeDevicesV1,
+		v1Root:  root,
+		applied: make(map[string]map[string]*deviceRule),
+	}
+	return ctl, dir, func() {
+		resolveCgroupDir = saved
+		os.RemoveAll(root)
+	}
+}
+
+// readRule reads the last rule written to a devices cgroup entry.
+func readRule(t *testing.T, dir, entry string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, entry))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read %s: %v", entry, err)
+	}
+	os.Remove(filepath.Join(dir, entry))
+	return string(data)
+}
+
+// appliedRules returns the applied rules of the test container.
+func appliedRules(ctl *devices) []string {
+	rules := []string{}
+	for _, rule := range ctl.applied["ctr-id"] {
+		rules = append(rules, rule.String())
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+func TestUpdateDevices(t *testing.T) {
+	ctl, dir, cleanup := newDevicesTest(t)
+	defer cleanup()
+
+	c := &fakeContainer{
+		devices: []cache.Device{{Container: "/dev/null", Host: "/dev/null", Permissions: "rwm"}},
+	}
+	ctl.setBaseline(c)
+
+	// the baseline set up by the runtime is not written again
+	if err := ctl.update(c); err != nil {
+		t.Fatalf("failed to update devices: %v", err)
+	}
+	if rule := readRule(t, dir, "devices.allow"); rule != "" {
+		t.Errorf("expected no rules for baseline devices, got %q", rule)
+	}
+
+	// an added device is granted, with the default permissions
+	c.devices = append(c.devices, cache.Device{Container: "/dev/zero", Host: "/dev/zero"})
+	if err := ctl.update(c); err != nil {
+		t.Fatalf("failed to grant device: %v", err)
+	}
+	if rule := readRule(t, dir, "devices.allow"); rule != "c 1:5 rw" {
+		t.Errorf("expected access to /dev/zero granted, got %q", rule)
+	}
+	if rules := appliedRules(ctl); strings.Join(rules, ",") != "c 1:3 rwm,c 1:5 rw" {
+		t.Errorf("unexpected applied rules %v", rules)
+	}
+
+	// a removed device is revoked
+	c.devices = c.devices[1:]
+	if err := ctl.update(c); err != nil {
+		t.Fatalf("failed to revoke device: %v", err)
+	}
+	if rule := readRule(t, dir, "devices.deny"); rule != "c 1:3 rwm" {
+		t.Errorf("expected access to /dev/null revoked, got %q", rule)
+	}
+
+	// changed permissions are revoked, then granted
+	c.devices[0].Permissions = "r"
+	if err := ctl.update(c); err != nil {
+		t.Fatalf("failed to update device permissions: %v", err)
+	}
+	if deny, allow := readRule(t, dir, "devices.deny"), readRule(t, dir, "devices.allow"); deny != "c 1:5 rw" || allow != "c 1:5 r" {
+		t.Errorf("expected permissions of /dev/zero updated, got deny %q, allow %q", deny, allow)
+	}
+	if rules := appliedRules(ctl); strings.Join(rules, ",") != "c 1:5 r" {
+		t.Errorf("unexpected applied rules %v", rules)
+	}
+}
+
+func TestDeviceRuleFor(t *testing.T) {
+	tcases := []struct {
+		name     string
+		dev      cache.Device
+		expected string
+		err      bool
+	}{
+		{name: "default permissions", dev: cache.Device{Host: "/dev/null"}, expected: "c 1:3 rw"},
+		{name: "given permissions", dev: cache.Device{Host: "/dev/zero", Permissions: "m"}, expected: "c 1:5 m"},
+		{name: "invalid permissions", dev: cache.Device{Host: "/dev/null", Permissions: "rx"}, err: true},
+		{name: "not a device", dev: cache.Device{Host: "/"}, err: true},
+		{name: "missing device", dev: cache.Device{Host: "/dev/no-such-device"}, err: true},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := deviceRuleFor(&tc.dev)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if err == nil && rule.String() != tc.expected {
+				t.Errorf("expected rule %q, got %q", tc.expected, rule.String())
+			}
+		})
+	}
+}
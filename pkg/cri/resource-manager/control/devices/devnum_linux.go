@@ -0,0 +1,40 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devices
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// deviceNumber returns the type and the major and minor numbers of a device node.
+func deviceNumber(path string) (string, int64, int64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return "", 0, 0, err
+	}
+
+	var devType string
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		devType = "c"
+	case unix.S_IFBLK:
+		devType = "b"
+	default:
+		return "", 0, 0, devicesError("%s is not a device node", path)
+	}
+
+	rdev := uint64(st.Rdev)
+	return devType, int64(unix.Major(rdev)), int64(unix.Minor(rdev)), nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package devices
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// deviceNumber returns the type and the major and minor numbers of a device node.
+func deviceNumber(path string) (string, int64, int64, error) {
+	return "", 0, 0, fmt.Errorf("not supported on %s", runtime.GOOS)
+}
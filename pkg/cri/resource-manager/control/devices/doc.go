@@ -0,0 +1,43 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devices
+
+var configHelp = `
+Resource Manager device access controller.
+
+The devices controller keeps the device access of running containers in
+sync with the devices assigned to them. Policies and adjustments can grant
+a container access to additional device nodes, for instance a local NVMe
+namespace or a DSA work queue, or take the access away once the device is
+released.
+
+Devices assigned to a container before it is created are passed to the
+runtime, which sets up the device access of the container both on cgroup
+v1 and v2. Devices assigned to or removed from a running container are
+granted or revoked by the controller, by writing the corresponding rules
+to the devices.allow and devices.deny entries of the devices cgroup of the
+container. The controller needs the devices cgroup of cgroup v1, it does not
+start on hosts with cgroup v2 only. There device access is enforced by an
+eBPF device filter program the runtime attaches to the container, and the
+controller does not replace that program, so device access can only be set
+up when a container is created.
+
+Devices assigned without any permissions are granted DefaultPermissions,
+some combination of r(ead), w(rite) and m(knod). Here is a sample
+configuration fragment:
+
+  devices:
+    DefaultPermissions: rw
+`
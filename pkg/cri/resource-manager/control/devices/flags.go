@@ -0,0 +1,56 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devices
+
+import (
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// DefaultPermissions are the permissions granted for devices without any.
+	DefaultPermissions string `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		DefaultPermissions: "rw",
+	}
+}
+
+// validateOptions checks that the default permissions are valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return devicesError("invalid configuration type %T", cfg)
+	}
+	if o.DefaultPermissions == "" || strings.Trim(o.DefaultPermissions, "rwm") != "" {
+		return devicesError("invalid default permissions %q", o.DefaultPermissions)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.devices", configHelp, opt, defaultOptions,
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
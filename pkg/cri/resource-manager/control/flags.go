@@ -19,16 +19,46 @@ import (
 	"fmt"
 	"github.com/intel/cri-resource-manager/pkg/config"
 	"strings"
+	"time"
 )
 
 // Options captures our runtime configuration.
 type options struct {
 	Controllers map[string]mode
+	// StrictFencing fails starting containers if the fence hook of any running controller fails.
+	StrictFencing bool `json:",omitempty"`
 }
 
 // Our runtime configuration.
 var opt = defaultOptions().(*options)
 
+// RecheckInterval is the configurable interval of periodic controller rechecks.
+// It is a duration string, with an empty one disabling rechecks.
+type RecheckInterval string
+
+// Duration returns the recheck interval, 0 if disabled or invalid.
+func (i RecheckInterval) Duration() time.Duration {
+	if i == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(string(i))
+	if err != nil {
+		return 0
+	}
+	return interval
+}
+
+// Validate checks that the recheck interval is a valid duration, if set.
+func (i RecheckInterval) Validate() error {
+	if i == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(string(i)); err != nil {
+		return fmt.Errorf("invalid RecheckInterval %q: %v", string(i), err)
+	}
+	return nil
+}
+
 // mode describes how errors for the controller should be treated.
 type mode int
 
@@ -0,0 +1,41 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package irq
+
+var configHelp = `
+Resource Manager IRQ affinity controller.
+
+The IRQ affinity controller steers device interrupts away from the CPUs
+exclusively granted to containers. A CPU is considered exclusive if it is
+in the cpuset of a single container. The controller removes such CPUs
+from the affinity of all interrupts, /proc/irq/*/smp_affinity_list, and
+restores the original affinities once the grants are released. Optionally
+it also bans the same CPUs in an irqbalance configuration file, so that
+irqbalance does not move the interrupts back.
+
+Interrupt steering is enabled per container class. A container's class is
+its assigned RDT class, or its QoS class if it has no RDT class assigned.
+'*' can be used to enable or disable steering for all other classes.
+
+Here is a sample configuration fragment to steer interrupts away from the
+exclusive CPUs of Guaranteed containers, and to keep the irqbalance banned
+CPUs up to date:
+
+  irq:
+    Classes:
+      Guaranteed: true
+      "*": false
+    IrqbalanceConfig: /etc/sysconfig/irqbalance
+`
@@ -0,0 +1,42 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package irq
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// Classes enables IRQ steering for container classes.
+	Classes map[string]bool `json:",omitempty"`
+	// IrqbalanceConfig is the irqbalance configuration file to update with banned CPUs.
+	IrqbalanceConfig string `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{Classes: make(map[string]bool)}
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.irq", configHelp, opt, defaultOptions,
+		config.WithNotify(getIRQController().(*irqctl).configNotify),
+		config.WithFragment())
+}
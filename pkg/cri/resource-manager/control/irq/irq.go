@@ -0,0 +1,307 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package irq
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// IRQController is the name of the IRQ affinity controller.
+	IRQController = "irq"
+	// irqbalanceBanned is the irqbalance configuration variable for banned CPUs.
+	irqbalanceBanned = "IRQBALANCE_BANNED_CPUS"
+)
+
+// irqctl encapsulates the runtime state of our IRQ affinity controller.
+type irqctl struct {
+	cache    cache.Cache    // resource manager cache
+	procRoot string         // procfs root, /proc
+	defaults map[int]string // original affinity of interrupts we have altered
+	banned   cpuset.CPUSet  // CPUs interrupts are currently steered away from
+}
+
+// Our singleton IRQ affinity controller instance.
+var singleton *irqctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(IRQController)
+
+// getIRQController returns our singleton IRQ affinity controller instance.
+func getIRQController() control.Controller {
+	if singleton == nil {
+		singleton = &irqctl{
+			procRoot: "/proc",
+			defaults: make(map[int]string),
+			banned:   cpuset.NewCPUSet(),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *irqctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(IRQController); err != nil {
+		return err
+	}
+
+	if _, err := ioutil.ReadDir(filepath.Join(ctl.procRoot, "irq")); err != nil {
+		return irqError("failed to access IRQs: %v", err)
+	}
+	ctl.cache = cache
+	return ctl.steer()
+}
+
+// Stop shuts down the controller, restoring the original IRQ affinities.
+func (ctl *irqctl) Stop() {
+	ctl.restore()
+}
+
+// PreCreateHook is the IRQ affinity controller pre-create hook.
+func (ctl *irqctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the IRQ affinity controller pre-start hook.
+func (ctl *irqctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the IRQ affinity controller post-start hook.
+func (ctl *irqctl) PostStartHook(c cache.Container) error {
+	return ctl.steer()
+}
+
+// PostUpdateHook is the IRQ affinity controller post-update hook.
+func (ctl *irqctl) PostUpdateHook(c cache.Container) error {
+	return ctl.steer()
+}
+
+// PostStopHook is the IRQ affinity controller post-stop hook.
+func (ctl *irqctl) PostStopHook(c cache.Container) error {
+	return ctl.steer()
+}
+
+// steer updates IRQ affinities to avoid the current exclusive CPU grants.
+func (ctl *irqctl) steer() error {
+	if ctl.cache == nil {
+		return nil
+	}
+
+	banned := ctl.exclusiveCPUs()
+	if banned.Equals(ctl.banned) {
+		return nil
+	}
+
+	log.Info("steering IRQs away from CPUs %s", banned)
+
+	irqs, err := ctl.listIRQs()
+	if err != nil {
+		return err
+	}
+
+	for _, irq := range irqs {
+		orig, ok := ctl.defaults[irq]
+		if !ok {
+			if orig, err = ctl.getAffinity(irq); err != nil {
+				log.Debug("skipping IRQ %d: %v", irq, err)
+				continue
+			}
+		}
+
+		cpus, err := cpuset.Parse(orig)
+		if err != nil {
+			log.Debug("skipping IRQ %d with affinity %q: %v", irq, orig, err)
+			continue
+		}
+		allowed := cpus.Difference(banned)
+		if allowed.IsEmpty() {
+			allowed = cpus
+		}
+		if !ok && allowed.Equals(cpus) {
+			continue
+		}
+
+		if err := ctl.setAffinity(irq, allowed.String()); err != nil {
+			// some interrupts (for instance per-CPU ones) can't be moved
+			log.Debug("failed to set affinity of IRQ %d: %v", irq, err)
+			continue
+		}
+		if allowed.Equals(cpus) {
+			delete(ctl.defaults, irq)
+		} else {
+			ctl.defaults[irq] = orig
+		}
+	}
+
+	ctl.banned = banned
+	ctl.updateIrqbalance()
+
+	return nil
+}
+
+// restore restores the original affinity of all IRQs we have altered.
+func (ctl *irqctl) restore() {
+	for irq, orig := range ctl.defaults {
+		if err := ctl.setAffinity(irq, orig); err != nil {
+			log.Warn("failed to restore affinity %s of IRQ %d: %v", orig, irq, err)
+		}
+		delete(ctl.defaults, irq)
+	}
+	ctl.banned = cpuset.NewCPUSet()
+	ctl.updateIrqbalance()
+}
+
+// exclusiveCPUs returns the CPUs exclusively granted to containers with IRQ steering.
+func (ctl *irqctl) exclusiveCPUs() cpuset.CPUSet {
+	containers := ctl.cache.GetContainers()
+	exclusive := cache.ExclusiveCPUs(containers)
+	steered := cpuset.NewCPUSet()
+
+	for _, c := range containers {
+		if c.IsIgnored() {
+			continue
+		}
+		if cpus, ok := exclusive[c.GetCacheID()]; ok && ctl.steerClass(c) {
+			steered = steered.Union(cpus)
+		}
+	}
+
+	return steered
+}
+
+// steerClass checks if IRQ steering is enabled for the class of the container.
+func (ctl *irqctl) steerClass(c cache.Container) bool {
+	class := c.GetRDTClass()
+	if class == "" {
+		class = string(c.GetQOSClass())
+	}
+	enabled, ok := opt.Classes[class]
+	if !ok {
+		enabled = opt.Classes["*"]
+	}
+	return enabled
+}
+
+// listIRQs lists the IRQs in the system.
+func (ctl *irqctl) listIRQs() ([]int, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(ctl.procRoot, "irq"))
+	if err != nil {
+		return nil, irqError("failed to list IRQs: %v", err)
+	}
+	irqs := []int{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if irq, err := strconv.Atoi(entry.Name()); err == nil {
+			irqs = append(irqs, irq)
+		}
+	}
+	return irqs, nil
+}
+
+// affinityPath returns the path to the affinity list of the given IRQ.
+func (ctl *irqctl) affinityPath(irq int) string {
+	return filepath.Join(ctl.procRoot, "irq", strconv.Itoa(irq), "smp_affinity_list")
+}
+
+// getAffinity returns the current affinity of the given IRQ.
+func (ctl *irqctl) getAffinity(irq int) (string, error) {
+	data, err := ioutil.ReadFile(ctl.affinityPath(irq))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// setAffinity sets the affinity of the given IRQ.
+func (ctl *irqctl) setAffinity(irq int, cpus string) error {
+	return ioutil.WriteFile(ctl.affinityPath(irq), []byte(cpus), 0644)
+}
+
+// updateIrqbalance updates the banned CPUs in the irqbalance configuration, if any.
+func (ctl *irqctl) updateIrqbalance() {
+	if opt.IrqbalanceConfig == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(opt.IrqbalanceConfig)
+	if err != nil {
+		log.Error("failed to read irqbalance configuration %s: %v", opt.IrqbalanceConfig, err)
+		return
+	}
+
+	setting := irqbalanceBanned + "=" + cpuMask(ctl.banned)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), irqbalanceBanned+"=") {
+			lines[i] = setting
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, setting)
+	}
+
+	data = []byte(strings.Join(lines, "\n") + "\n")
+	if err := ioutil.WriteFile(opt.IrqbalanceConfig, data, 0644); err != nil {
+		log.Error("failed to update irqbalance configuration %s: %v", opt.IrqbalanceConfig, err)
+	}
+}
+
+// cpuMask returns the given CPUs as a comma-separated hexadecimal mask used by irqbalance.
+func cpuMask(cpus cpuset.CPUSet) string {
+	words := []uint32{0}
+	for _, cpu := range cpus.ToSlice() {
+		for len(words) <= cpu/32 {
+			words = append(words, 0)
+		}
+		words[cpu/32] |= 1 << uint(cpu%32)
+	}
+	mask := make([]string, 0, len(words))
+	for i := len(words) - 1; i >= 0; i-- {
+		mask = append(mask, fmt.Sprintf("%08x", words[i]))
+	}
+	return strings.Join(mask, ",")
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *irqctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+	return ctl.steer()
+}
+
+// irqError creates an IRQ-controller-specific formatted error message.
+func irqError(format string, args ...interface{}) error {
+	return fmt.Errorf("irq: "+format, args...)
+}
+
+// Register us as a controller.
+func init() {
+	control.Register(IRQController, "IRQ affinity controller", getIRQController())
+}
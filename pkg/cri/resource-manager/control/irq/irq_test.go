@@ -0,0 +1,186 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package irq
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+)
+
+// fakeContainer is a running Guaranteed container with only its identity and cpuset set.
+type fakeContainer struct {
+	cache.Container
+	id   string
+	cpus string
+}
+
+func (c *fakeContainer) GetCacheID() string             { return c.id }
+func (c *fakeContainer) PrettyName() string             { return "pod/" + c.id }
+func (c *fakeContainer) IsIgnored() bool                { return false }
+func (c *fakeContainer) GetCpusetCpus() string          { return c.cpus }
+func (c *fakeContainer) GetState() cache.ContainerState { return cache.ContainerStateRunning }
+func (c *fakeContainer) GetRDTClass() string            { return "" }
+func (c *fakeContainer) GetQOSClass() v1.PodQOSClass    { return v1.PodQOSGuaranteed }
+
+// setupProcfs creates a fake procfs with IRQs of the given affinities.
+func setupProcfs(t *testing.T, affinities map[int]string) string {
+	root, err := ioutil.TempDir("", "irq-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	for irq, cpus := range affinities {
+		dir := filepath.Join(root, "irq", strconv.Itoa(irq))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		path := filepath.Join(dir, "smp_affinity_list")
+		if err := ioutil.WriteFile(path, []byte(cpus+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	return root
+}
+
+// setOptions sets the controller configuration, returning a function to restore it.
+func setOptions(o *options) func() {
+	saved := *opt
+	*opt = *o
+	return func() { *opt = saved }
+}
+
+func TestSteer(t *testing.T) {
+	root := setupProcfs(t, map[int]string{
+		0: "0-3", // steered away from the exclusive CPUs
+		1: "2-3", // would be left without CPUs, kept as is
+		2: "4-7", // only on shared CPUs, untouched
+	})
+	defer os.RemoveAll(root)
+
+	irqbalance := filepath.Join(root, "irqbalance")
+	if err := ioutil.WriteFile(irqbalance, []byte("IRQBALANCE_ONESHOT=0\n"), 0644); err != nil {
+		t.Fatalf("failed to write irqbalance configuration: %v", err)
+	}
+	defer setOptions(&options{
+		Classes:          map[string]bool{"*": true},
+		IrqbalanceConfig: irqbalance,
+	})()
+
+	exclusive := &fakeContainer{id: "exclusive", cpus: "2-3"}
+	shared1 := &fakeContainer{id: "shared1", cpus: "4-7"}
+	shared2 := &fakeContainer{id: "shared2", cpus: "4-7"}
+	cch := &cachetest.FakeCache{Containers: []cache.Container{exclusive, shared1, shared2}}
+	ctl := &irqctl{
+		cache:    cch,
+		procRoot: root,
+		defaults: make(map[int]string),
+		banned:   cpuset.NewCPUSet(),
+	}
+
+	check := func(step string, expected map[int]string, banned string) {
+		for irq, cpus := range expected {
+			if affinity, err := ctl.getAffinity(irq); err != nil || affinity != cpus {
+				t.Errorf("%s: expected IRQ %d affinity %q, got %q (error %v)",
+					step, irq, cpus, affinity, err)
+			}
+		}
+		data, err := ioutil.ReadFile(irqbalance)
+		if err != nil {
+			t.Fatalf("%s: failed to read irqbalance configuration: %v", step, err)
+		}
+		expectedCfg := "IRQBALANCE_ONESHOT=0\n" + irqbalanceBanned + "=" + banned + "\n"
+		if string(data) != expectedCfg {
+			t.Errorf("%s: expected irqbalance configuration %q, got %q", step, expectedCfg, string(data))
+		}
+	}
+
+	if err := ctl.steer(); err != nil {
+		t.Fatalf("failed to steer IRQs: %v", err)
+	}
+	check("steered", map[int]string{0: "0-1", 1: "2-3", 2: "4-7"}, "0000000c")
+	if len(ctl.defaults) != 1 || ctl.defaults[0] != "0-3" {
+		t.Errorf("expected only the original affinity of IRQ 0 recorded, got %v", ctl.defaults)
+	}
+
+	// the exclusive grant is released
+	cch.Containers = []cache.Container{shared1, shared2}
+	if err := ctl.steer(); err != nil {
+		t.Fatalf("failed to steer IRQs: %v", err)
+	}
+	check("released", map[int]string{0: "0-3", 1: "2-3", 2: "4-7"}, "00000000")
+	if len(ctl.defaults) != 0 {
+		t.Errorf("expected no recorded affinities after release, got %v", ctl.defaults)
+	}
+
+	// the controller is stopped with an exclusive grant
+	cch.Containers = []cache.Container{exclusive, shared1, shared2}
+	if err := ctl.steer(); err != nil {
+		t.Fatalf("failed to steer IRQs: %v", err)
+	}
+	ctl.restore()
+	check("restored", map[int]string{0: "0-3", 1: "2-3", 2: "4-7"}, "00000000")
+	if len(ctl.defaults) != 0 || !ctl.banned.IsEmpty() {
+		t.Errorf("expected no recorded affinities or banned CPUs after restore, got %v, %s",
+			ctl.defaults, ctl.banned)
+	}
+}
+
+func TestSteerDisabledClass(t *testing.T) {
+	root := setupProcfs(t, map[int]string{0: "0-3"})
+	defer os.RemoveAll(root)
+	defer setOptions(&options{Classes: map[string]bool{"*": true, "Guaranteed": false}})()
+
+	ctl := &irqctl{
+		cache: &cachetest.FakeCache{
+			Containers: []cache.Container{&fakeContainer{id: "exclusive", cpus: "2-3"}},
+		},
+		procRoot: root,
+		defaults: make(map[int]string),
+		banned:   cpuset.NewCPUSet(),
+	}
+	if err := ctl.steer(); err != nil {
+		t.Fatalf("failed to steer IRQs: %v", err)
+	}
+	if affinity, _ := ctl.getAffinity(0); affinity != "0-3" {
+		t.Errorf("expected IRQ 0 affinity 0-3 without steering, got %q", affinity)
+	}
+}
+
+func TestCpuMask(t *testing.T) {
+	tcases := []struct {
+		cpus     string
+		expected string
+	}{
+		{cpus: "", expected: "00000000"},
+		{cpus: "0-1", expected: "00000003"},
+		{cpus: "31", expected: "80000000"},
+		{cpus: "32", expected: "00000001,00000000"},
+		{cpus: "0,33,64", expected: "00000001,00000002,00000001"},
+		{cpus: "28-35", expected: "0000000f,f0000000"},
+	}
+	for _, tc := range tcases {
+		if mask := cpuMask(cpuset.MustParse(tc.cpus)); mask != tc.expected {
+			t.Errorf("expected mask %s for CPUs %q, got %s", tc.expected, tc.cpus, mask)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+var configHelp = `
+Resource Manager memory QoS controller.
+
+The memory QoS controller protects the memory of containers from reclaim
+using the memory.min and memory.low cgroup v2 controls. The protected amount
+is the memory request of the container, optionally scaled down to the given
+percentage. Protection with memory.min is unconditional while memory.low is a
+best-effort protection, only overridden if there is no unprotected memory to
+reclaim. Since protection is hierarchical, the protection of the ancestor
+cgroups of containers is updated to cover their children. Protection already
+set by others, for instance by kubelet, is never lowered, and it is restored
+when the controller is stopped.
+
+The type of protection is configured per QoS class. By default Guaranteed
+containers are protected with memory.min and Burstable ones with memory.low,
+while BestEffort containers, which have no memory request, are not protected.
+'*' can be used to set the protection of all other classes.
+
+Here is a sample configuration fragment to protect 90% of the requested memory
+of Guaranteed and Burstable containers with memory.low:
+
+  memory:
+    Classes:
+      Guaranteed: low
+      Burstable: low
+    RequestPercentage: 90
+
+The controller also applies the memory pinning mode the policy selected for
+a container, which the topology-aware policy takes from the memory-pinning
+pod annotation. The modes differ in what happens when the memory nodes of a
+container run out of memory:
+
+  strict: memory is allocated only from the pinned nodes. Exhausting them
+    triggers an OOM kill constrained to the cpuset, even if other nodes
+    still have free memory. This is the default.
+  preferred: the memory nodes of the container are widened to all nodes
+    while its CPUs stay pinned, so allocations are first served locally
+    and spill over to remote nodes instead of triggering an OOM kill.
+    Spilled pages stay remote until freed, at the cost of access latency.
+  spread-cache: page cache and slab allocations are spread over the pinned
+    nodes with the cgroup v1 cpuset.memory_spread_page and
+    cpuset.memory_spread_slab controls. Anonymous memory is not interleaved,
+    it follows the allocation policy of the process. Like strict, exhausting
+    the pinned nodes triggers an OOM kill.
+
+Page cache spreading requires the cgroup v1 cpuset hierarchy and memory protection
+requires the cgroup v2 memory controller. Either is disabled if unavailable.
+`
@@ -0,0 +1,66 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// Classes maps QoS classes to the type of memory protection.
+	Classes map[string]string `json:",omitempty"`
+	// RequestPercentage is the percentage of the memory request to protect.
+	RequestPercentage int `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		Classes: map[string]string{
+			"Guaranteed": ProtectionMin,
+			"Burstable":  ProtectionLow,
+		},
+		RequestPercentage: 100,
+	}
+}
+
+// validateOptions checks that all configured protection types are known.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return memoryError("invalid configuration type %T", cfg)
+	}
+	for class, protection := range o.Classes {
+		if _, ok := protectionFiles[protection]; !ok && protection != ProtectionNone {
+			return memoryError("invalid memory protection %q for class %s", protection, class)
+		}
+	}
+	if o.RequestPercentage < 0 || o.RequestPercentage > 100 {
+		return memoryError("invalid RequestPercentage %d, expecting 0-100", o.RequestPercentage)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.memory", configHelp, opt, defaultOptions,
+		config.WithNotify(getMemoryController().(*memctl).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
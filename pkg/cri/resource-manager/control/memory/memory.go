@@ -0,0 +1,421 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// MemoryController is the name of the memory QoS controller.
+	MemoryController = "memory"
+
+	// ProtectionMin protects memory unconditionally from reclaim (memory.min).
+	ProtectionMin = "min"
+	// ProtectionLow protects memory from reclaim on a best-effort basis (memory.low).
+	ProtectionLow = "low"
+	// ProtectionNone disables memory protection.
+	ProtectionNone = "none"
+
+	// cpusetRoot is the mount point of the cgroup v1 cpuset hierarchy.
+	cpusetRoot = "/sys/fs/cgroup/cpuset"
+)
+
+// protection files, by protection type
+var protectionFiles = map[string]string{
+	ProtectionMin: "memory.min",
+	ProtectionLow: "memory.low",
+}
+
+// pinningFiles are the cgroup v1 cpuset controls of page cache and slab spreading.
+var pinningFiles = []string{"cpuset.memory_spread_page", "cpuset.memory_spread_slab"}
+
+// memctl encapsulates the runtime state of our memory QoS controller.
+type memctl struct {
+	cache  cache.Cache       // resource manager cache
+	root   string            // cgroup v2 mount point, empty if protection is unavailable
+	cpuset string            // cgroup v1 cpuset mount point, empty if spreading is unavailable
+	cgroup map[string]string // cgroup directories of containers we protect
+	spread map[string]string // cpuset directories of containers we spread the cache of
+	floor  map[string]int64  // protection set by others (kubelet), by protection file path
+}
+
+// Our singleton memory QoS controller instance.
+var singleton *memctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(MemoryController)
+
+// resolveCgroupPath resolves the cgroup directory of a container, overridable for testing.
+var resolveCgroupPath = cgroups.ResolveCgroupPath
+
+// getMemoryController returns our singleton memory QoS controller instance.
+func getMemoryController() control.Controller {
+	if singleton == nil {
+		singleton = &memctl{
+			cgroup: make(map[string]string),
+			spread: make(map[string]string),
+			floor:  make(map[string]int64),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *memctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(MemoryController); err != nil {
+		return err
+	}
+
+	ctl.root = cgroups.V2path
+	data, err := ioutil.ReadFile(filepath.Join(ctl.root, "cgroup.controllers"))
+	switch {
+	case err != nil:
+		err = memoryError("cgroup v2 not available at %s: %v", ctl.root, err)
+	case !hasController(string(data), "memory"):
+		err = memoryError("cgroup v2 memory controller not available at %s", ctl.root)
+	}
+	if err != nil {
+		ctl.root = ""
+	}
+
+	ctl.cpuset = cpusetRoot
+	if _, serr := os.Stat(filepath.Join(ctl.cpuset, pinningFiles[0])); serr != nil {
+		ctl.cpuset = ""
+	}
+
+	switch {
+	case ctl.root == "" && ctl.cpuset == "":
+		return err
+	case ctl.root == "":
+		log.Warn("memory protection disabled: %v", err)
+	case ctl.cpuset == "":
+		log.Info("no cgroup v1 cpuset hierarchy, page cache spreading disabled")
+	}
+
+	ctl.cache = cache
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *memctl) Stop() {
+	ctl.restore()
+}
+
+// PreCreateHook is the memory QoS controller pre-create hook.
+func (ctl *memctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the memory QoS controller pre-start hook.
+func (ctl *memctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the memory QoS controller post-start hook.
+func (ctl *memctl) PostStartHook(c cache.Container) error {
+	if err := ctl.protect(c); err != nil {
+		return err
+	}
+	return ctl.spreadCache(c)
+}
+
+// PostUpdateHook is the memory QoS controller post-update hook.
+func (ctl *memctl) PostUpdateHook(c cache.Container) error {
+	if c.GetState() != cache.ContainerStateRunning {
+		return nil
+	}
+	if err := ctl.protect(c); err != nil {
+		return err
+	}
+	return ctl.spreadCache(c)
+}
+
+// PostStopHook is the memory QoS controller post-stop hook.
+func (ctl *memctl) PostStopHook(c cache.Container) error {
+	delete(ctl.spread, c.GetCacheID())
+
+	dir, ok := ctl.cgroup[c.GetCacheID()]
+	if !ok {
+		return nil
+	}
+	delete(ctl.cgroup, c.GetCacheID())
+
+	// the container cgroup might already be gone, update its ancestors
+	for _, file := range protectionFiles {
+		delete(ctl.floor, filepath.Join(dir, file))
+		ctl.propagate(filepath.Dir(dir), file)
+	}
+	return nil
+}
+
+// protect sets the memory protection of the container according to its class.
+func (ctl *memctl) protect(c cache.Container) error {
+	if ctl.root == "" {
+		return nil
+	}
+
+	protection := ctl.protectionType(c)
+	amount := ctl.protectedAmount(c, protection)
+
+	dir, ok := ctl.cgroup[c.GetCacheID()]
+	if !ok {
+		if protection == ProtectionNone || amount == 0 {
+			return nil
+		}
+		resolved, err := resolveCgroupPath(ctl.root, c.GetID())
+		if err != nil {
+			return memoryError("failed to find cgroup v2 directory of %s: %v", c.PrettyName(), err)
+		}
+		dir = resolved
+		ctl.cgroup[c.GetCacheID()] = dir
+	}
+
+	for kind, file := range protectionFiles {
+		value := int64(0)
+		if kind == protection {
+			value = amount
+		}
+		changed, err := ctl.setProtection(filepath.Join(dir, file), value)
+		if err != nil {
+			return memoryError("%s: failed to set %s to %d: %v", c.PrettyName(), file, value, err)
+		}
+		if changed {
+			log.Info("%s: %s set to %d", c.PrettyName(), file, value)
+			ctl.propagate(filepath.Dir(dir), file)
+		}
+	}
+
+	return nil
+}
+
+// spreadCache sets the page cache and slab spreading of the container by its memory pinning mode.
+//
+// The page cache and slab allocations of containers tagged with spread-cache
+// memory pinning by the policy are spread over their memory nodes using the
+// cgroup v1 cpuset controls. This is not MPOL_INTERLEAVE: anonymous memory is
+// allocated by the policy of the process, which no cgroup control can change,
+// so it stays confined to the memory nodes like with strict pinning. Spreading
+// is turned off again if the mode of the container changes.
+func (ctl *memctl) spreadCache(c cache.Container) error {
+	if ctl.cpuset == "" {
+		return nil
+	}
+
+	mode, _ := c.GetTag(cache.TagMemoryPinning)
+	enable := mode == cache.MemoryPinningSpreadCache
+
+	dir, ok := ctl.spread[c.GetCacheID()]
+	if !ok {
+		if !enable {
+			return nil
+		}
+		resolved, err := resolveCgroupPath(ctl.cpuset, c.GetID())
+		if err != nil {
+			return memoryError("failed to find cpuset directory of %s: %v", c.PrettyName(), err)
+		}
+		dir = resolved
+	}
+
+	value := int64(0)
+	if enable {
+		value = 1
+	}
+	for _, file := range pinningFiles {
+		changed, err := writeValue(filepath.Join(dir, file), value)
+		if err != nil {
+			return memoryError("%s: failed to set %s to %d: %v", c.PrettyName(), file, value, err)
+		}
+		if changed {
+			log.Info("%s: %s set to %d", c.PrettyName(), file, value)
+		}
+	}
+
+	if enable {
+		ctl.spread[c.GetCacheID()] = dir
+	} else {
+		delete(ctl.spread, c.GetCacheID())
+	}
+
+	return nil
+}
+
+// propagate updates the protection of ancestor cgroups to cover their children.
+//
+// Memory protection is hierarchical: the effective protection of a cgroup is
+// limited by that of its ancestors. Unless the hierarchy is mounted with the
+// memory_recursiveprot option, we need to protect every ancestor of a cgroup
+// with at least the sum of the protection of its children. Protection set by
+// others, for instance by kubelet for pods and QoS classes, is never lowered.
+func (ctl *memctl) propagate(dir, file string) {
+	for ; strings.HasPrefix(dir, ctl.root+"/"); dir = filepath.Dir(dir) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Debug("failed to list cgroup %s: %v", dir, err)
+			continue
+		}
+		sum := int64(0)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if value, err := readValue(filepath.Join(dir, entry.Name(), file)); err == nil {
+				sum += value
+			}
+		}
+		if _, err := ctl.setProtection(filepath.Join(dir, file), sum); err != nil {
+			log.Warn("failed to set %s of %s to %d: %v", file, dir, sum, err)
+			return
+		}
+	}
+}
+
+// setProtection sets a protection file, but never below the value set by others.
+//
+// The value found in a file the first time we touch it is recorded as the floor
+// for the file. Files with a value we can't parse, typically "max", are left alone.
+func (ctl *memctl) setProtection(path string, value int64) (bool, error) {
+	floor, ok := ctl.floor[path]
+	if !ok {
+		current, err := readValue(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, err
+			}
+			log.Debug("leaving %s alone: %v", path, err)
+			return false, nil
+		}
+		floor = current
+		ctl.floor[path] = floor
+	}
+	if value < floor {
+		value = floor
+	}
+	return writeValue(path, value)
+}
+
+// restore restores the protection set by others in all files we have changed.
+func (ctl *memctl) restore() {
+	for path, floor := range ctl.floor {
+		if _, err := writeValue(path, floor); err != nil && !os.IsNotExist(err) {
+			log.Warn("failed to restore %s to %d: %v", path, floor, err)
+		}
+	}
+	ctl.floor = make(map[string]int64)
+	ctl.cgroup = make(map[string]string)
+}
+
+// protectionType returns the type of memory protection for the container.
+func (ctl *memctl) protectionType(c cache.Container) string {
+	class := string(c.GetQOSClass())
+	protection, ok := opt.Classes[class]
+	if !ok {
+		if protection, ok = opt.Classes["*"]; !ok {
+			protection = ProtectionNone
+		}
+	}
+	if _, ok := protectionFiles[protection]; !ok {
+		protection = ProtectionNone
+	}
+
+	log.Debug("memory protection for %s (%s): %q", c.PrettyName(), class, protection)
+
+	return protection
+}
+
+// protectedAmount returns the amount of memory to protect for the container.
+func (ctl *memctl) protectedAmount(c cache.Container, protection string) int64 {
+	if protection == ProtectionNone {
+		return 0
+	}
+	req, ok := c.GetResourceRequirements().Requests[corev1.ResourceMemory]
+	if !ok {
+		return 0
+	}
+	return req.Value() * int64(opt.RequestPercentage) / 100
+}
+
+// readValue reads a numeric cgroup value, treating "max" as an error.
+func readValue(path string) (int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// writeValue writes a numeric cgroup value if it differs from the current one.
+func writeValue(path string, value int64) (bool, error) {
+	if current, err := readValue(path); err == nil && current == value {
+		return false, nil
+	}
+	if err := ioutil.WriteFile(path, []byte(strconv.FormatInt(value, 10)), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasController checks if a controller is listed in cgroup.controllers.
+func hasController(controllers, name string) bool {
+	for _, c := range strings.Fields(controllers) {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *memctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	if ctl.cache == nil {
+		return nil
+	}
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.protect(c); err != nil {
+			log.Error("failed to update memory protection of %s: %v", c.PrettyName(), err)
+		}
+	}
+
+	return nil
+}
+
+// memoryError creates a memory-controller-specific formatted error message.
+func memoryError(format string, args ...interface{}) error {
+	return fmt.Errorf("memory: "+format, args...)
+}
+
+// Register us as a controller.
+func init() {
+	control.Register(MemoryController, "Memory QoS controller", getMemoryController())
+}
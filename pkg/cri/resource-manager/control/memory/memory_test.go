@@ -0,0 +1,218 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakeContainer is a container with only its identity, class, request and tags set.
+type fakeContainer struct {
+	cache.Container
+	id      string
+	qos     corev1.PodQOSClass
+	request string
+	tags    map[string]string
+}
+
+func (c *fakeContainer) GetID() string                    { return c.id }
+func (c *fakeContainer) GetCacheID() string               { return "cache-" + c.id }
+func (c *fakeContainer) PrettyName() string               { return "pod/" + c.id }
+func (c *fakeContainer) GetQOSClass() corev1.PodQOSClass  { return c.qos }
+func (c *fakeContainer) GetState() cache.ContainerState   { return cache.ContainerStateRunning }
+func (c *fakeContainer) GetTag(key string) (string, bool) { v, ok := c.tags[key]; return v, ok }
+func (c *fakeContainer) GetResourceRequirements() corev1.ResourceRequirements {
+	if c.request == "" {
+		return corev1.ResourceRequirements{}
+	}
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse(c.request),
+		},
+	}
+}
+
+// setupCgroups creates a fake cgroup hierarchy with the given files.
+func setupCgroups(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "memory-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// setResolver resolves containers to the directory named after their ID.
+func setResolver() func() {
+	saved := resolveCgroupPath
+	resolveCgroupPath = func(hierarchyDir, id string) (string, error) {
+		return filepath.Join(hierarchyDir, "kubepods", id), nil
+	}
+	return func() { resolveCgroupPath = saved }
+}
+
+// newTestMemctl returns a controller for the fake v2 and cpuset hierarchies.
+func newTestMemctl(root, cpuset string) *memctl {
+	return &memctl{
+		root:   root,
+		cpuset: cpuset,
+		cgroup: make(map[string]string),
+		spread: make(map[string]string),
+		floor:  make(map[string]int64),
+	}
+}
+
+func TestSpreadCache(t *testing.T) {
+	defer setResolver()()
+
+	cpuset := setupCgroups(t, map[string]string{
+		"kubepods/ctr/cpuset.memory_spread_page": "0",
+		"kubepods/ctr/cpuset.memory_spread_slab": "0",
+	})
+	defer os.RemoveAll(cpuset)
+
+	ctl := newTestMemctl("", cpuset)
+	ctr := &fakeContainer{id: "ctr", tags: map[string]string{}}
+	check := func(expected string) {
+		for _, file := range pinningFiles {
+			path := filepath.Join(cpuset, "kubepods/ctr", file)
+			if value := readFile(t, path); value != expected {
+				t.Errorf("expected %s to be %s, got %s", file, expected, value)
+			}
+		}
+	}
+
+	for _, mode := range []string{"", cache.MemoryPinningStrict, cache.MemoryPinningPreferred} {
+		if mode != "" {
+			ctr.tags[cache.TagMemoryPinning] = mode
+		}
+		if err := ctl.spreadCache(ctr); err != nil {
+			t.Fatalf("unexpected error for mode %q: %v", mode, err)
+		}
+		check("0")
+		if _, ok := ctl.spread[ctr.GetCacheID()]; ok {
+			t.Errorf("unexpected spreading for mode %q", mode)
+		}
+	}
+
+	ctr.tags[cache.TagMemoryPinning] = cache.MemoryPinningSpreadCache
+	if err := ctl.spreadCache(ctr); err != nil {
+		t.Fatalf("failed to spread cache: %v", err)
+	}
+	check("1")
+
+	ctr.tags[cache.TagMemoryPinning] = cache.MemoryPinningStrict
+	if err := ctl.spreadCache(ctr); err != nil {
+		t.Fatalf("failed to stop spreading cache: %v", err)
+	}
+	check("0")
+	if _, ok := ctl.spread[ctr.GetCacheID()]; ok {
+		t.Errorf("expected spreading to be forgotten")
+	}
+
+	ctl.cpuset = ""
+	ctr.tags[cache.TagMemoryPinning] = cache.MemoryPinningSpreadCache
+	if err := ctl.spreadCache(ctr); err != nil {
+		t.Fatalf("unexpected error without cpuset hierarchy: %v", err)
+	}
+	check("0")
+}
+
+func TestProtect(t *testing.T) {
+	defer setResolver()()
+
+	savedOpt := *opt
+	defer func() { *opt = savedOpt }()
+	*opt = *defaultOptions().(*options)
+
+	root := setupCgroups(t, map[string]string{
+		"kubepods/memory.min":     "0",
+		"kubepods/memory.low":     "4096",
+		"kubepods/ctr/memory.min": "0",
+		"kubepods/ctr/memory.low": "0",
+	})
+	defer os.RemoveAll(root)
+
+	ctl := newTestMemctl(root, "")
+	ctr := &fakeContainer{id: "ctr", qos: corev1.PodQOSGuaranteed, request: "1Mi"}
+
+	if err := ctl.protect(ctr); err != nil {
+		t.Fatalf("failed to protect container: %v", err)
+	}
+	expected := map[string]string{
+		"kubepods/ctr/memory.min": "1048576",
+		"kubepods/ctr/memory.low": "0",
+		"kubepods/memory.min":     "1048576",
+		"kubepods/memory.low":     "4096",
+	}
+	for file, value := range expected {
+		if v := readFile(t, filepath.Join(root, file)); v != value {
+			t.Errorf("expected %s to be %s, got %s", file, value, v)
+		}
+	}
+
+	// the protection kubelet set for the parent is never lowered
+	ctr.qos = corev1.PodQOSBurstable
+	ctr.request = "2k"
+	if err := ctl.protect(ctr); err != nil {
+		t.Fatalf("failed to update protection: %v", err)
+	}
+	expected = map[string]string{
+		"kubepods/ctr/memory.min": "0",
+		"kubepods/ctr/memory.low": "2000",
+		"kubepods/memory.min":     "0",
+		"kubepods/memory.low":     "4096",
+	}
+	for file, value := range expected {
+		if v := readFile(t, filepath.Join(root, file)); v != value {
+			t.Errorf("expected %s to be %s, got %s", file, value, v)
+		}
+	}
+
+	ctl.restore()
+	for file, value := range map[string]string{
+		"kubepods/ctr/memory.low": "0",
+		"kubepods/memory.low":     "4096",
+	} {
+		if v := readFile(t, filepath.Join(root, file)); v != value {
+			t.Errorf("expected %s to be restored to %s, got %s", file, value, v)
+		}
+	}
+}
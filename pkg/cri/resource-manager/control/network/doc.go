@@ -0,0 +1,58 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+var configHelp = `
+Resource Manager network class enforcement controller.
+
+The network controller classifies the egress traffic of containers so that
+traffic shaping configured on the node, for instance with tc qdiscs and
+classes, can differentiate between containers. It takes the assigned network
+class of a container and programs the corresponding traffic classification
+for the container's cgroup. If the container is not assigned to any network
+class by a policy, the controller uses the class given in the network-class
+pod annotation, or the QOS class of the container.
+
+The annotation value is either a class name, used for all containers of the
+pod, or a map of container names to class names:
+
+  cri-resource-manager.intel.com/network-class: latency-critical
+
+With net_cls/net_prio cgroups available, the classid and the per-interface
+priority of the container are set in the cgroup, the latter for the interfaces
+of the network namespace of the pod. With cgroup v2 only, an nftables rule is
+added in the network namespace of the pod to set the priority (the tc class)
+of the traffic originating from the cgroup of the container. This needs the
+nft command.
+
+The controller can be configured to map the containers' assigned class to
+a real network class, similarly to the block I/O and RDT controllers.
+
+Here is a sample configuration fragment defining 2 network classes and a
+class mapping for the 3 Kubernetes QoS classes:
+
+  network:
+    NetworkClasses:
+      latency-critical:
+        ClassID: "1:10"
+        Priority: 6
+      bulk:
+        ClassID: "1:30"
+        Priority: 1
+    Classes:
+      Guaranteed: latency-critical
+      BestEffort: bulk
+      "*": ""
+`
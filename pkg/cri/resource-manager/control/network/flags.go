@@ -0,0 +1,73 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// Classes is an assigned to actual network class map.
+	Classes map[string]string `json:",omitempty"`
+	// NetworkClasses defines the traffic classification of network classes.
+	NetworkClasses map[string]*NetworkClass `json:",omitempty"`
+	// Interfaces are the interfaces to set net_prio priorities for, all by default.
+	Interfaces []string `json:",omitempty"`
+}
+
+// NetworkClass defines how egress traffic of a network class is classified.
+type NetworkClass struct {
+	// ClassID is the traffic control class, in MAJOR:MINOR hex notation, of egress traffic.
+	ClassID string `json:",omitempty"`
+	// Priority is the net_prio priority of egress traffic.
+	Priority int `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		Classes:        make(map[string]string),
+		NetworkClasses: make(map[string]*NetworkClass),
+	}
+}
+
+// validateOptions checks that the class IDs of all network classes are valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return networkError("invalid configuration type %T", cfg)
+	}
+	for name, nc := range o.NetworkClasses {
+		if nc == nil || nc.ClassID == "" {
+			continue
+		}
+		if _, err := parseClassID(nc.ClassID); err != nil {
+			return networkError("network class %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.network", configHelp, opt, defaultOptions,
+		config.WithNotify(getNetworkController().(*network).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
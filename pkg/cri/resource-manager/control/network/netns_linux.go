@@ -0,0 +1,60 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// netNsPath returns the path of the network namespace of a process.
+func netNsPath(pid int) string {
+	return "/proc/" + strconv.Itoa(pid) + "/ns/net"
+}
+
+// netNsID returns an identifier of the network namespace of a process.
+func netNsID(pid int) (string, error) {
+	return os.Readlink(netNsPath(pid))
+}
+
+// inNetNs runs a function in the network namespace of a process.
+//
+// Interface names and nftables rulesets are per network namespace, so
+// the network classification of a container has to be set up from within
+// the network namespace of its pod. We do this on a dedicated OS thread,
+// which is never unlocked and therefore discarded together with the namespace.
+// Any commands executed by the function inherit the namespace of the thread.
+func inNetNs(pid int, fn func() error) error {
+	errC := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		ns, err := os.Open(netNsPath(pid))
+		if err != nil {
+			errC <- err
+			return
+		}
+		err = unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET)
+		ns.Close()
+		if err != nil {
+			errC <- err
+			return
+		}
+		errC <- fn()
+	}()
+	return <-errC
+}
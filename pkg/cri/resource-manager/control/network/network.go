@@ -0,0 +1,532 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// NetworkController is the name of the network controller.
+	NetworkController = cache.Network
+	// keyNetworkClass is the annotation key for the network class of containers.
+	keyNetworkClass = "network-class"
+
+	// nftTable is the nftables table we classify cgroup v2 traffic in, per pod network namespace.
+	nftTable = "cri-resmgr"
+	// nftChain is the nftables chain we classify cgroup v2 traffic in, per pod network namespace.
+	nftChain = "output"
+)
+
+// cgroup modes we can enforce network classes with
+const (
+	modeNone   = ""
+	modeNetCls = "net_cls"
+	modeNft    = "nftables"
+)
+
+// network encapsulates the runtime state of our network controller.
+type network struct {
+	cache  cache.Cache // resource manager cache
+	mode   string      // enforcement mode, net_cls/net_prio or cgroup v2 + nftables
+	v1Root string      // cgroup v1 mount point
+}
+
+// Our singleton network controller instance.
+var singleton *network
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(NetworkController)
+
+// getNetworkController returns our singleton network controller instance.
+func getNetworkController() control.Controller {
+	if singleton == nil {
+		singleton = &network{
+			v1Root: "/sys/fs/cgroup",
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *network) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(NetworkController); err != nil {
+		return err
+	}
+
+	ctl.cache = cache
+
+	switch {
+	case cgroups.IsDir(filepath.Join(ctl.v1Root, "net_cls")):
+		ctl.mode = modeNetCls
+	case cgroups.IsFile(filepath.Join(cgroups.V2path, "cgroup.controllers")):
+		if _, err := exec.LookPath("nft"); err != nil {
+			return networkError("cgroup v2 network classification needs nft: %v", err)
+		}
+		ctl.mode = modeNft
+	default:
+		return networkError("neither net_cls cgroup nor cgroup v2 found")
+	}
+
+	log.Info("enforcing network classes using %s", ctl.mode)
+
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *network) Stop() {
+	if ctl.mode == modeNft && ctl.cache != nil {
+		ctl.deleteNftTables()
+	}
+	ctl.mode = modeNone
+}
+
+// PreCreateHook is the network controller pre-create hook.
+func (ctl *network) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the network controller pre-start hook.
+func (ctl *network) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the network controller post-start hook.
+func (ctl *network) PostStartHook(c cache.Container) error {
+	// Notes:
+	//   Like the block I/O controller, we assign a class here even if
+	//   there are no pending changes for the container, since we might
+	//   fall back to an annotated class or the QoS class of the container.
+	if err := ctl.assign(c, ctl.NetworkClass(c)); err != nil {
+		return err
+	}
+	c.ClearPending(NetworkController)
+	return nil
+}
+
+// PostUpdateHook is the network controller post-update hook.
+func (ctl *network) PostUpdateHook(c cache.Container) error {
+	if !c.HasPending(NetworkController) {
+		return nil
+	}
+	if err := ctl.assign(c, ctl.NetworkClass(c)); err != nil {
+		return err
+	}
+	c.ClearPending(NetworkController)
+	return nil
+}
+
+// PostStopHook is the network controller post-stop hook.
+func (ctl *network) PostStopHook(c cache.Container) error {
+	if ctl.mode == modeNft {
+		return ctl.deleteNftRulesOf(c)
+	}
+	return nil
+}
+
+// assign assigns the container to the given network class.
+func (ctl *network) assign(c cache.Container, class string) error {
+	if class == "" || ctl.mode == modeNone {
+		return nil
+	}
+
+	nc, ok := opt.NetworkClasses[class]
+	if !ok {
+		log.Debug("%s: no network class %q defined, ignoring it", c.PrettyName(), class)
+		return nil
+	}
+
+	var err error
+	switch ctl.mode {
+	case modeNetCls:
+		err = ctl.assignNetCls(c, nc)
+	case modeNft:
+		err = ctl.assignNft(c, nc)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("container %s assigned to network class %s", c.PrettyName(), class)
+
+	return nil
+}
+
+// assignNetCls sets the net_cls classid and net_prio priorities of the container.
+func (ctl *network) assignNetCls(c cache.Container, nc *NetworkClass) error {
+	if nc.ClassID != "" {
+		classid, err := parseClassID(nc.ClassID)
+		if err != nil {
+			return networkError("%s: %v", c.PrettyName(), err)
+		}
+		dir, err := ctl.containerDir(c, filepath.Join(ctl.v1Root, "net_cls"))
+		if err != nil {
+			return err
+		}
+		entry := filepath.Join(dir, "net_cls.classid")
+		if err := ioutil.WriteFile(entry, []byte(strconv.FormatUint(uint64(classid), 10)), 0644); err != nil {
+			return networkError("%s: failed to set classid %s: %v", c.PrettyName(), nc.ClassID, err)
+		}
+	}
+
+	if nc.Priority > 0 {
+		dir, err := ctl.containerDir(c, filepath.Join(ctl.v1Root, "net_prio"))
+		if err != nil {
+			return err
+		}
+		pid, err := containerPid(dir)
+		if err != nil {
+			return networkError("%s: %v", c.PrettyName(), err)
+		}
+
+		// interface names in ifpriomap are looked up in the namespace of the writer
+		entry := filepath.Join(dir, "net_prio.ifpriomap")
+		err = inNetNs(pid, func() error {
+			for _, ifname := range interfaces() {
+				prio := ifname + " " + strconv.Itoa(nc.Priority)
+				if err := ioutil.WriteFile(entry, []byte(prio), 0644); err != nil {
+					return fmt.Errorf("failed to set priority %q: %v", prio, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return networkError("%s: %v", c.PrettyName(), err)
+		}
+	}
+
+	return nil
+}
+
+// assignNft classifies the egress traffic of the container using an nftables rule.
+func (ctl *network) assignNft(c cache.Container, nc *NetworkClass) error {
+	if nc.ClassID == "" {
+		log.Warn("%s: network class without ClassID, priorities need net_prio",
+			c.PrettyName())
+		return nil
+	}
+	if _, err := parseClassID(nc.ClassID); err != nil {
+		return networkError("%s: %v", c.PrettyName(), err)
+	}
+
+	dir, err := ctl.containerDir(c, cgroups.V2path)
+	if err != nil {
+		return err
+	}
+	path, err := filepath.Rel(cgroups.V2path, dir)
+	if err != nil {
+		return networkError("%s: invalid cgroup v2 path %s: %v", c.PrettyName(), dir, err)
+	}
+	level := strconv.Itoa(len(strings.Split(path, "/")))
+	pid, err := containerPid(dir)
+	if err != nil {
+		return networkError("%s: %v", c.PrettyName(), err)
+	}
+
+	// the output hook only sees traffic of its own network namespace
+	err = inNetNs(pid, func() error {
+		if err := setupNft(); err != nil {
+			return err
+		}
+		if err := deleteNftRules(c); err != nil {
+			return err
+		}
+		_, err := nft("add", "rule", "inet", nftTable, nftChain,
+			"socket", "cgroupv2", "level", level, strconv.Quote(path),
+			"meta", "priority", "set", nc.ClassID,
+			"comment", strconv.Quote(c.GetID()))
+		return err
+	})
+	if err != nil {
+		return networkError("%s: failed to set up nftables rule: %v", c.PrettyName(), err)
+	}
+
+	return nil
+}
+
+// deleteNftRulesOf deletes the nftables rules of a stopped container.
+func (ctl *network) deleteNftRulesOf(c cache.Container) error {
+	// the rules are in the network namespace of the pod, which we can
+	// only enter through another live container of the pod, if any
+	pid, ok := ctl.podPid(c)
+	if !ok {
+		return nil
+	}
+	err := inNetNs(pid, func() error {
+		return deleteNftRules(c)
+	})
+	if err != nil {
+		return networkError("%s: %v", c.PrettyName(), err)
+	}
+	return nil
+}
+
+// deleteNftTables deletes our nftables table in every pod network namespace.
+func (ctl *network) deleteNftTables() {
+	done := map[string]struct{}{}
+	for _, c := range ctl.cache.GetContainers() {
+		if c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		dir, err := ctl.containerDir(c, cgroups.V2path)
+		if err != nil {
+			continue
+		}
+		pid, err := containerPid(dir)
+		if err != nil {
+			continue
+		}
+		ns, err := netNsID(pid)
+		if err != nil {
+			continue
+		}
+		if _, ok := done[ns]; ok {
+			continue
+		}
+		done[ns] = struct{}{}
+		err = inNetNs(pid, func() error {
+			if !hasNftTable() {
+				return nil
+			}
+			_, err := nft("delete", "table", "inet", nftTable)
+			return err
+		})
+		if err != nil {
+			log.Warn("failed to remove nftables table %s of %s: %v", nftTable, ns, err)
+		}
+	}
+}
+
+// podPid returns a process in a live container of the pod of the given container.
+func (ctl *network) podPid(c cache.Container) (int, bool) {
+	pod, ok := c.GetPod()
+	if !ok {
+		return 0, false
+	}
+	for _, other := range pod.GetContainers() {
+		if other.GetID() == c.GetID() || other.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		dir, err := ctl.containerDir(other, cgroups.V2path)
+		if err != nil {
+			continue
+		}
+		if pid, err := containerPid(dir); err == nil {
+			return pid, true
+		}
+	}
+	return 0, false
+}
+
+// setupNft creates the nftables table and chain we classify traffic in.
+func setupNft() error {
+	if _, err := nft("add", "table", "inet", nftTable); err != nil {
+		return fmt.Errorf("failed to create nftables table %s: %v", nftTable, err)
+	}
+	_, err := nft("add", "chain", "inet", nftTable, nftChain,
+		"{", "type", "filter", "hook", "output", "priority", "0", ";", "}")
+	if err != nil {
+		return fmt.Errorf("failed to create nftables chain %s: %v", nftChain, err)
+	}
+	return nil
+}
+
+// hasNftTable checks if our nftables table exists.
+func hasNftTable() bool {
+	_, err := nft("list", "table", "inet", nftTable)
+	return err == nil
+}
+
+// deleteNftRules deletes all nftables rules of the container.
+func deleteNftRules(c cache.Container) error {
+	if !hasNftTable() {
+		return nil
+	}
+	out, err := nft("-a", "list", "chain", "inet", nftTable, nftChain)
+	if err != nil {
+		return fmt.Errorf("failed to list nftables chain %s: %v", nftChain, err)
+	}
+
+	tag := "comment " + strconv.Quote(c.GetID())
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.Contains(line, tag) {
+			continue
+		}
+		idx := strings.LastIndex(line, "# handle ")
+		if idx < 0 {
+			continue
+		}
+		handle := strings.TrimSpace(line[idx+len("# handle "):])
+		if _, err := nft("delete", "rule", "inet", nftTable, nftChain, "handle", handle); err != nil {
+			return fmt.Errorf("failed to delete nftables rule %s: %v", handle, err)
+		}
+	}
+
+	return nil
+}
+
+// containerDir finds the cgroup directory of the container in the given hierarchy.
+func (ctl *network) containerDir(c cache.Container, root string) (string, error) {
+	dir, err := cgroups.ResolveCgroupPath(root, c.GetID())
+	if err != nil {
+		return "", networkError("failed to find cgroup of %s in %s: %v", c.PrettyName(), root, err)
+	}
+	return dir, nil
+}
+
+// containerPid returns a process in the given container cgroup directory.
+func containerPid(dir string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return 0, err
+	}
+	for _, field := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(field); err == nil && pid > 0 {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("no processes in cgroup %s", dir)
+}
+
+// interfaces returns the network interfaces to set priorities for, in the current namespace.
+func interfaces() []string {
+	if len(opt.Interfaces) > 0 {
+		return opt.Interfaces
+	}
+
+	links, err := net.Interfaces()
+	if err != nil {
+		log.Error("failed to list network interfaces: %v", err)
+		return nil
+	}
+	ifnames := make([]string, 0, len(links))
+	for _, link := range links {
+		ifnames = append(ifnames, link.Name)
+	}
+	return ifnames
+}
+
+// NetworkClass determines the effective network class for a container.
+func (ctl *network) NetworkClass(c cache.Container) string {
+	cclass := c.GetNetworkClass()
+	if cclass == "" {
+		cclass = annotatedClass(c)
+	}
+	if cclass == "" {
+		cclass = string(c.GetQOSClass())
+	}
+
+	netclass, ok := opt.Classes[cclass]
+	if !ok {
+		if netclass, ok = opt.Classes["*"]; !ok {
+			netclass = cclass
+		}
+	}
+
+	log.Debug("network class for %s (%s): %q", c.PrettyName(), cclass, netclass)
+
+	return netclass
+}
+
+// annotatedClass returns the network class annotated for the container, if any.
+func annotatedClass(c cache.Container) string {
+	pod, ok := c.GetPod()
+	if !ok {
+		return ""
+	}
+	value, ok := pod.GetResmgrAnnotation(keyNetworkClass)
+	if !ok {
+		return ""
+	}
+
+	preferences := map[string]string{}
+	if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+		// not a per-container map, the class of all containers in the pod
+		return strings.TrimSpace(value)
+	}
+
+	return preferences[c.GetName()]
+}
+
+// parseClassID parses a traffic control class ID in MAJOR:MINOR hex notation.
+func parseClassID(id string) (uint32, error) {
+	split := strings.Split(id, ":")
+	if len(split) != 2 {
+		return 0, fmt.Errorf("invalid classid %q, expecting MAJOR:MINOR", id)
+	}
+	major, err := strconv.ParseUint(split[0], 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid classid %q major: %v", id, err)
+	}
+	minor, err := strconv.ParseUint(split[1], 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid classid %q minor: %v", id, err)
+	}
+	return uint32(major<<16 | minor), nil
+}
+
+// nft runs the nft command with the given arguments.
+func nft(args ...string) (string, error) {
+	out, err := exec.Command("nft", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("nft %s: %v (%s)", strings.Join(args, " "),
+			err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *network) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	if ctl.cache == nil || ctl.mode == modeNone {
+		return nil
+	}
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.assign(c, ctl.NetworkClass(c)); err != nil {
+			log.Error("failed to update network class of %s: %v", c.PrettyName(), err)
+		}
+	}
+
+	return nil
+}
+
+// networkError creates a network-controller-specific formatted error message.
+func networkError(format string, args ...interface{}) error {
+	return fmt.Errorf("network: "+format, args...)
+}
+
+// Register us as a controller.
+func init() {
+	control.Register(NetworkController, "Network class controller", getNetworkController())
+}
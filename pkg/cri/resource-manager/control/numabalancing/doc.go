@@ -0,0 +1,39 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package numabalancing
+
+var configHelp = `
+Resource Manager automatic NUMA balancing controller.
+
+The automatic NUMA balancing controller keeps the kernel from migrating the
+memory of pinned containers behind the back of the resource policy, while
+leaving automatic NUMA balancing enabled for containers in shared pools. A
+container is considered pinned if none of its CPUs are used by any other
+container.
+
+Automatic NUMA balancing is turned off per process, on kernels with a
+per-process /proc/<pid>/numa_balancing entry. Upstream kernels only have the
+global kernel.numa_balancing sysctl, on them the controller fails to start. The
+entry of every process of a pinned container is set to 0. Processes are
+periodically rechecked, with RecheckInterval, and any new ones are adjusted,
+too. Once a container is no longer pinned, the entries of its processes are set
+back to 1. If automatic NUMA balancing is globally disabled, the controller
+does nothing.
+
+Here is a sample configuration fragment changing the recheck interval:
+
+  numa-balancing:
+    RecheckInterval: 30s
+`
@@ -0,0 +1,56 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package numabalancing
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// RecheckInterval is the interval for adjusting new processes of pinned containers.
+	RecheckInterval control.RecheckInterval `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		RecheckInterval: "10s",
+	}
+}
+
+// validateOptions checks that the configured recheck interval is valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return numaError("invalid configuration type %T", cfg)
+	}
+	if err := o.RecheckInterval.Validate(); err != nil {
+		return numaError("%v", err)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.numa-balancing", configHelp, opt, defaultOptions,
+		config.WithNotify(getNUMABalancingController().(*numactl).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
@@ -0,0 +1,334 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package numabalancing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// NUMABalancingController is the name of the automatic NUMA balancing controller.
+	NUMABalancingController = "numa-balancing"
+
+	// numaBalancingSysctl is the global automatic NUMA balancing sysctl, relative to procfs.
+	numaBalancingSysctl = "sys/kernel/numa_balancing"
+	// numaBalancingEntry is the per-process automatic NUMA balancing entry.
+	numaBalancingEntry = "numa_balancing"
+)
+
+// getContainerPids returns the processes of a container, overridable for testing.
+var getContainerPids = utils.GetProcessInContainer
+
+// tracked is a pinned container we have disabled NUMA balancing for.
+type tracked struct {
+	name string              // pretty name of the container
+	id   string              // container ID
+	pids map[string]struct{} // processes already adjusted
+}
+
+// numactl encapsulates the runtime state of our automatic NUMA balancing controller.
+type numactl struct {
+	sync.Mutex
+	cache      cache.Cache         // resource manager cache
+	procRoot   string              // procfs mount point
+	enabled    bool                // whether NUMA balancing is globally enabled
+	containers map[string]*tracked // pinned containers, by cache ID
+	stop       chan struct{}       // channel to stop rechecking
+}
+
+// Our singleton automatic NUMA balancing controller instance.
+var singleton *numactl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(NUMABalancingController)
+
+// getNUMABalancingController returns our singleton NUMA balancing controller instance.
+func getNUMABalancingController() control.Controller {
+	if singleton == nil {
+		singleton = &numactl{
+			procRoot:   "/proc",
+			containers: make(map[string]*tracked),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *numactl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(NUMABalancingController); err != nil {
+		return err
+	}
+
+	value, err := ioutil.ReadFile(filepath.Join(ctl.procRoot, numaBalancingSysctl))
+	if err != nil {
+		return numaError("automatic NUMA balancing not supported: %v", err)
+	}
+	ctl.enabled = strings.TrimSpace(string(value)) != "0"
+
+	// upstream kernels only have the global sysctl, not the per-process entry
+	if _, err = os.Stat(filepath.Join(ctl.procRoot, "self", numaBalancingEntry)); err != nil {
+		return numaError("per-process NUMA balancing control not supported by the kernel "+
+			"(no /proc/<pid>/%s): %v", numaBalancingEntry, err)
+	}
+
+	if !ctl.enabled {
+		log.Info("automatic NUMA balancing is globally disabled, nothing to do")
+	}
+
+	ctl.cache = cache
+	ctl.update()
+	ctl.startRecheck()
+
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *numactl) Stop() {
+	ctl.stopRecheck()
+}
+
+// PreCreateHook is the NUMA balancing controller pre-create hook.
+func (ctl *numactl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the NUMA balancing controller pre-start hook.
+func (ctl *numactl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the NUMA balancing controller post-start hook.
+func (ctl *numactl) PostStartHook(c cache.Container) error {
+	ctl.update()
+	return nil
+}
+
+// PostUpdateHook is the NUMA balancing controller post-update hook.
+func (ctl *numactl) PostUpdateHook(c cache.Container) error {
+	ctl.update()
+	return nil
+}
+
+// PostStopHook is the NUMA balancing controller post-stop hook.
+func (ctl *numactl) PostStopHook(c cache.Container) error {
+	ctl.Lock()
+	defer ctl.Unlock()
+	delete(ctl.containers, c.GetCacheID())
+	return nil
+}
+
+// update brings per-process NUMA balancing in sync with the pinning of containers.
+func (ctl *numactl) update() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	if ctl.cache == nil || !ctl.enabled {
+		return
+	}
+
+	pinned := ctl.pinnedContainers()
+
+	for id, t := range ctl.containers {
+		if _, ok := pinned[id]; ok {
+			continue
+		}
+		if err := ctl.restore(t); err != nil {
+			log.Error("%v", err)
+		}
+		delete(ctl.containers, id)
+	}
+
+	for id, c := range pinned {
+		if c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		t, ok := ctl.containers[id]
+		if !ok || t.id != c.GetID() {
+			t = &tracked{
+				name: c.PrettyName(),
+				id:   c.GetID(),
+				pids: make(map[string]struct{}),
+			}
+			ctl.containers[id] = t
+		}
+		if err := ctl.disable(t); err != nil {
+			log.Error("%v", err)
+		}
+	}
+}
+
+// pinnedContainers returns the containers none of the CPUs of which are used by others.
+func (ctl *numactl) pinnedContainers() map[string]cache.Container {
+	containers := ctl.cache.GetContainers()
+	exclusive := cache.ExclusiveCPUs(containers)
+	pinned := map[string]cache.Container{}
+
+	for _, c := range containers {
+		own, ok := exclusive[c.GetCacheID()]
+		if !ok || c.IsIgnored() {
+			continue
+		}
+		if own.Equals(cpuset.MustParse(c.GetCpusetCpus())) {
+			pinned[c.GetCacheID()] = c
+		}
+	}
+
+	return pinned
+}
+
+// disable turns off NUMA balancing for any new processes of a tracked container.
+func (ctl *numactl) disable(t *tracked) error {
+	pids, err := getContainerPids(t.id)
+	if err != nil {
+		return numaError("%s: failed to get processes: %v", t.name, err)
+	}
+
+	seen := make(map[string]struct{}, len(pids))
+	for _, pid := range pids {
+		seen[pid] = struct{}{}
+		if _, ok := t.pids[pid]; ok {
+			continue
+		}
+		if err := ctl.setProcess(pid, false); err != nil {
+			return numaError("%s: %v", t.name, err)
+		}
+	}
+
+	if len(t.pids) == 0 && len(pids) > 0 {
+		log.Info("%s: NUMA balancing disabled", t.name)
+	}
+	t.pids = seen
+
+	return nil
+}
+
+// restore turns NUMA balancing back on for the processes of a no longer pinned container.
+func (ctl *numactl) restore(t *tracked) error {
+	pids, err := getContainerPids(t.id)
+	if err != nil {
+		// the container is most likely gone
+		return nil
+	}
+
+	for _, pid := range pids {
+		if err := ctl.setProcess(pid, true); err != nil {
+			return numaError("%s: %v", t.name, err)
+		}
+	}
+
+	log.Info("%s: NUMA balancing re-enabled", t.name)
+
+	return nil
+}
+
+// setProcess enables or disables NUMA balancing for a single process.
+func (ctl *numactl) setProcess(pid string, enable bool) error {
+	value := "0"
+	if enable {
+		value = "1"
+	}
+	entry := filepath.Join(ctl.procRoot, pid, numaBalancingEntry)
+	if err := ioutil.WriteFile(entry, []byte(value), 0644); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to set %s of process %s to %s: %v",
+			numaBalancingEntry, pid, value, err)
+	}
+	log.Debug("process %s %s set to %s", pid, numaBalancingEntry, value)
+	return nil
+}
+
+// startRecheck starts periodically adjusting new processes of pinned containers.
+func (ctl *numactl) startRecheck() {
+	ctl.stopRecheck()
+	interval := opt.RecheckInterval.Duration()
+	if interval <= 0 || !ctl.enabled {
+		return
+	}
+
+	stop := make(chan struct{})
+	ctl.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case _ = <-stop:
+				return
+			case _ = <-ticker.C:
+				ctl.recheck()
+			}
+		}
+	}()
+}
+
+// stopRecheck stops periodically adjusting new processes of pinned containers.
+func (ctl *numactl) stopRecheck() {
+	if ctl.stop != nil {
+		close(ctl.stop)
+		ctl.stop = nil
+	}
+}
+
+// recheck turns off NUMA balancing for any new processes of pinned containers.
+func (ctl *numactl) recheck() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for _, t := range ctl.containers {
+		if err := ctl.disable(t); err != nil {
+			log.Debug("%v", err)
+		}
+	}
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *numactl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	if ctl.cache == nil {
+		return nil
+	}
+	ctl.startRecheck()
+	ctl.update()
+
+	return nil
+}
+
+// numaError returns a controller-specific formatted error.
+func numaError(format string, args ...interface{}) error {
+	return fmt.Errorf("numa-balancing: "+format, args...)
+}
+
+// init registers this controller.
+func init() {
+	control.Register(NUMABalancingController, "automatic NUMA balancing controller",
+		getNUMABalancingController())
+}
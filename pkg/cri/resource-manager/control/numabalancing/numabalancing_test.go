@@ -0,0 +1,179 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package numabalancing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+)
+
+// fakeContainer is a running container with only its identity and cpuset set.
+type fakeContainer struct {
+	cache.Container
+	id   string
+	cpus string
+}
+
+func (c *fakeContainer) GetID() string                  { return c.id }
+func (c *fakeContainer) GetCacheID() string             { return c.id }
+func (c *fakeContainer) PrettyName() string             { return "pod/" + c.id }
+func (c *fakeContainer) IsIgnored() bool                { return false }
+func (c *fakeContainer) GetCpusetCpus() string          { return c.cpus }
+func (c *fakeContainer) GetState() cache.ContainerState { return cache.ContainerStateRunning }
+
+// setupProcfs creates a fake procfs with the given global setting and processes.
+func setupProcfs(t *testing.T, global string, perProcess bool, pids ...string) string {
+	root, err := ioutil.TempDir("", "numabalancing-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	write := func(path, value string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(value), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write(numaBalancingSysctl, global)
+	if perProcess {
+		write(filepath.Join("self", numaBalancingEntry), "1\n")
+		for _, pid := range pids {
+			write(filepath.Join(pid, numaBalancingEntry), "1\n")
+		}
+	}
+	return root
+}
+
+// readEntry reads the NUMA balancing entry of a process in a fake procfs.
+func readEntry(t *testing.T, root, pid string) string {
+	value, err := ioutil.ReadFile(filepath.Join(root, pid, numaBalancingEntry))
+	if err != nil {
+		t.Fatalf("failed to read entry of process %s: %v", pid, err)
+	}
+	return strings.TrimSpace(string(value))
+}
+
+func TestStart(t *testing.T) {
+	tcs := []struct {
+		name       string
+		global     string
+		perProcess bool
+		enabled    bool
+		err        bool
+	}{
+		{name: "no per-process entry", global: "1\n", err: true},
+		{name: "per-process entry", global: "1\n", perProcess: true, enabled: true},
+		{name: "globally disabled", global: "0\n", perProcess: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root := setupProcfs(t, tc.global, tc.perProcess)
+			defer os.RemoveAll(root)
+
+			ctl := &numactl{procRoot: root, containers: make(map[string]*tracked)}
+			err := ctl.Start(&cachetest.FakeCache{}, nil)
+			defer ctl.Stop()
+
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if err == nil && ctl.enabled != tc.enabled {
+				t.Errorf("expected enabled %v, got %v", tc.enabled, ctl.enabled)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	root := setupProcfs(t, "1\n", true, "10", "11", "12", "20", "30")
+	defer os.RemoveAll(root)
+
+	saved := getContainerPids
+	defer func() { getContainerPids = saved }()
+	pids := map[string][]string{
+		"pinned": {"10", "11"},
+		"shared": {"20"},
+		"other":  {"30"},
+	}
+	getContainerPids = func(id string) ([]string, error) {
+		p, ok := pids[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown container %s", id)
+		}
+		return p, nil
+	}
+
+	pinned := &fakeContainer{id: "pinned", cpus: "0-1"}
+	shared := &fakeContainer{id: "shared", cpus: "2-5"}
+	other := &fakeContainer{id: "other", cpus: "4-5"}
+	ctl := &numactl{
+		procRoot:   root,
+		enabled:    true,
+		cache:      &cachetest.FakeCache{Containers: []cache.Container{pinned, shared, other}},
+		containers: make(map[string]*tracked),
+	}
+
+	check := func(expected map[string]string) {
+		for pid, value := range expected {
+			if actual := readEntry(t, root, pid); actual != value {
+				t.Errorf("expected %s of process %s to be %s, got %s", numaBalancingEntry,
+					pid, value, actual)
+			}
+		}
+	}
+
+	ctl.update()
+	check(map[string]string{"10": "0", "11": "0", "20": "1", "30": "1"})
+
+	// new processes of pinned containers are picked up by rechecking
+	pids["pinned"] = append(pids["pinned"], "12")
+	ctl.recheck()
+	check(map[string]string{"12": "0"})
+
+	// processes of containers which are no longer pinned are restored
+	pinned.cpus = "0-2"
+	shared.cpus = "2-5"
+	ctl.update()
+	check(map[string]string{"10": "1", "11": "1", "12": "1", "20": "1", "30": "1"})
+	if len(ctl.containers) != 0 {
+		t.Errorf("expected no tracked containers, got %d", len(ctl.containers))
+	}
+}
+
+func TestSetProcess(t *testing.T) {
+	root := setupProcfs(t, "1\n", true, "10")
+	defer os.RemoveAll(root)
+
+	ctl := &numactl{procRoot: root}
+	if err := ctl.setProcess("10", false); err != nil {
+		t.Errorf("failed to disable NUMA balancing of process: %v", err)
+	}
+	if value := readEntry(t, root, "10"); value != "0" {
+		t.Errorf("expected NUMA balancing of process disabled, got %s", value)
+	}
+	if err := ctl.setProcess("99", false); err != nil {
+		t.Errorf("expected exited processes to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oom
+
+var configHelp = `
+Resource Manager OOM score adjustment controller.
+
+The OOM score adjustment controller sets the oom_score_adj of the processes of
+containers, so that the kernel picks victims for the OOM killer in an order
+matching the priorities of the resource policy. The adjustment is configured
+per QoS class. By default Guaranteed containers get -997 and BestEffort ones
+1000. Unless configured otherwise, the adjustment of Burstable containers is
+calculated from their memory request relative to the memory of the node, the
+same way the kubelet does it, keeping them between Guaranteed and BestEffort
+ones. '*' can be used to set the adjustment of all other classes.
+
+The adjustment can be overridden per pod or per container with the
+cri-resource-manager.intel.com/oom-score-adj annotation, either with a single
+value for all containers of the pod or with a map of container names to values.
+
+The processes of containers are periodically rechecked and any new ones, for
+instance a restarted main process, are adjusted, too. RecheckInterval sets the
+interval of these checks. Setting it to 0 disables rechecking.
+
+Here is a sample configuration fragment to protect Burstable containers from
+the OOM killer nearly as much as Guaranteed ones and to recheck every minute:
+
+  oom:
+    Classes:
+      Burstable: -900
+    RecheckInterval: 1m
+`
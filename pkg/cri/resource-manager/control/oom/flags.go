@@ -0,0 +1,75 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oom
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+)
+
+const (
+	// guaranteedScoreAdj is the default OOM score adjustment of Guaranteed containers.
+	guaranteedScoreAdj = -997
+	// bestEffortScoreAdj is the default OOM score adjustment of BestEffort containers.
+	bestEffortScoreAdj = 1000
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// Classes maps QoS classes to OOM score adjustments.
+	Classes map[string]int `json:",omitempty"`
+	// RecheckInterval is the interval for adjusting restarted or new processes.
+	RecheckInterval control.RecheckInterval `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		Classes: map[string]int{
+			"Guaranteed": guaranteedScoreAdj,
+			"BestEffort": bestEffortScoreAdj,
+		},
+		RecheckInterval: "10s",
+	}
+}
+
+// validateOptions checks that all configured OOM score adjustments are valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return oomError("invalid configuration type %T", cfg)
+	}
+	for class, score := range o.Classes {
+		if score < minScoreAdj || score > maxScoreAdj {
+			return oomError("invalid OOM score adjustment %d for class %s, expecting %d - %d",
+				score, class, minScoreAdj, maxScoreAdj)
+		}
+	}
+	if err := o.RecheckInterval.Validate(); err != nil {
+		return oomError("%v", err)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.oom", configHelp, opt, defaultOptions,
+		config.WithNotify(getOOMController().(*oomctl).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
@@ -0,0 +1,385 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oom
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// OOMController is the name of the OOM score adjustment controller.
+	OOMController = "oom"
+	// keyOOMScoreAdj is the annotation key for overriding the OOM score adjustment.
+	keyOOMScoreAdj = "oom-score-adj"
+
+	// minScoreAdj is the smallest valid OOM score adjustment, disabling OOM killing.
+	minScoreAdj = -1000
+	// maxScoreAdj is the largest valid OOM score adjustment.
+	maxScoreAdj = 1000
+)
+
+// tracked is a container we adjust the OOM score of.
+type tracked struct {
+	name  string              // pretty name of the container
+	id    string              // container ID
+	score int                 // OOM score adjustment to use
+	pids  map[string]struct{} // processes already adjusted
+}
+
+// oomctl encapsulates the runtime state of our OOM score adjustment controller.
+type oomctl struct {
+	sync.Mutex
+	cache      cache.Cache         // resource manager cache
+	procRoot   string              // procfs mount point
+	memory     int64               // memory capacity of the node
+	containers map[string]*tracked // containers we adjust, by cache ID
+	stop       chan struct{}       // channel to stop rechecking
+}
+
+// Our singleton OOM score adjustment controller instance.
+var singleton *oomctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(OOMController)
+
+// getOOMController returns our singleton OOM score adjustment controller instance.
+func getOOMController() control.Controller {
+	if singleton == nil {
+		singleton = &oomctl{
+			procRoot:   "/proc",
+			containers: make(map[string]*tracked),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *oomctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(OOMController); err != nil {
+		return err
+	}
+
+	memory, err := ctl.memoryCapacity()
+	if err != nil {
+		return oomError("failed to discover memory capacity: %v", err)
+	}
+
+	ctl.cache = cache
+	ctl.memory = memory
+	ctl.startRecheck()
+
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *oomctl) Stop() {
+	ctl.stopRecheck()
+}
+
+// PreCreateHook is the OOM controller pre-create hook.
+func (ctl *oomctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the OOM controller pre-start hook.
+func (ctl *oomctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the OOM controller post-start hook.
+func (ctl *oomctl) PostStartHook(c cache.Container) error {
+	return ctl.adjust(c)
+}
+
+// PostUpdateHook is the OOM controller post-update hook.
+func (ctl *oomctl) PostUpdateHook(c cache.Container) error {
+	if c.GetState() != cache.ContainerStateRunning {
+		return nil
+	}
+	return ctl.adjust(c)
+}
+
+// PostStopHook is the OOM controller post-stop hook.
+func (ctl *oomctl) PostStopHook(c cache.Container) error {
+	ctl.Lock()
+	defer ctl.Unlock()
+	delete(ctl.containers, c.GetCacheID())
+	return nil
+}
+
+// adjust sets the OOM score adjustment of all processes of the container.
+func (ctl *oomctl) adjust(c cache.Container) error {
+	score := ctl.scoreAdj(c)
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	t, ok := ctl.containers[c.GetCacheID()]
+	if !ok || t.score != score || t.id != c.GetID() {
+		t = &tracked{
+			name:  c.PrettyName(),
+			id:    c.GetID(),
+			score: score,
+			pids:  make(map[string]struct{}),
+		}
+		ctl.containers[c.GetCacheID()] = t
+	}
+
+	return ctl.apply(t)
+}
+
+// apply sets the OOM score adjustment of any new processes of a tracked container.
+func (ctl *oomctl) apply(t *tracked) error {
+	pids, err := utils.GetProcessInContainer(t.id)
+	if err != nil {
+		return oomError("%s: failed to get processes: %v", t.name, err)
+	}
+
+	seen := make(map[string]struct{}, len(pids))
+	for _, pid := range pids {
+		seen[pid] = struct{}{}
+		if _, ok := t.pids[pid]; ok {
+			continue
+		}
+		entry := filepath.Join(ctl.procRoot, pid, "oom_score_adj")
+		err := ioutil.WriteFile(entry, []byte(strconv.Itoa(t.score)), 0644)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return oomError("%s: failed to set oom_score_adj of process %s to %d: %v",
+				t.name, pid, t.score, err)
+		}
+		log.Debug("%s: process %s oom_score_adj set to %d", t.name, pid, t.score)
+	}
+
+	if len(t.pids) == 0 && len(pids) > 0 {
+		log.Info("%s: oom_score_adj set to %d", t.name, t.score)
+	}
+	t.pids = seen
+
+	return nil
+}
+
+// scoreAdj returns the OOM score adjustment for the container.
+func (ctl *oomctl) scoreAdj(c cache.Container) int {
+	if score, ok := annotatedScoreAdj(c); ok {
+		log.Debug("%s: annotated oom_score_adj %d", c.PrettyName(), score)
+		return score
+	}
+
+	class := c.GetQOSClass()
+	if score, ok := opt.Classes[string(class)]; ok {
+		return score
+	}
+	if score, ok := opt.Classes["*"]; ok {
+		return score
+	}
+
+	switch class {
+	case corev1.PodQOSGuaranteed:
+		return guaranteedScoreAdj
+	case corev1.PodQOSBestEffort:
+		return bestEffortScoreAdj
+	}
+
+	return ctl.burstableScoreAdj(c)
+}
+
+// burstableScoreAdj calculates the OOM score adjustment for a burstable container.
+//
+// The score is calculated like the kubelet does, the more memory the container
+// requests relative to the capacity of the node, the less likely it is killed.
+func (ctl *oomctl) burstableScoreAdj(c cache.Container) int {
+	if ctl.memory <= 0 {
+		return bestEffortScoreAdj - 1
+	}
+
+	request := int64(0)
+	if req, ok := c.GetResourceRequirements().Requests[corev1.ResourceMemory]; ok {
+		request = req.Value()
+	}
+
+	score := 1000 - int((1000*request)/ctl.memory)
+	switch {
+	case score < 2:
+		// keep burstable containers above guaranteed ones
+		score = 2
+	case score >= bestEffortScoreAdj:
+		// keep burstable containers below best-effort ones
+		score = bestEffortScoreAdj - 1
+	}
+
+	return score
+}
+
+// annotatedScoreAdj returns the OOM score adjustment annotated for the container, if any.
+func annotatedScoreAdj(c cache.Container) (int, bool) {
+	pod, ok := c.GetPod()
+	if !ok {
+		return 0, false
+	}
+	value, ok := pod.GetResmgrAnnotation(keyOOMScoreAdj)
+	if !ok {
+		return 0, false
+	}
+
+	if score, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return score, validScoreAdj(c, score)
+	}
+
+	preferences := map[string]int{}
+	if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+		log.Error("%s: failed to parse annotation %s = '%s': %v",
+			c.PrettyName(), keyOOMScoreAdj, value, err)
+		return 0, false
+	}
+
+	score, ok := preferences[c.GetName()]
+	if !ok {
+		return 0, false
+	}
+	return score, validScoreAdj(c, score)
+}
+
+// validScoreAdj checks if an annotated OOM score adjustment is within the valid range.
+func validScoreAdj(c cache.Container, score int) bool {
+	if score < minScoreAdj || score > maxScoreAdj {
+		log.Error("%s: ignoring invalid annotated oom_score_adj %d, expecting %d - %d",
+			c.PrettyName(), score, minScoreAdj, maxScoreAdj)
+		return false
+	}
+	return true
+}
+
+// startRecheck starts periodically adjusting new processes of containers.
+//
+// The processes of a container can change without any CRI requests, for instance
+// if the main process of a container is restarted by its init process. We check
+// for such new processes periodically and adjust their OOM scores, too.
+func (ctl *oomctl) startRecheck() {
+	ctl.stopRecheck()
+	interval := opt.RecheckInterval.Duration()
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	ctl.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case _ = <-stop:
+				return
+			case _ = <-ticker.C:
+				ctl.recheck()
+			}
+		}
+	}()
+}
+
+// stopRecheck stops periodically adjusting new processes of containers.
+func (ctl *oomctl) stopRecheck() {
+	if ctl.stop != nil {
+		close(ctl.stop)
+		ctl.stop = nil
+	}
+}
+
+// recheck adjusts the OOM score of any new processes of tracked containers.
+func (ctl *oomctl) recheck() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for _, t := range ctl.containers {
+		if err := ctl.apply(t); err != nil {
+			log.Debug("%v", err)
+		}
+	}
+}
+
+// memoryCapacity returns the total amount of memory on the node.
+func (ctl *oomctl) memoryCapacity() (int64, error) {
+	f, err := os.Open(filepath.Join(ctl.procRoot, "meminfo"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		kB, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kB * 1024, nil
+	}
+
+	return 0, fmt.Errorf("MemTotal not found")
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *oomctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	if ctl.cache == nil {
+		return nil
+	}
+	ctl.startRecheck()
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.adjust(c); err != nil {
+			log.Error("failed to update oom_score_adj of %s: %v", c.PrettyName(), err)
+		}
+	}
+
+	return nil
+}
+
+// oomError returns a controller-specific formatted error.
+func oomError(format string, args ...interface{}) error {
+	return fmt.Errorf("oom: "+format, args...)
+}
+
+// init registers this controller.
+func init() {
+	control.Register(OOMController, "OOM score adjustment controller", getOOMController())
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pod
+
+var configHelp = `
+Resource Manager pod cgroup controller.
+
+Besides the cgroups of containers, the pod cgroup controller programs the pod-
+level cgroup (the CgroupParent of the pod) and the cgroup of the pod sandbox
+with the resources assigned to the containers of the pod. With Cpuset enabled,
+cpuset.cpus and cpuset.mems of the pod are set to the union of those of its
+containers, so the sandbox and its pause container stay within the topology
+domain assigned to the pod. The memory limit of the pod is not touched, since
+kubelet already sets it to the sum of the limits of its containers.
+
+Pods with any container not pinned to CPUs or memory are left unrestricted.
+The pod cgroup is widened before new or updated container assignments take
+effect, and narrowed down again once containers have been started or stopped.
+With cgroup v1, a pod cgroup still used wider by any of its children is left
+as it is until a later update. Once Cpuset is disabled, the original cpusets
+of the pods are restored.
+
+Here is a sample configuration fragment enabling it:
+
+  pod:
+    Cpuset: true
+`
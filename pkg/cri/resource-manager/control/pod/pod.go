@@ -0,0 +1,381 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pod
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// PodController is the name of the pod cgroup controller.
+	PodController = "pod"
+	// cgroupRoot is the mount point of the cgroup hierarchies.
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+// podctl encapsulates the runtime state of our pod cgroup controller.
+type podctl struct {
+	sync.Mutex
+	cache cache.Cache                  // resource manager cache
+	root  string                       // cgroup mount point
+	v2    bool                         // whether the unified cgroup v2 hierarchy is in use
+	saved map[string]map[string]string // original cgroup entries we changed, by pod ID and path
+}
+
+// podCgroup identifies the cgroup directories of a pod.
+type podCgroup struct {
+	id      string // pod ID
+	name    string // pod name, for logging
+	dir     string // pod-level cgroup directory
+	sandbox string // sandbox cgroup directory, if found
+}
+
+// Our singleton pod cgroup controller instance.
+var singleton *podctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(PodController)
+
+// writeEntry writes a cgroup entry, overridable for testing.
+var writeEntry = func(path, value string) error {
+	return ioutil.WriteFile(path, []byte(value), 0644)
+}
+
+// getPodController returns our singleton pod cgroup controller instance.
+func getPodController() control.Controller {
+	if singleton == nil {
+		singleton = &podctl{
+			root:  cgroupRoot,
+			saved: make(map[string]map[string]string),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *podctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(PodController); err != nil {
+		return err
+	}
+
+	_, err := os.Stat(filepath.Join(ctl.root, "cgroup.controllers"))
+	ctl.v2 = err == nil
+	ctl.cache = cache
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *podctl) Stop() {
+}
+
+// PreCreateHook is the pod cgroup controller pre-create hook.
+func (ctl *podctl) PreCreateHook(c cache.Container) error {
+	return ctl.updateContainerPod(c, true)
+}
+
+// PreStartHook is the pod cgroup controller pre-start hook.
+func (ctl *podctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the pod cgroup controller post-start hook.
+func (ctl *podctl) PostStartHook(c cache.Container) error {
+	return ctl.updateContainerPod(c, false)
+}
+
+// PostUpdateHook is the pod cgroup controller post-update hook.
+func (ctl *podctl) PostUpdateHook(c cache.Container) error {
+	// the container itself is only updated after the hook, so we can only widen
+	return ctl.updateContainerPod(c, true)
+}
+
+// PostStopHook is the pod cgroup controller post-stop hook.
+func (ctl *podctl) PostStopHook(c cache.Container) error {
+	return ctl.updateContainerPod(c, false)
+}
+
+// updateContainerPod updates the cgroup of the pod of the given container.
+func (ctl *podctl) updateContainerPod(c cache.Container, widen bool) error {
+	if !opt.Cpuset {
+		return nil
+	}
+	pod, ok := c.GetPod()
+	if !ok {
+		return podError("failed to get Pod for %s", c.PrettyName())
+	}
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	ctl.pruneSaved()
+	return ctl.updatePod(pod, widen)
+}
+
+// updatePod updates the pod cgroup to the aggregate of its active containers.
+//
+// If widen is true the cgroup is only extended to cover the containers, never
+// restricted. This is necessary when the containers themselves are not yet
+// updated, since with cgroup v1 the cpuset of a child must be a subset of its
+// parent.
+func (ctl *podctl) updatePod(pod cache.Pod, widen bool) error {
+	containers := []cache.Container{}
+	for _, c := range pod.GetContainers() {
+		switch c.GetState() {
+		case cache.ContainerStateExited, cache.ContainerStateStale:
+			continue
+		}
+		containers = append(containers, c)
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+
+	pc := ctl.podCgroup(pod)
+
+	cpus, ok := aggregateCpuset(containers, cache.Container.GetCpusetCpus)
+	if ok {
+		if err := ctl.setCpuset(pc, "cpuset.cpus", cpus, widen); err != nil {
+			return err
+		}
+	}
+	mems, ok := aggregateCpuset(containers, cache.Container.GetCpusetMems)
+	if ok {
+		if err := ctl.setCpuset(pc, "cpuset.mems", mems, widen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// podCgroup looks up the cpuset cgroup directories of the given pod.
+func (ctl *podctl) podCgroup(pod cache.Pod) *podCgroup {
+	subsystemDir := ctl.subsystemDir("cpuset")
+	pc := &podCgroup{
+		id:   pod.GetID(),
+		name: pod.GetName(),
+		dir:  filepath.Join(subsystemDir, cgroups.ExpandCgroupParent(pod.GetCgroupParentDir())),
+	}
+
+	sandboxDir, err := utils.FindContainerDir(subsystemDir, pod.GetID())
+	if err != nil {
+		log.Debug("%s: failed to find sandbox cgroup: %v", pod.GetName(), err)
+	} else {
+		pc.sandbox = sandboxDir
+	}
+
+	return pc
+}
+
+// setCpuset sets a cpuset entry of the pod and its sandbox cgroup.
+//
+// To stay valid with cgroup v1 at every step, the pod cgroup is first widened
+// to cover both the current and the new set, then the sandbox cgroup is set,
+// and finally the pod cgroup is restricted to the new set. With cgroup v1 the
+// last step fails with EBUSY as long as any other child cgroup, for instance
+// that of a container not updated yet, still uses the excess CPUs or memory
+// nodes. The pod cgroup is then left wider, to be narrowed by a later update.
+func (ctl *podctl) setCpuset(pc *podCgroup, file string, set cpuset.CPUSet, widen bool) error {
+	podFile := filepath.Join(pc.dir, file)
+
+	current, err := readCpuset(podFile)
+	if err != nil {
+		return podError("%s: failed to read %s: %v", pc.name, podFile, err)
+	}
+	wider := current.Union(set)
+	if widen {
+		set = wider
+	}
+
+	if !wider.Equals(current) {
+		if err := ctl.writeCgroup(pc.id, podFile, wider.String()); err != nil {
+			return podError("%s: failed to set %s to %q: %v", pc.name, file, wider, err)
+		}
+	}
+
+	if pc.sandbox != "" {
+		sandboxFile := filepath.Join(pc.sandbox, file)
+		if err := ctl.writeCgroup(pc.id, sandboxFile, set.String()); err != nil {
+			return podError("%s: failed to set sandbox %s to %q: %v", pc.name, file, set, err)
+		}
+	}
+
+	if !set.Equals(wider) {
+		err := ctl.writeCgroup(pc.id, podFile, set.String())
+		switch {
+		case errors.Is(err, syscall.EBUSY):
+			log.Debug("%s: %s still in use by child cgroups, leaving it at %q",
+				pc.name, file, wider)
+			set = wider
+		case err != nil:
+			return podError("%s: failed to set %s to %q: %v", pc.name, file, set, err)
+		}
+	}
+
+	if !set.Equals(current) {
+		log.Info("%s: %s set to %q", pc.name, file, set)
+	}
+
+	return nil
+}
+
+// writeCgroup writes a cgroup entry of a pod, saving its original value first.
+func (ctl *podctl) writeCgroup(id, path, value string) error {
+	saved, ok := ctl.saved[id]
+	if !ok {
+		saved = make(map[string]string)
+		ctl.saved[id] = saved
+	}
+	if _, ok := saved[path]; !ok {
+		original, err := readEntry(path)
+		if err != nil {
+			return err
+		}
+		saved[path] = original
+	}
+	return writeEntry(path, value)
+}
+
+// restore restores the original cgroup entries of all pods we changed.
+func (ctl *podctl) restore() {
+	for id, saved := range ctl.saved {
+		// restore pod cgroups before the nested sandbox ones
+		paths := make([]string, 0, len(saved))
+		for path := range saved {
+			paths = append(paths, path)
+		}
+		sort.Slice(paths, func(i, j int) bool { return len(paths[i]) < len(paths[j]) })
+
+		for _, path := range paths {
+			err := writeEntry(path, saved[path])
+			if err != nil && !os.IsNotExist(err) {
+				log.Error("failed to restore %s to %q: %v", path, saved[path], err)
+				continue
+			}
+			delete(saved, path)
+		}
+		if len(saved) == 0 {
+			delete(ctl.saved, id)
+		}
+	}
+}
+
+// pruneSaved forgets the saved cgroup entries of pods no longer around.
+func (ctl *podctl) pruneSaved() {
+	if ctl.cache == nil || len(ctl.saved) == 0 {
+		return
+	}
+	for id := range ctl.saved {
+		if _, ok := ctl.cache.LookupPod(id); !ok {
+			delete(ctl.saved, id)
+		}
+	}
+}
+
+// subsystemDir returns the directory of the given cgroup subsystem.
+func (ctl *podctl) subsystemDir(subsystem string) string {
+	if ctl.v2 {
+		return ctl.root
+	}
+	return filepath.Join(ctl.root, subsystem)
+}
+
+// aggregateCpuset returns the union of the given cpuset entry of all containers.
+//
+// If any container is not restricted by the entry, there is no aggregate set.
+func aggregateCpuset(containers []cache.Container, get func(cache.Container) string) (cpuset.CPUSet, bool) {
+	set := cpuset.NewCPUSet()
+	for _, c := range containers {
+		cset, err := cpuset.Parse(get(c))
+		if err != nil || cset.IsEmpty() {
+			return cpuset.NewCPUSet(), false
+		}
+		set = set.Union(cset)
+	}
+	return set, true
+}
+
+// readCpuset reads a cpuset cgroup entry.
+func readCpuset(path string) (cpuset.CPUSet, error) {
+	value, err := readEntry(path)
+	if err != nil {
+		return cpuset.NewCPUSet(), err
+	}
+	return cpuset.Parse(value)
+}
+
+// readEntry reads a cgroup entry.
+func readEntry(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *podctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	if !opt.Cpuset {
+		ctl.restore()
+		return nil
+	}
+	if ctl.cache == nil {
+		return nil
+	}
+
+	ctl.pruneSaved()
+	for _, pod := range ctl.cache.GetPods() {
+		if pod.IsIgnored() {
+			continue
+		}
+		if err := ctl.updatePod(pod, false); err != nil {
+			log.Error("failed to update cgroup of pod %s: %v", pod.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// podError creates a pod-controller-specific formatted error message.
+func podError(format string, args ...interface{}) error {
+	return fmt.Errorf("pod: "+format, args...)
+}
+
+// Register us as a controller.
+func init() {
+	control.Register(PodController, "Pod cgroup controller", getPodController())
+}
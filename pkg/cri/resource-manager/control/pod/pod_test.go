@@ -0,0 +1,217 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakeContainer is a container with only its cpuset entries set.
+type fakeContainer struct {
+	cache.Container
+	cpus string
+	mems string
+}
+
+func (c *fakeContainer) GetCpusetCpus() string { return c.cpus }
+func (c *fakeContainer) GetCpusetMems() string { return c.mems }
+
+// setupCgroups creates a fake cgroup v1 cpuset hierarchy with a pod and its sandbox.
+func setupCgroups(t *testing.T) (string, *podCgroup) {
+	root, err := ioutil.TempDir("", "pod-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+
+	pc := &podCgroup{
+		id:      "pod-id",
+		name:    "pod",
+		dir:     filepath.Join(root, "cpuset", "kubepods", "podUID"),
+		sandbox: filepath.Join(root, "cpuset", "kubepods", "podUID", "pod-id"),
+	}
+	for _, dir := range []string{pc.dir, pc.sandbox} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		for file, content := range map[string]string{"cpuset.cpus": "0-7", "cpuset.mems": "0-1"} {
+			path := filepath.Join(dir, file)
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to create %s: %v", path, err)
+			}
+		}
+	}
+
+	return root, pc
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func newTestPodctl(root string) *podctl {
+	return &podctl{
+		root:  root,
+		saved: make(map[string]map[string]string),
+	}
+}
+
+func TestAggregateCpuset(t *testing.T) {
+	tcases := []struct {
+		name       string
+		containers []cache.Container
+		expected   string
+		ok         bool
+	}{
+		{
+			name: "union of pinned containers",
+			containers: []cache.Container{
+				&fakeContainer{cpus: "0-1"},
+				&fakeContainer{cpus: "4,6"},
+			},
+			expected: "0-1,4,6",
+			ok:       true,
+		},
+		{
+			name: "unpinned container",
+			containers: []cache.Container{
+				&fakeContainer{cpus: "0-1"},
+				&fakeContainer{cpus: ""},
+			},
+			ok: false,
+		},
+		{
+			name: "invalid cpuset",
+			containers: []cache.Container{
+				&fakeContainer{cpus: "x"},
+			},
+			ok: false,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			set, ok := aggregateCpuset(tc.containers, cache.Container.GetCpusetCpus)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v, got %v", tc.ok, ok)
+			}
+			if ok && set.String() != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, set.String())
+			}
+		})
+	}
+}
+
+func TestSetCpuset(t *testing.T) {
+	root, pc := setupCgroups(t)
+	defer os.RemoveAll(root)
+	ctl := newTestPodctl(root)
+
+	podFile := filepath.Join(pc.dir, "cpuset.cpus")
+	sandboxFile := filepath.Join(pc.sandbox, "cpuset.cpus")
+
+	// widening never restricts the pod
+	if err := ctl.setCpuset(pc, "cpuset.cpus", cpuset.MustParse("2-3"), true); err != nil {
+		t.Fatalf("failed to widen cpuset: %v", err)
+	}
+	if cpus := readFile(t, podFile); cpus != "0-7" {
+		t.Errorf("expected pod cpuset to stay %q, got %q", "0-7", cpus)
+	}
+
+	// narrowing restricts both the pod and the sandbox
+	if err := ctl.setCpuset(pc, "cpuset.cpus", cpuset.MustParse("2-3"), false); err != nil {
+		t.Fatalf("failed to narrow cpuset: %v", err)
+	}
+	for _, path := range []string{podFile, sandboxFile} {
+		if cpus := readFile(t, path); cpus != "2-3" {
+			t.Errorf("expected %s to be %q, got %q", path, "2-3", cpus)
+		}
+	}
+
+	// widening outside the current set extends the pod
+	if err := ctl.setCpuset(pc, "cpuset.cpus", cpuset.MustParse("8"), true); err != nil {
+		t.Fatalf("failed to widen cpuset: %v", err)
+	}
+	if cpus := readFile(t, podFile); cpus != "2-3,8" {
+		t.Errorf("expected pod cpuset %q, got %q", "2-3,8", cpus)
+	}
+
+	// restoring brings back the original values
+	ctl.restore()
+	for _, path := range []string{podFile, sandboxFile} {
+		if cpus := readFile(t, path); cpus != "0-7" {
+			t.Errorf("expected %s to be restored to %q, got %q", path, "0-7", cpus)
+		}
+	}
+	if len(ctl.saved) != 0 {
+		t.Errorf("expected no saved entries after restore, got %v", ctl.saved)
+	}
+}
+
+func TestSetCpusetBusy(t *testing.T) {
+	root, pc := setupCgroups(t)
+	defer os.RemoveAll(root)
+	ctl := newTestPodctl(root)
+
+	podFile := filepath.Join(pc.dir, "cpuset.mems")
+
+	// emulate cgroup v1 refusing to narrow a parent still used by a child
+	write := writeEntry
+	defer func() { writeEntry = write }()
+	writeEntry = func(path, value string) error {
+		if path == podFile && value == "1" {
+			return &os.PathError{Op: "write", Path: path, Err: syscall.EBUSY}
+		}
+		return write(path, value)
+	}
+
+	if err := ctl.setCpuset(pc, "cpuset.mems", cpuset.MustParse("1"), false); err != nil {
+		t.Fatalf("expected EBUSY to be tolerated, got %v", err)
+	}
+	if mems := readFile(t, podFile); mems != "0-1" {
+		t.Errorf("expected pod cpuset.mems to stay %q, got %q", "0-1", mems)
+	}
+	if mems := readFile(t, filepath.Join(pc.sandbox, "cpuset.mems")); mems != "1" {
+		t.Errorf("expected sandbox cpuset.mems %q, got %q", "1", mems)
+	}
+}
+
+func TestRestoreRemovedPod(t *testing.T) {
+	root, pc := setupCgroups(t)
+	defer os.RemoveAll(root)
+	ctl := newTestPodctl(root)
+
+	if err := ctl.setCpuset(pc, "cpuset.cpus", cpuset.MustParse("1"), false); err != nil {
+		t.Fatalf("failed to narrow cpuset: %v", err)
+	}
+	if err := os.RemoveAll(pc.dir); err != nil {
+		t.Fatalf("failed to remove %s: %v", pc.dir, err)
+	}
+
+	ctl.restore()
+	if len(ctl.saved) != 0 {
+		t.Errorf("expected entries of removed pod to be dropped, got %v", ctl.saved)
+	}
+}
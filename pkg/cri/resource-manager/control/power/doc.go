@@ -0,0 +1,46 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+var configHelp = `
+Resource Manager RAPL power capping controller.
+
+The power controller programs the long-term RAPL power limit of each physical
+package (CPU socket). Limits are given in watts, either per package ID, with
+'*' matching all packages, or as a single NodeLimit split among packages.
+
+Without ClassWeights, NodeLimit is split evenly among packages. With them, each
+package gets a share proportional to one plus the sum of the weights of the
+classes of containers running on its CPUs, so that packages running heavier
+classes of workloads get more of the power budget. The class of a container
+is its unified resource class, or its QoS class. A per-package limit caps the
+share of the package. Original limits are restored when the controller is
+stopped, including when cri-resmgr exits, or for packages left without a
+configured limit. Original limits are saved in the file given by the
+--power-state-file command line option, so that a restarted cri-resmgr
+restores them instead of the limits it has lowered itself.
+
+Here is a sample configuration fragment splitting 400 W among the packages,
+while capping any package to 250 W:
+
+  power:
+    NodeLimit: 400
+    PackageLimits:
+      "*": 250
+    ClassWeights:
+      Guaranteed: 4
+      Burstable: 2
+      BestEffort: 0
+`
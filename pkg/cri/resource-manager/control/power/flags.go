@@ -0,0 +1,55 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+import (
+	"flag"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// stateFile is the file original power limits are saved in across restarts.
+var stateFile = "/var/lib/cri-resmgr/power-limits.json"
+
+// options captures our configurable parameters.
+type options struct {
+	// NodeLimit is the power limit of all packages together, in watts.
+	NodeLimit uint64 `json:",omitempty"`
+	// PackageLimits are the power limits of packages in watts, by package ID or * for all.
+	PackageLimits map[string]uint64 `json:",omitempty"`
+	// ClassWeights are the weights of classes in splitting NodeLimit among packages.
+	ClassWeights map[string]uint64 `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		PackageLimits: make(map[string]uint64),
+		ClassWeights:  make(map[string]uint64),
+	}
+}
+
+// Register command line options and for configuration handling.
+func init() {
+	flag.StringVar(&stateFile, "power-state-file", stateFile,
+		"File to save the original RAPL power limits in, for restoring them across restarts")
+
+	config.Register("resource-manager.power", configHelp, opt, defaultOptions,
+		config.WithNotify(getPowerController().(*powerctl).configNotify),
+		config.WithFragment())
+}
@@ -0,0 +1,351 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+const (
+	// PowerController is the name of the power capping controller.
+	PowerController = "power"
+	// microwatts in a watt, the unit of configured power limits
+	watt = 1000 * 1000
+)
+
+// powerctl encapsulates the runtime state of our power capping controller.
+type powerctl struct {
+	sync.Mutex
+	cache    cache.Cache                 // resource manager cache
+	system   system.System               // system topology
+	zones    []*system.PowerZone         // RAPL package power zones
+	original map[system.ID]uint64        // original limits of zones we have altered, saved in stateFile
+	applied  map[system.ID]uint64        // limits we have last applied
+	cpus     map[system.ID]cpuset.CPUSet // CPUs of packages
+}
+
+// Our singleton power capping controller instance.
+var singleton *powerctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(PowerController)
+
+// getPowerController returns our singleton power capping controller instance.
+func getPowerController() control.Controller {
+	if singleton == nil {
+		singleton = &powerctl{
+			original: make(map[system.ID]uint64),
+			applied:  make(map[system.ID]uint64),
+			cpus:     make(map[system.ID]cpuset.CPUSet),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *powerctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(PowerController); err != nil {
+		return err
+	}
+
+	sys, err := system.DiscoverSystem()
+	if err != nil {
+		return powerError("failed to discover system topology: %v", err)
+	}
+	zones, err := system.DiscoverPowerZones()
+	if err != nil {
+		return powerError("failed to discover RAPL power zones: %v", err)
+	}
+	if len(zones) == 0 {
+		return powerError("no RAPL package power zones found")
+	}
+
+	ctl.cache = cache
+	ctl.system = sys
+	ctl.zones = zones
+	for _, id := range sys.PackageIDs() {
+		ctl.cpus[id] = sys.Package(id).CPUSet()
+	}
+	for _, z := range zones {
+		log.Info("discovered power zone %s", z)
+	}
+	if err := ctl.loadOriginal(); err != nil {
+		log.Error("%v", err)
+	}
+	ctl.update()
+
+	return nil
+}
+
+// Stop shuts down the controller, restoring the original limits of all packages.
+func (ctl *powerctl) Stop() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for _, z := range ctl.zones {
+		ctl.restore(z)
+	}
+}
+
+// PreCreateHook is the power capping controller pre-create hook.
+func (ctl *powerctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the power capping controller pre-start hook.
+func (ctl *powerctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the power capping controller post-start hook.
+func (ctl *powerctl) PostStartHook(c cache.Container) error {
+	if len(opt.ClassWeights) > 0 {
+		ctl.update()
+	}
+	return nil
+}
+
+// PostUpdateHook is the power capping controller post-update hook.
+func (ctl *powerctl) PostUpdateHook(c cache.Container) error {
+	if len(opt.ClassWeights) > 0 {
+		ctl.update()
+	}
+	return nil
+}
+
+// PostStopHook is the power capping controller post-stop hook.
+func (ctl *powerctl) PostStopHook(c cache.Container) error {
+	if len(opt.ClassWeights) > 0 {
+		ctl.update()
+	}
+	return nil
+}
+
+// update brings the power limits of all packages in sync with the configuration.
+//
+// With a node-wide limit, the limit is split among packages in proportion to
+// their weight, one plus the sum of the class weights of the containers running
+// on the CPUs of the package. A configured package limit caps the limit of the
+// package. Packages without any limit are restored to their original limit.
+func (ctl *powerctl) update() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	if ctl.cache == nil {
+		return
+	}
+
+	limits := ctl.wantedLimits()
+	for _, z := range ctl.zones {
+		limit, ok := limits[z.Package]
+		if !ok {
+			ctl.restore(z)
+			continue
+		}
+		if max := z.MaxPower(); max > 0 && limit > max {
+			limit = max
+		}
+		if applied, ok := ctl.applied[z.Package]; ok && applied == limit {
+			continue
+		}
+		old, err := z.SetPowerLimit(limit)
+		if err != nil {
+			log.Error("failed to set power limit of %s: %v", z, err)
+			continue
+		}
+		if _, ok := ctl.original[z.Package]; !ok {
+			ctl.original[z.Package] = old
+			if err := ctl.saveOriginal(); err != nil {
+				log.Error("%v", err)
+			}
+		}
+		ctl.applied[z.Package] = limit
+		log.Info("power limit of %s set to %.1f W", z, float64(limit)/watt)
+	}
+}
+
+// wantedLimits calculates the wanted power limits of packages, in microwatts.
+func (ctl *powerctl) wantedLimits() map[system.ID]uint64 {
+	limits := map[system.ID]uint64{}
+
+	if opt.NodeLimit > 0 {
+		weights := ctl.packageWeights()
+		total := uint64(0)
+		for _, w := range weights {
+			total += w
+		}
+		for id, w := range weights {
+			limits[id] = opt.NodeLimit * watt * w / total
+		}
+	}
+
+	for _, z := range ctl.zones {
+		limit, ok := opt.PackageLimits[strconv.Itoa(int(z.Package))]
+		if !ok {
+			limit, ok = opt.PackageLimits["*"]
+		}
+		if !ok || limit == 0 {
+			continue
+		}
+		if current, ok := limits[z.Package]; !ok || current > limit*watt {
+			limits[z.Package] = limit * watt
+		}
+	}
+
+	return limits
+}
+
+// packageWeights calculates the weights of packages for splitting a node-wide limit.
+func (ctl *powerctl) packageWeights() map[system.ID]uint64 {
+	weights := map[system.ID]uint64{}
+	for _, z := range ctl.zones {
+		weights[z.Package] = 1
+	}
+	if len(opt.ClassWeights) == 0 {
+		return weights
+	}
+
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		weight, ok := opt.ClassWeights[classOf(c)]
+		if !ok || weight == 0 {
+			continue
+		}
+		cpus, err := cpuset.Parse(c.GetCpusetCpus())
+		if err != nil || cpus.IsEmpty() {
+			continue
+		}
+		for id, pkgCPUs := range ctl.cpus {
+			if !cpus.Intersection(pkgCPUs).IsEmpty() {
+				weights[id] += weight
+			}
+		}
+	}
+
+	return weights
+}
+
+// restore restores the original power limit of a package, if we have altered it.
+func (ctl *powerctl) restore(z *system.PowerZone) {
+	old, ok := ctl.original[z.Package]
+	if !ok {
+		return
+	}
+	if _, err := z.SetPowerLimit(old); err != nil {
+		log.Error("failed to restore power limit of %s: %v", z, err)
+		return
+	}
+	delete(ctl.original, z.Package)
+	delete(ctl.applied, z.Package)
+	log.Info("power limit of %s restored to %.1f W", z, float64(old)/watt)
+	if err := ctl.saveOriginal(); err != nil {
+		log.Error("%v", err)
+	}
+}
+
+// loadOriginal loads the original limits saved by a previous instance.
+//
+// A restarted instance finds the limits it has lowered itself in sysfs. The
+// original limits saved before lowering them take precedence over those.
+func (ctl *powerctl) loadOriginal() error {
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return powerError("failed to read original power limits: %v", err)
+	}
+
+	original := map[system.ID]uint64{}
+	if err := json.Unmarshal(data, &original); err != nil {
+		return powerError("failed to parse original power limits from %s: %v", stateFile, err)
+	}
+	for _, z := range ctl.zones {
+		if limit, ok := original[z.Package]; ok {
+			ctl.original[z.Package] = limit
+			log.Info("original power limit of %s is %.1f W", z, float64(limit)/watt)
+		}
+	}
+
+	return nil
+}
+
+// saveOriginal saves the original limits of zones we have altered, or removes
+// the saved limits once we have restored all of them.
+func (ctl *powerctl) saveOriginal() error {
+	if len(ctl.original) == 0 {
+		if err := os.Remove(stateFile); err != nil && !os.IsNotExist(err) {
+			return powerError("failed to remove original power limits: %v", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(ctl.original)
+	if err != nil {
+		return powerError("failed to marshal original power limits: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0700); err != nil {
+		return powerError("failed to create directory for %s: %v", stateFile, err)
+	}
+	if err := ioutil.WriteFile(stateFile, data, 0600); err != nil {
+		return powerError("failed to save original power limits: %v", err)
+	}
+
+	return nil
+}
+
+// classOf returns the class of a container for power weights.
+//
+// The class is the unified resource class of the container, or its QoS class.
+func classOf(c cache.Container) string {
+	if class, ok := control.GetClassManager().ClassOf(c); ok {
+		return class
+	}
+	return string(c.GetQOSClass())
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *powerctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+	ctl.update()
+	return nil
+}
+
+// powerError creates a power-controller-specific formatted error message.
+func powerError(format string, args ...interface{}) error {
+	return fmt.Errorf("power: "+format, args...)
+}
+
+// Register us as a controller.
+func init() {
+	control.Register(PowerController, "RAPL power capping controller", getPowerController())
+	control.GetClassManager().AddNotify(getPowerController().(*powerctl).configNotify)
+}
@@ -0,0 +1,220 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// fakeContainer is a running container of a QoS class, with a cpuset.
+type fakeContainer struct {
+	cache.Container
+	cpus string
+	qos  v1.PodQOSClass
+}
+
+func (c *fakeContainer) IsIgnored() bool                { return false }
+func (c *fakeContainer) GetState() cache.ContainerState { return cache.ContainerStateRunning }
+func (c *fakeContainer) GetCpusetCpus() string          { return c.cpus }
+func (c *fakeContainer) GetQOSClass() v1.PodQOSClass    { return c.qos }
+func (c *fakeContainer) GetPod() (cache.Pod, bool)      { return nil, false }
+
+// setOptions sets the controller configuration, returning a function to restore it.
+func setOptions(o *options) func() {
+	saved := *opt
+	*opt = *o
+	return func() { *opt = saved }
+}
+
+// newTestPowerctl creates a controller with two packages of two CPUs, and a fake
+// powercap tree with the given limits for them, in watts.
+func newTestPowerctl(t *testing.T, limits ...uint64) (*powerctl, func()) {
+	root, err := ioutil.TempDir("", "power-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+
+	savedStateFile := stateFile
+	stateFile = filepath.Join(root, "state", "power-limits.json")
+	cleanup := func() {
+		stateFile = savedStateFile
+		os.RemoveAll(root)
+	}
+
+	for pkg, limit := range limits {
+		dir := filepath.Join(root, "class", "powercap", "intel-rapl:"+strconv.Itoa(pkg))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		for entry, value := range map[string]uint64{
+			"max_energy_range_uj":         1000000,
+			"constraint_0_power_limit_uw": limit * watt,
+			"constraint_0_max_power_uw":   300 * watt,
+		} {
+			data := []byte(strconv.FormatUint(value, 10) + "\n")
+			if err := ioutil.WriteFile(filepath.Join(dir, entry), data, 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", entry, err)
+			}
+		}
+		name := []byte("package-" + strconv.Itoa(pkg) + "\n")
+		if err := ioutil.WriteFile(filepath.Join(dir, "name"), name, 0644); err != nil {
+			t.Fatalf("failed to write zone name: %v", err)
+		}
+	}
+
+	zones, err := system.DiscoverPowerZonesAt(root)
+	if err != nil {
+		cleanup()
+		t.Fatalf("failed to discover power zones: %v", err)
+	}
+
+	ctl := &powerctl{
+		cache:    &cachetest.FakeCache{},
+		zones:    zones,
+		original: make(map[system.ID]uint64),
+		applied:  make(map[system.ID]uint64),
+		cpus: map[system.ID]cpuset.CPUSet{
+			0: cpuset.NewCPUSet(0, 1),
+			1: cpuset.NewCPUSet(2, 3),
+		},
+	}
+	return ctl, cleanup
+}
+
+// limitOf returns the current limit of a package in watts.
+func limitOf(t *testing.T, ctl *powerctl, pkg int) uint64 {
+	limit, err := ctl.zones[pkg].PowerLimit()
+	if err != nil {
+		t.Fatalf("failed to read power limit of package #%d: %v", pkg, err)
+	}
+	return limit / watt
+}
+
+func TestWantedLimits(t *testing.T) {
+	ctl, cleanup := newTestPowerctl(t, 200, 200)
+	defer cleanup()
+
+	ctl.cache = &cachetest.FakeCache{
+		Containers: []cache.Container{
+			&fakeContainer{cpus: "0-1", qos: v1.PodQOSGuaranteed},
+			&fakeContainer{cpus: "1-2", qos: v1.PodQOSBurstable},
+			&fakeContainer{cpus: "3", qos: v1.PodQOSBestEffort},
+		},
+	}
+
+	tcs := []struct {
+		name     string
+		opts     *options
+		expected map[system.ID]uint64
+	}{
+		{
+			name:     "no limits",
+			opts:     &options{},
+			expected: map[system.ID]uint64{},
+		},
+		{
+			name:     "even split",
+			opts:     &options{NodeLimit: 300},
+			expected: map[system.ID]uint64{0: 150, 1: 150},
+		},
+		{
+			name: "weighted split",
+			opts: &options{
+				NodeLimit:    300,
+				ClassWeights: map[string]uint64{"Guaranteed": 4, "Burstable": 2, "BestEffort": 0},
+			},
+			expected: map[system.ID]uint64{0: 210, 1: 90},
+		},
+		{
+			name: "weighted split, capped",
+			opts: &options{
+				NodeLimit:     300,
+				PackageLimits: map[string]uint64{"*": 180},
+				ClassWeights:  map[string]uint64{"Guaranteed": 4, "Burstable": 2},
+			},
+			expected: map[system.ID]uint64{0: 180, 1: 90},
+		},
+		{
+			name:     "package limits",
+			opts:     &options{PackageLimits: map[string]uint64{"*": 180, "1": 120}},
+			expected: map[system.ID]uint64{0: 180, 1: 120},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setOptions(tc.opts)()
+			limits := ctl.wantedLimits()
+			if len(limits) != len(tc.expected) {
+				t.Errorf("expected limits %v W, got %v uW", tc.expected, limits)
+			}
+			for id, expected := range tc.expected {
+				if limits[id] != expected*watt {
+					t.Errorf("package #%d: expected limit %d W, got %d uW", id, expected, limits[id])
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreOriginal(t *testing.T) {
+	ctl, cleanup := newTestPowerctl(t, 200, 250)
+	defer cleanup()
+
+	defer setOptions(&options{PackageLimits: map[string]uint64{"*": 150}})()
+	ctl.update()
+	if limitOf(t, ctl, 0) != 150 || limitOf(t, ctl, 1) != 150 {
+		t.Fatalf("expected limits to be lowered to 150 W")
+	}
+	if _, err := os.Stat(stateFile); err != nil {
+		t.Fatalf("expected original limits to be saved: %v", err)
+	}
+
+	// a restarted controller restores the saved limits, not the lowered ones
+	restarted := &powerctl{
+		cache:    ctl.cache,
+		zones:    ctl.zones,
+		original: make(map[system.ID]uint64),
+		applied:  make(map[system.ID]uint64),
+		cpus:     ctl.cpus,
+	}
+	if err := restarted.loadOriginal(); err != nil {
+		t.Fatalf("failed to load original limits: %v", err)
+	}
+	opt.PackageLimits = map[string]uint64{"0": 100}
+	restarted.update()
+	if limitOf(t, restarted, 0) != 100 || limitOf(t, restarted, 1) != 250 {
+		t.Errorf("expected limits 100 W and 250 W, got %d W and %d W",
+			limitOf(t, restarted, 0), limitOf(t, restarted, 1))
+	}
+
+	restarted.Stop()
+	if limitOf(t, restarted, 0) != 200 {
+		t.Errorf("expected limit 200 W to be restored, got %d W", limitOf(t, restarted, 0))
+	}
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("expected saved limits to be removed once restored, got %v", err)
+	}
+}
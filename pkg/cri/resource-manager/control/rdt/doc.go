@@ -36,4 +36,24 @@ mappings for the 3 Kubernetes QoS classes and defines also a default class.
       Burstable: ModerateRDT
       BestEffort: RestrictedRDT
       "*": RestrictedRDT
+
+The memory bandwidth of containers can be further limited within their RDT
+class with the cri-resource-manager.intel.com/memory-bandwidth-limit pod
+annotation, either with a single limit for all containers of the pod or with
+a map of container names to limits. Limits are given either as a percentage
+(for instance 20%) or in MBps (for instance 2000MBps). Containers with the
+same class and limit share a dedicated resctrl group, derived from the class.
+The number of these groups is capped by options.mb.maxLimitGroups of the RDT
+configuration, 4 by default. Limits beyond the cap are not enforced.
+
+If the system supports RDT monitoring, the processes of each pod can be put
+in a monitoring group of the pod, within every class the pod has containers
+in. The monitoring data of these groups is exported per pod. Monitoring groups
+use up RMIDs, which are a scarce resource, so per-pod monitoring is off by
+default. If the system runs out of RMIDs, the groups of pods in a lower QoS
+class are evicted to make room for those in a higher one, and restored once
+RMIDs are released again. Per-pod monitoring is turned on with
+
+  rdt:
+    PodMonitoring: true
 `
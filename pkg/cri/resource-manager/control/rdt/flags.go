@@ -27,6 +27,8 @@ type options struct {
 	ResctrlPath string
 	// Class is a assigned to actual RDT class map.
 	Classes map[string]string
+	// PodMonitoring enables per-pod RDT monitoring groups.
+	PodMonitoring bool
 }
 
 // Our runtime configuration.
@@ -56,5 +58,6 @@ func init() {
 		"Path of the resctrl filesystem mountpoint")
 
 	config.Register("resource-manager.rdt", configHelp, opt, defaultOptions,
-		config.WithNotify(getRDTController().(*rdtctl).configNotify))
+		config.WithNotify(getRDTController().(*rdtctl).configNotify),
+		config.WithFragment())
 }
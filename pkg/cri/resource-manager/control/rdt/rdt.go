@@ -15,11 +15,17 @@
 package rdt
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/intel/cri-resource-manager/pkg/cri/client"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
 	"github.com/intel/cri-resource-manager/pkg/rdt"
 	"github.com/intel/cri-resource-manager/pkg/utils"
 
@@ -30,17 +36,23 @@ import (
 const (
 	// RDTController is the name of the RDT controller.
 	RDTController = cache.RDT
+	// keyMBLimit is the annotation key for requesting a memory bandwidth limit.
+	keyMBLimit = "memory-bandwidth-limit"
 )
 
 // rdtctl encapsulates the runtime state of our RTD enforcement/controller.
 type rdtctl struct {
-	rdt   *rdt.Control // resctrl RDT control
-	cache cache.Cache  // resource manager cache
+	rdt           *rdt.Control // resctrl RDT control
+	cache         cache.Cache  // resource manager cache
+	podMonitoring bool         // whether monitoring groups were last synced with pod monitoring on
 }
 
 // Our logger instance.
 var log logger.Logger = logger.NewLogger(RDTController)
 
+// getContainerPids returns the processes of a container, overridable for testing.
+var getContainerPids = utils.GetProcessInContainer
+
 // our RDT controller singleton instance.
 var singleton *rdtctl
 
@@ -54,6 +66,10 @@ func getRDTController() control.Controller {
 
 // Start initializes the controller for enforcing decisions.
 func (ctl *rdtctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(RDTController); err != nil {
+		return err
+	}
+
 	if ctl.rdt != nil {
 		return nil
 	}
@@ -66,6 +82,8 @@ func (ctl *rdtctl) Start(cache cache.Cache, client client.Client) error {
 	ctl.rdt = &rdtc
 	ctl.cache = cache
 
+	ctl.syncMonGroups()
+
 	return nil
 }
 
@@ -83,6 +101,58 @@ func (ctl *rdtctl) PreStartHook(c cache.Container) error {
 	return nil
 }
 
+// FenceStartHook checks that the processes of a container about to start are in its RDT class.
+//
+// Runtimes which only create the processes of a container when starting it
+// leave nothing to check but the existence of the class. Otherwise processes
+// are assigned to the class ahead of the start, then checked to be in it.
+func (ctl *rdtctl) FenceStartHook(c cache.Container) error {
+	class := ctl.RDTClass(c)
+	if class == "" {
+		return nil
+	}
+	found := false
+	for _, name := range (*ctl.rdt).GetClasses() {
+		if name == class {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return rdtError("RDT class %s of container %s does not exist", class, c.PrettyName())
+	}
+
+	pids, err := getContainerPids(c.GetID())
+	if err != nil || len(pids) == 0 {
+		log.Debug("no processes of %s to check yet", c.PrettyName())
+		return nil
+	}
+	if err := ctl.assign(c, class); err != nil {
+		return err
+	}
+
+	tasks, err := (*ctl.rdt).GetClassTasks(class)
+	if err != nil {
+		return rdtError("failed to check processes of %s: %v", c.PrettyName(), err)
+	}
+	in := make(map[string]struct{}, len(tasks))
+	for _, task := range tasks {
+		in[task] = struct{}{}
+	}
+	missing := []string{}
+	for _, pid := range pids {
+		if _, ok := in[pid]; !ok {
+			missing = append(missing, pid)
+		}
+	}
+	if len(missing) > 0 {
+		return rdtError("processes %s of container %s are not in RDT class %s",
+			strings.Join(missing, ","), c.PrettyName(), class)
+	}
+
+	return nil
+}
+
 // PostStartHook is the RDT controller post-start hook.
 func (ctl *rdtctl) PostStartHook(c cache.Container) error {
 	// Notes:
@@ -112,6 +182,26 @@ func (ctl *rdtctl) PostUpdateHook(c cache.Container) error {
 
 // PostStop is the RDT controller post-stop hook.
 func (ctl *rdtctl) PostStopHook(c cache.Container) error {
+	if !(*ctl.rdt).MonSupported() {
+		return nil
+	}
+
+	// release the monitoring group of the pod with its last container
+	if pod, ok := c.GetPod(); ok {
+		for _, other := range pod.GetContainers() {
+			if other.GetID() == c.GetID() {
+				continue
+			}
+			switch other.GetState() {
+			case cache.ContainerStateRunning, cache.ContainerStateCreated:
+				return nil
+			}
+		}
+	}
+
+	if err := (*ctl.rdt).DeleteMonGroup(c.GetPodID()); err != nil {
+		return rdtError("failed to delete monitoring group of pod of %s: %v", c.PrettyName(), err)
+	}
 	return nil
 }
 
@@ -121,29 +211,163 @@ func (ctl *rdtctl) assign(c cache.Container, class string) error {
 		return nil
 	}
 
+	pids, err := getContainerPids(c.GetID())
+	if err != nil {
+		return rdtError("failed to get process list for container %s: %v", c.PrettyName(), err)
+	}
+
+	limit, limited := ctl.mbLimit(c)
+	if limited {
+		err := (*ctl.rdt).SetProcessMBLimit(class, limit, pids...)
+		switch {
+		case errors.Is(err, rdt.ErrTooManyMBLimits):
+			log.Warn("memory bandwidth limit %s of container %s not enforced: %v",
+				limit, c.PrettyName(), err)
+			limited = false
+		case err != nil:
+			return rdtError("failed assign container %s to class %s with memory bandwidth limit %s: %v",
+				c.PrettyName(), class, limit, err)
+		default:
+			log.Info("container %s assigned to class %s, memory bandwidth limited to %s",
+				c.PrettyName(), class, limit)
+		}
+	}
+	if !limited {
+		if err := (*ctl.rdt).SetProcessClass(class, pids...); err != nil {
+			return rdtError("failed assign container %s to class %s: %v", c.PrettyName(), class, err)
+		}
+		log.Info("container %s assigned to class %s", c.PrettyName(), class)
+	}
+
+	ctl.monitor(c, class, limit, pids)
+
+	return nil
+}
+
+// monitor puts the container in the RDT monitoring group of its pod.
+func (ctl *rdtctl) monitor(c cache.Container, class, limit string, pids []string) {
+	if !opt.PodMonitoring || !(*ctl.rdt).MonSupported() {
+		return
+	}
+
 	pod, ok := c.GetPod()
 	if !ok {
-		return rdtError("failed to get pod of container %s", c.PrettyName())
+		return
 	}
 
-	pids, err := utils.GetProcessInContainer(pod.GetCgroupParentDir(), c.GetID())
-	if err != nil {
-		return rdtError("failed to get process list for container %s: %v", c.PrettyName(), err)
+	group := rdt.MonGroup{
+		ID:        pod.GetID(),
+		Namespace: pod.GetNamespace(),
+		Pod:       pod.GetName(),
+		Priority:  monPriority(pod.GetQOSClass()),
+	}
+	if err := (*ctl.rdt).SetProcessMonGroup(class, limit, group, pids...); err != nil {
+		log.Error("failed to add container %s to monitoring group of its pod: %v",
+			c.PrettyName(), err)
 	}
+}
 
-	if err := (*ctl.rdt).SetProcessClass(class, pids...); err != nil {
-		return rdtError("failed assign container %s to class %s: %v", c.PrettyName(), class, err)
+// syncMonGroups drops the monitoring groups of pods which are gone and puts
+// running containers of pods without one in the monitoring groups of their pods.
+// Containers started later are put in their group by the post-start hook.
+func (ctl *rdtctl) syncMonGroups() {
+	ctl.podMonitoring = opt.PodMonitoring
+	if !(*ctl.rdt).MonSupported() {
+		return
 	}
 
-	log.Info("container %s assigned to class %s", c.PrettyName(), class)
+	groups := map[string]struct{}{}
+	for _, id := range (*ctl.rdt).GetMonGroups() {
+		if _, ok := ctl.cache.LookupPod(id); ok && opt.PodMonitoring {
+			groups[id] = struct{}{}
+			continue
+		}
+		if err := (*ctl.rdt).DeleteMonGroup(id); err != nil {
+			log.Warn("failed to delete monitoring group %s: %v", id, err)
+		}
+	}
 
-	return nil
+	if !opt.PodMonitoring {
+		return
+	}
+
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if _, ok := groups[c.GetPodID()]; ok {
+			continue
+		}
+		class := ctl.RDTClass(c)
+		if class == "" {
+			continue
+		}
+		pids, err := getContainerPids(c.GetID())
+		if err != nil {
+			log.Warn("failed to get process list for container %s: %v", c.PrettyName(), err)
+			continue
+		}
+		limit, _ := ctl.mbLimit(c)
+		ctl.monitor(c, class, limit, pids)
+	}
+}
+
+// monPriority returns the monitoring group priority for a pod QoS class.
+func monPriority(qos corev1.PodQOSClass) int {
+	switch qos {
+	case corev1.PodQOSGuaranteed:
+		return rdt.MonPriorityHigh
+	case corev1.PodQOSBurstable:
+		return rdt.MonPriorityMedium
+	}
+	return rdt.MonPriorityLow
+}
+
+// mbLimit returns the annotated memory bandwidth limit for a container, if any.
+func (ctl *rdtctl) mbLimit(c cache.Container) (string, bool) {
+	pod, ok := c.GetPod()
+	if !ok {
+		return "", false
+	}
+	value, ok := pod.GetResmgrAnnotation(keyMBLimit)
+	if !ok {
+		return "", false
+	}
+
+	limit := strings.TrimSpace(value)
+	if strings.Contains(value, ":") {
+		limits := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &limits); err != nil {
+			log.Error("%s: failed to parse annotation %s = '%s': %v",
+				c.PrettyName(), keyMBLimit, value, err)
+			return "", false
+		}
+		if limit, ok = limits[c.GetName()]; !ok {
+			return "", false
+		}
+	}
+
+	return strings.TrimSpace(limit), limit != ""
+}
+
+// ContainerClass returns the effective RDT class of a container.
+func (ctl *rdtctl) ContainerClass(c cache.Container) string {
+	return ctl.RDTClass(c)
 }
 
 // RDTClass determines the effective RDT class for a container.
 func (ctl *rdtctl) RDTClass(c cache.Container) string {
+	if rc := policy.GetRuntimeClassOptions(c); rc != nil && rc.SkipRDT {
+		log.Debug("RDT class for %s: skipped for its runtime class", c.PrettyName())
+		return ""
+	}
+
 	cclass := c.GetRDTClass()
 	if cclass == "" {
+		if rc, ok := control.GetClassManager().ResourceClass(c); ok && rc.RDT != "" {
+			log.Debug("RDT class for %s (resource class): %q", c.PrettyName(), rc.RDT)
+			return rc.RDT
+		}
 		cclass = string(c.GetQOSClass())
 	}
 	rdtclass, ok := opt.Classes[cclass]
@@ -161,6 +385,25 @@ func (ctl *rdtctl) RDTClass(c cache.Container) string {
 // configNotify is our runtime configuration notification callback.
 func (ctl *rdtctl) configNotify(event config.Event, source config.Source) error {
 	log.Info("configuration updated")
+	if ctl.rdt != nil && opt.PodMonitoring != ctl.podMonitoring {
+		ctl.syncMonGroups()
+	}
+	return nil
+}
+
+// classesNotify reassigns the RDT classes of containers after resource classes are updated.
+func (ctl *rdtctl) classesNotify(event config.Event, source config.Source) error {
+	if ctl.rdt == nil {
+		return nil
+	}
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.assign(c, ctl.RDTClass(c)); err != nil {
+			log.Error("failed to update RDT class of %s: %v", c.PrettyName(), err)
+		}
+	}
 	return nil
 }
 
@@ -172,4 +415,5 @@ func rdtError(format string, args ...interface{}) error {
 // Register us as a controller.
 func init() {
 	control.Register(RDTController, "RDT controller", getRDTController())
+	control.GetClassManager().AddNotify(getRDTController().(*rdtctl).classesNotify)
 }
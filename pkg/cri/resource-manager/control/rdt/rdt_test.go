@@ -0,0 +1,262 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rdt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+	"github.com/intel/cri-resource-manager/pkg/rdt"
+)
+
+// fakeContainer is a container with only its identity, RDT class, pod and state set.
+type fakeContainer struct {
+	cache.Container
+	id      string
+	class   string
+	pod     *fakePod
+	stopped bool
+}
+
+func (c *fakeContainer) GetID() string       { return c.id }
+func (c *fakeContainer) GetName() string     { return c.id }
+func (c *fakeContainer) PrettyName() string  { return "pod/" + c.id }
+func (c *fakeContainer) GetRDTClass() string { return c.class }
+func (c *fakeContainer) IsIgnored() bool     { return false }
+func (c *fakeContainer) GetPod() (cache.Pod, bool) {
+	if c.pod == nil {
+		return nil, false
+	}
+	return c.pod, true
+}
+func (c *fakeContainer) GetPodID() string {
+	if c.pod == nil {
+		return ""
+	}
+	return c.pod.id
+}
+func (c *fakeContainer) GetState() cache.ContainerState {
+	if c.stopped {
+		return cache.ContainerStateExited
+	}
+	return cache.ContainerStateRunning
+}
+
+// fakePod is a pod with only its identity set.
+type fakePod struct {
+	cache.Pod
+	id string
+}
+
+func (p *fakePod) GetID() string                                 { return p.id }
+func (p *fakePod) GetName() string                               { return p.id }
+func (p *fakePod) GetNamespace() string                          { return "default" }
+func (p *fakePod) GetQOSClass() corev1.PodQOSClass               { return corev1.PodQOSBurstable }
+func (p *fakePod) GetResmgrAnnotation(key string) (string, bool) { return "", false }
+
+// fakeRdt is an RDT control with a set of classes and their tasks.
+type fakeRdt struct {
+	rdt.Control
+	tasks map[string][]string // tasks by class
+	stuck bool                // whether assigning tasks silently fails
+	mon   map[string][]string // tasks by monitoring group, if monitoring is supported
+}
+
+func (r *fakeRdt) GetClasses() []string {
+	classes := []string{}
+	for class := range r.tasks {
+		classes = append(classes, class)
+	}
+	return classes
+}
+
+func (r *fakeRdt) SetProcessClass(class string, pids ...string) error {
+	if _, ok := r.tasks[class]; !ok {
+		return fmt.Errorf("unknown RDT class %q", class)
+	}
+	if !r.stuck {
+		r.tasks[class] = append(r.tasks[class], pids...)
+	}
+	return nil
+}
+
+func (r *fakeRdt) GetClassTasks(class string) ([]string, error) {
+	tasks, ok := r.tasks[class]
+	if !ok {
+		return nil, fmt.Errorf("unknown RDT class %q", class)
+	}
+	return tasks, nil
+}
+
+func (r *fakeRdt) MonSupported() bool {
+	return r.mon != nil
+}
+
+func (r *fakeRdt) GetMonGroups() []string {
+	ids := []string{}
+	for id := range r.mon {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (r *fakeRdt) DeleteMonGroup(id string) error {
+	delete(r.mon, id)
+	return nil
+}
+
+func (r *fakeRdt) SetProcessMonGroup(class, limit string, group rdt.MonGroup, pids ...string) error {
+	r.mon[group.ID] = append(r.mon[group.ID], pids...)
+	return nil
+}
+
+func TestFenceStartHook(t *testing.T) {
+	saved := getContainerPids
+	defer func() { getContainerPids = saved }()
+
+	tcs := []struct {
+		name    string
+		class   string
+		pids    []string
+		stuck   bool
+		invalid string
+	}{
+		{
+			name:  "no processes yet",
+			class: "Guaranteed",
+		},
+		{
+			name:  "processes assigned",
+			class: "Guaranteed",
+			pids:  []string{"10", "11"},
+		},
+		{
+			name:    "processes not in class",
+			class:   "Guaranteed",
+			pids:    []string{"10", "11"},
+			stuck:   true,
+			invalid: "processes 10,11 of container pod/ctr are not in RDT class Guaranteed",
+		},
+		{
+			name:    "unknown class",
+			class:   "Premium",
+			pids:    []string{"10"},
+			invalid: "RDT class Premium of container pod/ctr does not exist",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			getContainerPids = func(string) ([]string, error) {
+				if len(tc.pids) == 0 {
+					return nil, fmt.Errorf("no cgroup found")
+				}
+				return tc.pids, nil
+			}
+			var rc rdt.Control = &fakeRdt{
+				tasks: map[string][]string{"Guaranteed": {"1"}, "BestEffort": {}},
+				stuck: tc.stuck,
+			}
+			ctl := &rdtctl{rdt: &rc}
+
+			err := ctl.FenceStartHook(&fakeContainer{id: "ctr", class: tc.class})
+			if tc.invalid != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.invalid) {
+					t.Errorf("expected error %q, got %v", tc.invalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tasks, _ := rc.GetClassTasks(tc.class)
+			if len(tasks) != 1+len(tc.pids) {
+				t.Errorf("expected processes %v assigned to %s, got tasks %v", tc.pids, tc.class, tasks)
+			}
+		})
+	}
+}
+
+func TestSyncMonGroups(t *testing.T) {
+	if defaults := defaultOptions().(*options); defaults.PodMonitoring {
+		t.Errorf("expected per-pod monitoring to be off by default")
+	}
+
+	savedPids := getContainerPids
+	defer func() { getContainerPids = savedPids }()
+	getContainerPids = func(id string) ([]string, error) {
+		return []string{id + "-pid"}, nil
+	}
+	savedOpt := *opt
+	defer func() { *opt = savedOpt }()
+
+	pod1, pod2 := &fakePod{id: "pod1"}, &fakePod{id: "pod2"}
+	fr := &fakeRdt{
+		tasks: map[string][]string{"Burstable": {}},
+		mon: map[string][]string{
+			"pod1": {"ctr1-pid"},
+			"gone": {"1"},
+		},
+	}
+	var rc rdt.Control = fr
+	ctl := &rdtctl{
+		rdt: &rc,
+		cache: &cachetest.FakeCache{
+			Containers: []cache.Container{
+				&fakeContainer{id: "ctr1", class: "Burstable", pod: pod1},
+				&fakeContainer{id: "ctr2", class: "Burstable", pod: pod2},
+				&fakeContainer{id: "ctr3", class: "Burstable", pod: pod2, stopped: true},
+			},
+		},
+	}
+	check := func(expected map[string][]string) {
+		if len(fr.mon) != len(expected) {
+			t.Errorf("expected monitoring groups %v, got %v", expected, fr.mon)
+			return
+		}
+		for id, pids := range expected {
+			if strings.Join(fr.mon[id], ",") != strings.Join(pids, ",") {
+				t.Errorf("expected monitoring groups %v, got %v", expected, fr.mon)
+				return
+			}
+		}
+	}
+
+	// groups of pods which are gone are dropped, pods without a group get one
+	opt.PodMonitoring = true
+	ctl.syncMonGroups()
+	check(map[string][]string{"pod1": {"ctr1-pid"}, "pod2": {"ctr2-pid"}})
+
+	// unrelated configuration updates leave the groups alone
+	fr.mon["gone"] = []string{"1"}
+	if err := ctl.configNotify(config.UpdateEvent, config.ConfigFile); err != nil {
+		t.Fatalf("configuration update failed: %v", err)
+	}
+	check(map[string][]string{"pod1": {"ctr1-pid"}, "pod2": {"ctr2-pid"}, "gone": {"1"}})
+
+	// turning per-pod monitoring off drops all the groups
+	opt.PodMonitoring = false
+	if err := ctl.configNotify(config.UpdateEvent, config.ConfigFile); err != nil {
+		t.Fatalf("configuration update failed: %v", err)
+	}
+	check(map[string][]string{})
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+var configHelp = `
+Resource Manager scheduling policy controller.
+
+The scheduling policy controller sets the scheduling policy, real-time priority
+or niceness of the threads of containers, without the containers needing to be
+privileged to do so themselves. Scheduling classes are defined in Classes, each
+with a Policy (other, batch, idle, fifo, or rr), a real-time Priority (1 - 99)
+for the fifo and rr policies, or a Nice value (-20 - 19) for the others.
+
+Containers select their scheduling class with the annotation
+
+  cri-resource-manager.intel.com/scheduling-class: <class>
+
+or, per container, with a map of container names to classes. Alternatively the
+class can be set by the unified resource class of the container.
+
+With cgroup v1 and real-time group scheduling, real-time threads can only run
+in cgroups with a real-time runtime budget. For classes with a real-time policy
+RTRuntime sets the budget of containers, in microseconds per period. The
+budgets of the parent cgroups are raised as necessary to fit the budget of the
+container. Without RTRuntime the container needs to have a budget already.
+
+Real-time classes are only applied to containers with exclusive CPUs, CPUs not
+shared with any other container. The threads of a container which loses its
+exclusive CPUs are reset to the default scheduling policy.
+
+The threads of containers are periodically rechecked and any new ones are
+adjusted, too. RecheckInterval sets the interval of these checks. Setting it
+to 0 disables rechecking.
+
+Here is a sample configuration fragment defining a real-time and a background
+class:
+
+  scheduling:
+    Classes:
+      realtime:
+        Policy: fifo
+        Priority: 50
+        RTRuntime: 100000
+      background:
+        Policy: batch
+        Nice: 10
+`
@@ -0,0 +1,169 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+)
+
+// scheduling policies, by name
+const (
+	policyOther = "other"
+	policyBatch = "batch"
+	policyIdle  = "idle"
+	policyFIFO  = "fifo"
+	policyRR    = "rr"
+
+	// minNice is the smallest valid niceness.
+	minNice = -20
+	// maxNice is the largest valid niceness.
+	maxNice = 19
+	// minRTPrio is the smallest valid real-time priority.
+	minRTPrio = 1
+	// maxRTPrio is the largest valid real-time priority.
+	maxRTPrio = 99
+)
+
+// schedClass is a set of scheduling attributes to apply to the threads of containers.
+type schedClass struct {
+	// Policy is the scheduling policy: other, batch, idle, fifo, or rr.
+	Policy string `json:",omitempty"`
+	// Priority is the real-time priority for the fifo and rr policies.
+	Priority int `json:",omitempty"`
+	// Nice is the niceness for the other, batch and idle policies.
+	Nice *int `json:",omitempty"`
+	// RTRuntime is the real-time runtime budget of containers in microseconds, on cgroup v1.
+	RTRuntime int64 `json:",omitempty"`
+}
+
+// options captures our configurable parameters.
+type options struct {
+	// Classes maps scheduling class names to scheduling attributes.
+	Classes map[string]*schedClass `json:",omitempty"`
+	// RecheckInterval is the interval for adjusting new threads.
+	RecheckInterval control.RecheckInterval `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		Classes:         make(map[string]*schedClass),
+		RecheckInterval: "10s",
+	}
+}
+
+// policy returns the normalized scheduling policy of the class.
+func (c *schedClass) policy() string {
+	if c.Policy == "" {
+		return policyOther
+	}
+	return strings.ToLower(c.Policy)
+}
+
+// isRealtime checks if the class uses a real-time scheduling policy.
+func (c *schedClass) isRealtime() bool {
+	switch c.policy() {
+	case policyFIFO, policyRR:
+		return true
+	}
+	return false
+}
+
+// apply sets the scheduling attributes of the class for a thread.
+func (c *schedClass) apply(tid int) error {
+	if err := setScheduler(tid, c.policy(), c.Priority); err != nil {
+		return err
+	}
+	if c.Nice != nil && !c.isRealtime() {
+		return setNice(tid, *c.Nice)
+	}
+	return nil
+}
+
+// validate checks the scheduling attributes of the class.
+func (c *schedClass) validate() error {
+	switch c.policy() {
+	case policyOther, policyBatch, policyIdle:
+		if c.Priority != 0 {
+			return fmt.Errorf("priority is only valid for fifo and rr policies")
+		}
+		if c.Nice != nil && (*c.Nice < minNice || *c.Nice > maxNice) {
+			return fmt.Errorf("invalid nice %d, expecting %d - %d", *c.Nice, minNice, maxNice)
+		}
+		if c.RTRuntime != 0 {
+			return fmt.Errorf("RTRuntime is only valid for fifo and rr policies")
+		}
+	case policyFIFO, policyRR:
+		if c.Priority < minRTPrio || c.Priority > maxRTPrio {
+			return fmt.Errorf("invalid priority %d, expecting %d - %d",
+				c.Priority, minRTPrio, maxRTPrio)
+		}
+		if c.Nice != nil {
+			return fmt.Errorf("nice is only valid for other, batch and idle policies")
+		}
+		if c.RTRuntime < 0 {
+			return fmt.Errorf("invalid RTRuntime %d", c.RTRuntime)
+		}
+	default:
+		return fmt.Errorf("invalid policy %q", c.Policy)
+	}
+	return nil
+}
+
+// String returns the scheduling attributes of the class as a string.
+func (c *schedClass) String() string {
+	if c.isRealtime() {
+		return fmt.Sprintf("%s, priority %d", c.policy(), c.Priority)
+	}
+	if c.Nice != nil {
+		return fmt.Sprintf("%s, nice %d", c.policy(), *c.Nice)
+	}
+	return c.policy()
+}
+
+// validateOptions checks that all configured scheduling classes are valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return schedError("invalid configuration type %T", cfg)
+	}
+	for name, class := range o.Classes {
+		if class == nil {
+			return schedError("scheduling class %s has no attributes", name)
+		}
+		if err := class.validate(); err != nil {
+			return schedError("invalid scheduling class %s: %v", name, err)
+		}
+	}
+	if err := o.RecheckInterval.Validate(); err != nil {
+		return schedError("%v", err)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.scheduling", configHelp, opt, defaultOptions,
+		config.WithNotify(getSchedController().(*schedctl).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
@@ -0,0 +1,495 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// SchedController is the name of the scheduling policy controller.
+	SchedController = "scheduling"
+	// keySchedClass is the annotation key for selecting the scheduling class of containers.
+	keySchedClass = "scheduling-class"
+
+	// cgroupRoot is the mount point of the cgroup hierarchies.
+	cgroupRoot = "/sys/fs/cgroup"
+	// rtRuntimeEntry is the cgroup v1 real-time runtime budget entry.
+	rtRuntimeEntry = "cpu.rt_runtime_us"
+)
+
+// tracked is a container we set the scheduling attributes of.
+type tracked struct {
+	name  string              // pretty name of the container
+	id    string              // container ID
+	class string              // name of the scheduling class
+	tids  map[string]struct{} // threads already adjusted
+}
+
+// schedctl encapsulates the runtime state of our scheduling policy controller.
+type schedctl struct {
+	sync.Mutex
+	cache      cache.Cache         // resource manager cache
+	procRoot   string              // procfs mount point
+	v2         bool                // whether cgroup v2 is in use
+	containers map[string]*tracked // containers we adjust, by cache ID
+	stop       chan struct{}       // channel to stop rechecking
+}
+
+// Our singleton scheduling policy controller instance.
+var singleton *schedctl
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(SchedController)
+
+// resolveCgroupDir resolves the cgroup directory of a container, overridable for testing.
+var resolveCgroupDir = cgroups.ResolveCgroupDir
+
+// getSchedController returns our singleton scheduling policy controller instance.
+func getSchedController() control.Controller {
+	if singleton == nil {
+		singleton = &schedctl{
+			procRoot:   "/proc",
+			containers: make(map[string]*tracked),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *schedctl) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(SchedController); err != nil {
+		return err
+	}
+
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	ctl.v2 = err == nil
+
+	ctl.cache = cache
+	ctl.startRecheck()
+
+	return nil
+}
+
+// Stop shuts down the controller.
+func (ctl *schedctl) Stop() {
+	ctl.stopRecheck()
+}
+
+// PreCreateHook is the scheduling policy controller pre-create hook.
+func (ctl *schedctl) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the scheduling policy controller pre-start hook.
+func (ctl *schedctl) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook is the scheduling policy controller post-start hook.
+func (ctl *schedctl) PostStartHook(c cache.Container) error {
+	return ctl.adjust(c)
+}
+
+// PostUpdateHook is the scheduling policy controller post-update hook.
+func (ctl *schedctl) PostUpdateHook(c cache.Container) error {
+	if c.GetState() != cache.ContainerStateRunning {
+		return nil
+	}
+	return ctl.adjust(c)
+}
+
+// PostStopHook is the scheduling policy controller post-stop hook.
+func (ctl *schedctl) PostStopHook(c cache.Container) error {
+	ctl.Lock()
+	defer ctl.Unlock()
+	delete(ctl.containers, c.GetCacheID())
+	return nil
+}
+
+// adjust sets the scheduling attributes of all threads of the container.
+//
+// Real-time classes are only applied to containers with exclusive CPUs, so that
+// real-time threads can't starve other containers sharing their CPUs. Threads
+// of a container which loses its exclusive CPUs are reset to the default class.
+func (ctl *schedctl) adjust(c cache.Container) error {
+	name, ok := schedClassOf(c)
+	if !ok {
+		return nil
+	}
+	class := opt.Classes[name]
+	exclusive := !class.isRealtime() || ctl.hasExclusiveCPUs(c)
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	t, ok := ctl.containers[c.GetCacheID()]
+	if !exclusive {
+		if ok {
+			ctl.reset(t)
+			delete(ctl.containers, c.GetCacheID())
+		}
+		log.Warn("%s: not setting real-time scheduling class %s on shared CPUs",
+			c.PrettyName(), name)
+		return nil
+	}
+	if !ok || t.class != name || t.id != c.GetID() {
+		if class.isRealtime() {
+			if err := ctl.setRTRuntime(c, class.RTRuntime); err != nil {
+				return err
+			}
+		}
+		t = &tracked{
+			name:  c.PrettyName(),
+			id:    c.GetID(),
+			class: name,
+			tids:  make(map[string]struct{}),
+		}
+		ctl.containers[c.GetCacheID()] = t
+	}
+
+	return ctl.apply(t)
+}
+
+// apply sets the scheduling attributes of any new threads of a tracked container.
+func (ctl *schedctl) apply(t *tracked) error {
+	class, ok := opt.Classes[t.class]
+	if !ok {
+		return nil
+	}
+
+	pids, err := utils.GetProcessInContainer(t.id)
+	if err != nil {
+		return schedError("%s: failed to get processes: %v", t.name, err)
+	}
+
+	seen := make(map[string]struct{})
+	for _, pid := range pids {
+		tids, err := ioutil.ReadDir(filepath.Join(ctl.procRoot, pid, "task"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range tids {
+			tid := entry.Name()
+			if _, ok := t.tids[tid]; ok {
+				seen[tid] = struct{}{}
+				continue
+			}
+			id, err := strconv.Atoi(tid)
+			if err != nil {
+				continue
+			}
+			if err := class.apply(id); err != nil {
+				if isThreadGone(err) {
+					continue
+				}
+				return schedError("%s: failed to set scheduling class %s of thread %d: %v",
+					t.name, t.class, id, err)
+			}
+			seen[tid] = struct{}{}
+			log.Debug("%s: thread %d set to scheduling class %s", t.name, id, t.class)
+		}
+	}
+
+	if len(t.tids) == 0 && len(seen) > 0 {
+		log.Info("%s: scheduling class set to %s (%s)", t.name, t.class, class)
+	}
+	t.tids = seen
+
+	return nil
+}
+
+// reset resets the threads of a tracked container to the default scheduling class.
+func (ctl *schedctl) reset(t *tracked) {
+	for tid := range t.tids {
+		id, err := strconv.Atoi(tid)
+		if err != nil {
+			continue
+		}
+		if err := setScheduler(id, policyOther, 0); err != nil && !isThreadGone(err) {
+			log.Error("%s: failed to reset scheduling class of thread %d: %v", t.name, id, err)
+		}
+	}
+	log.Info("%s: scheduling class %s reset", t.name, t.class)
+}
+
+// hasExclusiveCPUs checks if the CPUs of a container are not shared with other containers.
+func (ctl *schedctl) hasExclusiveCPUs(c cache.Container) bool {
+	cpus, err := cpuset.Parse(c.GetCpusetCpus())
+	if err != nil || cpus.IsEmpty() {
+		return false
+	}
+	own, ok := cache.ExclusiveCPUs(ctl.cache.GetContainers())[c.GetCacheID()]
+	return ok && own.Equals(cpus)
+}
+
+// setRTRuntime sets up the cgroup v1 real-time runtime budget of a container.
+//
+// With real-time group scheduling every cgroup needs a budget for its real-time
+// tasks, and the budget of a parent must cover the budgets of all its children.
+// We raise the budgets of the parents of the container, starting from the top,
+// as needed to fit the budget of the container. Budgets of parents are not given
+// back once containers stop. Without a configured budget the container must
+// already have one, otherwise setting a real-time policy would fail.
+func (ctl *schedctl) setRTRuntime(c cache.Container, budget int64) error {
+	if ctl.v2 {
+		return nil
+	}
+
+	cpuDir := filepath.Join(cgroupRoot, "cpu")
+	dir, err := resolveCgroupDir(c.GetID())
+	if err != nil {
+		return schedError("%s: failed to find cgroup: %v", c.PrettyName(), err)
+	}
+
+	return setCgroupRTRuntime(c.PrettyName(), cpuDir, filepath.Join(cpuDir, dir), budget)
+}
+
+// setCgroupRTRuntime sets the real-time runtime budget of a cgroup under the given cpu hierarchy.
+func setCgroupRTRuntime(name, cpuDir, path string, budget int64) error {
+	if _, err := os.Stat(filepath.Join(path, rtRuntimeEntry)); os.IsNotExist(err) {
+		// no real-time group scheduling, no budget needed
+		return nil
+	}
+
+	current, err := readRTRuntime(path)
+	if err != nil {
+		return schedError("%s: %v", name, err)
+	}
+	if budget == 0 {
+		if current <= 0 {
+			return schedError("%s: no real-time runtime budget, RTRuntime needs to be set", name)
+		}
+		return nil
+	}
+
+	if budget > current {
+		// find the budgets the parents need, from the container up...
+		parents := []string{}
+		needs := []int64{}
+		child, need := path, budget
+		for p := filepath.Dir(path); p != cpuDir && strings.HasPrefix(p, cpuDir); p = filepath.Dir(p) {
+			n, err := neededRTRuntime(p, child, need)
+			if err != nil {
+				return schedError("%s: %v", name, err)
+			}
+			parents = append(parents, p)
+			needs = append(needs, n)
+			child, need = p, n
+		}
+		// ...then raise them, from the top down
+		for i := len(parents) - 1; i >= 0; i-- {
+			if err := writeRTRuntime(parents[i], needs[i]); err != nil {
+				return schedError("%s: %v", name, err)
+			}
+		}
+	}
+
+	if err := writeRTRuntime(path, budget); err != nil {
+		return schedError("%s: %v", name, err)
+	}
+	log.Info("%s: real-time runtime budget set to %dus", name, budget)
+
+	return nil
+}
+
+// neededRTRuntime returns the real-time budget a cgroup needs to fit a child with the given budget.
+func neededRTRuntime(dir, child string, budget int64) (int64, error) {
+	current, err := readRTRuntime(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	used := budget
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %v", dir, err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !e.IsDir() || path == child {
+			continue
+		}
+		if runtime, err := readRTRuntime(path); err == nil && runtime > 0 {
+			used += runtime
+		}
+	}
+
+	if current > used {
+		return current, nil
+	}
+	return used, nil
+}
+
+// readRTRuntime reads the real-time runtime budget of a cgroup.
+func readRTRuntime(dir string) (int64, error) {
+	path := filepath.Join(dir, rtRuntimeEntry)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	runtime, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", path, string(data), err)
+	}
+	return runtime, nil
+}
+
+// writeRTRuntime writes the real-time runtime budget of a cgroup.
+func writeRTRuntime(dir string, runtime int64) error {
+	path := filepath.Join(dir, rtRuntimeEntry)
+	if err := ioutil.WriteFile(path, []byte(strconv.FormatInt(runtime, 10)), 0644); err != nil {
+		return fmt.Errorf("failed to set %s to %d: %v", path, runtime, err)
+	}
+	return nil
+}
+
+// schedClassOf returns the name of the scheduling class of the container, if any.
+func schedClassOf(c cache.Container) (string, bool) {
+	name, ok := annotatedSchedClass(c)
+	if !ok {
+		rc, ok := control.GetClassManager().ResourceClass(c)
+		if !ok || rc.Scheduling == "" {
+			return "", false
+		}
+		name = rc.Scheduling
+	}
+
+	if _, ok := opt.Classes[name]; !ok {
+		log.Error("%s: ignoring unknown scheduling class %s", c.PrettyName(), name)
+		return "", false
+	}
+	return name, true
+}
+
+// annotatedSchedClass returns the scheduling class annotated for the container, if any.
+func annotatedSchedClass(c cache.Container) (string, bool) {
+	pod, ok := c.GetPod()
+	if !ok {
+		return "", false
+	}
+	value, ok := pod.GetResmgrAnnotation(keySchedClass)
+	if !ok {
+		return "", false
+	}
+
+	class := strings.TrimSpace(value)
+	if strings.Contains(value, ":") {
+		preferences := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+			log.Error("%s: failed to parse annotation %s = '%s': %v",
+				c.PrettyName(), keySchedClass, value, err)
+			return "", false
+		}
+		if class, ok = preferences[c.GetName()]; !ok {
+			return "", false
+		}
+	}
+
+	return strings.TrimSpace(class), class != ""
+}
+
+// startRecheck starts periodically adjusting new threads of containers.
+func (ctl *schedctl) startRecheck() {
+	ctl.stopRecheck()
+	interval := opt.RecheckInterval.Duration()
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	ctl.stop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case _ = <-stop:
+				return
+			case _ = <-ticker.C:
+				ctl.recheck()
+			}
+		}
+	}()
+}
+
+// stopRecheck stops periodically adjusting new threads of containers.
+func (ctl *schedctl) stopRecheck() {
+	if ctl.stop != nil {
+		close(ctl.stop)
+		ctl.stop = nil
+	}
+}
+
+// recheck sets the scheduling attributes of any new threads of tracked containers.
+func (ctl *schedctl) recheck() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for _, t := range ctl.containers {
+		if err := ctl.apply(t); err != nil {
+			log.Debug("%v", err)
+		}
+	}
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *schedctl) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	if ctl.cache == nil {
+		return nil
+	}
+	ctl.startRecheck()
+	for _, c := range ctl.cache.GetContainers() {
+		if c.IsIgnored() || c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if err := ctl.adjust(c); err != nil {
+			log.Error("failed to update scheduling class of %s: %v", c.PrettyName(), err)
+		}
+	}
+
+	return nil
+}
+
+// schedError returns a controller-specific formatted error.
+func schedError(format string, args ...interface{}) error {
+	return fmt.Errorf("scheduling: "+format, args...)
+}
+
+// init registers this controller.
+func init() {
+	control.Register(SchedController, "scheduling policy controller", getSchedController())
+	control.GetClassManager().AddNotify(getSchedController().(*schedctl).configNotify)
+}
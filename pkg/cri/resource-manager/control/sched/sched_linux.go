@@ -0,0 +1,56 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sched_setscheduler(2) policies
+var schedPolicies = map[string]int{
+	policyOther: 0,
+	policyFIFO:  1,
+	policyRR:    2,
+	policyBatch: 3,
+	policyIdle:  5,
+}
+
+// schedParam is struct sched_param of sched_setscheduler(2).
+type schedParam struct {
+	priority int32
+}
+
+// setScheduler sets the scheduling policy and real-time priority of a thread.
+func setScheduler(tid int, policy string, priority int) error {
+	param := schedParam{priority: int32(priority)}
+	_, _, errno := unix.Syscall(unix.SYS_SCHED_SETSCHEDULER, uintptr(tid),
+		uintptr(schedPolicies[policy]), uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setNice sets the niceness of a thread.
+func setNice(tid, nice int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, tid, nice)
+}
+
+// isThreadGone checks if an error is caused by a thread no longer existing.
+func isThreadGone(err error) bool {
+	return err == unix.ESRCH
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package sched
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// setScheduler sets the scheduling policy and real-time priority of a thread.
+func setScheduler(tid int, policy string, priority int) error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
+
+// setNice sets the niceness of a thread.
+func setNice(tid, nice int) error {
+	return fmt.Errorf("not supported on %s", runtime.GOOS)
+}
+
+// isThreadGone checks if an error is caused by a thread no longer existing.
+func isThreadGone(err error) bool {
+	return false
+}
@@ -0,0 +1,236 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sched
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+)
+
+// fakePod is a pod with only resource manager annotations.
+type fakePod struct {
+	cache.Pod
+	annotations map[string]string
+}
+
+func (p *fakePod) GetResmgrAnnotation(key string) (string, bool) {
+	value, ok := p.annotations[key]
+	return value, ok
+}
+
+// fakeContainer is a running container with a cpuset and an annotated scheduling class.
+type fakeContainer struct {
+	cache.Container
+	name string
+	cpus string
+	pod  *fakePod
+}
+
+func (c *fakeContainer) GetCacheID() string             { return c.name }
+func (c *fakeContainer) GetID() string                  { return "id-" + c.name }
+func (c *fakeContainer) GetName() string                { return c.name }
+func (c *fakeContainer) PrettyName() string             { return "pod/" + c.name }
+func (c *fakeContainer) GetCpusetCpus() string          { return c.cpus }
+func (c *fakeContainer) GetState() cache.ContainerState { return cache.ContainerStateRunning }
+func (c *fakeContainer) GetPod() (cache.Pod, bool)      { return c.pod, true }
+
+// newFakeContainer creates a container with the given CPUs and scheduling class.
+func newFakeContainer(name, cpus, class string) *fakeContainer {
+	return &fakeContainer{
+		name: name,
+		cpus: cpus,
+		pod:  &fakePod{annotations: map[string]string{keySchedClass: class}},
+	}
+}
+
+func TestValidateClass(t *testing.T) {
+	nice := func(n int) *int { return &n }
+	tcs := []struct {
+		name  string
+		class *schedClass
+		err   bool
+	}{
+		{name: "default", class: &schedClass{}},
+		{name: "batch, nice", class: &schedClass{Policy: "batch", Nice: nice(10)}},
+		{name: "fifo", class: &schedClass{Policy: "FIFO", Priority: 50, RTRuntime: 1000}},
+		{name: "rr without priority", class: &schedClass{Policy: "rr"}, err: true},
+		{name: "fifo, nice", class: &schedClass{Policy: "fifo", Priority: 1, Nice: nice(0)}, err: true},
+		{name: "other, priority", class: &schedClass{Priority: 1}, err: true},
+		{name: "other, runtime", class: &schedClass{RTRuntime: 1000}, err: true},
+		{name: "idle, invalid nice", class: &schedClass{Policy: "idle", Nice: nice(20)}, err: true},
+		{name: "invalid policy", class: &schedClass{Policy: "deadline"}, err: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.class.validate(); (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestHasExclusiveCPUs(t *testing.T) {
+	exclusive := newFakeContainer("exclusive", "0-1", "")
+	partial := newFakeContainer("partial", "2-3", "")
+	shared := newFakeContainer("shared", "3-5", "")
+	other := newFakeContainer("other", "4-5", "")
+	empty := newFakeContainer("empty", "", "")
+	ctl := &schedctl{
+		cache: &cachetest.FakeCache{
+			Containers: []cache.Container{exclusive, partial, shared, other, empty},
+		},
+	}
+
+	for _, tc := range []struct {
+		c         *fakeContainer
+		exclusive bool
+	}{
+		{c: exclusive, exclusive: true},
+		{c: partial},
+		{c: shared},
+		{c: other},
+		{c: empty},
+	} {
+		if ctl.hasExclusiveCPUs(tc.c) != tc.exclusive {
+			t.Errorf("%s: expected exclusive CPUs %v", tc.c.name, tc.exclusive)
+		}
+	}
+}
+
+func TestAdjustRealtimeOnSharedCPUs(t *testing.T) {
+	saved := opt.Classes
+	defer func() { opt.Classes = saved }()
+	opt.Classes = map[string]*schedClass{
+		"realtime": {Policy: "fifo", Priority: 50},
+	}
+
+	c := newFakeContainer("ctr", "0-1", "realtime")
+	other := newFakeContainer("other", "1-2", "")
+	ctl := &schedctl{
+		cache:      &cachetest.FakeCache{Containers: []cache.Container{c, other}},
+		containers: map[string]*tracked{},
+	}
+
+	if err := ctl.adjust(c); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := ctl.containers[c.GetCacheID()]; ok {
+		t.Errorf("expected real-time class not to be set on shared CPUs")
+	}
+
+	// a container losing its exclusive CPUs is no longer tracked
+	ctl.containers[c.GetCacheID()] = &tracked{
+		name:  c.PrettyName(),
+		id:    c.GetID(),
+		class: "realtime",
+		tids:  map[string]struct{}{strconv.Itoa(1 << 30): {}},
+	}
+	if err := ctl.adjust(c); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := ctl.containers[c.GetCacheID()]; ok {
+		t.Errorf("expected real-time class to be reset on shared CPUs")
+	}
+}
+
+// mkCgroup creates a cgroup directory with the given real-time runtime budget, if any.
+func mkCgroup(t *testing.T, dir string, runtime string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+	if runtime == "" {
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, rtRuntimeEntry), []byte(runtime+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write budget of %s: %v", dir, err)
+	}
+}
+
+func TestSetCgroupRTRuntime(t *testing.T) {
+	tcs := []struct {
+		name     string
+		budgets  []string // budgets of kubepods, pod, container, sibling
+		budget   int64
+		err      bool
+		expected []int64 // expected budgets of kubepods, pod, container
+	}{
+		{
+			name:     "raise parents",
+			budgets:  []string{"20000", "10000", "0", "15000"},
+			budget:   30000,
+			expected: []int64{45000, 45000, 30000},
+		},
+		{
+			name:     "parents large enough",
+			budgets:  []string{"100000", "50000", "0", "0"},
+			budget:   30000,
+			expected: []int64{100000, 50000, 30000},
+		},
+		{
+			name:     "no budget, none configured",
+			budgets:  []string{"100000", "50000", "0", "0"},
+			err:      true,
+			expected: []int64{100000, 50000, 0},
+		},
+		{
+			name:     "existing budget, none configured",
+			budgets:  []string{"100000", "50000", "20000", "0"},
+			expected: []int64{100000, 50000, 20000},
+		},
+		{
+			name:    "no real-time group scheduling",
+			budgets: []string{"", "", "", ""},
+			budget:  30000,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "sched-test")
+			if err != nil {
+				t.Fatalf("failed to create test directory: %v", err)
+			}
+			defer os.RemoveAll(root)
+
+			cpuDir := filepath.Join(root, "cpu")
+			dirs := []string{
+				filepath.Join(cpuDir, "kubepods"),
+				filepath.Join(cpuDir, "kubepods", "pod"),
+				filepath.Join(cpuDir, "kubepods", "pod", "ctr"),
+				filepath.Join(cpuDir, "kubepods", "pod", "sibling"),
+			}
+			mkCgroup(t, cpuDir, "950000")
+			for i, dir := range dirs {
+				mkCgroup(t, dir, tc.budgets[i])
+			}
+
+			err = setCgroupRTRuntime("pod/ctr", cpuDir, dirs[2], tc.budget)
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+			for i, expected := range tc.expected {
+				if budget, _ := readRTRuntime(dirs[i]); budget != expected {
+					t.Errorf("%s: expected budget %d, got %d", dirs[i], expected, budget)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package throttle
+
+var configHelp = `
+Resource Manager startup throttling controller.
+
+The startup throttling controller keeps bursts of pod startups from stealing
+CPU cycles and disk bandwidth from running containers. Pulling and unpacking
+images and setting up pod sandboxes is done mostly by the container runtime
+itself, and for VM-based runtimes partly in the cgroup of the pod.
+
+With ImagePull enabled, the cgroup of the runtime is put into a low-priority
+CPU and block I/O class while any image is being pulled. With Sandbox enabled,
+the cgroup of a newly created pod is put into the same class until one of its
+containers starts, and the runtime is kept there as long as any pod is starting
+up. The original settings are restored at that point, or once StartupTimeout
+has passed without any container of the pod starting.
+
+The low-priority class is given by CPUShares and BlkioWeight, the latter of
+which is left untouched if set to 0. RuntimeCgroup is the cgroup of the runtime,
+relative to the cgroup hierarchy mount points. With cgroup v2, CPUShares is
+converted to cpu.weight and BlkioWeight is used for io.bfq.weight, or converted
+to io.weight if BFQ is not in use.
+
+Here is a sample configuration fragment to throttle both image pulls and pod
+startup of CRI-O:
+
+  throttle:
+    ImagePull: true
+    Sandbox: true
+    RuntimeCgroup: system.slice/crio.service
+    CPUShares: 2
+    BlkioWeight: 10
+`
@@ -0,0 +1,92 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package throttle
+
+import (
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+)
+
+// options captures our configurable parameters.
+type options struct {
+	// ImagePull enables throttling the runtime while images are pulled.
+	ImagePull bool
+	// Sandbox enables throttling pods and the runtime until containers start.
+	Sandbox bool
+	// RuntimeCgroup is the cgroup of the container runtime.
+	RuntimeCgroup string `json:",omitempty"`
+	// CPUShares is the CPU shares of throttled cgroups.
+	CPUShares int
+	// BlkioWeight is the block I/O weight of throttled cgroups, 0 leaves it untouched.
+	BlkioWeight int
+	// StartupTimeout is the longest a pod is throttled without a container starting.
+	StartupTimeout string `json:",omitempty"`
+}
+
+// Our runtime configuration.
+var opt = defaultOptions().(*options)
+
+// defaultOptions returns a new options instance, all initialized to defaults.
+func defaultOptions() interface{} {
+	return &options{
+		ImagePull:      false,
+		Sandbox:        false,
+		RuntimeCgroup:  "system.slice/containerd.service",
+		CPUShares:      2,
+		BlkioWeight:    10,
+		StartupTimeout: "5m",
+	}
+}
+
+// startupTimeout returns the configured startup timeout, 0 if disabled.
+func (o *options) startupTimeout() time.Duration {
+	if o.StartupTimeout == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(o.StartupTimeout)
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+// validateOptions checks that the configured throttling values are valid.
+func validateOptions(cfg interface{}) error {
+	o, ok := cfg.(*options)
+	if !ok {
+		return throttleError("invalid configuration type %T", cfg)
+	}
+	if o.CPUShares < 2 || o.CPUShares > 262144 {
+		return throttleError("invalid CPUShares %d, expecting 2 - 262144", o.CPUShares)
+	}
+	if o.BlkioWeight != 0 && (o.BlkioWeight < 10 || o.BlkioWeight > 1000) {
+		return throttleError("invalid BlkioWeight %d, expecting 0 or 10 - 1000", o.BlkioWeight)
+	}
+	if o.StartupTimeout != "" {
+		if _, err := time.ParseDuration(o.StartupTimeout); err != nil {
+			return throttleError("invalid StartupTimeout %q: %v", o.StartupTimeout, err)
+		}
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.throttle", configHelp, opt, defaultOptions,
+		config.WithNotify(getThrottleController().(*throttle).configNotify),
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
@@ -0,0 +1,448 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package throttle
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// ThrottleController is the name of the startup throttling controller.
+	ThrottleController = "throttle"
+)
+
+// entry is a cgroup entry for CPU or block I/O weight, with its conversion from cgroup v1 values.
+type entry struct {
+	name    string        // name of the entry
+	convert func(int) int // conversion from the configured value
+}
+
+// hierarchy is the layout of the cgroup hierarchies we throttle in.
+type hierarchy struct {
+	cpuDir   string  // cpu (v1) or unified (v2) hierarchy mount point
+	cpu      entry   // CPU weight entry
+	blkioDir string  // blkio (v1) or unified (v2) hierarchy mount point
+	blkio    []entry // block I/O weight entries, in order of preference
+}
+
+// saved is the original CPU and block I/O settings of a throttled cgroup.
+type saved struct {
+	dir         string // cgroup directory, relative to the hierarchy roots
+	cpuPath     string // CPU weight entry
+	cpuWeight   string // original CPU weight
+	blkioPath   string // block I/O weight entry in use, if any
+	blkioWeight string // original block I/O weight
+}
+
+// starting is a pod which has not started any of its containers yet.
+type starting struct {
+	cgroup *saved      // original settings of the pod cgroup, if throttled
+	timer  *time.Timer // timer for giving up waiting for a container to start
+}
+
+// throttle encapsulates the runtime state of our startup throttling controller.
+type throttle struct {
+	sync.Mutex
+	cache   cache.Cache          // resource manager cache
+	root    string               // cgroup mount point
+	cgroups *hierarchy           // cgroup hierarchy layout
+	pulls   map[string]int       // image pulls in progress, by image
+	pods    map[string]*starting // pods starting up, by pod ID
+	runtime *saved               // original settings of the runtime cgroup, if throttled
+}
+
+// Our logger instance.
+var log logger.Logger = logger.NewLogger(ThrottleController)
+
+// Our singleton startup throttling controller instance.
+var singleton *throttle
+
+// getThrottleController returns our singleton startup throttling controller instance.
+func getThrottleController() control.Controller {
+	if singleton == nil {
+		singleton = &throttle{
+			root:  "/sys/fs/cgroup",
+			pulls: make(map[string]int),
+			pods:  make(map[string]*starting),
+		}
+	}
+	return singleton
+}
+
+// Start initializes the controller for enforcing decisions.
+func (ctl *throttle) Start(cache cache.Cache, client client.Client) error {
+	if err := control.RequireLinux(ThrottleController); err != nil {
+		return err
+	}
+
+	hierarchy, err := detectHierarchy(ctl.root)
+	if err != nil {
+		return err
+	}
+
+	ctl.cache = cache
+	ctl.cgroups = hierarchy
+	return nil
+}
+
+// detectHierarchy returns the layout of the cgroup v1 or v2 hierarchies under root.
+func detectHierarchy(root string) (*hierarchy, error) {
+	v1 := &hierarchy{
+		cpuDir:   filepath.Join(root, "cpu"),
+		cpu:      entry{name: "cpu.shares", convert: identity},
+		blkioDir: filepath.Join(root, "blkio"),
+		blkio: []entry{
+			{name: "blkio.weight", convert: identity},
+			{name: "blkio.bfq.weight", convert: identity},
+		},
+	}
+	if cgroups.IsFile(filepath.Join(v1.cpuDir, v1.cpu.name)) {
+		return v1, nil
+	}
+
+	for _, dir := range []string{root, cgroups.V2path} {
+		if !cgroups.IsFile(filepath.Join(dir, "cgroup.controllers")) {
+			continue
+		}
+		return &hierarchy{
+			cpuDir:   dir,
+			cpu:      entry{name: "cpu.weight", convert: sharesToWeight},
+			blkioDir: dir,
+			blkio: []entry{
+				{name: "io.bfq.weight", convert: identity},
+				{name: "io.weight", convert: blkioToIOWeight},
+			},
+		}, nil
+	}
+
+	return nil, throttleError("neither cgroup v1 cpu controller nor cgroup v2 found")
+}
+
+// identity returns a configured value as such.
+func identity(value int) int {
+	return value
+}
+
+// sharesToWeight converts cgroup v1 CPU shares (2 - 262144) to cgroup v2 CPU weight (1 - 10000).
+func sharesToWeight(shares int) int {
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// blkioToIOWeight converts cgroup v1 block I/O weight (10 - 1000) to cgroup v2 I/O weight (1 - 10000).
+func blkioToIOWeight(weight int) int {
+	return 1 + ((weight-10)*9999)/990
+}
+
+// Stop shuts down the controller, restoring everything we have throttled.
+func (ctl *throttle) Stop() {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	for id := range ctl.pods {
+		ctl.podStarted(id)
+	}
+	ctl.pulls = make(map[string]int)
+	ctl.updateRuntime()
+}
+
+// PreCreateHook is the startup throttling controller pre-create hook.
+func (ctl *throttle) PreCreateHook(c cache.Container) error {
+	return nil
+}
+
+// PreStartHook is the startup throttling controller pre-start hook.
+func (ctl *throttle) PreStartHook(c cache.Container) error {
+	return nil
+}
+
+// PostStartHook ends the startup phase of the pod of the container.
+func (ctl *throttle) PostStartHook(c cache.Container) error {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	if _, ok := ctl.pods[c.GetPodID()]; !ok {
+		return nil
+	}
+
+	log.Debug("%s started, ending startup phase of its pod", c.PrettyName())
+	ctl.podStarted(c.GetPodID())
+	ctl.updateRuntime()
+
+	return nil
+}
+
+// PostUpdateHook is the startup throttling controller post-update hook.
+func (ctl *throttle) PostUpdateHook(c cache.Container) error {
+	return nil
+}
+
+// PostStopHook is the startup throttling controller post-stop hook.
+func (ctl *throttle) PostStopHook(c cache.Container) error {
+	return nil
+}
+
+// PostRunPodHook starts the startup phase of a newly created pod.
+func (ctl *throttle) PostRunPodHook(pod cache.Pod) error {
+	if !opt.Sandbox {
+		return nil
+	}
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	id := pod.GetID()
+	if _, ok := ctl.pods[id]; ok {
+		return nil
+	}
+
+	s := &starting{}
+	if dir := pod.GetCgroupParentDir(); dir != "" {
+		cgroup, err := ctl.lower(cgroups.ExpandCgroupParent(dir))
+		if err != nil {
+			log.Warn("failed to throttle pod %s: %v", pod.GetName(), err)
+		} else {
+			log.Info("pod %s throttled until one of its containers starts", pod.GetName())
+			s.cgroup = cgroup
+		}
+	}
+	if timeout := opt.startupTimeout(); timeout > 0 {
+		s.timer = time.AfterFunc(timeout, func() {
+			ctl.Lock()
+			defer ctl.Unlock()
+			if _, ok := ctl.pods[id]; ok {
+				log.Warn("pod %s: no container started in %v, ending startup phase", id, timeout)
+				ctl.podStarted(id)
+				ctl.updateRuntime()
+			}
+		})
+	}
+	ctl.pods[id] = s
+	ctl.updateRuntime()
+
+	return nil
+}
+
+// PreImagePullHook starts the image pull phase.
+func (ctl *throttle) PreImagePullHook(image string) error {
+	if !opt.ImagePull {
+		return nil
+	}
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	ctl.pulls[image]++
+	ctl.updateRuntime()
+
+	return nil
+}
+
+// PostImagePullHook ends the image pull phase.
+//
+// Only pulls we have seen starting are counted, so a pull started before
+// ImagePull got enabled does not end the phase of another one in progress.
+func (ctl *throttle) PostImagePullHook(image string) error {
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	cnt, ok := ctl.pulls[image]
+	if !ok {
+		return nil
+	}
+	if cnt > 1 {
+		ctl.pulls[image] = cnt - 1
+	} else {
+		delete(ctl.pulls, image)
+	}
+	ctl.updateRuntime()
+
+	return nil
+}
+
+// pullCount returns the number of image pulls in progress.
+func (ctl *throttle) pullCount() int {
+	cnt := 0
+	for _, n := range ctl.pulls {
+		cnt += n
+	}
+	return cnt
+}
+
+// podStarted ends the startup phase of the given pod, restoring its cgroup if necessary.
+func (ctl *throttle) podStarted(id string) {
+	s, ok := ctl.pods[id]
+	if !ok {
+		return
+	}
+	delete(ctl.pods, id)
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.cgroup != nil {
+		if err := restore(s.cgroup); err != nil {
+			log.Warn("failed to restore cgroup %s: %v", s.cgroup.dir, err)
+		}
+	}
+}
+
+// updateRuntime throttles the runtime while pods are starting up or images are pulled.
+func (ctl *throttle) updateRuntime() {
+	busy := len(ctl.pulls) > 0 || len(ctl.pods) > 0
+
+	switch {
+	case busy && ctl.runtime == nil && opt.RuntimeCgroup != "":
+		runtime, err := ctl.lower(opt.RuntimeCgroup)
+		if err != nil {
+			log.Warn("failed to throttle runtime: %v", err)
+			return
+		}
+		log.Info("runtime throttled (%d image pulls, %d pods starting up)",
+			ctl.pullCount(), len(ctl.pods))
+		ctl.runtime = runtime
+
+	case !busy && ctl.runtime != nil:
+		if err := restore(ctl.runtime); err != nil {
+			log.Warn("failed to restore runtime: %v", err)
+		}
+		log.Info("runtime unthrottled")
+		ctl.runtime = nil
+	}
+}
+
+// lower sets the CPU and block I/O weight of the cgroup to the throttled values.
+func (ctl *throttle) lower(dir string) (*saved, error) {
+	if ctl.cgroups == nil {
+		return nil, throttleError("cgroup hierarchy not detected")
+	}
+
+	h := ctl.cgroups
+	s := &saved{dir: dir}
+
+	cpu := filepath.Join(h.cpuDir, dir)
+	value, err := readEntry(cpu, h.cpu.name)
+	if err != nil {
+		return nil, err
+	}
+	s.cpuPath = filepath.Join(cpu, h.cpu.name)
+	s.cpuWeight = value
+
+	blkio := filepath.Join(h.blkioDir, dir)
+	var weight entry
+	for _, e := range h.blkio {
+		if value, err := readEntry(blkio, e.name); err == nil {
+			weight = e
+			s.blkioPath = filepath.Join(blkio, e.name)
+			s.blkioWeight = value
+			break
+		}
+	}
+
+	if err := writeFile(s.cpuPath, strconv.Itoa(h.cpu.convert(opt.CPUShares))); err != nil {
+		return nil, err
+	}
+	if s.blkioPath != "" && opt.BlkioWeight > 0 {
+		if err := writeFile(s.blkioPath, strconv.Itoa(weight.convert(opt.BlkioWeight))); err != nil {
+			writeFile(s.cpuPath, s.cpuWeight)
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// restore restores the original CPU and block I/O weight of a throttled cgroup.
+func restore(s *saved) error {
+	err := writeFile(s.cpuPath, s.cpuWeight)
+	if s.blkioPath != "" && opt.BlkioWeight > 0 {
+		if berr := writeFile(s.blkioPath, s.blkioWeight); err == nil {
+			err = berr
+		}
+	}
+	// a cgroup gone in the meantime is not an error
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// readEntry reads the value of a cgroup entry.
+func readEntry(dir, entry string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, entry))
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(data))
+	// *.bfq.weight and io.weight read as 'default <weight>' with optional per-device weights
+	if strings.HasPrefix(value, "default ") {
+		value = strings.Fields(value)[1]
+	}
+	return value, nil
+}
+
+// writeFile writes the value of a cgroup entry.
+func writeFile(path, value string) error {
+	return ioutil.WriteFile(path, []byte(value), 0644)
+}
+
+// configNotify is our runtime configuration notification callback.
+func (ctl *throttle) configNotify(event config.Event, source config.Source) error {
+	log.Info("configuration updated")
+
+	ctl.Lock()
+	defer ctl.Unlock()
+
+	if !opt.Sandbox {
+		for id := range ctl.pods {
+			ctl.podStarted(id)
+		}
+	}
+	if !opt.ImagePull {
+		ctl.pulls = make(map[string]int)
+	}
+	if ctl.runtime != nil && ctl.runtime.dir != opt.RuntimeCgroup {
+		if err := restore(ctl.runtime); err != nil {
+			log.Warn("failed to restore runtime: %v", err)
+		}
+		ctl.runtime = nil
+	}
+	ctl.updateRuntime()
+
+	return nil
+}
+
+// throttleError returns a controller-specific formatted error.
+func throttleError(format string, args ...interface{}) error {
+	return fmt.Errorf("throttle: "+format, args...)
+}
+
+// init registers this controller.
+func init() {
+	control.Register(ThrottleController, "startup throttling controller", getThrottleController())
+}
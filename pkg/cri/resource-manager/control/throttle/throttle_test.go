@@ -0,0 +1,220 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package throttle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+)
+
+// setupCgroups creates a fake cgroup v1 or v2 hierarchy with a runtime cgroup.
+func setupCgroups(t *testing.T, v2 bool) string {
+	root, err := ioutil.TempDir("", "throttle-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+
+	files := map[string]string{
+		"cpu/cpu.shares":                 "1024",
+		"cpu/runtime/cpu.shares":         "1024",
+		"blkio/runtime/blkio.bfq.weight": "default 100",
+	}
+	if v2 {
+		files = map[string]string{
+			"cgroup.controllers": "cpu io memory",
+			"runtime/cpu.weight": "100",
+			"runtime/io.weight":  "default 100",
+		}
+	}
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+
+	return root
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// newTestThrottle returns a throttle controller for the fake hierarchy at root.
+func newTestThrottle(t *testing.T, root string) *throttle {
+	h, err := detectHierarchy(root)
+	if err != nil {
+		t.Fatalf("failed to detect cgroup hierarchy: %v", err)
+	}
+	return &throttle{
+		root:    root,
+		cgroups: h,
+		pulls:   make(map[string]int),
+		pods:    make(map[string]*starting),
+	}
+}
+
+// setOptions overrides the runtime configuration, returning a function to restore it.
+func setOptions(o options) func() {
+	saved := *opt
+	*opt = o
+	return func() { *opt = saved }
+}
+
+func TestThrottleRuntime(t *testing.T) {
+	defer setOptions(options{
+		ImagePull:     true,
+		RuntimeCgroup: "runtime",
+		CPUShares:     2,
+		BlkioWeight:   10,
+	})()
+
+	tcases := []struct {
+		name      string
+		v2        bool
+		cpu       string
+		blkio     string
+		lowCPU    string
+		lowBlkio  string
+		origCPU   string
+		origBlkio string
+	}{
+		{
+			name:      "cgroup v1",
+			cpu:       "cpu/runtime/cpu.shares",
+			blkio:     "blkio/runtime/blkio.bfq.weight",
+			lowCPU:    "2",
+			lowBlkio:  "10",
+			origCPU:   "1024",
+			origBlkio: "100",
+		},
+		{
+			name:      "cgroup v2",
+			v2:        true,
+			cpu:       "runtime/cpu.weight",
+			blkio:     "runtime/io.weight",
+			lowCPU:    "1",
+			lowBlkio:  "1",
+			origCPU:   "100",
+			origBlkio: "100",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := setupCgroups(t, tc.v2)
+			defer os.RemoveAll(root)
+			ctl := newTestThrottle(t, root)
+			cpu, blkio := filepath.Join(root, tc.cpu), filepath.Join(root, tc.blkio)
+
+			ctl.PreImagePullHook("image-a")
+			ctl.PreImagePullHook("image-b")
+			if got := readFile(t, cpu); got != tc.lowCPU {
+				t.Errorf("expected throttled CPU weight %s, got %s", tc.lowCPU, got)
+			}
+			if got := readFile(t, blkio); got != tc.lowBlkio {
+				t.Errorf("expected throttled block I/O weight %s, got %s", tc.lowBlkio, got)
+			}
+
+			ctl.PostImagePullHook("image-a")
+			if got := readFile(t, cpu); got != tc.lowCPU {
+				t.Errorf("unthrottled with an image pull in progress")
+			}
+
+			ctl.PostImagePullHook("image-b")
+			if got := readFile(t, cpu); got != tc.origCPU {
+				t.Errorf("expected restored CPU weight %s, got %s", tc.origCPU, got)
+			}
+			if got := readFile(t, blkio); got != tc.origBlkio {
+				t.Errorf("expected restored block I/O weight %s, got %s", tc.origBlkio, got)
+			}
+		})
+	}
+}
+
+func TestUncountedImagePull(t *testing.T) {
+	restoreOptions := setOptions(options{
+		ImagePull:     false,
+		RuntimeCgroup: "runtime",
+		CPUShares:     2,
+	})
+	defer restoreOptions()
+
+	root := setupCgroups(t, false)
+	defer os.RemoveAll(root)
+	ctl := newTestThrottle(t, root)
+	cpu := filepath.Join(root, "cpu/runtime/cpu.shares")
+
+	// a pull started while throttling image pulls is disabled
+	ctl.PreImagePullHook("image-a")
+	opt.ImagePull = true
+	ctl.PreImagePullHook("image-b")
+
+	ctl.PostImagePullHook("image-a")
+	if ctl.pullCount() != 1 {
+		t.Errorf("expected 1 image pull in progress, got %d", ctl.pullCount())
+	}
+	if got := readFile(t, cpu); got != "2" {
+		t.Errorf("unthrottled by the end of an uncounted image pull")
+	}
+
+	ctl.PostImagePullHook("image-b")
+	ctl.PostImagePullHook("image-b")
+	if ctl.pullCount() != 0 {
+		t.Errorf("expected no image pulls in progress, got %d", ctl.pullCount())
+	}
+	if got := readFile(t, cpu); got != "1024" {
+		t.Errorf("expected restored CPU shares 1024, got %s", got)
+	}
+}
+
+func TestDetectHierarchy(t *testing.T) {
+	root, err := ioutil.TempDir("", "throttle-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	v2path := cgroups.V2path
+	defer func() { cgroups.V2path = v2path }()
+	cgroups.V2path = filepath.Join(root, "unified")
+
+	if _, err := detectHierarchy(root); err == nil {
+		t.Errorf("expected an error for an empty cgroup mount point")
+	}
+}
+
+func TestWeightConversion(t *testing.T) {
+	for shares, weight := range map[int]int{2: 1, 1024: 39, 262144: 10000} {
+		if got := sharesToWeight(shares); got != weight {
+			t.Errorf("sharesToWeight(%d): expected %d, got %d", shares, weight, got)
+		}
+	}
+	for blkio, weight := range map[int]int{10: 1, 1000: 10000} {
+		if got := blkioToIOWeight(blkio); got != weight {
+			t.Errorf("blkioToIOWeight(%d): expected %d, got %d", blkio, weight, got)
+		}
+	}
+}
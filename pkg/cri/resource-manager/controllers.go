@@ -17,6 +17,18 @@ package resmgr
 import (
 	// List of controllers to pull in.
 	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/blockio"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/coresched"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/cpu"
 	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/cri"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/devices"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/irq"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/memory"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/network"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/numabalancing"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/oom"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/pod"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/power"
 	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/rdt"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/sched"
+	_ "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control/throttle"
 )
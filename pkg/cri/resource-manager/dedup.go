@@ -0,0 +1,119 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"strconv"
+
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// podRequest is a pod sandbox creation request in progress.
+type podRequest struct {
+	done  chan struct{} // closed once the request is finished
+	reply interface{}   // reply to the request
+	err   error         // error of the request
+}
+
+// podRequests are the pod sandbox creation requests in progress, by pod UID and attempt.
+type podRequests map[string]*podRequest
+
+// podRequestKey returns the key of a pod sandbox creation request.
+func podRequestKey(request *criapi.RunPodSandboxRequest) (string, string, uint32) {
+	meta := request.GetConfig().GetMetadata()
+	uid, attempt := meta.GetUid(), meta.GetAttempt()
+	return uid + "/" + strconv.FormatUint(uint64(attempt), 10), uid, attempt
+}
+
+// lookupRetriedPod checks if a pod sandbox creation request is a retry.
+//
+// Kubelet retries RunPodSandbox after a timeout with the same pod UID and
+// attempt. If the sandbox has been created already, the retry is answered with
+// the existing sandbox. If the original request is still in progress, the retry
+// waits for it and is answered with the same reply. Must be called with m locked.
+func (m *resmgr) lookupRetriedPod(ctx context.Context,
+	request *criapi.RunPodSandboxRequest) (interface{}, bool, error) {
+	key, uid, attempt := podRequestKey(request)
+	if uid == "" {
+		return nil, false, nil
+	}
+
+	if pod, ok := m.cache.LookupPodByUID(uid, attempt); ok && pod.GetState() == cache.PodStateReady {
+		m.Warn("reusing pod sandbox %s (%s) for retried request", pod.GetName(), pod.GetID())
+		return &criapi.RunPodSandboxResponse{PodSandboxId: pod.GetID()}, true, nil
+	}
+
+	r, ok := m.runPods[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	m.Warn("waiting for pod sandbox %s in progress for retried request",
+		request.GetConfig().GetMetadata().GetName())
+
+	m.Unlock()
+	defer m.Lock()
+
+	select {
+	case <-r.done:
+		return r.reply, true, r.err
+	case <-ctx.Done():
+		return nil, true, ctx.Err()
+	}
+}
+
+// startPodRequest registers a pod sandbox creation request in progress.
+func (m *resmgr) startPodRequest(request *criapi.RunPodSandboxRequest) *podRequest {
+	key, uid, _ := podRequestKey(request)
+	if uid == "" {
+		return nil
+	}
+	if m.runPods == nil {
+		m.runPods = make(podRequests)
+	}
+	r := &podRequest{done: make(chan struct{})}
+	m.runPods[key] = r
+	return r
+}
+
+// finishPodRequest unregisters a finished pod sandbox creation request.
+func (m *resmgr) finishPodRequest(request *criapi.RunPodSandboxRequest, r *podRequest,
+	reply interface{}, err error) {
+	if r == nil {
+		return
+	}
+	key, _, _ := podRequestKey(request)
+	delete(m.runPods, key)
+	r.reply, r.err = reply, err
+	close(r.done)
+}
+
+// lookupRetriedContainer checks if a container creation request is a retry.
+//
+// Kubelet retries CreateContainer after a timeout with the same pod sandbox,
+// container name and attempt. If the container has been created already, the
+// retry is answered with the existing container instead of allocating it a
+// second time.
+func (m *resmgr) lookupRetriedContainer(request *criapi.CreateContainerRequest) (cache.Container, bool) {
+	meta := request.GetConfig().GetMetadata()
+	c, ok := m.cache.LookupContainerByName(request.PodSandboxId, meta.GetName(), int(meta.GetAttempt()))
+	if !ok || c.GetState() != cache.ContainerStateCreated || c.GetID() == "" {
+		return nil, false
+	}
+	return c, true
+}
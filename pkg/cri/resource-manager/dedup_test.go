@@ -0,0 +1,196 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// newDedupTestResmgr creates a resource manager with an empty temporary cache.
+func newDedupTestResmgr(t *testing.T) (*resmgr, func()) {
+	dir, err := ioutil.TempDir("", "dedup-test")
+	if err != nil {
+		t.Fatalf("failed to create cache directory: %v", err)
+	}
+	cch, err := cache.NewCache(cache.Options{CacheDir: dir})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	m := &resmgr{
+		Logger: logger.NewLogger("dedup-test"),
+		cache:  cch,
+	}
+	return m, func() { os.RemoveAll(dir) }
+}
+
+// runPodRequest returns a pod sandbox creation request for the given attempt.
+func runPodRequest(attempt uint32) *criapi.RunPodSandboxRequest {
+	return &criapi.RunPodSandboxRequest{
+		Config: &criapi.PodSandboxConfig{
+			Metadata: &criapi.PodSandboxMetadata{
+				Name:      "pod",
+				Uid:       "pod-uid",
+				Namespace: "default",
+				Attempt:   attempt,
+			},
+		},
+	}
+}
+
+func TestRetriedPodCreated(t *testing.T) {
+	m, cleanup := newDedupTestResmgr(t)
+	defer cleanup()
+
+	m.cache.InsertPod("pod-id", runPodRequest(0))
+
+	m.Lock()
+	defer m.Unlock()
+
+	reply, retried, err := m.lookupRetriedPod(context.Background(), runPodRequest(0))
+	if !retried || err != nil {
+		t.Fatalf("expected retried request to be answered, got retried %v, error %v", retried, err)
+	}
+	if id := reply.(*criapi.RunPodSandboxResponse).PodSandboxId; id != "pod-id" {
+		t.Errorf("expected existing pod sandbox %q, got %q", "pod-id", id)
+	}
+
+	if _, retried, _ := m.lookupRetriedPod(context.Background(), runPodRequest(1)); retried {
+		t.Errorf("expected request with a new attempt not to be a retry")
+	}
+}
+
+func TestRetriedPodInProgress(t *testing.T) {
+	m, cleanup := newDedupTestResmgr(t)
+	defer cleanup()
+
+	request := runPodRequest(0)
+	expected := &criapi.RunPodSandboxResponse{PodSandboxId: "pod-id"}
+
+	m.Lock()
+	defer m.Unlock()
+
+	inflight := m.startPodRequest(request)
+
+	// the retry releases the lock while it waits for the original request
+	go func() {
+		m.Lock()
+		defer m.Unlock()
+		m.finishPodRequest(request, inflight, expected, nil)
+	}()
+
+	reply, retried, err := m.lookupRetriedPod(context.Background(), runPodRequest(0))
+	if !retried || err != nil {
+		t.Fatalf("expected retried request to be answered, got retried %v, error %v", retried, err)
+	}
+	if reply != expected {
+		t.Errorf("expected reply of the original request, got %v", reply)
+	}
+	if len(m.runPods) != 0 {
+		t.Errorf("expected no requests in progress, got %d", len(m.runPods))
+	}
+}
+
+func TestRetriedPodCancelled(t *testing.T) {
+	m, cleanup := newDedupTestResmgr(t)
+	defer cleanup()
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.startPodRequest(runPodRequest(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, retried, err := m.lookupRetriedPod(ctx, runPodRequest(0))
+	if !retried || err != context.Canceled {
+		t.Errorf("expected cancelled retry, got retried %v, error %v", retried, err)
+	}
+}
+
+func TestRetriedPodWithoutUID(t *testing.T) {
+	m, cleanup := newDedupTestResmgr(t)
+	defer cleanup()
+
+	request := &criapi.RunPodSandboxRequest{Config: &criapi.PodSandboxConfig{}}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if r := m.startPodRequest(request); r != nil {
+		t.Errorf("expected request without UID not to be tracked")
+	}
+	if _, retried, _ := m.lookupRetriedPod(context.Background(), request); retried {
+		t.Errorf("expected request without UID not to be a retry")
+	}
+}
+
+func TestRetriedContainer(t *testing.T) {
+	m, cleanup := newDedupTestResmgr(t)
+	defer cleanup()
+
+	pod := runPodRequest(0)
+	m.cache.InsertPod("pod-id", pod)
+
+	request := &criapi.CreateContainerRequest{
+		PodSandboxId: "pod-id",
+		Config: &criapi.ContainerConfig{
+			Metadata: &criapi.ContainerMetadata{Name: "ctr"},
+		},
+		SandboxConfig: pod.Config,
+	}
+
+	if _, ok := m.lookupRetriedContainer(request); ok {
+		t.Fatalf("expected first request not to be a retry")
+	}
+
+	c, err := m.cache.InsertContainer(request)
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+	if _, ok := m.lookupRetriedContainer(request); ok {
+		t.Errorf("expected container still being created not to be reused")
+	}
+
+	if _, err := m.cache.UpdateContainerID(c.GetCacheID(),
+		&criapi.CreateContainerResponse{ContainerId: "ctr-id"}); err != nil {
+		t.Fatalf("failed to set container ID: %v", err)
+	}
+	c.UpdateState(cache.ContainerStateCreated)
+
+	if found, ok := m.lookupRetriedContainer(request); !ok || found.GetID() != "ctr-id" {
+		t.Errorf("expected created container to be reused for retried request")
+	}
+
+	request.Config.Metadata.Attempt = 1
+	if _, ok := m.lookupRetriedContainer(request); ok {
+		t.Errorf("expected restarted container not to be a retry")
+	}
+
+	request.Config.Metadata.Attempt = 0
+	c.UpdateState(cache.ContainerStateRunning)
+	if _, ok := m.lookupRetriedContainer(request); ok {
+		t.Errorf("expected running container not to be reused")
+	}
+}
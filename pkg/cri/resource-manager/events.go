@@ -15,8 +15,12 @@
 package resmgr
 
 import (
+	"os"
+	"os/signal"
+	"strconv"
 	"time"
 
+	"github.com/intel/cri-resource-manager/pkg/cri/relay"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/metrics"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
@@ -48,9 +52,45 @@ func (m *resmgr) startEventProcessing() error {
 		return resmgrError("failed to start metrics (pre)processor: %v", err)
 	}
 
+	m.startReconciling()
+
 	stop := m.stop
 	go func() {
 		rebalanceTimer := time.NewTicker(opt.RebalanceTimer)
+		var janitorC <-chan time.Time
+		if opt.JanitorInterval > 0 {
+			janitorTimer := time.NewTicker(opt.JanitorInterval)
+			defer janitorTimer.Stop()
+			janitorC = janitorTimer.C
+		}
+		var isolationC <-chan time.Time
+		if opt.IsolationInterval > 0 {
+			isolationTimer := time.NewTicker(opt.IsolationInterval)
+			defer isolationTimer.Stop()
+			isolationC = isolationTimer.C
+		}
+		var consistencyC <-chan time.Time
+		if opt.ConsistencyInterval > 0 {
+			consistencyTimer := time.NewTicker(opt.ConsistencyInterval)
+			defer consistencyTimer.Stop()
+			consistencyC = consistencyTimer.C
+		}
+		var statsC <-chan time.Time
+		if opt.StatsInterval > 0 {
+			statsTimer := time.NewTicker(opt.StatsInterval)
+			defer statsTimer.Stop()
+			statsC = statsTimer.C
+		}
+		var statusC <-chan time.Time
+		if opt.NodeStatusInterval > 0 {
+			m.exportNodeStatus()
+			statusTimer := time.NewTicker(opt.NodeStatusInterval)
+			defer statusTimer.Stop()
+			statusC = statusTimer.C
+		}
+		dumpC := make(chan os.Signal, 1)
+		notifyDumpSignal(dumpC)
+		defer signal.Stop(dumpC)
 		for {
 			select {
 			case _ = <-stop:
@@ -58,9 +98,19 @@ func (m *resmgr) startEventProcessing() error {
 			case event := <-m.events:
 				m.processEvent(event)
 			case _ = <-rebalanceTimer.C:
-				if err := m.RebalanceContainers(); err != nil {
-					evtlog.Error("rebalancing failed: %v", err)
-				}
+				m.requestRebalance("periodic")
+			case _ = <-janitorC:
+				m.runJanitor(opt.JanitorDryRun)
+			case _ = <-isolationC:
+				m.runIsolation(true)
+			case _ = <-consistencyC:
+				m.checkConsistency(opt.ConsistencyRepair)
+			case _ = <-statsC:
+				m.sampleStats()
+			case _ = <-statusC:
+				m.exportNodeStatus()
+			case _ = <-dumpC:
+				m.dumpPolicyState()
 			}
 		}
 	}()
@@ -71,6 +121,7 @@ func (m *resmgr) startEventProcessing() error {
 // stopEventProcessing stops event and metrics processing.
 func (m *resmgr) stopEventProcessing() {
 	close(m.stop)
+	m.stopRebalancing()
 	m.metrics.Stop()
 }
 
@@ -97,13 +148,40 @@ func (m *resmgr) processEvent(e interface{}) {
 	switch event := e.(type) {
 	case string:
 		evtlog.Debug("'%s'...", event)
+	case *relay.HealthEvent:
+		m.processRuntimeHealth(event)
 	case *metrics.Event:
 		m.processAvx(event.Avx)
+		m.processRdt(event.Rdt)
+		m.processPerf(event.Perf)
+		m.processPressure()
+		m.processBurst()
+		m.updateOOMKills()
+		if m.policy != nil {
+			m.policy.PollMetrics()
+		}
 	default:
 		evtlog.Warn("event of unexpected type %T...", e)
 	}
 }
 
+// processRuntimeHealth processes runtime connection health events.
+//
+// Once the runtime comes back, containers might have been created, removed or
+// changed behind our back, for instance by a restarted runtime, so we check the
+// consistency of our cache, repairing it if configured so.
+func (m *resmgr) processRuntimeHealth(e *relay.HealthEvent) {
+	m.setReady(readyRuntime, e.Healthy)
+
+	if !e.Healthy {
+		evtlog.Warn("runtime down since %s: %v", e.Since.Format(time.RFC3339), e.Error)
+		return
+	}
+
+	evtlog.Info("runtime back after %v, checking cache consistency", time.Since(e.Since))
+	go m.checkConsistency(opt.ConsistencyRepair)
+}
+
 // processAvx processes AVX512 events.
 func (m *resmgr) processAvx(e *metrics.AvxEvent) bool {
 	if e == nil {
@@ -131,6 +209,26 @@ func (m *resmgr) processAvx(e *metrics.AvxEvent) bool {
 	return changes
 }
 
+// processRdt processes RDT class resource usage events.
+func (m *resmgr) processRdt(e *metrics.RdtEvent) {
+	if e == nil {
+		return
+	}
+
+	for _, c := range m.cache.GetContainers() {
+		class := c.GetRDTClass()
+		if class == "" {
+			class = string(c.GetQOSClass())
+		}
+		usage, ok := e.Classes[class]
+		if !ok {
+			continue
+		}
+		c.SetTag(cache.TagRDTLLCOccupancy, strconv.FormatUint(usage.LLCOccupancy, 10))
+		c.SetTag(cache.TagRDTMemBandwidth, strconv.FormatUint(usage.MemBandwidth, 10))
+	}
+}
+
 // resolveCgroupPath resolves a cgroup path to a container.
 func (m *resmgr) resolveCgroupPath(path string) (cache.Container, bool) {
 	return m.cache.LookupContainerByCgroup(path)
@@ -0,0 +1,130 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"strconv"
+
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/utils"
+)
+
+const (
+	// tagOOMKillsBase tags containers with the OOM kills of their previous instances.
+	tagOOMKillsBase = "oom-kills/previous"
+)
+
+// getOOMKills returns the number of OOM kills in the cgroup of a container.
+var getOOMKills = utils.GetContainerOOMKills
+
+// trackRestart carries exit and OOM kill statistics over to a restarted container.
+//
+// A restarted container is a new container with the same name in the same pod.
+// We look up the previous instance of the container in the cache, and ask the
+// runtime how it exited. Its OOM kills are accumulated to the new instance.
+func (m *resmgr) trackRestart(ctx context.Context, c cache.Container) {
+	prev := m.previousInstance(c)
+	if prev == nil {
+		return
+	}
+
+	status, err := m.relay.Client().ContainerStatus(ctx,
+		&criapi.ContainerStatusRequest{ContainerId: prev.GetID()})
+	if err != nil || status.GetStatus() == nil {
+		m.Debug("failed to query exit status of previous instance of %s: %v",
+			c.PrettyName(), err)
+	}
+
+	m.carryOverExit(c, prev, status.GetStatus())
+}
+
+// previousInstance returns the previous instance of a restarted container, if any.
+func (m *resmgr) previousInstance(c cache.Container) cache.Container {
+	if c.GetRestartCount() == 0 {
+		return nil
+	}
+
+	var prev cache.Container
+	for _, o := range m.cache.GetContainers() {
+		if o.GetPodID() != c.GetPodID() || o.GetName() != c.GetName() {
+			continue
+		}
+		if o.GetCacheID() == c.GetCacheID() || o.GetID() == "" {
+			continue
+		}
+		if o.GetRestartCount() >= c.GetRestartCount() {
+			continue
+		}
+		if prev == nil || o.GetRestartCount() > prev.GetRestartCount() {
+			prev = o
+		}
+	}
+	return prev
+}
+
+// carryOverExit carries the OOM kills and the exit status of the previous instance over.
+func (m *resmgr) carryOverExit(c, prev cache.Container, status *criapi.ContainerStatus) {
+	c.SetOOMKills(prev.GetOOMKills())
+	c.SetTag(tagOOMKillsBase, strconv.Itoa(c.GetOOMKills()))
+
+	if status == nil {
+		return
+	}
+
+	exit := &cache.ContainerExit{
+		Reason:     status.Reason,
+		ExitCode:   status.ExitCode,
+		FinishedAt: status.FinishedAt,
+	}
+	if exit.Reason == cache.ContainerExitOOMKilled && prev.GetOOMKills() == 0 {
+		c.SetOOMKills(1)
+	}
+	c.SetLastExit(exit)
+	c.SetTag(tagOOMKillsBase, strconv.Itoa(c.GetOOMKills()))
+
+	m.Info("%s: restart #%d, previous instance exited with code %d (%s), %d OOM kills",
+		c.PrettyName(), c.GetRestartCount(), exit.ExitCode, exit.Reason, c.GetOOMKills())
+}
+
+// updateOOMKills updates the OOM kill counts of running containers from their cgroups.
+func (m *resmgr) updateOOMKills() {
+	for _, c := range m.cache.GetContainers() {
+		if c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		kills, err := getOOMKills(c.GetID())
+		if err != nil {
+			m.Debug("%s: failed to get OOM kills: %v", c.PrettyName(), err)
+			continue
+		}
+		if kills == 0 {
+			continue
+		}
+
+		// the cgroup counts OOM kills of this instance only
+		base := 0
+		if value, ok := c.GetTag(tagOOMKillsBase); ok {
+			base, _ = strconv.Atoi(value)
+		}
+		total := base + kills
+		if total > c.GetOOMKills() {
+			m.Warn("%s: %d processes OOM killed", c.PrettyName(), total-c.GetOOMKills())
+			c.SetOOMKills(total)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// newExitsTestResmgr creates a resource manager with a pod in a temporary cache.
+func newExitsTestResmgr(t *testing.T) (*resmgr, *criapi.PodSandboxConfig, func()) {
+	dir, err := ioutil.TempDir("", "exits-test")
+	if err != nil {
+		t.Fatalf("failed to create cache directory: %v", err)
+	}
+	cch, err := cache.NewCache(cache.Options{CacheDir: dir})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	cfg := &criapi.PodSandboxConfig{
+		Metadata: &criapi.PodSandboxMetadata{
+			Name:      "pod",
+			Uid:       "pod-uid",
+			Namespace: "default",
+		},
+	}
+	cch.InsertPod("pod-id", &criapi.RunPodSandboxRequest{Config: cfg})
+
+	m := &resmgr{
+		Logger: logger.NewLogger("exits-test"),
+		cache:  cch,
+	}
+	return m, cfg, func() { os.RemoveAll(dir) }
+}
+
+// createInstance creates a running instance of the test container with the given restart count.
+func createInstance(t *testing.T, m *resmgr, cfg *criapi.PodSandboxConfig, attempt uint32) cache.Container {
+	c, err := m.cache.InsertContainer(&criapi.CreateContainerRequest{
+		PodSandboxId: "pod-id",
+		Config: &criapi.ContainerConfig{
+			Metadata: &criapi.ContainerMetadata{
+				Name:    "ctr",
+				Attempt: attempt,
+			},
+		},
+		SandboxConfig: cfg,
+	})
+	if err != nil {
+		t.Fatalf("failed to create container instance #%d: %v", attempt, err)
+	}
+	id := fmt.Sprintf("ctr-id-%d", attempt)
+	if _, err := m.cache.UpdateContainerID(c.GetCacheID(),
+		&criapi.CreateContainerResponse{ContainerId: id}); err != nil {
+		t.Fatalf("failed to set ID of container instance #%d: %v", attempt, err)
+	}
+	c.UpdateState(cache.ContainerStateRunning)
+	return c
+}
+
+func TestPreviousInstance(t *testing.T) {
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+
+	first := createInstance(t, m, cfg, 0)
+	if prev := m.previousInstance(first); prev != nil {
+		t.Errorf("expected no previous instance for %s, got %s",
+			first.PrettyName(), prev.PrettyName())
+	}
+
+	second := createInstance(t, m, cfg, 1)
+	third := createInstance(t, m, cfg, 2)
+
+	if prev := m.previousInstance(third); prev == nil || prev.GetID() != second.GetID() {
+		t.Errorf("expected previous instance %s, got %v", second.GetID(), prev)
+	}
+	if prev := m.previousInstance(second); prev == nil || prev.GetID() != first.GetID() {
+		t.Errorf("expected previous instance %s, got %v", first.GetID(), prev)
+	}
+}
+
+func TestCarryOverExit(t *testing.T) {
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+
+	prev := createInstance(t, m, cfg, 0)
+	c := createInstance(t, m, cfg, 1)
+
+	// an OOM killed instance without any OOM kills seen from its cgroup counts as one
+	m.carryOverExit(c, prev, &criapi.ContainerStatus{
+		Reason:     cache.ContainerExitOOMKilled,
+		ExitCode:   137,
+		FinishedAt: 1000,
+	})
+	if kills := c.GetOOMKills(); kills != 1 {
+		t.Errorf("expected 1 OOM kill, got %d", kills)
+	}
+	exit := c.GetLastExit()
+	if exit == nil || exit.Reason != cache.ContainerExitOOMKilled || exit.ExitCode != 137 {
+		t.Errorf("expected OOM killed last exit, got %+v", exit)
+	}
+
+	// OOM kills accumulate across restarts
+	next := createInstance(t, m, cfg, 2)
+	c.SetOOMKills(4)
+	m.carryOverExit(next, c, &criapi.ContainerStatus{Reason: "Error", ExitCode: 1})
+	if kills := next.GetOOMKills(); kills != 4 {
+		t.Errorf("expected 4 OOM kills, got %d", kills)
+	}
+	if base, _ := next.GetTag(tagOOMKillsBase); base != "4" {
+		t.Errorf("expected OOM kill base 4, got %q", base)
+	}
+
+	// without an exit status only OOM kills are carried over
+	last := createInstance(t, m, cfg, 3)
+	m.carryOverExit(last, next, nil)
+	if kills := last.GetOOMKills(); kills != 4 {
+		t.Errorf("expected 4 OOM kills, got %d", kills)
+	}
+	if exit := last.GetLastExit(); exit != nil {
+		t.Errorf("expected no last exit, got %+v", *exit)
+	}
+}
+
+func TestUpdateOOMKills(t *testing.T) {
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+
+	kills := map[string]int{}
+	saved := getOOMKills
+	defer func() { getOOMKills = saved }()
+	getOOMKills = func(id string) (int, error) {
+		if count, ok := kills[id]; ok {
+			return count, nil
+		}
+		return 0, fmt.Errorf("no cgroup for container %s", id)
+	}
+
+	c := createInstance(t, m, cfg, 1)
+	c.SetOOMKills(2)
+	c.SetTag(tagOOMKillsBase, "2")
+
+	m.updateOOMKills()
+	if count := c.GetOOMKills(); count != 2 {
+		t.Errorf("expected 2 OOM kills without a cgroup, got %d", count)
+	}
+
+	// kills in the cgroup of this instance add to the ones of earlier instances
+	kills[c.GetID()] = 3
+	m.updateOOMKills()
+	if count := c.GetOOMKills(); count != 5 {
+		t.Errorf("expected 5 OOM kills, got %d", count)
+	}
+
+	// containers not running are not updated
+	c.UpdateState(cache.ContainerStateExited)
+	kills[c.GetID()] = 10
+	m.updateOOMKills()
+	if count := c.GetOOMKills(); count != 5 {
+		t.Errorf("expected 5 OOM kills for exited container, got %d", count)
+	}
+}
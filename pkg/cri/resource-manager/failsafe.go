@@ -0,0 +1,237 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/cri/server"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+const (
+	// failurePanic is the failure reason for policy processing panics.
+	failurePanic = "panic"
+	// failureError is the failure reason for policy processing errors.
+	failureError = "error"
+)
+
+// failSafe is a circuit breaker for the policy processing of CRI requests.
+//
+// If processing intercepted requests panics or fails too many times in a row,
+// the breaker trips and the relay switches to transparent pass-through, IOW to
+// null policy semantics, until an operator re-engages policy processing.
+type failSafe struct {
+	sync.Mutex
+	failures int  // number of consecutive failures
+	tripped  bool // whether we're passing requests through
+}
+
+// rejection is an error for a request deliberately rejected, not a failure.
+type rejection struct {
+	error
+}
+
+// rejected marks the given error as a deliberate rejection of a request.
+func rejected(err error) error {
+	return &rejection{err}
+}
+
+// isTripped returns true if we're in pass-through mode.
+func (fs *failSafe) isTripped() bool {
+	fs.Lock()
+	defer fs.Unlock()
+	return fs.tripped
+}
+
+// isFailing returns true if we've had failures since the last success, without tripping.
+func (fs *failSafe) isFailing() bool {
+	fs.Lock()
+	defer fs.Unlock()
+	return !fs.tripped && fs.failures > 0
+}
+
+// success records successful policy processing of a request.
+func (fs *failSafe) success() {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.failures = 0
+}
+
+// failure records a policy processing failure, returning true if the breaker just tripped.
+func (fs *failSafe) failure(threshold int) bool {
+	fs.Lock()
+	defer fs.Unlock()
+
+	fs.failures++
+	if fs.tripped || fs.failures < threshold {
+		return false
+	}
+
+	fs.tripped = true
+	failSafeMetrics.passthrough.Set(1)
+	return true
+}
+
+// reset re-engages policy processing, returning true if we were passing requests through.
+func (fs *failSafe) reset() bool {
+	fs.Lock()
+	defer fs.Unlock()
+
+	tripped := fs.tripped
+	fs.failures = 0
+	fs.tripped = false
+	failSafeMetrics.passthrough.Set(0)
+	return tripped
+}
+
+// failSafeInterceptor wraps an interceptor with panic recovery and failure accounting.
+func (m *resmgr) failSafeInterceptor(fn server.Interceptor) server.Interceptor {
+	if opt.FailSafeThreshold <= 0 {
+		return fn
+	}
+
+	return func(ctx context.Context, method string, request interface{},
+		handler server.Handler) (reply interface{}, err error) {
+
+		if m.failsafe.isTripped() {
+			return handler(ctx, request)
+		}
+
+		// keep track of whether and how the request was relayed to the runtime
+		relayed := false
+		var rtreply interface{}
+		var rterr error
+		relay := func(ctx context.Context, request interface{}) (interface{}, error) {
+			relayed = true
+			rtreply, rterr = handler(ctx, request)
+			return rtreply, rterr
+		}
+
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			m.Error("%s: recovered from panic in request processing: %v\n%s",
+				method, r, debug.Stack())
+			m.policyFailure(method, failurePanic)
+			if relayed {
+				reply, err = rtreply, rterr
+			} else {
+				reply, err = handler(ctx, request)
+			}
+		}()
+
+		reply, err = fn(ctx, method, request, relay)
+
+		switch err.(type) {
+		case nil:
+			m.failsafe.success()
+		case *rejection:
+		default:
+			// runtime failures are not ours to account for
+			if !relayed || rterr == nil {
+				m.policyFailure(method, failureError)
+			}
+		}
+
+		return reply, err
+	}
+}
+
+// policyFailure records a policy failure and switches to pass-through if necessary.
+func (m *resmgr) policyFailure(method, reason string) {
+	failSafeMetrics.failures.WithLabelValues(method, reason).Inc()
+	if m.failsafe.failure(opt.FailSafeThreshold) {
+		m.Error("too many consecutive request processing failures, "+
+			"switching to pass-through mode (%s policy semantics)", policy.NullPolicy)
+		if opt.NodeStatusInterval > 0 {
+			m.exportNodeStatus()
+		}
+	}
+}
+
+// reengagePolicy leaves pass-through mode and resynchronizes the policy with the runtime.
+func (m *resmgr) reengagePolicy(ctx context.Context) error {
+	if !m.failsafe.reset() {
+		return nil
+	}
+
+	m.Warn("re-engaging policy processing of CRI requests...")
+	if opt.NodeStatusInterval > 0 {
+		m.exportNodeStatus()
+	}
+
+	add, del, err := m.syncWithCRI(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.policy.Sync(add, del); err != nil {
+		return resmgrError("failed to resynchronize policy: %v", err)
+	}
+
+	return m.queuePostReleaseHooks("re-engage")
+}
+
+// failSafeMetricsCollector exports the state of the fail-safe circuit breaker.
+type failSafeMetricsCollector struct {
+	passthrough prometheus.Gauge
+	failures    *prometheus.CounterVec
+}
+
+// Our fail-safe metrics collector.
+var failSafeMetrics = &failSafeMetricsCollector{
+	passthrough: prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cri_resmgr_failsafe_passthrough",
+			Help: "Whether requests are passed through without policy processing (1) or not (0).",
+		},
+	),
+	failures: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cri_resmgr_policy_failures_total",
+			Help: "Number of failed policy processing of CRI requests, by method and reason.",
+		},
+		[]string{"method", "reason"},
+	),
+}
+
+// Describe implements prometheus.Collector.
+func (c *failSafeMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.passthrough.Describe(ch)
+	c.failures.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *failSafeMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.passthrough.Collect(ch)
+	c.failures.Collect(ch)
+}
+
+func init() {
+	err := metrics.RegisterCollector("failsafe", func() (prometheus.Collector, error) {
+		return failSafeMetrics, nil
+	})
+	if err != nil {
+		logger.Get("resource-manager").Error("failed to register fail-safe metrics collector: %v", err)
+	}
+}
@@ -16,6 +16,7 @@ package resmgr
 
 import (
 	"flag"
+	"strings"
 	"time"
 
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/sockets"
@@ -34,6 +35,93 @@ type options struct {
 	ForceConfig    string
 	MetricsTimer   time.Duration
 	RebalanceTimer time.Duration
+	PodEvents      bool
+	PodCPUSetDir   string
+
+	// labeling of created containers with their placement
+	PlacementLabels bool
+
+	// export of node resource topology for topology-aware scheduling
+	NodeResourceTopology bool
+
+	// consecutive policy failures before switching to pass-through mode
+	FailSafeThreshold int
+
+	// interval for publishing our status as node labels, using the agent
+	NodeStatusInterval time.Duration
+
+	// periodic cleanup of stale resctrl groups and cgroup directories
+	JanitorInterval    time.Duration
+	JanitorDryRun      bool
+	JanitorHTTPCleanup bool
+
+	// periodic checks and enforcement of exclusive CPU isolation from host processes
+	IsolationInterval    time.Duration
+	IsolationMethod      string
+	IsolationHTTPEnforce bool
+
+	// directory policy state is dumped to for debugging
+	PolicyStateDir string
+
+	// periodic cache consistency checks and repair
+	ConsistencyInterval time.Duration
+	ConsistencyRepair   bool
+
+	// interval for sampling cgroup statistics of containers
+	StatsInterval time.Duration
+
+	// rebalancing rate-limiting and batching
+	RebalanceMinInterval time.Duration
+	RebalanceMaxChanges  int
+	RebalanceOnChurn     bool
+
+	// prioritized, rate-limited reconciliation of container changes
+	ReconcileRate    float64
+	ReconcileRetries int
+	ReconcileBackoff time.Duration
+
+	// secondary runtime and the runtime handlers relayed to it
+	SecondaryRuntimeSocket   string
+	SecondaryRuntimeHandlers stringList
+
+	// runtime connection health checks, reconnection and request buffering
+	RuntimeHealthInterval    time.Duration
+	RuntimeReconnectMaxDelay time.Duration
+	RuntimeWaitForReady      time.Duration
+
+	// maximum delay of asynchronous cache saves, 0 for synchronous saves
+	CacheWriteBehind time.Duration
+
+	// annotations stripped or rewritten in requests sent to the runtime
+	DropAnnotations   stringList
+	DigestAnnotations stringList
+	MaxAnnotationSize int
+
+	// warm standby and taking over from the active instance
+	Standby  bool
+	Takeover bool
+
+	// address of the resource reservation server for external schedulers
+	ReservationAddress string
+}
+
+// stringList is a comma-separated list of strings command line option.
+type stringList []string
+
+// String returns the string representation of the list.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set sets the list from a comma-separated string.
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
 }
 
 // Relay command line options.
@@ -45,6 +133,16 @@ func init() {
 		"Unix domain socket path where CRI image service requests should be relayed to.")
 	flag.StringVar(&opt.RuntimeSocket, "runtime-socket", sockets.Containerd,
 		"Unix domain socket path where CRI runtime service requests should be relayed to.")
+	flag.StringVar(&opt.SecondaryRuntimeSocket, "secondary-runtime-socket", "",
+		"Unix domain socket path of an optional secondary runtime, for instance for VM-based pods.")
+	flag.Var(&opt.SecondaryRuntimeHandlers, "secondary-runtime-handlers",
+		"Comma-separated list of runtime handlers (RuntimeClasses) to relay to the secondary runtime.")
+	flag.DurationVar(&opt.RuntimeHealthInterval, "runtime-health-interval", 5*time.Second,
+		"Interval for checking the health of the runtime connection. 0 disables.")
+	flag.DurationVar(&opt.RuntimeReconnectMaxDelay, "runtime-reconnect-max-delay", 5*time.Second,
+		"Maximum delay between attempts to reconnect to the runtime.")
+	flag.DurationVar(&opt.RuntimeWaitForReady, "runtime-wait-for-ready", 0,
+		"Time to hold requests while the runtime connection is being re-established, 0 to fail fast.")
 	flag.StringVar(&opt.RelaySocket, "relay-socket", sockets.ResourceManagerRelay,
 		"Unix domain socket path where the resource manager should serve requests on.")
 	flag.StringVar(&opt.RelayDir, "relay-dir", "/var/lib/cri-resmgr",
@@ -63,4 +161,62 @@ func init() {
 		"Interval for polling/gathering runtime metrics data. Use 'disable' for disabling.")
 	flag.DurationVar(&opt.RebalanceTimer, "rebalance-interval", 5*time.Minute,
 		"Minimum interval between two container rebalancing attempts. Use 'disable' for disabling.")
+	flag.DurationVar(&opt.RebalanceMinInterval, "rebalance-min-interval", 30*time.Second,
+		"Minimum interval between two rebalancing passes. Requests in between are coalesced.")
+	flag.IntVar(&opt.RebalanceMaxChanges, "rebalance-max-changes", 0,
+		"Maximum number of containers updated in a single rebalancing pass, 0 for no limit.")
+	flag.BoolVar(&opt.RebalanceOnChurn, "rebalance-on-churn", false,
+		"Request (rate-limited) rebalancing when containers are stopped and release resources.")
+	flag.Float64Var(&opt.ReconcileRate, "reconcile-rate", 20,
+		"Maximum number of queued container reconciliations per second, 0 for no limit.")
+	flag.IntVar(&opt.ReconcileRetries, "reconcile-retries", 5,
+		"Number of times a failed container reconciliation is retried before giving up.")
+	flag.DurationVar(&opt.ReconcileBackoff, "reconcile-backoff", time.Second,
+		"Initial delay before retrying a failed container reconciliation, doubled on each retry.")
+	flag.BoolVar(&opt.PodEvents, "pod-events", false,
+		"Emit Kubernetes Events about resource assignments of pods, using cri-resmgr-agent.")
+	flag.StringVar(&opt.PodCPUSetDir, "pod-cpuset-dir", "",
+		"Directory to export the final cpusets of pods in, mounted to containers. Empty disables.")
+	flag.BoolVar(&opt.PlacementLabels, "placement-labels", false,
+		"Label created containers with their pool, cpuset digest and classes, for crictl and log collectors.")
+	flag.BoolVar(&opt.NodeResourceTopology, "node-resource-topology", false,
+		"Export the resource topology of the node as a NodeResourceTopology object, using cri-resmgr-agent.")
+	flag.DurationVar(&opt.NodeStatusInterval, "node-status-interval", 0,
+		"Interval for publishing the active policy and mode as node labels, using cri-resmgr-agent. 0 disables.")
+	flag.IntVar(&opt.FailSafeThreshold, "failsafe-threshold", 5,
+		"Consecutive request processing failures or panics before passing requests through, 0 disables.")
+	flag.DurationVar(&opt.JanitorInterval, "janitor-interval", 0,
+		"Interval for cleaning up stale resctrl groups and cgroup directories of containers, 0 disables.")
+	flag.BoolVar(&opt.JanitorDryRun, "janitor-dry-run", false,
+		"Only report stale resctrl groups and cgroup directories, don't remove them.")
+	flag.BoolVar(&opt.JanitorHTTPCleanup, "janitor-http-cleanup", false,
+		"Allow cleaning up stale resctrl groups and cgroup directories by a POST request to "+JanitorPath+".")
+	flag.DurationVar(&opt.IsolationInterval, "isolation-interval", 0,
+		"Interval for checking that host processes stay off exclusive CPUs, 0 disables.")
+	flag.StringVar(&opt.IsolationMethod, "isolation-method", isolateReport,
+		"How to keep host processes off exclusive CPUs: report, cpuset (of system slices) or taskset.")
+	flag.BoolVar(&opt.IsolationHTTPEnforce, "isolation-http-enforce", false,
+		"Allow enforcing exclusive CPU isolation by a POST request to "+IsolationPath+".")
+	flag.StringVar(&opt.PolicyStateDir, "policy-state-dir", "",
+		"Directory to dump policy state to on SIGUSR1 or a POST to /policy-state. Empty for policy-state in -relay-dir.")
+	flag.DurationVar(&opt.ConsistencyInterval, "consistency-interval", 0,
+		"Interval for checking the cache against the runtime and kernel state, 0 disables.")
+	flag.BoolVar(&opt.ConsistencyRepair, "consistency-repair", false,
+		"Repair discrepancies found by periodic cache consistency checks.")
+	flag.DurationVar(&opt.StatsInterval, "stats-interval", 10*time.Second,
+		"Interval for sampling cpu, memory and io statistics of containers from their cgroups, 0 disables.")
+	flag.DurationVar(&opt.CacheWriteBehind, "cache-write-behind", 0,
+		"Save the cache asynchronously at most this much after changes, 0 saves synchronously.")
+	flag.Var(&opt.DropAnnotations, "drop-annotations",
+		"Comma-separated glob patterns of pod and container annotations to strip from requests sent to the runtime.")
+	flag.Var(&opt.DigestAnnotations, "digest-annotations",
+		"Comma-separated glob patterns of annotations to replace with a digest of their value in requests sent to the runtime.")
+	flag.IntVar(&opt.MaxAnnotationSize, "max-annotation-size", 0,
+		"Replace annotation values larger than this with a digest in requests sent to the runtime, 0 for no limit.")
+	flag.BoolVar(&opt.Standby, "standby", false,
+		"Start as a warm standby, taking over the relay socket once the active instance exits.")
+	flag.BoolVar(&opt.Takeover, "takeover", false,
+		"With -standby, ask the active instance to hand over once we are ready to take over.")
+	flag.StringVar(&opt.ReservationAddress, "reservation-address", "",
+		"Unix domain socket to serve resource reservations for external schedulers on. Empty disables.")
 }
@@ -0,0 +1,53 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+// hysteresis debounces raising and lowering a flag based on periodic samples.
+//
+// A flag is raised once the samples stay above a limit, and lowered once they
+// stay below the limit scaled down by a hysteresis factor, for a given number of
+// consecutive samples. Samples between the two limits reset both counts. The flag
+// itself is kept by the caller, typically as a tag of a container.
+type hysteresis struct {
+	above int // consecutive samples above the limit
+	below int // consecutive samples below the lowered limit
+}
+
+// update accounts for a sample, returning true if the flag should be toggled.
+//
+// flagged tells whether the flag is currently raised, high whether the sample
+// is above the limit, and low whether it is below the lowered limit.
+func (h *hysteresis) update(flagged, high, low bool, samples int) bool {
+	switch {
+	case !flagged && high:
+		h.below = 0
+		h.above++
+		if h.above >= samples {
+			h.above = 0
+			return true
+		}
+	case flagged && low:
+		h.above = 0
+		h.below++
+		if h.below >= samples {
+			h.below = 0
+			return true
+		}
+	default:
+		h.above = 0
+		h.below = 0
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"testing"
+)
+
+func TestHysteresis(t *testing.T) {
+	const (
+		limit   = 100.0
+		factor  = 0.8
+		samples = 3
+	)
+
+	tcases := []struct {
+		name    string
+		flagged bool
+		values  []float64
+		toggles []bool
+	}{
+		{
+			name:    "raise after consecutive samples above the limit",
+			values:  []float64{120, 130, 110},
+			toggles: []bool{false, false, true},
+		},
+		{
+			name:    "samples below the limit reset the count",
+			values:  []float64{120, 130, 90, 110, 120, 150},
+			toggles: []bool{false, false, false, false, false, true},
+		},
+		{
+			name:    "samples at the limit do not count",
+			values:  []float64{100, 100, 100},
+			toggles: []bool{false, false, false},
+		},
+		{
+			name:    "lower after consecutive samples below the lowered limit",
+			flagged: true,
+			values:  []float64{70, 60, 10},
+			toggles: []bool{false, false, true},
+		},
+		{
+			name:    "samples between the limits keep the flag",
+			flagged: true,
+			values:  []float64{70, 90, 70, 70, 95, 60},
+			toggles: []bool{false, false, false, false, false, false},
+		},
+		{
+			name:    "samples above the limit keep the flag",
+			flagged: true,
+			values:  []float64{150, 200, 120},
+			toggles: []bool{false, false, false},
+		},
+		{
+			name:    "raise, then lower",
+			values:  []float64{110, 110, 110, 50, 50, 50},
+			toggles: []bool{false, false, true, false, false, true},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &hysteresis{}
+			flagged := tc.flagged
+			for i, v := range tc.values {
+				toggle := h.update(flagged, v > limit, v <= factor*limit, samples)
+				if toggle != tc.toggles[i] {
+					t.Fatalf("sample #%d (%.0f): expected toggle %v, got %v",
+						i, v, tc.toggles[i], toggle)
+				}
+				if toggle {
+					flagged = !flagged
+				}
+			}
+		})
+	}
+}
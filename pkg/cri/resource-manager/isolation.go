@@ -0,0 +1,649 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+)
+
+const (
+	// IsolationPath is the HTTP path for inspecting and enforcing exclusive CPU isolation.
+	IsolationPath = "/isolation"
+
+	// isolateReport only reports host processes which can run on exclusive CPUs.
+	isolateReport = "report"
+	// isolateCpuset shrinks the cpusets of system slices to exclude exclusive CPUs.
+	isolateCpuset = "cpuset"
+	// isolateTaskset changes the CPU affinity of host processes to exclude exclusive CPUs.
+	isolateTaskset = "taskset"
+
+	// isolationStateFile is the file, in the relay directory, original settings are saved in.
+	isolationStateFile = "isolation.json"
+
+	// procRoot is where procfs is mounted.
+	procRoot = "/proc"
+	// pfKthread is the process flag of kernel threads, which we leave alone.
+	pfKthread = 0x00200000
+	// statFlags and statStartTime are the indices of the process flags and start
+	// time among the fields of /proc/<pid>/stat following the command.
+	statFlags     = 6
+	statStartTime = 19
+)
+
+// isolationViolator is a host process which can run on exclusive CPUs.
+type isolationViolator struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+	Cgroup  string `json:"cgroup"`
+	CPUs    string `json:"cpus"`
+	Moved   bool   `json:"moved,omitempty"`
+	Error   string `json:"error,omitempty"`
+	start   uint64 // start time of the process, to tell it apart from one reusing its PID
+}
+
+// isolatedSlice is a system slice, or a cgroup in one, with its cpuset changed.
+type isolatedSlice struct {
+	Path     string `json:"path"`
+	Original string `json:"original"`
+	CPUs     string `json:"cpus"`
+	Error    string `json:"error,omitempty"`
+}
+
+// isolationReport is the result of checking and enforcing exclusive CPU isolation.
+type isolationReport struct {
+	Method    string               `json:"method"`
+	Exclusive string               `json:"exclusive"`
+	Slices    []*isolatedSlice     `json:"slices,omitempty"`
+	Violators []*isolationViolator `json:"violators"`
+}
+
+// isolationState is the state of exclusive CPU isolation enforcement.
+//
+// The state is saved in the relay directory whenever it changes, so that the
+// original settings are restored even if we are restarted in between.
+type isolationState struct {
+	// Cpusets are the original cpusets of the cgroups we changed, by directory.
+	Cpusets map[string]string `json:"cpusets,omitempty"`
+	// Affinity is the original CPU affinity of the processes we changed, by PID.
+	Affinity map[int]*originalAffinity `json:"affinity,omitempty"`
+}
+
+// originalAffinity is the original CPU affinity of a process we changed.
+type originalAffinity struct {
+	Start uint64 `json:"start"` // start time of the process, to detect PID reuse
+	CPUs  string `json:"cpus"`
+}
+
+// checkIsolationMethod checks the configured isolation enforcement method.
+func checkIsolationMethod() error {
+	switch opt.IsolationMethod {
+	case isolateReport, isolateCpuset, isolateTaskset:
+		return nil
+	}
+	return resmgrError("invalid isolation method %q, expecting %s, %s or %s",
+		opt.IsolationMethod, isolateReport, isolateCpuset, isolateTaskset)
+}
+
+// setupIsolationEndpoint sets up the HTTP endpoint for exclusive CPU isolation.
+func (m *resmgr) setupIsolationEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, exclusive CPU isolation inspection disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, IsolationPath, http.HandlerFunc(m.serveIsolation))
+
+	return nil
+}
+
+// serveIsolation reports violators for GET, and enforces isolation for POST requests.
+//
+// The HTTP endpoint is unauthenticated, so enforcing isolation over it is only
+// allowed if explicitly enabled on the command line.
+func (m *resmgr) serveIsolation(w http.ResponseWriter, req *http.Request) {
+	var report *isolationReport
+
+	switch req.Method {
+	case http.MethodGet:
+		report = m.runIsolation(false)
+	case http.MethodPost:
+		if !opt.IsolationHTTPEnforce {
+			http.Error(w, "enforcing isolation over HTTP is disabled", http.StatusForbidden)
+			return
+		}
+		report = m.runIsolation(true)
+	default:
+		http.Error(w, "unsupported method "+req.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		m.Error("failed to serve isolation report: %v", err)
+	}
+}
+
+// startIsolation loads the saved isolation state, restoring the settings the
+// configured method no longer manages, for instance after switching methods.
+func (m *resmgr) startIsolation() {
+	if err := m.loadIsolation(); err != nil {
+		m.Error("isolation: %v", err)
+	}
+	none := cpuset.NewCPUSet()
+	if opt.IsolationMethod != isolateCpuset && len(m.isolation.Cpusets) > 0 {
+		m.isolateSlices(none)
+	}
+	if opt.IsolationMethod != isolateTaskset && len(m.isolation.Affinity) > 0 {
+		m.restoreAffinity(none)
+	}
+	if err := m.saveIsolation(); err != nil {
+		m.Error("isolation: %v", err)
+	}
+}
+
+// stopIsolation restores all the settings we have changed to isolate exclusive CPUs.
+func (m *resmgr) stopIsolation() {
+	none := cpuset.NewCPUSet()
+	if len(m.isolation.Cpusets) > 0 {
+		m.isolateSlices(none)
+	}
+	if len(m.isolation.Affinity) > 0 {
+		m.restoreAffinity(none)
+	}
+	if err := m.saveIsolation(); err != nil {
+		m.Error("isolation: %v", err)
+	}
+}
+
+// loadIsolation loads the saved isolation state.
+func (m *resmgr) loadIsolation() error {
+	path := filepath.Join(opt.RelayDir, isolationStateFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return resmgrError("failed to read isolation state: %v", err)
+	}
+
+	state := isolationState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return resmgrError("failed to parse isolation state from %s: %v", path, err)
+	}
+	m.isolation = state
+
+	return nil
+}
+
+// saveIsolation saves the isolation state, or removes it once nothing is changed.
+func (m *resmgr) saveIsolation() error {
+	path := filepath.Join(opt.RelayDir, isolationStateFile)
+	if len(m.isolation.Cpusets) == 0 && len(m.isolation.Affinity) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return resmgrError("failed to remove isolation state: %v", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(m.isolation)
+	if err != nil {
+		return resmgrError("failed to marshal isolation state: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return resmgrError("failed to save isolation state: %v", err)
+	}
+
+	return nil
+}
+
+// runIsolation finds host processes which can run on exclusive CPUs, moving them off if enforce.
+//
+// Host processes are all processes outside the kubepods cgroup hierarchies,
+// except kernel threads. With the cpuset method, the cpusets of the top-level
+// cgroups other than kubepods, like system.slice and user.slice, are shrunk to
+// exclude exclusive CPUs. With the taskset method, the CPU affinity of every
+// thread of offending processes is changed instead. Either way the original
+// settings are restored once the CPUs are no longer used exclusively, or when
+// we stop.
+func (m *resmgr) runIsolation(enforce bool) *isolationReport {
+	m.Lock()
+	defer m.Unlock()
+
+	method := opt.IsolationMethod
+	if !enforce {
+		method = isolateReport
+	}
+
+	exclusive := cpuset.NewCPUSet()
+	if m.policy != nil {
+		exclusive = m.policy.ExclusiveCPUs()
+	}
+	report := &isolationReport{Method: method, Exclusive: exclusive.String()}
+
+	switch method {
+	case isolateCpuset:
+		report.Slices = m.isolateSlices(exclusive)
+	case isolateTaskset:
+		m.restoreAffinity(exclusive)
+	}
+
+	report.Violators = scanViolators(exclusive)
+	moved := 0
+	for _, v := range report.Violators {
+		if method == isolateTaskset && m.moveProcess(v, exclusive) {
+			moved++
+			continue
+		}
+		m.Debug("isolation: %s (pid %d, cgroup %s) can run on CPUs %s",
+			v.Command, v.PID, v.Cgroup, v.CPUs)
+	}
+
+	if left := len(report.Violators) - moved; left > 0 {
+		m.Warn("isolation: %d host processes can run on exclusive CPUs %s", left, exclusive)
+	}
+	if moved > 0 {
+		m.Info("isolation: moved %d host processes off exclusive CPUs %s", moved, exclusive)
+	}
+
+	if method != isolateReport {
+		if err := m.saveIsolation(); err != nil {
+			m.Error("isolation: %v", err)
+		}
+	}
+
+	return report
+}
+
+// scanViolators returns the host processes which can run on the given exclusive CPUs.
+func scanViolators(exclusive cpuset.CPUSet) []*isolationViolator {
+	violators := []*isolationViolator{}
+	if exclusive.IsEmpty() {
+		return violators
+	}
+
+	entries, _ := ioutil.ReadDir(procRoot)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(procRoot, e.Name())
+		if isKernelThread(dir) {
+			continue
+		}
+		cgroup, managed, ok := processCgroup(dir)
+		if !ok || managed {
+			continue
+		}
+		allowed, ok := allowedCPUs(filepath.Join(dir, "status"))
+		if !ok || allowed.Intersection(exclusive).IsEmpty() {
+			continue
+		}
+		comm, _ := ioutil.ReadFile(filepath.Join(dir, "comm"))
+		start, _ := processStartTime(dir)
+		violators = append(violators, &isolationViolator{
+			PID:     pid,
+			Command: strings.TrimSpace(string(comm)),
+			Cgroup:  cgroup,
+			CPUs:    allowed.String(),
+			start:   start,
+		})
+	}
+
+	sort.Slice(violators, func(i, j int) bool {
+		return violators[i].PID < violators[j].PID
+	})
+
+	return violators
+}
+
+// moveProcess moves all threads of a host process off the given exclusive CPUs.
+func (m *resmgr) moveProcess(v *isolationViolator, exclusive cpuset.CPUSet) bool {
+	allowed, err := cpuset.Parse(v.CPUs)
+	if err != nil {
+		v.Error = err.Error()
+		return false
+	}
+	target := allowed.Difference(exclusive)
+	if target.IsEmpty() {
+		v.Error = "process can run only on exclusive CPUs"
+		return false
+	}
+
+	if err := setProcessAffinity(v.PID, target); err != nil {
+		v.Error = err.Error()
+		m.Warn("isolation: failed to move %s (pid %d) off CPUs %s: %v",
+			v.Command, v.PID, exclusive, err)
+		return false
+	}
+
+	if m.isolation.Affinity == nil {
+		m.isolation.Affinity = make(map[int]*originalAffinity)
+	}
+	// an entry of an earlier process with the same PID is stale
+	if o, ok := m.isolation.Affinity[v.PID]; !ok || o.Start != v.start {
+		m.isolation.Affinity[v.PID] = &originalAffinity{Start: v.start, CPUs: v.CPUs}
+	}
+	v.Moved = true
+	m.Debug("isolation: moved %s (pid %d) from CPUs %s to %s", v.Command, v.PID, allowed, target)
+
+	return true
+}
+
+// restoreAffinity restores the CPU affinity of moved processes as CPUs stop being exclusive.
+//
+// Processes which are gone, or whose PID has been reused by another process
+// since we moved them, are forgotten.
+func (m *resmgr) restoreAffinity(exclusive cpuset.CPUSet) {
+	for pid, o := range m.isolation.Affinity {
+		dir := filepath.Join(procRoot, strconv.Itoa(pid))
+		current, ok := allowedCPUs(filepath.Join(dir, "status"))
+		if !ok {
+			delete(m.isolation.Affinity, pid)
+			continue
+		}
+		if start, ok := processStartTime(dir); !ok || start != o.Start {
+			delete(m.isolation.Affinity, pid)
+			continue
+		}
+		original, err := cpuset.Parse(o.CPUs)
+		if err != nil {
+			delete(m.isolation.Affinity, pid)
+			continue
+		}
+		target := original.Difference(exclusive)
+		if target.IsEmpty() {
+			continue
+		}
+		if !target.Equals(current) {
+			if err := setProcessAffinity(pid, target); err != nil {
+				m.Warn("isolation: failed to restore CPU affinity of pid %d: %v", pid, err)
+				continue
+			}
+			m.Debug("isolation: restored CPU affinity of pid %d to %s", pid, target)
+		}
+		if target.Equals(original) {
+			delete(m.isolation.Affinity, pid)
+		}
+	}
+}
+
+// isolateSlices shrinks the cpusets of system slices to exclude the given exclusive CPUs.
+//
+// With cgroup v1 the cpusets of all cgroups in a slice are changed, since the
+// cpuset of a cgroup must be a subset of that of its parent. Cpusets are first
+// shrunk deepest cgroup first, then grown shallowest cgroup first, to always
+// keep them nested. With cgroup v2 only the slice itself needs to be changed.
+func (m *resmgr) isolateSlices(exclusive cpuset.CPUSet) []*isolatedSlice {
+	root, v2 := cpusetHierarchy()
+	if root == "" {
+		m.Warn("isolation: no cpuset cgroup hierarchy found")
+		return nil
+	}
+	return m.isolateSlicesIn(root, v2, exclusive)
+}
+
+// isolateSlicesIn shrinks the cpusets of the system slices of the given cpuset hierarchy.
+func (m *resmgr) isolateSlicesIn(root string, v2 bool, exclusive cpuset.CPUSet) []*isolatedSlice {
+	online, ok := readCpusetFile(root, "cpuset.cpus.effective")
+	if !ok {
+		online, _ = readCpusetFile(root, "cpuset.cpus")
+	}
+
+	if m.isolation.Cpusets == nil {
+		m.isolation.Cpusets = make(map[string]string)
+	}
+	for dir := range m.isolation.Cpusets {
+		if !cgroups.IsDir(dir) {
+			delete(m.isolation.Cpusets, dir)
+		}
+	}
+
+	dirs := []string{}
+	for _, slice := range systemSlices(root) {
+		if v2 {
+			dirs = append(dirs, slice)
+			continue
+		}
+		filepath.Walk(slice, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				dirs = append(dirs, path)
+			}
+			return nil
+		})
+	}
+
+	slices := []*isolatedSlice{}
+	targets := map[string]cpuset.CPUSet{}
+	for _, dir := range dirs {
+		current, err := readCgroupFile(dir, "cpuset.cpus")
+		if err != nil {
+			continue
+		}
+		original, ok := m.isolation.Cpusets[dir]
+		if !ok {
+			original = current
+		}
+		if original == "" && !v2 {
+			// an empty cpuset in cgroup v1 means the cgroup can't have any tasks
+			continue
+		}
+		base := online
+		if original != "" {
+			if base, err = cpuset.Parse(original); err != nil {
+				continue
+			}
+		}
+		if base.Intersection(exclusive).IsEmpty() {
+			if ok && current != original {
+				if err := writeCgroupFile(dir, "cpuset.cpus", original); err != nil {
+					m.Warn("isolation: failed to restore cpuset of %s: %v", dir, err)
+					continue
+				}
+				m.Info("isolation: restored cpuset of %s to %q", dir, original)
+			}
+			delete(m.isolation.Cpusets, dir)
+			continue
+		}
+
+		s := &isolatedSlice{Path: dir, Original: original}
+		slices = append(slices, s)
+		target := base.Difference(exclusive)
+		if target.IsEmpty() {
+			s.CPUs = current
+			s.Error = "cgroup can run only on exclusive CPUs"
+			continue
+		}
+		s.CPUs = target.String()
+		targets[dir] = target
+		m.isolation.Cpusets[dir] = original
+	}
+
+	// shrink deepest first, then grow shallowest first, keeping cpusets nested
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target, ok := targets[dirs[i]]
+		if !ok {
+			continue
+		}
+		current, _ := readCpusetFile(dirs[i], "cpuset.cpus")
+		if shrunk := current.Intersection(target); !shrunk.Equals(current) && !shrunk.IsEmpty() {
+			writeCgroupFile(dirs[i], "cpuset.cpus", shrunk.String())
+		}
+	}
+	for _, s := range slices {
+		target, ok := targets[s.Path]
+		if !ok {
+			continue
+		}
+		if current, _ := readCpusetFile(s.Path, "cpuset.cpus"); current.Equals(target) {
+			continue
+		}
+		if err := writeCgroupFile(s.Path, "cpuset.cpus", target.String()); err != nil {
+			s.Error = err.Error()
+			m.Warn("isolation: failed to set cpuset of %s to %s: %v", s.Path, target, err)
+			continue
+		}
+		m.Info("isolation: cpuset of %s set to %s", s.Path, target)
+	}
+
+	return slices
+}
+
+// cpusetHierarchy returns the cgroup hierarchy with the cpuset controller, and whether it is v2.
+func cpusetHierarchy() (string, bool) {
+	if data, err := ioutil.ReadFile(filepath.Join(cgroupRoot, "cgroup.subtree_control")); err == nil {
+		for _, controller := range strings.Fields(string(data)) {
+			if controller == "cpuset" {
+				return cgroupRoot, true
+			}
+		}
+		return "", false
+	}
+	if dir := filepath.Join(cgroupRoot, "cpuset"); cgroups.IsDir(dir) {
+		return dir, false
+	}
+	return "", false
+}
+
+// systemSlices returns the top-level cgroups of a hierarchy, other than kubepods.
+func systemSlices(root string) []string {
+	slices := []string{}
+	entries, _ := ioutil.ReadDir(root)
+	for _, e := range entries {
+		if !e.IsDir() || cgroups.IsKubepodsDir(e.Name()) {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, "cpuset.cpus")); err == nil {
+			slices = append(slices, dir)
+		}
+	}
+	return slices
+}
+
+// processCgroup returns the cgroup of a process, and whether it is in a kubepods hierarchy.
+func processCgroup(dir string) (string, bool, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cgroup"))
+	if err != nil {
+		return "", false, false
+	}
+
+	cgroup, unified, managed := "", "", false
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		top := strings.SplitN(strings.TrimPrefix(fields[2], "/"), "/", 2)[0]
+		if cgroups.IsKubepodsDir(top) {
+			managed = true
+		}
+		if fields[1] == "" {
+			unified = fields[2]
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "cpuset" {
+				cgroup = fields[2]
+			}
+		}
+	}
+	if cgroup == "" {
+		cgroup = unified
+	}
+
+	return cgroup, managed, true
+}
+
+// isKernelThread checks if the process of the given procfs directory is a kernel thread.
+func isKernelThread(dir string) bool {
+	flags, ok := processStat(dir, statFlags)
+	return ok && flags&pfKthread != 0
+}
+
+// processStartTime returns the start time of the process of the given procfs directory.
+func processStartTime(dir string) (uint64, bool) {
+	return processStat(dir, statStartTime)
+}
+
+// processStat returns a numeric field of the stat of the process of the given procfs directory.
+//
+// Fields are indexed from the one following the command, which may contain
+// spaces and parentheses itself.
+func processStat(dir string, field int) (uint64, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return 0, false
+	}
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) <= field {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(fields[field], 10, 64)
+	return value, err == nil
+}
+
+// allowedCPUs returns the CPUs a process is allowed to run on, from its procfs status.
+func allowedCPUs(status string) (cpuset.CPUSet, bool) {
+	data, err := ioutil.ReadFile(status)
+	if err != nil {
+		return cpuset.NewCPUSet(), false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Cpus_allowed_list:") {
+			continue
+		}
+		cpus, err := cpuset.Parse(strings.TrimSpace(strings.TrimPrefix(line, "Cpus_allowed_list:")))
+		return cpus, err == nil
+	}
+	return cpuset.NewCPUSet(), false
+}
+
+// readCgroupFile reads the trimmed content of a cgroup control file.
+func readCgroupFile(dir, file string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readCpusetFile reads a cpuset from a cgroup control file.
+func readCpusetFile(dir, file string) (cpuset.CPUSet, bool) {
+	value, err := readCgroupFile(dir, file)
+	if err != nil {
+		return cpuset.NewCPUSet(), false
+	}
+	cpus, err := cpuset.Parse(value)
+	return cpus, err == nil
+}
+
+// writeCgroupFile writes a cgroup control file.
+//
+// The value is newline-terminated, so an empty one still gets written.
+func writeCgroupFile(dir, file, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, file), []byte(value+"\n"), 0644)
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package resmgr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// setProcessAffinity sets the CPU affinity of all threads of a process.
+func setProcessAffinity(pid int, cpus cpuset.CPUSet) error {
+	set := unix.CPUSet{}
+	for _, cpu := range cpus.ToSlice() {
+		set.Set(cpu)
+	}
+
+	tasks, err := ioutil.ReadDir(filepath.Join(procRoot, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// threads might exit while we're at it
+		if err := unix.SchedSetaffinity(tid, &set); err != nil && err != unix.ESRCH {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// writeTestFiles creates the given files, with their directories, under root.
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create %s: %v", path, err)
+		}
+	}
+}
+
+// testStat returns a /proc/<pid>/stat line with the given command, flags and start time.
+func testStat(comm, flags, start string) string {
+	fields := []string{"1", "(" + comm + ")", "S"}
+	for i := 1; i < 20; i++ {
+		switch i {
+		case statFlags:
+			fields = append(fields, flags)
+		case statStartTime:
+			fields = append(fields, start)
+		default:
+			fields = append(fields, "0")
+		}
+	}
+	return strings.Join(fields, " ") + "\n"
+}
+
+func TestProcessStat(t *testing.T) {
+	root, err := ioutil.TempDir("", "isolation-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"1/stat": testStat("systemd", "4194560", "12"),
+		"2/stat": testStat("kthreadd", "2129984", "13"),
+		"3/stat": testStat("odd) (name", "4194560", "14"),
+		"4/stat": "4 (short) S 1",
+	})
+
+	tcases := []struct {
+		pid     string
+		kthread bool
+		start   uint64
+		ok      bool
+	}{
+		{pid: "1", start: 12, ok: true},
+		{pid: "2", kthread: true, start: 13, ok: true},
+		{pid: "3", start: 14, ok: true},
+		{pid: "4"},
+		{pid: "5"},
+	}
+	for _, tc := range tcases {
+		dir := filepath.Join(root, tc.pid)
+		if kthread := isKernelThread(dir); kthread != tc.kthread {
+			t.Errorf("pid %s: expected kernel thread %v, got %v", tc.pid, tc.kthread, kthread)
+		}
+		start, ok := processStartTime(dir)
+		if ok != tc.ok || start != tc.start {
+			t.Errorf("pid %s: expected start time %d (%v), got %d (%v)", tc.pid, tc.start, tc.ok, start, ok)
+		}
+	}
+}
+
+func TestProcessCgroup(t *testing.T) {
+	root, err := ioutil.TempDir("", "isolation-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	tcases := []struct {
+		name    string
+		content string
+		cgroup  string
+		managed bool
+		ok      bool
+	}{
+		{
+			name:    "v2 system slice",
+			content: "0::/system.slice/sshd.service\n",
+			cgroup:  "/system.slice/sshd.service",
+			ok:      true,
+		},
+		{
+			name:    "v2 pod",
+			content: "0::/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope\n",
+			cgroup:  "/kubepods.slice/kubepods-pod1.slice/cri-containerd-1.scope",
+			managed: true,
+			ok:      true,
+		},
+		{
+			name:    "v1 cpuset cgroup preferred",
+			content: "12:cpu,cpuacct:/user.slice\n5:cpuset:/\n0::/user.slice/session-1.scope\n",
+			cgroup:  "/",
+			ok:      true,
+		},
+		{
+			name:    "v1 pod",
+			content: "5:cpuset:/kubepods/besteffort/pod1/ctr\n1:name=systemd:/kubepods/besteffort/pod1/ctr\n",
+			cgroup:  "/kubepods/besteffort/pod1/ctr",
+			managed: true,
+			ok:      true,
+		},
+		{
+			name: "no cgroup file",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(root, strings.Replace(tc.name, " ", "-", -1))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("failed to create %s: %v", dir, err)
+			}
+			if tc.content != "" {
+				writeTestFiles(t, dir, map[string]string{"cgroup": tc.content})
+			}
+			cgroup, managed, ok := processCgroup(dir)
+			if cgroup != tc.cgroup || managed != tc.managed || ok != tc.ok {
+				t.Errorf("expected (%q, %v, %v), got (%q, %v, %v)",
+					tc.cgroup, tc.managed, tc.ok, cgroup, managed, ok)
+			}
+		})
+	}
+}
+
+func TestAllowedCPUs(t *testing.T) {
+	root, err := ioutil.TempDir("", "isolation-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"valid":   "Name:\tbash\nCpus_allowed:\tff\nCpus_allowed_list:\t0-3,6\nMems_allowed_list:\t0\n",
+		"missing": "Name:\tbash\nCpus_allowed:\tff\n",
+		"invalid": "Cpus_allowed_list:\tfoo\n",
+	})
+
+	if cpus, ok := allowedCPUs(filepath.Join(root, "valid")); !ok || cpus.String() != "0-3,6" {
+		t.Errorf("expected allowed CPUs 0-3,6, got %s (%v)", cpus, ok)
+	}
+	for _, file := range []string{"missing", "invalid", "nonexistent"} {
+		if cpus, ok := allowedCPUs(filepath.Join(root, file)); ok {
+			t.Errorf("%s: expected failure, got %s", file, cpus)
+		}
+	}
+}
+
+func TestIsolateSlices(t *testing.T) {
+	tcases := []struct {
+		name  string
+		v2    bool
+		files map[string]string
+		// expected cpusets after isolation, and after restoration
+		isolated map[string]string
+		restored map[string]string
+	}{
+		{
+			name: "cgroup v2",
+			v2:   true,
+			files: map[string]string{
+				"cpuset.cpus.effective":                 "0-7",
+				"system.slice/cpuset.cpus":              "",
+				"system.slice/sshd.service/cpuset.cpus": "",
+				"user.slice/cpuset.cpus":                "0-3",
+				"kubepods.slice/cpuset.cpus":            "",
+			},
+			isolated: map[string]string{
+				"system.slice/cpuset.cpus":              "0-1,4-7",
+				"system.slice/sshd.service/cpuset.cpus": "",
+				"user.slice/cpuset.cpus":                "0-1",
+				"kubepods.slice/cpuset.cpus":            "",
+			},
+			restored: map[string]string{
+				"system.slice/cpuset.cpus": "",
+				"user.slice/cpuset.cpus":   "0-3",
+			},
+		},
+		{
+			name: "cgroup v1",
+			files: map[string]string{
+				"cpuset.cpus":                           "0-7",
+				"system.slice/cpuset.cpus":              "0-7",
+				"system.slice/sshd.service/cpuset.cpus": "0-7",
+				"system.slice/empty/cpuset.cpus":        "",
+				"kubepods/cpuset.cpus":                  "0-7",
+			},
+			isolated: map[string]string{
+				"system.slice/cpuset.cpus":              "0-1,4-7",
+				"system.slice/sshd.service/cpuset.cpus": "0-1,4-7",
+				"system.slice/empty/cpuset.cpus":        "",
+				"kubepods/cpuset.cpus":                  "0-7",
+			},
+			restored: map[string]string{
+				"system.slice/cpuset.cpus":              "0-7",
+				"system.slice/sshd.service/cpuset.cpus": "0-7",
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "isolation-test")
+			if err != nil {
+				t.Fatalf("failed to create test directory: %v", err)
+			}
+			defer os.RemoveAll(root)
+			writeTestFiles(t, root, tc.files)
+
+			check := func(expected map[string]string) {
+				for file, value := range expected {
+					data, err := ioutil.ReadFile(filepath.Join(root, file))
+					if err != nil {
+						t.Fatalf("failed to read %s: %v", file, err)
+					}
+					if v := strings.TrimSpace(string(data)); v != value {
+						t.Errorf("expected %s to be %q, got %q", file, value, v)
+					}
+				}
+			}
+
+			m := &resmgr{Logger: logger.NewLogger("isolation-test")}
+			exclusive := cpuset.NewCPUSet(2, 3)
+			for i := 0; i < 2; i++ {
+				for _, s := range m.isolateSlicesIn(root, tc.v2, exclusive) {
+					if s.Error != "" {
+						t.Errorf("failed to isolate %s: %s", s.Path, s.Error)
+					}
+				}
+				check(tc.isolated)
+			}
+
+			// a restarted instance picks up the originals, not the shrunk cpusets
+			m = &resmgr{Logger: logger.NewLogger("isolation-test"), isolation: m.isolation}
+			m.isolateSlicesIn(root, tc.v2, cpuset.NewCPUSet())
+			check(tc.restored)
+			if len(m.isolation.Cpusets) != 0 {
+				t.Errorf("expected all cpusets restored, left %v", m.isolation.Cpusets)
+			}
+		})
+	}
+}
+
+func TestSaveIsolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "isolation-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	saved := opt.RelayDir
+	defer func() { opt.RelayDir = saved }()
+	opt.RelayDir = dir
+
+	m := &resmgr{Logger: logger.NewLogger("isolation-test")}
+	m.isolation.Cpusets = map[string]string{"/sys/fs/cgroup/system.slice": ""}
+	m.isolation.Affinity = map[int]*originalAffinity{123: {Start: 4567, CPUs: "0-7"}}
+	if err := m.saveIsolation(); err != nil {
+		t.Fatalf("failed to save isolation state: %v", err)
+	}
+
+	restarted := &resmgr{Logger: logger.NewLogger("isolation-test")}
+	if err := restarted.loadIsolation(); err != nil {
+		t.Fatalf("failed to load isolation state: %v", err)
+	}
+	if original, ok := restarted.isolation.Cpusets["/sys/fs/cgroup/system.slice"]; !ok || original != "" {
+		t.Errorf("expected original cpuset of system.slice, got %v", restarted.isolation.Cpusets)
+	}
+	if o := restarted.isolation.Affinity[123]; o == nil || o.Start != 4567 || o.CPUs != "0-7" {
+		t.Errorf("expected original affinity of pid 123, got %v", restarted.isolation.Affinity)
+	}
+
+	m.isolation = isolationState{}
+	if err := m.saveIsolation(); err != nil {
+		t.Fatalf("failed to clear isolation state: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, isolationStateFile)); !os.IsNotExist(err) {
+		t.Errorf("expected isolation state to be removed, got %v", err)
+	}
+}
+
+func TestIsolationHTTPEnforceDisabled(t *testing.T) {
+	saved := opt.IsolationHTTPEnforce
+	defer func() { opt.IsolationHTTPEnforce = saved }()
+	opt.IsolationHTTPEnforce = false
+
+	m := &resmgr{Logger: logger.NewLogger("isolation-test")}
+	rec := httptest.NewRecorder()
+	m.serveIsolation(rec, httptest.NewRequest(http.MethodPost, IsolationPath, nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST with enforcement disabled: expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
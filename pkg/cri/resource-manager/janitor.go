@@ -0,0 +1,262 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+	"github.com/intel/cri-resource-manager/pkg/rdt"
+)
+
+const (
+	// JanitorPath is the HTTP path for inspecting and triggering stale state cleanup.
+	JanitorPath = "/janitor"
+
+	// cgroupRoot is where the cgroup hierarchies are mounted.
+	cgroupRoot = "/sys/fs/cgroup"
+	// janitorMinAge is how old a directory must be before we consider it stale.
+	janitorMinAge = 5 * time.Minute
+
+	// staleCgroup is the kind of stale cgroup directories.
+	staleCgroup = "cgroup"
+	// staleResctrl is the kind of stale resctrl groups.
+	staleResctrl = "resctrl"
+)
+
+var (
+	// containerIDRe matches the names of directories of containers and pod sandboxes.
+	containerIDRe = regexp.MustCompile(`(?:^|[-.])([0-9a-f]{64})(?:\.scope)?$`)
+	// podUIDRe matches the names of directories of pods.
+	podUIDRe = regexp.MustCompile(
+		`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+)
+
+// staleEntry is a cgroup directory or resctrl group left behind by a container or pod.
+type staleEntry struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Owner   string `json:"owner"`
+	Busy    bool   `json:"busy,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// janitorReport is the result of scanning for and cleaning up stale entries.
+type janitorReport struct {
+	DryRun  bool          `json:"dryRun"`
+	Entries []*staleEntry `json:"entries"`
+}
+
+// setupJanitorEndpoint sets up the HTTP endpoint for stale state cleanup.
+func (m *resmgr) setupJanitorEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, stale state cleanup inspection disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, JanitorPath, http.HandlerFunc(m.serveJanitor))
+
+	return nil
+}
+
+// serveJanitor reports stale entries for GET, and cleans them up for POST requests.
+//
+// The HTTP endpoint is unauthenticated, so cleaning up over it is only allowed
+// if explicitly enabled on the command line.
+func (m *resmgr) serveJanitor(w http.ResponseWriter, req *http.Request) {
+	var report *janitorReport
+
+	switch req.Method {
+	case http.MethodGet:
+		report = m.runJanitor(true)
+	case http.MethodPost:
+		if !opt.JanitorHTTPCleanup {
+			http.Error(w, "cleanup over HTTP is disabled", http.StatusForbidden)
+			return
+		}
+		report = m.runJanitor(false)
+	default:
+		http.Error(w, "unsupported method "+req.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		m.Error("failed to serve janitor report: %v", err)
+	}
+}
+
+// runJanitor scans for stale cgroup directories and resctrl groups, removing them unless dryRun.
+func (m *resmgr) runJanitor(dryRun bool) *janitorReport {
+	m.Lock()
+	defer m.Unlock()
+
+	known := m.knownOwners()
+	report := &janitorReport{DryRun: dryRun}
+
+	for _, root := range kubepodsRoots() {
+		report.Entries = append(report.Entries, scanStale(staleCgroup, root, "cgroup.procs", known)...)
+	}
+	if path, err := rdt.ResctrlMountPath(); err == nil {
+		report.Entries = append(report.Entries, scanStale(staleResctrl, path, "tasks", known)...)
+	}
+
+	// remove the deepest entries first, so parents are empty by the time we get to them
+	sort.Slice(report.Entries, func(i, j int) bool {
+		return len(report.Entries[i].Path) > len(report.Entries[j].Path)
+	})
+
+	for _, e := range report.Entries {
+		if e.Busy {
+			continue
+		}
+		if dryRun {
+			m.Info("janitor: would remove stale %s %s of %s", e.Kind, e.Path, e.Owner)
+			continue
+		}
+		if err := os.Remove(e.Path); err != nil {
+			e.Error = err.Error()
+			m.Warn("janitor: failed to remove stale %s %s: %v", e.Kind, e.Path, err)
+			continue
+		}
+		e.Removed = true
+		m.Info("janitor: removed stale %s %s of %s", e.Kind, e.Path, e.Owner)
+	}
+
+	return report
+}
+
+// knownOwners returns the IDs of all cached containers and pod sandboxes, and the UIDs of pods.
+func (m *resmgr) knownOwners() map[string]struct{} {
+	known := map[string]struct{}{}
+	for _, p := range m.cache.GetPods() {
+		known[p.GetID()] = struct{}{}
+		if uid := p.GetUID(); uid != "" {
+			known[uid] = struct{}{}
+		}
+	}
+	for _, c := range m.cache.GetContainers() {
+		known[c.GetID()] = struct{}{}
+	}
+	return known
+}
+
+// kubepodsRoots returns the top-level pod cgroup directories in all cgroup hierarchies.
+func kubepodsRoots() []string {
+	hierarchies := []string{cgroupRoot}
+	if entries, err := ioutil.ReadDir(cgroupRoot); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				hierarchies = append(hierarchies, filepath.Join(cgroupRoot, e.Name()))
+			}
+		}
+	}
+
+	roots := []string{}
+	for _, h := range hierarchies {
+		for _, dir := range cgroups.KubepodsDirs {
+			root := filepath.Join(h, dir)
+			if cgroups.IsDir(root) {
+				roots = append(roots, root)
+			}
+		}
+	}
+	return roots
+}
+
+// scanStale scans a hierarchy for directories of containers and pods not in the cache.
+//
+// Directories with any tasks left in them, or in any of their subdirectories, are
+// reported as busy. Directories younger than janitorMinAge are ignored altogether,
+// as they might belong to a container or pod which we have not seen created yet.
+func scanStale(kind, root, tasks string, known map[string]struct{}) []*staleEntry {
+	entries := []*staleEntry{}
+	busy := []string{}
+
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if kind == staleResctrl && isResctrlInfoDir(filepath.Base(path)) {
+			return filepath.SkipDir
+		}
+		if hasTasks(filepath.Join(path, tasks)) {
+			busy = append(busy, path)
+		}
+
+		owner := ownerOf(filepath.Base(path))
+		if owner == "" {
+			return nil
+		}
+		if _, ok := known[owner]; ok {
+			return nil
+		}
+		if time.Since(info.ModTime()) < janitorMinAge {
+			return nil
+		}
+
+		entries = append(entries, &staleEntry{Kind: kind, Path: path, Owner: owner})
+		return nil
+	})
+
+	for _, e := range entries {
+		for _, b := range busy {
+			if b == e.Path || strings.HasPrefix(b, e.Path+"/") {
+				e.Busy = true
+				break
+			}
+		}
+	}
+
+	return entries
+}
+
+// ownerOf returns the container ID or pod UID a directory name refers to, if any.
+func ownerOf(name string) string {
+	if m := containerIDRe.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+	if m := podUIDRe.FindStringSubmatch(name); m != nil {
+		return strings.Replace(m[1], "_", "-", -1)
+	}
+	return ""
+}
+
+// isResctrlInfoDir checks if a resctrl directory holds information, not a group.
+func isResctrlInfoDir(name string) bool {
+	return name == "info" || name == "mon_data"
+}
+
+// hasTasks checks if the given tasks file of a cgroup or resctrl group lists any tasks.
+func hasTasks(path string) bool {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(data)) != ""
+}
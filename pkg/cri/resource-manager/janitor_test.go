@@ -0,0 +1,142 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	testContainerID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testPodUID      = "01234567-89ab-cdef-0123-456789abcdef"
+)
+
+func TestOwnerOf(t *testing.T) {
+	tcases := []struct {
+		name  string
+		owner string
+	}{
+		{name: testContainerID, owner: testContainerID},
+		{name: "docker-" + testContainerID + ".scope", owner: testContainerID},
+		{name: "cri-containerd-" + testContainerID + ".scope", owner: testContainerID},
+		{name: "pod" + testPodUID, owner: testPodUID},
+		{name: "kubepods-besteffort-pod" + strings.Replace(testPodUID, "-", "_", -1) + ".slice",
+			owner: testPodUID},
+		{name: "cri-resmgr.Guaranteed", owner: ""},
+		{name: "kubepods.slice", owner: ""},
+	}
+	for _, tc := range tcases {
+		if owner := ownerOf(tc.name); owner != tc.owner {
+			t.Errorf("ownerOf(%q): expected %q, got %q", tc.name, tc.owner, owner)
+		}
+	}
+}
+
+// mkdirAged creates a directory with an optional tasks file and sets its age.
+func mkdirAged(t *testing.T, path, tasks string, age time.Duration) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(tasks), 0644); err != nil {
+		t.Fatalf("failed to create tasks of %s: %v", path, err)
+	}
+	when := time.Now().Add(-age)
+	if err := os.Chtimes(path, when, when); err != nil {
+		t.Fatalf("failed to set age of %s: %v", path, err)
+	}
+}
+
+func TestScanStale(t *testing.T) {
+	root, err := ioutil.TempDir("", "janitor-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	known := "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	young := "1111111111111111111111111111111111111111111111111111111111111111"
+	stalePod := filepath.Join(root, "besteffort", "pod"+testPodUID)
+	staleCtr := filepath.Join(stalePod, testContainerID)
+	busyPod := filepath.Join(root, "burstable", "pod89abcdef-0123-4567-89ab-cdef01234567")
+	busyCtr := filepath.Join(busyPod, "2222222222222222222222222222222222222222222222222222222222222222")
+
+	mkdirAged(t, staleCtr, "", time.Hour)
+	mkdirAged(t, filepath.Join(stalePod, known), "", time.Hour)
+	mkdirAged(t, filepath.Join(stalePod, young), "", time.Minute)
+	mkdirAged(t, stalePod, "", time.Hour)
+	mkdirAged(t, busyCtr, "1234\n", time.Hour)
+	mkdirAged(t, busyPod, "", time.Hour)
+
+	entries := scanStale(staleCgroup, root, "cgroup.procs", map[string]struct{}{known: {}})
+
+	stale := map[string]bool{}
+	for _, e := range entries {
+		stale[e.Path] = e.Busy
+	}
+	expected := map[string]bool{
+		stalePod: false,
+		staleCtr: false,
+		busyPod:  true,
+		busyCtr:  true,
+	}
+	if len(stale) != len(expected) {
+		paths := []string{}
+		for path := range stale {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		t.Fatalf("expected %d stale entries, got %d: %v", len(expected), len(stale), paths)
+	}
+	for path, busy := range expected {
+		got, ok := stale[path]
+		if !ok {
+			t.Errorf("%s not reported stale", path)
+			continue
+		}
+		if got != busy {
+			t.Errorf("%s: expected busy %v, got %v", path, busy, got)
+		}
+	}
+}
+
+func TestJanitorHTTPCleanupDisabled(t *testing.T) {
+	saved := opt.JanitorHTTPCleanup
+	defer func() { opt.JanitorHTTPCleanup = saved }()
+	opt.JanitorHTTPCleanup = false
+
+	m := &resmgr{Logger: logger.NewLogger("janitor-test")}
+	rec := httptest.NewRecorder()
+	m.serveJanitor(rec, httptest.NewRequest(http.MethodPost, JanitorPath, nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST with cleanup disabled: expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	m.serveJanitor(rec, httptest.NewRequest(http.MethodDelete, JanitorPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
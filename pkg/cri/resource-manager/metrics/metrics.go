@@ -40,7 +40,9 @@ const (
 
 // Event is a set of metrics events we deliver to be acted upon.
 type Event struct {
-	Avx *AvxEvent // AVX512 container usage changes
+	Avx  *AvxEvent  // AVX512 container usage changes
+	Rdt  *RdtEvent  // RDT class resource usage
+	Perf *PerfEvent // per cgroup LLC misses
 }
 
 // Options describes options for metrics collection and processing.
@@ -61,6 +63,8 @@ type Metrics struct {
 	stop chan interface{}      // channel to stop polling goroutine
 	raw  []*model.MetricFamily // latest set of raw metrics
 	pend []*model.MetricFamily // pending metrics for forwarding
+	rdt  map[string]rdtSample  // last RDT memory bandwidth samples
+	perf map[string]perfSample // last per cgroup LLC miss samples
 }
 
 // Our logger instance.
@@ -152,7 +156,9 @@ func (m *Metrics) process() error {
 	}
 
 	event := &Event{
-		Avx: m.collectAvxEvents(raw),
+		Avx:  m.collectAvxEvents(raw),
+		Rdt:  m.collectRdtEvents(raw),
+		Perf: m.collectPerfEvents(raw),
 	}
 
 	return m.sendEvent(event)
@@ -0,0 +1,93 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+)
+
+const (
+	// CacheLineSize is the number of bytes transferred from memory per LLC miss.
+	CacheLineSize = 64
+)
+
+// PerfEvent describes per cgroup last-level cache miss rates.
+type PerfEvent struct {
+	// Cgroups contains the usage of cgroups, by cgroup path.
+	Cgroups map[string]*PerfCgroupUsage
+}
+
+// PerfCgroupUsage describes the LLC misses and memory bandwidth of a single cgroup.
+type PerfCgroupUsage struct {
+	// LLCMissRate is the number of LLC misses per second.
+	LLCMissRate uint64
+	// MemBandwidth is the memory bandwidth estimated from LLC misses in bytes/second.
+	MemBandwidth uint64
+}
+
+// perfSample is the last LLC miss counter value of a cgroup.
+type perfSample struct {
+	misses uint64
+	stamp  time.Time
+}
+
+func (m *Metrics) collectPerfEvents(raw map[string]*model.MetricFamily) *PerfEvent {
+	llc, ok := raw["cgroup_llc_misses"]
+	if !ok {
+		return nil
+	}
+	dump("LLC misses", llc)
+
+	now := time.Now()
+	prev := m.perf
+	m.perf = map[string]perfSample{}
+
+	usage := map[string]*PerfCgroupUsage{}
+	for _, v := range llc.Metric {
+		cgroup := labelValue(v, "cgroup")
+		misses := uint64(v.Counter.GetValue())
+		m.perf[cgroup] = perfSample{misses: misses, stamp: now}
+
+		p, ok := prev[cgroup]
+		if !ok || misses < p.misses {
+			continue
+		}
+		secs := now.Sub(p.stamp).Seconds()
+		if secs <= 0 {
+			continue
+		}
+		rate := uint64(float64(misses-p.misses) / secs)
+		usage[cgroup] = &PerfCgroupUsage{
+			LLCMissRate:  rate,
+			MemBandwidth: rate * CacheLineSize,
+		}
+		log.Debug(" cgroup %s: LLC miss rate %d/s, memory bandwidth %d bytes/s",
+			cgroup, rate, rate*CacheLineSize)
+	}
+
+	return &PerfEvent{Cgroups: usage}
+}
+
+// labelValue returns the value of the given label of a metric.
+func labelValue(m *model.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"time"
+
+	model "github.com/prometheus/client_model/go"
+)
+
+// RdtEvent describes RDT class LLC occupancy and memory bandwidth usage.
+type RdtEvent struct {
+	// Classes contains the usage of RDT classes, by class name.
+	Classes map[string]*RdtClassUsage
+}
+
+// RdtClassUsage describes the resource usage of a single RDT class.
+type RdtClassUsage struct {
+	// LLCOccupancy is the total L3 cache occupancy of the class in bytes.
+	LLCOccupancy uint64
+	// MemBandwidth is the memory bandwidth used by the class in bytes/second.
+	MemBandwidth uint64
+}
+
+// rdtSample is the last total memory bandwidth counter value of a class.
+type rdtSample struct {
+	bytes uint64
+	stamp time.Time
+}
+
+func (m *Metrics) collectRdtEvents(raw map[string]*model.MetricFamily) *RdtEvent {
+	llc, ok := raw["rdt_class_llc_occupancy"]
+	if !ok {
+		return nil
+	}
+	dump("RDT LLC occupancy", llc)
+
+	usage := map[string]*RdtClassUsage{}
+	for _, v := range llc.Metric {
+		class := rdtClassLabel(v)
+		if usage[class] == nil {
+			usage[class] = &RdtClassUsage{}
+		}
+		usage[class].LLCOccupancy += uint64(v.Gauge.GetValue())
+	}
+
+	if mbm, ok := raw["rdt_class_mbm_total_bytes"]; ok {
+		dump("RDT memory bandwidth", mbm)
+
+		now := time.Now()
+		totals := map[string]uint64{}
+		for _, v := range mbm.Metric {
+			totals[rdtClassLabel(v)] += uint64(v.Counter.GetValue())
+		}
+
+		if m.rdt == nil {
+			m.rdt = map[string]rdtSample{}
+		}
+		for class, bytes := range totals {
+			if prev, ok := m.rdt[class]; ok && bytes >= prev.bytes {
+				if secs := now.Sub(prev.stamp).Seconds(); secs > 0 && usage[class] != nil {
+					usage[class].MemBandwidth = uint64(float64(bytes-prev.bytes) / secs)
+				}
+			}
+			m.rdt[class] = rdtSample{bytes: bytes, stamp: now}
+		}
+	}
+
+	for class, u := range usage {
+		log.Debug(" RDT class %s: LLC occupancy %d, memory bandwidth %d bytes/s",
+			class, u.LLCOccupancy, u.MemBandwidth)
+	}
+
+	return &RdtEvent{Classes: usage}
+}
+
+// rdtClassLabel returns the RDT class label value of a metric.
+func rdtClassLabel(m *model.Metric) string {
+	for _, l := range m.Label {
+		if l.GetName() == "class" {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
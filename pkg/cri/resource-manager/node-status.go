@@ -0,0 +1,95 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"sort"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/agent"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+const (
+	// nodeStatusTimeout is the timeout for publishing our status through the agent.
+	nodeStatusTimeout = 5 * time.Second
+
+	// modeActive is our mode while processing requests with the active policy.
+	modeActive = "active"
+	// modeDegraded is our mode after policy failures, before switching to pass-through.
+	modeDegraded = "degraded"
+	// modePassThrough is our mode while passing requests through without policy processing.
+	modePassThrough = "passthrough"
+)
+
+// exportNodeStatus publishes our policy, mode and memory types as node labels using the agent.
+func (m *resmgr) exportNodeStatus() {
+	if m.agent == nil {
+		return
+	}
+
+	status := &agent.NodeStatus{
+		Policy:      policy.ActivePolicy(),
+		Mode:        m.operatingMode(),
+		MemoryTypes: m.nodeMemoryTypes(),
+	}
+
+	// don't hold up event processing by a slow or missing agent
+	go func() {
+		if err := m.agent.UpdateNodeStatus(status, nodeStatusTimeout); err != nil {
+			m.Warn("%v", err)
+		}
+	}()
+}
+
+// operatingMode returns our current mode of operation.
+func (m *resmgr) operatingMode() string {
+	switch {
+	case m.failsafe.isTripped():
+		return modePassThrough
+	case m.failsafe.isFailing():
+		return modeDegraded
+	}
+	return modeActive
+}
+
+// memoryTypes are the types of memory present in the node, discovered once.
+var memoryTypes []string
+
+// nodeMemoryTypes returns the types of memory present in the node.
+func (m *resmgr) nodeMemoryTypes() []string {
+	if memoryTypes != nil {
+		return memoryTypes
+	}
+
+	sys, err := system.DiscoverSystem()
+	if err != nil {
+		m.Error("failed to discover system memory types: %v", err)
+		return nil
+	}
+
+	found := map[string]struct{}{}
+	for _, id := range sys.NodeIDs() {
+		found[string(sys.Node(id).MemoryType())] = struct{}{}
+	}
+	memoryTypes = make([]string, 0, len(found))
+	for t := range found {
+		memoryTypes = append(memoryTypes, t)
+	}
+	sort.Strings(memoryTypes)
+
+	return memoryTypes
+}
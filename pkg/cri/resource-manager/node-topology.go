@@ -0,0 +1,137 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// nodeTopologyTimeout is the timeout for updating the node resource topology.
+	nodeTopologyTimeout = 5 * time.Second
+)
+
+// Our logger instance for node resource topology exports.
+var nrtlog = logger.NewLogger("node-topology")
+
+// nodeTopology exports node resource topology updates through the agent.
+//
+// Updates are exported in order by a single worker. Updates arriving while an
+// earlier one is being exported are coalesced, only the latest one of them is
+// exported once the worker is done with the earlier one.
+type nodeTopology struct {
+	sync.Mutex
+	export  func(string) error // function for exporting JSON-encoded zones
+	zones   string             // last JSON-encoded zones queued for export
+	pending bool               // whether the last zones are waiting to be exported
+	kick    chan struct{}      // wakes up the export worker
+	stop    chan struct{}      // stops the export worker
+	done    chan struct{}      // closed once the export worker has stopped
+}
+
+// exportNodeTopology updates the node resource topology if the policy zones have changed.
+func (m *resmgr) exportNodeTopology() {
+	if !opt.NodeResourceTopology || m.agent == nil {
+		return
+	}
+
+	zones := m.policy.GetTopologyZones()
+	if len(zones) == 0 {
+		return
+	}
+	data, err := json.Marshal(zones)
+	if err != nil {
+		m.Error("failed to marshal node resource topology: %v", err)
+		return
+	}
+
+	m.topology.update(string(data), func(zones string) error {
+		return m.agent.UpdateNodeResourceTopology(zones, nodeTopologyTimeout)
+	})
+}
+
+// update queues zones for export, unless they are the last ones queued.
+func (t *nodeTopology) update(zones string, export func(string) error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.kick == nil {
+		t.export = export
+		t.kick = make(chan struct{}, 1)
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.run(t.kick, t.stop, t.done)
+	}
+
+	if t.zones == zones {
+		return
+	}
+	t.zones = zones
+	t.pending = true
+
+	select {
+	case t.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run exports queued zones until stopped.
+func (t *nodeTopology) run(kick, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-kick:
+		}
+
+		t.Lock()
+		zones, pending, export := t.zones, t.pending, t.export
+		t.pending = false
+		t.Unlock()
+
+		if !pending {
+			continue
+		}
+
+		// don't hold up request processing by a slow or missing agent
+		if err := export(zones); err != nil {
+			nrtlog.Warn("%v", err)
+			// force a retry with the next update
+			t.Lock()
+			if t.zones == zones && !t.pending {
+				t.zones = ""
+			}
+			t.Unlock()
+		}
+	}
+}
+
+// shutdown stops the export worker, if it was started.
+func (t *nodeTopology) shutdown() {
+	t.Lock()
+	stop, done := t.stop, t.done
+	t.kick, t.stop, t.done = nil, nil, nil
+	t.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeExporter records exported zones, blocking exports until released.
+type fakeExporter struct {
+	sync.Mutex
+	exported []string
+	started  chan string
+	release  chan struct{}
+	fail     bool
+}
+
+func newFakeExporter() *fakeExporter {
+	return &fakeExporter{
+		started: make(chan string, 16),
+		release: make(chan struct{}),
+	}
+}
+
+func (e *fakeExporter) export(zones string) error {
+	e.started <- zones
+	<-e.release
+	e.Lock()
+	defer e.Unlock()
+	if e.fail {
+		return fmt.Errorf("failed to export %s", zones)
+	}
+	e.exported = append(e.exported, zones)
+	return nil
+}
+
+func (e *fakeExporter) get() []string {
+	e.Lock()
+	defer e.Unlock()
+	return append([]string{}, e.exported...)
+}
+
+func waitExported(t *testing.T, e *fakeExporter, count int) []string {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if exported := e.get(); len(exported) >= count {
+			return exported
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d exports, got %v", count, e.get())
+	return nil
+}
+
+func TestNodeTopologyCoalescing(t *testing.T) {
+	e := newFakeExporter()
+	nrt := &nodeTopology{}
+	defer nrt.shutdown()
+
+	// the first update blocks the worker, the rest are coalesced into the last one
+	nrt.update("1", e.export)
+	<-e.started
+	nrt.update("2", e.export)
+	nrt.update("3", e.export)
+	nrt.update("3", e.export)
+	close(e.release)
+
+	exported := waitExported(t, e, 2)
+	time.Sleep(10 * time.Millisecond)
+	exported = e.get()
+
+	expected := []string{"1", "3"}
+	if len(exported) != len(expected) {
+		t.Fatalf("expected exports %v, got %v", expected, exported)
+	}
+	for i := range expected {
+		if exported[i] != expected[i] {
+			t.Errorf("expected exports %v, got %v", expected, exported)
+		}
+	}
+
+	// unchanged zones are not exported again
+	nrt.update("3", e.export)
+	time.Sleep(10 * time.Millisecond)
+	if exported := e.get(); len(exported) != 2 {
+		t.Errorf("unexpected export of unchanged zones: %v", exported)
+	}
+}
+
+func TestNodeTopologyRetry(t *testing.T) {
+	e := newFakeExporter()
+	e.fail = true
+	close(e.release)
+	nrt := &nodeTopology{}
+	defer nrt.shutdown()
+
+	nrt.update("1", e.export)
+	deadline := time.Now().Add(time.Second)
+	for {
+		nrt.Lock()
+		zones := nrt.zones
+		nrt.Unlock()
+		if zones == "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed export was not reset for retry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the same zones are exported again once the agent is back
+	e.Lock()
+	e.fail = false
+	e.Unlock()
+	nrt.update("1", e.export)
+	if exported := waitExported(t, e, 1); exported[0] != "1" {
+		t.Errorf("expected retried export of 1, got %v", exported)
+	}
+}
+
+func TestNodeTopologyShutdown(t *testing.T) {
+	nrt := &nodeTopology{}
+	nrt.shutdown()
+
+	e := newFakeExporter()
+	close(e.release)
+	nrt.update("1", e.export)
+	waitExported(t, e, 1)
+
+	done := make(chan struct{})
+	go func() {
+		nrt.shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("export worker did not stop")
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"strconv"
+
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/metrics"
+)
+
+const (
+	// tagDemotedFrom tags demoted noisy neighbors with their original RDT class.
+	tagDemotedFrom = cache.TagNoisyNeighbor + "/demoted-from"
+)
+
+// noisyOptions captures our configurable noisy neighbor detection.
+type noisyOptions struct {
+	// LLCMissRate is the LLC miss rate (misses/s) above which a container is noisy.
+	LLCMissRate uint64 `json:",omitempty"`
+	// MemBandwidth is the memory bandwidth (bytes/s) above which a container is noisy.
+	MemBandwidth uint64 `json:",omitempty"`
+	// Hysteresis is the fraction (0 - 1) of the limits below which a noisy container calms down.
+	Hysteresis float64 `json:",omitempty"`
+	// Samples is the number of consecutive samples needed to flag or unflag a container.
+	Samples int `json:",omitempty"`
+	// RDTClass is the RDT class to demote noisy neighbors to, if any.
+	RDTClass string `json:",omitempty"`
+	// Rebalance requests rebalancing when containers are flagged or unflagged.
+	Rebalance bool `json:",omitempty"`
+}
+
+// noisyStates is the detection state of containers, by cache ID.
+type noisyStates map[string]*hysteresis
+
+// Our noisy neighbor detection configuration.
+var noisy = defaultNoisyOptions().(*noisyOptions)
+
+// defaultNoisyOptions returns a new noisyOptions instance, all initialized to defaults.
+func defaultNoisyOptions() interface{} {
+	return &noisyOptions{
+		Hysteresis: 0.8,
+		Samples:    3,
+	}
+}
+
+// enabled checks if noisy neighbor detection is enabled.
+func (o *noisyOptions) enabled() bool {
+	return o.LLCMissRate > 0 || o.MemBandwidth > 0
+}
+
+// exceeds checks if the given usage exceeds the limits, scaled by the given factor.
+func (o *noisyOptions) exceeds(usage *metrics.PerfCgroupUsage, scale float64) bool {
+	if o.LLCMissRate > 0 && float64(usage.LLCMissRate) > scale*float64(o.LLCMissRate) {
+		return true
+	}
+	if o.MemBandwidth > 0 && float64(usage.MemBandwidth) > scale*float64(o.MemBandwidth) {
+		return true
+	}
+	return false
+}
+
+// processPerf processes per cgroup LLC miss events, flagging noisy neighbors.
+//
+// A container is flagged as a noisy neighbor once its LLC miss rate or memory
+// bandwidth stays above the configured limits for the configured number of
+// consecutive samples. It is unflagged once both stay below the limits scaled
+// down by the hysteresis factor for the same number of samples. Flagged containers
+// are tagged with cache.TagNoisyNeighbor, for policies to act upon, optionally
+// demoted to a dedicated RDT class, and rebalancing is requested if configured.
+func (m *resmgr) processPerf(e *metrics.PerfEvent) {
+	if e == nil {
+		return
+	}
+
+	usages := map[string]*metrics.PerfCgroupUsage{}
+	for cgroup, usage := range e.Cgroups {
+		c, ok := m.resolveCgroupPath(cgroup)
+		if !ok {
+			continue
+		}
+		c.SetTag(cache.TagLLCMissRate, strconv.FormatUint(usage.LLCMissRate, 10))
+		c.SetTag(cache.TagMemBandwidth, strconv.FormatUint(usage.MemBandwidth, 10))
+		usages[c.GetCacheID()] = usage
+	}
+
+	if m.noisy == nil {
+		m.noisy = make(noisyStates)
+	}
+
+	changed := []cache.Container{}
+	for _, c := range m.cache.GetContainers() {
+		id := c.GetCacheID()
+		usage, ok := usages[id]
+		if !ok || !noisy.enabled() {
+			delete(m.noisy, id)
+			if !noisy.enabled() && m.unflagNoisy(c) {
+				changed = append(changed, c)
+			}
+			continue
+		}
+
+		h, ok := m.noisy[id]
+		if !ok {
+			h = &hysteresis{}
+			m.noisy[id] = h
+		}
+
+		_, flagged := c.GetTag(cache.TagNoisyNeighbor)
+		high := noisy.exceeds(usage, 1.0)
+		low := !noisy.exceeds(usage, noisy.Hysteresis)
+		if !h.update(flagged, high, low, noisy.Samples) {
+			continue
+		}
+		if flagged {
+			m.unflagNoisy(c)
+		} else {
+			m.flagNoisy(c, usage)
+		}
+		changed = append(changed, c)
+	}
+
+	for id := range m.noisy {
+		if _, ok := m.cache.LookupContainer(id); !ok {
+			delete(m.noisy, id)
+		}
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+
+	if err := m.queuePostUpdateHooksFor("NoisyNeighbor", changed); err != nil {
+		m.Error("failed to update noisy neighbors: %v", err)
+	}
+	m.cache.Save()
+
+	if noisy.Rebalance {
+		m.requestRebalance("noisy neighbors")
+	}
+}
+
+// flagNoisy flags a container as a noisy neighbor, demoting it if configured so.
+func (m *resmgr) flagNoisy(c cache.Container, usage *metrics.PerfCgroupUsage) {
+	evtlog.Info("container %s is a noisy neighbor (LLC miss rate %d/s, memory bandwidth %d bytes/s)",
+		c.PrettyName(), usage.LLCMissRate, usage.MemBandwidth)
+
+	c.SetTag(cache.TagNoisyNeighbor, "true")
+	if noisy.RDTClass != "" && c.GetRDTClass() != noisy.RDTClass {
+		c.SetTag(tagDemotedFrom, c.GetRDTClass())
+		c.SetRDTClass(noisy.RDTClass)
+		evtlog.Info("container %s demoted to RDT class %s", c.PrettyName(), noisy.RDTClass)
+	}
+}
+
+// unflagNoisy unflags a noisy neighbor, restoring its RDT class if it was demoted.
+func (m *resmgr) unflagNoisy(c cache.Container) bool {
+	if _, flagged := c.DeleteTag(cache.TagNoisyNeighbor); !flagged {
+		return false
+	}
+
+	evtlog.Info("container %s is no longer a noisy neighbor", c.PrettyName())
+	if class, demoted := c.DeleteTag(tagDemotedFrom); demoted {
+		c.SetRDTClass(class)
+		evtlog.Info("container %s restored to RDT class %q", c.PrettyName(), class)
+	}
+	return true
+}
+
+// validateNoisyOptions checks the noisy neighbor detection configuration.
+func validateNoisyOptions(cfg interface{}) error {
+	o, ok := cfg.(*noisyOptions)
+	if !ok {
+		return resmgrError("invalid noisy neighbor configuration %T", cfg)
+	}
+	if o.Hysteresis <= 0 || o.Hysteresis > 1 {
+		return resmgrError("invalid noisy neighbor hysteresis %f, expecting 0 < h <= 1",
+			o.Hysteresis)
+	}
+	if o.Samples < 1 {
+		return resmgrError("invalid noisy neighbor sample count %d, expecting >= 1", o.Samples)
+	}
+	return nil
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.noisy-neighbors", noisyHelp, noisy, defaultNoisyOptions,
+		config.WithValidator(validateNoisyOptions))
+}
+
+var noisyHelp = `
+Noisy neighbor detection based on per container last-level cache misses.
+
+LLC misses are sampled using per cgroup perf counters, which need to be enabled
+with the --perf-llc-misses command line option. Memory bandwidth is estimated
+from the miss rate. Containers exceeding either limit for Samples consecutive
+metrics polls are tagged as noisy neighbors, and untagged once both stay below
+the limits scaled by Hysteresis for the same number of polls. Noisy neighbors
+can be demoted to a dedicated RDT class, and rebalancing can be requested for
+policies to relocate them. For instance:
+
+  LLCMissRate: 10000000
+  MemBandwidth: 2000000000
+  Hysteresis: 0.8
+  Samples: 3
+  RDTClass: noisy
+  Rebalance: true
+`
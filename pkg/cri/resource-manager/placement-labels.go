@@ -0,0 +1,75 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
+)
+
+const (
+	// LabelPool is the container label for the pool of the container.
+	LabelPool = kubernetes.ResmgrKeyNamespace + "/pool"
+	// LabelCpusetDigest is the container label for the digest of the cpuset of the container.
+	LabelCpusetDigest = kubernetes.ResmgrKeyNamespace + "/cpuset-digest"
+	// LabelRDTClass is the container label for the RDT class of the container.
+	LabelRDTClass = kubernetes.ResmgrKeyNamespace + "/rdt-class"
+	// LabelBlockIOClass is the container label for the block I/O class of the container.
+	LabelBlockIOClass = kubernetes.ResmgrKeyNamespace + "/blockio-class"
+)
+
+// labelPlacement labels a container being created with its placement.
+//
+// The labels end up in the CRI labels of the container, visible for instance
+// with crictl inspect and to log collectors. Since the labels of a container
+// can't be changed once it has been created, they reflect the placement of the
+// container at creation time. The classes are the effective ones the running
+// controllers assign the container to, whether annotated, mapped from the QoS
+// class or given by a unified resource class.
+func (m *resmgr) labelPlacement(c cache.Container) {
+	if !opt.PlacementLabels {
+		return
+	}
+
+	classes := m.control.ContainerClasses(c)
+	labels := map[string]string{
+		LabelCpusetDigest: cpusetDigest(c.GetCpusetCpus(), c.GetCpusetMems()),
+		LabelRDTClass:     classes[cache.RDT],
+		LabelBlockIOClass: classes[cache.BlockIO],
+	}
+	if pool, ok := c.GetTag(cache.TagPool); ok {
+		labels[LabelPool] = pool
+	}
+
+	for key, value := range labels {
+		if value == "" {
+			continue
+		}
+		c.SetLabel(key, value)
+	}
+}
+
+// cpusetDigest returns a short digest of the given cpuset cpus and mems.
+func cpusetDigest(cpus, mems string) string {
+	if cpus == "" && mems == "" {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(cpus + "/" + mems))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+func TestLabelPlacement(t *testing.T) {
+	saved := opt.PlacementLabels
+	defer func() { opt.PlacementLabels = saved }()
+	opt.PlacementLabels = true
+
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+	m.control = &fakeControl{
+		classes: map[string]string{cache.RDT: "gold"},
+	}
+
+	c := createInstance(t, m, cfg, 0)
+	c.SetRDTClass("annotated")
+	c.SetBlockIOClass("annotated")
+	c.SetCpusetCpus("0-3")
+	c.SetCpusetMems("0")
+	c.SetTag(cache.TagPool, "socket #0")
+
+	m.labelPlacement(c)
+
+	for key, expected := range map[string]string{
+		LabelPool:         "socket #0",
+		LabelCpusetDigest: cpusetDigest("0-3", "0"),
+		LabelRDTClass:     "gold",
+	} {
+		if value, _ := c.GetLabel(key); value != expected {
+			t.Errorf("expected label %s=%q, got %q", key, expected, value)
+		}
+	}
+	if value, ok := c.GetLabel(LabelBlockIOClass); ok {
+		t.Errorf("expected no block I/O class label without an effective class, got %q", value)
+	}
+}
+
+func TestCpusetDigest(t *testing.T) {
+	if digest := cpusetDigest("", ""); digest != "" {
+		t.Errorf("expected no digest for an empty cpuset, got %q", digest)
+	}
+	a, b := cpusetDigest("0-3", "0"), cpusetDigest("0-3", "1")
+	if len(a) != 8 || len(b) != 8 {
+		t.Errorf("expected 8-character digests, got %q, %q", a, b)
+	}
+	if a == b {
+		t.Errorf("expected different cpusets to have different digests")
+	}
+	if a != cpusetDigest("0-3", "0") {
+		t.Errorf("expected the digest of a cpuset to be stable")
+	}
+}
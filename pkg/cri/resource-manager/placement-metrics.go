@@ -0,0 +1,144 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+// placementLabels are the labels of the placement info of a container.
+var placementLabels = []string{
+	"container_id", "container", "pod", "namespace", "cpuset", "mems", "rdt_class", "blockio_class",
+}
+
+// placementIDLabels are the labels identifying a container instance.
+var placementIDLabels = []string{"container_id", "container", "pod", "namespace"}
+
+// placementMetricsCollector exports the placement of containers as info-style metrics.
+type placementMetricsCollector struct {
+	sync.Mutex
+	info    *prometheus.GaugeVec
+	changed *prometheus.GaugeVec
+	last    map[string][]string // last exported placement labels, by container cache ID
+}
+
+// Our container placement metrics collector.
+var placementMetrics = newPlacementMetricsCollector()
+
+// newPlacementMetricsCollector creates a new container placement metrics collector.
+func newPlacementMetricsCollector() *placementMetricsCollector {
+	return &placementMetricsCollector{
+		info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cri_resmgr_container_placement_info",
+				Help: "Placement of a container, with its cpuset, memory nodes and classes as labels.",
+			},
+			placementLabels,
+		),
+		changed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cri_resmgr_container_placement_change_timestamp_seconds",
+				Help: "Time of the last change in the placement of a container.",
+			},
+			placementIDLabels,
+		),
+		last: make(map[string][]string),
+	}
+}
+
+// updatePlacementMetrics updates the placement metrics of all containers.
+func (m *resmgr) updatePlacementMetrics() {
+	c := placementMetrics
+	c.Lock()
+	defer c.Unlock()
+
+	now := float64(time.Now().Unix())
+	current := map[string]struct{}{}
+
+	for _, ctr := range m.cache.GetContainers() {
+		id := ctr.GetCacheID()
+		current[id] = struct{}{}
+
+		podName := ""
+		if pod, ok := ctr.GetPod(); ok {
+			podName = pod.GetName()
+		}
+		labels := []string{
+			ctr.GetID(), ctr.GetName(), podName, ctr.GetNamespace(),
+			ctr.GetCpusetCpus(), ctr.GetCpusetMems(), ctr.GetRDTClass(), ctr.GetBlockIOClass(),
+		}
+		last, ok := c.last[id]
+		if ok && equalLabels(last, labels) {
+			continue
+		}
+		if ok {
+			c.info.DeleteLabelValues(last...)
+		}
+		c.info.WithLabelValues(labels...).Set(1)
+		c.changed.WithLabelValues(labels[:len(placementIDLabels)]...).Set(now)
+		c.last[id] = labels
+	}
+
+	for id, last := range c.last {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		c.info.DeleteLabelValues(last...)
+		c.changed.DeleteLabelValues(last[:len(placementIDLabels)]...)
+		delete(c.last, id)
+	}
+}
+
+// equalLabels checks if two label value slices are equal.
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe implements prometheus.Collector.
+func (c *placementMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.info.Describe(ch)
+	c.changed.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *placementMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	c.info.Collect(ch)
+	c.changed.Collect(ch)
+}
+
+func init() {
+	err := metrics.RegisterCollector("placement", func() (prometheus.Collector, error) {
+		return placementMetrics, nil
+	})
+	if err != nil {
+		logger.Get("resource-manager").Error("failed to register placement metrics collector: %v", err)
+	}
+}
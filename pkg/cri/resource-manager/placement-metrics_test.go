@@ -0,0 +1,77 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countSeries returns the number of series exported by a collector.
+func countSeries(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 16)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestUpdatePlacementMetrics(t *testing.T) {
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+
+	saved := placementMetrics
+	defer func() { placementMetrics = saved }()
+	placementMetrics = newPlacementMetricsCollector()
+	c := placementMetrics
+
+	first := createInstance(t, m, cfg, 0)
+	first.SetCpusetCpus("0-1")
+	m.updatePlacementMetrics()
+	if n := countSeries(c.info); n != 1 {
+		t.Errorf("expected 1 placement info series, got %d", n)
+	}
+
+	// a placement change replaces the series of the container
+	first.SetCpusetCpus("2-3")
+	m.updatePlacementMetrics()
+	if n := countSeries(c.info); n != 1 {
+		t.Errorf("expected 1 placement info series after a change, got %d", n)
+	}
+	if last := c.last[first.GetCacheID()]; last[4] != "2-3" {
+		t.Errorf("expected cpuset 2-3 exported, got %v", last)
+	}
+
+	// the restarted instance is exported alongside the old one
+	second := createInstance(t, m, cfg, 1)
+	second.SetCpusetCpus("2-3")
+	m.updatePlacementMetrics()
+	if n := countSeries(c.changed); n != 2 {
+		t.Errorf("expected 2 placement change series, got %d", n)
+	}
+
+	// dropping the old instance keeps the series of the new one
+	m.cache.DeleteContainer(first.GetCacheID())
+	m.updatePlacementMetrics()
+	if n := countSeries(c.info); n != 1 {
+		t.Errorf("expected 1 placement info series, got %d", n)
+	}
+	if n := countSeries(c.changed); n != 1 {
+		t.Errorf("expected 1 placement change series, got %d", n)
+	}
+	if !c.changed.DeleteLabelValues(second.GetID(), "ctr", "pod", "default") {
+		t.Errorf("expected placement change series of %s", second.GetID())
+	}
+}
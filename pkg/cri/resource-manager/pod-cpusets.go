@@ -0,0 +1,189 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// PodCPUSetMount is where the cpuset directory of the pod is mounted in containers.
+	PodCPUSetMount = "/.cri-resmgr-pod"
+	// podCPUSetFile is the JSON file with the cpusets of the pod and its containers.
+	podCPUSetFile = "cpuset.json"
+	// podCPUsFile is the plain text file with the CPUs of the pod.
+	podCPUsFile = "cpus"
+)
+
+// podCPUSet is the exported final cpuset of a pod and its containers.
+type podCPUSet struct {
+	// CPUs is the union of the CPUs of all containers of the pod.
+	CPUs string `json:"cpus"`
+	// Mems is the union of the memory nodes of all containers of the pod.
+	Mems string `json:"mems"`
+	// Containers is the cpusets of the containers of the pod, by container name.
+	Containers map[string]*containerCPUSet `json:"containers"`
+}
+
+// containerCPUSet is the exported final cpuset of a container.
+type containerCPUSet struct {
+	// CPUs is the CPUs of the container.
+	CPUs string `json:"cpus"`
+	// Mems is the memory nodes of the container.
+	Mems string `json:"mems"`
+}
+
+// podCPUSetDir returns the directory for exporting the cpuset of the given pod.
+func podCPUSetDir(pod cache.Pod) string {
+	id := pod.GetUID()
+	if id == "" {
+		id = pod.GetID()
+	}
+	return filepath.Join(opt.PodCPUSetDir, id)
+}
+
+// mountPodCPUSet mounts the cpuset directory of its pod into the container.
+func (m *resmgr) mountPodCPUSet(container cache.Container) {
+	if opt.PodCPUSetDir == "" {
+		return
+	}
+
+	pod, ok := container.GetPod()
+	if !ok {
+		m.Warn("can't mount pod cpuset for %s: failed to look up pod", container.PrettyName())
+		return
+	}
+
+	dir := podCPUSetDir(pod)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		m.Error("failed to create pod cpuset directory %s: %v", dir, err)
+		return
+	}
+
+	container.InsertMount(&cache.Mount{
+		Container:   PodCPUSetMount,
+		Host:        dir,
+		Readonly:    true,
+		Propagation: cache.MountHostToContainer,
+	})
+}
+
+// exportPodCPUSets updates the exported cpusets of all pods which have changed.
+func (m *resmgr) exportPodCPUSets() {
+	if opt.PodCPUSetDir == "" {
+		return
+	}
+	if m.podCPUSets == nil {
+		m.podCPUSets = make(map[string]string)
+	}
+
+	for _, pod := range m.cache.GetPods() {
+		dir := podCPUSetDir(pod)
+		pcs := m.podCPUSet(pod)
+		data, err := json.Marshal(pcs)
+		if err != nil {
+			m.Error("failed to marshal cpuset of pod %s: %v", pod.GetName(), err)
+			continue
+		}
+		if m.podCPUSets[dir] == string(data) {
+			continue
+		}
+
+		if err := writeFileAtomic(dir, podCPUSetFile, append(data, '\n')); err != nil {
+			m.Error("failed to export cpuset of pod %s: %v", pod.GetName(), err)
+			continue
+		}
+		if err := writeFileAtomic(dir, podCPUsFile, []byte(pcs.CPUs+"\n")); err != nil {
+			m.Error("failed to export CPUs of pod %s: %v", pod.GetName(), err)
+			continue
+		}
+
+		m.podCPUSets[dir] = string(data)
+	}
+}
+
+// removePodCPUSet removes the exported cpuset of the given pod.
+func (m *resmgr) removePodCPUSet(pod cache.Pod) {
+	if opt.PodCPUSetDir == "" {
+		return
+	}
+
+	dir := podCPUSetDir(pod)
+	delete(m.podCPUSets, dir)
+	if err := os.RemoveAll(dir); err != nil {
+		m.Warn("failed to remove pod cpuset directory %s: %v", dir, err)
+	}
+}
+
+// podCPUSet collects the cpusets of the pod and its running or created containers.
+func (m *resmgr) podCPUSet(pod cache.Pod) *podCPUSet {
+	cpus, mems := cpuset.NewCPUSet(), cpuset.NewCPUSet()
+	pcs := &podCPUSet{Containers: make(map[string]*containerCPUSet)}
+
+	for _, c := range pod.GetContainers() {
+		switch c.GetState() {
+		case cache.ContainerStateCreated, cache.ContainerStateRunning:
+		default:
+			continue
+		}
+
+		ccs := &containerCPUSet{CPUs: c.GetCpusetCpus(), Mems: c.GetCpusetMems()}
+		pcs.Containers[c.GetName()] = ccs
+
+		if cset, err := cpuset.Parse(ccs.CPUs); err == nil {
+			cpus = cpus.Union(cset)
+		}
+		if mset, err := cpuset.Parse(ccs.Mems); err == nil {
+			mems = mems.Union(mset)
+		}
+	}
+
+	pcs.CPUs = cpus.String()
+	pcs.Mems = mems.String()
+
+	return pcs
+}
+
+// writeFileAtomic replaces a file in the given directory, without exposing partial content.
+func writeFileAtomic(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "."+name+".")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/agent"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// EventExclusiveCPUs is the event reason for granting exclusive CPUs.
+	EventExclusiveCPUs = "ExclusiveCPUs"
+	// EventRDTClassChanged is the event reason for changing the RDT class.
+	EventRDTClassChanged = "RDTClassChanged"
+	// EventMemoryMoved is the event reason for changing the memory nodes.
+	EventMemoryMoved = "MemoryMoved"
+	// EventCPUsPreempted is the event reason for taking exclusive CPUs from a container.
+	EventCPUsPreempted = "CPUsPreempted"
+	// EventResourcePressure is the event reason for acting on sustained resource pressure.
+	EventResourcePressure = "ResourcePressure"
+	// EventResourceUpdateClamped is the event reason for clamping an in-place resource update.
+	EventResourceUpdateClamped = "ResourceUpdateClamped"
+	// EventResourceUpdateRejected is the event reason for rejecting an in-place resource update.
+	EventResourceUpdateRejected = "ResourceUpdateRejected"
+
+	// podEventTimeout is the timeout for emitting a single event.
+	podEventTimeout = 2 * time.Second
+)
+
+// podEventState is the last seen resource assignment of a container.
+type podEventState struct {
+	exclusive cpuset.CPUSet
+	mems      string
+	rdtClass  string
+}
+
+// eventStates is the last seen resource assignments of containers.
+type eventStates map[string]*podEventState
+
+// emitPodEvents emits Kubernetes Events about the resource assignment changes of containers.
+func (m *resmgr) emitPodEvents() {
+	if !opt.PodEvents || m.agent == nil {
+		return
+	}
+	if m.eventState == nil {
+		m.eventState = make(eventStates)
+	}
+
+	containers := []cache.Container{}
+	for _, c := range m.cache.GetContainers() {
+		switch c.GetState() {
+		case cache.ContainerStateExited, cache.ContainerStateStale:
+			continue
+		}
+		containers = append(containers, c)
+	}
+	exclusive := cache.ExclusiveCPUs(containers)
+
+	events := []*agent.PodEvent{}
+	seen := map[string]struct{}{}
+
+	for _, c := range containers {
+		id := c.GetCacheID()
+		seen[id] = struct{}{}
+
+		own, ok := exclusive[id]
+		if !ok {
+			own = cpuset.NewCPUSet()
+		}
+		state := &podEventState{
+			exclusive: own,
+			mems:      c.GetCpusetMems(),
+			rdtClass:  c.GetRDTClass(),
+		}
+
+		old, ok := m.eventState[id]
+		m.eventState[id] = state
+		if !ok {
+			// only report exclusive CPUs for a container we see the first time
+			old = &podEventState{
+				exclusive: cpuset.NewCPUSet(),
+				mems:      state.mems,
+				rdtClass:  state.rdtClass,
+			}
+		}
+
+		if !state.exclusive.IsEmpty() && !state.exclusive.Equals(old.exclusive) {
+			events = append(events, podEvent(c, core_v1.EventTypeNormal, EventExclusiveCPUs,
+				"exclusive CPUs %s assigned to container %s", state.exclusive, c.GetName()))
+		}
+		if state.rdtClass != old.rdtClass {
+			events = append(events, podEvent(c, core_v1.EventTypeNormal, EventRDTClassChanged,
+				"container %s moved to RDT class %q", c.GetName(), state.rdtClass))
+		}
+		if state.mems != old.mems {
+			events = append(events, podEvent(c, core_v1.EventTypeNormal, EventMemoryMoved,
+				"memory of container %s moved to nodes %s", c.GetName(), state.mems))
+		}
+		if lost := old.exclusive.Difference(state.exclusive); !lost.IsEmpty() {
+			events = append(events, podEvent(c, core_v1.EventTypeWarning, EventCPUsPreempted,
+				"exclusive CPUs %s taken from container %s", lost, c.GetName()))
+		}
+	}
+
+	for id := range m.eventState {
+		if _, ok := seen[id]; !ok {
+			delete(m.eventState, id)
+		}
+	}
+
+	m.sendPodEvents(events)
+}
+
+// sendPodEvents emits the given Kubernetes Events asynchronously.
+func (m *resmgr) sendPodEvents(events []*agent.PodEvent) {
+	if len(events) == 0 || m.agent == nil {
+		return
+	}
+
+	// don't hold up request processing by a slow or missing agent
+	go func() {
+		for _, e := range events {
+			if e == nil {
+				continue
+			}
+			if err := m.agent.EmitPodEvent(e, podEventTimeout); err != nil {
+				m.Debug("%v", err)
+			}
+		}
+	}()
+}
+
+// podEvent creates an event for the pod of the given container.
+func podEvent(c cache.Container, eventType, reason, format string, args ...interface{}) *agent.PodEvent {
+	pod, ok := c.GetPod()
+	if !ok {
+		return nil
+	}
+	return &agent.PodEvent{
+		Namespace: pod.GetNamespace(),
+		Name:      pod.GetName(),
+		UID:       pod.GetUID(),
+		Type:      eventType,
+		Reason:    reason,
+		Message:   fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// PolicyDataPath is the HTTP path for inspecting per-container policy data.
+	PolicyDataPath = "/policy-data"
+	// LoggerPath is the HTTP path for inspecting and changing the logger configuration.
+	LoggerPath = "/logger"
+)
+
+// setupPolicyDataEndpoint sets up the HTTP endpoint for inspecting policy data.
+func (m *resmgr) setupPolicyDataEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, policy data inspection disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, PolicyDataPath, http.HandlerFunc(m.servePolicyData))
+
+	return nil
+}
+
+// setupLoggerEndpoint sets up the HTTP endpoint for runtime logger configuration.
+func (m *resmgr) setupLoggerEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, runtime logger configuration disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, LoggerPath, logger.HTTPHandler())
+
+	return nil
+}
+
+// servePolicyData serves the policy-private data of all containers, by container.
+func (m *resmgr) servePolicyData(w http.ResponseWriter, req *http.Request) {
+	m.Lock()
+	data := map[string]map[string]cache.PolicyEntry{}
+	for _, c := range m.cache.GetContainers() {
+		if entries := c.GetPolicyEntries(); len(entries) > 0 {
+			data[c.PrettyName()] = entries
+		}
+	}
+	m.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		m.Error("failed to serve policy data: %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+)
+
+const (
+	// PolicyStatePath is the HTTP path for inspecting and dumping the state of the policy.
+	PolicyStatePath = "/policy-state"
+
+	// policyStateSubdir is where policy state is dumped to by default, within the relay directory.
+	policyStateSubdir = "policy-state"
+	// maxPolicyStateDumps is the number of dumps kept, older ones are removed.
+	maxPolicyStateDumps = 10
+)
+
+// policyStateDump is the result of dumping the state of the policy.
+type policyStateDump struct {
+	JSON  string `json:"json,omitempty"`
+	Dot   string `json:"dot,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// setupPolicyStateEndpoint sets up the HTTP endpoint for inspecting and dumping the policy state.
+func (m *resmgr) setupPolicyStateEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, policy state inspection disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, PolicyStatePath, http.HandlerFunc(m.servePolicyState))
+
+	return nil
+}
+
+// servePolicyState serves the policy state for GET, and dumps it to files for POST requests.
+//
+// For GET the state is served as JSON, or as a graphviz digraph with ?format=dot.
+func (m *resmgr) servePolicyState(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		state, err := m.getPolicyState()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if req.URL.Query().Get("format") == "dot" {
+			w.Header().Set("Content-Type", "text/vnd.graphviz")
+			w.Write(state.Dot())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			m.Error("failed to serve policy state: %v", err)
+		}
+	case http.MethodPost:
+		dump := m.dumpPolicyState()
+		w.Header().Set("Content-Type", "application/json")
+		if dump.Error != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if err := json.NewEncoder(w).Encode(dump); err != nil {
+			m.Error("failed to serve policy state dump: %v", err)
+		}
+	default:
+		http.Error(w, "unsupported method "+req.Method, http.StatusMethodNotAllowed)
+	}
+}
+
+// getPolicyState takes a snapshot of the state of the active policy.
+func (m *resmgr) getPolicyState() (*policy.State, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	state := m.policy.GetState()
+	if state == nil {
+		return nil, resmgrError("policy %s does not support state inspection",
+			policy.ActivePolicy())
+	}
+
+	return state, nil
+}
+
+// dumpPolicyState dumps the state of the active policy into a JSON and a graphviz file.
+func (m *resmgr) dumpPolicyState() *policyStateDump {
+	dump := &policyStateDump{}
+
+	state, err := m.getPolicyState()
+	if err != nil {
+		m.Error("failed to dump policy state: %v", err)
+		dump.Error = err.Error()
+		return dump
+	}
+
+	if err := writePolicyState(state, dump); err != nil {
+		m.Error("failed to dump policy state: %v", err)
+		dump.Error = err.Error()
+		return dump
+	}
+
+	m.Info("dumped policy state to %s and %s", dump.JSON, dump.Dot)
+
+	if err := prunePolicyStates(policyStateDir(), maxPolicyStateDumps); err != nil {
+		m.Warn("failed to remove old policy state dumps: %v", err)
+	}
+
+	return dump
+}
+
+// writePolicyState writes the policy state to files in the policy state directory.
+func writePolicyState(state *policy.State, dump *policyStateDump) error {
+	dir := policyStateDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return resmgrError("failed to create policy state directory %s: %v", dir, err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return resmgrError("failed to marshal policy state: %v", err)
+	}
+
+	base := filepath.Join(dir, state.Policy+"-"+state.Time.Format("20060102-150405.000"))
+	if err := ioutil.WriteFile(base+".json", data, 0600); err != nil {
+		return resmgrError("failed to write policy state: %v", err)
+	}
+	dump.JSON = base + ".json"
+
+	if err := ioutil.WriteFile(base+".dot", state.Dot(), 0600); err != nil {
+		return resmgrError("failed to write policy state: %v", err)
+	}
+	dump.Dot = base + ".dot"
+
+	return nil
+}
+
+// policyStateDir returns the directory policy state is dumped to.
+func policyStateDir() string {
+	if opt.PolicyStateDir != "" {
+		return opt.PolicyStateDir
+	}
+	return filepath.Join(opt.RelayDir, policyStateSubdir)
+}
+
+// prunePolicyStates removes all but the given number of the latest policy state dumps in dir.
+func prunePolicyStates(dir string, keep int) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	latest := map[string]os.FileInfo{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".dot") {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), ext)
+		if other, ok := latest[base]; !ok || e.ModTime().After(other.ModTime()) {
+			latest[base] = e
+		}
+	}
+	if len(latest) <= keep {
+		return nil
+	}
+
+	bases := make([]string, 0, len(latest))
+	for base := range latest {
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		ti, tj := latest[bases[i]].ModTime(), latest[bases[j]].ModTime()
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return bases[i] > bases[j]
+	})
+
+	for _, base := range bases[keep:] {
+		for _, ext := range []string{".json", ".dot"} {
+			path := filepath.Join(dir, base+ext)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPrunePolicyStates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy-state-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	dumps := []string{
+		"topology-aware-20200101-120000.000",
+		"topology-aware-20200101-120001.000",
+		"topology-aware-20200101-120002.000",
+		"static-20200101-120003.000",
+		"topology-aware-20200101-120004.000",
+	}
+	for i, base := range dumps {
+		for _, ext := range []string{".json", ".dot"} {
+			path := filepath.Join(dir, base+ext)
+			if err := ioutil.WriteFile(path, []byte("{}"), 0600); err != nil {
+				t.Fatalf("failed to create %s: %v", path, err)
+			}
+			when := now.Add(time.Duration(i-len(dumps)) * time.Minute)
+			if err := os.Chtimes(path, when, when); err != nil {
+				t.Fatalf("failed to set age of %s: %v", path, err)
+			}
+		}
+	}
+	other := filepath.Join(dir, "README")
+	if err := ioutil.WriteFile(other, []byte("not a dump"), 0600); err != nil {
+		t.Fatalf("failed to create %s: %v", other, err)
+	}
+
+	if err := prunePolicyStates(dir, len(dumps)); err != nil {
+		t.Fatalf("failed to prune policy states: %v", err)
+	}
+	if err := prunePolicyStates(dir, 3); err != nil {
+		t.Fatalf("failed to prune policy states: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", dir, err)
+	}
+	left := []string{}
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	expected := []string{"README"}
+	for _, base := range dumps[2:] {
+		expected = append(expected, base+".json", base+".dot")
+	}
+	sort.Strings(expected)
+	sort.Strings(left)
+	if len(left) != len(expected) {
+		t.Fatalf("expected %v to be left, got %v", expected, left)
+	}
+	for i := range left {
+		if left[i] != expected[i] {
+			t.Errorf("expected %v to be left, got %v", expected, left)
+			break
+		}
+	}
+}
@@ -18,8 +18,9 @@ import (
 	"fmt"
 	"strings"
 
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
@@ -136,6 +137,45 @@ func (eda *eda) ExportResourceData(c cache.Container) map[string]string {
 	return nil
 }
 
+// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+func (eda *eda) DescribeMetrics() []*prometheus.Desc {
+	return nil
+}
+
+// PollMetrics provides policy metrics for monitoring.
+func (eda *eda) PollMetrics() policy.Metrics {
+	return nil
+}
+
+// CollectMetrics generates prometheus metrics from cached/polled policy-specific metrics data.
+func (eda *eda) CollectMetrics(policy.Metrics) ([]prometheus.Metric, error) {
+	return nil, nil
+}
+
+// GetTopologyZones returns the resource topology zones of the node, if known.
+func (eda *eda) GetTopologyZones() []*policy.TopologyZone {
+	return nil
+}
+
+// GetState returns a snapshot of the internal state of the policy.
+func (eda *eda) GetState() *policy.State {
+	return nil
+}
+
+// ReviewResourceUpdate reviews an in-place resource update of a container.
+func (eda *eda) ReviewResourceUpdate(cache.Container, *policy.ResourceUpdate) {
+}
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+func (eda *eda) ReserveResources(*policy.Reservation) error {
+	return policy.ErrReservationsNotSupported
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (eda *eda) ReleaseReservation(string) error {
+	return nil
+}
+
 //
 // Helper functions for STP policy backend
 //
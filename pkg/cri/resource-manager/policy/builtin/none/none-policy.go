@@ -15,6 +15,8 @@
 package none
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
@@ -92,6 +94,45 @@ func (n *none) ExportResourceData(c cache.Container) map[string]string {
 	return nil
 }
 
+// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+func (n *none) DescribeMetrics() []*prometheus.Desc {
+	return nil
+}
+
+// PollMetrics provides policy metrics for monitoring.
+func (n *none) PollMetrics() policy.Metrics {
+	return nil
+}
+
+// CollectMetrics generates prometheus metrics from cached/polled policy-specific metrics data.
+func (n *none) CollectMetrics(policy.Metrics) ([]prometheus.Metric, error) {
+	return nil, nil
+}
+
+// GetTopologyZones returns the resource topology zones of the node, if known.
+func (n *none) GetTopologyZones() []*policy.TopologyZone {
+	return nil
+}
+
+// GetState returns a snapshot of the internal state of the policy.
+func (n *none) GetState() *policy.State {
+	return nil
+}
+
+// ReviewResourceUpdate reviews an in-place resource update of a container.
+func (n *none) ReviewResourceUpdate(cache.Container, *policy.ResourceUpdate) {
+}
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+func (n *none) ReserveResources(*policy.Reservation) error {
+	return policy.ErrReservationsNotSupported
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (n *none) ReleaseReservation(string) error {
+	return nil
+}
+
 // Register us as a policy implementation.
 func init() {
 	policy.Register(PolicyName, PolicyDescription, CreateNonePolicy)
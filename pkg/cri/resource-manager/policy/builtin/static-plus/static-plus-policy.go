@@ -19,11 +19,12 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 
 	"github.com/intel/cri-resource-manager/pkg/cpuallocator"
@@ -199,6 +200,45 @@ func (p *staticplus) ExportResourceData(c cache.Container) map[string]string {
 	return data
 }
 
+// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+func (p *staticplus) DescribeMetrics() []*prometheus.Desc {
+	return nil
+}
+
+// PollMetrics provides policy metrics for monitoring.
+func (p *staticplus) PollMetrics() policy.Metrics {
+	return nil
+}
+
+// CollectMetrics generates prometheus metrics from cached/polled policy-specific metrics data.
+func (p *staticplus) CollectMetrics(policy.Metrics) ([]prometheus.Metric, error) {
+	return nil, nil
+}
+
+// GetTopologyZones returns the resource topology zones of the node, if known.
+func (p *staticplus) GetTopologyZones() []*policy.TopologyZone {
+	return nil
+}
+
+// GetState returns a snapshot of the internal state of the policy.
+func (p *staticplus) GetState() *policy.State {
+	return nil
+}
+
+// ReviewResourceUpdate reviews an in-place resource update of a container.
+func (p *staticplus) ReviewResourceUpdate(cache.Container, *policy.ResourceUpdate) {
+}
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+func (p *staticplus) ReserveResources(*policy.Reservation) error {
+	return policy.ErrReservationsNotSupported
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (p *staticplus) ReleaseReservation(string) error {
+	return nil
+}
+
 // policyError creates a formatted policy-specific error.
 func policyError(format string, args ...interface{}) error {
 	return fmt.Errorf(PolicyName+": "+format, args...)
@@ -25,6 +25,8 @@ import (
 	"strings"
 
 	"github.com/ghodss/yaml"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 )
 
 type cpuList struct {
@@ -187,6 +189,30 @@ func validateCPUList(name string) error {
 	return nil
 }
 
+// validateConf checks that the pools of the configuration have valid,
+// non-overlapping cpu lists
+func validateConf(c interface{}) error {
+	owner := map[int]string{}
+	for name, pool := range c.(*conf).Pools {
+		for _, cl := range pool.CPULists {
+			if err := validateCPUList(cl.Cpuset); err != nil {
+				return fmt.Errorf("invalid cpu list in pool %q: %v", name, err)
+			}
+			cpus, err := cpuset.Parse(cl.Cpuset)
+			if err != nil {
+				return fmt.Errorf("invalid cpu list in pool %q: %v", name, err)
+			}
+			for _, cpu := range cpus.ToSlice() {
+				if other, ok := owner[cpu]; ok && other != name {
+					return fmt.Errorf("cpu %d is in both pool %q and pool %q", cpu, other, name)
+				}
+				owner[cpu] = name
+			}
+		}
+	}
+	return nil
+}
+
 // Convert cpu list configuration directory name into a cpuList
 func parseCPUListName(name string) ([]uint, error) {
 	// The name should be a list of cpu ids (positive integers) separated by commas
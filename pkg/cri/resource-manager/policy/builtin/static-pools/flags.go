@@ -67,5 +67,7 @@ func init() {
 	flag.BoolVar(&opt.createNodeLabel, "static-pools-create-cmk-node-label", false, "Create CMK-related node label for backwards compatibility")
 	flag.BoolVar(&opt.createNodeTaint, "static-pools-create-cmk-node-taint", false, "Create CMK-related node taint for backwards compatibility")
 
-	config.Register(PolicyPath, PolicyDescription, cfg, defaultConfig)
+	config.Register(PolicyPath, PolicyDescription, cfg, defaultConfig,
+		config.WithValidator(validateConf),
+		config.WithFragment())
 }
@@ -22,6 +22,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/intel/cri-resource-manager/pkg/config"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/agent"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
@@ -248,10 +250,49 @@ func (stp *stp) ExportResourceData(c cache.Container) map[string]string {
 	return nil
 }
 
+// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+func (stp *stp) DescribeMetrics() []*prometheus.Desc {
+	return nil
+}
+
+// PollMetrics provides policy metrics for monitoring.
+func (stp *stp) PollMetrics() policy.Metrics {
+	return nil
+}
+
+// CollectMetrics generates prometheus metrics from cached/polled policy-specific metrics data.
+func (stp *stp) CollectMetrics(policy.Metrics) ([]prometheus.Metric, error) {
+	return nil, nil
+}
+
+// GetTopologyZones returns the resource topology zones of the node, if known.
+func (stp *stp) GetTopologyZones() []*policy.TopologyZone {
+	return nil
+}
+
+// GetState returns a snapshot of the internal state of the policy.
+func (stp *stp) GetState() *policy.State {
+	return nil
+}
+
+// ReviewResourceUpdate reviews an in-place resource update of a container.
+func (stp *stp) ReviewResourceUpdate(cache.Container, *policy.ResourceUpdate) {
+}
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+func (stp *stp) ReserveResources(*policy.Reservation) error {
+	return policy.ErrReservationsNotSupported
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (stp *stp) ReleaseReservation(string) error {
+	return nil
+}
+
 func (stp *stp) configNotify(event config.Event, source config.Source) error {
 	stp.Info("configuration %s", event)
 
-	if err := stp.verifyConfig(cfg); err != nil {
+	if err := stp.reconcileConfig(cfg); err != nil {
 		return err
 	}
 
@@ -331,6 +372,110 @@ func (stp *stp) verifyConfig(cfg *conf) error {
 	return nil
 }
 
+// stpMigration is a pending move of a container to new cpu lists
+type stpMigration struct {
+	id     string
+	status stpContainerStatus
+}
+
+// Reconcile existing containers with a new configuration. Containers in
+// non-exclusive pools whose cpu lists are gone are migrated to other cpu
+// lists of their pool. Exclusive containers must find their cpu lists
+// intact, otherwise the configuration is rejected as a whole.
+func (stp *stp) reconcileConfig(cfg *conf) error {
+	if cfg == nil || cfg.Pools == nil {
+		return stpError("invalid config, no pools configured")
+	}
+
+	ccr := stp.getContainerRegistry()
+
+	// Take care of exclusive containers first, they can't be moved.
+	ids := make([]string, 0, len(*ccr))
+	for id, cs := range *ccr {
+		if cs.NExclusiveCPUs > 0 {
+			ids = append([]string{id}, ids...)
+		} else {
+			ids = append(ids, id)
+		}
+	}
+
+	migrations := []stpMigration{}
+	for _, id := range ids {
+		cs := (*ccr)[id]
+		pool, ok := cfg.Pools[cs.Pool]
+		if !ok {
+			return stpError("invalid stp configuration: pool %q for container %q not found", cs.Pool, id)
+		}
+		if pool.Exclusive && cs.NExclusiveCPUs < 1 {
+			return stpError("invalid stp configuration: container %q with no exclusive CPUs set to run in exclusive pool %q", id, cs.Pool)
+		} else if !pool.Exclusive && cs.NExclusiveCPUs > 0 {
+			return stpError("invalid stp configuration: container %q with exclusive CPUs set to run in non-exclusive pool %q", id, cs.Pool)
+		}
+
+		if clists, ok := findCPULists(&pool, cs.Cpusets); ok {
+			if pool.Exclusive {
+				for _, cl := range clists {
+					if len(cl.getContainers()) > 0 {
+						return stpError("invalid stp configuration: cpu list %q of exclusive container %q is shared in pool %q", cl.Cpuset, id, cs.Pool)
+					}
+				}
+			}
+			for _, cl := range clists {
+				cl.addContainer(id)
+			}
+			continue
+		}
+
+		if pool.Exclusive {
+			return stpError("invalid stp configuration: cpu lists %v of exclusive container %q not found in pool %q", cs.Cpusets, id, cs.Pool)
+		}
+
+		available := getAvailableCPULists(cs.Socket, &pool)
+		if len(available) == 0 {
+			return stpError("invalid stp configuration: no cpu list to migrate container %q to in pool %q", id, cs.Pool)
+		}
+		cl := available[rand.Int31n(int32(len(available)))]
+		cl.addContainer(id)
+		cs.Cpusets = []string{cl.Cpuset}
+		migrations = append(migrations, stpMigration{id: id, status: cs})
+	}
+
+	// Configuration is feasible, commit migrations.
+	for _, m := range migrations {
+		(*ccr)[m.id] = m.status
+		c, ok := stp.state.LookupContainer(m.id)
+		if !ok || m.status.NoAffinity {
+			continue
+		}
+		stp.Info("migrating container %q to cpu list %q", m.id, m.status.Cpusets[0])
+		c.SetCpusetCpus(m.status.Cpusets[0])
+	}
+	if len(migrations) > 0 {
+		stp.setContainerRegistry(ccr)
+	}
+
+	return nil
+}
+
+// findCPULists finds the cpu lists of the pool corresponding to the given cpusets
+func findCPULists(pool *poolConfig, cpusets []string) ([]*cpuList, bool) {
+	clists := make([]*cpuList, 0, len(cpusets))
+	for _, cset := range cpusets {
+		found := false
+		for _, cl := range pool.CPULists {
+			if cl.Cpuset == cset {
+				clists = append(clists, cl)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return clists, true
+}
+
 type cmkLegacyArgs struct {
 	Pool       string
 	SocketID   int64
@@ -448,7 +593,7 @@ func (stp *stp) allocateStpResources(c cache.Container, cs stpContainerStatus) e
 		cl.addContainer(containerID)
 		cpuset += sep + cl.Cpuset
 		sep = ","
-		cs.Cpusets = append(cs.Cpusets, cpuset)
+		cs.Cpusets = append(cs.Cpusets, cl.Cpuset)
 	}
 
 	// Commit our changes
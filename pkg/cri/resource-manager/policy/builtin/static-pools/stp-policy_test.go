@@ -85,3 +85,28 @@ func TestCachableData(t *testing.T) {
 		t.Errorf("Exptected %v but got %v", *ccr, *ccr2)
 	}
 }
+
+func TestValidateConf(t *testing.T) {
+	pools := func(lists map[string]string) *conf {
+		c := &conf{Pools: map[string]poolConfig{}}
+		for name, cpus := range lists {
+			c.Pools[name] = poolConfig{CPULists: []*cpuList{{Cpuset: cpus}}}
+		}
+		return c
+	}
+
+	// 1. disjoint pools should be valid
+	if err := validateConf(pools(map[string]string{"exclusive": "0-3", "shared": "4,5"})); err != nil {
+		t.Errorf("Expected <nil> but got %v", err)
+	}
+
+	// 2. malformed cpu lists should be rejected
+	if err := validateConf(pools(map[string]string{"exclusive": "0-3,x"})); err == nil {
+		t.Errorf("Expected error for malformed cpu list but got <nil>")
+	}
+
+	// 3. overlapping pools should be rejected
+	if err := validateConf(pools(map[string]string{"exclusive": "0-3", "shared": "3-5"})); err == nil {
+		t.Errorf("Expected error for overlapping pools but got <nil>")
+	}
+}
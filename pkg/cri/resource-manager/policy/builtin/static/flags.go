@@ -94,5 +94,6 @@ func defaultOptions() interface{} {
 
 // Register us for configuration handling.
 func init() {
-	config.Register(PolicyPath, PolicyDescription, opt, defaultOptions)
+	config.Register(PolicyPath, PolicyDescription, opt, defaultOptions,
+		config.WithFragment())
 }
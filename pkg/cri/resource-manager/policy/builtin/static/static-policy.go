@@ -18,11 +18,12 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
 	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 
 	"github.com/intel/cri-resource-manager/pkg/cpuallocator"
@@ -187,6 +188,45 @@ func (s *static) ExportResourceData(c cache.Container) map[string]string {
 	return data
 }
 
+// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+func (s *static) DescribeMetrics() []*prometheus.Desc {
+	return nil
+}
+
+// PollMetrics provides policy metrics for monitoring.
+func (s *static) PollMetrics() policy.Metrics {
+	return nil
+}
+
+// CollectMetrics generates prometheus metrics from cached/polled policy-specific metrics data.
+func (s *static) CollectMetrics(policy.Metrics) ([]prometheus.Metric, error) {
+	return nil, nil
+}
+
+// GetTopologyZones returns the resource topology zones of the node, if known.
+func (s *static) GetTopologyZones() []*policy.TopologyZone {
+	return nil
+}
+
+// GetState returns a snapshot of the internal state of the policy.
+func (s *static) GetState() *policy.State {
+	return nil
+}
+
+// ReviewResourceUpdate reviews an in-place resource update of a container.
+func (s *static) ReviewResourceUpdate(cache.Container, *policy.ResourceUpdate) {
+}
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+func (s *static) ReserveResources(*policy.Reservation) error {
+	return policy.ErrReservationsNotSupported
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (s *static) ReleaseReservation(string) error {
+	return nil
+}
+
 func (s *static) configNotify(event config.Event, source config.Source) error {
 	s.Info("configuration %s", event)
 
@@ -616,9 +656,13 @@ func (s *static) SetDefaultCPUSet(cset cpuset.CPUSet) {
 	// update cpuset for containers with default assignment
 	ca := s.GetCPUAssignments()
 	for _, id := range s.state.GetContainerCacheIds() {
-		if _, ok := ca[id]; !ok {
-			s.SetCpusetCpus(id, cset.String())
+		if _, ok := ca[id]; ok {
+			continue
+		}
+		if c, ok := s.state.LookupContainer(id); ok && c.IsIgnored() {
+			continue
 		}
+		s.SetCpusetCpus(id, cset.String())
 	}
 }
 
@@ -0,0 +1,88 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// burstIdleUtilization is the fraction of its exclusive CPUs an owner may use and still lend them.
+	burstIdleUtilization = 0.5
+)
+
+// burstCPUs returns the exclusive CPUs a bursting shared container may use.
+//
+// The resource manager tags containers with a CPU pressure spike above the limit
+// of their QoS class to burst. A bursting container without exclusive CPUs of its
+// own gets the idle exclusive CPUs of the other containers in or below its pool
+// added to its cpuset. Exclusive CPUs are idle if their owner has used at most
+// half of them on average since the previous cgroup statistics sample, and it is
+// not tagged to reclaim its CPUs. Isolated CPUs are never lent out.
+func (p *policy) burstCPUs(grant CPUGrant) cpuset.CPUSet {
+	container := grant.GetContainer()
+	if _, ok := container.GetTag(cache.TagCPUBurst); !ok || !grant.ExclusiveCPUs().IsEmpty() {
+		return cpuset.NewCPUSet()
+	}
+
+	pool := grant.GetNode()
+	lent := cpuset.NewCPUSet()
+	for _, other := range p.allocations.CPU {
+		exclusive := other.ExclusiveCPUs().Difference(p.isolated)
+		if exclusive.IsEmpty() || !isSubtreeNode(other.GetNode(), pool) {
+			continue
+		}
+		if _, ok := other.GetContainer().GetTag(cache.TagCPUReclaim); ok {
+			log.Debug("  => %s reclaims its CPUs %s", other.GetContainer().PrettyName(), exclusive)
+			continue
+		}
+		if !p.idleCPUs(other) {
+			log.Debug("  => %s is using its CPUs %s", other.GetContainer().PrettyName(), exclusive)
+			continue
+		}
+		lent = lent.Union(exclusive)
+	}
+
+	if !lent.IsEmpty() {
+		log.Debug("  => %s bursting onto exclusive CPUs %s", container.PrettyName(), lent)
+	}
+
+	return lent
+}
+
+// idleCPUs checks if the owner of exclusive CPUs uses little enough of them to lend them.
+//
+// Without cgroup statistics of the owner its CPUs are never considered idle.
+func (p *policy) idleCPUs(grant CPUGrant) bool {
+	if p.options.Stats == nil {
+		return false
+	}
+	stats, ok := p.options.Stats.Stats(grant.GetContainer().GetID())
+	if !ok || stats.Interval == 0 {
+		return false
+	}
+	return stats.CPUUtilization <= burstIdleUtilization*float64(grant.ExclusiveCPUs().Size())
+}
+
+// isSubtreeNode checks if a node is the given pool or one of its descendants.
+func isSubtreeNode(node, pool Node) bool {
+	for n := node; !n.IsNil(); n = n.Parent() {
+		if n.IsSameNode(pool) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/stats"
+)
+
+// fakeStats provides the CPU utilization of containers, by container ID.
+type fakeStats map[string]float64
+
+func (f fakeStats) Stats(id string) (*stats.Stats, bool) {
+	cpu, ok := f[id]
+	if !ok {
+		return nil, false
+	}
+	return &stats.Stats{Interval: 10 * time.Second, CPUUtilization: cpu}, true
+}
+
+func TestBurstCPUs(t *testing.T) {
+	root := &node{name: "root", id: 0, kind: VirtualNode, parent: nilnode}
+	numa0 := &node{name: "numa node #0", id: 1, kind: NumaNode, parent: root}
+	numa1 := &node{name: "numa node #1", id: 2, kind: NumaNode, parent: root}
+
+	p := &policy{
+		options: policyapi.BackendOptions{
+			Stats: fakeStats{
+				"owner0":    0.2,
+				"owner1":    0.2,
+				"owner2":    1.0,
+				"isolated":  0,
+				"busy":      1.5,
+				"exclusive": 0.5,
+			},
+		},
+		isolated:    cpuset.NewCPUSet(15),
+		allocations: allocations{CPU: map[string]CPUGrant{}},
+	}
+	grant := func(id string, n Node, exclusive cpuset.CPUSet, portion int, tags ...string) CPUGrant {
+		c := &mockContainer{name: id, returnValueForGetCacheID: id, tags: map[string]string{}}
+		for _, tag := range tags {
+			c.tags[tag] = "10.00%"
+		}
+		g := newCPUGrant(n, c, exclusive, portion)
+		p.allocations.CPU[id] = g
+		return g
+	}
+
+	grant("owner0", numa0, cpuset.NewCPUSet(2, 3), 0)
+	grant("owner1", numa0, cpuset.NewCPUSet(4, 5), 0, cache.TagCPUReclaim)
+	grant("owner2", numa1, cpuset.NewCPUSet(10, 11), 0)
+	grant("isolated", numa1, cpuset.NewCPUSet(15), 0)
+	grant("busy", numa0, cpuset.NewCPUSet(7, 8), 0)
+	grant("unsampled", numa0, cpuset.NewCPUSet(9), 0)
+	bursting0 := grant("bursting0", numa0, cpuset.NewCPUSet(), 500, cache.TagCPUBurst)
+	bursting1 := grant("bursting1", root, cpuset.NewCPUSet(), 500, cache.TagCPUBurst)
+	calm := grant("calm", numa0, cpuset.NewCPUSet(), 500)
+	exclusive := grant("exclusive", numa0, cpuset.NewCPUSet(6), 0, cache.TagCPUBurst)
+
+	tcases := []struct {
+		name     string
+		grant    CPUGrant
+		expected cpuset.CPUSet
+	}{
+		{
+			name:     "bursting in a NUMA node",
+			grant:    bursting0,
+			expected: cpuset.NewCPUSet(2, 3, 6),
+		},
+		{
+			name:     "bursting in the root",
+			grant:    bursting1,
+			expected: cpuset.NewCPUSet(2, 3, 6, 10, 11),
+		},
+		{
+			name:     "not bursting",
+			grant:    calm,
+			expected: cpuset.NewCPUSet(),
+		},
+		{
+			name:     "bursting with exclusive CPUs",
+			grant:    exclusive,
+			expected: cpuset.NewCPUSet(),
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if cpus := p.burstCPUs(tc.grant); !cpus.Equals(tc.expected) {
+				t.Errorf("expected burst CPUs %s, got %s", tc.expected, cpus)
+			}
+		})
+	}
+}
@@ -16,13 +16,15 @@ package topologyaware
 
 import (
 	"encoding/json"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 )
 
 const (
-	keyAllocations = "allocations"
-	keyConfig      = "config"
+	keyAllocations  = "allocations"
+	keyConfig       = "config"
+	keyPlacements   = "placements"
+	keyReservations = "reservations"
 )
 
 func (p *policy) saveAllocations() {
@@ -34,6 +36,22 @@ func (p *policy) restoreAllocations() bool {
 	return p.cache.GetPolicyEntry(keyAllocations, &p.allocations)
 }
 
+func (p *policy) savePlacements() {
+	p.cache.SetPolicyEntry(keyPlacements, cache.Cachable(&p.placements))
+}
+
+func (p *policy) restorePlacements() bool {
+	return p.cache.GetPolicyEntry(keyPlacements, &p.placements)
+}
+
+func (p *policy) saveReservations() {
+	p.cache.SetPolicyEntry(keyReservations, cache.Cachable(&p.podReserved))
+}
+
+func (p *policy) restoreReservations() bool {
+	return p.cache.GetPolicyEntry(keyReservations, &p.podReserved)
+}
+
 func (p *policy) saveConfig() error {
 	cached := cachedOptions{Options: *opt}
 	p.cache.SetPolicyEntry(keyConfig, cache.Cachable(&cached))
@@ -18,7 +18,7 @@ import (
 	"bytes"
 	"testing"
 
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 )
 
 func TestToCPUGrant(t *testing.T) {
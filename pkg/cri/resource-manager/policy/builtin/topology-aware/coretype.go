@@ -0,0 +1,101 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// coreTypeCPUs returns the CPUs of the given core type in a cpuset.
+//
+// The result is empty if no core type is given, or on systems with a single
+// type of cores.
+func coreTypeCPUs(sys system.System, cpus cpuset.CPUSet, coreType system.CoreType) cpuset.CPUSet {
+	if coreType == "" {
+		return cpuset.NewCPUSet()
+	}
+	return cpus.Intersection(sys.CoreTypeCPUs(coreType))
+}
+
+// takeSharable slices cnt exclusive CPUs off the sharable set of a supply.
+// CPUs of the given core type are used if there are enough of them.
+func (cs *cpuSupply) takeSharable(cnt int, coreType system.CoreType) (cpuset.CPUSet, error) {
+	preferred := coreTypeCPUs(cs.node.System(), cs.sharable, coreType)
+	if preferred.Size() < cnt {
+		return takeCPUs(&cs.sharable, nil, cnt)
+	}
+
+	exclusive, err := takeCPUs(&preferred, nil, cnt)
+	if err != nil {
+		return exclusive, err
+	}
+	cs.sharable = cs.sharable.Difference(exclusive)
+	log.Debug("  => sliced %s cores %s off %s", coreType, exclusive, cs.node.Name())
+
+	return exclusive, nil
+}
+
+// sharableFullCores returns the full cores to slice off the sharable set of a supply.
+// Full cores of the given core type are used if there are enough of them.
+func (cs *cpuSupply) sharableFullCores(cnt int, coreType system.CoreType) cpuset.CPUSet {
+	preferred := coreTypeCPUs(cs.node.System(), cs.sharable, coreType)
+	if cores := fullCores(cs.node.System(), preferred, cnt); !cores.IsEmpty() {
+		return cores
+	}
+	return fullCores(cs.node.System(), cs.sharable, cnt)
+}
+
+// sharedCoreTypeCPUs narrows the shared CPUs of a grant to the preferred core type of its container.
+//
+// Shared CPUs are left intact if the container has no core type preference, if
+// none of them is of the preferred type, or if the shared portions of all the
+// containers of the pool preferring the same type would oversubscribe the CPUs
+// of that type.
+func (p *policy) sharedCoreTypeCPUs(grant CPUGrant, shared cpuset.CPUSet) cpuset.CPUSet {
+	coreType := containerCoreType(grant.GetContainer())
+	preferred := coreTypeCPUs(p.sys, shared, coreType)
+	if preferred.IsEmpty() {
+		return shared
+	}
+
+	demand := 0
+	for _, other := range p.allocations.CPU {
+		if !other.GetNode().IsSameNode(grant.GetNode()) {
+			continue
+		}
+		if containerCoreType(other.GetContainer()) == coreType {
+			demand += other.SharedPortion()
+		}
+	}
+	if demand > 1000*preferred.Size() {
+		log.Debug("  => shared %s cores %s oversubscribed (%d mCPU), using %s",
+			coreType, preferred, demand, shared)
+		return shared
+	}
+
+	log.Debug("  => using shared %s cores %s of %s", coreType, preferred, shared)
+	return preferred
+}
+
+// containerCoreType returns the preferred core type of a container, if any.
+func containerCoreType(container cache.Container) system.CoreType {
+	pod, ok := container.GetPod()
+	if !ok {
+		return ""
+	}
+	return podCoreTypePreference(pod, container)
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+func TestSharedCoreTypeCPUs(t *testing.T) {
+	root := &node{name: "root", id: 0, kind: VirtualNode, parent: nilnode}
+	numa0 := &node{name: "numa node #0", id: 1, kind: NumaNode, parent: root}
+	numa1 := &node{name: "numa node #1", id: 2, kind: NumaNode, parent: root}
+
+	p := &policy{
+		sys: &mockSystem{
+			coreTypes: map[system.CoreType]cpuset.CPUSet{
+				system.CoreTypePerformance: cpuset.NewCPUSet(0, 1),
+				system.CoreTypeEfficient:   cpuset.NewCPUSet(2, 3, 4, 5),
+			},
+		},
+		allocations: allocations{CPU: map[string]CPUGrant{}},
+	}
+	grant := func(id string, n Node, portion int, coreType string) CPUGrant {
+		c := &mockContainer{name: id, returnValueForGetCacheID: id}
+		if coreType != "" {
+			c.pod = &mockPod{annotations: map[string]string{keyCoreTypePreference: coreType}}
+		}
+		g := newCPUGrant(n, c, cpuset.NewCPUSet(), portion)
+		p.allocations.CPU[id] = g
+		return g
+	}
+
+	shared := cpuset.NewCPUSet(0, 1, 2, 3, 4, 5)
+	pcores := cpuset.NewCPUSet(0, 1)
+
+	first := grant("first", numa0, 500, "p")
+	other := grant("other", numa1, 2000, "p")
+	none := grant("none", numa0, 2000, "")
+	grant("second", numa0, 1000, "p")
+	grant("efficient", numa0, 1000, "e")
+
+	for _, tc := range []struct {
+		name     string
+		grant    CPUGrant
+		expected cpuset.CPUSet
+	}{
+		{name: "preferred cores fit", grant: first, expected: pcores},
+		{name: "preferred cores in other pool", grant: other, expected: pcores},
+		{name: "no preference", grant: none, expected: shared},
+	} {
+		if cpus := p.sharedCoreTypeCPUs(tc.grant, shared); !cpus.Equals(tc.expected) {
+			t.Errorf("%s: expected shared CPUs %s, got %s", tc.name, tc.expected, cpus)
+		}
+	}
+
+	// another container preferring P-cores oversubscribes them
+	grant("third", numa0, 1000, "p")
+	if cpus := p.sharedCoreTypeCPUs(first, shared); !cpus.Equals(shared) {
+		t.Errorf("expected all shared CPUs for oversubscribed P-cores, got %s", cpus)
+	}
+	if cpus := p.sharedCoreTypeCPUs(other, shared); !cpus.Equals(pcores) {
+		t.Errorf("expected P-cores in a pool with no other P-core containers, got %s", cpus)
+	}
+}
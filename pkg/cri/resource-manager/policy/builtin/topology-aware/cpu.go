@@ -18,8 +18,10 @@ import (
 	"fmt"
 
 	"github.com/intel/cri-resource-manager/pkg/cpuallocator"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 )
 
 // CPUSupply represents avaialbe CPU capacity of a node.
@@ -63,6 +65,8 @@ type CPURequest interface {
 	CPUFraction() int
 	// Isolate returns whether isolated CPUs are preferred for this request.
 	Isolate() bool
+	// FullCores returns whether exclusive CPUs should be allocated as full physical cores.
+	FullCores() bool
 	// Elevate returns the requested elevation/allocation displacement for this request.
 	Elevate() int
 }
@@ -118,6 +122,8 @@ type cpuRequest struct {
 	full      int             // number of full CPUs requested
 	fraction  int             // amount of fractional CPU requested
 	isolate   bool            // prefer isolated exclusive CPUs
+	fullCores bool            // allocate exclusive CPUs as full physical cores
+	coreType  system.CoreType // preferred type of cores, on hybrid systems
 
 	// elevate indicates how much to elevate the actual allocation of the
 	// container in the tree of pools. Or in other words how many levels to
@@ -229,7 +235,7 @@ func (cs *cpuSupply) Allocate(r CPURequest) (CPUGrant, error) {
 
 	// allocate isolated exclusive CPUs or slice them off the sharable set
 	switch {
-	case cr.full > 0 && cs.isolated.Size() >= cr.full:
+	case cr.full > 0 && cr.isolate && cs.isolated.Size() >= cr.full:
 		exclusive, err = takeCPUs(&cs.isolated, nil, cr.full)
 		if err != nil {
 			return nil, policyError("internal error: "+
@@ -237,8 +243,13 @@ func (cs *cpuSupply) Allocate(r CPURequest) (CPUGrant, error) {
 				cr.full, cs.isolated, cs.node.Name())
 		}
 
-	case cr.full > 0 && (1000*cs.sharable.Size()-cs.granted)/1000 > cr.full:
-		exclusive, err = takeCPUs(&cs.sharable, nil, cr.full)
+	case cr.full > 0 && cr.fullCores && cs.fitsFullCores(cr.full):
+		exclusive = cs.sharableFullCores(cr.full, cr.coreType)
+		cs.sharable = cs.sharable.Difference(exclusive)
+		log.Debug("  => sliced full cores %s off %s for %d CPUs", exclusive, cs.node.Name(), cr.full)
+
+	case cr.full > 0 && cs.slicable()/1000 > cr.full:
+		exclusive, err = cs.takeSharable(cr.full, cr.coreType)
 		if err != nil {
 			return nil, policyError("internal error: "+
 				"can't slice %d exclusive CPUs from %s(-%d) of %s",
@@ -281,6 +292,20 @@ func (cs *cpuSupply) Release(g CPUGrant) {
 	})
 }
 
+// fitsFullCores checks if cnt CPUs can be sliced off the sharable set as full cores.
+func (cs *cpuSupply) fitsFullCores(cnt int) bool {
+	cores := fullCores(cs.node.System(), cs.sharable, cnt)
+	if cores.IsEmpty() {
+		return false
+	}
+	return cs.slicable()/1000 > cores.Size()
+}
+
+// slicable returns the sharable capacity available for slicing off exclusive CPUs.
+func (cs *cpuSupply) slicable() int {
+	return 1000*cs.sharable.Size() - cs.granted - getPoolHeadroom(cs.node)
+}
+
 // String returns the CPU supply as a string.
 func (cs *cpuSupply) String() string {
 	none, isolated, sharable, sep := "-", "", "", ""
@@ -303,12 +328,22 @@ func (cs *cpuSupply) String() string {
 func newCPURequest(container cache.Container) CPURequest {
 	pod, _ := container.GetPod()
 	full, fraction, isolate, elevate := cpuAllocationPreferences(pod, container)
+	smt := full > 0 && !isolate && podSMTIsolationPreference(pod, container)
+
+	if rc := policyapi.GetRuntimeClassOptions(container); rc != nil && rc.FullCores {
+		if fraction > 0 {
+			full, fraction = full+1, 0
+		}
+		smt = full > 0 && !isolate
+	}
 
 	return &cpuRequest{
 		container: container,
 		full:      full,
 		fraction:  fraction,
 		isolate:   isolate,
+		fullCores: smt,
+		coreType:  podCoreTypePreference(pod, container),
 		elevate:   elevate,
 	}
 }
@@ -321,6 +356,9 @@ func (cr *cpuRequest) GetContainer() cache.Container {
 // String returns aprintable representation of the CPU request.
 func (cr *cpuRequest) String() string {
 	isolated := map[bool]string{false: "", true: "isolated "}[cr.isolate]
+	if cr.fullCores {
+		isolated = "full-core "
+	}
 	switch {
 	case cr.full == 0 && cr.fraction == 0:
 		return fmt.Sprintf("<CPU request " + cr.container.PrettyName() + ": ->")
@@ -354,6 +392,11 @@ func (cr *cpuRequest) Isolate() bool {
 	return cr.isolate
 }
 
+// FullCores returns whether exclusive CPUs should be allocated as full physical cores.
+func (cr *cpuRequest) FullCores() bool {
+	return cr.fullCores
+}
+
 // Elevate returns the requested elevation/allocation displacement for this request.
 func (cr *cpuRequest) Elevate() int {
 	return cr.elevate
@@ -382,7 +425,14 @@ func (cs *cpuSupply) GetScore(request CPURequest) CPUScore {
 
 	// if we don't want isolated or there is not enough, calculate slicable capacity
 	if !cr.isolate || score.isolated < 0 {
-		score.shared -= 1000 * full
+		if cr.fullCores && cs.fitsFullCores(full) {
+			score.shared -= 1000 * fullCores(cs.node.System(), cs.sharable, full).Size()
+		} else {
+			score.shared -= 1000 * full
+		}
+		if full > 0 {
+			score.shared -= getPoolHeadroom(cs.node)
+		}
 	}
 
 	// calculate fractional capacity
@@ -519,9 +569,12 @@ func (cg *cpuGrant) String() string {
 		cg.container.PrettyName(), cg.node.Name(), isolated, exclusive, shared)
 }
 
+// allocateCpus picks CPUs using the host topology, overridable for tests.
+var allocateCpus = cpuallocator.AllocateCpus
+
 // takeCPUs takes up to cnt CPUs from a given CPU set to another.
 func takeCPUs(from, to *cpuset.CPUSet, cnt int) (cpuset.CPUSet, error) {
-	cset, err := cpuallocator.AllocateCpus(from, cnt, true)
+	cset, err := allocateCpus(from, cnt, true)
 	if err != nil {
 		return cset, err
 	}
@@ -532,3 +585,30 @@ func takeCPUs(from, to *cpuset.CPUSet, cnt int) (cpuset.CPUSet, error) {
 
 	return cset, err
 }
+
+// fullCores returns the CPUs of the full physical cores in from needed to cover cnt CPUs.
+// Only cores with all their hyperthreads in from are considered. If there are not enough
+// such cores to cover cnt CPUs, an empty set is returned.
+func fullCores(sys system.System, from cpuset.CPUSet, cnt int) cpuset.CPUSet {
+	known := sys.CPUSet()
+	cores := cpuset.NewCPUSet()
+	for _, id := range from.ToSlice() {
+		if cores.Size() >= cnt {
+			break
+		}
+		if cores.Contains(id) || !known.Contains(id) {
+			continue
+		}
+		threads := sys.CPU(system.ID(id)).ThreadCPUSet()
+		if !threads.IsSubsetOf(from) {
+			continue
+		}
+		cores = cores.Union(threads)
+	}
+
+	if cores.Size() < cnt {
+		return cpuset.NewCPUSet()
+	}
+
+	return cores
+}
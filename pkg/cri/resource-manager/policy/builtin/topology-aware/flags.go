@@ -15,6 +15,10 @@
 package topologyaware
 
 import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	config "github.com/intel/cri-resource-manager/pkg/config"
 	"github.com/intel/cri-resource-manager/pkg/topology"
 )
@@ -25,12 +29,95 @@ type options struct {
 	PinCPU bool
 	// PinMemory controls memory pinning in the topology-aware policy.
 	PinMemory bool
-	// PreferIsolated controls whether isolated CPUs are preferred for isolated allocations.
+	// PreferIsolated controls whether isolated CPUs can be granted to annotated containers.
 	PreferIsolated bool `json:"PreferIsolatedCPUs"`
 	// PreferShared controls whether shared CPU allocation is always preferred by default.
 	PreferShared bool `json:"PreferSharedCPUs"`
+	// PreferSMTIsolation controls whether exclusive CPUs are allocated as full cores by default.
+	PreferSMTIsolation bool `json:"PreferSMTIsolation"`
 	// FakeHints are the set of fake TopologyHints to use for testing purposes.
 	FakeHints fakehints `json:",omitempty"`
+	// MemBandwidth is the memory bandwidth capacity of pools in MB/s, by pool name.
+	MemBandwidth map[string]uint64 `json:"MemoryBandwidthCapacity,omitempty"`
+	// DiePools controls whether the dies of multi-die packages are modelled as pools.
+	DiePools bool `json:",omitempty"`
+	// CollapsePools omits sockets and dies with a single NUMA node from the pool tree.
+	CollapsePools bool `json:",omitempty"`
+	// LLCPools controls whether last-level caches splitting a NUMA node are modelled as pools.
+	LLCPools bool `json:",omitempty"`
+	// LLCPartitionClass is the RDT class for containers with an exclusive LLC partition.
+	LLCPartitionClass string `json:",omitempty"`
+	// ScoringWeights are the weights of the pool scoring heuristics.
+	ScoringWeights scoringWeights `json:",omitempty"`
+	// ScaleSharedCPUShares scales CPU shares of shared allocations to the size of the pool.
+	ScaleSharedCPUShares bool `json:",omitempty"`
+	// PoolLimits are the container count and churn limits of pools, by pool name.
+	PoolLimits map[string]*poolLimits `json:",omitempty"`
+	// PoolHeadroom is the sharable CPU capacity kept out of exclusive allocation, by pool name.
+	PoolHeadroom map[string]poolHeadroom `json:",omitempty"`
+	// StaticCPUNamespaces are the namespaces with kubelet static CPU manager semantics, * for all.
+	StaticCPUNamespaces []string `json:",omitempty"`
+	// MemoryOnlyNodes controls whether memory-only nodes are included in the memory of all containers.
+	MemoryOnlyNodes bool `json:",omitempty"`
+	// PlacementStrategy is the default strategy for picking pools: spread or pack.
+	PlacementStrategy placementStrategy `json:",omitempty"`
+	// NamespacePlacementStrategy overrides the default placement strategy, by namespace.
+	NamespacePlacementStrategy map[string]placementStrategy `json:",omitempty"`
+	// PowerAwarePlacement steers power-heavy containers to packages with more power headroom.
+	PowerAwarePlacement bool `json:",omitempty"`
+	// PowerHeavyCPUs is the CPU request of containers considered power-heavy.
+	PowerHeavyCPUs int `json:",omitempty"`
+	// ReservedPoolNamespaces are the namespace globs or /regexps/ of containers placed in the reserved pool.
+	ReservedPoolNamespaces []string `json:",omitempty"`
+	// CoreTypePlacement places Guaranteed containers on performance and BestEffort ones on efficient cores.
+	CoreTypePlacement bool `json:",omitempty"`
+	// StickyPlacementTTL is how long the NUMA nodes last used by containers are remembered, empty to disable.
+	StickyPlacementTTL string `json:",omitempty"`
+}
+
+// placementStrategy is the strategy for picking a pool among equally good ones.
+type placementStrategy string
+
+const (
+	// strategySpread prefers pools with more free capacity and fewer containers.
+	strategySpread placementStrategy = "spread"
+	// strategyPack prefers pools with less free capacity and more containers.
+	strategyPack placementStrategy = "pack"
+)
+
+// validate checks that a placement strategy is known.
+func (s placementStrategy) validate() error {
+	switch s {
+	case "", strategySpread, strategyPack:
+		return nil
+	}
+	return policyError("invalid placement strategy %q, expected %q or %q",
+		s, strategySpread, strategyPack)
+}
+
+// scoringWeights are the weights of the pool scoring heuristics.
+//
+// With all weights zero, pools are sorted by strict precedence of the heuristics.
+// Otherwise pools are sorted by the weighted sum of the heuristics, each one
+// normalized across all pools, falling back to strict precedence for a tie.
+type scoringWeights struct {
+	// Affinity is the weight of container affinity to the containers in the pool.
+	Affinity float64 `json:",omitempty"`
+	// HintMatch is the weight of matching topology hints, for instance device locality.
+	HintMatch float64 `json:",omitempty"`
+	// Depth is the weight of the depth of the pool in the tree, favoring smaller pools.
+	Depth float64 `json:",omitempty"`
+	// MemoryBandwidth is the weight of memory bandwidth headroom of the pool.
+	MemoryBandwidth float64 `json:",omitempty"`
+	// FreeCapacity is the weight of free CPU capacity, negative to prefer packing.
+	FreeCapacity float64 `json:",omitempty"`
+	// Disturbance is the weight of the number of containers sharing the pool, as a penalty.
+	Disturbance float64 `json:",omitempty"`
+}
+
+// enabled checks if any scoring weights are set.
+func (w scoringWeights) enabled() bool {
+	return w != scoringWeights{}
 }
 
 // Our runtime configuration.
@@ -59,10 +146,50 @@ func defaultOptions() interface{} {
 		PreferIsolated: true,
 		PreferShared:   false,
 		FakeHints:      make(fakehints),
+		MemBandwidth:   make(map[string]uint64),
+		PoolLimits:     make(map[string]*poolLimits),
+		PoolHeadroom:   make(map[string]poolHeadroom),
+
+		NamespacePlacementStrategy: make(map[string]placementStrategy),
+
+		PreferSMTIsolation: false,
+		LLCPartitionClass:  "exclusive-llc",
+		PowerHeavyCPUs:     2,
+
+		ReservedPoolNamespaces: []string{metav1.NamespaceSystem},
 	}
 }
 
 // Register us for configuration handling.
 func init() {
-	config.Register(PolicyPath, PolicyDescription, opt, defaultOptions)
+	config.Register(PolicyPath, PolicyDescription, opt, defaultOptions,
+		config.WithValidator(validateOptions),
+		config.WithFragment())
+}
+
+// validateOptions checks the configured placement strategies.
+func validateOptions(o interface{}) error {
+	cfg, ok := o.(*options)
+	if !ok {
+		return policyError("invalid topology-aware configuration %T", o)
+	}
+	if err := cfg.PlacementStrategy.validate(); err != nil {
+		return err
+	}
+	for ns, s := range cfg.NamespacePlacementStrategy {
+		if err := s.validate(); err != nil {
+			return policyError("namespace %s: %v", ns, err)
+		}
+	}
+	if cfg.StickyPlacementTTL != "" {
+		if _, err := time.ParseDuration(cfg.StickyPlacementTTL); err != nil {
+			return policyError("invalid sticky placement TTL %q: %v", cfg.StickyPlacementTTL, err)
+		}
+	}
+	for _, pattern := range cfg.ReservedPoolNamespaces {
+		if err := validateNamespacePattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
 }
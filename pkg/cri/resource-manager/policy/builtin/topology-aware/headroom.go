@@ -0,0 +1,119 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// defaultPoolHeadroom is the key of headroom for pools without own configured headroom.
+	defaultPoolHeadroom = "*"
+)
+
+// poolHeadroom is the sharable CPU capacity of a pool kept out of exclusive allocation.
+//
+// Headroom is given either as an absolute amount of CPU, for instance 2 or
+// 1500m, or as a percentage of the sharable CPUs of the pool, for instance 10%.
+type poolHeadroom struct {
+	milliCPU int // absolute headroom, in milli-CPUs
+	percent  int // relative headroom, in percentage of sharable CPUs
+}
+
+// UnmarshalJSON parses headroom from a JSON number or string.
+func (h *poolHeadroom) UnmarshalJSON(raw []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return policyError("invalid pool headroom %s: %v", string(raw), err)
+	}
+
+	var str string
+	switch v := value.(type) {
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		str = strings.TrimSpace(v)
+	default:
+		return policyError("invalid pool headroom %s", string(raw))
+	}
+
+	*h = poolHeadroom{}
+	if strings.HasSuffix(str, "%") {
+		percent, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(str, "%")))
+		if err != nil || percent < 0 || percent > 100 {
+			return policyError("invalid pool headroom percentage %q", str)
+		}
+		h.percent = percent
+		return nil
+	}
+
+	qty, err := resource.ParseQuantity(str)
+	if err != nil || qty.Sign() < 0 {
+		return policyError("invalid pool headroom %q", str)
+	}
+	h.milliCPU = int(qty.MilliValue())
+
+	return nil
+}
+
+// MarshalJSON encodes headroom as a JSON string.
+func (h poolHeadroom) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.String())
+}
+
+// String returns headroom as a string.
+func (h poolHeadroom) String() string {
+	if h.percent > 0 {
+		return strconv.Itoa(h.percent) + "%"
+	}
+	return resource.NewMilliQuantity(int64(h.milliCPU), resource.DecimalSI).String()
+}
+
+// getPoolHeadroom returns the configured headroom of a pool, in milli-CPUs.
+func getPoolHeadroom(pool Node) int {
+	h, ok := opt.PoolHeadroom[pool.Name()]
+	if !ok {
+		if h, ok = opt.PoolHeadroom[defaultPoolHeadroom]; !ok {
+			return 0
+		}
+	}
+
+	if h.percent > 0 {
+		return 10 * h.percent * pool.GetCPU().SharableCPUs().Size()
+	}
+	return h.milliCPU
+}
+
+// headroomUsage returns the headroom of a pool and how much of it is used by shared allocations.
+func headroomUsage(pool Node) (int, int) {
+	headroom := getPoolHeadroom(pool)
+	if headroom == 0 {
+		return 0, 0
+	}
+
+	free := 1000*pool.FreeCPU().SharableCPUs().Size() - pool.GrantedCPU()
+	switch {
+	case free <= 0:
+		return headroom, headroom
+	case free >= headroom:
+		return headroom, 0
+	default:
+		return headroom, headroom - free
+	}
+}
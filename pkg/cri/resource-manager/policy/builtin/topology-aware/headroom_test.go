@@ -0,0 +1,84 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPoolHeadroomUnmarshal(t *testing.T) {
+	tcases := []struct {
+		name          string
+		raw           string
+		expected      poolHeadroom
+		expectedError bool
+	}{
+		{
+			name:     "CPUs as number",
+			raw:      `2`,
+			expected: poolHeadroom{milliCPU: 2000},
+		},
+		{
+			name:     "fractional CPUs as number",
+			raw:      `1.5`,
+			expected: poolHeadroom{milliCPU: 1500},
+		},
+		{
+			name:     "milli-CPUs as string",
+			raw:      `"750m"`,
+			expected: poolHeadroom{milliCPU: 750},
+		},
+		{
+			name:     "percentage",
+			raw:      `"10%"`,
+			expected: poolHeadroom{percent: 10},
+		},
+		{
+			name:          "percentage out of range",
+			raw:           `"150%"`,
+			expectedError: true,
+		},
+		{
+			name:          "negative CPUs",
+			raw:           `-1`,
+			expectedError: true,
+		},
+		{
+			name:          "garbage",
+			raw:           `"lots"`,
+			expectedError: true,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := poolHeadroom{}
+			err := json.Unmarshal([]byte(tc.raw), &h)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got headroom %s", h)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if h != tc.expected {
+				t.Errorf("expected headroom %+v, got %+v", tc.expected, h)
+			}
+		})
+	}
+}
@@ -15,9 +15,9 @@
 package topologyaware
 
 import (
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 	"github.com/intel/cri-resource-manager/pkg/topology"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 	"strconv"
 	"strings"
 )
@@ -79,8 +79,15 @@ func (cs *cpuSupply) hintCpus(h topology.Hint) cpuset.CPUSet {
 	case h.NUMAs != "":
 		for _, idstr := range strings.Split(h.NUMAs, ",") {
 			if id, err := strconv.ParseInt(idstr, 0, 0); err == nil {
-				if node := cs.node.System().Node(system.ID(id)); node != nil {
-					cpus = cpus.Union(node.CPUSet())
+				node := cs.node.System().Node(system.ID(id))
+				if node == nil {
+					continue
+				}
+				// memory-only nodes stand for the CPUs of the nodes they are attached to
+				for _, cpuNode := range node.CPUNodeIDs() {
+					if n := cs.node.System().Node(cpuNode); n != nil {
+						cpus = cpus.Union(n.CPUSet())
+					}
 				}
 			}
 		}
@@ -15,11 +15,13 @@
 package topologyaware
 
 import (
+	"fmt"
+	"strconv"
 	"testing"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 	"github.com/intel/cri-resource-manager/pkg/topology"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 )
 
 func TestCpuHintScore(t *testing.T) {
@@ -266,3 +268,50 @@ func TestString(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkHintScores(b *testing.B) {
+	// 2 sockets, 8 NUMA nodes, 448 CPUs, 16 device hints per container
+	const (
+		cpuCnt  = 448
+		numaCnt = 8
+		hintCnt = 16
+	)
+	perNuma := cpuCnt / numaCnt
+
+	pools := make([]cpuset.CPUSet, numaCnt)
+	for n := range pools {
+		cpus := make([]int, 0, perNuma)
+		for id := n * perNuma; id < (n+1)*perNuma; id++ {
+			cpus = append(cpus, id)
+		}
+		pools[n] = cpuset.NewCPUSet(cpus...)
+	}
+
+	// half of the hints are CPU hints, the other half NUMA hints
+	hints := make(topology.Hints, hintCnt)
+	for i := 0; i < hintCnt; i++ {
+		n := i % numaCnt
+		hint := topology.Hint{Provider: fmt.Sprintf("device%d", i)}
+		if i%2 == 0 {
+			hint.CPUs = pools[n].String()
+		} else {
+			hint.NUMAs = strconv.Itoa(n)
+		}
+		hints[hint.Provider] = hint
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for n, cpus := range pools {
+			scores := make(map[string]float64, len(hints))
+			for provider, hint := range hints {
+				if hint.CPUs != "" {
+					scores[provider] = cpuHintScore(hint, cpus)
+				} else {
+					scores[provider] = numaHintScore(hint, system.ID(n))
+				}
+			}
+			combineHintScores(scores)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// HugePages is an amount of hugepages, the number of pages by page size in bytes.
+type HugePages map[uint64]int64
+
+// Clone creates a copy of the hugepages.
+func (hp HugePages) Clone() HugePages {
+	c := make(HugePages, len(hp))
+	for size, cnt := range hp {
+		c[size] = cnt
+	}
+	return c
+}
+
+// Cumulate adds the given hugepages to these ones.
+func (hp HugePages) Cumulate(more HugePages) {
+	for size, cnt := range more {
+		hp[size] += cnt
+	}
+}
+
+// Allocate takes the given hugepages from these ones.
+func (hp HugePages) Allocate(req HugePages) {
+	for size, cnt := range req {
+		hp[size] -= cnt
+	}
+}
+
+// Release returns the given hugepages to these ones.
+func (hp HugePages) Release(req HugePages) {
+	hp.Cumulate(req)
+}
+
+// Fits checks if the given hugepages can be allocated from these ones.
+func (hp HugePages) Fits(req HugePages) bool {
+	for size, cnt := range req {
+		if hp[size] < cnt {
+			return false
+		}
+	}
+	return true
+}
+
+// IsEmpty returns true if there are no hugepages of any size.
+func (hp HugePages) IsEmpty() bool {
+	for _, cnt := range hp {
+		if cnt != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a printable representation of the hugepages.
+func (hp HugePages) String() string {
+	sizes := make([]uint64, 0, len(hp))
+	for size := range hp {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	entries := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		q := resource.NewQuantity(int64(size), resource.BinarySI)
+		entries = append(entries, fmt.Sprintf("%s: %d", q.String(), hp[size]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// nodeHugePages returns the hugepage capacity and the hugepages currently in use
+// of the given system NUMA nodes.
+func nodeHugePages(sys system.System, ids ...system.ID) (HugePages, HugePages) {
+	capacity, used := HugePages{}, HugePages{}
+	for _, id := range ids {
+		info, err := sys.Node(id).HugePageInfo()
+		if err != nil {
+			log.Error("failed to discover hugepages of NUMA node #%v: %v", id, err)
+			continue
+		}
+		for size, pages := range info {
+			capacity[size] += int64(pages.Total)
+			if pages.Total > pages.Free {
+				used[size] += int64(pages.Total - pages.Free)
+			}
+		}
+	}
+	return capacity, used
+}
+
+// hugePageRequest returns the hugepages requested by the container.
+func hugePageRequest(container cache.Container) HugePages {
+	hp := HugePages{}
+
+	resources := container.GetResourceRequirements()
+	amounts := resources.Limits
+	if len(amounts) == 0 {
+		amounts = resources.Requests
+	}
+
+	for name, amount := range amounts {
+		if !strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+			continue
+		}
+		sizeStr := strings.TrimPrefix(string(name), corev1.ResourceHugePagesPrefix)
+		size, err := resource.ParseQuantity(sizeStr)
+		if err != nil || size.Value() <= 0 {
+			log.Error("%s: ignoring hugepages with invalid size %q",
+				container.PrettyName(), sizeStr)
+			continue
+		}
+		pageSize := size.Value()
+		hp[uint64(pageSize)] = (amount.Value() + pageSize - 1) / pageSize
+	}
+
+	return hp
+}
+
+// hugePageCharges are the hugepages charged to a container, by holding pool.
+type hugePageCharges map[string]HugePages
+
+// chargedHugePages are the hugepage charges of all containers, by cache ID.
+type chargedHugePages map[string]hugePageCharges
+
+// add adds the given number of pages to the charges of a pool.
+func (c hugePageCharges) add(pool string, size uint64, cnt int64) {
+	hp, ok := c[pool]
+	if !ok {
+		hp = HugePages{}
+		c[pool] = hp
+	}
+	hp[size] += cnt
+}
+
+// hugePageHolders returns the pools which hold the hugepages of the given pool.
+// These are the pools which discover their hugepages directly from the system
+// instead of cumulating them from their children.
+func hugePageHolders(pool Node) []Node {
+	if pool.Kind() == NumaNode || pool.IsLeafNode() {
+		return []Node{pool}
+	}
+	holders := []Node{}
+	for _, c := range pool.Children() {
+		holders = append(holders, hugePageHolders(c)...)
+	}
+	return holders
+}
+
+// hugePagesFit checks if the given hugepages fit into the pool and all its parents.
+func hugePagesFit(pool Node, req HugePages) bool {
+	for n := pool; !n.IsNil(); n = n.Parent() {
+		if !n.FreeHugePages().Fits(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// chargeHugePages charges hugepages to the pools holding them below the given pool.
+func chargeHugePages(pool Node, req HugePages) hugePageCharges {
+	// Notes:
+	//   Hugepages are taken from the NUMA nodes below the pool, so we charge
+	//   them to those nodes and to all their parents. Charging only the pool
+	//   itself would leave its children unaware of the pages in use and
+	//   would let them overcommit their hugepages.
+	holders := hugePageHolders(pool)
+	charges := hugePageCharges{}
+	for size, cnt := range req {
+		for _, h := range holders {
+			if cnt <= 0 {
+				break
+			}
+			take := h.FreeHugePages()[size]
+			if take > cnt {
+				take = cnt
+			}
+			if take > 0 {
+				charges.add(h.Name(), size, take)
+				cnt -= take
+			}
+		}
+		if cnt > 0 {
+			charges.add(holders[0].Name(), size, cnt)
+		}
+	}
+
+	for _, h := range holders {
+		if hp, ok := charges[h.Name()]; ok {
+			for n := h; !n.IsNil(); n = n.Parent() {
+				n.FreeHugePages().Allocate(hp)
+			}
+		}
+	}
+
+	return charges
+}
+
+// allocateHugePages charges the hugepages of the container to the pool.
+func (p *policy) allocateHugePages(container cache.Container, pool Node) {
+	req := hugePageRequest(container)
+	if req.IsEmpty() {
+		return
+	}
+
+	log.Debug("  => charging hugepages %s to %s", req, pool.Name())
+
+	p.hugepages[container.GetCacheID()] = chargeHugePages(pool, req)
+}
+
+// releaseHugePages returns the hugepages of the container to the pools holding them.
+func (p *policy) releaseHugePages(container cache.Container, pool Node) {
+	charges, ok := p.hugepages[container.GetCacheID()]
+	if !ok {
+		return
+	}
+
+	log.Debug("  => returning hugepages of %s to %s", container.PrettyName(), pool.Name())
+
+	for name, hp := range charges {
+		holder, ok := p.nodes[name]
+		if !ok {
+			continue
+		}
+		for n := holder; !n.IsNil(); n = n.Parent() {
+			n.FreeHugePages().Release(hp)
+		}
+	}
+	delete(p.hugepages, container.GetCacheID())
+}
+
+// chargeUnmanagedHugePages charges the hugepages found in use at startup, but
+// not charged to any container, to the pools holding them.
+func (p *policy) chargeUnmanagedHugePages() {
+	charged := map[string]HugePages{}
+	for _, charges := range p.hugepages {
+		for name, hp := range charges {
+			if _, ok := charged[name]; !ok {
+				charged[name] = HugePages{}
+			}
+			charged[name].Cumulate(hp)
+		}
+	}
+
+	for _, h := range hugePageHolders(p.root) {
+		unmanaged := HugePages{}
+		for size, cnt := range h.UsedHugePages() {
+			if cnt -= charged[h.Name()][size]; cnt > 0 {
+				unmanaged[size] = cnt
+			}
+		}
+		if unmanaged.IsEmpty() {
+			continue
+		}
+		log.Info("%s: %s hugepages in use by unmanaged processes", h.Name(), unmanaged)
+		for n := h; !n.IsNil(); n = n.Parent() {
+			n.FreeHugePages().Allocate(unmanaged)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestHugePageRequest(t *testing.T) {
+	const (
+		size2M = uint64(2 * 1024 * 1024)
+		size1G = uint64(1024 * 1024 * 1024)
+	)
+
+	tcases := []struct {
+		name      string
+		resources v1.ResourceRequirements
+		expected  HugePages
+	}{
+		{
+			name:     "no hugepages",
+			expected: HugePages{},
+		},
+		{
+			name: "2Mi and 1Gi hugepages",
+			resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceCPU:  resource.MustParse("1"),
+					"hugepages-2Mi": resource.MustParse("20Mi"),
+					"hugepages-1Gi": resource.MustParse("2Gi"),
+				},
+			},
+			expected: HugePages{size2M: 10, size1G: 2},
+		},
+		{
+			name: "partial pages are rounded up",
+			resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					"hugepages-2Mi": resource.MustParse("3Mi"),
+				},
+			},
+			expected: HugePages{size2M: 2},
+		},
+		{
+			name: "invalid page size is ignored",
+			resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					"hugepages-foo": resource.MustParse("3Mi"),
+				},
+			},
+			expected: HugePages{},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &mockContainer{
+				name:                                  tc.name,
+				returnValueForGetResourceRequirements: tc.resources,
+			}
+			hp := hugePageRequest(c)
+			if len(hp) != len(tc.expected) {
+				t.Errorf("expected %s, got %s", tc.expected, hp)
+			}
+			for size, cnt := range tc.expected {
+				if hp[size] != cnt {
+					t.Errorf("expected %s, got %s", tc.expected, hp)
+				}
+			}
+		})
+	}
+}
+
+func TestHugePagesFit(t *testing.T) {
+	free := HugePages{2048: 4}
+	req := HugePages{2048: 3}
+
+	if !free.Fits(req) {
+		t.Errorf("expected %s to fit into %s", req, free)
+	}
+	free.Allocate(req)
+	if free.Fits(req) {
+		t.Errorf("expected %s not to fit into %s", req, free)
+	}
+	free.Release(req)
+	if !free.Fits(req) {
+		t.Errorf("expected %s to fit into %s after release", req, free)
+	}
+	if free.Fits(HugePages{4096: 1}) {
+		t.Errorf("expected unknown page size not to fit into %s", free)
+	}
+}
+
+func TestChargeHugePages(t *testing.T) {
+	const size2M = uint64(2 * 1024 * 1024)
+
+	root := &node{name: "root", kind: VirtualNode, parent: nilnode}
+	numa0 := &node{name: "numa node #0", kind: NumaNode}
+	numa1 := &node{name: "numa node #1", kind: NumaNode}
+	numa0.LinkParent(root)
+	numa1.LinkParent(root)
+	root.freehp = HugePages{size2M: 5}
+	numa0.freehp = HugePages{size2M: 3}
+	numa1.freehp = HugePages{size2M: 2}
+	numa0.usedhp = HugePages{size2M: 1}
+
+	p := &policy{
+		root:      root,
+		nodes:     map[string]Node{"root": root, numa0.name: numa0, numa1.name: numa1},
+		hugepages: chargedHugePages{},
+	}
+
+	// charges to the root are taken from the NUMA nodes below it
+	c := &mockContainer{name: "c", returnValueForGetCacheID: "c"}
+	p.hugepages[c.GetCacheID()] = chargeHugePages(root, HugePages{size2M: 4})
+
+	for n, expected := range map[*node]int64{root: 1, numa0: 0, numa1: 1} {
+		if free := n.freehp[size2M]; free != expected {
+			t.Errorf("expected %d free pages in %s, got %d", expected, n.name, free)
+		}
+	}
+	if hugePagesFit(numa1, HugePages{size2M: 2}) {
+		t.Errorf("expected 2 pages not to fit into %s", numa1.name)
+	}
+
+	// releasing returns the pages to the same NUMA nodes
+	p.releaseHugePages(c, root)
+	for n, expected := range map[*node]int64{root: 5, numa0: 3, numa1: 2} {
+		if free := n.freehp[size2M]; free != expected {
+			t.Errorf("expected %d free pages in %s after release, got %d", expected, n.name, free)
+		}
+	}
+
+	// pages in use by others are charged to the NUMA node holding them
+	p.chargeUnmanagedHugePages()
+	for n, expected := range map[*node]int64{root: 4, numa0: 2, numa1: 2} {
+		if free := n.freehp[size2M]; free != expected {
+			t.Errorf("expected %d free pages in %s with unmanaged pages, got %d", expected, n.name, free)
+		}
+	}
+}
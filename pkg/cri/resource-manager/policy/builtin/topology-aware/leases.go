@@ -0,0 +1,202 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// keyCPULease is the policy entry key for the exclusive CPU lease of a container.
+	keyCPULease = "cpu-lease"
+	// schemaCPULease is the schema tag of CPU lease policy entries.
+	schemaCPULease = "cpu-lease/v1"
+
+	// leaseExpired is the end reason of leases which ran out of time.
+	leaseExpired = "expired"
+	// leasePreempted is the end reason of leases taken back for other containers.
+	leasePreempted = "preempted"
+)
+
+// cpuLease is the exclusive CPU lease of a container.
+type cpuLease struct {
+	// Expires is the time the lease expires at.
+	Expires time.Time `json:"expires"`
+	// Ended is the reason the lease ended before or at expiry, if it has.
+	Ended string `json:"ended,omitempty"`
+}
+
+// getLease returns the CPU lease of a container, if it has one.
+func getLease(container cache.Container) (*cpuLease, bool) {
+	lease := &cpuLease{}
+	ok, err := container.GetPolicyEntry(keyCPULease, schemaCPULease, lease)
+	if err != nil {
+		log.Error("invalid CPU lease of %s: %v", container.PrettyName(), err)
+		return nil, false
+	}
+	return lease, ok
+}
+
+// setLease updates the CPU lease of a container.
+func setLease(container cache.Container, lease *cpuLease) {
+	if err := container.SetPolicyEntry(keyCPULease, schemaCPULease, lease); err != nil {
+		log.Error("failed to update CPU lease of %s: %v", container.PrettyName(), err)
+	}
+}
+
+// endLease ends the CPU lease of a container for the given reason.
+func endLease(container cache.Container, lease *cpuLease, reason string) {
+	lease.Ended = reason
+	setLease(container, lease)
+}
+
+// leaseCPURequest applies exclusive CPU leasing to the CPU request of a container.
+//
+// A container annotated with a CPU lease gets its exclusive CPUs only for the
+// annotated duration. The lease starts with the first exclusive allocation of
+// the container. Once the lease has expired or has been preempted, the container
+// gets all of its CPU request from the shared pool.
+func (p *policy) leaseCPURequest(container cache.Container, request CPURequest) CPURequest {
+	if request.FullCPUs() == 0 {
+		return request
+	}
+	pod, ok := container.GetPod()
+	if !ok {
+		return request
+	}
+	duration := podCPULeasePreference(pod, container)
+	if duration <= 0 {
+		return request
+	}
+
+	lease, ok := getLease(container)
+	if !ok {
+		lease = &cpuLease{Expires: time.Now().Add(duration)}
+		setLease(container, lease)
+		log.Info("%s leases %d exclusive CPUs until %s", container.PrettyName(),
+			request.FullCPUs(), lease.Expires.Format(time.RFC3339))
+		return request
+	}
+
+	if lease.Ended != "" {
+		log.Debug("  => CPU lease of %s %s, using shared CPUs", container.PrettyName(), lease.Ended)
+		return sharedCPURequest(request)
+	}
+
+	if time.Now().After(lease.Expires) {
+		endLease(container, lease, leaseExpired)
+		log.Info("CPU lease of %s expired, using shared CPUs", container.PrettyName())
+		return sharedCPURequest(request)
+	}
+
+	return request
+}
+
+// sharedCPURequest turns a CPU request into one for shared CPUs only.
+func sharedCPURequest(request CPURequest) CPURequest {
+	cr := *request.(*cpuRequest)
+	cr.fraction += 1000 * cr.full
+	cr.full = 0
+	cr.isolate = false
+	cr.fullCores = false
+	return &cr
+}
+
+// activeLease returns the active CPU lease of a container, if any.
+func activeLease(container cache.Container) (*cpuLease, bool) {
+	lease, ok := getLease(container)
+	if !ok || lease.Ended != "" {
+		return nil, false
+	}
+	return lease, true
+}
+
+// expiredLeases returns the grants of exclusive CPUs with a lease expired by now.
+func (p *policy) expiredLeases(now time.Time) map[CPUGrant]*cpuLease {
+	expired := map[CPUGrant]*cpuLease{}
+	for _, grant := range p.allocations.CPU {
+		if grant.ExclusiveCPUs().IsEmpty() {
+			continue
+		}
+		if lease, ok := activeLease(grant.GetContainer()); ok && !now.Before(lease.Expires) {
+			expired[grant] = lease
+		}
+	}
+	return expired
+}
+
+// expireLeases moves containers with expired CPU leases to shared CPUs.
+func (p *policy) expireLeases() {
+	for grant, lease := range p.expiredLeases(time.Now()) {
+		c := grant.GetContainer()
+		endLease(c, lease, leaseExpired)
+		log.Info("CPU lease of %s expired, returning CPUs %s to shared pool",
+			c.PrettyName(), grant.ExclusiveCPUs())
+
+		if err := p.UpdateResources(c); err != nil {
+			log.Error("failed to move %s to shared CPUs: %v", c.PrettyName(), err)
+		}
+	}
+}
+
+// preemptLease preempts the CPU lease ending soonest to make room for a container.
+//
+// Only containers requesting exclusive CPUs without a lease of their own can
+// preempt leases. Returns whether any lease was preempted.
+func (p *policy) preemptLease(container cache.Container) bool {
+	if pod, ok := container.GetPod(); ok && podCPULeasePreference(pod, container) > 0 {
+		return false
+	}
+	if newCPURequest(container).FullCPUs() == 0 {
+		return false
+	}
+
+	victim, soonest := p.leaseToPreempt()
+	if victim == nil {
+		return false
+	}
+
+	c := victim.GetContainer()
+	endLease(c, soonest, leasePreempted)
+	log.Info("preempting CPU lease of %s for %s, returning CPUs %s to shared pool",
+		c.PrettyName(), container.PrettyName(), victim.ExclusiveCPUs())
+
+	if err := p.UpdateResources(c); err != nil {
+		log.Error("failed to move %s to shared CPUs: %v", c.PrettyName(), err)
+	}
+
+	return true
+}
+
+// leaseToPreempt returns the grant of exclusive CPUs with the active lease ending soonest.
+func (p *policy) leaseToPreempt() (CPUGrant, *cpuLease) {
+	var victim CPUGrant
+	var soonest *cpuLease
+	for _, grant := range p.allocations.CPU {
+		if grant.ExclusiveCPUs().IsEmpty() {
+			continue
+		}
+		lease, ok := activeLease(grant.GetContainer())
+		if !ok {
+			continue
+		}
+		if victim == nil || lease.Expires.Before(soonest.Expires) {
+			victim, soonest = grant, lease
+		}
+	}
+	return victim, soonest
+}
@@ -0,0 +1,196 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// leasingPod returns a pod leasing exclusive CPUs for the given duration.
+func leasingPod(duration string) *mockPod {
+	return &mockPod{
+		returnValue1FotGetResmgrAnnotation: duration,
+		returnValue2FotGetResmgrAnnotation: true,
+	}
+}
+
+func TestLeaseCPURequest(t *testing.T) {
+	p := &policy{}
+	now := time.Now()
+
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		lease     *cpuLease
+		full      int
+		shared    bool
+		leased    bool
+		endReason string
+	}{
+		{
+			name: "no lease without annotation",
+			full: 2,
+		},
+		{
+			name: "no lease without exclusive CPUs",
+			pod:  leasingPod("1h"),
+		},
+		{
+			name:   "first allocation starts a lease",
+			pod:    leasingPod("1h"),
+			full:   2,
+			leased: true,
+		},
+		{
+			name:   "active lease",
+			pod:    leasingPod("1h"),
+			lease:  &cpuLease{Expires: now.Add(time.Minute)},
+			full:   2,
+			leased: true,
+		},
+		{
+			name:      "expired lease",
+			pod:       leasingPod("1h"),
+			lease:     &cpuLease{Expires: now.Add(-time.Minute)},
+			full:      2,
+			shared:    true,
+			leased:    true,
+			endReason: leaseExpired,
+		},
+		{
+			name:      "preempted lease",
+			pod:       leasingPod("1h"),
+			lease:     &cpuLease{Expires: now.Add(time.Minute), Ended: leasePreempted},
+			full:      2,
+			shared:    true,
+			leased:    true,
+			endReason: leasePreempted,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &mockContainer{name: "ctr", pod: tc.pod}
+			if tc.lease != nil {
+				setLease(c, tc.lease)
+			}
+			request := &cpuRequest{container: c, full: tc.full, fraction: 500, isolate: true}
+
+			result := p.leaseCPURequest(c, request)
+
+			if tc.shared {
+				if result.FullCPUs() != 0 || result.CPUFraction() != 1000*tc.full+500 {
+					t.Errorf("expected %d shared milli-CPUs, got %s", 1000*tc.full+500, result)
+				}
+				if result.Isolate() {
+					t.Errorf("expected no isolated CPUs for shared request %s", result)
+				}
+			} else if result != CPURequest(request) {
+				t.Errorf("expected request %s unchanged, got %s", request, result)
+			}
+
+			lease, ok := getLease(c)
+			if ok != tc.leased {
+				t.Fatalf("expected lease %v, got %v", tc.leased, ok)
+			}
+			if !ok {
+				return
+			}
+			if lease.Ended != tc.endReason {
+				t.Errorf("expected lease end reason %q, got %q", tc.endReason, lease.Ended)
+			}
+			if tc.lease == nil && !lease.Expires.After(now.Add(59*time.Minute)) {
+				t.Errorf("expected lease expiring in an hour, got %s", lease.Expires)
+			}
+		})
+	}
+}
+
+func TestLeaseSelection(t *testing.T) {
+	numa0 := &node{name: "numa node #0", id: 1, kind: NumaNode, parent: nilnode}
+	now := time.Now()
+
+	p := &policy{
+		allocations: allocations{CPU: map[string]CPUGrant{}},
+	}
+	grant := func(id string, exclusive cpuset.CPUSet, lease *cpuLease) CPUGrant {
+		c := &mockContainer{name: id, returnValueForGetCacheID: id}
+		if lease != nil {
+			setLease(c, lease)
+		}
+		g := newCPUGrant(numa0, c, exclusive, 0)
+		p.allocations.CPU[id] = g
+		return g
+	}
+
+	grant("unleased", cpuset.NewCPUSet(2, 3), nil)
+	grant("shared", cpuset.NewCPUSet(), &cpuLease{Expires: now.Add(-time.Hour)})
+	grant("ended", cpuset.NewCPUSet(4), &cpuLease{Expires: now.Add(-time.Hour), Ended: leasePreempted})
+	expired := grant("expired", cpuset.NewCPUSet(5), &cpuLease{Expires: now.Add(-time.Minute)})
+	soon := grant("soon", cpuset.NewCPUSet(6), &cpuLease{Expires: now.Add(time.Minute)})
+	grant("later", cpuset.NewCPUSet(7), &cpuLease{Expires: now.Add(time.Hour)})
+
+	leases := p.expiredLeases(now)
+	if len(leases) != 1 {
+		t.Errorf("expected only the lease of expired container expired, got %d leases", len(leases))
+	}
+	if _, ok := leases[expired]; !ok {
+		t.Errorf("expected lease of expired container expired")
+	}
+
+	if victim, _ := p.leaseToPreempt(); victim != expired {
+		t.Errorf("expected lease of expired container preempted, got %s", nameOf(victim))
+	}
+
+	endLease(expired.GetContainer(), leases[expired], leaseExpired)
+	if leases = p.expiredLeases(now); len(leases) != 0 {
+		t.Errorf("expected no expired leases left, got %d", len(leases))
+	}
+	victim, lease := p.leaseToPreempt()
+	if victim != soon {
+		t.Errorf("expected lease of soon expiring container preempted, got %s", nameOf(victim))
+	}
+	if lease == nil || !lease.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected lease ending in a minute, got %v", lease)
+	}
+}
+
+// nameOf returns the name of the container of a grant.
+func nameOf(grant CPUGrant) string {
+	if grant == nil {
+		return "none"
+	}
+	return grant.GetContainer().PrettyName()
+}
+
+func TestPreemptLeaseByLeasingContainer(t *testing.T) {
+	numa0 := &node{name: "numa node #0", id: 1, kind: NumaNode, parent: nilnode}
+	p := &policy{
+		allocations: allocations{CPU: map[string]CPUGrant{}},
+	}
+	c := &mockContainer{name: "leased", returnValueForGetCacheID: "leased"}
+	setLease(c, &cpuLease{Expires: time.Now().Add(time.Hour)})
+	p.allocations.CPU["leased"] = newCPUGrant(numa0, c, cpuset.NewCPUSet(2), 0)
+
+	// containers leasing exclusive CPUs themselves never preempt other leases
+	if p.preemptLease(&mockContainer{name: "leasing", pod: leasingPod("1h")}) {
+		t.Errorf("expected no lease preempted for a leasing container")
+	}
+	if lease, _ := activeLease(c); lease == nil {
+		t.Errorf("expected lease of %s still active", c.PrettyName())
+	}
+}
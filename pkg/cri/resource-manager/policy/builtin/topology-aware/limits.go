@@ -0,0 +1,122 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"time"
+)
+
+const (
+	// defaultPoolLimits is the key of limits for pools without own configured limits.
+	defaultPoolLimits = "*"
+	// churnWindow is the sliding time window of the churn budget.
+	churnWindow = time.Minute
+)
+
+// poolLimits are the multi-tenant protection limits of a pool.
+type poolLimits struct {
+	// MaxContainers is the maximum number of containers allocated to the pool.
+	MaxContainers int `json:",omitempty"`
+	// ChurnBudget is the maximum number of (re)allocations to the pool per minute.
+	ChurnBudget int `json:",omitempty"`
+}
+
+// poolChurn tracks the times of recent allocations, by pool node ID.
+type poolChurn map[int][]time.Time
+
+// charge records an allocation to the given pool.
+func (pc poolChurn) charge(id int, now time.Time) {
+	pc.expire(id, now)
+	pc[id] = append(pc[id], now)
+}
+
+// count returns the number of allocations to the given pool within the churn window.
+func (pc poolChurn) count(id int, now time.Time) int {
+	pc.expire(id, now)
+	return len(pc[id])
+}
+
+// expire drops allocations to the given pool older than the churn window.
+func (pc poolChurn) expire(id int, now time.Time) {
+	stamps := pc[id]
+	idx := 0
+	for idx < len(stamps) && now.Sub(stamps[idx]) >= churnWindow {
+		idx++
+	}
+	if idx == len(stamps) {
+		delete(pc, id)
+	} else {
+		pc[id] = stamps[idx:]
+	}
+}
+
+// getPoolLimits returns the configured limits of a pool, if any.
+func getPoolLimits(pool Node) *poolLimits {
+	if limits, ok := opt.PoolLimits[pool.Name()]; ok {
+		return limits
+	}
+	return opt.PoolLimits[defaultPoolLimits]
+}
+
+// poolContainerCount returns the number of containers allocated to a pool.
+func (p *policy) poolContainerCount(pool Node) int {
+	count := 0
+	for _, grant := range p.allocations.CPU {
+		if grant.GetNode().IsSameNode(pool) {
+			count++
+		}
+	}
+	return count
+}
+
+// filterPoolsByLimits filters out pools at their container count limit or churn budget.
+func (p *policy) filterPoolsByLimits(pools []Node) []Node {
+	if len(opt.PoolLimits) == 0 {
+		return pools
+	}
+
+	now := time.Now()
+	filtered := make([]Node, 0, len(pools))
+	for _, pool := range pools {
+		limits := getPoolLimits(pool)
+		if limits == nil {
+			filtered = append(filtered, pool)
+			continue
+		}
+		if limits.MaxContainers > 0 {
+			if count := p.poolContainerCount(pool); count >= limits.MaxContainers {
+				log.Debug("  - %s is at its container limit (%d)", pool.Name(), count)
+				continue
+			}
+		}
+		if limits.ChurnBudget > 0 {
+			if count := p.churn.count(pool.NodeID(), now); count >= limits.ChurnBudget {
+				log.Debug("  - %s exceeds its churn budget (%d/min)", pool.Name(), count)
+				continue
+			}
+		}
+		filtered = append(filtered, pool)
+	}
+
+	return filtered
+}
+
+// chargePoolChurn charges an allocation against the churn budget of a pool.
+func (p *policy) chargePoolChurn(pool Node) {
+	if limits := getPoolLimits(pool); limits == nil || limits.ChurnBudget <= 0 {
+		return
+	}
+	p.churn.charge(pool.NodeID(), time.Now())
+}
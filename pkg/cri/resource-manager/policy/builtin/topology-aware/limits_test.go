@@ -0,0 +1,74 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolChurn(t *testing.T) {
+	start := time.Now()
+	tcases := []struct {
+		name     string
+		charges  []time.Duration
+		at       time.Duration
+		expected int
+	}{
+		{
+			name:     "no allocations",
+			at:       0,
+			expected: 0,
+		},
+		{
+			name:     "all allocations within window",
+			charges:  []time.Duration{0, 10 * time.Second, 20 * time.Second},
+			at:       30 * time.Second,
+			expected: 3,
+		},
+		{
+			name:     "some allocations expired",
+			charges:  []time.Duration{0, 10 * time.Second, 50 * time.Second},
+			at:       75 * time.Second,
+			expected: 1,
+		},
+		{
+			name:     "allocation expiring at the end of the window",
+			charges:  []time.Duration{0, 30 * time.Second},
+			at:       time.Minute,
+			expected: 1,
+		},
+		{
+			name:     "all allocations expired",
+			charges:  []time.Duration{0, 10 * time.Second},
+			at:       2 * time.Minute,
+			expected: 0,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			churn := make(poolChurn)
+			for _, d := range tc.charges {
+				churn.charge(1, start.Add(d))
+			}
+			if count := churn.count(1, start.Add(tc.at)); count != tc.expected {
+				t.Errorf("expected churn %d, got %d", tc.expected, count)
+			}
+			if churn.count(2, start.Add(tc.at)) != 0 {
+				t.Errorf("unexpected churn for uncharged pool")
+			}
+		})
+	}
+}
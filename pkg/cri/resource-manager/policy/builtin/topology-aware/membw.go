@@ -0,0 +1,113 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"strconv"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// bytes in a megabyte, the unit of configured memory bandwidth capacities
+	megabyte = 1000 * 1000
+)
+
+// memBandwidthHeadroom calculates the memory bandwidth headroom (bytes/s) of all pools.
+//
+// The headroom of a pool is its configured bandwidth capacity minus the measured
+// bandwidth usage of the containers allocated to the pool or any of its children.
+// Pools without a configured capacity inherit the sum of their children's capacity.
+// Pools without any capacity have no headroom, so unless capacity is configured
+// memory bandwidth does not affect pool selection.
+func (p *policy) memBandwidthHeadroom() map[int]int64 {
+	headroom := make(map[int]int64, p.nodeCnt)
+	if len(opt.MemBandwidth) == 0 {
+		return headroom
+	}
+
+	usage := p.containerMemBandwidth()
+	p.root.DepthFirst(func(n Node) error {
+		id := n.NodeID()
+		capacity := p.memBandwidthCapacity(n)
+		if capacity == 0 {
+			return nil
+		}
+		headroom[id] = capacity
+		for cacheID, grant := range p.allocations.CPU {
+			if isDescendant(grant.GetNode(), n) {
+				headroom[id] -= usage[cacheID]
+			}
+		}
+		return nil
+	})
+
+	return headroom
+}
+
+// memBandwidthCapacity returns the configured or cumulated capacity of a pool.
+func (p *policy) memBandwidthCapacity(n Node) int64 {
+	if capacity, ok := opt.MemBandwidth[n.Name()]; ok {
+		return int64(capacity * megabyte)
+	}
+	total := int64(0)
+	for _, c := range n.Children() {
+		total += p.memBandwidthCapacity(c)
+	}
+	return total
+}
+
+// containerMemBandwidth estimates the memory bandwidth usage of containers.
+//
+// Memory bandwidth is measured per RDT class. We estimate container usage by
+// dividing the bandwidth of each class evenly among the containers of the class.
+func (p *policy) containerMemBandwidth() map[string]int64 {
+	classes := map[string][]cache.Container{}
+	for _, c := range p.cache.GetContainers() {
+		if _, ok := c.GetTag(cache.TagRDTMemBandwidth); !ok {
+			continue
+		}
+		class := c.GetRDTClass()
+		if class == "" {
+			class = string(c.GetQOSClass())
+		}
+		classes[class] = append(classes[class], c)
+	}
+
+	usage := map[string]int64{}
+	for _, containers := range classes {
+		for _, c := range containers {
+			value, _ := c.GetTag(cache.TagRDTMemBandwidth)
+			bw, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				log.Warn("%s: invalid memory bandwidth tag %q", c.PrettyName(), value)
+				continue
+			}
+			usage[c.GetCacheID()] = bw / int64(len(containers))
+		}
+	}
+
+	return usage
+}
+
+// isDescendant checks if node n is either the same as or a descendant of node ancestor.
+func isDescendant(n, ancestor Node) bool {
+	for ; !n.IsNil(); n = n.Parent() {
+		if n.IsSameNode(ancestor) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+)
+
+func TestMemBandwidthCapacity(t *testing.T) {
+	defer func(saved map[string]uint64) { opt.MemBandwidth = saved }(opt.MemBandwidth)
+
+	root := &node{name: "root", id: 0, kind: VirtualNode, parent: nilnode}
+	numa0 := &node{name: "numa node #0", id: 1, kind: NumaNode}
+	numa1 := &node{name: "numa node #1", id: 2, kind: NumaNode}
+	numa0.LinkParent(root)
+	numa1.LinkParent(root)
+	p := &policy{root: root, nodeCnt: 3}
+
+	opt.MemBandwidth = nil
+	if headroom := p.memBandwidthHeadroom(); len(headroom) != 0 {
+		t.Errorf("expected no headroom without configured capacity, got %v", headroom)
+	}
+
+	opt.MemBandwidth = map[string]uint64{"numa node #0": 1000}
+	for n, expected := range map[*node]int64{root: 1000 * megabyte, numa0: 1000 * megabyte, numa1: 0} {
+		if capacity := p.memBandwidthCapacity(n); capacity != expected {
+			t.Errorf("expected capacity %d for %s, got %d", expected, n.name, capacity)
+		}
+	}
+}
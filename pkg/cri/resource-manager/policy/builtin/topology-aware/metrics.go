@@ -0,0 +1,155 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// Prometheus descriptors for the per-pool inputs of pool scoring.
+var (
+	poolSharedDesc = prometheus.NewDesc(
+		"topologyaware_pool_shared_capacity_millicpu",
+		"Free shared CPU capacity of a pool, in milli-CPUs.",
+		[]string{"pool", "kind"}, nil,
+	)
+	poolIsolatedDesc = prometheus.NewDesc(
+		"topologyaware_pool_isolated_cpus",
+		"Number of free isolated CPUs of a pool.",
+		[]string{"pool", "kind"}, nil,
+	)
+	poolContainersDesc = prometheus.NewDesc(
+		"topologyaware_pool_containers",
+		"Number of containers assigned to a pool.",
+		[]string{"pool", "kind"}, nil,
+	)
+	poolHeadroomDesc = prometheus.NewDesc(
+		"topologyaware_pool_headroom_millicpu",
+		"Sharable CPU capacity of a pool kept out of exclusive allocation, in milli-CPUs.",
+		[]string{"pool", "kind"}, nil,
+	)
+	poolHeadroomUsedDesc = prometheus.NewDesc(
+		"topologyaware_pool_headroom_used_millicpu",
+		"Headroom of a pool consumed by shared allocations, in milli-CPUs.",
+		[]string{"pool", "kind"}, nil,
+	)
+	reservedPoolDesc = prometheus.NewDesc(
+		"topologyaware_reserved_pool_container_info",
+		"Containers held in the reserved pool, with the namespace pattern they matched.",
+		[]string{"container", "namespace", "pattern"}, nil,
+	)
+)
+
+// poolMetrics is a snapshot of the metrics data of a single pool.
+type poolMetrics struct {
+	kind       NodeKind // pool type
+	shared     int      // free shared capacity
+	isolated   int      // free isolated CPUs
+	containers int      // number of containers assigned
+	headroom   int      // headroom kept out of exclusive allocation
+	used       int      // headroom used by shared allocations
+}
+
+// reservedMetrics is a snapshot of a container held in the reserved pool.
+type reservedMetrics struct {
+	container string // container (pretty) name
+	namespace string // container namespace
+	pattern   string // reserved pool namespace pattern matched
+}
+
+// metricsData is the policy-specific metrics data we poll.
+type metricsData struct {
+	pools    map[string]*poolMetrics // pool metrics, by pool name
+	reserved []reservedMetrics       // containers in the reserved pool
+}
+
+// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+func (p *policy) DescribeMetrics() []*prometheus.Desc {
+	return []*prometheus.Desc{
+		poolSharedDesc,
+		poolIsolatedDesc,
+		poolContainersDesc,
+		poolHeadroomDesc,
+		poolHeadroomUsedDesc,
+		reservedPoolDesc,
+	}
+}
+
+// PollMetrics provides policy metrics for monitoring.
+func (p *policy) PollMetrics() policyapi.Metrics {
+	data := metricsData{pools: make(map[string]*poolMetrics, len(p.pools))}
+
+	for _, pool := range p.pools {
+		free := pool.FreeCPU()
+		headroom, used := headroomUsage(pool)
+		data.pools[pool.Name()] = &poolMetrics{
+			kind:     pool.Kind(),
+			shared:   1000*free.SharableCPUs().Size() - pool.GrantedCPU(),
+			isolated: free.IsolatedCPUs().Size(),
+			headroom: headroom,
+			used:     used,
+		}
+	}
+	for _, grant := range p.allocations.CPU {
+		if pm, ok := data.pools[grant.GetNode().Name()]; ok {
+			pm.containers++
+		}
+	}
+	for _, c := range p.reservedPoolContainers() {
+		pattern, _ := c.GetTag(tagReservedPool)
+		data.reserved = append(data.reserved, reservedMetrics{
+			container: c.PrettyName(),
+			namespace: c.GetNamespace(),
+			pattern:   pattern,
+		})
+	}
+
+	return data
+}
+
+// CollectMetrics generates prometheus metrics from cached/polled policy-specific metrics data.
+func (p *policy) CollectMetrics(m policyapi.Metrics) ([]prometheus.Metric, error) {
+	data, ok := m.(metricsData)
+	if !ok {
+		return nil, policyError("unexpected policy metrics data of type %T", m)
+	}
+
+	metrics := make([]prometheus.Metric, 0, 5*len(data.pools)+len(data.reserved))
+	for name, pm := range data.pools {
+		kind := string(pm.kind)
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(poolSharedDesc,
+				prometheus.GaugeValue, float64(pm.shared), name, kind),
+			prometheus.MustNewConstMetric(poolIsolatedDesc,
+				prometheus.GaugeValue, float64(pm.isolated), name, kind),
+			prometheus.MustNewConstMetric(poolContainersDesc,
+				prometheus.GaugeValue, float64(pm.containers), name, kind),
+			prometheus.MustNewConstMetric(poolHeadroomDesc,
+				prometheus.GaugeValue, float64(pm.headroom), name, kind),
+			prometheus.MustNewConstMetric(poolHeadroomUsedDesc,
+				prometheus.GaugeValue, float64(pm.used), name, kind),
+		)
+	}
+	for _, rm := range data.reserved {
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(reservedPoolDesc,
+				prometheus.GaugeValue, 1, rm.container, rm.namespace, rm.pattern),
+		)
+	}
+
+	return metrics, nil
+}
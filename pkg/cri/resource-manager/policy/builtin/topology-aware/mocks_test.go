@@ -15,26 +15,32 @@
 package topologyaware
 
 import (
+	"encoding/json"
 	"os"
+	"strings"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/config"
 	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 	"github.com/intel/cri-resource-manager/pkg/topology"
 	v1 "k8s.io/api/core/v1"
 	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 )
 
 type mockSystemNode struct {
 	id        system.ID // node id
 	packageID system.ID // node id
 	distance  int
+	memType   system.MemoryType // memory type, DRAM if unset
 }
 
 func (fake *mockSystemNode) MemoryInfo() (*system.MemInfo, error) {
 	return nil, nil
 }
+func (fake *mockSystemNode) HugePageInfo() (map[uint64]*system.HugePageInfo, error) {
+	return map[uint64]*system.HugePageInfo{}, nil
+}
 func (fake *mockSystemNode) PackageID() system.ID {
 	return fake.packageID
 }
@@ -50,6 +56,18 @@ func (fake *mockSystemNode) Distance() []int {
 func (fake *mockSystemNode) DistanceFrom(id system.ID) int {
 	return 0
 }
+func (fake *mockSystemNode) IsMemoryOnly() bool {
+	return false
+}
+func (fake *mockSystemNode) CPUNodeIDs() []system.ID {
+	return []system.ID{fake.id}
+}
+func (fake *mockSystemNode) MemoryType() system.MemoryType {
+	if fake.memType == "" {
+		return system.MemoryTypeDRAM
+	}
+	return fake.memType
+}
 
 type mockSystemCPUPackage struct {
 	id system.ID // package id
@@ -64,6 +82,15 @@ func (fake *mockSystemCPUPackage) CPUSet() cpuset.CPUSet {
 func (fake *mockSystemCPUPackage) NodeIDs() []system.ID {
 	return []system.ID{}
 }
+func (fake *mockSystemCPUPackage) DieIDs() []system.ID {
+	return []system.ID{0}
+}
+func (fake *mockSystemCPUPackage) DieCPUSet(system.ID) cpuset.CPUSet {
+	return cpuset.NewCPUSet()
+}
+func (fake *mockSystemCPUPackage) DieNodeIDs(system.ID) []system.ID {
+	return []system.ID{}
+}
 
 type mockCPU struct {
 	id            system.ID
@@ -72,6 +99,7 @@ type mockCPU struct {
 	isolated      bool
 	online        bool
 	baseFrequency uint64
+	coreType      system.CoreType
 }
 
 func (c *mockCPU) BaseFrequency() uint64 {
@@ -83,6 +111,9 @@ func (c *mockCPU) ID() system.ID {
 func (c *mockCPU) PackageID() system.ID {
 	return c.pkg.ID()
 }
+func (c *mockCPU) DieID() system.ID {
+	return 0
+}
 func (c *mockCPU) NodeID() system.ID {
 	return c.node.ID()
 }
@@ -101,12 +132,23 @@ func (c *mockCPU) Online() bool {
 func (c *mockCPU) Isolated() bool {
 	return c.isolated
 }
+func (c *mockCPU) CoreType() system.CoreType {
+	return c.coreType
+}
 func (c *mockCPU) SetFrequencyLimits(min, max uint64) error {
 	return nil
 }
+func (c *mockCPU) EnergyPerformancePreference() string {
+	return ""
+}
+func (c *mockCPU) SetEnergyPerformancePreference(epp string) error {
+	return nil
+}
 
 type mockSystem struct {
 	isolatedCPU int
+	coreTypes   map[system.CoreType]cpuset.CPUSet
+	memTypes    map[system.ID]system.MemoryType
 }
 
 func (fake *mockSystem) CPU(system.ID) system.CPU {
@@ -134,7 +176,7 @@ func (fake *mockSystem) SetCpusOnline(online bool, cpus system.IDSet) (system.ID
 	return system.NewIDSet(), nil
 }
 func (fake *mockSystem) Node(id system.ID) system.Node {
-	return &mockSystemNode{id: id}
+	return &mockSystemNode{id: id, memType: fake.memTypes[id]}
 }
 func (fake *mockSystem) Package(id system.ID) system.CPUPackage {
 	return &mockSystemCPUPackage{id: id}
@@ -149,6 +191,18 @@ func (fake *mockSystem) Isolated() cpuset.CPUSet {
 
 	return cpuset.NewCPUSet()
 }
+func (fake *mockSystem) IsolatedBy(kind system.CPUIsolation) cpuset.CPUSet {
+	if kind == system.IsolCPUs {
+		return fake.Isolated()
+	}
+	return cpuset.NewCPUSet()
+}
+func (fake *mockSystem) CoreTypeCPUs(t system.CoreType) cpuset.CPUSet {
+	if cpus, ok := fake.coreTypes[t]; ok {
+		return cpus
+	}
+	return cpuset.NewCPUSet()
+}
 func (fake *mockSystem) CPUSet() cpuset.CPUSet {
 	return cpuset.NewCPUSet()
 }
@@ -164,22 +218,46 @@ func (fake *mockSystem) PackageIDs() []system.ID {
 func (fake *mockSystem) NodeIDs() []system.ID {
 	return []system.ID{0, 1}
 }
+func (fake *mockSystem) LLCIDs() []system.ID {
+	return []system.ID{}
+}
+func (fake *mockSystem) LLC(id system.ID) *system.Cache {
+	return nil
+}
+func (fake *mockSystem) BlockDevices() []*system.BlockDevice {
+	return []*system.BlockDevice{}
+}
+func (fake *mockSystem) CXLMemDevices() []*system.CXLMemDevice {
+	return []*system.CXLMemDevice{}
+}
+func (fake *mockSystem) WorkQueues() []*system.WorkQueue {
+	return []*system.WorkQueue{}
+}
 
 type mockContainer struct {
 	name                                  string
 	namespace                             string
 	returnValueForGetResourceRequirements v1.ResourceRequirements
 	returnValueForGetCacheID              string
+	tags                                  map[string]string
+	pod                                   *mockPod
+	policyEntries                         map[string][]byte
 }
 
 func (m *mockContainer) PrettyName() string {
 	return m.name
 }
+func (m *mockContainer) IsIgnored() bool {
+	return false
+}
 func (m *mockContainer) GetPod() (cache.Pod, bool) {
+	if m.pod != nil {
+		return m.pod, true
+	}
 	return &mockPod{}, false
 }
 func (m *mockContainer) GetID() string {
-	panic("unimplemented")
+	return m.GetCacheID()
 }
 func (m *mockContainer) GetPodID() string {
 	panic("unimplemented")
@@ -269,6 +347,12 @@ func (m *mockContainer) GetDeviceByHost(string) *cache.Device {
 func (m *mockContainer) GetDeviceByContainer(string) *cache.Device {
 	panic("unimplemented")
 }
+func (m *mockContainer) ExportResourceAssignment() ([]byte, error) {
+	panic("unimplemented")
+}
+func (m *mockContainer) ImportResourceAssignment([]byte) error {
+	panic("unimplemented")
+}
 func (m *mockContainer) GetResourceRequirements() v1.ResourceRequirements {
 	return m.returnValueForGetResourceRequirements
 }
@@ -338,6 +422,15 @@ func (m *mockContainer) GetCpusetMems() string {
 func (m *mockContainer) SetLinuxResources(*cri.LinuxContainerResources) {
 	panic("unimplemented")
 }
+func (m *mockContainer) UpdateResources(*cri.LinuxContainerResources) bool {
+	panic("unimplemented")
+}
+func (m *mockContainer) SnapshotResources() *cache.ResourceSnapshot {
+	panic("unimplemented")
+}
+func (m *mockContainer) RestoreResources(*cache.ResourceSnapshot) {
+	panic("unimplemented")
+}
 func (m *mockContainer) SetCPUPeriod(int64) {
 	panic("unimplemented")
 }
@@ -378,6 +471,67 @@ func (m *mockContainer) SetBlockIOClass(string) {
 func (m *mockContainer) GetBlockIOClass() string {
 	panic("unimplemented")
 }
+func (m *mockContainer) SetNetworkClass(string) {
+	panic("unimplemented")
+}
+func (m *mockContainer) GetNetworkClass() string {
+	panic("unimplemented")
+}
+func (m *mockContainer) GetRestartCount() int {
+	return 0
+}
+func (m *mockContainer) GetLastExit() *cache.ContainerExit {
+	return nil
+}
+func (m *mockContainer) SetLastExit(*cache.ContainerExit) {
+	panic("unimplemented")
+}
+func (m *mockContainer) GetOOMKills() int {
+	return 0
+}
+func (m *mockContainer) SetOOMKills(int) {
+	panic("unimplemented")
+}
+func (m *mockContainer) SetPolicyEntry(key, schema string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if m.policyEntries == nil {
+		m.policyEntries = make(map[string][]byte)
+	}
+	m.policyEntries[key+"/"+schema] = data
+	return nil
+}
+func (m *mockContainer) GetPolicyEntry(key, schema string, ptr interface{}) (bool, error) {
+	data, ok := m.policyEntries[key+"/"+schema]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, ptr)
+}
+func (m *mockContainer) DeletePolicyEntry(key string) {
+	for k := range m.policyEntries {
+		if strings.HasPrefix(k, key+"/") {
+			delete(m.policyEntries, k)
+		}
+	}
+}
+func (m *mockContainer) GetPolicyEntries() map[string]cache.PolicyEntry {
+	panic("unimplemented")
+}
+func (m *mockContainer) GetRequestedLinuxResources() *cri.LinuxContainerResources {
+	panic("unimplemented")
+}
+func (m *mockContainer) GetSentLinuxResources() *cri.LinuxContainerResources {
+	panic("unimplemented")
+}
+func (m *mockContainer) SetSentLinuxResources(*cri.LinuxContainerResources) {
+	panic("unimplemented")
+}
+func (m *mockContainer) GetLinuxResourceDiffs() []cache.ResourceDiff {
+	panic("unimplemented")
+}
 func (m *mockContainer) SetCRIRequest(req interface{}) error {
 	panic("unimplemented")
 }
@@ -405,8 +559,9 @@ func (m *mockContainer) HasPending(string) bool {
 func (m *mockContainer) ClearPending(string) {
 	panic("unimplemented")
 }
-func (m *mockContainer) GetTag(string) (string, bool) {
-	panic("unimplemented")
+func (m *mockContainer) GetTag(key string) (string, bool) {
+	value, ok := m.tags[key]
+	return value, ok
 }
 func (m *mockContainer) SetTag(string, string) (string, bool) {
 	panic("unimplemented")
@@ -420,6 +575,7 @@ type mockPod struct {
 	returnValueFotGetQOSClass          v1.PodQOSClass
 	returnValue1FotGetResmgrAnnotation string
 	returnValue2FotGetResmgrAnnotation bool
+	annotations                        map[string]string // per-key annotations, if set
 }
 
 func (m *mockPod) GetInitContainers() []cache.Container {
@@ -474,6 +630,10 @@ func (m *mockPod) GetResmgrAnnotationKeys() []string {
 	panic("unimplemented")
 }
 func (m *mockPod) GetResmgrAnnotation(key string) (string, bool) {
+	if m.annotations != nil {
+		value, ok := m.annotations[key]
+		return value, ok
+	}
 	return m.returnValue1FotGetResmgrAnnotation, m.returnValue2FotGetResmgrAnnotation
 }
 func (m *mockPod) GetResmgrAnnotationObject(string, interface{}, func([]byte, interface{}) error) (bool, error) {
@@ -482,6 +642,9 @@ func (m *mockPod) GetResmgrAnnotationObject(string, interface{}, func([]byte, in
 func (m *mockPod) GetCgroupParentDir() string {
 	panic("unimplemented")
 }
+func (m *mockPod) GetRuntimeHandler() string {
+	return ""
+}
 func (m *mockPod) GetPodResourceRequirements() cache.PodResourceRequirements {
 	panic("unimplemented")
 }
@@ -491,6 +654,9 @@ func (m *mockPod) GetContainerAffinity(string) []*cache.Affinity {
 func (m *mockPod) ScopeExpression() *cache.Expression {
 	panic("unimplemented")
 }
+func (m *mockPod) IsIgnored() bool {
+	return false
+}
 
 type mockCache struct {
 	returnValueForGetPolicyEntry   bool
@@ -507,6 +673,9 @@ func (m *mockCache) DeletePod(string) cache.Pod {
 func (m *mockCache) LookupPod(string) (cache.Pod, bool) {
 	panic("unimplemented")
 }
+func (m *mockCache) LookupPodByUID(string, uint32) (cache.Pod, bool) {
+	panic("unimplemented")
+}
 func (m *mockCache) InsertContainer(interface{}) (cache.Container, error) {
 	panic("unimplemented")
 }
@@ -522,6 +691,9 @@ func (m *mockCache) LookupContainer(string) (cache.Container, bool) {
 func (m *mockCache) LookupContainerByCgroup(path string) (cache.Container, bool) {
 	panic("unimplemented")
 }
+func (m *mockCache) LookupContainerByName(string, string, int) (cache.Container, bool) {
+	panic("unimplemented")
+}
 func (m *mockCache) GetPendingContainers() []cache.Container {
 	panic("unimplemented")
 }
@@ -545,6 +717,11 @@ func (m *mockCache) EvaluateAffinity(*cache.Affinity) map[string]int32 {
 		"fake key": 1,
 	}
 }
+func (m *mockCache) EvaluateContainerAffinity(cache.Container) map[string]int32 {
+	return map[string]int32{
+		"fake key": 1,
+	}
+}
 func (m *mockCache) AddImplicitAffinities(map[string]*cache.ImplicitAffinity) error {
 	panic("unimplemented")
 }
@@ -554,11 +731,17 @@ func (m *mockCache) GetActivePolicy() string {
 func (m *mockCache) SetActivePolicy(string) error {
 	panic("unimplemented")
 }
+func (m *mockCache) ResetActivePolicy() error {
+	panic("unimplemented")
+}
 func (m *mockCache) SetPolicyEntry(string, interface{}) {
 }
 func (m *mockCache) GetPolicyEntry(string, interface{}) bool {
 	return m.returnValueForGetPolicyEntry
 }
+func (m *mockCache) SetPodResources(map[string]*cache.PodResourceRequirements) []cache.Container {
+	panic("unimplemented")
+}
 func (m *mockCache) SetConfig(*config.RawConfig) error {
 	panic("unimplemented")
 }
@@ -566,6 +749,12 @@ func (m *mockCache) GetConfig() *config.RawConfig {
 	panic("unimplemented")
 }
 func (m *mockCache) Save() error {
+	return nil
+}
+func (m *mockCache) Flush() error {
+	panic("unimplemented")
+}
+func (m *mockCache) Reload() error {
 	panic("unimplemented")
 }
 func (m *mockCache) Refresh(interface{}) ([]cache.Pod, []cache.Pod, []cache.Container, []cache.Container) {
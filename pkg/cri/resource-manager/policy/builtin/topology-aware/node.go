@@ -17,9 +17,9 @@ package topologyaware
 import (
 	"fmt"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 	"github.com/intel/cri-resource-manager/pkg/topology"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 )
 
 //
@@ -28,8 +28,13 @@ import (
 // sockets a NUMA node. These nodes are linked into a tree resembling the topology
 // tree, with the full machine at the top, and CPU cores at the bottom. In a single
 // socket system, the virtual root is replaced with the single socket. In a single
-// NUMA node case, the single node is omitted. Also, CPU cores are not modelled as
-// nodes, instead they are properties of the nodes (as capacity and free CPU).
+// NUMA node case, the single node is omitted. If enabled, the dies of multi-die
+// packages are added as nodes between the socket and its NUMA nodes, provided
+// that each die consists of whole NUMA nodes, or as leaf nodes of a socket with
+// a single NUMA node. If enabled, last-level caches which
+// are shared by only a subset of the CPUs of a NUMA node (or a socket) are added
+// as nodes below it. Also, CPU cores are not modelled as nodes, instead they are
+// properties of the nodes (as capacity and free CPU).
 //
 
 // NodeKind represents a unique node type.
@@ -42,10 +47,14 @@ const (
 	UnknownNode NodeKind = "unknown"
 	// SocketNode represents a physical CPU package/socket in the system.
 	SocketNode NodeKind = "socket"
+	// DieNode represents a die of a multi-die CPU package in the system.
+	DieNode NodeKind = "die"
 	// NumaNode represents a NUMA node in the system.
 	NumaNode NodeKind = "numa node"
 	// VirtualNode represents a virtual node, currently the root multi-socket setups.
 	VirtualNode NodeKind = "virtual node"
+	// LLCNode represents a last-level cache (a CAT domain) in the system.
+	LLCNode NodeKind = "LLC"
 )
 
 const (
@@ -97,6 +106,16 @@ type Node interface {
 	GetMemset() system.IDSet
 	// DiscoverMemset
 	DiscoverMemset() system.IDSet
+	// GetMemoryOnlyNodes returns the memory-only (CPU-less) nodes attached to this node.
+	GetMemoryOnlyNodes() system.IDSet
+	// DiscoverHugePages discovers the hugepage capacity of this node.
+	DiscoverHugePages() HugePages
+	// GetHugePages returns the full hugepage capacity of this node.
+	GetHugePages() HugePages
+	// FreeHugePages returns the unallocated hugepages of this node.
+	FreeHugePages() HugePages
+	// UsedHugePages returns the hugepages of this node found in use at discovery.
+	UsedHugePages() HugePages
 	// DepthFirst traverse the tree@node calling the function at each node.
 	DepthFirst(func(Node) error) error
 	// BreadthFirst traverse the tree@node calling the function at each node.
@@ -123,6 +142,10 @@ type node struct {
 	nodecpu  CPUSupply    // CPU available at this node
 	freecpu  CPUSupply    // CPU allocatable at this node
 	mem      system.IDSet // memory attached to this node
+	memonly  system.IDSet // memory-only nodes attached to this node
+	nodehp   HugePages    // hugepages available at this node
+	freehp   HugePages    // hugepages allocatable at this node
+	usedhp   HugePages    // hugepages in use at discovery
 }
 
 // nodeself is used to 'upcast' a generic Node interface to a type-specific one.
@@ -137,6 +160,13 @@ type socketnode struct {
 	syspkg system.CPUPackage // corresponding system.Package
 }
 
+// dienode represents a die of a multi-die CPU package in the system.
+type dienode struct {
+	node                     // common node data
+	id     system.ID         // die id, within its package
+	syspkg system.CPUPackage // package of the die
+}
+
 // numanode represents a NUMA node in the system.
 type numanode struct {
 	node                // common node data
@@ -144,6 +174,14 @@ type numanode struct {
 	sysnode system.Node // corresponding system.Node
 }
 
+// llcnode represents a last-level cache (and the CPUs sharing it) in the system.
+type llcnode struct {
+	node                 // common node data
+	id     system.ID     // cache id
+	syscch *system.Cache // corresponding system.Cache
+	cpus   cpuset.CPUSet // CPUs sharing this cache
+}
+
 // virtualnode represents a virtual node (ATM only the root in a multi-socket system).
 type virtualnode struct {
 	node // common node data
@@ -274,6 +312,10 @@ func (n *node) Dump(prefix string, level ...int) {
 	log.Debug("%s  - node CPU: %v", idt, n.nodecpu)
 	log.Debug("%s  - free CPU: %v", idt, n.freecpu)
 	log.Debug("%s  - memory: %v", idt, n.mem)
+	if n.memonly.Size() > 0 {
+		log.Debug("%s  - memory-only: %v", idt, n.memonly)
+	}
+	log.Debug("%s  - hugepages: %v (free %v)", idt, n.nodehp, n.freehp)
 	for _, grant := range n.policy.allocations.CPU {
 		if grant.GetNode().NodeID() == n.id {
 			log.Debug("%s    + %s", idt, grant)
@@ -354,6 +396,39 @@ func (n *node) DiscoverMemset() system.IDSet {
 	return n.self.node.DiscoverMemset()
 }
 
+// GetMemoryOnlyNodes returns the memory-only (CPU-less) nodes attached to this node.
+func (n *node) GetMemoryOnlyNodes() system.IDSet {
+	return n.memonly.Clone()
+}
+
+// discoverMemoryOnlyNodes collects the memory-only nodes attached to the children of this node.
+func (n *node) discoverMemoryOnlyNodes() {
+	n.memonly = system.NewIDSet()
+	for _, c := range n.children {
+		n.memonly.Add(c.GetMemoryOnlyNodes().Members()...)
+	}
+}
+
+// GetHugePages returns the full hugepage capacity of this node.
+func (n *node) GetHugePages() HugePages {
+	return n.nodehp.Clone()
+}
+
+// DiscoverHugePages discovers the hugepage capacity of this node.
+func (n *node) DiscoverHugePages() HugePages {
+	return n.self.node.DiscoverHugePages()
+}
+
+// FreeHugePages returns the unallocated hugepages of this node.
+func (n *node) FreeHugePages() HugePages {
+	return n.freehp
+}
+
+// UsedHugePages returns the hugepages of this node found in use at discovery.
+func (n *node) UsedHugePages() HugePages {
+	return n.usedhp.Clone()
+}
+
 // Granted returns the amount of granted shared CPU capacity of this node.
 func (n *node) GrantedCPU() int {
 	granted := n.freecpu.Granted()
@@ -416,9 +491,17 @@ func (n *numanode) GetMemset() system.IDSet {
 // DiscoverMemset discovers the set of memory attached to this node.
 func (n *numanode) DiscoverMemset() system.IDSet {
 	n.mem = system.NewIDSet(n.sysnode.ID())
+	n.memonly = n.policy.memoryOnlyNodes(n.id)
 	return n.mem.Clone()
 }
 
+// DiscoverHugePages discovers the hugepages attached to this node.
+func (n *numanode) DiscoverHugePages() HugePages {
+	n.nodehp, n.usedhp = nodeHugePages(n.System(), n.id)
+	n.freehp = n.nodehp.Clone()
+	return n.nodehp.Clone()
+}
+
 // HintScore calculates the (CPU) score of the node for the given topology hint.
 func (n *numanode) HintScore(hint topology.Hint) float64 {
 	switch {
@@ -426,7 +509,7 @@ func (n *numanode) HintScore(hint topology.Hint) float64 {
 		return cpuHintScore(hint, n.sysnode.CPUSet())
 
 	case hint.NUMAs != "":
-		return numaHintScore(hint, n.id)
+		return numaHintScore(hint, append(n.memonly.Members(), n.id)...)
 
 	case hint.Sockets != "":
 		pkgID := n.sysnode.PackageID()
@@ -493,10 +576,29 @@ func (n *socketnode) DiscoverMemset() system.IDSet {
 	for _, c := range n.children {
 		n.mem.Add(c.GetMemset().Members()...)
 	}
+	if n.IsLeafNode() {
+		n.memonly = n.policy.memoryOnlyNodes(n.syspkg.NodeIDs()...)
+	} else {
+		n.discoverMemoryOnlyNodes()
+	}
 
 	return n.mem.Clone()
 }
 
+// DiscoverHugePages discovers the hugepages attached to this socket.
+func (n *socketnode) DiscoverHugePages() HugePages {
+	if n.IsLeafNode() {
+		n.nodehp, n.usedhp = nodeHugePages(n.System(), n.syspkg.NodeIDs()...)
+	} else {
+		n.nodehp = HugePages{}
+		for _, c := range n.children {
+			n.nodehp.Cumulate(c.GetHugePages())
+		}
+	}
+	n.freehp = n.nodehp.Clone()
+	return n.nodehp.Clone()
+}
+
 // HintScore calculates the (CPU) score of the node for the given topology hint.
 func (n *socketnode) HintScore(hint topology.Hint) float64 {
 	switch {
@@ -513,6 +615,183 @@ func (n *socketnode) HintScore(hint topology.Hint) float64 {
 	return 0.0
 }
 
+// NewDieNode creates a node for a die of a CPU package.
+func (p *policy) NewDieNode(pkgID, id system.ID, parent Node) Node {
+	n := &dienode{}
+	n.self.node = n
+	n.node.init(p, fmt.Sprintf("die #%v/%v", pkgID, id), DieNode, parent)
+	n.id = id
+	n.syspkg = p.sys.Package(pkgID)
+
+	return n
+}
+
+// Dump (the die-specific parts of) this node.
+func (n *dienode) dump(prefix string, level ...int) {
+	log.Debug("%s<socket #%v die #%v>", indent(prefix, level...), n.syspkg.ID(), n.id)
+}
+
+// Get CPU supply available at this node.
+func (n *dienode) GetCPU() CPUSupply {
+	return n.nodecpu.Clone()
+}
+
+// DiscoverCPU discovers the CPU supply available at this die.
+func (n *dienode) DiscoverCPU() CPUSupply {
+	log.Debug("discovering CPU available at node %s...", n.Name())
+
+	if n.IsLeafNode() {
+		diecpus := n.syspkg.DieCPUSet(n.id)
+		isolated := diecpus.Intersection(n.policy.isolated)
+		sharable := diecpus.Difference(isolated)
+		n.nodecpu = newCPUSupply(n, isolated, sharable, 0)
+	} else {
+		n.nodecpu = newCPUSupply(n, cpuset.NewCPUSet(), cpuset.NewCPUSet(), 0)
+		for _, c := range n.children {
+			n.nodecpu.Cumulate(c.DiscoverCPU())
+		}
+	}
+
+	n.freecpu = n.nodecpu.Clone()
+	return n.nodecpu.Clone()
+}
+
+// GetMemset() returns the set of memory attached to this die.
+func (n *dienode) GetMemset() system.IDSet {
+	return n.mem.Clone()
+}
+
+// DiscoverMemset discovers the set of memory attached to this die.
+func (n *dienode) DiscoverMemset() system.IDSet {
+	n.mem = system.NewIDSet()
+	for _, c := range n.children {
+		n.mem.Add(c.GetMemset().Members()...)
+	}
+	if n.IsLeafNode() {
+		n.mem.Add(n.syspkg.DieNodeIDs(n.id)...)
+		n.memonly = n.policy.memoryOnlyNodes(n.syspkg.DieNodeIDs(n.id)...)
+	} else {
+		n.discoverMemoryOnlyNodes()
+	}
+
+	return n.mem.Clone()
+}
+
+// DiscoverHugePages discovers the hugepages attached to this die.
+func (n *dienode) DiscoverHugePages() HugePages {
+	if n.IsLeafNode() {
+		// Sibling dies splitting a NUMA node would share its hugepages, so
+		// like LLC nodes, we only give dies the hugepages of whole nodes.
+		ids := []system.ID{}
+		for _, id := range n.syspkg.DieNodeIDs(n.id) {
+			if _, ok := n.policy.nodeDie(id); ok {
+				ids = append(ids, id)
+			}
+		}
+		n.nodehp, n.usedhp = nodeHugePages(n.System(), ids...)
+	} else {
+		n.nodehp = HugePages{}
+		for _, c := range n.children {
+			n.nodehp.Cumulate(c.GetHugePages())
+		}
+	}
+	n.freehp = n.nodehp.Clone()
+	return n.nodehp.Clone()
+}
+
+// HintScore calculates the (CPU) score of the node for the given topology hint.
+func (n *dienode) HintScore(hint topology.Hint) float64 {
+	switch {
+	case hint.CPUs != "":
+		return cpuHintScore(hint, n.syspkg.DieCPUSet(n.id))
+
+	case hint.NUMAs != "":
+		return OverfitPenalty * numaHintScore(hint, n.syspkg.DieNodeIDs(n.id)...)
+
+	case hint.Sockets != "":
+		score := socketHintScore(hint, n.syspkg.ID())
+		if score > 0.0 {
+			// penalize underfit reciprocally to the number of dies in the socket
+			score /= float64(len(n.syspkg.DieIDs()))
+		}
+		return score
+	}
+
+	return 0.0
+}
+
+// NewLLCNode creates a node for a last-level cache.
+func (p *policy) NewLLCNode(id system.ID, parent Node) Node {
+	n := &llcnode{}
+	n.self.node = n
+	n.node.init(p, fmt.Sprintf("LLC #%v", id), LLCNode, parent)
+	n.id = id
+	n.syscch = p.sys.LLC(id)
+	n.cpus = n.syscch.CPUSet().Intersection(p.sys.CPUSet())
+
+	return n
+}
+
+// Dump (the LLC-specific parts of) this node.
+func (n *llcnode) dump(prefix string, level ...int) {
+	log.Debug("%s<L%d cache #%v, %d bytes>", indent(prefix, level...),
+		n.syscch.Level(), n.id, n.syscch.Size())
+}
+
+// Get CPU supply available at this node.
+func (n *llcnode) GetCPU() CPUSupply {
+	return n.nodecpu.Clone()
+}
+
+// DiscoverCPU discovers the CPU supply available at this node.
+func (n *llcnode) DiscoverCPU() CPUSupply {
+	log.Debug("discovering CPU available at node %s...", n.Name())
+
+	isolated := n.cpus.Intersection(n.policy.isolated)
+	sharable := n.cpus.Difference(isolated)
+	n.nodecpu = newCPUSupply(n, isolated, sharable, 0)
+
+	n.freecpu = n.nodecpu.Clone()
+	return n.nodecpu.Clone()
+}
+
+// GetMemset() returns the set of memory attached to this node.
+func (n *llcnode) GetMemset() system.IDSet {
+	return n.mem.Clone()
+}
+
+// DiscoverMemset discovers the set of memory closest to the CPUs sharing this cache.
+func (n *llcnode) DiscoverMemset() system.IDSet {
+	n.mem = system.NewIDSet()
+	for _, id := range n.cpus.ToSlice() {
+		n.mem.Add(n.System().CPU(system.ID(id)).NodeID())
+	}
+	n.memonly = n.policy.memoryOnlyNodes(n.mem.Members()...)
+	return n.mem.Clone()
+}
+
+// DiscoverHugePages discovers the hugepages attached to this node.
+func (n *llcnode) DiscoverHugePages() HugePages {
+	// Notes:
+	//   Hugepages are a per NUMA node resource. Sibling LLC nodes share the
+	//   hugepages of their parent, so to avoid overcommitting them we don't
+	//   give LLC nodes any. Containers requesting hugepages end up in a pool
+	//   at NUMA node level or above.
+	n.nodehp = HugePages{}
+	n.freehp = n.nodehp.Clone()
+	return n.nodehp.Clone()
+}
+
+// HintScore calculates the (CPU) score of the node for the given topology hint.
+func (n *llcnode) HintScore(hint topology.Hint) float64 {
+	if hint.CPUs != "" {
+		return cpuHintScore(hint, n.cpus)
+	}
+
+	// penalize underfit reciprocally to the number of caches sharing our parent
+	return n.parent.HintScore(hint) / float64(len(n.parent.Children()))
+}
+
 // NewVirtualNode creates a new virtual node.
 func (p *policy) NewVirtualNode(name string, parent Node) Node {
 	n := &virtualnode{}
@@ -556,9 +835,20 @@ func (n *virtualnode) DiscoverMemset() system.IDSet {
 	for _, c := range n.children {
 		n.mem.Add(c.GetMemset().Members()...)
 	}
+	n.discoverMemoryOnlyNodes()
 	return n.mem.Clone()
 }
 
+// DiscoverHugePages discovers the hugepages attached to this node.
+func (n *virtualnode) DiscoverHugePages() HugePages {
+	n.nodehp = HugePages{}
+	for _, c := range n.children {
+		n.nodehp.Cumulate(c.GetHugePages())
+	}
+	n.freehp = n.nodehp.Clone()
+	return n.nodehp.Clone()
+}
+
 // HintScore calculates the (CPU) score of the node for the given topology hint.
 func (n *virtualnode) HintScore(hint topology.Hint) float64 {
 	// don't bother calculating any scores, the root should always score 1.0
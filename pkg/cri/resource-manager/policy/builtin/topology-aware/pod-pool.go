@@ -0,0 +1,116 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// poolSame pins all containers of a pod into the pool of the first one allocated.
+	poolSame = "same"
+)
+
+// podPinnedPool returns the pool all containers of the pod of a container are pinned to.
+//
+// The pool is taken from the pod-level pool annotation, which either names a
+// pool or is 'same' to put all containers into the pool of the first one of
+// the pod allocated. A nil pool without an error is returned if the pod is not
+// pinned or, for 'same', if no other container of the pod has been allocated
+// yet. An error is returned for an unknown pool or one at its limits.
+func (p *policy) podPinnedPool(container cache.Container, pools []Node) (Node, error) {
+	pod, ok := container.GetPod()
+	if !ok {
+		return nil, nil
+	}
+	value, ok := podPoolPreference(pod)
+	if !ok {
+		return nil, nil
+	}
+
+	var pool Node
+	if value == poolSame {
+		if pool = p.podSiblingPool(container); pool == nil {
+			return nil, nil
+		}
+	} else {
+		if pool = p.poolByName(value); pool == nil {
+			return nil, policyError("%s: annotated pool %q not found in topology (pools: %s)",
+				container.PrettyName(), value, strings.Join(p.poolNames(), ", "))
+		}
+	}
+
+	for _, n := range pools {
+		if n.IsSameNode(pool) {
+			log.Debug("  => %s pinned to pool %s", container.PrettyName(), pool.Name())
+			return pool, nil
+		}
+	}
+
+	return nil, policyError("%s: pinned pool %s is at its limits",
+		container.PrettyName(), pool.Name())
+}
+
+// podSiblingPool returns the pool of an already allocated container of the same pod.
+func (p *policy) podSiblingPool(container cache.Container) Node {
+	podID := container.GetPodID()
+	ids := []string{}
+	for id, grant := range p.allocations.CPU {
+		if id == container.GetCacheID() || grant.GetContainer().GetPodID() != podID {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+	return p.allocations.CPU[ids[0]].GetNode()
+}
+
+// poolByName looks up a pool by name.
+//
+// Besides the exact name, pools can be referred to by their name in lowercase
+// with spaces, dashes, underscores and '#' left out, for instance socket0 for
+// 'socket #0' and numanode1 for 'numa node #1'.
+func (p *policy) poolByName(name string) Node {
+	if pool, ok := p.nodes[name]; ok {
+		return pool
+	}
+	key := poolNameKey(name)
+	for _, pool := range p.pools {
+		if poolNameKey(pool.Name()) == key {
+			return pool
+		}
+	}
+	return nil
+}
+
+// poolNames returns the names of all pools.
+func (p *policy) poolNames() []string {
+	names := make([]string, 0, len(p.pools))
+	for _, pool := range p.pools {
+		names = append(names, pool.Name())
+	}
+	return names
+}
+
+// poolNameKey returns the relaxed form of a pool name used for lookups.
+func poolNameKey(name string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "", "#", "").Replace(name))
+}
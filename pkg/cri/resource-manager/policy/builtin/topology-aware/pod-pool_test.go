@@ -0,0 +1,118 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+)
+
+func TestPoolByName(t *testing.T) {
+	p := &policy{nodes: map[string]Node{}}
+	for name, kind := range map[string]NodeKind{
+		"root":         VirtualNode,
+		"socket #0":    SocketNode,
+		"socket #1":    SocketNode,
+		"numa node #0": NumaNode,
+		"numa node #1": NumaNode,
+	} {
+		n := &node{name: name, kind: kind}
+		p.nodes[name] = n
+		p.pools = append(p.pools, n)
+	}
+
+	tcases := []struct {
+		name     string
+		pool     string
+		expected string
+	}{
+		{
+			name:     "exact name",
+			pool:     "socket #1",
+			expected: "socket #1",
+		},
+		{
+			name:     "relaxed name",
+			pool:     "socket0",
+			expected: "socket #0",
+		},
+		{
+			name:     "relaxed name with dash",
+			pool:     "NUMA-node-1",
+			expected: "numa node #1",
+		},
+		{
+			name: "unknown pool",
+			pool: "socket2",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := p.poolByName(tc.pool)
+			switch {
+			case pool == nil && tc.expected != "":
+				t.Errorf("expected pool %q, got none", tc.expected)
+			case pool != nil && pool.Name() != tc.expected:
+				t.Errorf("expected pool %q, got %q", tc.expected, pool.Name())
+			}
+		})
+	}
+}
+
+func TestPodPoolPreference(t *testing.T) {
+	tcases := []struct {
+		name     string
+		pod      *mockPod
+		expected string
+		pinned   bool
+	}{
+		{
+			name: "no annotation",
+			pod:  &mockPod{},
+		},
+		{
+			name: "same pool",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "Same",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			expected: poolSame,
+			pinned:   true,
+		},
+		{
+			name: "named pool",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: " socket0 ",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			expected: "socket0",
+			pinned:   true,
+		},
+		{
+			name: "empty annotation",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, pinned := podPoolPreference(tc.pod)
+			if value != tc.expected || pinned != tc.pinned {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tc.expected, tc.pinned, value, pinned)
+			}
+		})
+	}
+}
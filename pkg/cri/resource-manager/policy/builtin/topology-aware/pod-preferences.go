@@ -16,13 +16,15 @@ package topologyaware
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ghodss/yaml"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 )
 
 const (
@@ -30,6 +32,28 @@ const (
 	keyIsolationPreference = "prefer-isolated-cpus"
 	// annotation key for opting out of exclusive allocation and relaxed topology fitting.
 	keySharedCPUPreference = "prefer-shared-cpus"
+	// annotation key for opting in to exclusive allocation of full physical cores.
+	keySMTIsolationPreference = "prefer-smt-isolation"
+	// annotation key for opting in to an exclusive last-level cache partition.
+	keyLLCPartitionPreference = "prefer-exclusive-llc"
+	// annotation key for opting in to kubelet static CPU manager semantics.
+	keyStaticCPUPreference = "static-cpu-manager"
+	// annotation key for leasing exclusive CPUs for a bounded duration.
+	keyCPULeasePreference = "cpu-lease"
+	// annotation key for opting in to memory from memory-only (HBM) nodes.
+	keyHBMPreference = "prefer-hbm"
+	// annotation key for the preferred types of memory.
+	keyMemoryTypePreference = "memory-type"
+	// annotation key for the memory pinning mode, strict, preferred or spread-cache.
+	keyMemoryPinningPreference = "memory-pinning"
+	// annotation key for the placement strategy, spread or pack.
+	keyPlacementStrategyPreference = "placement-strategy"
+	// annotation key for the preferred type of cores on hybrid systems.
+	keyCoreTypePreference = "core-type"
+	// annotation key for pinning all containers of a pod into a single pool.
+	keyPoolPreference = "pool"
+	// staticCPUAllNamespaces enables static CPU manager semantics in all namespaces.
+	staticCPUAllNamespaces = "*"
 )
 
 // podIsolationPreference checks if containers explicitly prefers to run on multiple isolated CPUs.
@@ -61,6 +85,324 @@ func podIsolationPreference(pod cache.Pod, container cache.Container) (bool, boo
 	return opt.PreferIsolated, false
 }
 
+// podCPULeasePreference returns the duration a container leases its exclusive CPUs for.
+// A zero duration means exclusive CPUs are not leased but kept for the lifetime of the container.
+func podCPULeasePreference(pod cache.Pod, container cache.Container) time.Duration {
+	value, ok := pod.GetResmgrAnnotation(keyCPULeasePreference)
+	if !ok {
+		return 0
+	}
+
+	var duration time.Duration
+	if err := cache.DecodeAnnotation([]byte(value), &duration); err == nil {
+		return duration
+	}
+
+	preferences := map[string]string{}
+	if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+		log.Error("failed to parse CPU lease preference %s = '%s': %v",
+			keyCPULeasePreference, value, err)
+		return 0
+	}
+	pref, ok := preferences[container.GetName()]
+	if !ok {
+		return 0
+	}
+	if err := cache.DecodeAnnotation([]byte(pref), &duration); err != nil {
+		log.Error("invalid CPU lease duration for container %s: %v", container.GetName(), err)
+		return 0
+	}
+
+	log.Debug("%s per-container CPU lease preference '%v'", container.GetName(), duration)
+
+	return duration
+}
+
+// podSMTIsolationPreference checks if a container wants its exclusive CPUs as full cores.
+// Containers allocated full cores never share any hyperthread sibling of their
+// exclusive CPUs with other containers, neither exclusively nor via the shared pool.
+func podSMTIsolationPreference(pod cache.Pod, container cache.Container) bool {
+	value, ok := pod.GetResmgrAnnotation(keySMTIsolationPreference)
+	if !ok {
+		return opt.PreferSMTIsolation
+	}
+	if value == "false" || value == "true" {
+		return value[0] == 't'
+	}
+
+	preferences := map[string]bool{}
+	if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+		log.Error("failed to parse SMT isolation preference %s = '%s': %v",
+			keySMTIsolationPreference, value, err)
+		return opt.PreferSMTIsolation
+	}
+
+	name := container.GetName()
+	if pref, ok := preferences[name]; ok {
+		log.Debug("%s per-container SMT isolation preference '%v'", name, pref)
+		return pref
+	}
+
+	return opt.PreferSMTIsolation
+}
+
+// podHBMPreference checks if a container wants its memory from HBM.
+// Such containers get their memory only from the memory-only nodes attached
+// to their pool, if the pool has any.
+func podHBMPreference(pod cache.Pod, container cache.Container) bool {
+	value, ok := pod.GetResmgrAnnotation(keyHBMPreference)
+	if !ok {
+		return false
+	}
+	if value == "false" || value == "true" {
+		return value[0] == 't'
+	}
+
+	preferences := map[string]bool{}
+	if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+		log.Error("failed to parse HBM preference %s = '%s': %v",
+			keyHBMPreference, value, err)
+		return false
+	}
+
+	name := container.GetName()
+	if pref, ok := preferences[name]; ok {
+		log.Debug("%s per-container HBM preference '%v'", name, pref)
+		return pref
+	}
+
+	return false
+}
+
+// podMemoryTypePreference returns the types of memory a container prefers, if any.
+// The types are given as a comma-separated list, for instance "dram,cxl". The HBM
+// preference is a shorthand for preferring HBM only.
+func podMemoryTypePreference(pod cache.Pod, container cache.Container) []system.MemoryType {
+	value, ok := pod.GetResmgrAnnotation(keyMemoryTypePreference)
+	if !ok {
+		if podHBMPreference(pod, container) {
+			return []system.MemoryType{system.MemoryTypeHBM}
+		}
+		return nil
+	}
+
+	if strings.Contains(value, ":") {
+		preferences := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+			log.Error("failed to parse memory type preference %s = '%s': %v",
+				keyMemoryTypePreference, value, err)
+			return nil
+		}
+		if value, ok = preferences[container.GetName()]; !ok {
+			return nil
+		}
+		log.Debug("%s per-container memory type preference '%v'", container.GetName(), value)
+	}
+
+	names := []string{}
+	if err := cache.DecodeAnnotation([]byte(value), &names); err != nil {
+		log.Error("invalid memory type preference %s = '%s': %v",
+			keyMemoryTypePreference, value, err)
+		return nil
+	}
+
+	types := []system.MemoryType{}
+	for _, name := range names {
+		t, err := system.ParseMemoryType(name)
+		if err != nil {
+			log.Error("invalid memory type preference %s = '%s': %v",
+				keyMemoryTypePreference, value, err)
+			return nil
+		}
+		types = append(types, t)
+	}
+
+	return types
+}
+
+// podMemoryPinningPreference returns the memory pinning mode of a container.
+// The mode is strict, unless the container is annotated to prefer its memory
+// nodes, or to spread its page cache over them.
+func podMemoryPinningPreference(pod cache.Pod, container cache.Container) string {
+	value, ok := pod.GetResmgrAnnotation(keyMemoryPinningPreference)
+	if !ok {
+		return cache.MemoryPinningStrict
+	}
+
+	if strings.Contains(value, ":") {
+		preferences := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+			log.Error("failed to parse memory pinning preference %s = '%s': %v",
+				keyMemoryPinningPreference, value, err)
+			return cache.MemoryPinningStrict
+		}
+		if value, ok = preferences[container.GetName()]; !ok {
+			return cache.MemoryPinningStrict
+		}
+		log.Debug("%s per-container memory pinning preference '%v'", container.GetName(), value)
+	}
+
+	switch mode := strings.TrimSpace(value); mode {
+	case cache.MemoryPinningStrict, cache.MemoryPinningPreferred, cache.MemoryPinningSpreadCache:
+		return mode
+	default:
+		log.Error("invalid memory pinning preference %s = '%s', expecting %s, %s or %s",
+			keyMemoryPinningPreference, value, cache.MemoryPinningStrict,
+			cache.MemoryPinningPreferred, cache.MemoryPinningSpreadCache)
+		return cache.MemoryPinningStrict
+	}
+}
+
+// podLLCPartitionPreference checks if a container wants an exclusive last-level cache partition.
+// Such containers are allocated CPUs from a pool of CPUs sharing a last-level cache
+// and assigned to the RDT class which partitions that cache for them.
+func podLLCPartitionPreference(pod cache.Pod, container cache.Container) bool {
+	value, ok := pod.GetResmgrAnnotation(keyLLCPartitionPreference)
+	if !ok {
+		return false
+	}
+	if value == "false" || value == "true" {
+		return value[0] == 't'
+	}
+
+	preferences := map[string]bool{}
+	if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+		log.Error("failed to parse LLC partition preference %s = '%s': %v",
+			keyLLCPartitionPreference, value, err)
+		return false
+	}
+
+	name := container.GetName()
+	if pref, ok := preferences[name]; ok {
+		log.Debug("%s per-container LLC partition preference '%v'", name, pref)
+		return pref
+	}
+
+	return false
+}
+
+// podStaticCPUPreference checks if a container is allocated CPU with kubelet static CPU manager semantics.
+// Such containers in the Guaranteed QoS class get exclusive CPUs for an integer CPU
+// request and only shared CPUs for any other request. Containers opt in by annotation
+// or by being in one of the namespaces configured for static CPU manager semantics.
+func podStaticCPUPreference(pod cache.Pod, container cache.Container) bool {
+	value, ok := pod.GetResmgrAnnotation(keyStaticCPUPreference)
+	if ok {
+		if value == "false" || value == "true" {
+			return value[0] == 't'
+		}
+
+		preferences := map[string]bool{}
+		if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+			log.Error("failed to parse static CPU manager preference %s = '%s': %v",
+				keyStaticCPUPreference, value, err)
+		} else if pref, ok := preferences[container.GetName()]; ok {
+			log.Debug("%s per-container static CPU manager preference '%v'",
+				container.GetName(), pref)
+			return pref
+		}
+	}
+
+	namespace := container.GetNamespace()
+	for _, ns := range opt.StaticCPUNamespaces {
+		if ns == namespace || ns == staticCPUAllNamespaces {
+			return true
+		}
+	}
+
+	return false
+}
+
+// podPlacementStrategy returns the placement strategy for a container.
+// The strategy is taken from the pod annotation, if any, then from the
+// namespace of the container, and finally from the global configuration.
+func podPlacementStrategy(pod cache.Pod, container cache.Container) placementStrategy {
+	if value, ok := pod.GetResmgrAnnotation(keyPlacementStrategyPreference); ok {
+		if strings.Contains(value, ":") {
+			preferences := map[string]string{}
+			if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+				log.Error("failed to parse placement strategy %s = '%s': %v",
+					keyPlacementStrategyPreference, value, err)
+				value = ""
+			} else {
+				value = preferences[container.GetName()]
+			}
+		}
+		if value != "" {
+			strategy := placementStrategy(value)
+			if err := strategy.validate(); err == nil {
+				log.Debug("%s annotated placement strategy '%s'", container.PrettyName(), strategy)
+				return strategy
+			}
+			log.Error("invalid placement strategy %s = '%s'", keyPlacementStrategyPreference, value)
+		}
+	}
+
+	if strategy, ok := opt.NamespacePlacementStrategy[container.GetNamespace()]; ok && strategy != "" {
+		return strategy
+	}
+	if opt.PlacementStrategy != "" {
+		return opt.PlacementStrategy
+	}
+	return strategySpread
+}
+
+// podCoreTypePreference returns the type of cores a container prefers on hybrid systems.
+// The type is taken from the pod annotation, if any, either for the whole pod or
+// per container. Otherwise, with CoreTypePlacement enabled, Guaranteed containers
+// prefer performance cores and BestEffort containers efficient ones.
+func podCoreTypePreference(pod cache.Pod, container cache.Container) system.CoreType {
+	if value, ok := pod.GetResmgrAnnotation(keyCoreTypePreference); ok {
+		if strings.Contains(value, ":") {
+			preferences := map[string]string{}
+			if err := yaml.Unmarshal([]byte(value), &preferences); err != nil {
+				log.Error("failed to parse core type preference %s = '%s': %v",
+					keyCoreTypePreference, value, err)
+				value = ""
+			} else {
+				value = preferences[container.GetName()]
+			}
+		}
+		if value != "" {
+			coreType, err := system.ParseCoreType(value)
+			if err == nil {
+				log.Debug("%s annotated core type preference '%s'", container.PrettyName(), coreType)
+				return coreType
+			}
+			log.Error("invalid core type preference %s = '%s': %v", keyCoreTypePreference, value, err)
+		}
+	}
+
+	if !opt.CoreTypePlacement {
+		return ""
+	}
+	switch pod.GetQOSClass() {
+	case corev1.PodQOSGuaranteed:
+		return system.CoreTypePerformance
+	case corev1.PodQOSBestEffort:
+		return system.CoreTypeEfficient
+	}
+	return ""
+}
+
+// podPoolPreference returns the pool all containers of a pod are pinned to, if any.
+// The value is either the name of a pool or 'same' for the pool of the first
+// container of the pod allocated.
+func podPoolPreference(pod cache.Pod) (string, bool) {
+	value, ok := pod.GetResmgrAnnotation(keyPoolPreference)
+	if !ok {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", false
+	}
+	if strings.ToLower(value) == poolSame {
+		return poolSame, true
+	}
+	return value, true
+}
+
 // podSharedCPUPreference checks if a container wants to opt-out from exclusive allocation.
 // The first return value indicates if the container prefers to opt-out from
 // exclusive (sliced-off or isolated) CPU allocation even if it was otherwise
@@ -120,29 +462,34 @@ func cpuAllocationPreferences(pod cache.Pod, container cache.Container) (int, in
 	preferIsol, explicit := podIsolationPreference(pod, container)
 	preferShared, elevate := podSharedCPUPreference(pod, container)
 
+	// With static CPU manager semantics only an explicit annotation opts out of exclusive CPUs.
+	static := qos == corev1.PodQOSGuaranteed && podStaticCPUPreference(pod, container)
+	if static && preferShared {
+		if _, ok := pod.GetResmgrAnnotation(keySharedCPUPreference); !ok {
+			preferShared = false
+		}
+	}
+
 	full, fraction, isolate := 0, 0, false
 	switch {
-	case container.GetNamespace() == metav1.NamespaceSystem:
+	case isReservedPoolNamespace(container.GetNamespace()):
 		full, fraction = 0, int(req.MilliValue())
 
 	case qos == corev1.PodQOSBurstable || preferShared:
 		full, fraction = 0, int(req.MilliValue())
 
+	case static && req.MilliValue()%1000 != 0:
+		full, fraction = 0, int(req.MilliValue())
+
 	case qos == corev1.PodQOSGuaranteed:
 		full = int(req.MilliValue()) / 1000
 		fraction = int(req.MilliValue()) % 1000
 	}
 
+	// Kernel-isolated CPUs are only granted to explicitly annotated containers.
 	if !preferShared {
-		switch {
-		case full == 1:
-			if explicit {
-				isolate = preferIsol
-			} else {
-				isolate = true
-			}
-		case full > 1:
-			isolate = preferIsol && explicit
+		if full > 0 {
+			isolate = preferIsol && explicit && opt.PreferIsolated
 		}
 	} else {
 		elevate = -elevate
@@ -15,12 +15,17 @@
 package topologyaware
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	resapi "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 )
 
 func TestPodIsolationPreference(t *testing.T) {
@@ -100,6 +105,68 @@ func TestPodIsolationPreference(t *testing.T) {
 	}
 }
 
+func TestPodSMTIsolationPreference(t *testing.T) {
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		expected  bool
+	}{
+		{
+			name:      "return defaults",
+			pod:       &mockPod{},
+			container: &mockContainer{},
+			expected:  opt.PreferSMTIsolation,
+		},
+		{
+			name: "prefer resmgr's annotation value",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "true",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  true,
+		},
+		{
+			name: "return defaults for unparsable",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "UNPARSABLE",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  opt.PreferSMTIsolation,
+		},
+		{
+			name: "return defaults for missing preferences",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "key: true",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  opt.PreferSMTIsolation,
+		},
+		{
+			name: "return defined preferences",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: true",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+			expected: true,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			smt := podSMTIsolationPreference(tc.pod, tc.container)
+			if smt != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, smt)
+			}
+		})
+	}
+}
+
 func TestPodSharedCPUPreference(t *testing.T) {
 	tcases := []struct {
 		name            string
@@ -294,6 +361,7 @@ func TestCpuAllocationPreferences(t *testing.T) {
 		{
 			name: "return request's value for guaranteed QoS and isolate",
 			container: &mockContainer{
+				name: "testcontainer",
 				returnValueForGetResourceRequirements: v1.ResourceRequirements{
 					Requests: v1.ResourceList{
 						corev1.ResourceCPU: resapi.MustParse("1"),
@@ -302,10 +370,27 @@ func TestCpuAllocationPreferences(t *testing.T) {
 			},
 			pod: &mockPod{
 				returnValueFotGetQOSClass: corev1.PodQOSGuaranteed,
+				annotations: map[string]string{
+					keyIsolationPreference: "testcontainer: true",
+				},
 			},
 			expectedFull:    1,
 			expectedIsolate: true,
 		},
+		{
+			name: "return request's value for guaranteed QoS, no isolate without annotation",
+			container: &mockContainer{
+				returnValueForGetResourceRequirements: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						corev1.ResourceCPU: resapi.MustParse("1"),
+					},
+				},
+			},
+			pod: &mockPod{
+				returnValueFotGetQOSClass: corev1.PodQOSGuaranteed,
+			},
+			expectedFull: 1,
+		},
 		{
 			name: "return request's value for guaranteed QoS and no isolate",
 			container: &mockContainer{
@@ -358,3 +443,458 @@ func TestCpuAllocationPreferences(t *testing.T) {
 		})
 	}
 }
+
+func TestStaticCPUAllocationPreferences(t *testing.T) {
+	saved := opt.StaticCPUNamespaces
+	defer func() { opt.StaticCPUNamespaces = saved }()
+	opt.StaticCPUNamespaces = []string{"static"}
+
+	tcases := []struct {
+		name             string
+		namespace        string
+		request          string
+		qos              corev1.PodQOSClass
+		expectedFull     int
+		expectedFraction int
+	}{
+		{
+			name:         "integer request in static namespace",
+			namespace:    "static",
+			request:      "2",
+			qos:          corev1.PodQOSGuaranteed,
+			expectedFull: 2,
+		},
+		{
+			name:             "fractional request in static namespace",
+			namespace:        "static",
+			request:          "1500m",
+			qos:              corev1.PodQOSGuaranteed,
+			expectedFraction: 1500,
+		},
+		{
+			name:             "fractional request outside static namespace",
+			namespace:        "default",
+			request:          "1500m",
+			qos:              corev1.PodQOSGuaranteed,
+			expectedFull:     1,
+			expectedFraction: 500,
+		},
+		{
+			name:             "burstable request in static namespace",
+			namespace:        "static",
+			request:          "2",
+			qos:              corev1.PodQOSBurstable,
+			expectedFraction: 2000,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			container := &mockContainer{
+				namespace: tc.namespace,
+				returnValueForGetResourceRequirements: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						corev1.ResourceCPU: resapi.MustParse(tc.request),
+					},
+				},
+			}
+			pod := &mockPod{
+				returnValueFotGetQOSClass: tc.qos,
+			}
+			full, fraction, _, _ := cpuAllocationPreferences(pod, container)
+			if full != tc.expectedFull || fraction != tc.expectedFraction {
+				t.Errorf("Expected (%v, %v), but got (%v, %v)",
+					tc.expectedFull, tc.expectedFraction, full, fraction)
+			}
+		})
+	}
+}
+
+func TestPodCPULeasePreference(t *testing.T) {
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		expected  time.Duration
+	}{
+		{
+			name:      "no lease without annotation",
+			pod:       &mockPod{},
+			container: &mockContainer{},
+		},
+		{
+			name: "pod-level lease duration",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "30m",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  30 * time.Minute,
+		},
+		{
+			name: "no lease for unparsable",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "UNPARSABLE",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+		},
+		{
+			name: "no lease for other containers",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "other: 1h",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+		},
+		{
+			name: "per-container lease duration",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: 1h",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+			expected: time.Hour,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			duration := podCPULeasePreference(tc.pod, tc.container)
+			if duration != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, duration)
+			}
+		})
+	}
+}
+
+func TestPodHBMPreference(t *testing.T) {
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		expected  bool
+	}{
+		{
+			name:      "no HBM without annotation",
+			pod:       &mockPod{},
+			container: &mockContainer{},
+		},
+		{
+			name: "pod-level HBM preference",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "true",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  true,
+		},
+		{
+			name: "no HBM for unparsable",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "UNPARSABLE",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+		},
+		{
+			name: "per-container HBM preference",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: true",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+			expected: true,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			hbm := podHBMPreference(tc.pod, tc.container)
+			if hbm != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, hbm)
+			}
+		})
+	}
+}
+
+func TestPodMemoryTypePreference(t *testing.T) {
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		expected  []system.MemoryType
+	}{
+		{
+			name:      "no preference without annotation",
+			pod:       &mockPod{},
+			container: &mockContainer{},
+		},
+		{
+			name: "pod-level memory types",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "dram, cxl",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  []system.MemoryType{system.MemoryTypeDRAM, system.MemoryTypeCXL},
+		},
+		{
+			name: "no preference for invalid memory type",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "dram,flash",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+		},
+		{
+			name: "per-container memory types",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: pmem",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+			expected: []system.MemoryType{system.MemoryTypePMEM},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			types := podMemoryTypePreference(tc.pod, tc.container)
+			if !reflect.DeepEqual(types, tc.expected) {
+				t.Errorf("Expected %v, but got %v", tc.expected, types)
+			}
+		})
+	}
+}
+
+func TestPodMemoryPinningPreference(t *testing.T) {
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		expected  string
+	}{
+		{
+			name:      "strict without annotation",
+			pod:       &mockPod{},
+			container: &mockContainer{},
+			expected:  cache.MemoryPinningStrict,
+		},
+		{
+			name: "pod-level preferred pinning",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "preferred",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  cache.MemoryPinningPreferred,
+		},
+		{
+			name: "strict for invalid mode",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "loose",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  cache.MemoryPinningStrict,
+		},
+		{
+			name: "per-container cache spreading",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: spread-cache",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+			expected: cache.MemoryPinningSpreadCache,
+		},
+		{
+			name: "strict for other containers",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: spread-cache",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "othercontainer",
+			},
+			expected: cache.MemoryPinningStrict,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			mode := podMemoryPinningPreference(tc.pod, tc.container)
+			if mode != tc.expected {
+				t.Errorf("Expected %q, but got %q", tc.expected, mode)
+			}
+		})
+	}
+}
+
+func TestPodPlacementStrategy(t *testing.T) {
+	savedDefault, savedNamespaces := opt.PlacementStrategy, opt.NamespacePlacementStrategy
+	defer func() {
+		opt.PlacementStrategy, opt.NamespacePlacementStrategy = savedDefault, savedNamespaces
+	}()
+	opt.PlacementStrategy = ""
+	opt.NamespacePlacementStrategy = map[string]placementStrategy{"batch": strategyPack}
+
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		expected  placementStrategy
+	}{
+		{
+			name:      "spread by default",
+			pod:       &mockPod{},
+			container: &mockContainer{},
+			expected:  strategySpread,
+		},
+		{
+			name:      "namespace override",
+			pod:       &mockPod{},
+			container: &mockContainer{namespace: "batch"},
+			expected:  strategyPack,
+		},
+		{
+			name: "pod-level annotation",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "pack",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  strategyPack,
+		},
+		{
+			name: "annotation overrides namespace",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "spread",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{namespace: "batch"},
+			expected:  strategySpread,
+		},
+		{
+			name: "invalid annotation ignored",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "scatter",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{namespace: "batch"},
+			expected:  strategyPack,
+		},
+		{
+			name: "per-container annotation",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: pack",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{name: "testcontainer"},
+			expected:  strategyPack,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			strategy := podPlacementStrategy(tc.pod, tc.container)
+			if strategy != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, strategy)
+			}
+		})
+	}
+}
+
+func TestPodCoreTypePreference(t *testing.T) {
+	saved := opt.CoreTypePlacement
+	defer func() {
+		opt.CoreTypePlacement = saved
+	}()
+
+	tcases := []struct {
+		name      string
+		pod       *mockPod
+		container *mockContainer
+		placement bool
+		expected  system.CoreType
+	}{
+		{
+			name:      "no preference by default",
+			pod:       &mockPod{returnValueFotGetQOSClass: corev1.PodQOSGuaranteed},
+			container: &mockContainer{},
+		},
+		{
+			name:      "guaranteed on performance cores",
+			pod:       &mockPod{returnValueFotGetQOSClass: corev1.PodQOSGuaranteed},
+			container: &mockContainer{},
+			placement: true,
+			expected:  system.CoreTypePerformance,
+		},
+		{
+			name:      "besteffort on efficient cores",
+			pod:       &mockPod{returnValueFotGetQOSClass: corev1.PodQOSBestEffort},
+			container: &mockContainer{},
+			placement: true,
+			expected:  system.CoreTypeEfficient,
+		},
+		{
+			name:      "no preference for burstable",
+			pod:       &mockPod{returnValueFotGetQOSClass: corev1.PodQOSBurstable},
+			container: &mockContainer{},
+			placement: true,
+		},
+		{
+			name: "pod-level annotation",
+			pod: &mockPod{
+				returnValueFotGetQOSClass:          corev1.PodQOSBurstable,
+				returnValue1FotGetResmgrAnnotation: "performance",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			expected:  system.CoreTypePerformance,
+		},
+		{
+			name: "annotation overrides default",
+			pod: &mockPod{
+				returnValueFotGetQOSClass:          corev1.PodQOSGuaranteed,
+				returnValue1FotGetResmgrAnnotation: "e",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+			placement: true,
+			expected:  system.CoreTypeEfficient,
+		},
+		{
+			name: "per-container annotation",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "testcontainer: efficient",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{
+				name: "testcontainer",
+			},
+			expected: system.CoreTypeEfficient,
+		},
+		{
+			name: "invalid annotation",
+			pod: &mockPod{
+				returnValue1FotGetResmgrAnnotation: "turbo",
+				returnValue2FotGetResmgrAnnotation: true,
+			},
+			container: &mockContainer{},
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			opt.CoreTypePlacement = tc.placement
+			coreType := podCoreTypePreference(tc.pod, tc.container)
+			if coreType != tc.expected {
+				t.Errorf("Expected %q, but got %q", tc.expected, coreType)
+			}
+		})
+	}
+}
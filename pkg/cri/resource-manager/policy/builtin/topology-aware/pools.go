@@ -15,26 +15,52 @@
 package topologyaware
 
 import (
+	"math"
 	"sort"
+	"strings"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
-	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/kubernetes"
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
 	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 )
 
+const (
+	// weightEpsilon is the smallest difference in weighted pool scores we consider a difference.
+	weightEpsilon = 1e-9
+	// maxCPUShares is the largest valid CFS CPU shares value.
+	maxCPUShares = 262144
+)
+
 // buildPoolsByTopology builds a hierarchical tree of pools based on HW topology.
 func (p *policy) buildPoolsByTopology() error {
 	var n Node
 
+	// memory-only nodes are attached to pools, they don't get pools of their own
+	cpuNodes := []system.ID{}
+	for _, id := range p.sys.NodeIDs() {
+		if !p.sys.Node(id).IsMemoryOnly() {
+			cpuNodes = append(cpuNodes, id)
+		}
+	}
+
 	socketCnt := p.sys.SocketCount()
-	nodeCnt := p.sys.NUMANodeCount()
+	nodeCnt := len(cpuNodes)
 	if nodeCnt < 2 {
 		nodeCnt = 0
 	}
-	poolCnt := socketCnt + nodeCnt + map[bool]int{false: 0, true: 1}[socketCnt > 1]
 
-	p.nodes = make(map[string]Node, poolCnt)
-	p.pools = make([]Node, poolCnt)
+	dies := map[system.ID][]system.ID{}
+	if opt.DiePools {
+		dies = p.splitDies(cpuNodes, nodeCnt > 0)
+	}
+
+	llcs := map[system.ID][]system.ID{}
+	if opt.LLCPools {
+		llcs = p.splitLLCs(nodeCnt > 0)
+	}
+
+	p.nodes = make(map[string]Node)
 
 	// create virtual root if necessary
 	if socketCnt > 1 {
@@ -42,10 +68,14 @@ func (p *policy) buildPoolsByTopology() error {
 		p.nodes[p.root.Name()] = p.root
 	}
 
-	// create nodes for sockets
+	// create nodes for sockets, unless collapsed to their single NUMA node
 	sockets := make(map[system.ID]Node, socketCnt)
 	for _, id := range p.sys.PackageIDs() {
 		if socketCnt > 1 {
+			if opt.CollapsePools && nodeCnt > 0 && len(p.packageNodes(id, cpuNodes)) == 1 {
+				log.Info("collapsing socket #%v with a single NUMA node", id)
+				continue
+			}
 			n = p.NewSocketNode(id, p.root)
 		} else {
 			n = p.NewSocketNode(id, nilnode)
@@ -55,14 +85,59 @@ func (p *policy) buildPoolsByTopology() error {
 		sockets[id] = n
 	}
 
+	// create nodes for dies
+	dieNodes := make(map[system.ID]map[system.ID]Node, len(dies))
+	for _, pkgID := range p.sys.PackageIDs() {
+		for _, id := range dies[pkgID] {
+			parent, ok := sockets[pkgID]
+			if !ok {
+				parent = p.root
+			}
+			n = p.NewDieNode(pkgID, id, parent)
+			p.nodes[n.Name()] = n
+			if dieNodes[pkgID] == nil {
+				dieNodes[pkgID] = make(map[system.ID]Node)
+			}
+			dieNodes[pkgID][id] = n
+		}
+	}
+
 	// create nodes for NUMA nodes
 	if nodeCnt > 0 {
-		for _, id := range p.sys.NodeIDs() {
-			n = p.NewNumaNode(id, sockets[p.sys.Node(id).PackageID()])
+		for _, id := range cpuNodes {
+			pkgID := p.sys.Node(id).PackageID()
+			parent, ok := sockets[pkgID]
+			if !ok {
+				parent = p.root
+			}
+			if die, ok := p.nodeDie(id); ok && dieNodes[pkgID][die] != nil {
+				parent = dieNodes[pkgID][die]
+			}
+			n = p.NewNumaNode(id, parent)
 			p.nodes[n.Name()] = n
+			for _, llc := range llcs[id] {
+				c := p.NewLLCNode(llc, n)
+				p.nodes[c.Name()] = c
+			}
+		}
+	} else {
+		for id, n := range sockets {
+			for _, llc := range llcs[id] {
+				parent := p.llcDie(id, llc, dieNodes[id])
+				if parent == nil {
+					if len(dieNodes[id]) > 0 {
+						continue
+					}
+					parent = n
+				}
+				c := p.NewLLCNode(llc, parent)
+				p.nodes[c.Name()] = c
+			}
 		}
 	}
 
+	p.pools = make([]Node, len(p.nodes))
+
 	// enumerate nodes, calculate tree depth, discover node resource capacity
 	p.root.DepthFirst(func(n Node) error {
 		p.pools[p.nodeCnt] = n
@@ -75,6 +150,7 @@ func (p *policy) buildPoolsByTopology() error {
 
 		n.DiscoverCPU()
 		n.DiscoverMemset()
+		n.DiscoverHugePages()
 
 		return nil
 	})
@@ -82,27 +158,215 @@ func (p *policy) buildPoolsByTopology() error {
 	return nil
 }
 
+// packageNodes returns the given NUMA nodes which are in the given package.
+func (p *policy) packageNodes(pkgID system.ID, ids []system.ID) []system.ID {
+	nodes := []system.ID{}
+	for _, id := range ids {
+		if p.sys.Node(id).PackageID() == pkgID {
+			nodes = append(nodes, id)
+		}
+	}
+	return nodes
+}
+
+// splitDies returns the dies of each multi-die package. With NUMA pools the
+// dies are added only if they consist of whole NUMA nodes, and packages with
+// NUMA nodes spanning several dies are left alone. Without NUMA pools, for
+// instance on a single-NUMA multi-die system, all dies become leaf pools.
+func (p *policy) splitDies(cpuNodes []system.ID, numa bool) map[system.ID][]system.ID {
+	dies := map[system.ID][]system.ID{}
+	for _, pkgID := range p.sys.PackageIDs() {
+		pkg := p.sys.Package(pkgID)
+		if len(pkg.DieIDs()) < 2 {
+			continue
+		}
+
+		if !numa {
+			for _, id := range pkg.DieIDs() {
+				if !pkg.DieCPUSet(id).IsEmpty() {
+					dies[pkgID] = append(dies[pkgID], id)
+				}
+			}
+			continue
+		}
+
+		nodes := map[system.ID]int{}
+		split := false
+		for _, id := range p.packageNodes(pkgID, cpuNodes) {
+			die, ok := p.nodeDie(id)
+			if !ok {
+				split = true
+				break
+			}
+			nodes[die]++
+		}
+		if split {
+			log.Warn("NUMA nodes of socket #%v span several dies, not modelling dies as pools", pkgID)
+			continue
+		}
+
+		for _, id := range pkg.DieIDs() {
+			if nodes[id] == 0 || (opt.CollapsePools && nodes[id] == 1) {
+				continue
+			}
+			dies[pkgID] = append(dies[pkgID], id)
+		}
+	}
+
+	return dies
+}
+
+// llcDie returns the die pool an LLC pool of a package belongs under, nil if
+// there is none. LLCs spanning several dies or covering a whole die get none.
+func (p *policy) llcDie(pkgID, llc system.ID, dieNodes map[system.ID]Node) Node {
+	cpus := p.sys.LLC(llc).CPUSet()
+	for id, n := range dieNodes {
+		diecpus := p.sys.Package(pkgID).DieCPUSet(id)
+		if cpus.IsSubsetOf(diecpus) && !cpus.Equals(diecpus) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nodeDie returns the die of the CPUs of a NUMA node, false if they are on several dies.
+func (p *policy) nodeDie(id system.ID) (system.ID, bool) {
+	cpus := p.sys.Node(id).CPUSet().ToSlice()
+	if len(cpus) == 0 {
+		return 0, false
+	}
+	die := p.sys.CPU(system.ID(cpus[0])).DieID()
+	for _, cpu := range cpus[1:] {
+		if p.sys.CPU(system.ID(cpu)).DieID() != die {
+			return 0, false
+		}
+	}
+	return die, true
+}
+
+// memoryOnlyNodes returns the memory-only (CPU-less) nodes attached to any of the given nodes.
+func (p *policy) memoryOnlyNodes(ids ...system.ID) system.IDSet {
+	attached := system.NewIDSet()
+	for _, id := range p.sys.NodeIDs() {
+		node := p.sys.Node(id)
+		if !node.IsMemoryOnly() {
+			continue
+		}
+		for _, cpuNode := range node.CPUNodeIDs() {
+			for _, nodeID := range ids {
+				if cpuNode == nodeID {
+					attached.Add(id)
+				}
+			}
+		}
+	}
+	return attached
+}
+
+// splitLLCs returns the last-level caches shared by a subset of the CPUs of each
+// NUMA node, or socket if numa is false. Caches shared by all CPUs of a node, or
+// by CPUs of several nodes, would not split the node so they are omitted.
+func (p *policy) splitLLCs(numa bool) map[system.ID][]system.ID {
+	parents := map[system.ID]cpuset.CPUSet{}
+	if numa {
+		for _, id := range p.sys.NodeIDs() {
+			if cpus := p.sys.Node(id).CPUSet(); !cpus.IsEmpty() {
+				parents[id] = cpus
+			}
+		}
+	} else {
+		for _, id := range p.sys.PackageIDs() {
+			parents[id] = p.sys.Package(id).CPUSet()
+		}
+	}
+
+	llcs := map[system.ID][]system.ID{}
+	for _, id := range p.sys.LLCIDs() {
+		cpus := p.sys.LLC(id).CPUSet()
+		for parent, pcpus := range parents {
+			if cpus.IsSubsetOf(pcpus) && !cpus.Equals(pcpus) {
+				llcs[parent] = append(llcs[parent], id)
+				break
+			}
+		}
+	}
+
+	return llcs
+}
+
 // Pick a pool and allocate resource from it to the container.
 func (p *policy) allocatePool(container cache.Container) (CPUGrant, error) {
 	var pool Node
 
-	request := newCPURequest(container)
+	request := p.leaseCPURequest(container, newCPURequest(container))
+	hugepages := hugePageRequest(container)
+	partition := p.llcPartitionPreference(container)
 
-	if container.GetNamespace() == kubernetes.NamespaceSystem {
+	if res := p.podReservation(container); res != nil {
+		pool = p.consumeReservation(res, request)
+		partition = false
+	} else if p.reservedPoolPreference(container) {
+		log.Debug("  => %s held in reserved pool", container.PrettyName())
 		pool = p.root
+		partition = false
 	} else {
 		affinity := p.calculatePoolAffinities(request.GetContainer())
-		scores, pools := p.sortPoolsByScore(request, affinity)
+		scores, pools := p.sortPoolsByScore(request, hugepages, affinity)
+		if pools = p.filterPoolsByLimits(pools); len(pools) == 0 {
+			return nil, policyError("failed to allocate %s: all pools are at their limits",
+				container.PrettyName())
+		}
+		if pools = p.filterPoolsByReservations(pools, container); len(pools) == 0 {
+			return nil, policyError("failed to allocate %s: free memory of all pools is reserved",
+				container.PrettyName())
+		}
 
 		if log.DebugEnabled() {
-			log.Debug("* node fitting for %s", request)
+			log.Debug("* node fitting for %s, hugepages %s", request, hugepages)
 			for idx, n := range pools {
-				log.Debug("    - #%d: node %s, score %s, affinity: %d",
-					idx, n.Name(), scores[n.NodeID()], affinity[n.NodeID()])
+				log.Debug("    - #%d: node %s, score %s, affinity: %d, free hugepages: %s",
+					idx, n.Name(), scores[n.NodeID()], affinity[n.NodeID()],
+					n.FreeHugePages())
 			}
 		}
 
+		p.recordScores(container, pools, scores, affinity)
+
 		pool = pools[0]
+		pinned, err := p.podPinnedPool(container, pools)
+		if err != nil {
+			return nil, err
+		}
+		stuck := false
+		if pinned != nil {
+			pool, partition = pinned, false
+		} else if sticky := p.stickyPools(container, pools, scores, hugepages); len(sticky) > 0 {
+			pools, pool, stuck = sticky, sticky[0], true
+		} else if rc := policyapi.GetRuntimeClassOptions(container); rc != nil && rc.SingleNUMANode {
+			if numa := singleNUMAPool(pools, scores, hugepages); numa != nil {
+				pool = numa
+			} else {
+				log.Warn("%s: no single NUMA node pool with enough capacity to align CPUs to",
+					container.PrettyName())
+			}
+		}
+		if !partition && pinned == nil && !stuck {
+			pool = p.pressurePool(container, request, pool)
+		}
+		if partition {
+			if llc := p.llcPartitionPool(pools, scores); llc != nil {
+				pool = llc
+			} else {
+				log.Warn("%s: no free LLC pool for an exclusive cache partition",
+					container.PrettyName())
+				partition = false
+			}
+		}
+	}
+
+	if !hugePagesFit(pool, hugepages) {
+		return nil, policyError("failed to allocate hugepages %s for %s: not enough free in %s",
+			hugepages, container.PrettyName(), pool.Name())
 	}
 
 	cpus := pool.FreeCPU()
@@ -112,20 +376,128 @@ func (p *policy) allocatePool(container cache.Container) (CPUGrant, error) {
 	}
 
 	p.allocations.CPU[container.GetCacheID()] = grant
+	p.allocateHugePages(container, pool)
+	p.chargePoolChurn(pool)
 	p.saveAllocations()
+	p.recordPlacement(container, pool)
+
+	if partition {
+		log.Debug("  => assigning exclusive partition of %s (RDT class %s)",
+			pool.Name(), opt.LLCPartitionClass)
+		container.SetRDTClass(opt.LLCPartitionClass)
+	}
 
 	return grant, nil
 }
 
+// singleNUMAPool picks the best fitting pool within a single NUMA node with enough capacity.
+func singleNUMAPool(pools []Node, scores map[int]CPUScore, hugepages HugePages) Node {
+	for _, pool := range pools {
+		switch pool.Kind() {
+		case NumaNode, LLCNode:
+		default:
+			continue
+		}
+		score := scores[pool.NodeID()]
+		if score.IsolatedCapacity() < 0 || score.SharedCapacity() < 0 {
+			continue
+		}
+		if !hugePagesFit(pool, hugepages) {
+			continue
+		}
+		return pool
+	}
+	return nil
+}
+
+// pressurePool widens the pool of a shared container under sustained CPU pressure.
+//
+// The resource manager tags the containers of pools under sustained pressure
+// with the resources under pressure. Such containers under CPU pressure without
+// exclusive CPUs are moved one level up in the pool tree, spreading them over
+// the CPUs of the parent pool. More CPUs would not relieve memory or I/O pressure.
+func (p *policy) pressurePool(container cache.Container, request CPURequest, pool Node) Node {
+	if !underCPUPressure(container) || request.FullCPUs() > 0 {
+		return pool
+	}
+	if pool.IsRootNode() {
+		return pool
+	}
+	parent := pool.Parent()
+	log.Info("%s: under resource pressure, expanding from pool %s to %s",
+		container.PrettyName(), pool.Name(), parent.Name())
+	return parent
+}
+
+// underCPUPressure checks if the container is tagged to be under sustained CPU pressure.
+func underCPUPressure(container cache.Container) bool {
+	value, ok := container.GetTag(cache.TagPressure)
+	if !ok {
+		return false
+	}
+	for _, resource := range strings.Split(value, ",") {
+		if resource == "cpu" {
+			return true
+		}
+	}
+	return false
+}
+
+// llcPartitionPreference checks if the container should get an exclusive LLC partition.
+func (p *policy) llcPartitionPreference(container cache.Container) bool {
+	if !opt.LLCPools || opt.LLCPartitionClass == "" {
+		return false
+	}
+	pod, ok := container.GetPod()
+	if !ok {
+		return false
+	}
+	return podLLCPartitionPreference(pod, container)
+}
+
+// llcPartitionPool picks the best fitting LLC pool not yet partitioned to any container.
+func (p *policy) llcPartitionPool(pools []Node, scores map[int]CPUScore) Node {
+	for _, pool := range pools {
+		if pool.Kind() != LLCNode {
+			continue
+		}
+		score := scores[pool.NodeID()]
+		if score.IsolatedCapacity() < 0 || score.SharedCapacity() < 0 {
+			continue
+		}
+		if owner := p.llcPartitionOwner(pool); owner != nil {
+			log.Debug("  - %s partitioned to %s", pool.Name(), owner.PrettyName())
+			continue
+		}
+		return pool
+	}
+	return nil
+}
+
+// llcPartitionOwner returns the container with the exclusive partition of an LLC pool, if any.
+func (p *policy) llcPartitionOwner(pool Node) cache.Container {
+	for _, grant := range p.allocations.CPU {
+		if !grant.GetNode().IsSameNode(pool) {
+			continue
+		}
+		if c := grant.GetContainer(); c.GetRDTClass() == opt.LLCPartitionClass {
+			return c
+		}
+	}
+	return nil
+}
+
 // Apply the result of allocation to the requesting container.
 func (p *policy) applyGrant(grant CPUGrant) error {
 	log.Debug("* applying grant %s", grant)
 
 	container := grant.GetContainer()
 	exclusive := grant.ExclusiveCPUs()
-	shared := grant.SharedCPUs()
+	shared := p.sharedCoreTypeCPUs(grant, grant.SharedCPUs().Union(p.burstCPUs(grant)))
 	portion := grant.SharedPortion()
 
+	container.SetTag(cache.TagPool, grant.GetNode().Name())
+
 	cpus := ""
 	kind := ""
 	if exclusive.IsEmpty() {
@@ -142,7 +514,9 @@ func (p *policy) applyGrant(grant CPUGrant) error {
 	mems := ""
 	node := grant.GetNode()
 	if !node.IsRootNode() && opt.PinMemory {
-		mems = node.GetMemset().String()
+		mems = p.pinnedMemset(container, p.memsetOf(container, node)).String()
+	} else {
+		container.DeleteTag(cache.TagMemoryPinning)
 	}
 
 	if opt.PinCPU {
@@ -153,7 +527,7 @@ func (p *policy) applyGrant(grant CPUGrant) error {
 		}
 		container.SetCpusetCpus(cpus)
 		if exclusive.IsEmpty() {
-			container.SetCPUShares(int64(cache.MilliCPUToShares(portion)))
+			container.SetCPUShares(p.sharedCPUShares(grant))
 		} else {
 			// Notes:
 			//   Hmm... I think setting CPU shares according to the normal formula
@@ -167,7 +541,7 @@ func (p *policy) applyGrant(grant CPUGrant) error {
 			//   itself to the shared subset will not get properly weighted wrt. other
 			//   processes sharing the same CPUs.
 			//
-			container.SetCPUShares(int64(cache.MilliCPUToShares(portion)))
+			container.SetCPUShares(p.sharedCPUShares(grant))
 		}
 	}
 
@@ -181,6 +555,75 @@ func (p *policy) applyGrant(grant CPUGrant) error {
 	return nil
 }
 
+// pinnedMemset applies the memory pinning mode of a container to its memory nodes.
+//
+// With strict pinning the container gets only the given nodes. With preferred
+// pinning it gets the memory nodes of the whole system, so it can fall back to
+// other nodes once the given ones run out of memory. Allocations still favor
+// the given nodes, since they are local to the CPUs of the container. The mode
+// is tagged for the memory controller to apply the corresponding cgroup controls.
+func (p *policy) pinnedMemset(container cache.Container, mems system.IDSet) system.IDSet {
+	mode := cache.MemoryPinningStrict
+	if pod, ok := container.GetPod(); ok {
+		mode = podMemoryPinningPreference(pod, container)
+	}
+
+	if mode == cache.MemoryPinningStrict {
+		container.DeleteTag(cache.TagMemoryPinning)
+		return mems
+	}
+	container.SetTag(cache.TagMemoryPinning, mode)
+
+	if mode == cache.MemoryPinningPreferred {
+		all := p.memsetOf(container, p.root)
+		all.Add(mems.Members()...)
+		log.Debug("  => %s prefers memory %s, falling back to %s",
+			container.PrettyName(), mems, all)
+		return all
+	}
+
+	log.Debug("  => %s spreads page cache over %s", container.PrettyName(), mems)
+	return mems
+}
+
+// memsetOf returns the memory nodes for a container allocated from the given pool.
+//
+// Containers with a memory type preference get only the nodes of the pool with
+// the preferred types of memory, if there are any. Otherwise the memory-only
+// nodes are included only if they are configured to be.
+func (p *policy) memsetOf(container cache.Container, node Node) system.IDSet {
+	mems := node.GetMemset()
+	memonly := node.GetMemoryOnlyNodes()
+
+	if pod, ok := container.GetPod(); ok {
+		if types := podMemoryTypePreference(pod, container); len(types) > 0 {
+			preferred := system.NewIDSet()
+			for _, id := range append(mems.Members(), memonly.Members()...) {
+				memType := p.sys.Node(id).MemoryType()
+				for _, t := range types {
+					if memType == t {
+						preferred.Add(id)
+					}
+				}
+			}
+			if preferred.Size() > 0 {
+				log.Debug("  => %s prefers memory types %v, using nodes %s",
+					container.PrettyName(), types, preferred)
+				return preferred
+			}
+			log.Warn("%s prefers memory types %v, but pool %s has no such memory",
+				container.PrettyName(), types, node.Name())
+			return mems
+		}
+	}
+
+	if opt.MemoryOnlyNodes {
+		mems.Add(memonly.Members()...)
+	}
+
+	return mems
+}
+
 // Release resources allocated by this grant.
 func (p *policy) releasePool(container cache.Container) (CPUGrant, bool, error) {
 	log.Debug("* releasing resources allocated to %s", container.PrettyName())
@@ -197,8 +640,11 @@ func (p *policy) releasePool(container cache.Container) (CPUGrant, bool, error)
 	cpus := pool.FreeCPU()
 
 	cpus.Release(grant)
+	p.releaseHugePages(container, pool)
 	delete(p.allocations.CPU, container.GetCacheID())
+	delete(p.scores, container.GetCacheID())
 	p.saveAllocations()
+	p.touchPlacement(container)
 
 	return grant, true, nil
 }
@@ -214,16 +660,52 @@ func (p *policy) updateSharedAllocations(grant CPUGrant) error {
 		}
 
 		if opt.PinCPU {
-			shared := other.GetNode().FreeCPU().SharableCPUs().String()
+			shared := p.sharedCoreTypeCPUs(other,
+				other.GetNode().FreeCPU().SharableCPUs().Union(p.burstCPUs(other))).String()
 			log.Debug("  => updating %s with shared CPUs of %s: %s...",
 				other, other.GetNode().Name(), shared)
 			other.GetContainer().SetCpusetCpus(shared)
+			if opt.ScaleSharedCPUShares {
+				other.GetContainer().SetCPUShares(p.sharedCPUShares(other))
+			}
 		}
 	}
 
 	return nil
 }
 
+// sharedCPUShares returns the CPU shares for the shared portion of a grant.
+//
+// By default CPU shares are calculated from the shared portion alone, like the
+// kubelet does. With ScaleSharedCPUShares, the shared portions of all containers
+// in a pool are scaled to the size of the shared CPUs of the pool, so that their
+// shares add up to the shares of the shared CPUs. This keeps relative fairness
+// between the containers correct as the shared CPUs of the pool grow or shrink.
+func (p *policy) sharedCPUShares(grant CPUGrant) int64 {
+	portion := grant.SharedPortion()
+	if !opt.ScaleSharedCPUShares || portion == 0 {
+		return cache.MilliCPUToShares(portion)
+	}
+
+	node := grant.GetNode()
+	size := node.FreeCPU().SharableCPUs().Size()
+	total := 0
+	for _, g := range p.allocations.CPU {
+		if g.GetNode().IsSameNode(node) {
+			total += g.SharedPortion()
+		}
+	}
+	if size == 0 || total == 0 {
+		return cache.MilliCPUToShares(portion)
+	}
+
+	shares := cache.MilliCPUToShares(int(int64(portion) * int64(1000*size) / int64(total)))
+	if shares > maxCPUShares {
+		shares = maxCPUShares
+	}
+	return shares
+}
+
 // addImplicitAffinities adds our set of policy-specific implicit affinities.
 func (p *policy) addImplicitAffinities() error {
 	return p.cache.AddImplicitAffinities(map[string]*cache.ImplicitAffinity{
@@ -241,6 +723,12 @@ func (p *policy) addImplicitAffinities() error {
 			},
 			Affinity: cache.GlobalAntiAffinity("tags/"+cache.TagAVX512, 5),
 		},
+		PolicyName + ":noisy-neighbor-push": {
+			Eligible: func(c cache.Container) bool {
+				return true
+			},
+			Affinity: cache.GlobalAntiAffinity("tags/"+cache.TagNoisyNeighbor, 5),
+		},
 	})
 }
 
@@ -248,6 +736,10 @@ func (p *policy) addImplicitAffinities() error {
 func (p *policy) calculatePoolAffinities(container cache.Container) map[int]int32 {
 	log.Debug("=> calculating pool affinities...")
 
+	// The weight of an affinity is accounted to the pool of the other container
+	// and to all pools overlapping with it, IOW to its ancestors and descendants.
+	// This pulls containers towards and pushes them away from each other across
+	// the whole pool tree, not just within the exact same pool.
 	result := make(map[int]int32, len(p.nodes))
 	for id, w := range p.calculateContainerAffinity(container) {
 		grant, ok := p.allocations.CPU[id]
@@ -256,6 +748,15 @@ func (p *policy) calculatePoolAffinities(container cache.Container) map[int]int3
 		}
 		node := grant.GetNode()
 		result[node.NodeID()] += w
+		for n := node.Parent(); !n.IsNil(); n = n.Parent() {
+			result[n.NodeID()] += w
+		}
+		node.DepthFirst(func(n Node) error {
+			if !n.IsSameNode(node) {
+				result[n.NodeID()] += w
+			}
+			return nil
+		})
 	}
 
 	return result
@@ -265,14 +766,7 @@ func (p *policy) calculatePoolAffinities(container cache.Container) map[int]int3
 func (p *policy) calculateContainerAffinity(container cache.Container) map[string]int32 {
 	log.Debug("* calculating affinity for container %s...", container.PrettyName())
 
-	ca := container.GetAffinity()
-
-	result := make(map[string]int32)
-	for _, a := range ca {
-		for id, w := range p.cache.EvaluateAffinity(a) {
-			result[id] += w
-		}
-	}
+	result := p.cache.EvaluateContainerAffinity(container)
 
 	log.Debug("  => affinity: %v", result)
 
@@ -280,24 +774,95 @@ func (p *policy) calculateContainerAffinity(container cache.Container) map[strin
 }
 
 // Score pools against the request and sort them by score.
-func (p *policy) sortPoolsByScore(req CPURequest, aff map[int]int32) (map[int]CPUScore, []Node) {
+func (p *policy) sortPoolsByScore(req CPURequest, hugepages HugePages,
+	aff map[int]int32) (map[int]CPUScore, []Node) {
 	scores := make(map[int]CPUScore, p.nodeCnt)
+	headroom := p.memBandwidthHeadroom()
 
 	p.root.DepthFirst(func(n Node) error {
 		scores[n.NodeID()] = n.GetScore(req)
 		return nil
 	})
+	weighted := p.weightedScores(req, scores, aff, headroom)
+	var power map[int]int64
+	if isPowerHeavy(req) {
+		power = p.powerHeadroom()
+	}
+	pack := false
+	if c := req.GetContainer(); c != nil {
+		if pod, ok := c.GetPod(); ok {
+			pack = podPlacementStrategy(pod, c) == strategyPack
+		}
+	}
 
 	sort.Slice(p.pools, func(i, j int) bool {
-		return p.compareScores(req, scores, aff, i, j)
+		return p.compareScores(req, hugepages, scores, aff, headroom, power, weighted, pack, i, j)
 	})
 
 	return scores, p.pools
 }
 
+// weightedScores calculates the weighted sum of scoring heuristics for all pools.
+//
+// Each heuristic is normalized to [-1, 1] by dividing it with its largest absolute
+// value across all pools, before weighting it. If no weights are configured, nil
+// is returned and pools are compared by strict precedence of the heuristics alone.
+func (p *policy) weightedScores(request CPURequest, scores map[int]CPUScore,
+	affinity map[int]int32, headroom map[int]int64) map[int]float64 {
+	w := opt.ScoringWeights
+	if !w.enabled() {
+		return nil
+	}
+
+	terms := map[int][]float64{}
+	max := make([]float64, 6)
+	for _, n := range p.pools {
+		id := n.NodeID()
+		score := scores[id]
+		hints, _ := combineHintScores(score.HintScores())
+		capacity := score.SharedCapacity()
+		if request.Isolate() {
+			capacity = score.IsolatedCapacity()
+		}
+		if capacity < 0 {
+			// pools with insufficient capacity lose anyway
+			capacity = 0
+		}
+		t := []float64{
+			float64(affinity[id]),
+			hints,
+			float64(n.RootDistance()),
+			float64(headroom[id]),
+			float64(capacity),
+			-float64(score.Colocated()),
+		}
+		for idx, v := range t {
+			if math.Abs(v) > max[idx] {
+				max[idx] = math.Abs(v)
+			}
+		}
+		terms[id] = t
+	}
+
+	weights := []float64{w.Affinity, w.HintMatch, w.Depth, w.MemoryBandwidth, w.FreeCapacity, w.Disturbance}
+	weighted := make(map[int]float64, len(terms))
+	for id, t := range terms {
+		sum := 0.0
+		for idx, v := range t {
+			if max[idx] != 0 {
+				sum += weights[idx] * v / max[idx]
+			}
+		}
+		weighted[id] = sum
+	}
+
+	return weighted
+}
+
 // Compare two pools by scores for allocation preference.
-func (p *policy) compareScores(request CPURequest, scores map[int]CPUScore,
-	affinity map[int]int32, i int, j int) bool {
+func (p *policy) compareScores(request CPURequest, hugepages HugePages,
+	scores map[int]CPUScore, affinity map[int]int32, headroom, power map[int]int64,
+	weighted map[int]float64, pack bool, i int, j int) bool {
 	node1, node2 := p.pools[i], p.pools[j]
 	depth1, depth2 := node1.RootDistance(), node2.RootDistance()
 	id1, id2 := node1.NodeID(), node2.NodeID()
@@ -305,28 +870,37 @@ func (p *policy) compareScores(request CPURequest, scores map[int]CPUScore,
 	isolated1, shared1 := score1.IsolatedCapacity(), score1.SharedCapacity()
 	isolated2, shared2 := score2.IsolatedCapacity(), score2.SharedCapacity()
 	affinity1, affinity2 := affinity[id1], affinity[id2]
+	membw1, membw2 := headroom[id1], headroom[id2]
+	power1, power2 := power[id1], power[id2]
+	hpfit1, hpfit2 := node1.FreeHugePages().Fits(hugepages), node2.FreeHugePages().Fits(hugepages)
 
 	//
 	// Notes:
 	//
 	// Our scoring/score sorting algorithm is:
 	//
-	// 1) - insufficient isolated or shared capacity loses
+	// 1) - insufficient isolated, shared or hugepage capacity loses
+	//    - with scoring weights configured, the higher weighted score wins
 	// 2) - if we have affinity, the higher affinity wins
 	// 3) - if we have topology hints
 	//       * better hint score wins
 	//       * for a tie, prefer the lower node then the smaller id
 	// 4) - if a node is lower in the tree it wins
-	// 5) - for isolated allocations
+	// 5) - more memory bandwidth headroom wins
+	// 6) - for isolated allocations
 	//       * more isolated capacity wins
 	//       * for a tie, prefer the smaller id
-	// 6) - for exclusive allocations
+	// 7) - for exclusive allocations
 	//       * more slicable (shared) capacity wins
 	//       * for a tie, prefer the smaller id
-	// 7) - for shared-only allocations
+	// 8) - for shared-only allocations
 	//       * fewer colocated containers win
 	//       * for a tie prefer more shared capacity then the smaller id
 	//
+	// With the pack placement strategy the comparisons of 6) - 8) are inverted:
+	// less capacity and more colocated containers win, consolidating containers
+	// to as few pools as possible.
+	//
 
 	// 1) a node with insufficient isolated or shared capacity loses
 	switch {
@@ -336,6 +910,21 @@ func (p *policy) compareScores(request CPURequest, scores map[int]CPUScore,
 		return false
 	}
 
+	// ... and so does one with insufficient hugepages
+	switch {
+	case !hpfit2 && hpfit1:
+		return true
+	case !hpfit1 && hpfit2:
+		return false
+	}
+
+	// ... then a higher weighted score wins, if we have scoring weights
+	if weighted != nil {
+		if diff := weighted[id1] - weighted[id2]; math.Abs(diff) > weightEpsilon {
+			return diff > 0
+		}
+	}
+
 	// 2) higher affinity wins
 	if affinity1 > affinity2 {
 		return true
@@ -387,7 +976,30 @@ func (p *policy) compareScores(request CPURequest, scores map[int]CPUScore,
 		return false
 	}
 
-	// 5) more isolated capacity wins
+	// 5) more memory bandwidth headroom wins
+	if membw1 > membw2 {
+		return true
+	}
+	if membw2 > membw1 {
+		return false
+	}
+
+	// ... then, for power-heavy requests, more package power headroom wins
+	if power1 > power2 {
+		return true
+	}
+	if power2 > power1 {
+		return false
+	}
+
+	colo1, colo2 := score1.Colocated(), score2.Colocated()
+	if pack {
+		isolated1, isolated2 = isolated2, isolated1
+		shared1, shared2 = shared2, shared1
+		colo1, colo2 = colo2, colo1
+	}
+
+	// 6) more isolated capacity wins
 	if request.Isolate() {
 		if isolated1 > isolated2 {
 			return true
@@ -398,7 +1010,7 @@ func (p *policy) compareScores(request CPURequest, scores map[int]CPUScore,
 		return id1 < id2
 	}
 
-	// 6) more slicable shared capacity wins
+	// 7) more slicable shared capacity wins
 	if request.FullCPUs() > 0 {
 		if shared1 > shared2 {
 			return true
@@ -410,11 +1022,11 @@ func (p *policy) compareScores(request CPURequest, scores map[int]CPUScore,
 		return id1 < id2
 	}
 
-	// 7) fewer colocated containers win
-	if score1.Colocated() < score2.Colocated() {
+	// 8) fewer colocated containers win
+	if colo1 < colo2 {
 		return true
 	}
-	if score2.Colocated() < score1.Colocated() {
+	if colo2 < colo1 {
 		return false
 	}
 
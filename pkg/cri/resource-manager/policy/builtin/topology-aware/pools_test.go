@@ -0,0 +1,324 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+	"github.com/intel/cri-resource-manager/pkg/sysfs/sysfstest"
+)
+
+func TestDiePools(t *testing.T) {
+	savedDies, savedCollapse := opt.DiePools, opt.CollapsePools
+	defer func() { opt.DiePools, opt.CollapsePools = savedDies, savedCollapse }()
+
+	tcases := []struct {
+		name     string
+		packages int // 0 for the default topology
+		nodes    int // NUMA nodes per package
+		dies     int // dies per package
+		collapse bool
+		pools    int
+		parents  map[string]string
+	}{
+		{
+			name:  "two NUMA nodes per die",
+			nodes: 4,
+			dies:  2,
+			pools: 15,
+			parents: map[string]string{
+				"die #0/0":     "socket #0",
+				"die #1/1":     "socket #1",
+				"numa node #1": "die #0/0",
+				"numa node #2": "die #0/1",
+				"numa node #7": "die #1/1",
+			},
+		},
+		{
+			name:  "one NUMA node per die",
+			nodes: 2,
+			dies:  2,
+			pools: 11,
+			parents: map[string]string{
+				"die #0/1":     "socket #0",
+				"numa node #1": "die #0/1",
+			},
+		},
+		{
+			name:     "one NUMA node per die, collapsed",
+			nodes:    2,
+			dies:     2,
+			collapse: true,
+			pools:    7,
+			parents: map[string]string{
+				"numa node #1": "socket #0",
+				"numa node #3": "socket #1",
+			},
+		},
+		{
+			name:     "one NUMA node per socket, collapsed",
+			nodes:    1,
+			collapse: true,
+			pools:    3,
+			parents: map[string]string{
+				"numa node #0": "root",
+				"numa node #1": "root",
+			},
+		},
+		{
+			name:  "NUMA nodes spanning dies",
+			nodes: 1,
+			dies:  2,
+			pools: 5,
+			parents: map[string]string{
+				"numa node #0": "socket #0",
+				"numa node #1": "socket #1",
+			},
+		},
+		{
+			name:     "single NUMA node, leaf dies",
+			packages: 1,
+			nodes:    1,
+			dies:     2,
+			pools:    3,
+			parents: map[string]string{
+				"die #0/0": "socket #0",
+				"die #0/1": "socket #0",
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			topology := sysfstest.DefaultTopology()
+			if tc.packages > 0 {
+				topology.Packages = tc.packages
+			}
+			topology.NodesPerPackage = tc.nodes
+			topology.DiesPerPackage = tc.dies
+			sys, cleanup, err := sysfstest.NewSystem(topology)
+			if err != nil {
+				t.Fatalf("failed to create system: %v", err)
+			}
+			defer cleanup()
+
+			opt.DiePools, opt.CollapsePools = true, tc.collapse
+			p := &policy{sys: sys, isolated: cpuset.NewCPUSet()}
+			if err := p.buildPoolsByTopology(); err != nil {
+				t.Fatalf("failed to build pools: %v", err)
+			}
+
+			if len(p.pools) != tc.pools {
+				t.Errorf("expected %d pools, got %d: %v", tc.pools, len(p.pools), p.poolNames())
+			}
+			for name, parent := range tc.parents {
+				pool, ok := p.nodes[name]
+				if !ok {
+					t.Errorf("expected pool %s, got pools %v", name, p.poolNames())
+					continue
+				}
+				if pool.Parent().Name() != parent {
+					t.Errorf("expected parent %s of pool %s, got %s", parent, name, pool.Parent().Name())
+				}
+			}
+			for _, pool := range p.nodes {
+				die, ok := pool.(*dienode)
+				if !ok {
+					continue
+				}
+				expected := topology.DieCPUs(int(die.syspkg.ID()), int(die.id))
+				if cpus := pool.GetCPU().SharableCPUs(); !cpus.Equals(expected) {
+					t.Errorf("expected CPUs %s for pool %s, got %s", expected, pool.Name(), cpus)
+				}
+			}
+		})
+	}
+}
+
+func TestMemsetOf(t *testing.T) {
+	saved := opt.MemoryOnlyNodes
+	defer func() { opt.MemoryOnlyNodes = saved }()
+
+	p := &policy{
+		sys: &mockSystem{
+			memTypes: map[system.ID]system.MemoryType{
+				2: system.MemoryTypeHBM,
+				3: system.MemoryTypePMEM,
+				4: system.MemoryTypeCXL,
+			},
+		},
+	}
+	pool := func(memonly ...system.ID) Node {
+		n := &numanode{id: 0}
+		n.name = "numa node #0"
+		n.mem = system.NewIDSet(0)
+		n.memonly = system.NewIDSet(memonly...)
+		return n
+	}
+
+	tcases := []struct {
+		name        string
+		annotations map[string]string
+		memonly     bool
+		pool        Node
+		expected    system.IDSet
+	}{
+		{
+			name:     "no preference",
+			pool:     pool(2, 3, 4),
+			expected: system.NewIDSet(0),
+		},
+		{
+			name:     "no preference with memory-only nodes",
+			memonly:  true,
+			pool:     pool(2, 3, 4),
+			expected: system.NewIDSet(0, 2, 3, 4),
+		},
+		{
+			name:        "HBM preference",
+			annotations: map[string]string{keyHBMPreference: "true"},
+			pool:        pool(2, 3, 4),
+			expected:    system.NewIDSet(2),
+		},
+		{
+			name:        "HBM preference without HBM",
+			annotations: map[string]string{keyHBMPreference: "true"},
+			pool:        pool(3, 4),
+			expected:    system.NewIDSet(0),
+		},
+		{
+			name:        "PMEM preference",
+			annotations: map[string]string{keyMemoryTypePreference: "pmem"},
+			pool:        pool(2, 3, 4),
+			expected:    system.NewIDSet(3),
+		},
+		{
+			name:        "DRAM and CXL preference",
+			annotations: map[string]string{keyMemoryTypePreference: "dram,cxl"},
+			pool:        pool(2, 3, 4),
+			expected:    system.NewIDSet(0, 4),
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			opt.MemoryOnlyNodes = tc.memonly
+			c := &mockContainer{
+				name: "testcontainer",
+				pod:  &mockPod{annotations: tc.annotations},
+			}
+			if tc.annotations == nil {
+				c.pod.annotations = map[string]string{}
+			}
+			mems := p.memsetOf(c, tc.pool)
+			if mems.String() != tc.expected.String() {
+				t.Errorf("expected memory nodes %s, got %s", tc.expected, mems)
+			}
+		})
+	}
+}
+
+func TestUnderCPUPressure(t *testing.T) {
+	tcases := []struct {
+		name     string
+		tags     map[string]string
+		expected bool
+	}{
+		{name: "not tagged", tags: map[string]string{}},
+		{name: "cpu pressure", tags: map[string]string{cache.TagPressure: "cpu"}, expected: true},
+		{name: "cpu and io pressure", tags: map[string]string{cache.TagPressure: "memory,cpu,io"}, expected: true},
+		{name: "memory pressure", tags: map[string]string{cache.TagPressure: "memory"}},
+		{name: "memory and io pressure", tags: map[string]string{cache.TagPressure: "memory,io"}},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &mockContainer{name: "ctr", tags: tc.tags}
+			if underCPUPressure(c) != tc.expected {
+				t.Errorf("expected CPU pressure %v for tags %v", tc.expected, tc.tags)
+			}
+		})
+	}
+}
+
+func TestComparePlacementStrategy(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	leaves := []int{}
+	for idx, pool := range p.pools {
+		if pool.RootDistance() == p.depth {
+			leaves = append(leaves, idx)
+		}
+	}
+	if len(leaves) < 2 {
+		t.Fatalf("expected at least 2 leaf pools, got %d", len(leaves))
+	}
+	i, j := leaves[0], leaves[1]
+	id1, id2 := p.pools[i].NodeID(), p.pools[j].NodeID()
+
+	tcases := []struct {
+		name    string
+		request *cpuRequest
+		score1  *cpuScore
+		score2  *cpuScore
+		spread  bool // whether pool i is preferred when spreading
+	}{
+		{
+			name:    "shared, fewer colocated containers",
+			request: &cpuRequest{fraction: 500},
+			score1:  &cpuScore{shared: 4000, colocated: 1},
+			score2:  &cpuScore{shared: 4000, colocated: 3},
+			spread:  true,
+		},
+		{
+			name:    "shared, same colocation, more shared capacity",
+			request: &cpuRequest{fraction: 500},
+			score1:  &cpuScore{shared: 2000, colocated: 2},
+			score2:  &cpuScore{shared: 6000, colocated: 2},
+			spread:  false,
+		},
+		{
+			name:    "exclusive, more shared capacity",
+			request: &cpuRequest{full: 2},
+			score1:  &cpuScore{shared: 6000, colocated: 3},
+			score2:  &cpuScore{shared: 4000},
+			spread:  true,
+		},
+		{
+			name:    "isolated, more isolated capacity",
+			request: &cpuRequest{full: 1, isolate: true},
+			score1:  &cpuScore{isolated: 1, shared: 8000},
+			score2:  &cpuScore{isolated: 3, shared: 2000},
+			spread:  false,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			scores := map[int]CPUScore{id1: tc.score1, id2: tc.score2}
+			compare := func(pack bool, i, j int) bool {
+				return p.compareScores(tc.request, HugePages{}, scores, nil, nil, nil, nil, pack, i, j)
+			}
+			if compare(false, i, j) != tc.spread || compare(false, j, i) == tc.spread {
+				t.Errorf("spread: expected pool %s preferred: %v", p.pools[i].Name(), tc.spread)
+			}
+			if compare(true, i, j) == tc.spread || compare(true, j, i) != tc.spread {
+				t.Errorf("pack: expected pool %s preferred: %v", p.pools[i].Name(), !tc.spread)
+			}
+		})
+	}
+}
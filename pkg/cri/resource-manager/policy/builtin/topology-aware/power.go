@@ -0,0 +1,93 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"time"
+
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+const (
+	// powerSampleInterval is the minimum interval between package power samples.
+	powerSampleInterval = time.Second
+)
+
+// isPowerHeavy checks if a request is heavy enough to be steered by power headroom.
+func isPowerHeavy(request CPURequest) bool {
+	if !opt.PowerAwarePlacement {
+		return false
+	}
+	return 1000*request.FullCPUs()+request.CPUFraction() >= 1000*opt.PowerHeavyCPUs
+}
+
+// powerHeadroom calculates the package power headroom (microwatts) of all pools.
+//
+// The headroom of a package is its RAPL power limit minus its measured power
+// consumption. The headroom of a pool is the sum of the headroom of all the
+// packages its CPUs are in. If package power can't be measured, nil is returned.
+func (p *policy) powerHeadroom() map[int]int64 {
+	packages := map[system.ID]int64{}
+	for _, z := range p.powerZones() {
+		power, ok := z.Power(powerSampleInterval)
+		if !ok {
+			continue
+		}
+		limit, err := z.PowerLimit()
+		if err != nil {
+			log.Warn("failed to read power limit of %s: %v", z, err)
+			continue
+		}
+		packages[z.Package] = int64(limit) - int64(power)
+	}
+	if len(packages) == 0 {
+		return nil
+	}
+
+	headroom := make(map[int]int64, p.nodeCnt)
+	p.root.DepthFirst(func(n Node) error {
+		cpus := n.GetCPU().SharableCPUs().Union(n.GetCPU().IsolatedCPUs())
+		for _, id := range p.sys.PackageIDs() {
+			h, ok := packages[id]
+			if !ok {
+				continue
+			}
+			if !cpus.Intersection(p.sys.Package(id).CPUSet()).IsEmpty() {
+				headroom[n.NodeID()] += h
+			}
+		}
+		return nil
+	})
+
+	return headroom
+}
+
+// powerZones returns the RAPL package power zones, discovering them on first use.
+func (p *policy) powerZones() []*system.PowerZone {
+	if p.zones != nil {
+		return p.zones
+	}
+
+	zones, err := system.DiscoverPowerZones()
+	if err != nil {
+		log.Error("failed to discover RAPL power zones: %v", err)
+	}
+	if zones == nil {
+		zones = []*system.PowerZone{}
+	}
+	p.zones = zones
+
+	return p.zones
+}
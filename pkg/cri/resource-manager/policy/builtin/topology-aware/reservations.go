@@ -0,0 +1,381 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// reservation is a set of resources reserved for a pod ahead of its creation.
+type reservation struct {
+	*policyapi.Reservation
+	pool   Node          // pool the resources are reserved from
+	cpus   cpuset.CPUSet // reserved CPUs not yet taken by containers of the pod
+	memory int64         // reserved memory not yet taken by containers of the pod
+}
+
+// reservations is our cache.Cachable for saving resource reservations in the cache.
+type reservations map[string]*reservation
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+//
+// Exclusive CPUs are sliced off the sharable CPUs of the smallest pool with
+// enough free CPU and memory. Both the CPUs and the memory are kept out of any
+// other allocation until the containers of the pod take them, or the reservation
+// expires or gets released.
+func (p *policy) ReserveResources(r *policyapi.Reservation) error {
+	p.expireReservations()
+
+	if r.PodUID == "" {
+		return policyError("can't reserve resources without a pod UID")
+	}
+	if r.CPUs < 0 || r.Memory < 0 {
+		return policyError("invalid reservation for pod %s: %d CPUs, %d bytes of memory",
+			r.PodUID, r.CPUs, r.Memory)
+	}
+	if _, ok := p.podReserved[r.PodUID]; ok {
+		log.Info("replacing reservation of pod %s", r.PodUID)
+		p.dropReservation(r.PodUID)
+	}
+
+	pool := p.reservationPool(r)
+	if pool == nil {
+		return policyError("no pool has %d free exclusive CPUs and %d bytes of free memory for pod %s",
+			r.CPUs, r.Memory, r.PodUID)
+	}
+
+	cpus := cpuset.NewCPUSet()
+	if r.CPUs > 0 {
+		var err error
+		supply := pool.FreeCPU().(*cpuSupply)
+		// take from a copy, the supply is left intact if we come up short
+		sharable := supply.sharable.Clone()
+		if cpus, err = takeCPUs(&sharable, nil, r.CPUs); err != nil {
+			return policyError("failed to reserve %d CPUs from %s for pod %s: %v",
+				r.CPUs, pool.Name(), r.PodUID, err)
+		}
+		if cpus.Size() != r.CPUs {
+			return policyError("failed to reserve %d CPUs from %s for pod %s, got only %q",
+				r.CPUs, pool.Name(), r.PodUID, cpus)
+		}
+		supply.sharable = sharable
+		grant := newCPUGrant(pool, nil, cpus, 0)
+		pool.DepthFirst(func(n Node) error {
+			n.FreeCPU().AccountAllocate(grant)
+			return nil
+		})
+	}
+
+	r.Pool = pool.Name()
+	r.Cpuset = cpus
+	r.Mems = pool.GetMemset().String()
+	p.podReserved[r.PodUID] = &reservation{Reservation: r, pool: pool, cpus: cpus, memory: r.Memory}
+	p.saveReservations()
+	p.cache.Save()
+
+	log.Info("reserved CPUs %q and %d bytes of memory from %s for pod %s, expiring at %s",
+		cpus, r.Memory, pool.Name(), r.PodUID, r.Expires.Format(time.RFC3339))
+
+	return nil
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (p *policy) ReleaseReservation(uid string) error {
+	p.expireReservations()
+
+	if _, ok := p.podReserved[uid]; !ok {
+		return nil
+	}
+
+	log.Info("releasing reservation of pod %s", uid)
+	p.dropReservation(uid)
+	p.cache.Save()
+
+	return nil
+}
+
+// reservationPool picks the pool to reserve resources from, preferring the smallest fitting one.
+func (p *policy) reservationPool(r *policyapi.Reservation) Node {
+	memory := p.grantedMemory()
+	pools := []Node{}
+	for _, pool := range p.pools {
+		supply := pool.FreeCPU().(*cpuSupply)
+		if r.CPUs > 0 && supply.slicable()/1000 <= r.CPUs {
+			continue
+		}
+		if r.Memory > 0 {
+			free := p.zoneMemory(pool, memory[pool.Name()]).Available
+			if free.Value() < r.Memory {
+				continue
+			}
+		}
+		pools = append(pools, pool)
+	}
+	if len(pools) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(pools, func(i, j int) bool {
+		if di, dj := pools[i].RootDistance(), pools[j].RootDistance(); di != dj {
+			return di > dj
+		}
+		si := pools[i].FreeCPU().(*cpuSupply).slicable()
+		sj := pools[j].FreeCPU().(*cpuSupply).slicable()
+		return si > sj
+	})
+
+	return pools[0]
+}
+
+// podReservation returns the unexpired reservation of the pod of a container, if any.
+func (p *policy) podReservation(container cache.Container) *reservation {
+	if len(p.podReserved) == 0 {
+		return nil
+	}
+
+	p.expireReservations()
+
+	pod, ok := container.GetPod()
+	if !ok {
+		return nil
+	}
+	return p.podReserved[pod.GetUID()]
+}
+
+// consumeReservation hands over reserved resources to a container and returns the pool to allocate from.
+//
+// Reserved CPUs are released back to the pool for the exclusive CPUs requested
+// by the container, which then gets allocated from the same pool. The memory
+// requested by the container is taken off the reserved memory. Once all of its
+// CPUs and memory have been taken, the reservation is dropped.
+func (p *policy) consumeReservation(res *reservation, request CPURequest) Node {
+	cnt := request.FullCPUs()
+	if cnt > res.cpus.Size() {
+		cnt = res.cpus.Size()
+	}
+	if cnt > 0 {
+		released := cpuset.NewCPUSet(res.cpus.ToSlice()[:cnt]...)
+		res.pool.FreeCPU().Release(newCPUGrant(res.pool, nil, released, 0))
+		res.cpus = res.cpus.Difference(released)
+	}
+	resources := request.GetContainer().GetResourceRequirements()
+	if memory, ok := resources.Requests[corev1.ResourceMemory]; ok {
+		if res.memory -= memory.Value(); res.memory < 0 {
+			res.memory = 0
+		}
+	}
+
+	log.Debug("  => using reservation of pod %s in %s (%d CPUs, %d bytes of memory left)",
+		res.PodUID, res.pool.Name(), res.cpus.Size(), res.memory)
+
+	if res.cpus.IsEmpty() && res.memory == 0 {
+		delete(p.podReserved, res.PodUID)
+	}
+	p.saveReservations()
+
+	return res.pool
+}
+
+// dropReservation releases all remaining resources of a reservation.
+func (p *policy) dropReservation(uid string) {
+	res, ok := p.podReserved[uid]
+	if !ok {
+		return
+	}
+	if !res.cpus.IsEmpty() {
+		res.pool.FreeCPU().Release(newCPUGrant(res.pool, nil, res.cpus, 0))
+	}
+	delete(p.podReserved, uid)
+	p.saveReservations()
+}
+
+// expireReservations drops all expired reservations.
+func (p *policy) expireReservations() {
+	now := time.Now()
+	for uid, res := range p.podReserved {
+		if res.Expired(now) {
+			log.Warn("reservation of pod %s in %s expired", uid, res.pool.Name())
+			p.dropReservation(uid)
+		}
+	}
+}
+
+// reservedMemory returns the memory held by reservations, charged to their pools and its parents.
+func (p *policy) reservedMemory() map[string]int64 {
+	reserved := map[string]int64{}
+	for _, res := range p.podReserved {
+		for n := res.pool; !n.IsNil(); n = n.Parent() {
+			reserved[n.Name()] += res.memory
+		}
+	}
+	return reserved
+}
+
+// filterPoolsByReservations filters out pools with too little memory left by reservations.
+//
+// Pools which would fit the memory request of the container if it wasn't for
+// the memory held for other pods are filtered out. Pools which wouldn't fit it
+// anyway are left alone, as allocations are not otherwise refused by memory.
+func (p *policy) filterPoolsByReservations(pools []Node, container cache.Container) []Node {
+	if len(p.podReserved) == 0 {
+		return pools
+	}
+	request, ok := container.GetResourceRequirements().Requests[corev1.ResourceMemory]
+	if !ok || request.Value() == 0 {
+		return pools
+	}
+
+	granted := p.grantedMemory()
+	reserved := p.reservedMemory()
+	filtered := make([]Node, 0, len(pools))
+	for _, pool := range pools {
+		held := reserved[pool.Name()]
+		if held > 0 {
+			free := p.zoneMemory(pool, 0).Capacity.Value() - granted[pool.Name()]
+			if free < request.Value() && free+held >= request.Value() {
+				log.Debug("  - %s has its free memory reserved for other pods", pool.Name())
+				continue
+			}
+		}
+		filtered = append(filtered, pool)
+	}
+
+	return filtered
+}
+
+// reinstateReservations takes the resources of reservations restored from the cache.
+func (p *policy) reinstateReservations() {
+	now := time.Now()
+	for uid, res := range p.podReserved {
+		if res.Expired(now) {
+			log.Warn("dropping expired reservation of pod %s", uid)
+			delete(p.podReserved, uid)
+			continue
+		}
+		pool, ok := p.nodes[res.Pool]
+		if !ok {
+			log.Error("dropping reservation of pod %s in unknown pool %s", uid, res.Pool)
+			delete(p.podReserved, uid)
+			continue
+		}
+		res.pool = pool
+
+		supply := pool.FreeCPU().(*cpuSupply)
+		if cpus := res.cpus.Intersection(supply.sharable); !cpus.Equals(res.cpus) {
+			log.Warn("CPUs %s reserved for pod %s are no longer free",
+				res.cpus.Difference(cpus), uid)
+			res.cpus = cpus
+		}
+		if !res.cpus.IsEmpty() {
+			supply.sharable = supply.sharable.Difference(res.cpus)
+			grant := newCPUGrant(pool, nil, res.cpus, 0)
+			pool.DepthFirst(func(n Node) error {
+				n.FreeCPU().AccountAllocate(grant)
+				return nil
+			})
+		}
+
+		log.Info("restored reservation of CPUs %q and %d bytes of memory from %s for pod %s",
+			res.cpus, res.memory, pool.Name(), uid)
+	}
+	p.saveReservations()
+}
+
+// cachedReservation is the cached representation of a reservation.
+type cachedReservation struct {
+	PodUID     string
+	CPUs       int
+	Memory     int64
+	Expires    time.Time
+	Pool       string
+	Cpuset     string
+	Mems       string
+	HeldCPUs   string
+	HeldMemory int64
+}
+
+func (res *reservation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&cachedReservation{
+		PodUID:     res.PodUID,
+		CPUs:       res.CPUs,
+		Memory:     res.Memory,
+		Expires:    res.Expires,
+		Pool:       res.Pool,
+		Cpuset:     res.Cpuset.String(),
+		Mems:       res.Mems,
+		HeldCPUs:   res.cpus.String(),
+		HeldMemory: res.memory,
+	})
+}
+
+func (res *reservation) UnmarshalJSON(data []byte) error {
+	cres := cachedReservation{}
+	if err := json.Unmarshal(data, &cres); err != nil {
+		return policyError("failed to restore reservation: %v", err)
+	}
+
+	cpus, err := cpuset.Parse(cres.HeldCPUs)
+	if err != nil {
+		return policyError("failed to restore reservation of pod %s: %v", cres.PodUID, err)
+	}
+	reserved, err := cpuset.Parse(cres.Cpuset)
+	if err != nil {
+		return policyError("failed to restore reservation of pod %s: %v", cres.PodUID, err)
+	}
+
+	res.Reservation = &policyapi.Reservation{
+		PodUID:  cres.PodUID,
+		CPUs:    cres.CPUs,
+		Memory:  cres.Memory,
+		Expires: cres.Expires,
+		Pool:    cres.Pool,
+		Cpuset:  reserved,
+		Mems:    cres.Mems,
+	}
+	res.cpus = cpus
+	res.memory = cres.HeldMemory
+
+	return nil
+}
+
+// Get returns the reservations for caching.
+func (r *reservations) Get() interface{} {
+	return r
+}
+
+// Set sets the reservations from cached ones.
+func (r *reservations) Set(value interface{}) {
+	var from reservations
+
+	switch value.(type) {
+	case reservations:
+		from = value.(reservations)
+	case *reservations:
+		from = *value.(*reservations)
+	}
+
+	*r = make(reservations, len(from))
+	for uid, res := range from {
+		(*r)[uid] = res
+	}
+}
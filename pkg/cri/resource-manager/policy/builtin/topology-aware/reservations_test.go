@@ -0,0 +1,296 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/sysfs/sysfstest"
+)
+
+// allocateLowestCpus allocates the lowest numbered CPUs, ignoring the host topology.
+func allocateLowestCpus(from *cpuset.CPUSet, cnt int, _ bool) (cpuset.CPUSet, error) {
+	if from.Size() < cnt {
+		return cpuset.NewCPUSet(), fmt.Errorf("cpuset %s does not have %d CPUs", from, cnt)
+	}
+	cpus := cpuset.NewCPUSet(from.ToSlice()[:cnt]...)
+	*from = from.Difference(cpus)
+	return cpus, nil
+}
+
+// newReservationTestPolicy creates a policy with pools for the default test topology.
+// CPUs are allocated without looking at the host, which the test topology is not.
+func newReservationTestPolicy(t *testing.T) (*policy, func()) {
+	sys, removeSystem, err := sysfstest.NewSystem(sysfstest.DefaultTopology())
+	if err != nil {
+		t.Fatalf("failed to create system: %v", err)
+	}
+	saved := allocateCpus
+	allocateCpus = allocateLowestCpus
+	cleanup := func() {
+		allocateCpus = saved
+		removeSystem()
+	}
+	p := &policy{
+		sys:         sys,
+		cache:       &mockCache{},
+		isolated:    cpuset.NewCPUSet(),
+		allocations: allocations{CPU: map[string]CPUGrant{}},
+		podReserved: make(reservations),
+	}
+	if err := p.buildPoolsByTopology(); err != nil {
+		cleanup()
+		t.Fatalf("failed to build pools: %v", err)
+	}
+	return p, cleanup
+}
+
+// memoryContainer returns a container requesting the given amount of memory.
+func memoryContainer(name string, memory int64) *mockContainer {
+	return &mockContainer{
+		name: name,
+		returnValueForGetResourceRequirements: v1.ResourceRequirements{
+			Requests: v1.ResourceList{
+				v1.ResourceMemory: *resource.NewQuantity(memory, resource.BinarySI),
+			},
+		},
+	}
+}
+
+func TestReserveResources(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	expires := time.Now().Add(time.Minute)
+	r1 := &policyapi.Reservation{PodUID: "pod1", CPUs: 2, Memory: 10 << 30, Expires: expires}
+	if err := p.ReserveResources(r1); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	pool, ok := p.nodes[r1.Pool]
+	if !ok {
+		t.Fatalf("reservation in unknown pool %q", r1.Pool)
+	}
+	if pool.RootDistance() != p.depth {
+		t.Errorf("expected reservation from a leaf pool, got %s", pool.Name())
+	}
+	if r1.Cpuset.Size() != 2 || !r1.Cpuset.IsSubsetOf(pool.GetCPU().SharableCPUs()) {
+		t.Errorf("expected 2 CPUs of %s reserved, got %s", pool.Name(), r1.Cpuset)
+	}
+	if free := pool.FreeCPU().SharableCPUs(); !free.Intersection(r1.Cpuset).IsEmpty() {
+		t.Errorf("reserved CPUs %s still free in %s (%s)", r1.Cpuset, pool.Name(), free)
+	}
+
+	// the memory held for pod1 doesn't leave enough for another 10G in the same pool
+	r2 := &policyapi.Reservation{PodUID: "pod2", Memory: 10 << 30, Expires: expires}
+	if err := p.ReserveResources(r2); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	if r2.Pool == r1.Pool {
+		t.Errorf("expected memory of pod2 reserved from another pool than %s", r1.Pool)
+	}
+
+	// no pool has 64G of memory
+	r3 := &policyapi.Reservation{PodUID: "pod3", Memory: 64 << 30, Expires: expires}
+	if err := p.ReserveResources(r3); err == nil {
+		t.Errorf("expected reservation of 64G of memory to fail, got pool %s", r3.Pool)
+	}
+
+	if err := p.ReleaseReservation("pod1"); err != nil {
+		t.Fatalf("failed to release reservation: %v", err)
+	}
+	if _, ok := p.podReserved["pod1"]; ok {
+		t.Errorf("expected reservation of pod1 released")
+	}
+	if free := pool.FreeCPU().SharableCPUs(); !r1.Cpuset.IsSubsetOf(free) {
+		t.Errorf("released CPUs %s not free in %s (%s)", r1.Cpuset, pool.Name(), free)
+	}
+}
+
+func TestReserveResourcesShortOfCPUs(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	// an allocator coming up one CPU short, like the host one can
+	allocateCpus = func(from *cpuset.CPUSet, cnt int, prefer bool) (cpuset.CPUSet, error) {
+		return allocateLowestCpus(from, cnt-1, prefer)
+	}
+
+	free := map[string]cpuset.CPUSet{}
+	for _, pool := range p.pools {
+		free[pool.Name()] = pool.FreeCPU().SharableCPUs()
+	}
+
+	r := &policyapi.Reservation{PodUID: "pod1", CPUs: 2, Expires: time.Now().Add(time.Minute)}
+	if err := p.ReserveResources(r); err == nil {
+		t.Errorf("expected reservation of %d CPUs to fail, got %s", r.CPUs, r.Cpuset)
+	}
+	if _, ok := p.podReserved["pod1"]; ok {
+		t.Errorf("unexpected reservation of pod1")
+	}
+	for _, pool := range p.pools {
+		if cpus := pool.FreeCPU().SharableCPUs(); !cpus.Equals(free[pool.Name()]) {
+			t.Errorf("expected free CPUs of %s intact (%s), got %s", pool.Name(), free[pool.Name()], cpus)
+		}
+	}
+}
+
+func TestExpireReservations(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	r := &policyapi.Reservation{PodUID: "pod1", CPUs: 2, Expires: time.Now().Add(-time.Second)}
+	if err := p.ReserveResources(r); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	p.expireReservations()
+	if _, ok := p.podReserved["pod1"]; ok {
+		t.Errorf("expected expired reservation of pod1 dropped")
+	}
+	if free := p.nodes[r.Pool].FreeCPU().SharableCPUs(); !r.Cpuset.IsSubsetOf(free) {
+		t.Errorf("CPUs %s of expired reservation not free in %s (%s)", r.Cpuset, r.Pool, free)
+	}
+}
+
+func TestConsumeReservation(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	r := &policyapi.Reservation{PodUID: "pod1", CPUs: 2, Memory: 10 << 30, Expires: time.Now().Add(time.Minute)}
+	if err := p.ReserveResources(r); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	res := p.podReserved["pod1"]
+
+	c1 := memoryContainer("c1", 4<<30)
+	if pool := p.consumeReservation(res, &cpuRequest{container: c1, full: 1}); pool.Name() != r.Pool {
+		t.Errorf("expected pool %s, got %s", r.Pool, pool.Name())
+	}
+	if res.cpus.Size() != 1 || res.memory != 6<<30 {
+		t.Errorf("expected 1 CPU and 6G of memory left, got %s and %d", res.cpus, res.memory)
+	}
+	if _, ok := p.podReserved["pod1"]; !ok {
+		t.Fatalf("expected partially consumed reservation kept")
+	}
+	if free := p.nodes[r.Pool].FreeCPU().SharableCPUs(); free.Intersection(r.Cpuset).Size() != 1 {
+		t.Errorf("expected 1 of reserved CPUs %s released to %s (%s)", r.Cpuset, r.Pool, free)
+	}
+
+	c2 := memoryContainer("c2", 8<<30)
+	p.consumeReservation(res, &cpuRequest{container: c2, full: 1})
+	if _, ok := p.podReserved["pod1"]; ok {
+		t.Errorf("expected fully consumed reservation dropped")
+	}
+}
+
+func TestFilterPoolsByReservations(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	r := &policyapi.Reservation{PodUID: "pod1", Memory: 10 << 30, Expires: time.Now().Add(time.Minute)}
+	if err := p.ReserveResources(r); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	reserved := p.nodes[r.Pool]
+
+	tcases := []struct {
+		name     string
+		memory   int64
+		filtered bool
+	}{
+		{
+			name:   "fits next to reservation",
+			memory: 4 << 30,
+		},
+		{
+			name:     "doesn't fit because of reservation",
+			memory:   8 << 30,
+			filtered: true,
+		},
+		{
+			name:   "wouldn't fit anyway",
+			memory: 32 << 30,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pools := p.filterPoolsByReservations(p.pools, memoryContainer("c", tc.memory))
+			found := false
+			for _, pool := range pools {
+				found = found || pool.Name() == reserved.Name()
+			}
+			if found == tc.filtered {
+				t.Errorf("expected pool %s filtered: %v, got %v", reserved.Name(), tc.filtered, !found)
+			}
+			if len(pools) < len(p.pools)-p.depth-1 {
+				t.Errorf("expected at most the pool and its parents filtered, got %d of %d pools",
+					len(pools), len(p.pools))
+			}
+		})
+	}
+}
+
+func TestReservationMarshalling(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	expires := time.Now().Add(time.Minute)
+	r1 := &policyapi.Reservation{PodUID: "pod1", CPUs: 2, Memory: 1 << 30, Expires: expires}
+	if err := p.ReserveResources(r1); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	r2 := &policyapi.Reservation{PodUID: "pod2", CPUs: 1, Expires: expires}
+	if err := p.ReserveResources(r2); err != nil {
+		t.Fatalf("failed to reserve resources: %v", err)
+	}
+	p.podReserved["pod2"].Expires = time.Now().Add(-time.Second)
+
+	data, err := json.Marshal(&p.podReserved)
+	if err != nil {
+		t.Fatalf("failed to marshal reservations: %v", err)
+	}
+
+	restarted, cleanup2 := newReservationTestPolicy(t)
+	defer cleanup2()
+	if err := json.Unmarshal(data, &restarted.podReserved); err != nil {
+		t.Fatalf("failed to unmarshal reservations: %v", err)
+	}
+	restarted.reinstateReservations()
+
+	if _, ok := restarted.podReserved["pod2"]; ok {
+		t.Errorf("expected expired reservation of pod2 dropped")
+	}
+	res, ok := restarted.podReserved["pod1"]
+	if !ok {
+		t.Fatalf("expected reservation of pod1 restored")
+	}
+	if res.pool == nil || res.pool.Name() != r1.Pool {
+		t.Errorf("expected reservation restored in pool %s", r1.Pool)
+	}
+	if !res.cpus.Equals(r1.Cpuset) || res.memory != 1<<30 || !res.Expires.Equal(expires) {
+		t.Errorf("expected CPUs %s, 1G of memory until %v, got %s, %d until %v",
+			r1.Cpuset, expires, res.cpus, res.memory, res.Expires)
+	}
+	if free := res.pool.FreeCPU().SharableCPUs(); !free.Intersection(r1.Cpuset).IsEmpty() {
+		t.Errorf("restored reserved CPUs %s still free in %s (%s)", r1.Cpuset, r1.Pool, free)
+	}
+}
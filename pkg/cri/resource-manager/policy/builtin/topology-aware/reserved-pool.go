@@ -0,0 +1,149 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// tagReservedPool tags containers held in the reserved pool with the namespace pattern they matched.
+	tagReservedPool = "topology-aware/reserved-pool"
+)
+
+// namespaceRegexps are the compiled regular expressions of reserved pool namespace patterns.
+var namespaceRegexps = map[string]*regexp.Regexp{}
+
+// isRegexpPattern checks if a namespace pattern is a /regular expression/.
+func isRegexpPattern(pattern string) bool {
+	return len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// validateNamespacePattern checks a namespace glob pattern or /regular expression/.
+func validateNamespacePattern(pattern string) error {
+	if isRegexpPattern(pattern) {
+		if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+			return policyError("invalid namespace regexp %s: %v", pattern, err)
+		}
+		return nil
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return policyError("invalid namespace pattern %q: %v", pattern, err)
+	}
+	return nil
+}
+
+// matchNamespace checks if a namespace matches a glob pattern or /regular expression/.
+func matchNamespace(pattern, namespace string) bool {
+	if !isRegexpPattern(pattern) {
+		ok, _ := filepath.Match(pattern, namespace)
+		return ok
+	}
+
+	re, ok := namespaceRegexps[pattern]
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+			log.Error("invalid namespace regexp %s: %v", pattern, err)
+			return false
+		}
+		namespaceRegexps[pattern] = re
+	}
+	return re.MatchString(namespace)
+}
+
+// reservedPoolPattern returns the reserved pool namespace pattern a namespace matches, if any.
+func reservedPoolPattern(namespace string) (string, bool) {
+	for _, pattern := range opt.ReservedPoolNamespaces {
+		if matchNamespace(pattern, namespace) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// isReservedPoolNamespace checks if containers of a namespace belong to the reserved pool.
+func isReservedPoolNamespace(namespace string) bool {
+	_, ok := reservedPoolPattern(namespace)
+	return ok
+}
+
+// reservedPoolPreference checks if a container should be placed in the reserved pool.
+//
+// Containers of namespaces matching any of the configured reserved pool namespace
+// patterns are placed in the reserved pool. These containers get shared CPUs only.
+// Containers are tagged with the pattern they matched, or untagged if none.
+func (p *policy) reservedPoolPreference(container cache.Container) bool {
+	pattern, ok := reservedPoolPattern(container.GetNamespace())
+	if !ok {
+		container.DeleteTag(tagReservedPool)
+		return false
+	}
+	container.SetTag(tagReservedPool, pattern)
+	return true
+}
+
+// updateReservedPool moves containers in or out of the reserved pool after a configuration change.
+func (p *policy) updateReservedPool() {
+	namespaceRegexps = map[string]*regexp.Regexp{}
+
+	moved := []cache.Container{}
+	for _, grant := range p.allocations.CPU {
+		c := grant.GetContainer()
+		_, held := c.GetTag(tagReservedPool)
+		if held != isReservedPoolNamespace(c.GetNamespace()) {
+			moved = append(moved, c)
+		}
+	}
+
+	for _, c := range moved {
+		if _, held := c.GetTag(tagReservedPool); held {
+			log.Info("moving %s out of the reserved pool", c.PrettyName())
+		} else {
+			log.Info("moving %s into the reserved pool", c.PrettyName())
+		}
+		if err := p.UpdateResources(c); err != nil {
+			log.Error("failed to move %s: %v", c.PrettyName(), err)
+		}
+	}
+
+	reserved := p.reservedPoolContainers()
+	names := make([]string, 0, len(reserved))
+	for _, c := range reserved {
+		names = append(names, c.PrettyName())
+	}
+	log.Info("  - reserved pool namespaces: %s", strings.Join(opt.ReservedPoolNamespaces, ", "))
+	log.Info("  - containers in reserved pool: %s", strings.Join(names, ", "))
+}
+
+// reservedPoolContainers returns the containers currently held in the reserved pool.
+func (p *policy) reservedPoolContainers() []cache.Container {
+	containers := []cache.Container{}
+	for _, grant := range p.allocations.CPU {
+		c := grant.GetContainer()
+		if _, held := c.GetTag(tagReservedPool); held {
+			containers = append(containers, c)
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].PrettyName() < containers[j].PrettyName()
+	})
+	return containers
+}
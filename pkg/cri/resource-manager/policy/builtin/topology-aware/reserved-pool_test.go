@@ -0,0 +1,61 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+)
+
+func TestReservedPoolPattern(t *testing.T) {
+	saved := opt.ReservedPoolNamespaces
+	defer func() { opt.ReservedPoolNamespaces = saved }()
+	opt.ReservedPoolNamespaces = []string{"kube-system", "monitoring-*", "/^team-[0-9]+$/"}
+
+	tcases := []struct {
+		namespace string
+		expected  string
+	}{
+		{namespace: "kube-system", expected: "kube-system"},
+		{namespace: "monitoring-prometheus", expected: "monitoring-*"},
+		{namespace: "team-42", expected: "/^team-[0-9]+$/"},
+		{namespace: "team-a"},
+		{namespace: "default"},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.namespace, func(t *testing.T) {
+			pattern, ok := reservedPoolPattern(tc.namespace)
+			switch {
+			case tc.expected == "" && ok:
+				t.Errorf("expected no match, got pattern %q", pattern)
+			case tc.expected != "" && pattern != tc.expected:
+				t.Errorf("expected pattern %q, got %q", tc.expected, pattern)
+			}
+		})
+	}
+}
+
+func TestValidateNamespacePattern(t *testing.T) {
+	for pattern, valid := range map[string]bool{
+		"kube-system":  true,
+		"kube-*":       true,
+		"/^kube-.*$/":  true,
+		"/^kube-(.*$/": false,
+		"kube-[":       false,
+	} {
+		if err := validateNamespacePattern(pattern); (err == nil) != valid {
+			t.Errorf("pattern %q: expected valid %v, got error %v", pattern, valid, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// ReviewResourceUpdate rejects updates growing a container beyond the free CPU capacity.
+//
+// Updates are applied by releasing the resources of the container and then
+// allocating them anew from a single pool. An update growing the CPU request
+// of the container beyond what is free in the best pool would leave the
+// container with no resources at all, so we reject it up front. The resources
+// released by the container are counted as free in its own pool, and in the
+// pools above and below it, which share CPUs with it.
+func (p *policy) ReviewResourceUpdate(c cache.Container, u *policyapi.ResourceUpdate) {
+	qty, ok := u.Resources.Requests[corev1.ResourceCPU]
+	if !ok {
+		return
+	}
+
+	var node Node
+	current := 0
+	if grant, ok := p.allocations.CPU[c.GetCacheID()]; ok {
+		node = grant.GetNode()
+		current = 1000*grant.ExclusiveCPUs().Size() + grant.SharedPortion()
+	}
+
+	best, free := p.freestPool(node, current)
+	if requested := int(qty.MilliValue()); requested > free {
+		u.Reject(fmt.Sprintf("CPU request %s exceeds the free capacity of %dm of the freest pool %s",
+			qty.String(), free, best.Name()))
+	}
+}
+
+// freestPool returns the pool with the most free CPU capacity, and that capacity,
+// counting the given capacity as free in the pools sharing CPUs with node.
+func (p *policy) freestPool(node Node, released int) (Node, int) {
+	var best Node
+	max := 0
+	for _, pool := range p.pools {
+		supply := pool.FreeCPU()
+		free := 1000*(supply.SharableCPUs().Size()+supply.IsolatedCPUs().Size()) - pool.GrantedCPU()
+		if node != nil && (isDescendant(pool, node) || isDescendant(node, pool)) {
+			free += released
+		}
+		if best == nil || free > max {
+			best, max = pool, free
+		}
+	}
+	return best, max
+}
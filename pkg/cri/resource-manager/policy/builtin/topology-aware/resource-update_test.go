@@ -0,0 +1,115 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// cpuRequirements returns resource requirements with the given CPU request.
+func cpuRequirements(milliCPU int64) v1.ResourceRequirements {
+	return v1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			v1.ResourceCPU: *resource.NewMilliQuantity(milliCPU, resource.DecimalSI),
+		},
+	}
+}
+
+func TestReviewResourceUpdate(t *testing.T) {
+	p, cleanup := newReservationTestPolicy(t)
+	defer cleanup()
+
+	// grant 6 shared CPUs in each of the 4 NUMA nodes of 8 CPUs, leaving
+	// 2 CPUs free per NUMA node, 4 per socket and 8 in the root pool
+	var owner *mockContainer
+	for _, pool := range p.pools {
+		if pool.Kind() != NumaNode {
+			continue
+		}
+		c := &mockContainer{
+			name:                                  pool.Name(),
+			returnValueForGetCacheID:              pool.Name(),
+			returnValueForGetResourceRequirements: cpuRequirements(6000),
+		}
+		g, err := pool.FreeCPU().Allocate(&cpuRequest{container: c, fraction: 6000})
+		if err != nil {
+			t.Fatalf("failed to allocate CPU from %s: %v", pool.Name(), err)
+		}
+		p.allocations.CPU[c.GetCacheID()] = g
+		if owner == nil {
+			owner = c
+		}
+	}
+	if owner == nil {
+		t.Fatalf("no NUMA node pools in test topology")
+	}
+	other := &mockContainer{
+		name:                     "other",
+		returnValueForGetCacheID: "other",
+	}
+
+	tcases := []struct {
+		name      string
+		container *mockContainer
+		current   int64
+		requested int64
+		rejected  bool
+	}{
+		{
+			name:      "new request fitting the freest pool",
+			container: other,
+			requested: 8000,
+		},
+		{
+			name:      "new request exceeding the freest pool",
+			container: other,
+			requested: 8001,
+			rejected:  true,
+		},
+		{
+			name:      "growing within the freest pool and the current grant",
+			container: owner,
+			current:   6000,
+			requested: 14000,
+		},
+		{
+			name:      "growing beyond the freest pool and the current grant",
+			container: owner,
+			current:   6000,
+			requested: 14001,
+			rejected:  true,
+		},
+		{
+			name:      "shrinking",
+			container: owner,
+			current:   6000,
+			requested: 1000,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := policyapi.NewResourceUpdate(cpuRequirements(tc.current), cpuRequirements(tc.requested))
+			p.ReviewResourceUpdate(tc.container, u)
+			if rejected := u.Verdict == policyapi.UpdateRejected; rejected != tc.rejected {
+				t.Errorf("expected rejected %v, got %s", tc.rejected, u)
+			}
+		})
+	}
+}
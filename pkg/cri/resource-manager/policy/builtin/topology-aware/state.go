@@ -0,0 +1,98 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// scoreRecords are the last pool scorings of containers, by container cache ID.
+type scoreRecords map[string][]*policyapi.ScoreState
+
+// GetState returns a snapshot of our pool tree, grants and last pool scorings.
+func (p *policy) GetState() *policyapi.State {
+	state := &policyapi.State{}
+
+	p.root.BreadthFirst(func(n Node) error {
+		supply := n.GetCPU()
+		pool := &policyapi.PoolState{
+			Name: n.Name(),
+			Kind: string(n.Kind()),
+			Attributes: map[string]string{
+				"isolated":  supply.IsolatedCPUs().String(),
+				"sharable":  supply.SharableCPUs().String(),
+				"free":      n.FreeCPU().String(),
+				"granted":   strconv.Itoa(n.GrantedCPU()) + "m",
+				"mems":      n.GetMemset().String(),
+				"hugepages": n.FreeHugePages().String(),
+			},
+		}
+		if !n.IsRootNode() {
+			pool.Parent = n.Parent().Name()
+		}
+		state.Pools = append(state.Pools, pool)
+		return nil
+	})
+
+	for _, grant := range p.allocations.CPU {
+		c := grant.GetContainer()
+		state.Grants = append(state.Grants, &policyapi.GrantState{
+			Container: c.PrettyName(),
+			Pool:      grant.GetNode().Name(),
+			Attributes: map[string]string{
+				"exclusive": grant.ExclusiveCPUs().String(),
+				"isolated":  grant.IsolatedCPUs().String(),
+				"shared":    strconv.Itoa(grant.SharedPortion()) + "m",
+				"cpus":      c.GetCpusetCpus(),
+				"mems":      c.GetCpusetMems(),
+			},
+		})
+	}
+
+	for _, scores := range p.scores {
+		state.Scores = append(state.Scores, scores...)
+	}
+
+	return state
+}
+
+// recordScores records the scoring of pools for a container, for inspecting its placement.
+func (p *policy) recordScores(container cache.Container, pools []Node,
+	scores map[int]CPUScore, affinity map[int]int32) {
+	recorded := make([]*policyapi.ScoreState, 0, len(pools))
+	for rank, n := range pools {
+		score := scores[n.NodeID()]
+		hints, _ := combineHintScores(score.HintScores())
+		recorded = append(recorded, &policyapi.ScoreState{
+			Container: container.PrettyName(),
+			Pool:      n.Name(),
+			Rank:      rank,
+			Attributes: map[string]string{
+				"isolated":  strconv.Itoa(score.IsolatedCapacity()),
+				"shared":    strconv.Itoa(score.SharedCapacity()),
+				"colocated": strconv.Itoa(score.Colocated()),
+				"affinity":  strconv.Itoa(int(affinity[n.NodeID()])),
+				"hints":     fmt.Sprintf("%.3f", hints),
+				"depth":     strconv.Itoa(n.RootDistance()),
+			},
+		})
+	}
+	p.scores[container.GetCacheID()] = recorded
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// placementRecord is the NUMA node a container of a pod was last placed on.
+type placementRecord struct {
+	// Pool is the name of the NUMA node pool.
+	Pool string
+	// LastUsed is when the container was last allocated or released.
+	LastUsed time.Time
+}
+
+// placementHistory is our cache.Cachable for saving placement history in the cache.
+//
+// Records are keyed by the namespace and name of the pod, and the name of the
+// container, instead of the pod UID. This way they outlive their pods and can
+// be looked up for the recreated pods of StatefulSets, which keep their names.
+type placementHistory map[string]*placementRecord
+
+// stickyPlacementTTL returns how long placement history is kept, 0 if disabled.
+func stickyPlacementTTL() time.Duration {
+	if opt.StickyPlacementTTL == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(opt.StickyPlacementTTL)
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
+
+// placementKey returns the placement history key of a container.
+func placementKey(container cache.Container) (string, bool) {
+	pod, ok := container.GetPod()
+	if !ok {
+		return "", false
+	}
+	return pod.GetNamespace() + "/" + pod.GetName() + "/" + container.GetName(), true
+}
+
+// numaNodeOf returns the NUMA node a pool is, or is within, nil for pools above NUMA nodes.
+func numaNodeOf(pool Node) Node {
+	for n := pool; !n.IsNil(); n = n.Parent() {
+		if n.Kind() == NumaNode {
+			return n
+		}
+	}
+	return nil
+}
+
+// stickyPools returns the pools fitting a container on the NUMA node it was last placed on.
+//
+// The pools are returned in the order of the given pools. None are returned if
+// sticky placement is disabled, there is no placement history for the container,
+// or nothing on its last NUMA node has enough capacity left for it.
+func (p *policy) stickyPools(container cache.Container, pools []Node,
+	scores map[int]CPUScore, hugepages HugePages) []Node {
+	ttl := stickyPlacementTTL()
+	if ttl == 0 {
+		return nil
+	}
+	key, ok := placementKey(container)
+	if !ok {
+		return nil
+	}
+	record, ok := p.placements[key]
+	if !ok || time.Since(record.LastUsed) > ttl {
+		return nil
+	}
+	numa, ok := p.nodes[record.Pool]
+	if !ok {
+		log.Warn("%s: previously used pool %s not found in topology",
+			container.PrettyName(), record.Pool)
+		return nil
+	}
+
+	sticky := []Node{}
+	for _, pool := range pools {
+		if n := numaNodeOf(pool); n == nil || !n.IsSameNode(numa) {
+			continue
+		}
+		score := scores[pool.NodeID()]
+		if score.IsolatedCapacity() < 0 || score.SharedCapacity() < 0 {
+			continue
+		}
+		if !pool.FreeHugePages().Fits(hugepages) {
+			continue
+		}
+		sticky = append(sticky, pool)
+	}
+
+	if len(sticky) == 0 {
+		log.Warn("%s: previously used %s has no room left, placing elsewhere",
+			container.PrettyName(), numa.Name())
+		return nil
+	}
+
+	log.Debug("  => %s sticks to previously used %s", container.PrettyName(), numa.Name())
+
+	return sticky
+}
+
+// recordPlacement updates the placement history of a container with its pool.
+//
+// Only placements on, or within, a single NUMA node are recorded.
+func (p *policy) recordPlacement(container cache.Container, pool Node) {
+	if stickyPlacementTTL() == 0 {
+		return
+	}
+	key, ok := placementKey(container)
+	if !ok {
+		return
+	}
+	numa := numaNodeOf(pool)
+	if numa == nil {
+		return
+	}
+
+	p.placements[key] = &placementRecord{Pool: numa.Name(), LastUsed: time.Now()}
+	p.expirePlacements()
+	p.savePlacements()
+}
+
+// touchPlacement updates the last use of the placement history of a released container.
+func (p *policy) touchPlacement(container cache.Container) {
+	if stickyPlacementTTL() == 0 {
+		return
+	}
+	key, ok := placementKey(container)
+	if !ok {
+		return
+	}
+	record, ok := p.placements[key]
+	if !ok {
+		return
+	}
+
+	record.LastUsed = time.Now()
+	p.expirePlacements()
+	p.savePlacements()
+}
+
+// expirePlacements removes the placement history of containers released longer than the TTL ago.
+//
+// The history of containers which are still allocated is always kept, however
+// long ago they were placed.
+func (p *policy) expirePlacements() {
+	ttl := stickyPlacementTTL()
+
+	active := make(map[string]struct{}, len(p.allocations.CPU))
+	for _, grant := range p.allocations.CPU {
+		if key, ok := placementKey(grant.GetContainer()); ok {
+			active[key] = struct{}{}
+		}
+	}
+
+	for key, record := range p.placements {
+		if _, ok := active[key]; ok {
+			continue
+		}
+		if ttl == 0 || time.Since(record.LastUsed) > ttl {
+			log.Debug("expiring placement history of %s (%s)", key, record.Pool)
+			delete(p.placements, key)
+		}
+	}
+}
+
+// Get returns the placement history for caching.
+func (h *placementHistory) Get() interface{} {
+	return h
+}
+
+// Set sets the placement history from a cached one.
+func (h *placementHistory) Set(value interface{}) {
+	var from placementHistory
+
+	switch value.(type) {
+	case placementHistory:
+		from = value.(placementHistory)
+	case *placementHistory:
+		from = *value.(*placementHistory)
+	}
+
+	*h = make(placementHistory, len(from))
+	for key, record := range from {
+		(*h)[key] = record
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNumaNodeOf(t *testing.T) {
+	root := &node{name: "root", id: 0, kind: VirtualNode, parent: nilnode}
+	socket := &node{name: "socket #0", id: 1, kind: SocketNode, parent: root}
+	numa := &node{name: "numa node #0", id: 2, kind: NumaNode, parent: socket}
+	llc := &node{name: "llc #0", id: 3, kind: LLCNode, parent: numa}
+
+	tcases := []struct {
+		name     string
+		pool     Node
+		expected string
+	}{
+		{
+			name: "root",
+			pool: root,
+		},
+		{
+			name: "socket",
+			pool: socket,
+		},
+		{
+			name:     "NUMA node",
+			pool:     numa,
+			expected: "numa node #0",
+		},
+		{
+			name:     "LLC within NUMA node",
+			pool:     llc,
+			expected: "numa node #0",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := numaNodeOf(tc.pool)
+			switch {
+			case n == nil && tc.expected != "":
+				t.Errorf("expected NUMA node %q, got none", tc.expected)
+			case n != nil && n.Name() != tc.expected:
+				t.Errorf("expected NUMA node %q, got %q", tc.expected, n.Name())
+			}
+		})
+	}
+}
+
+func TestExpirePlacements(t *testing.T) {
+	saved := opt.StickyPlacementTTL
+	defer func() { opt.StickyPlacementTTL = saved }()
+
+	now := time.Now()
+	tcases := []struct {
+		name     string
+		ttl      string
+		expected []string
+	}{
+		{
+			name:     "fresh records kept",
+			ttl:      "1h",
+			expected: []string{"default/db-0/db", "default/db-1/db"},
+		},
+		{
+			name:     "stale records expired",
+			ttl:      "10m",
+			expected: []string{"default/db-0/db"},
+		},
+		{
+			name: "all records dropped when disabled",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			opt.StickyPlacementTTL = tc.ttl
+			p := &policy{
+				allocations: allocations{CPU: map[string]CPUGrant{}},
+				placements: placementHistory{
+					"default/db-0/db": {Pool: "numa node #0", LastUsed: now.Add(-time.Minute)},
+					"default/db-1/db": {Pool: "numa node #1", LastUsed: now.Add(-30 * time.Minute)},
+				},
+			}
+			p.expirePlacements()
+			if len(p.placements) != len(tc.expected) {
+				t.Errorf("expected %d records, got %d", len(tc.expected), len(p.placements))
+			}
+			for _, key := range tc.expected {
+				if _, ok := p.placements[key]; !ok {
+					t.Errorf("expected record %s to be kept", key)
+				}
+			}
+		})
+	}
+}
@@ -17,9 +17,9 @@ package topologyaware
 import (
 	corev1 "k8s.io/api/core/v1"
 	resapi "k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
 	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 
 	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
@@ -56,6 +56,12 @@ type policy struct {
 	nodeCnt     int                      // number of pools
 	depth       int                      // tree depth
 	allocations allocations              // container pool assignments
+	hugepages   chargedHugePages         // hugepages charged to containers
+	churn       poolChurn                // recent allocations to pools
+	podReserved reservations             // resources reserved for pods, by pod UID
+	zones       []*system.PowerZone      // RAPL package power zones, nil until discovered
+	placements  placementHistory         // NUMA nodes last used by containers, by pod name
+	scores      scoreRecords             // last pool scoring, by container
 
 }
 
@@ -72,6 +78,11 @@ func CreateTopologyAwarePolicy(opts *policyapi.BackendOptions) policyapi.Backend
 
 	p.nodes = make(map[string]Node)
 	p.allocations = allocations{policy: p, CPU: make(map[string]CPUGrant, 32)}
+	p.hugepages = make(chargedHugePages)
+	p.churn = make(poolChurn)
+	p.podReserved = make(reservations)
+	p.placements = make(placementHistory)
+	p.scores = make(scoreRecords)
 
 	if err := p.checkConstraints(); err != nil {
 		log.Fatal("failed to create topology-aware policy: %v", err)
@@ -106,9 +117,12 @@ func (p *policy) Start(add []cache.Container, del []cache.Container) error {
 		return policyError("failed to start: %v", err)
 	}
 
+	err := p.Sync(add, del)
+	p.chargeUnmanagedHugePages()
+
 	p.root.Dump("<post-start>")
 
-	return p.Sync(add, del)
+	return err
 }
 
 // Sync synchronizes the state of this policy.
@@ -129,6 +143,9 @@ func (p *policy) AllocateResources(container cache.Container) error {
 	log.Debug("allocating resources for %s...", container.PrettyName())
 
 	grant, err := p.allocatePool(container)
+	for err != nil && p.preemptLease(container) {
+		grant, err = p.allocatePool(container)
+	}
 	if err != nil {
 		return policyError("failed to allocate resources for %s: %v",
 			container.PrettyName(), err)
@@ -175,18 +192,28 @@ func (p *policy) ReleaseResources(container cache.Container) error {
 
 // UpdateResources is a resource allocation update request for this policy.
 func (p *policy) UpdateResources(c cache.Container) error {
-	log.Debug("(not) updating container %s...", c.PrettyName())
-	return nil
+	log.Debug("updating resource allocations of container %s...", c.PrettyName())
+
+	if err := p.ReleaseResources(c); err != nil {
+		return err
+	}
+
+	return p.AllocateResources(c)
 }
 
 // Rebalance tries to find an optimal allocation of resources for the current containers.
 func (p *policy) Rebalance() (bool, error) {
 	var errors error
 
+	p.expireLeases()
+
 	containers := p.cache.GetContainers()
 	movable := []cache.Container{}
 
 	for _, c := range containers {
+		if c.IsIgnored() {
+			continue
+		}
 		if c.GetQOSClass() != corev1.PodQOSGuaranteed {
 			p.ReleaseResources(c)
 			movable = append(movable, c)
@@ -238,6 +265,8 @@ func (p *policy) configNotify(event config.Event, source config.Source) error {
 	log.Info("  - prefer isolated CPUs: %v", opt.PreferIsolated)
 	log.Info("  - prefer shared CPUs: %v", opt.PreferShared)
 
+	p.updateReservedPool()
+
 	// TODO: We probably should release and reallocate resources for all containers
 	//   to honor the latest configuration. Depending on the changes that might be
 	//   disruptive to some containers, so whether we do so or not should probably
@@ -298,6 +327,19 @@ func (p *policy) restoreCache() error {
 		p.saveAllocations()
 	} else {
 		p.allocations.Dump(log.Info, "restored ")
+		for _, grant := range p.allocations.CPU {
+			p.allocateHugePages(grant.GetContainer(), grant.GetNode())
+		}
+	}
+
+	if !p.restorePlacements() {
+		p.savePlacements()
+	} else {
+		p.expirePlacements()
+	}
+
+	if p.restoreReservations() {
+		p.reinstateReservations()
 	}
 
 	return nil
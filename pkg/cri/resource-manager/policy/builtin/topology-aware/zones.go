@@ -0,0 +1,182 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topologyaware
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	policyapi "github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// GetTopologyZones returns the resource topology zones of the node, one per pool.
+func (p *policy) GetTopologyZones() []*policyapi.TopologyZone {
+	memory := p.grantedMemory()
+
+	zones := make([]*policyapi.TopologyZone, 0, len(p.pools))
+	for _, pool := range p.pools {
+		zone := &policyapi.TopologyZone{
+			Name: zoneName(pool),
+			Type: zoneType(pool),
+		}
+		if parent := pool.Parent(); !parent.IsNil() {
+			zone.Parent = zoneName(parent)
+		}
+
+		zone.Resources = append(zone.Resources, p.zoneCPU(pool))
+		zone.Resources = append(zone.Resources, p.zoneMemory(pool, memory[pool.Name()]))
+		zone.Resources = append(zone.Resources, zoneHugePages(pool)...)
+
+		zone.Attributes = []*policyapi.ZoneAttribute{
+			{Name: "pool", Value: pool.Name()},
+			{Name: "mems", Value: pool.GetMemset().String()},
+		}
+
+		zones = append(zones, zone)
+	}
+
+	return zones
+}
+
+// zoneName returns the zone name for a pool, following scheduler naming for NUMA nodes.
+func zoneName(pool Node) string {
+	if pool.Kind() == NumaNode {
+		return fmt.Sprintf("node-%d", pool.NodeID())
+	}
+	return pool.Name()
+}
+
+// zoneType returns the zone type for a pool.
+func zoneType(pool Node) string {
+	switch pool.Kind() {
+	case NumaNode:
+		return policyapi.ZoneTypeNode
+	case SocketNode:
+		return "Socket"
+	case DieNode:
+		return "Die"
+	case LLCNode:
+		return "LLC"
+	default:
+		return "Virtual"
+	}
+}
+
+// zoneCPU returns the CPU capacity, allocatable and available amounts of a pool.
+func (p *policy) zoneCPU(pool Node) *policyapi.ZoneResource {
+	supply := pool.GetCPU()
+	free := pool.FreeCPU()
+
+	allocatable := supply.IsolatedCPUs().Union(supply.SharableCPUs())
+	reserved := cpuset.NewCPUSet()
+	for _, id := range pool.GetMemset().Members() {
+		reserved = reserved.Union(p.sys.Node(id).CPUSet().Intersection(p.reserved))
+	}
+	capacity := allocatable.Union(reserved)
+
+	available := 1000*(free.IsolatedCPUs().Size()+free.SharableCPUs().Size()) - pool.GrantedCPU()
+	if available < 0 {
+		available = 0
+	}
+
+	return &policyapi.ZoneResource{
+		Name:        string(corev1.ResourceCPU),
+		Capacity:    *resource.NewQuantity(int64(capacity.Size()), resource.DecimalSI),
+		Allocatable: *resource.NewQuantity(int64(allocatable.Size()), resource.DecimalSI),
+		Available:   *resource.NewMilliQuantity(int64(available), resource.DecimalSI),
+	}
+}
+
+// zoneMemory returns the memory capacity, allocatable and available amounts of a pool.
+func (p *policy) zoneMemory(pool Node, granted int64) *policyapi.ZoneResource {
+	capacity := int64(0)
+	for _, id := range pool.GetMemset().Members() {
+		info, err := p.sys.Node(id).MemoryInfo()
+		if err != nil {
+			log.Error("failed to discover memory of NUMA node #%v: %v", id, err)
+			continue
+		}
+		capacity += int64(info.MemTotal)
+	}
+
+	available := capacity - granted
+	if available < 0 {
+		available = 0
+	}
+
+	return &policyapi.ZoneResource{
+		Name:        string(corev1.ResourceMemory),
+		Capacity:    *resource.NewQuantity(capacity, resource.BinarySI),
+		Allocatable: *resource.NewQuantity(capacity, resource.BinarySI),
+		Available:   *resource.NewQuantity(available, resource.BinarySI),
+	}
+}
+
+// zoneHugePages returns the hugepage capacity and available amounts of a pool.
+func zoneHugePages(pool Node) []*policyapi.ZoneResource {
+	capacity := pool.GetHugePages()
+	free := pool.FreeHugePages()
+
+	sizes := make([]uint64, 0, len(capacity))
+	for size := range capacity {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	resources := []*policyapi.ZoneResource{}
+	for _, size := range sizes {
+		cnt := capacity[size]
+		if cnt == 0 {
+			continue
+		}
+		name := corev1.ResourceHugePagesPrefix +
+			resource.NewQuantity(int64(size), resource.BinarySI).String()
+		available := free[size]
+		if available < 0 {
+			available = 0
+		}
+		resources = append(resources, &policyapi.ZoneResource{
+			Name:        name,
+			Capacity:    *resource.NewQuantity(cnt*int64(size), resource.BinarySI),
+			Allocatable: *resource.NewQuantity(cnt*int64(size), resource.BinarySI),
+			Available:   *resource.NewQuantity(available*int64(size), resource.BinarySI),
+		})
+	}
+
+	return resources
+}
+
+// grantedMemory returns the memory requested by containers or held by reservations, charged to their pools and its parents.
+func (p *policy) grantedMemory() map[string]int64 {
+	granted := map[string]int64{}
+	for _, grant := range p.allocations.CPU {
+		resources := grant.GetContainer().GetResourceRequirements()
+		request, ok := resources.Requests[corev1.ResourceMemory]
+		if !ok {
+			continue
+		}
+		for n := grant.GetNode(); !n.IsNil(); n = n.Parent() {
+			granted[n.Name()] += request.Value()
+		}
+	}
+	for name, reserved := range p.reservedMemory() {
+		granted[name] += reserved
+	}
+	return granted
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+const (
+	// tagGrant tags containers with the CPUs and memory currently granted to them.
+	tagGrant = "policy/grant"
+	// tagGrantedAt tags containers with the time their current grant was made.
+	tagGrantedAt = "policy/granted-at"
+
+	// operations of policy decisions we measure
+	opAllocate  = "allocate"
+	opRelease   = "release"
+	opUpdate    = "update"
+	opRebalance = "rebalance"
+)
+
+// grantAgeBuckets are the upper bounds of grant age buckets, in seconds.
+var grantAgeBuckets = []float64{
+	60, 5 * 60, 15 * 60, 3600, 6 * 3600, 24 * 3600, 7 * 24 * 3600, 30 * 24 * 3600,
+}
+
+// decisionMetrics collects metrics about the decisions of the active policy.
+//
+// Decision latencies and rebalancing changes are observed as decisions are made.
+// The age distribution of grants is based on the time the current CPUs and memory
+// of containers were granted, which is stored in the cache and therefore persists
+// over restarts. Like other
+// policy metrics, grant ages are polled synchronously with request processing,
+// by PollMetrics(), and turned into prometheus metrics when collected.
+type decisionMetrics struct {
+	sync.Mutex
+	latency   *prometheus.HistogramVec
+	errors    *prometheus.CounterVec
+	rebalance *prometheus.HistogramVec
+	ageDesc   *prometheus.Desc
+	policy    string    // name of the active policy
+	ages      []float64 // polled ages of grants, in seconds
+}
+
+// Our policy decision metrics collector.
+var decisions = &decisionMetrics{
+	latency: prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cri_resmgr_policy_decision_duration_seconds",
+			Help:    "Time taken by policy decisions, by policy and operation.",
+			Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"policy", "operation"},
+	),
+	errors: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cri_resmgr_policy_decision_errors_total",
+			Help: "Number of failed policy decisions, by policy and operation.",
+		},
+		[]string{"policy", "operation"},
+	),
+	rebalance: prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cri_resmgr_policy_rebalance_changed_containers",
+			Help:    "Number of containers changed by a rebalancing pass, by policy.",
+			Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+		},
+		[]string{"policy"},
+	),
+	ageDesc: prometheus.NewDesc(
+		"cri_resmgr_policy_grant_age_seconds",
+		"Age of the resource grants of current containers, by policy.",
+		[]string{"policy"}, nil,
+	),
+}
+
+// setPolicy sets the name of the policy decisions are measured for.
+func (m *decisionMetrics) setPolicy(name string) {
+	m.Lock()
+	defer m.Unlock()
+	m.policy = name
+	m.ages = nil
+}
+
+// observe records the latency and outcome of a single policy decision.
+func (m *decisionMetrics) observe(policy, operation string, start time.Time, err error) {
+	m.latency.WithLabelValues(policy, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(policy, operation).Inc()
+	}
+}
+
+// observeRebalance records the number of containers changed by rebalancing.
+func (m *decisionMetrics) observeRebalance(policy string, changed int) {
+	m.rebalance.WithLabelValues(policy).Observe(float64(changed))
+}
+
+// poll takes a snapshot of the ages of grants of the given containers.
+func (m *decisionMetrics) poll(containers []cache.Container) {
+	now := time.Now()
+	ages := make([]float64, 0, len(containers))
+	for _, c := range containers {
+		if at, ok := grantTime(c); ok {
+			ages = append(ages, now.Sub(at).Seconds())
+		}
+	}
+	sort.Float64s(ages)
+
+	m.Lock()
+	defer m.Unlock()
+	m.ages = ages
+}
+
+// grantOf returns the CPUs and memory currently granted to a container.
+func grantOf(c cache.Container) string {
+	return c.GetCpusetCpus() + "/" + c.GetCpusetMems()
+}
+
+// markGranted tags a container with the time of its grant, unless the grant is unchanged.
+func markGranted(c cache.Container) {
+	grant := grantOf(c)
+	if old, ok := c.GetTag(tagGrant); ok && old == grant {
+		if _, ok := c.GetTag(tagGrantedAt); ok {
+			return
+		}
+	}
+	c.SetTag(tagGrant, grant)
+	c.SetTag(tagGrantedAt, time.Now().Format(time.RFC3339))
+}
+
+// grantTime returns the time the current grant of a container was made.
+func grantTime(c cache.Container) (time.Time, bool) {
+	value, ok := c.GetTag(tagGrantedAt)
+	if !ok {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// Describe implements prometheus.Collector.
+func (m *decisionMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.latency.Describe(ch)
+	m.errors.Describe(ch)
+	m.rebalance.Describe(ch)
+	ch <- m.ageDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *decisionMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.latency.Collect(ch)
+	m.errors.Collect(ch)
+	m.rebalance.Collect(ch)
+
+	m.Lock()
+	defer m.Unlock()
+	if m.policy == "" {
+		return
+	}
+
+	sum := 0.0
+	buckets := make(map[float64]uint64, len(grantAgeBuckets))
+	for _, age := range m.ages {
+		sum += age
+	}
+	for _, bound := range grantAgeBuckets {
+		buckets[bound] = uint64(sort.Search(len(m.ages), func(i int) bool {
+			return m.ages[i] > bound
+		}))
+	}
+	hist, err := prometheus.NewConstHistogram(m.ageDesc, uint64(len(m.ages)), sum, buckets, m.policy)
+	if err != nil {
+		log.Error("failed to collect policy grant ages: %v", err)
+		return
+	}
+	ch <- hist
+}
+
+func init() {
+	err := metrics.RegisterCollector("policy-decisions", func() (prometheus.Collector, error) {
+		return decisions, nil
+	})
+	if err != nil {
+		log.Error("failed to register policy decision metrics collector: %v", err)
+	}
+}
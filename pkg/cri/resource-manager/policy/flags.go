@@ -21,9 +21,9 @@ import (
 	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
 	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 )
 
 const (
@@ -41,6 +41,15 @@ type options struct {
 	Available ConstraintSet `json:"AvailableResources,omitempty"`
 	// Reserved hardware resources, for system and kube tasks.
 	Reserved ConstraintSet `json:"ReservedResources,omitempty"`
+	// KubeletConfig is the kubelet configuration file to derive CPU reservation from.
+	KubeletConfig string `json:"KubeletConfig,omitempty"`
+	// ExportEnv controls whether exported resource data is also injected into the
+	// environment of containers being created.
+	ExportEnv bool `json:"ExportResourceEnv,omitempty"`
+	// RuntimeClasses is the treatment of containers by runtime handler (RuntimeClass).
+	RuntimeClasses map[string]*RuntimeClassOptions `json:",omitempty"`
+	// ResourceUpdates bounds in-place resource updates of containers.
+	ResourceUpdates *ResourceUpdateOptions `json:",omitempty"`
 }
 
 // Our runtime configuration.
@@ -179,5 +188,15 @@ func defaultOptions() interface{} {
 
 // Register us for configuration handling.
 func init() {
-	config.Register("policy", "Generic policy layer.", opt, defaultOptions)
+	config.Register("policy", "Generic policy layer.", opt, defaultOptions,
+		config.WithValidator(validateOptions))
+}
+
+// validateOptions checks the generic policy configuration.
+func validateOptions(o interface{}) error {
+	cfg, ok := o.(*options)
+	if !ok {
+		return policyError("invalid policy configuration %T", o)
+	}
+	return validateResourceUpdateOptions(cfg.ResourceUpdates)
 }
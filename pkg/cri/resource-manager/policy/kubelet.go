@@ -0,0 +1,186 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// kubeletCheckInterval is the interval for checking kubelet configuration for changes.
+var kubeletCheckInterval = time.Minute
+
+// kubeletConfig is the part of the kubelet configuration we care about.
+type kubeletConfig struct {
+	KubeReserved       map[string]string `json:"kubeReserved,omitempty"`
+	SystemReserved     map[string]string `json:"systemReserved,omitempty"`
+	ReservedSystemCPUs string            `json:"reservedSystemCPUs,omitempty"`
+}
+
+// readKubeletConfig reads the given kubelet configuration file.
+func readKubeletConfig(path string) (*kubeletConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, policyError("failed to read kubelet configuration: %v", err)
+	}
+
+	kc := &kubeletConfig{}
+	if err := yaml.Unmarshal(data, kc); err != nil {
+		return nil, policyError("failed to parse kubelet configuration %s: %v", path, err)
+	}
+
+	return kc, nil
+}
+
+// reservedCPU returns the CPU reservation of the kubelet configuration.
+//
+// An explicit set of reserved CPUs takes precedence. Otherwise the reservation
+// is the sum of kube- and system-reserved CPU. If neither is set, the returned
+// reservation is nil.
+func (kc *kubeletConfig) reservedCPU() (Constraint, error) {
+	if kc.ReservedSystemCPUs != "" {
+		cset, err := cpuset.Parse(kc.ReservedSystemCPUs)
+		if err != nil {
+			return nil, policyError("invalid kubelet reservedSystemCPUs %q: %v",
+				kc.ReservedSystemCPUs, err)
+		}
+		return cset, nil
+	}
+
+	total := resource.Quantity{Format: resource.DecimalSI}
+	for _, reserved := range []map[string]string{kc.KubeReserved, kc.SystemReserved} {
+		value, ok := reserved["cpu"]
+		if !ok {
+			continue
+		}
+		qty, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, policyError("invalid kubelet CPU reservation %q: %v", value, err)
+		}
+		total.Add(qty)
+	}
+
+	if total.IsZero() {
+		return nil, nil
+	}
+	return total, nil
+}
+
+// kubeletReservedCPU reads the CPU reservation from the given kubelet configuration.
+func kubeletReservedCPU(path string) (Constraint, error) {
+	kc, err := readKubeletConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return kc.reservedCPU()
+}
+
+// reservedResources returns the resource reservations to use for the backend.
+//
+// If a kubelet configuration is given and there is no explicitly configured CPU
+// reservation, the CPU reservation is derived from the kubelet configuration.
+func reservedResources() (ConstraintSet, error) {
+	if opt.KubeletConfig == "" {
+		return opt.Reserved, nil
+	}
+
+	if _, ok := opt.Reserved[DomainCPU]; ok {
+		log.Info("explicit CPU reservation overrides kubelet configuration %s",
+			opt.KubeletConfig)
+		return opt.Reserved, nil
+	}
+
+	cpu, err := kubeletReservedCPU(opt.KubeletConfig)
+	if err != nil {
+		return nil, err
+	}
+	if cpu == nil {
+		log.Warn("no CPU reservation found in kubelet configuration %s", opt.KubeletConfig)
+		return opt.Reserved, nil
+	}
+
+	reserved := make(ConstraintSet, len(opt.Reserved)+1)
+	for domain, constraint := range opt.Reserved {
+		reserved[domain] = constraint
+	}
+	reserved[DomainCPU] = cpu
+
+	log.Info("using CPU reservation %s from kubelet configuration %s",
+		ConstraintToString(cpu), opt.KubeletConfig)
+
+	return reserved, nil
+}
+
+// watchKubeletConfig starts checking kubelet configuration for CPU reservation drift.
+//
+// The reserved resources of a running policy cannot be changed. If the CPU
+// reservation in the kubelet configuration changes and no longer matches the one
+// in use, we warn about it, so that the drift can be corrected by a restart.
+// Checking stops once the stop channel is closed. The returned channel is
+// closed once checking has stopped.
+func watchKubeletConfig(path string, inUse Constraint, stop <-chan struct{}) <-chan struct{} {
+	reservation := func(c Constraint) string {
+		if c == nil {
+			return "none"
+		}
+		return ConstraintToString(c)
+	}
+
+	current := reservation(inUse)
+	last := current
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(kubeletCheckInterval)
+
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			cpu, err := kubeletReservedCPU(path)
+			if err != nil {
+				log.Warn("failed to check kubelet configuration: %v", err)
+				continue
+			}
+
+			kubelet := reservation(cpu)
+			if kubelet == last {
+				continue
+			}
+			last = kubelet
+
+			if kubelet != current {
+				log.Warn("CPU reservation in kubelet configuration %s (%s) differs from "+
+					"the one in use (%s), a restart is needed to take it into use",
+					path, kubelet, current)
+			} else {
+				log.Info("CPU reservation in kubelet configuration %s matches again "+
+					"the one in use (%s)", path, current)
+			}
+		}
+	}()
+
+	return done
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+func writeKubeletConfig(t *testing.T, dir, data string) string {
+	path := filepath.Join(dir, "kubelet.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write kubelet configuration: %v", err)
+	}
+	return path
+}
+
+func TestKubeletReservedCPU(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubelet-config")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tcases := []struct {
+		name      string
+		config    string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "no reservation",
+			config:   "kind: KubeletConfiguration\n",
+			expected: "",
+		},
+		{
+			name:     "reserved CPUs",
+			config:   "reservedSystemCPUs: 0-1\nkubeReserved:\n  cpu: 750m\n",
+			expected: "0-1",
+		},
+		{
+			name:     "kube and system reserved",
+			config:   "kubeReserved:\n  cpu: 750m\nsystemReserved:\n  cpu: 500m\n",
+			expected: "1250m",
+		},
+		{
+			name:      "invalid reserved CPUs",
+			config:    "reservedSystemCPUs: foo\n",
+			expectErr: true,
+		},
+		{
+			name:      "invalid CPU quantity",
+			config:    "kubeReserved:\n  cpu: lots\n",
+			expectErr: true,
+		},
+		{
+			name:      "invalid configuration",
+			config:    "kubeReserved: [\n",
+			expectErr: true,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeKubeletConfig(t, dir, tc.config)
+			cpu, err := kubeletReservedCPU(path)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got reservation %v", cpu)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch c := cpu.(type) {
+			case nil:
+				if tc.expected != "" {
+					t.Errorf("expected reservation %s, got none", tc.expected)
+				}
+			case cpuset.CPUSet:
+				if c.String() != tc.expected {
+					t.Errorf("expected reserved CPUs %s, got %s", tc.expected, c)
+				}
+			case resource.Quantity:
+				if c.String() != tc.expected {
+					t.Errorf("expected reserved CPU %s, got %s", tc.expected, c.String())
+				}
+			default:
+				t.Errorf("unexpected reservation type %T", cpu)
+			}
+		})
+	}
+
+	if _, err := kubeletReservedCPU(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("expected an error for missing kubelet configuration")
+	}
+}
+
+func TestWatchKubeletConfigStops(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubelet-config")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	saved := kubeletCheckInterval
+	kubeletCheckInterval = time.Millisecond
+	defer func() { kubeletCheckInterval = saved }()
+
+	path := writeKubeletConfig(t, dir, "reservedSystemCPUs: 0-1\n")
+	stop := make(chan struct{})
+	done := watchKubeletConfig(path, cpuset.MustParse("0"), stop)
+
+	// let the watcher notice the drift a few times
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("kubelet configuration watcher did not stop")
+	}
+}
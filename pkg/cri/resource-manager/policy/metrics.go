@@ -0,0 +1,95 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+// Metrics is the opaque, policy-specific metrics data polled from a backend.
+type Metrics interface{}
+
+// policyCollector is the prometheus.Collector for the metrics of the active policy.
+//
+// Backends are not safe for concurrent use, so metrics data is polled from the
+// active backend synchronously with request processing, by PollMetrics(), and
+// later turned into prometheus metrics from that snapshot when collected.
+type policyCollector struct {
+	sync.Mutex
+	policy *policy // active policy
+	polled Metrics // last polled metrics data
+}
+
+// Our collector for policy-specific metrics.
+var collector = &policyCollector{}
+
+// setPolicy sets the policy metrics are collected for.
+func (c *policyCollector) setPolicy(p *policy) {
+	c.Lock()
+	defer c.Unlock()
+	c.policy = p
+	c.polled = nil
+}
+
+// poll polls metrics data from the active policy.
+func (c *policyCollector) poll() {
+	c.Lock()
+	defer c.Unlock()
+	if c.policy != nil {
+		c.polled = c.policy.backend.PollMetrics()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *policyCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.Lock()
+	defer c.Unlock()
+	if c.policy == nil {
+		return
+	}
+	for _, d := range c.policy.backend.DescribeMetrics() {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *policyCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	if c.policy == nil || c.polled == nil {
+		return
+	}
+	collected, err := c.policy.backend.CollectMetrics(c.polled)
+	if err != nil {
+		log.Error("failed to collect metrics of policy %s: %v", c.policy.backend.Name(), err)
+		return
+	}
+	for _, m := range collected {
+		ch <- m
+	}
+}
+
+func init() {
+	err := metrics.RegisterCollector("policy", func() (prometheus.Collector, error) {
+		return collector, nil
+	})
+	if err != nil {
+		log.Error("failed to register policy metrics collector: %v", err)
+	}
+}
@@ -19,12 +19,15 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/agent"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/stats"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 	system "github.com/intel/cri-resource-manager/pkg/sysfs"
 )
@@ -55,6 +58,10 @@ type ConstraintSet map[Domain]Constraint
 type Options struct {
 	// Client interface to cri-resmgr agent
 	AgentCli agent.Interface
+	// System to use instead of discovering the running one, mostly for simulation.
+	System system.System
+	// Stats provides cgroup statistics of containers, nil if they are not collected.
+	Stats stats.Provider
 }
 
 // BackendOptions describes the options for a policy backend instance
@@ -69,6 +76,8 @@ type BackendOptions struct {
 	Reserved ConstraintSet
 	// Client interface to cri-resmgr agent
 	AgentCli agent.Interface
+	// Stats provides the latest cgroup statistics of containers
+	Stats stats.Provider
 }
 
 // CreateFn is the type for functions used to create a policy instance.
@@ -77,12 +86,24 @@ type CreateFn func(*BackendOptions) Backend
 const (
 	// ExportedResources is the basename of the file container resources are exported to.
 	ExportedResources = "resources.sh"
+	// ExportedAssignment is the basename of the file container assignments are exported to.
+	ExportedAssignment = "assignment.json"
 	// ExportSharedCPUs is the shell variable used to export shared container CPUs.
 	ExportSharedCPUs = "SHARED_CPUS"
 	// ExportIsolatedCPUs is the shell variable used to export isolated container CPUs.
 	ExportIsolatedCPUs = "ISOLATED_CPUS"
 	// ExportExclusiveCPUs is the shell variable used to export exclusive container CPUs.
 	ExportExclusiveCPUs = "EXCLUSIVE_CPUS"
+	// ExportCPUSetCPUs is the shell variable used to export the final container cpuset.
+	ExportCPUSetCPUs = "CPUSET_CPUS"
+	// ExportCPUSetMems is the shell variable used to export the final container memory nodes.
+	ExportCPUSetMems = "CPUSET_MEMS"
+	// ExportRDTClass is the shell variable used to export the RDT class of the container.
+	ExportRDTClass = "RDT_CLASS"
+	// ExportWorkQueues is the shell variable used to export the accelerator work queues of the container.
+	ExportWorkQueues = "WORK_QUEUES"
+	// ExportEnvPrefix is prepended to exported variables when injected into the environment.
+	ExportEnvPrefix = "CRI_RESMGR_"
 )
 
 // Backend is the policy (decision making logic) interface exposed by implementations.
@@ -109,6 +130,22 @@ type Backend interface {
 	Rebalance() (bool, error)
 	// ExportResourceData provides resource data to export for the container.
 	ExportResourceData(cache.Container) map[string]string
+	// DescribeMetrics generates policy-specific prometheus metrics data descriptors.
+	DescribeMetrics() []*prometheus.Desc
+	// PollMetrics provides policy metrics data for monitoring.
+	PollMetrics() Metrics
+	// CollectMetrics generates prometheus metrics from polled policy-specific metrics data.
+	CollectMetrics(Metrics) ([]prometheus.Metric, error)
+	// GetTopologyZones returns the resource topology zones of the node, if known.
+	GetTopologyZones() []*TopologyZone
+	// GetState returns a snapshot of the internal state of the policy, nil if not supported.
+	GetState() *State
+	// ReviewResourceUpdate reviews an in-place resource update of a container, clamping or rejecting it.
+	ReviewResourceUpdate(cache.Container, *ResourceUpdate)
+	// ReserveResources reserves resources for a pod ahead of its creation.
+	ReserveResources(*Reservation) error
+	// ReleaseReservation releases any resources reserved for the pod with the given UID.
+	ReleaseReservation(string) error
 }
 
 // Policy is the exposed interface for container resource allocations decision making.
@@ -127,6 +164,22 @@ type Policy interface {
 	Rebalance() (bool, error)
 	// ExportResourceData exports/updates resource data for the container.
 	ExportResourceData(cache.Container)
+	// ExclusiveCPUs returns the CPUs allocated exclusively to containers.
+	ExclusiveCPUs() cpuset.CPUSet
+	// PollMetrics polls policy-specific metrics data for later collection.
+	PollMetrics()
+	// GetTopologyZones returns the current resource topology zones of the node.
+	GetTopologyZones() []*TopologyZone
+	// GetState returns a snapshot of the internal state of the active policy, nil if not supported.
+	GetState() *State
+	// ReviewResourceUpdate reviews an in-place resource update of a container, clamping or rejecting it.
+	ReviewResourceUpdate(cache.Container, *ResourceUpdate)
+	// ReserveResources reserves resources for a pod ahead of its creation.
+	ReserveResources(*Reservation) error
+	// ReleaseReservation releases any resources reserved for the pod with the given UID.
+	ReleaseReservation(string) error
+	// Stop stops any background activity of the policy.
+	Stop()
 }
 
 // Policy instance/state.
@@ -134,6 +187,7 @@ type policy struct {
 	cache   cache.Cache   // system state cache
 	backend Backend       // our active backend
 	system  system.System // system/HW/topology info
+	stop    chan struct{} // closed to stop background activity
 }
 
 // backend is a registered Backend.
@@ -165,14 +219,18 @@ func NewPolicy(cache cache.Cache, o *Options) (Policy, error) {
 		return nil, policyError("unknown policy '%s'", opt.Policy)
 	}
 
-	sys, err := system.DiscoverSystem()
-	if err != nil {
-		return nil, policyError("failed to discover system topology: %v", err)
+	sys := o.System
+	if sys == nil {
+		var err error
+		if sys, err = system.DiscoverSystem(); err != nil {
+			return nil, policyError("failed to discover system topology: %v", err)
+		}
 	}
 
 	p := &policy{
 		cache:  cache,
 		system: sys,
+		stop:   make(chan struct{}),
 	}
 
 	log.Info("creating new policy '%s'...", backend.name)
@@ -183,10 +241,15 @@ func NewPolicy(cache cache.Cache, o *Options) (Policy, error) {
 		}
 	}
 
-	if len(opt.Reserved) != 0 {
+	reserved, err := reservedResources()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reserved) != 0 {
 		log.Info("  with resource reservation constraints:")
-		for d := range opt.Reserved {
-			log.Info("    - %s=%s", d, ConstraintToString(opt.Reserved[d]))
+		for d := range reserved {
+			log.Info("    - %s=%s", d, ConstraintToString(reserved[d]))
 		}
 	}
 
@@ -201,10 +264,20 @@ func NewPolicy(cache cache.Cache, o *Options) (Policy, error) {
 		Cache:     p.cache,
 		System:    p.system,
 		Available: opt.Available,
-		Reserved:  opt.Reserved,
+		Reserved:  reserved,
 		AgentCli:  o.AgentCli,
+		Stats:     o.Stats,
+	}
+	if backendOpts.Stats == nil {
+		backendOpts.Stats = stats.NoStats()
 	}
 	p.backend = backend.create(backendOpts)
+	collector.setPolicy(p)
+	decisions.setPolicy(backend.name)
+
+	if opt.KubeletConfig != "" {
+		watchKubeletConfig(opt.KubeletConfig, reserved[DomainCPU], p.stop)
+	}
 
 	return p, nil
 }
@@ -217,32 +290,127 @@ func (p *policy) Start(add []cache.Container, del []cache.Container) error {
 
 	log.Info("starting policy '%s'...", p.backend.Name())
 
-	return p.backend.Start(add, del)
+	if err := p.backend.Start(managed(add), managed(del)); err != nil {
+		return err
+	}
+
+	// grants made before we tracked grant times, or changed meanwhile, start aging now
+	for _, c := range managed(p.cache.GetContainers()) {
+		markGranted(c)
+	}
+
+	return nil
+}
+
+// Stop stops any background activity of the policy.
+func (p *policy) Stop() {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
 }
 
 // Sync synchronizes the active policy state.
 func (p *policy) Sync(add []cache.Container, del []cache.Container) error {
-	return p.backend.Sync(add, del)
+	return p.backend.Sync(managed(add), managed(del))
 }
 
 // AllocateResources allocates resources for a container.
 func (p *policy) AllocateResources(c cache.Container) error {
-	return p.backend.AllocateResources(c)
+	if c.IsIgnored() {
+		log.Debug("not allocating resources for ignored container %s", c.PrettyName())
+		return nil
+	}
+	start := time.Now()
+	err := p.backend.AllocateResources(c)
+	decisions.observe(p.backend.Name(), opAllocate, start, err)
+	if err == nil {
+		markGranted(c)
+	}
+	return err
 }
 
 // ReleaseResources release resources of a container.
 func (p *policy) ReleaseResources(c cache.Container) error {
-	return p.backend.ReleaseResources(c)
+	if c.IsIgnored() {
+		return nil
+	}
+	start := time.Now()
+	err := p.backend.ReleaseResources(c)
+	decisions.observe(p.backend.Name(), opRelease, start, err)
+	return err
 }
 
 // UpdateResources updates resource allocations of a container.
 func (p *policy) UpdateResources(c cache.Container) error {
-	return p.backend.UpdateResources(c)
+	if c.IsIgnored() {
+		log.Debug("not updating resources of ignored container %s", c.PrettyName())
+		return nil
+	}
+	start := time.Now()
+	err := p.backend.UpdateResources(c)
+	decisions.observe(p.backend.Name(), opUpdate, start, err)
+	if err == nil {
+		markGranted(c)
+	}
+	return err
 }
 
 // Rebalance tries to find a more optimal allocation of resources for the current containers.
 func (p *policy) Rebalance() (bool, error) {
-	return p.backend.Rebalance()
+	containers := managed(p.cache.GetContainers())
+	grants := make(map[string]string, len(containers))
+	for _, c := range containers {
+		grants[c.GetCacheID()] = grantOf(c)
+	}
+
+	start := time.Now()
+	changes, err := p.backend.Rebalance()
+	decisions.observe(p.backend.Name(), opRebalance, start, err)
+
+	changed := 0
+	for _, c := range containers {
+		if grantOf(c) != grants[c.GetCacheID()] {
+			changed++
+		}
+		markGranted(c)
+	}
+	decisions.observeRebalance(p.backend.Name(), changed)
+
+	return changes, err
+}
+
+// PollMetrics polls policy-specific metrics data for later collection.
+func (p *policy) PollMetrics() {
+	collector.poll()
+	decisions.poll(p.cache.GetContainers())
+}
+
+// GetTopologyZones returns the current resource topology zones of the node.
+func (p *policy) GetTopologyZones() []*TopologyZone {
+	return p.backend.GetTopologyZones()
+}
+
+// GetState returns a snapshot of the internal state of the active policy.
+func (p *policy) GetState() *State {
+	state := p.backend.GetState()
+	if state == nil {
+		return nil
+	}
+	state.Policy = p.backend.Name()
+	state.Time = time.Now()
+	return state
+}
+
+// ReserveResources reserves resources for a pod ahead of its creation.
+func (p *policy) ReserveResources(r *Reservation) error {
+	return p.backend.ReserveResources(r)
+}
+
+// ReleaseReservation releases any resources reserved for the pod with the given UID.
+func (p *policy) ReleaseReservation(uid string) error {
+	return p.backend.ReleaseReservation(uid)
 }
 
 // ExportResourceData exports/updates resource data for the container.
@@ -250,6 +418,11 @@ func (p *policy) ExportResourceData(c cache.Container) {
 	var buf bytes.Buffer
 
 	data := p.backend.ExportResourceData(c)
+	if data == nil {
+		data = make(map[string]string)
+	}
+	exportAllocation(c, data)
+
 	keys := []string{}
 	for key := range data {
 		keys = append(keys, key)
@@ -267,6 +440,67 @@ func (p *policy) ExportResourceData(c cache.Container) {
 	}
 
 	p.cache.WriteFile(c.GetCacheID(), ExportedResources, 0644, buf.Bytes())
+
+	if assignment, err := c.ExportResourceAssignment(); err != nil {
+		log.Error("%v", err)
+	} else {
+		p.cache.WriteFile(c.GetCacheID(), ExportedAssignment, 0644, assignment)
+	}
+
+	// The environment can only be altered before the container gets created.
+	if opt.ExportEnv && c.GetState() == cache.ContainerStateCreating {
+		for _, key := range keys {
+			c.SetEnv(ExportEnvPrefix+key, data[key])
+		}
+	}
+}
+
+// ExclusiveCPUs returns the CPUs allocated exclusively to containers, isolated or not.
+func (p *policy) ExclusiveCPUs() cpuset.CPUSet {
+	cpus := cpuset.NewCPUSet()
+	for _, c := range p.cache.GetContainers() {
+		data := p.backend.ExportResourceData(c)
+		for _, key := range []string{ExportExclusiveCPUs, ExportIsolatedCPUs} {
+			value, ok := data[key]
+			if !ok || value == "" {
+				continue
+			}
+			set, err := cpuset.Parse(value)
+			if err != nil {
+				log.Error("container %s: invalid exported %s %q: %v", c.PrettyName(), key, value, err)
+				continue
+			}
+			cpus = cpus.Union(set)
+		}
+	}
+	return cpus
+}
+
+// managed filters out containers which are annotated to be left unmanaged.
+func managed(containers []cache.Container) []cache.Container {
+	filtered := make([]cache.Container, 0, len(containers))
+	for _, c := range containers {
+		if !c.IsIgnored() {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// exportAllocation adds the policy-agnostic final allocation of the container to data.
+func exportAllocation(c cache.Container, data map[string]string) {
+	if cpus := c.GetCpusetCpus(); cpus != "" {
+		data[ExportCPUSetCPUs] = cpus
+	}
+	if mems := c.GetCpusetMems(); mems != "" {
+		data[ExportCPUSetMems] = mems
+	}
+	if class := c.GetRDTClass(); class != "" {
+		data[ExportRDTClass] = class
+	}
+	if queues, ok := c.GetTag(cache.TagWorkQueues); ok && queues != "" {
+		data[ExportWorkQueues] = queues
+	}
 }
 
 // Register registers a policy backend.
@@ -0,0 +1,190 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	model "github.com/prometheus/client_model/go"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+)
+
+// fakeContainer is a container which is ignored or not, with a cpuset.
+type fakeContainer struct {
+	cache.Container
+	name    string
+	ignored bool
+	cpus    string
+	mems    string
+	tags    map[string]string
+}
+
+func (c *fakeContainer) IsIgnored() bool       { return c.ignored }
+func (c *fakeContainer) PrettyName() string    { return c.name }
+func (c *fakeContainer) GetCacheID() string    { return c.name }
+func (c *fakeContainer) GetCpusetCpus() string { return c.cpus }
+func (c *fakeContainer) GetCpusetMems() string { return c.mems }
+func (c *fakeContainer) GetTag(key string) (string, bool) {
+	value, ok := c.tags[key]
+	return value, ok
+}
+func (c *fakeContainer) SetTag(key, value string) (string, bool) {
+	old, ok := c.tags[key]
+	c.tags[key] = value
+	return old, ok
+}
+
+// recordingBackend records the containers it is asked to act on.
+type recordingBackend struct {
+	Backend
+	seen      []string
+	rebalance func()
+}
+
+func (b *recordingBackend) Name() string { return "recording" }
+func (b *recordingBackend) Sync(add, del []cache.Container) error {
+	for _, c := range append(add, del...) {
+		b.seen = append(b.seen, c.PrettyName())
+	}
+	return nil
+}
+func (b *recordingBackend) AllocateResources(c cache.Container) error {
+	b.seen = append(b.seen, c.PrettyName())
+	return nil
+}
+func (b *recordingBackend) ReleaseResources(c cache.Container) error {
+	b.seen = append(b.seen, c.PrettyName())
+	return nil
+}
+func (b *recordingBackend) UpdateResources(c cache.Container) error {
+	b.seen = append(b.seen, c.PrettyName())
+	return nil
+}
+func (b *recordingBackend) Rebalance() (bool, error) {
+	b.rebalance()
+	return true, nil
+}
+
+func TestIgnoredContainers(t *testing.T) {
+	b := &recordingBackend{}
+	p := &policy{backend: b}
+
+	managed := &fakeContainer{name: "managed", tags: map[string]string{}}
+	ignored := &fakeContainer{name: "ignored", ignored: true, tags: map[string]string{}}
+	both := []cache.Container{managed, ignored}
+
+	p.Sync(both, both)
+	for _, c := range both {
+		p.AllocateResources(c)
+		p.UpdateResources(c)
+		p.ReleaseResources(c)
+	}
+
+	if len(b.seen) != 5 {
+		t.Errorf("expected 5 backend calls, got %d: %v", len(b.seen), b.seen)
+	}
+	for _, name := range b.seen {
+		if name != managed.name {
+			t.Errorf("backend called for ignored container")
+			break
+		}
+	}
+	if _, ok := managed.GetTag(tagGrantedAt); !ok {
+		t.Errorf("expected grant time of managed container to be recorded")
+	}
+	if _, ok := ignored.GetTag(tagGrantedAt); ok {
+		t.Errorf("unexpected grant time of ignored container")
+	}
+}
+
+func TestMarkGranted(t *testing.T) {
+	c := &fakeContainer{name: "ctr", cpus: "0-1", mems: "0", tags: map[string]string{}}
+
+	markGranted(c)
+	if _, ok := grantTime(c); !ok {
+		t.Fatalf("expected grant time to be recorded")
+	}
+
+	old := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	c.SetTag(tagGrantedAt, old)
+	markGranted(c)
+	if c.tags[tagGrantedAt] != old {
+		t.Errorf("expected grant time of unchanged grant to be kept")
+	}
+
+	c.mems = "0,1"
+	markGranted(c)
+	if at, _ := grantTime(c); time.Since(at) > time.Minute {
+		t.Errorf("expected grant time to be reset by changed memory, got %v", at)
+	}
+
+	c.SetTag(tagGrantedAt, old)
+	c.cpus = "2-3"
+	markGranted(c)
+	if at, _ := grantTime(c); time.Since(at) > time.Minute {
+		t.Errorf("expected grant time to be reset by changed CPUs, got %v", at)
+	}
+}
+
+// rebalanceChanges returns the total number of changed containers observed for rebalancing.
+func rebalanceChanges(t *testing.T, policy string) float64 {
+	m := &model.Metric{}
+	if err := decisions.rebalance.WithLabelValues(policy).(prometheus.Metric).Write(m); err != nil {
+		t.Fatalf("failed to read rebalancing metrics: %v", err)
+	}
+	return m.GetHistogram().GetSampleSum()
+}
+
+func TestRebalanceChanges(t *testing.T) {
+	moved := &fakeContainer{name: "moved", cpus: "0-1", mems: "0", tags: map[string]string{}}
+	kept := &fakeContainer{name: "kept", cpus: "2-3", mems: "0", tags: map[string]string{}}
+	ignored := &fakeContainer{name: "ignored", ignored: true, cpus: "4", tags: map[string]string{}}
+	b := &recordingBackend{
+		rebalance: func() {
+			moved.cpus = "4-5"
+			ignored.cpus = "5"
+		},
+	}
+	p := &policy{
+		backend: b,
+		cache:   &cachetest.FakeCache{Containers: []cache.Container{moved, kept, ignored}},
+	}
+
+	old := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	for _, c := range []*fakeContainer{moved, kept} {
+		markGranted(c)
+		c.SetTag(tagGrantedAt, old)
+	}
+
+	before := rebalanceChanges(t, b.Name())
+	p.Rebalance()
+	if changed := rebalanceChanges(t, b.Name()) - before; changed != 1 {
+		t.Errorf("expected 1 changed container, got %v", changed)
+	}
+
+	if kept.tags[tagGrantedAt] != old {
+		t.Errorf("expected grant time of unmoved container to be kept")
+	}
+	if moved.tags[tagGrantedAt] == old {
+		t.Errorf("expected grant time of moved container to be reset")
+	}
+	if _, ok := ignored.tags[tagGrantedAt]; ok {
+		t.Errorf("unexpected grant time of ignored container")
+	}
+}
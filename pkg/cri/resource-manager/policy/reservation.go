@@ -0,0 +1,53 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"errors"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// ErrReservationsNotSupported is returned by policies which can't reserve resources.
+var ErrReservationsNotSupported = errors.New("policy does not support resource reservations")
+
+// Reservation is a reservation of node resources for a pod ahead of its creation.
+//
+// Reservations are made by external schedulers once they have picked the node
+// for a pending pod, so the resources are still available when the containers
+// of the pod get created. A reservation is consumed as the containers of the pod
+// get allocated resources, and it is dropped once it expires.
+type Reservation struct {
+	// PodUID is the UID of the pod to reserve resources for.
+	PodUID string
+	// CPUs is the number of exclusive CPUs to reserve.
+	CPUs int
+	// Memory is the amount of memory to reserve, in bytes.
+	Memory int64
+	// Expires is the time the reservation expires if not consumed by then.
+	Expires time.Time
+	// Pool is the pool the resources got reserved from, filled in by the policy.
+	Pool string
+	// Cpuset is the set of reserved exclusive CPUs, filled in by the policy.
+	Cpuset cpuset.CPUSet
+	// Mems is the set of memory nodes of the reserved resources, filled in by the policy.
+	Mems string
+}
+
+// Expired checks if the reservation has expired.
+func (r *Reservation) Expired(now time.Time) bool {
+	return !r.Expires.IsZero() && !now.Before(r.Expires)
+}
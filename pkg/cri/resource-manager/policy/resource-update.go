@@ -0,0 +1,210 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// TagResourceUpdate tags containers with the verdict on their last in-place resource update.
+	TagResourceUpdate = "policy/resource-update"
+
+	// boundsClamp clamps updates exceeding the bounds to the bounds.
+	boundsClamp = "clamp"
+	// boundsReject rejects updates exceeding the bounds.
+	boundsReject = "reject"
+)
+
+// UpdateVerdict is the verdict on an in-place resource update of a container.
+type UpdateVerdict string
+
+const (
+	// UpdateAccepted is the verdict on updates applied as requested.
+	UpdateAccepted UpdateVerdict = "accepted"
+	// UpdateClamped is the verdict on updates applied with some resources clamped.
+	UpdateClamped UpdateVerdict = "clamped"
+	// UpdateRejected is the verdict on updates not applied at all.
+	UpdateRejected UpdateVerdict = "rejected"
+)
+
+// ResourceUpdateOptions bounds in-place resource updates of containers.
+//
+// In-place updates of the resources of running containers typically come from
+// vertical pod autoscalers. The CPU request and limit of a container are bounded
+// by MinCPU and MaxCPU, its memory limit by MinMemory and MaxMemory. Updates
+// outside the bounds are clamped to them, or rejected with OutOfBounds 'reject'.
+type ResourceUpdateOptions struct {
+	// OutOfBounds is what is done with updates outside the bounds, clamp or reject.
+	OutOfBounds string `json:",omitempty"`
+	// MinCPU is the lower bound of CPU requests and limits.
+	MinCPU *resource.Quantity `json:",omitempty"`
+	// MaxCPU is the upper bound of CPU requests and limits.
+	MaxCPU *resource.Quantity `json:",omitempty"`
+	// MinMemory is the lower bound of memory limits.
+	MinMemory *resource.Quantity `json:",omitempty"`
+	// MaxMemory is the upper bound of memory limits.
+	MaxMemory *resource.Quantity `json:",omitempty"`
+}
+
+// ResourceUpdate is an in-place resource update of a container under review.
+//
+// The update starts out accepted, with Resources as requested. Reviewing it can
+// clamp Resources, or reject it altogether.
+type ResourceUpdate struct {
+	// Current are the resources of the container before the update.
+	Current corev1.ResourceRequirements
+	// Resources are the resources to update the container to.
+	Resources corev1.ResourceRequirements
+	// Verdict is the verdict on the update.
+	Verdict UpdateVerdict
+	// Reason explains why the update was clamped or rejected.
+	Reason string
+}
+
+// NewResourceUpdate creates an accepted update of a container to the given resources.
+func NewResourceUpdate(current, requested corev1.ResourceRequirements) *ResourceUpdate {
+	return &ResourceUpdate{
+		Current: current,
+		Resources: corev1.ResourceRequirements{
+			Requests: requested.Requests.DeepCopy(),
+			Limits:   requested.Limits.DeepCopy(),
+		},
+		Verdict: UpdateAccepted,
+	}
+}
+
+// Clamp clamps a resource of the update to the given value.
+func (u *ResourceUpdate) Clamp(list corev1.ResourceList, name corev1.ResourceName,
+	value resource.Quantity, reason string) {
+	if u.Verdict == UpdateRejected {
+		return
+	}
+	list[name] = value
+	u.Verdict = UpdateClamped
+	u.addReason(reason)
+}
+
+// Reject rejects the update.
+func (u *ResourceUpdate) Reject(reason string) {
+	u.Verdict = UpdateRejected
+	u.Reason = reason
+}
+
+// String returns the verdict, and the reason for it if any.
+func (u *ResourceUpdate) String() string {
+	if u.Reason == "" {
+		return string(u.Verdict)
+	}
+	return string(u.Verdict) + ": " + u.Reason
+}
+
+// addReason adds a reason for clamping the update.
+func (u *ResourceUpdate) addReason(reason string) {
+	if u.Reason == "" {
+		u.Reason = reason
+	} else {
+		u.Reason += "; " + reason
+	}
+}
+
+// reviewResourceBounds clamps or rejects an update exceeding the configured bounds.
+func reviewResourceBounds(u *ResourceUpdate) {
+	o := opt.ResourceUpdates
+	if o == nil {
+		return
+	}
+	r, c := u.Resources, u.Current
+	checkResourceBound(u, o, c.Requests, r.Requests, corev1.ResourceCPU, "CPU request", o.MinCPU, o.MaxCPU)
+	checkResourceBound(u, o, c.Limits, r.Limits, corev1.ResourceCPU, "CPU limit", o.MinCPU, o.MaxCPU)
+	checkResourceBound(u, o, c.Limits, r.Limits, corev1.ResourceMemory, "memory limit",
+		o.MinMemory, o.MaxMemory)
+}
+
+// checkResourceBound clamps or rejects a single resource of an update exceeding its bounds.
+//
+// Only resources the update changes are checked. Resources which are absent
+// from the update, or which were absent or zero before it, are left alone, so
+// for instance the nominal CPU request of a BestEffort container is never
+// clamped up to the minimum, which would turn it into a sized container.
+func checkResourceBound(u *ResourceUpdate, o *ResourceUpdateOptions, current, list corev1.ResourceList,
+	name corev1.ResourceName, what string, min, max *resource.Quantity) {
+	qty, ok := list[name]
+	if !ok || u.Verdict == UpdateRejected {
+		return
+	}
+	if old, ok := current[name]; !ok || old.IsZero() || old.Cmp(qty) == 0 {
+		return
+	}
+
+	var bound *resource.Quantity
+	var reason string
+	switch {
+	case min != nil && qty.Cmp(*min) < 0:
+		bound = min
+		reason = fmt.Sprintf("%s %s below minimum %s", what, qty.String(), min.String())
+	case max != nil && qty.Cmp(*max) > 0:
+		bound = max
+		reason = fmt.Sprintf("%s %s above maximum %s", what, qty.String(), max.String())
+	default:
+		return
+	}
+
+	if strings.ToLower(o.OutOfBounds) == boundsReject {
+		u.Reject(reason)
+		return
+	}
+	u.Clamp(list, name, bound.DeepCopy(), reason)
+}
+
+// validateResourceUpdateOptions checks that resource update bounds are sane.
+func validateResourceUpdateOptions(o *ResourceUpdateOptions) error {
+	if o == nil {
+		return nil
+	}
+	switch strings.ToLower(o.OutOfBounds) {
+	case "", boundsClamp, boundsReject:
+	default:
+		return policyError("invalid resource update OutOfBounds %q, expected %q or %q",
+			o.OutOfBounds, boundsClamp, boundsReject)
+	}
+	if o.MinCPU != nil && o.MaxCPU != nil && o.MinCPU.Cmp(*o.MaxCPU) > 0 {
+		return policyError("resource update MinCPU %s is above MaxCPU %s", o.MinCPU, o.MaxCPU)
+	}
+	if o.MinMemory != nil && o.MaxMemory != nil && o.MinMemory.Cmp(*o.MaxMemory) > 0 {
+		return policyError("resource update MinMemory %s is above MaxMemory %s",
+			o.MinMemory, o.MaxMemory)
+	}
+	return nil
+}
+
+// ReviewResourceUpdate reviews an in-place resource update of a container.
+//
+// The update is first checked against the configured bounds, then reviewed by
+// the active policy, unless it has already been rejected. The verdict is stored
+// as a tag of the container.
+func (p *policy) ReviewResourceUpdate(c cache.Container, u *ResourceUpdate) {
+	reviewResourceBounds(u)
+	if u.Verdict != UpdateRejected {
+		p.backend.ReviewResourceUpdate(c, u)
+	}
+	c.SetTag(TagResourceUpdate, u.String())
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// cpuList returns a resource list with the given CPU quantity, or an empty one.
+func cpuList(qty string) corev1.ResourceList {
+	if qty == "" {
+		return corev1.ResourceList{}
+	}
+	return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(qty)}
+}
+
+func TestCheckResourceBound(t *testing.T) {
+	min, max := resource.MustParse("500m"), resource.MustParse("4")
+
+	tcases := []struct {
+		name        string
+		outOfBounds string
+		current     string
+		requested   string
+		verdict     UpdateVerdict
+		expected    string
+	}{
+		{
+			name:      "within bounds",
+			current:   "1",
+			requested: "2",
+			verdict:   UpdateAccepted,
+			expected:  "2",
+		},
+		{
+			name:      "above maximum, clamped",
+			current:   "1",
+			requested: "8",
+			verdict:   UpdateClamped,
+			expected:  "4",
+		},
+		{
+			name:      "below minimum, clamped",
+			current:   "1",
+			requested: "100m",
+			verdict:   UpdateClamped,
+			expected:  "500m",
+		},
+		{
+			name:        "above maximum, rejected",
+			outOfBounds: boundsReject,
+			current:     "1",
+			requested:   "8",
+			verdict:     UpdateRejected,
+			expected:    "8",
+		},
+		{
+			name:      "absent from update",
+			current:   "1",
+			requested: "",
+			verdict:   UpdateAccepted,
+		},
+		{
+			name:      "absent before update",
+			current:   "",
+			requested: "8",
+			verdict:   UpdateAccepted,
+			expected:  "8",
+		},
+		{
+			name:      "zero before update",
+			current:   "0",
+			requested: "8",
+			verdict:   UpdateAccepted,
+			expected:  "8",
+		},
+		{
+			name:      "unchanged",
+			current:   "8",
+			requested: "8",
+			verdict:   UpdateAccepted,
+			expected:  "8",
+		},
+		{
+			name:      "BestEffort request not raised",
+			current:   "",
+			requested: "0",
+			verdict:   UpdateAccepted,
+			expected:  "0",
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := &ResourceUpdateOptions{OutOfBounds: tc.outOfBounds, MinCPU: &min, MaxCPU: &max}
+			u := NewResourceUpdate(
+				corev1.ResourceRequirements{Requests: cpuList(tc.current)},
+				corev1.ResourceRequirements{Requests: cpuList(tc.requested)},
+			)
+			checkResourceBound(u, o, u.Current.Requests, u.Resources.Requests,
+				corev1.ResourceCPU, "CPU request", o.MinCPU, o.MaxCPU)
+			if u.Verdict != tc.verdict {
+				t.Errorf("expected verdict %s, got %s", tc.verdict, u)
+			}
+			qty, ok := u.Resources.Requests[corev1.ResourceCPU]
+			if tc.expected == "" {
+				if ok {
+					t.Errorf("expected no CPU request, got %s", qty.String())
+				}
+				return
+			}
+			if expected := resource.MustParse(tc.expected); !ok || qty.Cmp(expected) != 0 {
+				t.Errorf("expected CPU request %s, got %s", tc.expected, qty.String())
+			}
+		})
+	}
+}
+
+func TestReviewResourceBounds(t *testing.T) {
+	saved := opt.ResourceUpdates
+	defer func() { opt.ResourceUpdates = saved }()
+
+	min, max := resource.MustParse("1Gi"), resource.MustParse("4Gi")
+	opt.ResourceUpdates = &ResourceUpdateOptions{MinMemory: &min, MaxMemory: &max}
+
+	current := corev1.ResourceRequirements{
+		Requests: cpuList("1"),
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("2Gi"),
+		},
+	}
+	requested := corev1.ResourceRequirements{
+		Requests: cpuList("100"),
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("200"),
+			corev1.ResourceMemory: resource.MustParse("8Gi"),
+		},
+	}
+
+	u := NewResourceUpdate(current, requested)
+	reviewResourceBounds(u)
+	if u.Verdict != UpdateClamped {
+		t.Fatalf("expected verdict %s, got %s", UpdateClamped, u)
+	}
+	if qty := u.Resources.Limits[corev1.ResourceMemory]; qty.Cmp(max) != 0 {
+		t.Errorf("expected memory limit %s, got %s", max.String(), qty.String())
+	}
+	// CPU is unbounded, so it is left as requested
+	if qty := u.Resources.Limits[corev1.ResourceCPU]; qty.Cmp(resource.MustParse("200")) != 0 {
+		t.Errorf("expected CPU limit 200, got %s", qty.String())
+	}
+
+	opt.ResourceUpdates = nil
+	u = NewResourceUpdate(current, requested)
+	reviewResourceBounds(u)
+	if u.Verdict != UpdateAccepted {
+		t.Errorf("expected verdict %s without bounds, got %s", UpdateAccepted, u)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// RuntimeClassOptions describes how containers of a runtime handler (RuntimeClass) are treated.
+//
+// For VM-isolated pods, for instance with kata or gVisor runtime handlers, cgroup-level
+// controls apply to the VMM instead of the workload running in the guest. Such pods
+// are typically better off with full CPUs, with their vCPUs aligned to a single NUMA
+// node, and without any RDT class assignment.
+type RuntimeClassOptions struct {
+	// FullCores rounds CPU requests up to full, exclusively allocated physical cores.
+	FullCores bool `json:",omitempty"`
+	// SingleNUMANode aligns all CPUs of a container to a single NUMA node.
+	SingleNUMANode bool `json:",omitempty"`
+	// SkipRDT leaves containers out of RDT class assignment.
+	SkipRDT bool `json:",omitempty"`
+}
+
+// GetRuntimeClassOptions returns the runtime class options for the container, or nil.
+func GetRuntimeClassOptions(c cache.Container) *RuntimeClassOptions {
+	if len(opt.RuntimeClasses) == 0 {
+		return nil
+	}
+	pod, ok := c.GetPod()
+	if !ok {
+		return nil
+	}
+	handler := pod.GetRuntimeHandler()
+	if handler == "" {
+		return nil
+	}
+	return opt.RuntimeClasses[handler]
+}
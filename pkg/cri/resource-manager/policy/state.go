@@ -0,0 +1,131 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// State is a snapshot of the internal state of a policy, for offline inspection.
+type State struct {
+	// Policy is the name of the policy.
+	Policy string `json:"policy"`
+	// Time is when the snapshot was taken.
+	Time time.Time `json:"time"`
+	// Pools are the pools of the policy, parents before their children.
+	Pools []*PoolState `json:"pools,omitempty"`
+	// Grants are the resources granted to containers.
+	Grants []*GrantState `json:"grants,omitempty"`
+	// Scores are how pools scored when containers were last placed.
+	Scores []*ScoreState `json:"scores,omitempty"`
+}
+
+// PoolState is the state of a pool.
+type PoolState struct {
+	// Name of the pool.
+	Name string `json:"name"`
+	// Kind of the pool, for instance 'socket' or 'numa node'.
+	Kind string `json:"kind,omitempty"`
+	// Parent is the name of the parent pool, if any.
+	Parent string `json:"parent,omitempty"`
+	// Attributes are policy-specific attributes of the pool.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// GrantState is the state of the resources granted to a container.
+type GrantState struct {
+	// Container is the name of the container.
+	Container string `json:"container"`
+	// Pool is the name of the pool the container was granted resources from.
+	Pool string `json:"pool"`
+	// Attributes are policy-specific attributes of the grant.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// ScoreState is how a pool scored when a container was last placed.
+type ScoreState struct {
+	// Container is the name of the container placed.
+	Container string `json:"container"`
+	// Pool is the name of the pool scored.
+	Pool string `json:"pool"`
+	// Rank is the position of the pool among all candidates, 0 for the best one.
+	Rank int `json:"rank"`
+	// Attributes are the policy-specific intermediates of the scoring.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Dot renders the pools and grants of the state as a graphviz digraph.
+func (s *State) Dot() []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "digraph %s {\n", dotQuote(s.Policy))
+	fmt.Fprintf(buf, "  label=%s;\n", dotQuote(s.Policy+" @ "+s.Time.Format(time.RFC3339)))
+	fmt.Fprintf(buf, "  node [fontname=monospace];\n")
+
+	for _, pool := range s.Pools {
+		fmt.Fprintf(buf, "  %s [shape=box, label=%s];\n", dotID("pool", pool.Name),
+			dotLabel(pool.Name+" ("+pool.Kind+")", pool.Attributes))
+		if pool.Parent != "" {
+			fmt.Fprintf(buf, "  %s -> %s;\n", dotID("pool", pool.Parent), dotID("pool", pool.Name))
+		}
+	}
+
+	for _, grant := range s.Grants {
+		fmt.Fprintf(buf, "  %s [shape=ellipse, label=%s];\n", dotID("grant", grant.Container),
+			dotLabel(grant.Container, grant.Attributes))
+		fmt.Fprintf(buf, "  %s -> %s [style=dashed];\n", dotID("pool", grant.Pool),
+			dotID("grant", grant.Container))
+	}
+
+	fmt.Fprintf(buf, "}\n")
+
+	return buf.Bytes()
+}
+
+// dotID returns a quoted graphviz node ID for a named object of the given kind.
+func dotID(kind, name string) string {
+	return dotQuote(kind + ":" + name)
+}
+
+// dotLabel returns a quoted graphviz label with a title and sorted attributes.
+func dotLabel(title string, attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// graphviz takes \l as a left-justified line break
+	label := dotEscape(title) + "\\l"
+	for _, key := range keys {
+		label += dotEscape(key+": "+attributes[key]) + "\\l"
+	}
+
+	return "\"" + label + "\""
+}
+
+// dotQuote returns a string quoted for graphviz.
+func dotQuote(s string) string {
+	return "\"" + dotEscape(s) + "\""
+}
+
+// dotEscape escapes a string for use within graphviz quotes.
+func dotEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", " ").Replace(s)
+}
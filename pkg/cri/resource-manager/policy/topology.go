@@ -0,0 +1,59 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// ZoneTypeNode is the zone type of NUMA nodes, as expected by the scheduler.
+	ZoneTypeNode = "Node"
+)
+
+// TopologyZone describes the resources of a single topology zone of the node.
+//
+// The layout of zones follows that of the zones in the v1alpha1 NodeResourceTopology
+// custom resource, which is what the topology-aware scheduler plugin consumes.
+type TopologyZone struct {
+	// Name of the zone, for NUMA nodes 'node-<id>'.
+	Name string `json:"name"`
+	// Type of the zone, ZoneTypeNode for NUMA nodes.
+	Type string `json:"type"`
+	// Parent is the name of the enclosing zone, if any.
+	Parent string `json:"parent,omitempty"`
+	// Resources are the resources of the zone.
+	Resources []*ZoneResource `json:"resources,omitempty"`
+	// Attributes are extra, policy-specific attributes of the zone.
+	Attributes []*ZoneAttribute `json:"attributes,omitempty"`
+}
+
+// ZoneResource describes the amounts of a single resource in a zone.
+type ZoneResource struct {
+	// Name of the resource.
+	Name string `json:"name"`
+	// Capacity is the total amount of the resource in the zone.
+	Capacity resource.Quantity `json:"capacity"`
+	// Allocatable is the amount available for workloads in total.
+	Allocatable resource.Quantity `json:"allocatable"`
+	// Available is the amount currently not allocated to any workload.
+	Available resource.Quantity `json:"available"`
+}
+
+// ZoneAttribute is a name-value attribute of a zone.
+type ZoneAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
@@ -0,0 +1,402 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	core_v1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/agent"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+// pressureResources are the resources we collect pressure stall information for.
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// pressureOptions captures our configurable pressure stall information triggers.
+type pressureOptions struct {
+	// CPU is the CPU pressure (% of time stalled) above which a pool is under pressure.
+	CPU float64 `json:",omitempty"`
+	// Memory is the memory pressure (% of time stalled) above which a pool is under pressure.
+	Memory float64 `json:",omitempty"`
+	// IO is the I/O pressure (% of time stalled) above which a pool is under pressure.
+	IO float64 `json:",omitempty"`
+	// Hysteresis is the fraction (0 - 1) of the limits below which a pool calms down.
+	Hysteresis float64 `json:",omitempty"`
+	// Samples is the number of consecutive samples needed to flag or unflag a pool.
+	Samples int `json:",omitempty"`
+	// Rebalance requests rebalancing when pools come under or out of pressure.
+	Rebalance bool `json:",omitempty"`
+}
+
+// pressureStates is the detection state of pools, by pool cpuset.
+type pressureStates map[string]*hysteresis
+
+// Our pressure stall information trigger configuration.
+var pressure = defaultPressureOptions().(*pressureOptions)
+
+// defaultPressureOptions returns a new pressureOptions instance, all initialized to defaults.
+func defaultPressureOptions() interface{} {
+	return &pressureOptions{
+		Hysteresis: 0.8,
+		Samples:    3,
+	}
+}
+
+// enabled checks if pressure stall information triggers are enabled.
+func (o *pressureOptions) enabled() bool {
+	return o.CPU > 0 || o.Memory > 0 || o.IO > 0
+}
+
+// limit returns the configured pressure limit of a resource, 0 if none.
+func (o *pressureOptions) limit(resource string) float64 {
+	switch resource {
+	case "cpu":
+		return o.CPU
+	case "memory":
+		return o.Memory
+	case "io":
+		return o.IO
+	}
+	return 0
+}
+
+// exceeded returns the resources the given pressures exceed the limits of, scaled by the factor.
+func (o *pressureOptions) exceeded(pressures map[string]float64, scale float64) []string {
+	resources := []string{}
+	for _, resource := range pressureResources {
+		limit := o.limit(resource)
+		if limit > 0 && pressures[resource] > scale*limit {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// processPressure processes pressure stall information, flagging pools under pressure.
+//
+// Containers sharing the same set of CPUs form a pool. A pool is flagged to be
+// under pressure once the average 10 second pressure of its containers stays above
+// the configured limit of any resource for the configured number of consecutive
+// samples. It is unflagged once the pressures of all resources stay below the
+// limits scaled down by the hysteresis factor for the same number of samples.
+// The containers of flagged pools are tagged with cache.TagPressure, for policies
+// to act upon, and rebalancing is requested if configured.
+func (m *resmgr) processPressure() {
+	if !pressure.enabled() {
+		if len(m.pressure) > 0 || pressureMetrics.reset() {
+			for _, c := range m.cache.GetContainers() {
+				c.DeleteTag(cache.TagPressure)
+			}
+			m.pressure = nil
+		}
+		return
+	}
+
+	if m.pressure == nil {
+		m.pressure = make(pressureStates)
+	}
+
+	pools := map[string][]cache.Container{}
+	for _, c := range m.cache.GetContainers() {
+		if c.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		if cpus := c.GetCpusetCpus(); cpus != "" {
+			pools[cpus] = append(pools[cpus], c)
+		}
+	}
+
+	triggered := false
+	events := []*agent.PodEvent{}
+	for pool, containers := range pools {
+		pressures, ok := m.poolPressure(containers)
+		if !ok {
+			delete(m.pressure, pool)
+			continue
+		}
+		pressureMetrics.update(pool, pressures)
+
+		h, ok := m.pressure[pool]
+		if !ok {
+			h = &hysteresis{}
+			m.pressure[pool] = h
+		}
+
+		flagged := false
+		for _, c := range containers {
+			if _, ok := c.GetTag(cache.TagPressure); ok {
+				flagged = true
+				break
+			}
+		}
+
+		exceeded := pressure.exceeded(pressures, 1.0)
+		high := len(exceeded) > 0
+		low := len(pressure.exceeded(pressures, pressure.Hysteresis)) == 0
+		if !h.update(flagged, high, low, pressure.Samples) {
+			continue
+		}
+		if flagged {
+			m.unflagPressure(pool, containers)
+		} else {
+			events = append(events, m.flagPressure(pool, containers, exceeded, pressures)...)
+		}
+		triggered = true
+	}
+
+	for pool := range m.pressure {
+		if _, ok := pools[pool]; !ok {
+			delete(m.pressure, pool)
+		}
+	}
+	pressureMetrics.prune(pools)
+
+	if !triggered {
+		return
+	}
+
+	if opt.PodEvents {
+		m.sendPodEvents(events)
+	}
+	m.cache.Save()
+
+	if pressure.Rebalance {
+		m.requestRebalance("resource pressure")
+	}
+}
+
+// flagPressure flags the containers of a pool under pressure.
+func (m *resmgr) flagPressure(pool string, containers []cache.Container, resources []string,
+	pressures map[string]float64) []*agent.PodEvent {
+	details := []string{}
+	for _, resource := range resources {
+		details = append(details, resource+" "+formatPressure(pressures[resource]))
+		pressureMetrics.trigger(resource)
+	}
+
+	action := "tagging containers for the policy"
+	if pressure.Rebalance {
+		action = "requesting rebalancing"
+	}
+	evtlog.Info("pool %s is under sustained pressure (%s), %s", pool,
+		strings.Join(details, ", "), action)
+
+	events := []*agent.PodEvent{}
+	value := strings.Join(resources, ",")
+	for _, c := range containers {
+		c.SetTag(cache.TagPressure, value)
+		events = append(events, podEvent(c, core_v1.EventTypeWarning, EventResourcePressure,
+			"CPUs %s of container %s under sustained %s pressure, %s", pool, c.GetName(),
+			strings.Join(details, ", "), action))
+	}
+
+	return events
+}
+
+// unflagPressure unflags the containers of a pool no longer under pressure.
+func (m *resmgr) unflagPressure(pool string, containers []cache.Container) {
+	evtlog.Info("pool %s is no longer under pressure", pool)
+	for _, c := range containers {
+		c.DeleteTag(cache.TagPressure)
+	}
+}
+
+// poolPressure returns the average 10 second pressures of the given containers.
+func (m *resmgr) poolPressure(containers []cache.Container) (map[string]float64, bool) {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for _, c := range containers {
+		dir, err := cgroups.ResolveCgroupPath(cgroupRoot, c.GetID())
+		if err != nil {
+			continue
+		}
+		for _, resource := range pressureResources {
+			psi, err := cgroups.GetPressure(dir, resource)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					m.Debug("failed to read %s pressure of %s: %v", resource, c.PrettyName(), err)
+				}
+				continue
+			}
+			sums[resource] += psi.Some.Avg10
+			counts[resource]++
+		}
+	}
+
+	if len(counts) == 0 {
+		return nil, false
+	}
+
+	pressures := map[string]float64{}
+	for resource, sum := range sums {
+		pressures[resource] = sum / float64(counts[resource])
+	}
+	return pressures, true
+}
+
+// formatPressure formats a pressure percentage.
+func formatPressure(value float64) string {
+	return fmt.Sprintf("%.2f%%", value)
+}
+
+// validatePressureOptions checks the pressure stall information trigger configuration.
+func validatePressureOptions(cfg interface{}) error {
+	o, ok := cfg.(*pressureOptions)
+	if !ok {
+		return resmgrError("invalid pressure configuration %T", cfg)
+	}
+	for _, resource := range pressureResources {
+		if limit := o.limit(resource); limit < 0 || limit > 100 {
+			return resmgrError("invalid %s pressure limit %f, expecting 0 - 100", resource, limit)
+		}
+	}
+	if o.Hysteresis <= 0 || o.Hysteresis > 1 {
+		return resmgrError("invalid pressure hysteresis %f, expecting 0 < h <= 1", o.Hysteresis)
+	}
+	if o.Samples < 1 {
+		return resmgrError("invalid pressure sample count %d, expecting >= 1", o.Samples)
+	}
+	return nil
+}
+
+// pressureMetricsCollector exports the pressure of pools and the triggers fired.
+type pressureMetricsCollector struct {
+	sync.Mutex
+	pressure *prometheus.GaugeVec
+	triggers *prometheus.CounterVec
+	pools    map[string]struct{} // pools with exported pressure
+}
+
+// Our pool pressure metrics collector.
+var pressureMetrics = &pressureMetricsCollector{
+	pressure: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cri_resmgr_pool_pressure_percent",
+			Help: "Average 10 second pressure stall of the containers of a pool, by resource.",
+		},
+		[]string{"pool", "resource"},
+	),
+	triggers: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cri_resmgr_pressure_triggers_total",
+			Help: "Number of times pools were flagged to be under sustained pressure, by resource.",
+		},
+		[]string{"resource"},
+	),
+	pools: make(map[string]struct{}),
+}
+
+// update updates the exported pressures of a pool.
+func (c *pressureMetricsCollector) update(pool string, pressures map[string]float64) {
+	c.Lock()
+	defer c.Unlock()
+	for resource, value := range pressures {
+		c.pressure.WithLabelValues(pool, resource).Set(value)
+	}
+	c.pools[pool] = struct{}{}
+}
+
+// trigger counts a pressure trigger of a resource.
+func (c *pressureMetricsCollector) trigger(resource string) {
+	c.triggers.WithLabelValues(resource).Inc()
+}
+
+// prune drops the exported pressures of pools no longer around.
+func (c *pressureMetricsCollector) prune(current map[string][]cache.Container) {
+	c.Lock()
+	defer c.Unlock()
+	stale := []string{}
+	for pool := range c.pools {
+		if _, ok := current[pool]; !ok {
+			stale = append(stale, pool)
+		}
+	}
+	sort.Strings(stale)
+	for _, pool := range stale {
+		for _, resource := range pressureResources {
+			c.pressure.DeleteLabelValues(pool, resource)
+		}
+		delete(c.pools, pool)
+	}
+}
+
+// reset drops the exported pressures of all pools, returning whether there were any.
+func (c *pressureMetricsCollector) reset() bool {
+	c.Lock()
+	defer c.Unlock()
+	if len(c.pools) == 0 {
+		return false
+	}
+	c.pressure.Reset()
+	c.pools = make(map[string]struct{})
+	return true
+}
+
+// Describe implements prometheus.Collector.
+func (c *pressureMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.pressure.Describe(ch)
+	c.triggers.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *pressureMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	c.pressure.Collect(ch)
+	c.triggers.Collect(ch)
+}
+
+// Register us for configuration handling and metrics collection.
+func init() {
+	config.Register("resource-manager.pressure", pressureHelp, pressure, defaultPressureOptions,
+		config.WithValidator(validatePressureOptions))
+
+	err := metrics.RegisterCollector("pressure", func() (prometheus.Collector, error) {
+		return pressureMetrics, nil
+	})
+	if err != nil {
+		logger.Get("resource-manager").Error("failed to register pressure metrics collector: %v", err)
+	}
+}
+
+var pressureHelp = `
+Resource pressure triggers based on pressure stall information (PSI).
+
+The cpu, memory and io pressure of containers is read from their cgroups (v2)
+at every metrics poll. Containers sharing the same set of CPUs form a pool. A
+pool is flagged to be under pressure once the average 10 second pressure (the
+percentage of time some tasks were stalled) of its containers exceeds any of
+the configured limits for Samples consecutive polls, and unflagged once all
+stay below the limits scaled by Hysteresis for the same number of polls. The
+containers of flagged pools are tagged, for policies to give them more room,
+and rebalancing can be requested. For instance:
+
+  CPU: 20
+  Memory: 10
+  Hysteresis: 0.8
+  Samples: 3
+  Rebalance: true
+`
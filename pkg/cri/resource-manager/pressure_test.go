@@ -0,0 +1,93 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPressureExceeded(t *testing.T) {
+	o := &pressureOptions{CPU: 20, IO: 10}
+	tcases := []struct {
+		name      string
+		pressures map[string]float64
+		scale     float64
+		expected  []string
+	}{
+		{
+			name:      "below the limits",
+			pressures: map[string]float64{"cpu": 15, "io": 5},
+			scale:     1.0,
+			expected:  []string{},
+		},
+		{
+			name:      "at the limit",
+			pressures: map[string]float64{"cpu": 20},
+			scale:     1.0,
+			expected:  []string{},
+		},
+		{
+			name:      "above the limits",
+			pressures: map[string]float64{"cpu": 25, "io": 11},
+			scale:     1.0,
+			expected:  []string{"cpu", "io"},
+		},
+		{
+			name:      "unlimited resource",
+			pressures: map[string]float64{"memory": 90},
+			scale:     1.0,
+			expected:  []string{},
+		},
+		{
+			name:      "above the scaled limit",
+			pressures: map[string]float64{"cpu": 17, "io": 7},
+			scale:     0.8,
+			expected:  []string{"cpu"},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if exceeded := o.exceeded(tc.pressures, tc.scale); !reflect.DeepEqual(exceeded, tc.expected) {
+				t.Errorf("expected exceeded resources %v, got %v", tc.expected, exceeded)
+			}
+		})
+	}
+}
+
+func TestValidatePressureOptions(t *testing.T) {
+	tcases := []struct {
+		name string
+		opts *pressureOptions
+		err  bool
+	}{
+		{name: "defaults", opts: defaultPressureOptions().(*pressureOptions)},
+		{name: "valid", opts: &pressureOptions{CPU: 20, Memory: 10, IO: 100, Hysteresis: 1, Samples: 1}},
+		{name: "negative limit", opts: &pressureOptions{CPU: -1, Hysteresis: 0.8, Samples: 3}, err: true},
+		{name: "limit above 100", opts: &pressureOptions{IO: 101, Hysteresis: 0.8, Samples: 3}, err: true},
+		{name: "no hysteresis", opts: &pressureOptions{CPU: 20, Samples: 3}, err: true},
+		{name: "hysteresis above 1", opts: &pressureOptions{CPU: 20, Hysteresis: 1.5, Samples: 3}, err: true},
+		{name: "no samples", opts: &pressureOptions{CPU: 20, Hysteresis: 0.8}, err: true},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validatePressureOptions(tc.opts); (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/config"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// AnyNamespace is the quota key matching namespaces without an explicit quota.
+	AnyNamespace = "*"
+)
+
+// NamespaceQuota is the node-level cap on resources consumed by a namespace.
+type NamespaceQuota struct {
+	// ExclusiveCPUs is the maximum number of CPUs exclusively used by containers.
+	ExclusiveCPUs int `json:"exclusiveCPUs,omitempty"`
+	// MemoryNodes is the maximum number of memory nodes used by containers.
+	MemoryNodes int `json:"memoryNodes,omitempty"`
+}
+
+// quotaOptions captures our configurable namespace quotas.
+type quotaOptions struct {
+	// Namespaces is the per-namespace quotas, AnyNamespace for the default.
+	Namespaces map[string]*NamespaceQuota `json:",omitempty"`
+}
+
+// namespaceUsage is the resources consumed by a namespace.
+type namespaceUsage struct {
+	exclusive cpuset.CPUSet
+	mems      cpuset.CPUSet
+}
+
+// Our namespace quota configuration.
+var quotas = defaultQuotaOptions().(*quotaOptions)
+
+// defaultQuotaOptions returns a new quotaOptions instance, all initialized to defaults.
+func defaultQuotaOptions() interface{} {
+	return &quotaOptions{Namespaces: make(map[string]*NamespaceQuota)}
+}
+
+// quotaFor returns the quota of the given namespace, or nil if it has none.
+func (o *quotaOptions) quotaFor(namespace string) *NamespaceQuota {
+	if q, ok := o.Namespaces[namespace]; ok {
+		return q
+	}
+	return o.Namespaces[AnyNamespace]
+}
+
+// checkNamespaceQuota checks if an allocated container keeps its namespace within quota.
+//
+// The usage of the namespace is only known once the policy has allocated the
+// container, so the check can't be done up front. Releasing the resources of a
+// rejected container is a partial rollback: other containers moved around by
+// the policy while allocating it are not put back where they were.
+func (m *resmgr) checkNamespaceQuota(container cache.Container) error {
+	namespace := container.GetNamespace()
+	quota := quotas.quotaFor(namespace)
+	if quota == nil {
+		return nil
+	}
+
+	usage := m.namespaceUsage(namespace)
+
+	if quota.ExclusiveCPUs > 0 && usage.exclusive.Size() > quota.ExclusiveCPUs {
+		return resmgrError("namespace %s exclusive CPU quota exceeded: %d CPUs (%s) > %d",
+			namespace, usage.exclusive.Size(), usage.exclusive, quota.ExclusiveCPUs)
+	}
+	if quota.MemoryNodes > 0 && usage.mems.Size() > quota.MemoryNodes {
+		return resmgrError("namespace %s memory node quota exceeded: %d nodes (%s) > %d",
+			namespace, usage.mems.Size(), usage.mems, quota.MemoryNodes)
+	}
+
+	return nil
+}
+
+// namespaceUsage calculates the exclusive CPUs and memory nodes used by a namespace.
+//
+// A CPU is considered to be exclusively used by a container if it is not in the
+// cpuset of any other container. This is independent of the active policy.
+func (m *resmgr) namespaceUsage(namespace string) *namespaceUsage {
+	containers := m.cache.GetContainers()
+	exclusive := cache.ExclusiveCPUs(containers)
+	usage := &namespaceUsage{
+		exclusive: cpuset.NewCPUSet(),
+		mems:      cpuset.NewCPUSet(),
+	}
+
+	for _, c := range containers {
+		if c.GetNamespace() != namespace {
+			continue
+		}
+		switch c.GetState() {
+		case cache.ContainerStateExited, cache.ContainerStateStale:
+			continue
+		}
+		if cpus, ok := exclusive[c.GetCacheID()]; ok {
+			usage.exclusive = usage.exclusive.Union(cpus)
+		}
+		if nodes, err := cpuset.Parse(c.GetCpusetMems()); err == nil {
+			usage.mems = usage.mems.Union(nodes)
+		}
+	}
+
+	return usage
+}
+
+// Register us for configuration handling.
+func init() {
+	config.Register("resource-manager.quota", quotaHelp, quotas, defaultQuotaOptions)
+}
+
+var quotaHelp = `
+Per-namespace resource quotas enforced at the node level.
+
+Containers are rejected at creation if their allocated resources would take
+their namespace over its quota. Rejected containers have their resources
+released, but containers rebalanced while allocating them are not restored. Quotas are given per namespace, with '*'
+matching any namespace without an explicit quota. For instance:
+
+  Namespaces:
+    "*":
+      exclusiveCPUs: 8
+    batch:
+      exclusiveCPUs: 2
+      memoryNodes: 1
+`
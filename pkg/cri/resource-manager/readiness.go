@@ -0,0 +1,151 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+)
+
+const (
+	// ReadinessPath is the HTTP path for checking if we're ready to serve requests.
+	ReadinessPath = "/ready"
+
+	// readyCache is the condition of the cache being synchronized with the runtime.
+	readyCache = "cache"
+	// readyPolicy is the condition of the active policy being started.
+	readyPolicy = "policy"
+	// readyRuntime is the condition of the runtime being reachable.
+	readyRuntime = "runtime"
+)
+
+// readinessConditions are all the conditions which need to be met for us to be ready.
+var readinessConditions = []string{readyCache, readyPolicy, readyRuntime}
+
+// readiness tracks the conditions of our readiness to serve requests.
+type readiness struct {
+	sync.Mutex
+	conditions map[string]bool
+}
+
+// readinessReport is our readiness, with the state of the individual conditions.
+type readinessReport struct {
+	Ready      bool            `json:"ready"`
+	Conditions map[string]bool `json:"conditions"`
+	Pending    []string        `json:"pending,omitempty"`
+}
+
+// setupReadinessEndpoint sets up the HTTP endpoint for checking readiness.
+func (m *resmgr) setupReadinessEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, readiness endpoint disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, ReadinessPath, http.HandlerFunc(m.serveReadiness))
+
+	return nil
+}
+
+// serveReadiness reports our readiness, with status 503 if we're not ready.
+func (m *resmgr) serveReadiness(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "unsupported method "+req.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := m.readiness.report()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		m.Error("failed to serve readiness report: %v", err)
+	}
+}
+
+// setReady updates a readiness condition, and the gRPC health status of our relay.
+func (m *resmgr) setReady(condition string, ready bool) {
+	was, is, changed := m.readiness.set(condition, ready)
+	if !changed {
+		return
+	}
+
+	m.Info("readiness condition %s: %v", condition, ready)
+
+	if was != is {
+		if is {
+			m.Info("ready to serve requests")
+		} else {
+			m.Warn("not ready to serve requests")
+		}
+	}
+
+	if m.relay != nil {
+		m.relay.Server().SetServing(is)
+	}
+}
+
+// set updates a condition, returning readiness before and after, and whether the condition changed.
+func (r *readiness) set(condition string, ready bool) (bool, bool, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conditions == nil {
+		r.conditions = make(map[string]bool)
+	}
+
+	was := r.ready()
+	if old, ok := r.conditions[condition]; ok && old == ready {
+		return was, was, false
+	}
+	r.conditions[condition] = ready
+
+	return was, r.ready(), true
+}
+
+// ready returns true if all the conditions are met, with the lock held.
+func (r *readiness) ready() bool {
+	for _, condition := range readinessConditions {
+		if !r.conditions[condition] {
+			return false
+		}
+	}
+	return true
+}
+
+// report returns the current readiness report.
+func (r *readiness) report() *readinessReport {
+	r.Lock()
+	defer r.Unlock()
+
+	report := &readinessReport{
+		Ready:      r.ready(),
+		Conditions: make(map[string]bool),
+	}
+	for _, condition := range readinessConditions {
+		met := r.conditions[condition]
+		report.Conditions[condition] = met
+		if !met {
+			report.Pending = append(report.Pending, condition)
+		}
+	}
+
+	return report
+}
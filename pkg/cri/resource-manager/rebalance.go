@@ -0,0 +1,105 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// rebalancer rate-limits and batches container rebalancing passes.
+type rebalancer struct {
+	sync.Mutex
+	last  time.Time   // time the last rebalancing pass started
+	timer *time.Timer // timer of the next scheduled pass, if any
+}
+
+// requestRebalance asks for a rebalancing pass, subject to rate-limiting.
+//
+// Passes are never started less than the minimum rebalancing interval apart.
+// Requests arriving before that are coalesced into a single pass started once
+// the interval has passed.
+func (m *resmgr) requestRebalance(reason string) {
+	m.rebalance.Lock()
+	defer m.rebalance.Unlock()
+
+	if m.rebalance.timer != nil {
+		m.Debug("rebalancing (%s) coalesced with an already scheduled pass", reason)
+		return
+	}
+
+	delay := time.Until(m.rebalance.last.Add(opt.RebalanceMinInterval))
+	if delay < 0 {
+		delay = 0
+	}
+
+	m.Debug("scheduling rebalancing (%s) in %v", reason, delay)
+
+	m.rebalance.timer = time.AfterFunc(delay, func() {
+		m.rebalance.Lock()
+		m.rebalance.timer = nil
+		m.rebalance.Unlock()
+
+		if err := m.RebalanceContainers(); err != nil {
+			m.Error("rebalancing (%s) failed: %v", reason, err)
+		}
+	})
+}
+
+// stopRebalancing cancels any scheduled rebalancing pass.
+func (m *resmgr) stopRebalancing() {
+	m.rebalance.Lock()
+	defer m.rebalance.Unlock()
+
+	if m.rebalance.timer != nil {
+		m.rebalance.timer.Stop()
+		m.rebalance.timer = nil
+	}
+}
+
+// markRebalanced records the start of a rebalancing pass.
+func (m *resmgr) markRebalanced() {
+	m.rebalance.Lock()
+	defer m.rebalance.Unlock()
+	m.rebalance.last = time.Now()
+}
+
+// rebalanceBatch splits pending containers to ones updated now and ones deferred.
+//
+// At most the configured maximum number of containers is updated during a
+// single rebalancing pass. Updates of the rest of the containers are left
+// pending, to be applied in subsequent passes. Containers are updated in the
+// order of their reconciliation priority, Guaranteed ones first, BestEffort
+// ones last. Containers of the same priority are ordered by cache ID, only to
+// keep the order stable between passes.
+func (m *resmgr) rebalanceBatch(pending []cache.Container) ([]cache.Container, []cache.Container) {
+	max := opt.RebalanceMaxChanges
+	if max <= 0 || len(pending) <= max {
+		return pending, nil
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		pi, pj := reconcilePriority(pending[i], false), reconcilePriority(pending[j], false)
+		if pi != pj {
+			return pi < pj
+		}
+		return pending[i].GetCacheID() < pending[j].GetCacheID()
+	})
+
+	return pending[:max], pending[max:]
+}
@@ -0,0 +1,359 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+const (
+	// reconcileCritical is the priority of latency-critical (Guaranteed) containers.
+	reconcileCritical = iota
+	// reconcileBurstable is the priority of Burstable containers.
+	reconcileBurstable
+	// reconcileBatch is the priority of BestEffort containers.
+	reconcileBatch
+	// reconcileCleanup is the priority of cleaning up after stopped containers.
+	reconcileCleanup
+
+	// maxReconcileBackoff is the longest delay between retries of a failed reconciliation.
+	maxReconcileBackoff = time.Minute
+)
+
+// reconcileItem is a queued reconciliation of a single container.
+type reconcileItem struct {
+	container cache.Container // container to reconcile
+	priority  int             // priority, lower is more urgent
+	cleanup   bool            // run post-stop instead of post-update hooks
+	attempts  int             // number of failed attempts
+	due       time.Time       // earliest time of the next attempt
+	seq       uint64          // sequence number, for FIFO order within a priority
+}
+
+// reconcileQueue is a prioritized, rate-limited queue of container reconciliations.
+//
+// Reconciling a container runs the controller hooks which write its cgroup and
+// resctrl settings. Reconciliations triggered by synchronizing with the runtime,
+// rebalancing or repairing the cache are queued instead of being run inline, so
+// that one slow or failing write does not hold up updates of other containers.
+type reconcileQueue struct {
+	sync.Mutex
+	items    map[string]*reconcileItem // queued reconciliations, by container cache ID
+	inflight *reconcileItem            // reconciliation picked by the worker, not yet run
+	kick     chan struct{}             // wakes up the queue worker
+	last     time.Time                 // time of the last reconciliation
+	seq      uint64                    // next sequence number
+}
+
+// reconcilePriority returns the reconciliation priority of a container.
+func reconcilePriority(c cache.Container, cleanup bool) int {
+	if cleanup {
+		return reconcileCleanup
+	}
+	switch c.GetQOSClass() {
+	case corev1.PodQOSGuaranteed:
+		return reconcileCritical
+	case corev1.PodQOSBurstable:
+		return reconcileBurstable
+	}
+	return reconcileBatch
+}
+
+// queueReconcile queues the reconciliation of a container, replacing any queued one.
+func (m *resmgr) queueReconcile(c cache.Container, cleanup bool) {
+	q := &m.reconcile
+	q.Lock()
+	defer q.Unlock()
+
+	if q.items == nil {
+		q.items = make(map[string]*reconcileItem)
+		q.kick = make(chan struct{}, 1)
+	}
+
+	q.items[c.GetCacheID()] = &reconcileItem{
+		container: c,
+		priority:  reconcilePriority(c, cleanup),
+		cleanup:   cleanup,
+		seq:       q.seq,
+	}
+	q.seq++
+
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+// queuePostReleaseHooks queues the reconciliation of all pending containers.
+//
+// This is the queued counterpart of runPostReleaseHooks. Stopped containers are
+// removed from the cache right away, with the cleanup after them queued.
+func (m *resmgr) queuePostReleaseHooks(method string) error {
+	pending := m.cache.GetPendingContainers()
+	for _, c := range pending {
+		switch c.GetState() {
+		case cache.ContainerStateStale, cache.ContainerStateExited:
+			m.queueReconcile(c, true)
+			m.cache.DeleteContainer(c.GetCacheID())
+		case cache.ContainerStateRunning, cache.ContainerStateCreated:
+			m.queueReconcile(c, false)
+		default:
+			m.Warn("%s: skipping pending container %s (in state %v)",
+				method, c.PrettyName(), c.GetState())
+		}
+	}
+	m.Info("%s: queued reconciliation of %d containers", method, len(pending))
+	m.publishAssignments()
+	m.updatePlacementMetrics()
+	m.exportPodCPUSets()
+	m.emitPodEvents()
+	m.exportNodeTopology()
+	return nil
+}
+
+// queuePostUpdateHooksFor queues the reconciliation of the given containers.
+//
+// This is the queued counterpart of runPostUpdateHooksFor.
+func (m *resmgr) queuePostUpdateHooksFor(method string, containers []cache.Container) error {
+	for _, c := range containers {
+		switch c.GetState() {
+		case cache.ContainerStateRunning, cache.ContainerStateCreated:
+			m.queueReconcile(c, false)
+		default:
+			m.Warn("%s: skipping container %s (in state %v)", method,
+				c.PrettyName(), c.GetState())
+		}
+	}
+	m.publishAssignments()
+	m.updatePlacementMetrics()
+	m.exportPodCPUSets()
+	m.emitPodEvents()
+	m.exportNodeTopology()
+	return nil
+}
+
+// next returns the most urgent reconciliation due, or the time until one is.
+func (q *reconcileQueue) next(now time.Time) (*reconcileItem, time.Duration) {
+	q.Lock()
+	defer q.Unlock()
+
+	due := []*reconcileItem{}
+	wait := time.Duration(-1)
+	for _, item := range q.items {
+		if delay := item.due.Sub(now); delay > 0 {
+			if wait < 0 || delay < wait {
+				wait = delay
+			}
+			continue
+		}
+		due = append(due, item)
+	}
+	if len(due) == 0 {
+		return nil, wait
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].priority != due[j].priority {
+			return due[i].priority < due[j].priority
+		}
+		return due[i].seq < due[j].seq
+	})
+
+	item := due[0]
+	delete(q.items, item.container.GetCacheID())
+	q.inflight = item
+	return item, 0
+}
+
+// claim marks a reconciliation picked by next as run, unless it was taken meanwhile.
+func (q *reconcileQueue) claim(item *reconcileItem) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.inflight != item {
+		return false
+	}
+	q.inflight = nil
+	return true
+}
+
+// take removes and returns the queued or picked reconciliation of a container, if any.
+func (q *reconcileQueue) take(id string) *reconcileItem {
+	q.Lock()
+	defer q.Unlock()
+
+	if item, ok := q.items[id]; ok {
+		delete(q.items, id)
+		return item
+	}
+	if item := q.inflight; item != nil && item.container.GetCacheID() == id {
+		q.inflight = nil
+		return item
+	}
+	return nil
+}
+
+// retry requeues a failed reconciliation with exponential backoff, unless superseded.
+func (q *reconcileQueue) retry(item *reconcileItem) {
+	q.Lock()
+	defer q.Unlock()
+
+	id := item.container.GetCacheID()
+	if _, ok := q.items[id]; ok {
+		return
+	}
+
+	backoff := opt.ReconcileBackoff << uint(item.attempts-1)
+	if backoff <= 0 || backoff > maxReconcileBackoff {
+		backoff = maxReconcileBackoff
+	}
+	item.due = time.Now().Add(backoff)
+	q.items[id] = item
+}
+
+// startReconciling starts processing queued reconciliations.
+func (m *resmgr) startReconciling() {
+	q := &m.reconcile
+	q.Lock()
+	if q.items == nil {
+		q.items = make(map[string]*reconcileItem)
+		q.kick = make(chan struct{}, 1)
+	}
+	kick := q.kick
+	q.Unlock()
+
+	var interval time.Duration
+	if opt.ReconcileRate > 0 {
+		interval = time.Duration(float64(time.Second) / opt.ReconcileRate)
+	}
+
+	stop := m.stop
+	go func() {
+		for {
+			item, wait := q.next(time.Now())
+			if item == nil {
+				var timeout <-chan time.Time
+				if wait >= 0 {
+					timeout = time.After(wait)
+				}
+				select {
+				case _ = <-stop:
+					return
+				case _ = <-kick:
+				case _ = <-timeout:
+				}
+				continue
+			}
+
+			if delay := time.Until(q.last.Add(interval)); delay > 0 {
+				select {
+				case _ = <-stop:
+					return
+				case _ = <-time.After(delay):
+				}
+			}
+			q.last = time.Now()
+
+			m.runReconcile(item)
+		}
+	}()
+}
+
+// runReconcile reconciles a single container picked by the queue worker.
+func (m *resmgr) runReconcile(item *reconcileItem) {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.reconcile.claim(item) {
+		return
+	}
+	m.reconcileItem(item)
+}
+
+// flushReconcile runs any queued reconciliation of a container right away.
+//
+// This is called with the resource manager locked before starting a container,
+// so that settings still queued for it do not get its start fenced off.
+func (m *resmgr) flushReconcile(method string, c cache.Container) {
+	item := m.reconcile.take(c.GetCacheID())
+	if item == nil {
+		return
+	}
+	m.Debug("%s: flushing queued reconciliation of %s", method, c.PrettyName())
+	m.reconcileItem(item)
+}
+
+// reconcileItem reconciles a single container, requeueing it if it fails.
+func (m *resmgr) reconcileItem(item *reconcileItem) {
+	c := item.container
+	err := m.reconcileContainer(item)
+	if err == nil {
+		if item.attempts > 0 {
+			m.Info("reconciled %s after %d failed attempts", c.PrettyName(), item.attempts)
+		}
+		return
+	}
+
+	item.attempts++
+	if item.attempts > opt.ReconcileRetries {
+		m.Error("giving up reconciling %s after %d attempts: %v",
+			c.PrettyName(), item.attempts, err)
+		return
+	}
+
+	m.Warn("failed to reconcile %s (attempt %d), retrying: %v",
+		c.PrettyName(), item.attempts, err)
+	m.reconcile.retry(item)
+}
+
+// reconcileContainer runs the controller hooks of a single container.
+func (m *resmgr) reconcileContainer(item *reconcileItem) error {
+	c := item.container
+
+	if item.cleanup {
+		return m.control.RunPostStopHooks(c)
+	}
+
+	if _, ok := m.cache.LookupContainer(c.GetCacheID()); !ok {
+		m.Debug("dropping reconciliation of removed container %s", c.PrettyName())
+		return nil
+	}
+	switch c.GetState() {
+	case cache.ContainerStateRunning, cache.ContainerStateCreated:
+	default:
+		m.Debug("dropping reconciliation of %s (in state %v)", c.PrettyName(), c.GetState())
+		return nil
+	}
+
+	if err := m.control.RunPostUpdateHooks(c); err != nil {
+		return err
+	}
+	if req, ok := c.GetCRIRequest(); ok {
+		if _, err := m.sendCRIRequest(context.Background(), req); err != nil {
+			return err
+		}
+		c.ClearCRIRequest()
+	}
+	m.policy.ExportResourceData(c)
+
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// fakeControl records the containers it runs post-update hooks for.
+type fakeControl struct {
+	control.Control
+	updated []string
+	err     error
+	classes map[string]string
+}
+
+func (c *fakeControl) RunPostUpdateHooks(ctr cache.Container) error {
+	c.updated = append(c.updated, ctr.GetCacheID())
+	return c.err
+}
+
+func (c *fakeControl) ContainerClasses(cache.Container) map[string]string {
+	return c.classes
+}
+
+// fakePolicy is a policy which exports no resource data.
+type fakePolicy struct {
+	policy.Policy
+}
+
+func (p *fakePolicy) ExportResourceData(cache.Container) {}
+
+// newReconcileTestResmgr creates a resource manager with some running containers.
+func newReconcileTestResmgr(t *testing.T, count int) (*resmgr, []cache.Container, func()) {
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	m.control = &fakeControl{}
+	m.policy = &fakePolicy{}
+	m.reconcile.items = make(map[string]*reconcileItem)
+	m.reconcile.kick = make(chan struct{}, 1)
+
+	containers := []cache.Container{}
+	for i := 0; i < count; i++ {
+		containers = append(containers, createInstance(t, m, cfg, uint32(i)))
+	}
+	return m, containers, cleanup
+}
+
+func TestReconcileQueueNext(t *testing.T) {
+	m, ctrs, cleanup := newReconcileTestResmgr(t, 5)
+	defer cleanup()
+
+	now := time.Now()
+	q := &m.reconcile
+	for i, item := range []*reconcileItem{
+		{container: ctrs[0], priority: reconcileBatch, seq: 0},
+		{container: ctrs[1], priority: reconcileCritical, seq: 1},
+		{container: ctrs[2], priority: reconcileBatch, seq: 2},
+		{container: ctrs[3], priority: reconcileCleanup, seq: 3},
+		{container: ctrs[4], priority: reconcileCritical, seq: 4, due: now.Add(time.Second)},
+	} {
+		q.items[ctrs[i].GetCacheID()] = item
+	}
+
+	for _, expected := range []int{1, 0, 2, 3} {
+		item, _ := q.next(now)
+		if item == nil {
+			t.Fatalf("expected container #%d to be reconciled next, got none", expected)
+		}
+		if item.container != ctrs[expected] {
+			t.Errorf("expected container #%d to be reconciled next, got %s",
+				expected, item.container.GetCacheID())
+		}
+		if !q.claim(item) {
+			t.Errorf("failed to claim reconciliation of %s", item.container.GetCacheID())
+		}
+	}
+
+	item, wait := q.next(now)
+	if item != nil {
+		t.Errorf("expected no reconciliation due, got %s", item.container.GetCacheID())
+	}
+	if wait != time.Second {
+		t.Errorf("expected to wait %v for the next reconciliation, got %v", time.Second, wait)
+	}
+
+	if item, _ = q.next(now.Add(time.Second)); item == nil || item.container != ctrs[4] {
+		t.Errorf("expected the delayed reconciliation to be due, got %v", item)
+	}
+}
+
+func TestReconcileQueueRetry(t *testing.T) {
+	m, ctrs, cleanup := newReconcileTestResmgr(t, 2)
+	defer cleanup()
+
+	savedBackoff := opt.ReconcileBackoff
+	defer func() { opt.ReconcileBackoff = savedBackoff }()
+	opt.ReconcileBackoff = time.Second
+
+	q := &m.reconcile
+	for _, tc := range []struct {
+		attempts int
+		backoff  time.Duration
+	}{
+		{attempts: 1, backoff: time.Second},
+		{attempts: 2, backoff: 2 * time.Second},
+		{attempts: 4, backoff: 8 * time.Second},
+		{attempts: 10, backoff: maxReconcileBackoff},
+		{attempts: 100, backoff: maxReconcileBackoff},
+	} {
+		item := &reconcileItem{container: ctrs[0], attempts: tc.attempts}
+		before := time.Now()
+		q.retry(item)
+		if q.items[ctrs[0].GetCacheID()] != item {
+			t.Fatalf("attempt %d: expected reconciliation to be requeued", tc.attempts)
+		}
+		if delay := item.due.Sub(before); delay < tc.backoff || delay > tc.backoff+time.Second {
+			t.Errorf("attempt %d: expected backoff %v, got %v", tc.attempts, tc.backoff, delay)
+		}
+		delete(q.items, ctrs[0].GetCacheID())
+	}
+
+	m.queueReconcile(ctrs[1], false)
+	queued := q.items[ctrs[1].GetCacheID()]
+	q.retry(&reconcileItem{container: ctrs[1], attempts: 1})
+	if q.items[ctrs[1].GetCacheID()] != queued {
+		t.Errorf("expected retry not to replace a newer queued reconciliation")
+	}
+}
+
+func TestReconcileItem(t *testing.T) {
+	m, ctrs, cleanup := newReconcileTestResmgr(t, 1)
+	defer cleanup()
+
+	savedRetries := opt.ReconcileRetries
+	defer func() { opt.ReconcileRetries = savedRetries }()
+	opt.ReconcileRetries = 2
+
+	ctl := m.control.(*fakeControl)
+	ctl.err = fmt.Errorf("failed")
+	id := ctrs[0].GetCacheID()
+
+	item := &reconcileItem{container: ctrs[0]}
+	for attempt := 1; attempt <= 2; attempt++ {
+		m.reconcileItem(item)
+		if m.reconcile.items[id] != item || item.attempts != attempt {
+			t.Fatalf("expected failed attempt %d to be retried, got %d attempts, queue %v",
+				attempt, item.attempts, m.reconcile.items)
+		}
+		delete(m.reconcile.items, id)
+	}
+	m.reconcileItem(item)
+	if _, ok := m.reconcile.items[id]; ok {
+		t.Errorf("expected reconciliation to be given up after %d attempts", item.attempts)
+	}
+	if len(ctl.updated) != 3 {
+		t.Errorf("expected 3 post-update hook runs, got %d", len(ctl.updated))
+	}
+
+	ctrs[0].UpdateState(cache.ContainerStateExited)
+	ctl.updated = nil
+	m.reconcileItem(&reconcileItem{container: ctrs[0]})
+	if len(ctl.updated) != 0 || len(m.reconcile.items) != 0 {
+		t.Errorf("expected reconciliation of an exited container to be dropped")
+	}
+}
+
+func TestFlushReconcile(t *testing.T) {
+	m, ctrs, cleanup := newReconcileTestResmgr(t, 3)
+	defer cleanup()
+
+	ctl := m.control.(*fakeControl)
+
+	// a queued reconciliation, even if delayed by backoff, is run right away
+	m.queueReconcile(ctrs[0], false)
+	m.queueReconcile(ctrs[1], false)
+	m.reconcile.items[ctrs[0].GetCacheID()].due = time.Now().Add(time.Hour)
+	m.flushReconcile("test", ctrs[0])
+	if len(ctl.updated) != 1 || ctl.updated[0] != ctrs[0].GetCacheID() {
+		t.Errorf("expected %s to be reconciled, got %v", ctrs[0].GetCacheID(), ctl.updated)
+	}
+	if _, ok := m.reconcile.items[ctrs[0].GetCacheID()]; ok {
+		t.Errorf("expected flushed reconciliation to be removed from the queue")
+	}
+	if _, ok := m.reconcile.items[ctrs[1].GetCacheID()]; !ok {
+		t.Errorf("expected reconciliation of other containers to stay queued")
+	}
+
+	// a reconciliation already picked by the worker is run, and not run again by the worker
+	ctl.updated = nil
+	item, _ := m.reconcile.next(time.Now())
+	if item == nil || item.container != ctrs[1] {
+		t.Fatalf("expected %s to be picked for reconciliation, got %v", ctrs[1].GetCacheID(), item)
+	}
+	m.flushReconcile("test", ctrs[1])
+	m.runReconcile(item)
+	if len(ctl.updated) != 1 || ctl.updated[0] != ctrs[1].GetCacheID() {
+		t.Errorf("expected %s to be reconciled once, got %v", ctrs[1].GetCacheID(), ctl.updated)
+	}
+
+	// nothing is run without a queued reconciliation
+	ctl.updated = nil
+	m.flushReconcile("test", ctrs[2])
+	if len(ctl.updated) != 0 {
+		t.Errorf("expected nothing to be reconciled, got %v", ctl.updated)
+	}
+}
@@ -16,9 +16,11 @@ package resmgr
 
 import (
 	"context"
+	"sort"
 
 	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
 	"github.com/intel/cri-resource-manager/pkg/cri/server"
@@ -41,6 +43,11 @@ func (m *resmgr) setupRequestProcessing() error {
 		"RemoveContainer": m.RemoveContainer,
 
 		"UpdateContainerResources": m.UpdateContainer,
+
+		"PullImage": m.PullImage,
+	}
+	for method, fn := range interceptors {
+		interceptors[method] = m.failSafeInterceptor(fn)
 	}
 
 	if err := m.relay.Server().RegisterInterceptors(interceptors); err != nil {
@@ -58,16 +65,24 @@ func (m *resmgr) startRequestProcessing() error {
 	if err != nil {
 		return err
 	}
+	m.setReady(readyCache, true)
 
 	if policy.ActivePolicy() == policy.NullPolicy {
+		m.setReady(readyPolicy, true)
 		return nil
 	}
 
+	if m.rebuild {
+		add = m.rebuildFromCRI(add)
+		m.rebuild = false
+	}
+
 	if err := m.policy.Start(add, del); err != nil {
 		return resmgrError("failed to start policy %s: %v", policy.ActivePolicy(), err)
 	}
+	m.setReady(readyPolicy, true)
 
-	if err := m.runPostReleaseHooks(ctx, "startup"); err != nil {
+	if err := m.queuePostReleaseHooks("startup"); err != nil {
 		m.Error("startup: failed to run post-release hooks: %v", err)
 	}
 
@@ -90,7 +105,7 @@ func (m *resmgr) syncWithCRI(ctx context.Context) ([]cache.Container, []cache.Co
 	}
 	_, _, added, deleted := m.cache.Refresh(pods)
 	for _, c := range added {
-		if c.GetState() != cache.ContainerStateRunning {
+		if !m.isSyncable(c) {
 			m.Info("ignoring discovered container %s (in state %v)...",
 				c.GetID(), c.GetState())
 			continue
@@ -109,7 +124,7 @@ func (m *resmgr) syncWithCRI(ctx context.Context) ([]cache.Container, []cache.Co
 	}
 	_, _, added, deleted = m.cache.Refresh(containers)
 	for _, c := range added {
-		if c.GetState() != cache.ContainerStateRunning {
+		if !m.isSyncable(c) {
 			m.Info("ignoring discovered container %s (in state %v)...",
 				c.GetID(), c.GetState())
 			continue
@@ -125,27 +140,127 @@ func (m *resmgr) syncWithCRI(ctx context.Context) ([]cache.Container, []cache.Co
 	return add, del, nil
 }
 
+// isSyncable checks if a discovered container should be handed to the policy.
+func (m *resmgr) isSyncable(c cache.Container) bool {
+	if c.IsIgnored() {
+		return false
+	}
+	switch c.GetState() {
+	case cache.ContainerStateRunning:
+		return true
+	case cache.ContainerStateCreated:
+		// When rebuilding, created containers have been through CreateContainer
+		// without us knowing about them, so we need to allocate them, too.
+		return m.rebuild
+	}
+	return false
+}
+
+// rebuildFromCRI records the current cpusets of discovered containers and orders
+// them for allocation. It does not restore earlier grants: the policy allocates
+// the containers from scratch, and may well give them different resources.
+func (m *resmgr) rebuildFromCRI(add []cache.Container) []cache.Container {
+	m.Info("rebuilding cache from CRI runtime state (%d containers), "+
+		"previous allocations are not restored...", len(add))
+
+	sizes := make(map[string]int, len(add))
+	for _, c := range add {
+		cpus, mems, err := getContainerCpuset(c.GetID())
+		if err != nil {
+			m.Warn("%s: failed to inspect current cpuset: %v", c.PrettyName(), err)
+			continue
+		}
+
+		m.Info("%s: current cpuset.cpus %q, cpuset.mems %q", c.PrettyName(), cpus, mems)
+		c.SetCpusetCpus(cpus)
+		c.SetCpusetMems(mems)
+		if cset, err := cpuset.Parse(cpus); err == nil {
+			sizes[c.GetCacheID()] = cset.Size()
+		}
+	}
+
+	// Hand containers confined to fewer CPUs (presumably with exclusive
+	// allocations) to the policy first, to give them the best chance of
+	// getting similar allocations back.
+	sort.SliceStable(add, func(i, j int) bool {
+		ci, cj := sizes[add[i].GetCacheID()], sizes[add[j].GetCacheID()]
+		switch {
+		case ci == 0:
+			return false
+		case cj == 0:
+			return true
+		}
+		return ci < cj
+	})
+
+	m.cache.Save()
+
+	return add
+}
+
 // RunPod intercepts CRI requests for Pod creation.
 func (m *resmgr) RunPod(ctx context.Context, method string, request interface{},
 	handler server.Handler) (interface{}, error) {
 
-	reply, rqerr := handler(ctx, request)
-	if rqerr != nil {
-		m.Error("%s: failed to create pod: %v", method, rqerr)
-		return reply, rqerr
+	run := request.(*criapi.RunPodSandboxRequest)
+
+	m.Lock()
+	if reply, retried, err := m.lookupRetriedPod(ctx, run); retried {
+		m.Unlock()
+		return reply, err
 	}
+	inflight := m.startPodRequest(run)
+	m.Unlock()
+
+	reply, rqerr := handler(ctx, filterPodRequest(run))
 
 	m.Lock()
 	defer m.Unlock()
+	defer func() { m.finishPodRequest(run, inflight, reply, rqerr) }()
+
+	if rqerr != nil {
+		m.Error("%s: failed to create pod: %v", method, rqerr)
+		return reply, rqerr
+	}
 
 	podID := reply.(*criapi.RunPodSandboxResponse).PodSandboxId
 	pod := m.cache.InsertPod(podID, request)
 
 	m.Info("created pod %s (%s)", pod.GetName(), podID)
 
+	if err := m.control.RunPostRunPodHooks(pod); err != nil {
+		m.Error("%s: post-run hook failed for pod %s: %v", method, pod.GetName(), err)
+	}
+
 	return reply, nil
 }
 
+// PullImage intercepts CRI requests for pulling images.
+func (m *resmgr) PullImage(ctx context.Context, method string, request interface{},
+	handler server.Handler) (interface{}, error) {
+
+	image := request.(*criapi.PullImageRequest).GetImage().GetImage()
+
+	// don't block other requests for the duration of the pull
+	m.Lock()
+	err := m.control.RunPreImagePullHooks(image)
+	m.Unlock()
+	if err != nil {
+		m.Error("%s: pre-pull hook failed for image %s: %v", method, image, err)
+	}
+
+	reply, rqerr := handler(ctx, request)
+
+	m.Lock()
+	err = m.control.RunPostImagePullHooks(image)
+	m.Unlock()
+	if err != nil {
+		m.Error("%s: post-pull hook failed for image %s: %v", method, image, err)
+	}
+
+	return reply, rqerr
+}
+
 // RemovePod intercepts CRI requests for Pod removal.
 func (m *resmgr) RemovePod(ctx context.Context, method string, request interface{},
 	handler server.Handler) (interface{}, error) {
@@ -174,16 +289,26 @@ func (m *resmgr) RemovePod(ctx context.Context, method string, request interface
 
 	for _, c := range pod.GetInitContainers() {
 		m.Info("%s: removing stale init-container %s...", method, c.PrettyName())
+		if c.IsIgnored() {
+			c.UpdateState(cache.ContainerStateStale)
+			continue
+		}
 		if err := m.policy.ReleaseResources(c); err != nil {
 			m.Warn("%s: failed to release init-container %s: %v", method, c.PrettyName(), err)
 		}
+		m.releaseWorkQueues(c)
 		c.UpdateState(cache.ContainerStateStale)
 	}
 	for _, c := range pod.GetContainers() {
 		m.Info("%s: removing stale container %s...", method, c.PrettyName())
+		if c.IsIgnored() {
+			c.UpdateState(cache.ContainerStateStale)
+			continue
+		}
 		if err := m.policy.ReleaseResources(c); err != nil {
 			m.Warn("%s: failed to release container %s: %v", method, c.PrettyName(), err)
 		}
+		m.releaseWorkQueues(c)
 		c.UpdateState(cache.ContainerStateStale)
 	}
 
@@ -193,6 +318,7 @@ func (m *resmgr) RemovePod(ctx context.Context, method string, request interface
 	}
 
 	m.cache.DeletePod(podID)
+	m.removePodCPUSet(pod)
 
 	return reply, rqerr
 }
@@ -204,6 +330,12 @@ func (m *resmgr) CreateContainer(ctx context.Context, method string, request int
 	m.Lock()
 	defer m.Unlock()
 
+	if c, ok := m.lookupRetriedContainer(request.(*criapi.CreateContainerRequest)); ok {
+		m.Warn("%s: reusing container %s (%s) for retried request",
+			method, c.PrettyName(), c.GetID())
+		return &criapi.CreateContainerResponse{ContainerId: c.GetID()}, nil
+	}
+
 	container, err := m.cache.InsertContainer(request)
 	if err != nil {
 		m.Error("%s: failed to insert new container to cache: %v", method, err)
@@ -211,9 +343,14 @@ func (m *resmgr) CreateContainer(ctx context.Context, method string, request int
 	}
 
 	container.SetCRIRequest(request)
+	m.trackRestart(ctx, container)
 
 	m.Info("%s: creating container %s...", method, container.PrettyName())
 
+	if container.IsIgnored() {
+		return m.createIgnoredContainer(ctx, method, container, request, handler)
+	}
+
 	if err := m.policy.AllocateResources(container); err != nil {
 		m.Error("%s: failed to allocate resources for container %s: %v",
 			method, container.PrettyName(), err)
@@ -221,12 +358,30 @@ func (m *resmgr) CreateContainer(ctx context.Context, method string, request int
 		return nil, resmgrError("failed to allocate container resources: %v", err)
 	}
 
+	if err := m.checkNamespaceQuota(container); err != nil {
+		m.Error("%s: rejecting container %s: %v", method, container.PrettyName(), err)
+		m.policy.ReleaseResources(container)
+		m.runPostReleaseHooks(ctx, method)
+		m.cache.DeleteContainer(container.GetCacheID())
+		return nil, rejected(err)
+	}
+
+	if err := m.assignWorkQueues(container); err != nil {
+		m.Error("%s: rejecting container %s: %v", method, container.PrettyName(), err)
+		m.policy.ReleaseResources(container)
+		m.runPostReleaseHooks(ctx, method)
+		m.cache.DeleteContainer(container.GetCacheID())
+		return nil, rejected(err)
+	}
+
 	container.InsertMount(&cache.Mount{
 		Container:   "/.cri-resmgr",
 		Host:        m.cache.ContainerDirectory(container.GetCacheID()),
 		Readonly:    true,
 		Propagation: cache.MountHostToContainer,
 	})
+	m.mountPodCPUSet(container)
+	m.labelPlacement(container)
 
 	if err := m.runPostAllocateHooks(ctx, method); err != nil {
 		m.Error("%s: failed to run post-allocate hooks for %s: %v",
@@ -238,7 +393,9 @@ func (m *resmgr) CreateContainer(ctx context.Context, method string, request int
 	}
 
 	container.ClearCRIRequest()
-	reply, rqerr := handler(ctx, request)
+	create := filterContainerRequest(request.(*criapi.CreateContainerRequest))
+	container.SetSentLinuxResources(create.GetConfig().GetLinux().GetResources())
+	reply, rqerr := handler(ctx, create)
 
 	if rqerr != nil {
 		m.Error("%s: failed to create container %s: %v", method, container.PrettyName(), rqerr)
@@ -254,6 +411,29 @@ func (m *resmgr) CreateContainer(ctx context.Context, method string, request int
 	return reply, nil
 }
 
+// createIgnoredContainer passes through the creation of a container we leave unmanaged.
+func (m *resmgr) createIgnoredContainer(ctx context.Context, method string, container cache.Container,
+	request interface{}, handler server.Handler) (interface{}, error) {
+	m.Info("%s: container %s is annotated to be ignored, passing request through",
+		method, container.PrettyName())
+
+	container.ClearCRIRequest()
+	create := filterContainerRequest(request.(*criapi.CreateContainerRequest))
+	container.SetSentLinuxResources(create.GetConfig().GetLinux().GetResources())
+	reply, rqerr := handler(ctx, create)
+
+	if rqerr != nil {
+		m.Error("%s: failed to create container %s: %v", method, container.PrettyName(), rqerr)
+		m.cache.DeleteContainer(container.GetCacheID())
+		return nil, rqerr
+	}
+
+	m.cache.UpdateContainerID(container.GetCacheID(), reply)
+	container.UpdateState(cache.ContainerStateCreated)
+
+	return reply, nil
+}
+
 // StartContainer intercepts CRI requests for starting Containers.
 func (m *resmgr) StartContainer(ctx context.Context, method string, request interface{},
 	handler server.Handler) (interface{}, error) {
@@ -279,6 +459,13 @@ func (m *resmgr) StartContainer(ctx context.Context, method string, request inte
 			container.PrettyName(), container.GetState())
 	}
 
+	m.flushReconcile(method, container)
+
+	if err := m.runPreStartHooks(ctx, method, container); err != nil {
+		m.Error("%s: refusing to start container %s: %v", method, container.PrettyName(), err)
+		return nil, resmgrError("refusing to start container %s: %v", container.PrettyName(), err)
+	}
+
 	reply, rqerr := handler(ctx, request)
 
 	if rqerr != nil {
@@ -288,6 +475,10 @@ func (m *resmgr) StartContainer(ctx context.Context, method string, request inte
 
 	container.UpdateState(cache.ContainerStateRunning)
 
+	if container.IsIgnored() {
+		return reply, nil
+	}
+
 	if err := m.runPostStartHooks(ctx, method, container); err != nil {
 		m.Error("%s: failed to run post-start hooks for %s: %v",
 			method, container.PrettyName(), err)
@@ -327,10 +518,16 @@ func (m *resmgr) StopContainer(ctx context.Context, method string, request inter
 	//   For now, we assume any error replies from CRI are about the container not
 	//   being found, in which case we still go ahead and finish locally stopping it...
 
+	if container.IsIgnored() {
+		container.UpdateState(cache.ContainerStateExited)
+		return reply, rqerr
+	}
+
 	if err := m.policy.ReleaseResources(container); err != nil {
 		m.Error("%s: failed to release resources for container %s: %v",
 			method, container.PrettyName(), err)
 	}
+	m.releaseWorkQueues(container)
 
 	if err := m.runPostReleaseHooks(ctx, method); err != nil {
 		m.Error("%s: failed to run post-release hooks for %s: %v",
@@ -339,6 +536,10 @@ func (m *resmgr) StopContainer(ctx context.Context, method string, request inter
 
 	container.UpdateState(cache.ContainerStateExited)
 
+	if opt.RebalanceOnChurn {
+		m.requestRebalance("container stopped")
+	}
+
 	return reply, rqerr
 }
 
@@ -369,10 +570,16 @@ func (m *resmgr) RemoveContainer(ctx context.Context, method string, request int
 		m.Error("%s: failed to remove container %s: %v", method, container.PrettyName(), rqerr)
 	}
 
+	if container.IsIgnored() {
+		m.cache.DeleteContainer(container.GetCacheID())
+		return reply, rqerr
+	}
+
 	if err := m.policy.ReleaseResources(container); err != nil {
 		m.Error("%s: failed to release resources for container %s: %v",
 			method, container.PrettyName(), err)
 	}
+	m.releaseWorkQueues(container)
 
 	if err := m.runPostReleaseHooks(ctx, method); err != nil {
 		m.Error("%s: failed to run post-release hooks for %s: %v",
@@ -385,34 +592,99 @@ func (m *resmgr) RemoveContainer(ctx context.Context, method string, request int
 }
 
 // UpdateContainer intercepts CRI requests for updating Containers.
+//
+// Updates which change the CPU or memory resources of a container, IOW in-place
+// resizes of a pod, for instance by a vertical pod autoscaler, are first reviewed
+// by the policy, which can accept, clamp or reject them. Accepted and clamped ones
+// update the cached resource requirements of the container and reallocate its
+// resources. Other updates are dropped, since we are in control of the resources
+// of the containers we manage.
 func (m *resmgr) UpdateContainer(ctx context.Context, method string, request interface{},
 	handler server.Handler) (interface{}, error) {
 
 	m.Lock()
 	defer m.Unlock()
 
-	containerID := request.(*criapi.UpdateContainerResourcesRequest).ContainerId
-	container, ok := m.cache.LookupContainer(containerID)
+	update := request.(*criapi.UpdateContainerResourcesRequest)
+	container, ok := m.cache.LookupContainer(update.ContainerId)
 
 	if !ok {
 		m.Warn("%s: silently dropping container update request for %s...",
-			method, containerID)
-	} else {
-		m.Warn("%s: silently dropping container update request for %s...",
+			method, update.ContainerId)
+		return &criapi.UpdateContainerResourcesResponse{}, nil
+	}
+
+	if container.IsIgnored() {
+		m.Info("%s: passing through update request for ignored container %s...",
+			method, container.PrettyName())
+		container.SetSentLinuxResources(update.GetLinux())
+		return handler(ctx, request)
+	}
+
+	if err := m.reviewResourceUpdate(method, container, update); err != nil {
+		return nil, rejected(err)
+	}
+
+	snapshot := container.SnapshotResources()
+	if !container.UpdateResources(update.GetLinux()) {
+		m.Info("%s: no resource changes for %s, dropping update request...",
 			method, container.PrettyName())
-		m.Warn("%s: XXX TODO: we probably should reallocate the container instead...",
-			method)
+		return &criapi.UpdateContainerResourcesResponse{}, nil
+	}
+
+	m.Info("%s: resizing container %s...", method, container.PrettyName())
+
+	if err := m.policy.UpdateResources(container); err != nil {
+		m.Error("%s: failed to reallocate resources for %s: %v",
+			method, container.PrettyName(), err)
+		m.undoResourceUpdate(method, container, snapshot)
+		return nil, resmgrError("failed to reallocate resources for %s: %v",
+			container.PrettyName(), err)
+	}
+
+	if err := container.SetCRIRequest(update); err != nil {
+		m.Warn("%s: failed to set pending update request for %s: %v",
+			method, container.PrettyName(), err)
+	}
+
+	if err := m.runPostUpdateHooks(ctx, method); err != nil {
+		m.Error("%s: failed to run post-update hooks for %s: %v",
+			method, container.PrettyName(), err)
+		return nil, resmgrError("failed to run post-update hooks for %s: %v",
+			container.PrettyName(), err)
 	}
 
+	m.cache.Save()
+
 	return &criapi.UpdateContainerResourcesResponse{}, nil
 }
 
+// undoResourceUpdate restores the resources of a container after a failed update.
+//
+// The policy releases the resources of a container before reallocating them for
+// an update, so after a failed update we reallocate the original resources.
+func (m *resmgr) undoResourceUpdate(method string, c cache.Container, snapshot *cache.ResourceSnapshot) {
+	c.RestoreResources(snapshot)
+	if err := m.policy.UpdateResources(c); err != nil {
+		m.Error("%s: failed to restore resources of %s: %v", method, c.PrettyName(), err)
+		return
+	}
+	m.Info("%s: restored original resources of %s", method, c.PrettyName())
+	m.cache.Save()
+}
+
 // RebalanceContainers tries to find a more optimal container resource allocation if necessary.
 func (m *resmgr) RebalanceContainers() error {
 	m.Lock()
 	defer m.Unlock()
 
+	if m.failsafe.isTripped() {
+		m.Warn("rebalancing skipped, policy processing is disengaged")
+		return nil
+	}
+
 	m.Info("rebalancing (reallocating) containers...")
+	m.markRebalanced()
 
 	method := "Rebalance"
 	changes := false
@@ -429,10 +701,16 @@ func (m *resmgr) RebalanceContainers() error {
 	}
 
 	if changes {
-		if err := m.runPostUpdateHooks(context.Background(), method); err != nil {
+		batch, deferred := m.rebalanceBatch(m.cache.GetPendingContainers())
+		if err := m.queuePostUpdateHooksFor(method, batch); err != nil {
 			m.Error("%s: failed to run post-update hooks: %v", method, err)
 			return resmgrError("%s: failed to run post-update hooks: %v", method, err)
 		}
+		if len(deferred) > 0 {
+			m.Info("%s: deferring updates of %d containers to the next pass",
+				method, len(deferred))
+			m.requestRebalance("deferred updates")
+		}
 	}
 
 	m.cache.Save()
@@ -456,19 +734,39 @@ func (m *resmgr) runPostAllocateHooks(ctx context.Context, method string) error
 			}
 			m.policy.ExportResourceData(c)
 		case cache.ContainerStateCreating:
+			// export first, so any injected environment ends up in the request
+			m.policy.ExportResourceData(c)
 			if err := m.control.RunPreCreateHooks(c); err != nil {
 				m.Warn("%s pre-create hook failed for %s: %v",
 					method, c.PrettyName(), err)
 			}
-			m.policy.ExportResourceData(c)
 		default:
 			m.Warn("%s: skipping container %s (in state %v)", method,
 				c.PrettyName(), c.GetState())
 		}
 	}
+	m.publishAssignments()
+	m.updatePlacementMetrics()
+	m.exportPodCPUSets()
+	m.emitPodEvents()
+	m.exportNodeTopology()
 	return nil
 }
 
+// runPreStartHooks runs the necessary hooks before starting a container.
+//
+// Pre-start hook failures are only logged, but failing fence hooks prevent
+// starting the container with partially applied resource assignments.
+func (m *resmgr) runPreStartHooks(ctx context.Context, method string, c cache.Container) error {
+	if c.IsIgnored() {
+		return nil
+	}
+	if err := m.control.RunPreStartHooks(c); err != nil {
+		m.Error("%s: pre-start hook failed for %s: %v", method, c.PrettyName(), err)
+	}
+	return m.control.RunFenceStartHooks(c)
+}
+
 // runPostStartHooks runs the necessary hooks after having started a container.
 func (m *resmgr) runPostStartHooks(ctx context.Context, method string, c cache.Container) error {
 	if err := m.control.RunPostStartHooks(c); err != nil {
@@ -501,12 +799,22 @@ func (m *resmgr) runPostReleaseHooks(ctx context.Context, method string) error {
 				method, c.PrettyName(), c.GetState())
 		}
 	}
+	m.publishAssignments()
+	m.updatePlacementMetrics()
+	m.exportPodCPUSets()
+	m.emitPodEvents()
+	m.exportNodeTopology()
 	return nil
 }
 
 // runPostUpdateHooks runs the necessary hooks after reconcilation.
 func (m *resmgr) runPostUpdateHooks(ctx context.Context, method string) error {
-	for _, c := range m.cache.GetPendingContainers() {
+	return m.runPostUpdateHooksFor(ctx, method, m.cache.GetPendingContainers())
+}
+
+// runPostUpdateHooksFor runs the necessary hooks after reconcilation for the given containers.
+func (m *resmgr) runPostUpdateHooksFor(ctx context.Context, method string, containers []cache.Container) error {
+	for _, c := range containers {
 		switch c.GetState() {
 		case cache.ContainerStateRunning, cache.ContainerStateCreated:
 			if err := m.control.RunPostUpdateHooks(c); err != nil {
@@ -526,6 +834,11 @@ func (m *resmgr) runPostUpdateHooks(ctx context.Context, method string) error {
 				c.PrettyName(), c.GetState())
 		}
 	}
+	m.publishAssignments()
+	m.updatePlacementMetrics()
+	m.exportPodCPUSets()
+	m.emitPodEvents()
+	m.exportNodeTopology()
 	return nil
 }
 
@@ -536,6 +849,9 @@ func (m *resmgr) sendCRIRequest(ctx context.Context, request interface{}) (inter
 	case *criapi.UpdateContainerResourcesRequest:
 		req := request.(*criapi.UpdateContainerResourcesRequest)
 		m.Debug("sending update request for container %s...", req.ContainerId)
+		if c, ok := m.cache.LookupContainer(req.ContainerId); ok {
+			c.SetSentLinuxResources(req.GetLinux())
+		}
 		return client.UpdateContainerResources(ctx, req)
 	default:
 		return nil, resmgrError("sendCRIRequest: unhandled request type %T", request)
@@ -0,0 +1,176 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/client"
+	"github.com/intel/cri-resource-manager/pkg/cri/relay"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+)
+
+// fakeClient is a CRI client listing a fixed set of pods and containers.
+type fakeClient struct {
+	client.Client
+	pods       []*criapi.PodSandbox
+	containers []*criapi.Container
+}
+
+func (c *fakeClient) HasRuntimeService() bool { return true }
+
+func (c *fakeClient) ListPodSandbox(context.Context, *criapi.ListPodSandboxRequest,
+	...grpc.CallOption) (*criapi.ListPodSandboxResponse, error) {
+	return &criapi.ListPodSandboxResponse{Items: c.pods}, nil
+}
+
+func (c *fakeClient) ListContainers(context.Context, *criapi.ListContainersRequest,
+	...grpc.CallOption) (*criapi.ListContainersResponse, error) {
+	return &criapi.ListContainersResponse{Containers: c.containers}, nil
+}
+
+// fakeRelay is a CRI relay with only a client.
+type fakeRelay struct {
+	relay.Relay
+	client client.Client
+}
+
+func (r *fakeRelay) Client() client.Client { return r.client }
+
+// listedPod returns a pod as listed by the CRI runtime.
+func listedPod(id string) *criapi.PodSandbox {
+	return &criapi.PodSandbox{
+		Id:       id,
+		Metadata: &criapi.PodSandboxMetadata{Name: id, Uid: id + "-uid", Namespace: "default"},
+		State:    criapi.PodSandboxState_SANDBOX_READY,
+	}
+}
+
+// listedContainer returns a container as listed by the CRI runtime.
+func listedContainer(id, pod string, state criapi.ContainerState) *criapi.Container {
+	return &criapi.Container{
+		Id:           id,
+		PodSandboxId: pod,
+		Metadata:     &criapi.ContainerMetadata{Name: id},
+		State:        state,
+	}
+}
+
+// containerIDs returns the sorted IDs of the given containers.
+func containerIDs(containers []cache.Container) string {
+	ids := []string{}
+	for _, c := range containers {
+		ids = append(ids, c.GetID())
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestRebuildFromCRI(t *testing.T) {
+	m, cfg, cleanup := newExitsTestResmgr(t)
+	defer cleanup()
+
+	// cached state: a pod gone from the runtime, a container gone from a live
+	// pod, and a container still known to the runtime
+	m.cache.InsertPod("stale-pod", &criapi.RunPodSandboxRequest{
+		Config: &criapi.PodSandboxConfig{
+			Metadata: &criapi.PodSandboxMetadata{Name: "stale", Uid: "stale-uid", Namespace: "default"},
+		},
+	})
+	for _, c := range []struct{ pod, id string }{
+		{"stale-pod", "stale-ctr"},
+		{"pod-id", "missing-ctr"},
+		{"pod-id", "kept-ctr"},
+	} {
+		ctr, err := m.cache.InsertContainer(&criapi.CreateContainerRequest{
+			PodSandboxId:  c.pod,
+			Config:        &criapi.ContainerConfig{Metadata: &criapi.ContainerMetadata{Name: c.id}},
+			SandboxConfig: cfg,
+		})
+		if err != nil {
+			t.Fatalf("failed to create container %s: %v", c.id, err)
+		}
+		if _, err := m.cache.UpdateContainerID(ctr.GetCacheID(),
+			&criapi.CreateContainerResponse{ContainerId: c.id}); err != nil {
+			t.Fatalf("failed to set ID of container %s: %v", c.id, err)
+		}
+		ctr.UpdateState(cache.ContainerStateRunning)
+	}
+
+	m.relay = &fakeRelay{
+		client: &fakeClient{
+			pods: []*criapi.PodSandbox{listedPod("pod-id"), listedPod("new-pod")},
+			containers: []*criapi.Container{
+				listedContainer("kept-ctr", "pod-id", criapi.ContainerState_CONTAINER_RUNNING),
+				listedContainer("new-wide", "new-pod", criapi.ContainerState_CONTAINER_RUNNING),
+				listedContainer("new-narrow", "new-pod", criapi.ContainerState_CONTAINER_RUNNING),
+				listedContainer("new-created", "new-pod", criapi.ContainerState_CONTAINER_CREATED),
+				listedContainer("new-unknown", "new-pod", criapi.ContainerState_CONTAINER_UNKNOWN),
+				listedContainer("new-exited", "new-pod", criapi.ContainerState_CONTAINER_EXITED),
+			},
+		},
+	}
+
+	cpusets := map[string]string{
+		"new-wide":   "0-7",
+		"new-narrow": "2-3",
+	}
+	saved := getContainerCpuset
+	defer func() { getContainerCpuset = saved }()
+	getContainerCpuset = func(id string) (string, string, error) {
+		cpus, ok := cpusets[id]
+		if !ok {
+			return "", "", fmt.Errorf("no cpuset cgroup for %s", id)
+		}
+		return cpus, "0", nil
+	}
+
+	m.rebuild = true
+	add, del, err := m.syncWithCRI(context.Background())
+	if err != nil {
+		t.Fatalf("failed to synchronize with CRI: %v", err)
+	}
+	if ids := containerIDs(add); ids != "new-created,new-narrow,new-wide" {
+		t.Errorf("expected new containers new-created,new-narrow,new-wide, got %s", ids)
+	}
+	if ids := containerIDs(del); ids != "missing-ctr,stale-ctr" {
+		t.Errorf("expected stale containers missing-ctr,stale-ctr, got %s", ids)
+	}
+	if _, ok := m.cache.LookupPod("stale-pod"); ok {
+		t.Errorf("expected stale pod to be purged from the cache")
+	}
+
+	add = m.rebuildFromCRI(add)
+	order := []string{}
+	for _, c := range add {
+		order = append(order, c.GetID())
+	}
+	// containers are ordered by the size of their cpuset, unknown ones last
+	if ids := strings.Join(order, ","); ids != "new-narrow,new-wide,new-created" {
+		t.Errorf("expected containers in order new-narrow,new-wide,new-created, got %s", ids)
+	}
+	for _, c := range add {
+		if cpus := c.GetCpusetCpus(); cpus != cpusets[c.GetID()] {
+			t.Errorf("expected %s to have cpuset %q, got %q", c.GetID(), cpusets[c.GetID()], cpus)
+		}
+	}
+}
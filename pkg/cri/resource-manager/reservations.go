@@ -0,0 +1,54 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/reserve"
+)
+
+// setupReservationServer sets up our resource reservation server, if enabled.
+func (m *resmgr) setupReservationServer() error {
+	var err error
+
+	if opt.ReservationAddress == "" {
+		return nil
+	}
+
+	if m.reservations, err = reserve.NewReservationServer(m.reserveResources, m.releaseReservation); err != nil {
+		return resmgrError("failed to create resource reservation server: %v", err)
+	}
+
+	return nil
+}
+
+// reserveResources reserves resources for a pod on behalf of an external scheduler.
+func (m *resmgr) reserveResources(r *policy.Reservation) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.Info("reserving %d CPUs and %d bytes of memory for pod %s until %s",
+		r.CPUs, r.Memory, r.PodUID, r.Expires)
+
+	return m.policy.ReserveResources(r)
+}
+
+// releaseReservation releases any resources reserved for a pod.
+func (m *resmgr) releaseReservation(uid string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.policy.ReleaseReservation(uid)
+}
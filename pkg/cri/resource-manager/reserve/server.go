@@ -0,0 +1,176 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reserve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/reserve/api/v1"
+	"github.com/intel/cri-resource-manager/pkg/log"
+)
+
+const (
+	// DefaultTTL is the time to live of reservations which don't specify one.
+	DefaultTTL = time.Minute
+	// unixPrefix is the prefix of unix domain socket addresses.
+	unixPrefix = "unix://"
+)
+
+// ReserveCb is a callback function for Reserve requests.
+type ReserveCb func(*policy.Reservation) error
+
+// ReleaseCb is a callback function for Release requests.
+type ReleaseCb func(string) error
+
+// Server is the interface for our gRPC server.
+type Server interface {
+	Start(string) error
+	Stop()
+}
+
+// server implements Server.
+type server struct {
+	log.Logger
+	sync.Mutex
+	server    *grpc.Server // gRPC server instance
+	reserveCb ReserveCb
+	releaseCb ReleaseCb
+}
+
+// NewReservationServer creates new Server instance.
+func NewReservationServer(reserveCb ReserveCb, releaseCb ReleaseCb) (Server, error) {
+	s := &server{
+		Logger:    log.NewLogger("reservation-server"),
+		reserveCb: reserveCb,
+		releaseCb: releaseCb,
+	}
+	return s, nil
+}
+
+// Start runs server instance.
+//
+// The address is a unix domain socket, given as an absolute path or with a
+// unix:// prefix. The server can reserve any free CPUs of the node, so it is
+// not served over the network, and the socket is only accessible by its owner.
+func (s *server) Start(address string) error {
+	if !strings.HasPrefix(address, unixPrefix) && !filepath.IsAbs(address) {
+		return serverError("invalid address %q, not a unix domain socket", address)
+	}
+	address = strings.TrimPrefix(address, unixPrefix)
+
+	if err := os.MkdirAll(filepath.Dir(address), 0700); err != nil {
+		return serverError("failed to create directory for socket %s: %v",
+			address, err)
+	}
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return serverError("failed to unlink socket file: %s", err)
+	}
+
+	lis, err := net.Listen("unix", address)
+	if err != nil {
+		return serverError("failed to listen to socket %s: %v", address, err)
+	}
+	if err := os.Chmod(address, 0600); err != nil {
+		lis.Close()
+		return serverError("failed to set permissions of socket %s: %v", address, err)
+	}
+
+	srv := grpc.NewServer()
+	v1.RegisterReservationServer(srv, s)
+
+	s.Lock()
+	s.server = srv
+	s.Unlock()
+
+	s.Info("starting reservation-server at socket %s...", address)
+	go func() {
+		defer lis.Close()
+		// Serve fails with ErrServerStopped if we were stopped before serving.
+		err := srv.Serve(lis)
+		if err != nil && err != grpc.ErrServerStopped {
+			s.Fatal("reservation-server died: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Stop Server instance
+func (s *server) Stop() {
+	s.Lock()
+	srv := s.server
+	s.server = nil
+	s.Unlock()
+
+	if srv != nil {
+		srv.Stop()
+	}
+}
+
+// Reserve reserves resources for a pod ahead of its creation.
+func (s *server) Reserve(ctx context.Context, req *v1.ReserveRequest) (*v1.ReserveReply, error) {
+	s.Debug("REQUEST: %s", req)
+
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	r := &policy.Reservation{
+		PodUID:  req.PodUid,
+		CPUs:    int(req.Cpus),
+		Memory:  req.Memory,
+		Expires: time.Now().Add(ttl),
+	}
+
+	reply := &v1.ReserveReply{}
+	if err := s.reserveCb(r); err != nil {
+		reply.Error = fmt.Sprintf("failed to reserve resources: %v", err)
+		return reply, nil
+	}
+
+	reply.Pool = r.Pool
+	reply.Cpus = r.Cpuset.String()
+	reply.Mems = r.Mems
+
+	return reply, nil
+}
+
+// Release releases any resources reserved for a pod.
+func (s *server) Release(ctx context.Context, req *v1.ReleaseRequest) (*v1.ReleaseReply, error) {
+	s.Debug("REQUEST: %s", req)
+
+	reply := &v1.ReleaseReply{}
+	if err := s.releaseCb(req.PodUid); err != nil {
+		reply.Error = fmt.Sprintf("failed to release reservation: %v", err)
+	}
+
+	return reply, nil
+}
+
+func serverError(format string, args ...interface{}) error {
+	return fmt.Errorf(format, args...)
+}
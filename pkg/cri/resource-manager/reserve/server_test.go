@@ -0,0 +1,187 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reserve
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/reserve/api/v1"
+)
+
+func TestStartAddress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reserve-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tcases := []struct {
+		name    string
+		address string
+		invalid bool
+	}{
+		{
+			name:    "TCP address refused",
+			address: "127.0.0.1:0",
+			invalid: true,
+		},
+		{
+			name:    "port-only TCP address refused",
+			address: ":8891",
+			invalid: true,
+		},
+		{
+			name:    "relative path refused",
+			address: "reserve.sock",
+			invalid: true,
+		},
+		{
+			name:    "socket path",
+			address: filepath.Join(dir, "sub", "reserve.sock"),
+		},
+		{
+			name:    "unix:// socket address",
+			address: "unix://" + filepath.Join(dir, "reserve.sock"),
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := NewReservationServer(nil, nil)
+			err := s.Start(tc.address)
+			if tc.invalid {
+				if err == nil {
+					s.Stop()
+					t.Errorf("expected address %q to be refused", tc.address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to start server at %q: %v", tc.address, err)
+			}
+			defer s.Stop()
+
+			info, err := os.Stat(strings.TrimPrefix(tc.address, unixPrefix))
+			if err != nil {
+				t.Fatalf("failed to stat socket: %v", err)
+			}
+			if info.Mode()&os.ModeSocket == 0 {
+				t.Errorf("expected a socket at %q, got mode %v", tc.address, info.Mode())
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Errorf("expected socket permissions 0600, got %#o", perm)
+			}
+		})
+	}
+}
+
+func TestReserveRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reserve-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "reserve.sock")
+
+	reserved := map[string]*policy.Reservation{}
+	released := []string{}
+	reserve := func(r *policy.Reservation) error {
+		if r.CPUs > 4 {
+			return fmt.Errorf("not enough free CPUs")
+		}
+		r.Pool = "numa node #0"
+		r.Cpuset = cpuset.NewCPUSet(2, 3)
+		r.Mems = "0"
+		reserved[r.PodUID] = r
+		return nil
+	}
+	release := func(uid string) error {
+		released = append(released, uid)
+		return nil
+	}
+
+	s, _ := NewReservationServer(reserve, release)
+	if err := s.Start(socket); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer s.Stop()
+
+	conn, err := grpc.Dial(socket, grpc.WithInsecure(),
+		grpc.WithDialer(func(sock string, timeout time.Duration) (net.Conn, error) {
+			return net.Dial("unix", sock)
+		}))
+	if err != nil {
+		t.Fatalf("failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	client := v1.NewReservationClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	before := time.Now()
+	reply, err := client.Reserve(ctx, &v1.ReserveRequest{PodUid: "pod1", Cpus: 2, Memory: 1 << 30})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if reply.Error != "" || reply.Pool != "numa node #0" || reply.Cpus != "2-3" || reply.Mems != "0" {
+		t.Errorf("unexpected reply %+v", reply)
+	}
+	r, ok := reserved["pod1"]
+	if !ok {
+		t.Fatalf("expected reservation for pod1")
+	}
+	if r.CPUs != 2 || r.Memory != 1<<30 {
+		t.Errorf("expected 2 CPUs and 1G of memory reserved, got %d CPUs, %d bytes", r.CPUs, r.Memory)
+	}
+	if r.Expires.Before(before.Add(DefaultTTL)) || r.Expires.After(time.Now().Add(DefaultTTL)) {
+		t.Errorf("expected default TTL %v, got expiry %v", DefaultTTL, r.Expires)
+	}
+
+	_, err = client.Reserve(ctx, &v1.ReserveRequest{PodUid: "pod2", Cpus: 1, TtlSeconds: 3600})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if r := reserved["pod2"]; r == nil || r.Expires.Before(before.Add(time.Hour)) {
+		t.Errorf("expected reservation of pod2 with a TTL of 1h, got %+v", r)
+	}
+
+	reply, err = client.Reserve(ctx, &v1.ReserveRequest{PodUid: "pod3", Cpus: 8})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if reply.Error == "" {
+		t.Errorf("expected reservation of 8 CPUs to fail")
+	}
+
+	if _, err := client.Release(ctx, &v1.ReleaseRequest{PodUid: "pod1"}); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if len(released) != 1 || released[0] != "pod1" {
+		t.Errorf("expected reservation of pod1 released, got %v", released)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"net/http"
+
+	cri "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+)
+
+// ResourceDiffPath is the HTTP path for inspecting how containers' Linux resources were modified.
+const ResourceDiffPath = "/resource-diffs"
+
+// resourceDiff is the inspectable Linux resource modification info of a container.
+type resourceDiff struct {
+	// Requested are the Linux resources as requested by the kubelet.
+	Requested *cri.LinuxContainerResources `json:"requested,omitempty"`
+	// Sent are the Linux resources last sent to the runtime.
+	Sent *cri.LinuxContainerResources `json:"sent,omitempty"`
+	// Diffs are the fields which differ between the requested and sent resources.
+	Diffs []cache.ResourceDiff `json:"diffs,omitempty"`
+}
+
+// setupResourceDiffEndpoint sets up the HTTP endpoint for inspecting Linux resource modifications.
+func (m *resmgr) setupResourceDiffEndpoint() error {
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, resource diff inspection disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, ResourceDiffPath, http.HandlerFunc(m.serveResourceDiffs))
+
+	return nil
+}
+
+// serveResourceDiffs serves the requested and sent Linux resources of all containers, by container.
+func (m *resmgr) serveResourceDiffs(w http.ResponseWriter, req *http.Request) {
+	m.Lock()
+	data := map[string]*resourceDiff{}
+	for _, c := range m.cache.GetContainers() {
+		data[c.PrettyName()] = &resourceDiff{
+			Requested: c.GetRequestedLinuxResources(),
+			Sent:      c.GetSentLinuxResources(),
+			Diffs:     c.GetLinuxResourceDiffs(),
+		}
+	}
+	m.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		m.Error("failed to serve resource diffs: %v", err)
+	}
+}
@@ -15,6 +15,8 @@
 package resmgr
 
 import (
+	"context"
+	"os"
 	"sync"
 	"time"
 
@@ -26,6 +28,7 @@ import (
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/control"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/metrics"
 	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/reserve"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 )
 
@@ -49,12 +52,28 @@ type resmgr struct {
 	cache        cache.Cache       // cached state
 	policy       policy.Policy     // resource manager policy
 	configServer config.Server     // configuration management server
+	reservations reserve.Server    // resource reservation server for schedulers
 	control      control.Control   // policy controllers/enforcement
 	agent        agent.Interface   // connection to cri-resmgr agent
 	conf         *config.RawConfig // pending for saving in cache
 	metrics      *metrics.Metrics  // metrics collector/pre-processor
 	events       chan interface{}  // channel for delivering events
 	stop         chan interface{}  // channel for signalling shutdown to goroutines
+	rebuild      bool              // cache needs to be rebuilt from the runtime
+	stream       *assignmentStream // streaming of container assignment updates
+	eventState   eventStates       // last assignments pod events were emitted for
+	rebalance    rebalancer        // rebalancing rate-limiting and batching
+	reconcile    reconcileQueue    // prioritized queue of container reconciliations
+	podCPUSets   map[string]string // last exported pod cpusets, by directory
+	topology     nodeTopology      // last exported node resource topology
+	failsafe     failSafe          // circuit breaker for policy failures
+	noisy        noisyStates       // noisy neighbor detection state
+	pressure     pressureStates    // pool pressure detection state
+	burst        burstStates       // CPU burst detection state
+	isolation    isolationState    // exclusive CPU isolation enforcement state
+	readiness    readiness         // readiness to serve requests
+	runPods      podRequests       // pod sandbox creations in progress
+	leader       *os.File          // leader lock, held while we're active
 }
 
 // NewResourceManager creates a new ResourceManager instance.
@@ -85,6 +104,10 @@ func NewResourceManager() (ResourceManager, error) {
 		return nil, err
 	}
 
+	if err := m.setupReservationServer(); err != nil {
+		return nil, err
+	}
+
 	if err := m.setupRelay(); err != nil {
 		return nil, err
 	}
@@ -101,6 +124,42 @@ func NewResourceManager() (ResourceManager, error) {
 		return nil, err
 	}
 
+	if err := m.setupAssignmentStream(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupPolicyDataEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupResourceDiffEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupLoggerEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupJanitorEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupIsolationEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupConsistencyEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupReadinessEndpoint(); err != nil {
+		return nil, err
+	}
+
+	if err := m.setupPolicyStateEndpoint(); err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
@@ -108,6 +167,10 @@ func NewResourceManager() (ResourceManager, error) {
 func (m *resmgr) Start() error {
 	m.Info("starting...")
 
+	if err := m.acquireLeadership(); err != nil {
+		return err
+	}
+
 	m.Lock()
 	defer m.Unlock()
 
@@ -115,6 +178,8 @@ func (m *resmgr) Start() error {
 		return err
 	}
 
+	m.startIsolation()
+
 	if err := m.startRequestProcessing(); err != nil {
 		return err
 	}
@@ -126,6 +191,8 @@ func (m *resmgr) Start() error {
 	if err := m.relay.Start(); err != nil {
 		return resmgrError("failed to start CRI relay: %v", err)
 	}
+	// relay setup waited for the runtime, so it was reachable a moment ago
+	m.setReady(readyRuntime, true)
 
 	if opt.ForceConfig == "" {
 		if err := m.configServer.Start(opt.ConfigSocket); err != nil {
@@ -142,6 +209,12 @@ func (m *resmgr) Start() error {
 		}
 	}
 
+	if m.reservations != nil {
+		if err := m.reservations.Start(opt.ReservationAddress); err != nil {
+			return resmgrError("failed to start reservation server: %v", err)
+		}
+	}
+
 	m.Info("up and running")
 
 	return nil
@@ -154,9 +227,25 @@ func (m *resmgr) Stop() {
 	m.Lock()
 	defer m.Unlock()
 
+	m.setReady(readyPolicy, false)
 	m.configServer.Stop()
+	if m.reservations != nil {
+		m.reservations.Stop()
+	}
 	m.relay.Stop()
 	m.stopEventProcessing()
+	m.topology.shutdown()
+	if m.policy != nil {
+		m.policy.Stop()
+	}
+	m.control.StopControllers()
+	m.stopIsolation()
+
+	if err := m.cache.Flush(); err != nil {
+		m.Error("failed to flush cache: %v", err)
+	}
+
+	m.releaseLeadership()
 }
 
 // SetConfig pushes new configuration to the resource manager.
@@ -178,19 +267,103 @@ func (m *resmgr) SetConfig(conf *config.RawConfig) error {
 		return resmgrError("failed to fully activate configuration: %v", err)
 	}
 
+	// apply any container changes policies made to adapt to the configuration
+	if err := m.runPostUpdateHooks(context.Background(), "SetConfig"); err != nil {
+		m.Error("failed to update containers for new configuration: %v", err)
+	}
+
+	// a configuration update is the operator's cue to re-engage a tripped policy
+	if err := m.reengagePolicy(context.Background()); err != nil {
+		m.Error("failed to re-engage policy: %v", err)
+	}
+
 	m.cache.SetConfig(conf)
 	m.Info("successfully switched to new configuration")
 
 	return nil
 }
 
+// UpdatePodResources updates the resource requirements of pods pushed by the agent.
+//
+// The resources of existing containers with changed requirements are reallocated.
+func (m *resmgr) UpdatePodResources(resources map[string]string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	pods := make(map[string]*cache.PodResourceRequirements, len(resources))
+	for uid, value := range resources {
+		r := &cache.PodResourceRequirements{}
+		if err := cache.DecodeAnnotationStrict([]byte(value), r); err != nil {
+			m.Error("ignoring invalid resources of pod %s: %v", uid, err)
+			continue
+		}
+		pods[uid] = r
+	}
+
+	method := "UpdatePodResources"
+	snapshots := map[string]*cache.ResourceSnapshot{}
+	for _, c := range m.cache.GetContainers() {
+		snapshots[c.GetCacheID()] = c.SnapshotResources()
+	}
+
+	changed := m.cache.SetPodResources(pods)
+	m.Debug("updated resources of %d pods from agent", len(pods))
+
+	if len(changed) > 0 && m.failsafe.isTripped() {
+		m.Warn("%s: reallocation skipped, policy processing is disengaged", method)
+		return nil
+	}
+
+	for _, c := range changed {
+		switch c.GetState() {
+		case cache.ContainerStateExited, cache.ContainerStateStale:
+			continue
+		}
+		if c.IsIgnored() {
+			continue
+		}
+		m.Info("%s: reallocating resources of %s", method, c.PrettyName())
+		if err := m.policy.UpdateResources(c); err != nil {
+			m.Error("%s: failed to reallocate resources for %s: %v",
+				method, c.PrettyName(), err)
+			m.undoResourceUpdate(method, c, snapshots[c.GetCacheID()])
+		}
+	}
+
+	if len(changed) > 0 {
+		if err := m.runPostUpdateHooks(context.Background(), method); err != nil {
+			m.Error("%s: failed to run post-update hooks: %v", method, err)
+			return resmgrError("%s: failed to run post-update hooks: %v", method, err)
+		}
+		m.cache.Save()
+	}
+
+	return nil
+}
+
 // setupCache creates a cache and reloads its last saved state if found.
 func (m *resmgr) setupCache() error {
 	var err error
 
-	options := cache.Options{CacheDir: opt.RelayDir}
+	options := cache.Options{CacheDir: opt.RelayDir, WriteBehind: opt.CacheWriteBehind}
 	if m.cache, err = cache.NewCache(options); err != nil {
-		return resmgrError("failed to create cache: %v", err)
+		m.Error("failed to load cache: %v", err)
+
+		discarded, derr := cache.DiscardCache(options)
+		if derr != nil {
+			return resmgrError("failed to create cache: %v", err)
+		}
+		m.Warn("discarded unusable cache (saved as %s), rebuilding from runtime...",
+			discarded)
+
+		if m.cache, err = cache.NewCache(options); err != nil {
+			return resmgrError("failed to create cache: %v", err)
+		}
+	}
+
+	if len(m.cache.GetPods()) == 0 && len(m.cache.GetContainers()) == 0 {
+		m.Info("no cached state, will rebuild cache from CRI runtime...")
+		m.rebuild = true
 	}
 
 	return nil
@@ -212,7 +385,7 @@ func (m *resmgr) setupConfigAgent() error {
 func (m *resmgr) setupConfigServer() error {
 	var err error
 
-	if m.configServer, err = config.NewConfigServer(m.SetConfig); err != nil {
+	if m.configServer, err = config.NewConfigServer(m.SetConfig, m.UpdatePodResources); err != nil {
 		return resmgrError("failed to create configuration notification server: %v", err)
 	}
 
@@ -226,6 +399,14 @@ func (m *resmgr) checkOpts() error {
 			opt.FallbackConfig, opt.ForceConfig)
 	}
 
+	if err := checkAnnotationPatterns(); err != nil {
+		return err
+	}
+
+	if err := checkIsolationMethod(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -293,6 +474,24 @@ func (m *resmgr) loadConfig() error {
 func (m *resmgr) setupPolicy() error {
 	var err error
 
+	if err = m.checkActivePolicy(); err != nil {
+		return err
+	}
+
+	active := policy.ActivePolicy()
+	options := &policy.Options{AgentCli: m.agent}
+	if opt.StatsInterval > 0 {
+		options.Stats = containerStats
+	}
+	if m.policy, err = policy.NewPolicy(m.cache, options); err != nil {
+		return resmgrError("failed to create policy %s: %v", active, err)
+	}
+
+	return nil
+}
+
+// checkActivePolicy checks that the cache is usable with the active policy.
+func (m *resmgr) checkActivePolicy() error {
 	active := policy.ActivePolicy()
 	cached := m.cache.GetActivePolicy()
 
@@ -304,11 +503,6 @@ func (m *resmgr) setupPolicy() error {
 		m.cache.SetActivePolicy(active)
 	}
 
-	options := &policy.Options{AgentCli: m.agent}
-	if m.policy, err = policy.NewPolicy(m.cache, options); err != nil {
-		return resmgrError("failed to create policy %s: %v", active, err)
-	}
-
 	return nil
 }
 
@@ -317,9 +511,19 @@ func (m *resmgr) setupRelay() error {
 	var err error
 
 	options := relay.Options{
-		RelaySocket:   opt.RelaySocket,
-		ImageSocket:   opt.ImageSocket,
-		RuntimeSocket: opt.RuntimeSocket,
+		RelaySocket:              opt.RelaySocket,
+		ImageSocket:              opt.ImageSocket,
+		RuntimeSocket:            opt.RuntimeSocket,
+		SecondaryRuntimeSocket:   opt.SecondaryRuntimeSocket,
+		SecondaryRuntimeHandlers: opt.SecondaryRuntimeHandlers,
+		HealthCheckInterval:      opt.RuntimeHealthInterval,
+		ReconnectMaxDelay:        opt.RuntimeReconnectMaxDelay,
+		WaitForRuntime:           opt.RuntimeWaitForReady,
+		HealthNotify: func(e *relay.HealthEvent) {
+			if err := m.SendEvent(e); err != nil {
+				m.Error("%v", err)
+			}
+		},
 	}
 	if m.relay, err = relay.NewRelay(options); err != nil {
 		return resmgrError("failed to create CRI relay: %v", err)
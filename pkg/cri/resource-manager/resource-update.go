@@ -0,0 +1,91 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	core_v1 "k8s.io/api/core/v1"
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/agent"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/policy"
+)
+
+// reviewResourceUpdate lets the policy accept, clamp or reject an in-place resource update.
+//
+// Only updates which change the CPU or memory resources of the container are
+// reviewed, others, like cpuset-only updates, are left alone. A clamped update
+// is rewritten with the clamped resources. For a rejected one an error is
+// returned. The verdict on clamped and rejected updates is reported back as a
+// Pod event.
+func (m *resmgr) reviewResourceUpdate(method string, c cache.Container,
+	update *criapi.UpdateContainerResourcesRequest) error {
+	lnx := update.GetLinux()
+	if lnx == nil {
+		return nil
+	}
+
+	current := c.GetResourceRequirements()
+	resources, resized := cache.UpdatedResources(current, lnx)
+	if !resized {
+		return nil
+	}
+
+	review := policy.NewResourceUpdate(current, resources)
+	m.policy.ReviewResourceUpdate(c, review)
+
+	switch review.Verdict {
+	case policy.UpdateRejected:
+		m.Warn("%s: resource update of %s rejected: %s", method, c.PrettyName(), review.Reason)
+		m.reportResourceUpdate(c, core_v1.EventTypeWarning, EventResourceUpdateRejected, review)
+		return resmgrError("resource update of %s rejected: %s", c.PrettyName(), review.Reason)
+	case policy.UpdateClamped:
+		m.Info("%s: resource update of %s clamped: %s", method, c.PrettyName(), review.Reason)
+		m.reportResourceUpdate(c, core_v1.EventTypeNormal, EventResourceUpdateClamped, review)
+		update.Linux = clampedLinuxResources(lnx, review.Resources)
+	}
+
+	return nil
+}
+
+// reportResourceUpdate emits a Pod event about the verdict on a resource update.
+func (m *resmgr) reportResourceUpdate(c cache.Container, eventType, reason string,
+	review *policy.ResourceUpdate) {
+	if !opt.PodEvents {
+		return
+	}
+	m.sendPodEvents([]*agent.PodEvent{
+		podEvent(c, eventType, reason, "resource update of container %s %s",
+			c.GetName(), review),
+	})
+}
+
+// clampedLinuxResources returns a copy of CRI resources with CPU and memory set to clamped values.
+func clampedLinuxResources(lnx *criapi.LinuxContainerResources,
+	resources core_v1.ResourceRequirements) *criapi.LinuxContainerResources {
+	clamped := *lnx
+
+	if qty, ok := resources.Requests[core_v1.ResourceCPU]; ok {
+		clamped.CpuShares = cache.MilliCPUToShares(int(qty.MilliValue()))
+	}
+	if qty, ok := resources.Limits[core_v1.ResourceCPU]; ok {
+		clamped.CpuQuota, clamped.CpuPeriod = cache.MilliCPUToQuota(qty.MilliValue())
+	}
+	if qty, ok := resources.Limits[core_v1.ResourceMemory]; ok {
+		clamped.MemoryLimitInBytes = qty.Value()
+	}
+
+	return &clamped
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"testing"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	criapi "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
+)
+
+func TestClampedLinuxResources(t *testing.T) {
+	lnx := &criapi.LinuxContainerResources{
+		CpuShares:          2048,
+		CpuQuota:           400000,
+		CpuPeriod:          100000,
+		MemoryLimitInBytes: 4 << 30,
+		CpusetCpus:         "0-3",
+	}
+	original := *lnx
+
+	tcases := []struct {
+		name      string
+		resources core_v1.ResourceRequirements
+		expected  criapi.LinuxContainerResources
+	}{
+		{
+			name: "all clamped",
+			resources: core_v1.ResourceRequirements{
+				Requests: core_v1.ResourceList{
+					core_v1.ResourceCPU: resource.MustParse("500m"),
+				},
+				Limits: core_v1.ResourceList{
+					core_v1.ResourceCPU:    resource.MustParse("1"),
+					core_v1.ResourceMemory: resource.MustParse("512Mi"),
+				},
+			},
+			expected: criapi.LinuxContainerResources{
+				CpuShares:          512,
+				CpuQuota:           100000,
+				CpuPeriod:          100000,
+				MemoryLimitInBytes: 512 << 20,
+				CpusetCpus:         "0-3",
+			},
+		},
+		{
+			name: "only memory",
+			resources: core_v1.ResourceRequirements{
+				Limits: core_v1.ResourceList{
+					core_v1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+			expected: criapi.LinuxContainerResources{
+				CpuShares:          2048,
+				CpuQuota:           400000,
+				CpuPeriod:          100000,
+				MemoryLimitInBytes: 1 << 30,
+				CpusetCpus:         "0-3",
+			},
+		},
+		{
+			name:     "nothing",
+			expected: original,
+		},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			clamped := clampedLinuxResources(lnx, tc.resources)
+			if *clamped != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, *clamped)
+			}
+			if *lnx != original {
+				t.Errorf("original resources modified: %+v", *lnx)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+)
+
+const (
+	// leaderLockFile is the lock file of the active instance, in the relay directory.
+	leaderLockFile = "leader.lock"
+)
+
+// acquireLeadership makes us the active instance, waiting for it if we're a standby.
+//
+// The active instance holds an exclusive lock on a file in the relay directory,
+// with its PID written to the file. The kernel releases the lock once the active
+// instance exits. A warm standby is fully set up, except for serving requests,
+// and waits for the lock. If asked to take over, it sends SIGTERM to the active
+// instance, which saves its cache and exits. Once the standby gets the lock, it
+// reloads the cache saved by the previous active instance.
+func (m *resmgr) acquireLeadership() error {
+	path := filepath.Join(opt.RelayDir, leaderLockFile)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return resmgrError("failed to open leader lock %s: %v", path, err)
+	}
+
+	waited := false
+	err = lockLeader(f, false)
+	if err == errLeaderBusy {
+		pid := readLeaderPID(path)
+		if !opt.Standby {
+			f.Close()
+			return resmgrError("another instance (PID %d) is active, use -standby to take over", pid)
+		}
+
+		m.Info("standing by for the active instance (PID %d)...", pid)
+		if opt.Takeover && pid > 0 {
+			m.Info("asking the active instance (PID %d) to hand over...", pid)
+			if err := signalLeader(pid); err != nil {
+				m.Warn("failed to signal the active instance (PID %d): %v", pid, err)
+			}
+		}
+
+		err = lockLeader(f, true)
+		waited = true
+	}
+	if err != nil {
+		f.Close()
+		return resmgrError("failed to lock leader lock %s: %v", path, err)
+	}
+
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		m.Warn("failed to write our PID to leader lock %s: %v", path, err)
+	}
+	m.leader = f
+
+	if waited {
+		return m.takeOver()
+	}
+
+	return nil
+}
+
+// takeOver takes over the state left behind by the previous active instance.
+func (m *resmgr) takeOver() error {
+	m.Info("taking over from the previous active instance...")
+
+	if err := m.cache.Reload(); err != nil {
+		return resmgrError("failed to reload cache: %v", err)
+	}
+	m.rebuild = len(m.cache.GetPods()) == 0 && len(m.cache.GetContainers()) == 0
+
+	if err := m.checkActivePolicy(); err != nil {
+		return err
+	}
+
+	// the HTTP endpoint was in use by the previous active instance
+	if err := instrumentation.Restart(); err != nil {
+		m.Warn("failed to restart instrumentation: %v", err)
+	}
+
+	return nil
+}
+
+// releaseLeadership releases the leader lock, letting any standby take over.
+func (m *resmgr) releaseLeadership() {
+	if m.leader == nil {
+		return
+	}
+	m.leader.Close()
+	m.leader = nil
+}
+
+// readLeaderPID reads the PID of the active instance from the leader lock.
+func readLeaderPID(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
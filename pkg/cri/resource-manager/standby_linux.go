@@ -0,0 +1,40 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package resmgr
+
+import (
+	"os"
+	"syscall"
+)
+
+// errLeaderBusy is returned by a non-blocking lockLeader if the lock is held.
+var errLeaderBusy = syscall.EWOULDBLOCK
+
+// lockLeader takes an exclusive lock on the leader lock, optionally waiting for it.
+func lockLeader(f *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+// signalLeader asks the active instance to hand over.
+func signalLeader(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package resmgr
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+// errLeaderBusy is returned by a non-blocking lockLeader if the lock is held.
+var errLeaderBusy = errors.New("leader lock is held by another instance")
+
+// lockLeader does nothing, leader locking is only supported on Linux.
+func lockLeader(f *os.File, wait bool) error {
+	return nil
+}
+
+// signalLeader fails, taking over from an active instance is only supported on Linux.
+func signalLeader(pid int) error {
+	return resmgrError("taking over is not supported on %s", runtime.GOOS)
+}
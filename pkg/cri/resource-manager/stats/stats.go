@@ -0,0 +1,376 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// Stats is a timestamped sample of the cgroup statistics of a container.
+type Stats struct {
+	// Timestamp is the time the sample was taken.
+	Timestamp time.Time `json:"timestamp"`
+	// Interval is the time elapsed since the previous sample, 0 for the first one.
+	Interval time.Duration `json:"interval,omitempty"`
+	// CPUUsage is the total CPU time consumed by the container.
+	CPUUsage time.Duration `json:"cpuUsage"`
+	// CPUUtilization is the average number of CPUs used since the previous sample.
+	CPUUtilization float64 `json:"cpuUtilization"`
+	// MemoryUsage is the current memory usage of the container, in bytes.
+	MemoryUsage int64 `json:"memoryUsage"`
+	// MemoryMaxUsage is the peak memory usage of the container, in bytes, if known.
+	MemoryMaxUsage int64 `json:"memoryMaxUsage,omitempty"`
+	// IOReadBytes is the total number of bytes read from block devices.
+	IOReadBytes int64 `json:"ioReadBytes"`
+	// IOWriteBytes is the total number of bytes written to block devices.
+	IOWriteBytes int64 `json:"ioWriteBytes"`
+	// IOReadRate is the average read rate since the previous sample, in bytes per second.
+	IOReadRate float64 `json:"ioReadRate"`
+	// IOWriteRate is the average write rate since the previous sample, in bytes per second.
+	IOWriteRate float64 `json:"ioWriteRate"`
+}
+
+// Provider provides the latest cgroup statistics of containers.
+type Provider interface {
+	// Stats returns the latest statistics of the container with the given ID.
+	Stats(containerID string) (*Stats, bool)
+}
+
+// Target is a running container to sample the statistics of.
+//
+// Targets are snapshots of containers, taken while the cache is locked, so
+// that cgroups can be sampled without holding any locks.
+type Target struct {
+	ID         string // container ID
+	Name       string // container name
+	Pod        string // pod name
+	Namespace  string // pod namespace
+	PrettyName string // container name for logging
+}
+
+// Targets returns the sampling targets of the running ones of the given containers.
+func Targets(containers []cache.Container) []Target {
+	targets := make([]Target, 0, len(containers))
+	for _, ctr := range containers {
+		if ctr.GetState() != cache.ContainerStateRunning {
+			continue
+		}
+		t := Target{
+			ID:         ctr.GetID(),
+			Name:       ctr.GetName(),
+			Namespace:  ctr.GetNamespace(),
+			PrettyName: ctr.PrettyName(),
+		}
+		if pod, ok := ctr.GetPod(); ok {
+			t.Pod = pod.GetName()
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// noStats is a Provider without any statistics.
+type noStats struct{}
+
+// NoStats returns a Provider without any statistics, for when collection is disabled.
+func NoStats() Provider {
+	return noStats{}
+}
+
+// Stats implements Provider.
+func (noStats) Stats(string) (*Stats, bool) {
+	return nil, false
+}
+
+// Collector periodically samples and caches the cgroup statistics of containers.
+type Collector interface {
+	Provider
+	prometheus.Collector
+	// Sample takes a new sample of the statistics of the given containers.
+	Sample(targets []Target)
+	// Reset drops all collected statistics.
+	Reset()
+}
+
+// statsLabels are the labels of the exported statistics of a container.
+var statsLabels = []string{"container", "pod", "namespace"}
+
+// collector implements Collector.
+type collector struct {
+	log.Logger
+	sync.RWMutex
+	root    string              // cgroup hierarchy mount point
+	stats   map[string]*Stats   // latest statistics, by container ID
+	labels  map[string][]string // exported metric labels, by container ID
+	cpu     *prometheus.GaugeVec
+	memory  *prometheus.GaugeVec
+	ioRead  *prometheus.GaugeVec
+	ioWrite *prometheus.GaugeVec
+}
+
+// NewCollector creates a collector for container cgroups under the given mount point.
+func NewCollector(cgroupRoot string) Collector {
+	return &collector{
+		Logger: log.NewLogger("stats"),
+		root:   cgroupRoot,
+		stats:  make(map[string]*Stats),
+		labels: make(map[string][]string),
+		cpu: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cri_resmgr_container_cpu_utilization",
+				Help: "Average number of CPUs used by a container since the previous sample.",
+			},
+			statsLabels,
+		),
+		memory: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cri_resmgr_container_memory_usage_bytes",
+				Help: "Current memory usage of a container.",
+			},
+			statsLabels,
+		),
+		ioRead: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cri_resmgr_container_io_read_bytes_per_second",
+				Help: "Average block device read rate of a container since the previous sample.",
+			},
+			statsLabels,
+		),
+		ioWrite: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cri_resmgr_container_io_write_bytes_per_second",
+				Help: "Average block device write rate of a container since the previous sample.",
+			},
+			statsLabels,
+		),
+	}
+}
+
+// Stats returns a copy of the latest statistics of a container.
+func (c *collector) Stats(containerID string) (*Stats, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	s, ok := c.stats[containerID]
+	if !ok {
+		return nil, false
+	}
+	stats := *s
+	return &stats, true
+}
+
+// Sample takes a new sample of the statistics of the given containers.
+//
+// Statistics of containers not among the given ones are dropped.
+func (c *collector) Sample(targets []Target) {
+	now := time.Now()
+	unified := cgroups.IsFile(filepath.Join(c.root, "cgroup.controllers"))
+
+	stats := make(map[string]*Stats)
+	labels := make(map[string][]string)
+	for _, t := range targets {
+		s, err := c.read(t.ID, unified)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				c.Debug("failed to read cgroup statistics of %s: %v", t.PrettyName, err)
+			}
+			continue
+		}
+		s.Timestamp = now
+		stats[t.ID] = s
+		labels[t.ID] = []string{t.Name, t.Pod, t.Namespace}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for id, s := range stats {
+		updateRates(c.stats[id], s)
+	}
+	c.stats = stats
+
+	for id, last := range c.labels {
+		if l, ok := labels[id]; !ok || !equalLabels(l, last) {
+			c.deleteMetrics(last)
+		}
+	}
+	for id, s := range stats {
+		l := labels[id]
+		c.cpu.WithLabelValues(l...).Set(s.CPUUtilization)
+		c.memory.WithLabelValues(l...).Set(float64(s.MemoryUsage))
+		c.ioRead.WithLabelValues(l...).Set(s.IOReadRate)
+		c.ioWrite.WithLabelValues(l...).Set(s.IOWriteRate)
+	}
+	c.labels = labels
+}
+
+// Reset drops all collected statistics.
+func (c *collector) Reset() {
+	c.Lock()
+	defer c.Unlock()
+	c.stats = make(map[string]*Stats)
+	c.labels = make(map[string][]string)
+	c.cpu.Reset()
+	c.memory.Reset()
+	c.ioRead.Reset()
+	c.ioWrite.Reset()
+}
+
+// deleteMetrics drops the exported statistics with the given labels.
+func (c *collector) deleteMetrics(labels []string) {
+	c.cpu.DeleteLabelValues(labels...)
+	c.memory.DeleteLabelValues(labels...)
+	c.ioRead.DeleteLabelValues(labels...)
+	c.ioWrite.DeleteLabelValues(labels...)
+}
+
+// read reads the current cgroup statistics of a container.
+func (c *collector) read(id string, unified bool) (*Stats, error) {
+	if unified {
+		return c.readUnified(id)
+	}
+	return c.readLegacy(id)
+}
+
+// readUnified reads the statistics of a container from the cgroup v2 hierarchy.
+func (c *collector) readUnified(id string) (*Stats, error) {
+	dir, err := cgroups.ResolveCgroupPath(c.root, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, err := cgroups.GetCPUStat(dir)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := cgroups.GetMemoryCurrent(dir)
+	if err != nil {
+		return nil, err
+	}
+	io, err := cgroups.GetIOStat(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &Stats{
+		CPUUsage:     time.Duration(cpu.UsageUsec) * time.Microsecond,
+		MemoryUsage:  mem,
+		IOReadBytes:  io.ReadBytes,
+		IOWriteBytes: io.WriteBytes,
+	}, nil
+}
+
+// readLegacy reads the statistics of a container from the cgroup v1 hierarchies.
+func (c *collector) readLegacy(id string) (*Stats, error) {
+	s := &Stats{}
+
+	dir, err := cgroups.ResolveCgroupPath(filepath.Join(c.root, "cpuacct"), id)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := cgroups.GetCPUAcctStats(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range usage {
+		s.CPUUsage += time.Duration(u.User + u.System)
+	}
+
+	dir, err = cgroups.ResolveCgroupPath(filepath.Join(c.root, "memory"), id)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := cgroups.GetMemoryUsage(dir)
+	if err != nil {
+		return nil, err
+	}
+	s.MemoryUsage = mem.Bytes
+	s.MemoryMaxUsage = mem.MaxBytes
+
+	dir, err = cgroups.ResolveCgroupPath(filepath.Join(c.root, "blkio"), id)
+	if err != nil {
+		return nil, err
+	}
+	blkio, err := cgroups.GetBlkioThrottleBytes(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, dev := range blkio.DeviceBytes {
+		s.IOReadBytes += dev.Operations["Read"]
+		s.IOWriteBytes += dev.Operations["Write"]
+	}
+
+	return s, nil
+}
+
+// updateRates updates the utilization and rates of a sample from the previous one.
+//
+// Counters going backwards, for instance due to a container restart, yield zero rates.
+func updateRates(prev, s *Stats) {
+	if prev == nil || !s.Timestamp.After(prev.Timestamp) {
+		return
+	}
+
+	s.Interval = s.Timestamp.Sub(prev.Timestamp)
+	seconds := s.Interval.Seconds()
+	if s.CPUUsage >= prev.CPUUsage {
+		s.CPUUtilization = float64(s.CPUUsage-prev.CPUUsage) / float64(s.Interval)
+	}
+	if s.IOReadBytes >= prev.IOReadBytes {
+		s.IOReadRate = float64(s.IOReadBytes-prev.IOReadBytes) / seconds
+	}
+	if s.IOWriteBytes >= prev.IOWriteBytes {
+		s.IOWriteRate = float64(s.IOWriteBytes-prev.IOWriteBytes) / seconds
+	}
+}
+
+// equalLabels checks if two label value slices are equal.
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	c.cpu.Describe(ch)
+	c.memory.Describe(ch)
+	c.ioRead.Describe(ch)
+	c.ioWrite.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.RLock()
+	defer c.RUnlock()
+	c.cpu.Collect(ch)
+	c.memory.Collect(ch)
+	c.ioRead.Collect(ch)
+	c.ioWrite.Collect(ch)
+}
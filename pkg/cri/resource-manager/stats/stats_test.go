@@ -0,0 +1,127 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache"
+	"github.com/intel/cri-resource-manager/pkg/cri/resource-manager/cache/cachetest"
+)
+
+func TestUpdateRates(t *testing.T) {
+	now := time.Now()
+	prev := &Stats{
+		Timestamp:    now,
+		CPUUsage:     10 * time.Second,
+		IOReadBytes:  1000,
+		IOWriteBytes: 5000,
+	}
+
+	tcases := []struct {
+		name        string
+		sample      *Stats
+		prev        *Stats
+		interval    time.Duration
+		utilization float64
+		readRate    float64
+		writeRate   float64
+	}{
+		{
+			name:   "first sample",
+			sample: &Stats{Timestamp: now, CPUUsage: 10 * time.Second},
+		},
+		{
+			name: "two busy CPUs",
+			sample: &Stats{
+				Timestamp:    now.Add(2 * time.Second),
+				CPUUsage:     14 * time.Second,
+				IOReadBytes:  3000,
+				IOWriteBytes: 5000,
+			},
+			prev:        prev,
+			interval:    2 * time.Second,
+			utilization: 2.0,
+			readRate:    1000,
+		},
+		{
+			name: "counters reset",
+			sample: &Stats{
+				Timestamp:    now.Add(4 * time.Second),
+				CPUUsage:     time.Second,
+				IOReadBytes:  10,
+				IOWriteBytes: 9000,
+			},
+			prev:      prev,
+			interval:  4 * time.Second,
+			writeRate: 1000,
+		},
+		{
+			name:   "stale sample",
+			sample: &Stats{Timestamp: now.Add(-time.Second), CPUUsage: 20 * time.Second},
+			prev:   prev,
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			updateRates(tc.prev, tc.sample)
+			if tc.sample.Interval != tc.interval {
+				t.Errorf("expected interval %v, got %v", tc.interval, tc.sample.Interval)
+			}
+			if tc.sample.CPUUtilization != tc.utilization {
+				t.Errorf("expected CPU utilization %f, got %f", tc.utilization, tc.sample.CPUUtilization)
+			}
+			if tc.sample.IOReadRate != tc.readRate {
+				t.Errorf("expected read rate %f, got %f", tc.readRate, tc.sample.IOReadRate)
+			}
+			if tc.sample.IOWriteRate != tc.writeRate {
+				t.Errorf("expected write rate %f, got %f", tc.writeRate, tc.sample.IOWriteRate)
+			}
+		})
+	}
+}
+
+func TestTargets(t *testing.T) {
+	cch, err := cachetest.NewCache()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer cch.Close()
+
+	_, containers, err := cch.CreatePod(cachetest.NewPod("pod").Namespace("ns").
+		Container(cachetest.NewContainer("running")).
+		Container(cachetest.NewContainer("created")))
+	if err != nil {
+		t.Fatalf("failed to create pod: %v", err)
+	}
+	containers[0].UpdateState(cache.ContainerStateRunning)
+
+	targets := Targets(containers)
+	if len(targets) != 1 {
+		t.Fatalf("expected only the running container to be sampled, got %v", targets)
+	}
+	expected := Target{
+		ID:         containers[0].GetID(),
+		Name:       "running",
+		Pod:        "pod",
+		Namespace:  "ns",
+		PrettyName: containers[0].PrettyName(),
+	}
+	if targets[0] != expected {
+		t.Errorf("expected target %v, got %v", expected, targets[0])
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/intel/cri-resource-manager/pkg/instrumentation"
+)
+
+const (
+	// AssignmentStreamPath is the HTTP path for streaming container assignment updates.
+	AssignmentStreamPath = "/assignments/stream"
+	// assignmentStreamBacklog is the number of updates buffered per client.
+	assignmentStreamBacklog = 64
+)
+
+// assignmentUpdate is a single container assignment change streamed to clients.
+type assignmentUpdate struct {
+	// Container is the cache ID of the container.
+	Container string `json:"container"`
+	// Name is the pretty name of the container.
+	Name string `json:"name,omitempty"`
+	// Assignment is the new assignment, omitted if the container is gone.
+	Assignment json.RawMessage `json:"assignment,omitempty"`
+}
+
+// poolUpdate is a single pool state change streamed to clients.
+type poolUpdate struct {
+	// Pool is the name of the pool.
+	Pool string `json:"pool"`
+	// State is the new state of the pool, omitted if the pool is gone.
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+// assignmentStream pushes container assignment changes to streaming (SSE) clients.
+type assignmentStream struct {
+	sync.Mutex
+	clients map[chan []byte]struct{} // connected clients
+	last    map[string]string        // last published assignments
+	names   map[string]string        // pretty names of published containers
+	pools   map[string]string        // last published pool states
+}
+
+// httpEndpoint is an HTTP path of a multiplexer.
+type httpEndpoint struct {
+	mux  *http.ServeMux
+	path string
+}
+
+// httpHandlers are the HTTP handlers of the latest resource manager instance, by endpoint.
+var httpHandlers = struct {
+	sync.RWMutex
+	endpoints map[httpEndpoint]http.Handler
+}{endpoints: make(map[httpEndpoint]http.Handler)}
+
+// registerHTTPHandler registers an HTTP handler with the instrumentation multiplexer.
+//
+// The multiplexer is global and panics on duplicate registrations, so every path
+// is registered only once, dispatching to the handler of the latest instance.
+func registerHTTPHandler(mux *http.ServeMux, path string, handler http.Handler) {
+	httpHandlers.Lock()
+	defer httpHandlers.Unlock()
+
+	ep := httpEndpoint{mux: mux, path: path}
+	if _, ok := httpHandlers.endpoints[ep]; !ok {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			httpHandlers.RLock()
+			h := httpHandlers.endpoints[ep]
+			httpHandlers.RUnlock()
+			h.ServeHTTP(w, r)
+		})
+	}
+	httpHandlers.endpoints[ep] = handler
+}
+
+// setupAssignmentStream sets up the HTTP endpoint for streaming assignment updates.
+func (m *resmgr) setupAssignmentStream() error {
+	m.stream = &assignmentStream{
+		clients: make(map[chan []byte]struct{}),
+		last:    make(map[string]string),
+		names:   make(map[string]string),
+		pools:   make(map[string]string),
+	}
+
+	mux := instrumentation.GetHTTPMux()
+	if mux == nil {
+		m.Warn("no HTTP multiplexer, assignment streaming disabled")
+		return nil
+	}
+	registerHTTPHandler(mux, AssignmentStreamPath, http.HandlerFunc(m.stream.serve))
+
+	return nil
+}
+
+// publishAssignments streams the assignments which changed since they were last published.
+func (m *resmgr) publishAssignments() {
+	if m.stream == nil {
+		return
+	}
+
+	updates := []*assignmentUpdate{}
+	current := make(map[string]struct{})
+
+	m.stream.Lock()
+	defer m.stream.Unlock()
+
+	for _, c := range m.cache.GetContainers() {
+		id := c.GetCacheID()
+		current[id] = struct{}{}
+		data, err := c.ExportResourceAssignment()
+		if err != nil {
+			m.Error("%v", err)
+			continue
+		}
+		if m.stream.last[id] == string(data) {
+			continue
+		}
+		m.stream.last[id] = string(data)
+		m.stream.names[id] = c.PrettyName()
+		updates = append(updates, &assignmentUpdate{
+			Container:  id,
+			Name:       c.PrettyName(),
+			Assignment: json.RawMessage(data),
+		})
+	}
+	for id := range m.stream.last {
+		if _, ok := current[id]; !ok {
+			updates = append(updates, &assignmentUpdate{
+				Container: id,
+				Name:      m.stream.names[id],
+			})
+			delete(m.stream.last, id)
+			delete(m.stream.names, id)
+		}
+	}
+
+	for _, u := range updates {
+		m.stream.broadcast(sseEvent("assignment", u))
+	}
+
+	m.publishPools()
+}
+
+// publishPools streams the pool states which changed since they were last published.
+func (m *resmgr) publishPools() {
+	state := m.policy.GetState()
+	if state == nil {
+		return
+	}
+
+	updates := []*poolUpdate{}
+	current := make(map[string]struct{})
+
+	for _, pool := range state.Pools {
+		current[pool.Name] = struct{}{}
+		data, err := json.Marshal(pool)
+		if err != nil {
+			m.Error("failed to marshal state of pool %s: %v", pool.Name, err)
+			continue
+		}
+		if m.stream.pools[pool.Name] == string(data) {
+			continue
+		}
+		m.stream.pools[pool.Name] = string(data)
+		updates = append(updates, &poolUpdate{Pool: pool.Name, State: json.RawMessage(data)})
+	}
+	for name := range m.stream.pools {
+		if _, ok := current[name]; !ok {
+			updates = append(updates, &poolUpdate{Pool: name})
+			delete(m.stream.pools, name)
+		}
+	}
+
+	for _, u := range updates {
+		m.stream.broadcast(sseEvent("pool", u))
+	}
+}
+
+// sseEvent formats an update as a server-sent event.
+func sseEvent(event string, update interface{}) []byte {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return nil
+	}
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+}
+
+// broadcast sends an event to all clients, dropping clients which can't keep up.
+func (s *assignmentStream) broadcast(data []byte) {
+	if data == nil {
+		return
+	}
+	for ch := range s.clients {
+		select {
+		case ch <- data:
+		default:
+			delete(s.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// serve streams assignment updates to a client as server-sent events.
+func (s *assignmentStream) serve(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := make(chan []byte, assignmentStreamBacklog)
+
+	// take a snapshot of the full current state and subscribe to updates
+	// atomically, so no update falls between the two
+	s.Lock()
+	snapshot := make([][]byte, 0, len(s.last)+len(s.pools))
+	for id, data := range s.last {
+		snapshot = append(snapshot, sseEvent("assignment", &assignmentUpdate{
+			Container:  id,
+			Name:       s.names[id],
+			Assignment: json.RawMessage(data),
+		}))
+	}
+	for name, data := range s.pools {
+		snapshot = append(snapshot, sseEvent("pool", &poolUpdate{
+			Pool:  name,
+			State: json.RawMessage(data),
+		}))
+	}
+	s.clients[ch] = struct{}{}
+	s.Unlock()
+
+	defer func() {
+		s.Lock()
+		if _, ok := s.clients[ch]; ok {
+			delete(s.clients, ch)
+			close(ch)
+		}
+		s.Unlock()
+	}()
+
+	for _, data := range snapshot {
+		if _, err := w.Write(data); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
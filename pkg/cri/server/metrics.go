@@ -0,0 +1,98 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+const (
+	// phasePreprocess is the time spent before relaying a request, for instance in policy hooks.
+	phasePreprocess = "preprocess"
+	// phaseRuntime is the time spent waiting for the downstream runtime.
+	phaseRuntime = "runtime"
+	// phasePostprocess is the time spent after the runtime has replied.
+	phasePostprocess = "postprocess"
+	// phaseTotal is the total time spent processing a request.
+	phaseTotal = "total"
+)
+
+// requestMetrics collects request latencies and errors per CRI method.
+type requestMetrics struct {
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+}
+
+// Our request metrics collector.
+var reqMetrics = &requestMetrics{
+	latency: prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cri_request_duration_seconds",
+			Help:    "Latency of relayed CRI requests, by method, kind and processing phase.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"method", "kind", "phase"},
+	),
+	errors: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cri_request_errors_total",
+			Help: "Number of relayed CRI requests failed, by method and kind.",
+		},
+		[]string{"method", "kind"},
+	),
+}
+
+// observe records the latencies and outcome of a single request.
+func (m *requestMetrics) observe(kind, name string, start, send, recv, end time.Time, err error) {
+	total := end.Sub(start)
+	m.latency.WithLabelValues(name, kind, phaseTotal).Observe(total.Seconds())
+
+	// send/recv are unset if the request never reached the runtime
+	if !send.IsZero() && !recv.IsZero() {
+		m.latency.WithLabelValues(name, kind, phasePreprocess).Observe(send.Sub(start).Seconds())
+		m.latency.WithLabelValues(name, kind, phaseRuntime).Observe(recv.Sub(send).Seconds())
+		m.latency.WithLabelValues(name, kind, phasePostprocess).Observe(end.Sub(recv).Seconds())
+	}
+
+	if err != nil {
+		m.errors.WithLabelValues(name, kind).Inc()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *requestMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.latency.Describe(ch)
+	m.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *requestMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.latency.Collect(ch)
+	m.errors.Collect(ch)
+}
+
+func init() {
+	err := metrics.RegisterCollector("cri", func() (prometheus.Collector, error) {
+		return reqMetrics, nil
+	})
+	if err != nil {
+		logger.Get("cri/server").Error("failed to register CRI request metrics collector: %v", err)
+	}
+}
@@ -24,6 +24,8 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	api "k8s.io/cri-api/pkg/apis/runtime/v1alpha2"
 
@@ -55,6 +57,8 @@ type Server interface {
 	RegisterRuntimeService(api.RuntimeServiceServer) error
 	// RegisterInterceptors registers the given interceptors with the server.
 	RegisterInterceptors(map[string]Interceptor) error
+	// SetServing sets the status reported by the gRPC health service of the server.
+	SetServing(bool)
 	// Start starts the request processing loop (goroutine) of the server.
 	Start() error
 	// Stop stops the request processing loop (goroutine) of the server.
@@ -66,6 +70,7 @@ type server struct {
 	logger.Logger
 	listener     net.Listener              // socket our gRPC server listens on
 	server       *grpc.Server              // our gRPC server
+	health       *health.Server            // gRPC health service
 	options      Options                   // server options
 	interceptors map[string]Interceptor    // request intercepting hooks
 	runtime      *api.RuntimeServiceServer // CRI runtime service
@@ -137,9 +142,27 @@ func (s *server) RegisterInterceptors(intercept map[string]Interceptor) error {
 	return nil
 }
 
+// SetServing sets the status reported by the gRPC health service.
+func (s *server) SetServing(serving bool) {
+	if s.health == nil {
+		return
+	}
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	s.health.SetServingStatus("", status)
+}
+
 // Start starts the servers request processing goroutine.
 func (s *server) Start() error {
 	s.Debug("starting server on socket %s...", s.options.Socket)
+	if err := s.listen(); err != nil {
+		return err
+	}
+
 	go func() {
 		s.server.Serve(s.listener)
 	}()
@@ -155,15 +178,38 @@ func (s *server) Start() error {
 // Stop serving CRI requests.
 func (s *server) Stop() {
 	s.Debug("stopping server on socket %s...", s.options.Socket)
+	if s.health != nil {
+		s.health.Shutdown()
+	}
 	s.server.Stop()
 }
 
-// createGrpcServer creates a gRPC server instance on our socket.
+// createGrpcServer creates our gRPC server instance.
 func (s *server) createGrpcServer() error {
 	if s.server != nil {
 		return nil
 	}
 
+	s.server = grpc.NewServer(instrumentation.InjectGrpcServerTrace()...)
+
+	// We report ourselves not serving until told otherwise, so that anyone
+	// waiting for us to become ready can use the standard gRPC health check.
+	s.health = health.NewServer()
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s.server, s.health)
+
+	return nil
+}
+
+// listen creates the socket our gRPC server listens on.
+//
+// We only create the socket once we start serving requests, so that a warm
+// standby instance can be fully set up while another instance is active.
+func (s *server) listen() error {
+	if s.listener != nil {
+		return nil
+	}
+
 	if err := os.MkdirAll(filepath.Dir(s.options.Socket), 0700); err != nil {
 		return serverError("failed to create directory for socket %s: %v",
 			s.options.Socket, err)
@@ -184,7 +230,6 @@ func (s *server) createGrpcServer() error {
 		}
 	}
 	s.listener = l
-	s.server = grpc.NewServer(instrumentation.InjectGrpcServerTrace()...)
 
 	return nil
 }
@@ -244,8 +289,13 @@ func (s *server) intercept(ctx context.Context, req interface{},
 		span.AddAttributes(trace.StringAttribute("kind", kind))
 	}
 
+	var rpl interface{}
+	var err error
+
 	start = time.Now()
-	rpl, err := fn(ctx, name, req, wrapHandler)
+	instrumentation.WithProfilerLabels(ctx, func(ctx context.Context) {
+		rpl, err = fn(ctx, name, req, wrapHandler)
+	}, "cri-request", name, "kind", kind)
 	end = time.Now()
 	elapsed := end.Sub(start)
 
@@ -255,13 +305,15 @@ func (s *server) intercept(ctx context.Context, req interface{},
 		dump.ReplyMessage(kind, info.FullMethod, rpl, elapsed)
 	}
 
-	s.collectStatistics(kind, name, start, send, recv, end)
+	s.collectStatistics(kind, name, start, send, recv, end, err)
 
 	return rpl, err
 }
 
-// collectStatistics collects (should collect) request processing statistics.
-func (s *server) collectStatistics(kind, name string, start, send, recv, end time.Time) {
+// collectStatistics collects request processing statistics.
+func (s *server) collectStatistics(kind, name string, start, send, recv, end time.Time, err error) {
+	reqMetrics.observe(kind, name, start, send, recv, end, err)
+
 	if kind == "passthrough" {
 		return
 	}
@@ -51,6 +51,8 @@ type options struct {
 	Agent string
 	// Metrics is the Prometheus metrics exporter endpoint.
 	Metrics string
+	// Profiling enables pprof HTTP endpoints and per CRI request profiler labels.
+	Profiling bool
 }
 
 // Our instrumentation options.
@@ -0,0 +1,51 @@
+// Copyright 2019-2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"context"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+)
+
+// startProfiling registers the pprof HTTP endpoints, if profiling is enabled.
+func (s *Service) startProfiling() {
+	if !opt.Profiling {
+		return
+	}
+
+	log.Info("enabling profiling endpoints...")
+	s.reqmux.HandleFunc("/debug/pprof/", pprof.Index)
+	s.reqmux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	s.reqmux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	s.reqmux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	s.reqmux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// ProfilingEnabled returns true if profiling is enabled.
+func ProfilingEnabled() bool {
+	return opt.Profiling
+}
+
+// WithProfilerLabels runs fn with the given profiler label key-value pairs set,
+// if profiling is enabled. This allows CPU profiles to be broken down by labels,
+// for instance by CRI request.
+func WithProfilerLabels(ctx context.Context, fn func(context.Context), labels ...string) {
+	if !opt.Profiling {
+		fn(ctx)
+		return
+	}
+	runtimepprof.Do(ctx, runtimepprof.Labels(labels...), fn)
+}
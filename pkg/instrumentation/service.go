@@ -94,6 +94,7 @@ func (s *Service) start() error {
 	log.Info("starting instrumentation service...")
 
 	s.createHTTP()
+	s.startProfiling()
 	s.startJaegerExporter()
 	s.startPrometheusExporter()
 
@@ -40,6 +40,18 @@ debugging:
     enable: all
     debug: all
 
-The very same logger settings can be also controlled using the --logger-source
-and --logger-debug command line options.
+You can also set the least severity of messages to pass through per log source,
+for instance to only pass warnings and errors from the cache and debug messages
+from the topology-aware policy:
+
+  logger:
+    levels: warn:cache,debug:topology-aware
+
+To emit every message as a single JSON object, select the json backend:
+
+  logger:
+    logger: json
+
+The very same logger settings can be also controlled using the --logger-source,
+--logger-debug, --logger-source-level and --logger command line options.
 `
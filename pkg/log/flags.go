@@ -19,6 +19,7 @@ import (
 	"flag"
 	"fmt"
 	"github.com/intel/cri-resource-manager/pkg/config"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -35,6 +36,8 @@ const (
 	optionSource = "logger-source"
 	// Flag for enabling/disabling logging sources.
 	optionDebug = "logger-debug"
+	// Flag for setting per-source logging levels.
+	optionLevels = "logger-source-level"
 )
 
 // LevelNames maps severity levels to names.
@@ -65,9 +68,11 @@ type options struct {
 	Logger backendName // name of active backend
 	Enable stateMap    // enabled logger sources, all if nil
 	Debug  stateMap    // enable debug flags, all if nil
+	Levels levelMap    // per-source lowest unsuppressed severity
 }
 
 type stateMap map[string]bool
+type levelMap map[string]Level
 type backendName string
 
 // Our default and runtime configuration.
@@ -76,6 +81,7 @@ var defaults = &options{
 	Logger: backendName(fmtBackendName),
 	Enable: stateMap{"*": true},
 	Debug:  stateMap{"*": false},
+	Levels: levelMap{},
 }
 var opt = defaultOptions().(*options)
 
@@ -88,8 +94,7 @@ func (l *Level) Set(value string) error {
 	*l = level
 
 	if l == &defaults.Level {
-		opt.updateLoggers()
-		opt.Level = level
+		configure(func(o *options) { o.Level = level })
 	}
 
 	return nil
@@ -109,7 +114,7 @@ func (n *backendName) Set(value string) error {
 	activateBackend(value)
 
 	if n == &defaults.Logger {
-		opt.Logger = *n
+		configure(func(o *options) { o.Logger = backendName(value) })
 	}
 
 	return nil
@@ -167,21 +172,18 @@ func (m *stateMap) Set(value string) error {
 		}
 	}
 
-	var optMap *stateMap
-
-	switch {
-	case m == &defaults.Enable:
-		optMap = &opt.Enable
-	case m == &defaults.Debug:
-		optMap = &opt.Debug
-	}
-
-	if optMap != nil {
-		*optMap = make(stateMap)
+	if m == &defaults.Enable || m == &defaults.Debug {
+		state := make(stateMap)
 		for key, value := range *m {
-			(*optMap)[key] = value
+			state[key] = value
 		}
-		opt.updateLoggers()
+		configure(func(o *options) {
+			if m == &defaults.Enable {
+				o.Enable = state
+			} else {
+				o.Debug = state
+			}
+		})
 	}
 
 	return nil
@@ -244,6 +246,78 @@ func (m *stateMap) isEnabled(name string) bool {
 	return false
 }
 
+// Set is the flag.Value setter for per-source severity levels.
+//
+// The value is a comma-separated list of sources, each optionally prefixed by
+// a level and a colon. The level applies to all subsequent sources until the
+// next one, for instance 'warn:cache,resource-manager,debug:topology-aware'.
+func (m *levelMap) Set(value string) error {
+	if m != &defaults.Levels || *m == nil { // from the command line we cumulate these
+		*m = make(levelMap)
+	}
+
+	level, prev := LevelInfo, ""
+	for _, req := range strings.Split(strings.TrimSpace(value), ",") {
+		if req = strings.TrimSpace(req); req == "" {
+			continue
+		}
+		names := req
+		if split := strings.SplitN(req, ":", 2); len(split) == 2 {
+			l, ok := NamedLevels[split[0]]
+			if !ok {
+				return loggerError("unknown log level '%s' in spec '%s'", split[0], value)
+			}
+			level, prev, names = l, split[0], split[1]
+		}
+		if prev == "" {
+			return loggerError("missing log level for '%s' in spec '%s'", names, value)
+		}
+		switch names {
+		case "all", "*":
+			(*m)["*"] = level
+		default:
+			(*m)[names] = level
+		}
+	}
+
+	if m == &defaults.Levels {
+		levels := make(levelMap)
+		for key, value := range *m {
+			levels[key] = value
+		}
+		configure(func(o *options) { o.Levels = levels })
+	}
+
+	return nil
+}
+
+func (m *levelMap) String() string {
+	if m == nil || len(*m) == 0 {
+		return ""
+	}
+
+	bylevel := map[Level][]string{}
+	for name, level := range *m {
+		if name == "*" {
+			name = "all"
+		}
+		bylevel[level] = append(bylevel[level], name)
+	}
+
+	str, sep := "", ""
+	for _, level := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		names := bylevel[level]
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		str += sep + level.String() + ":" + strings.Join(names, ",")
+		sep = ","
+	}
+
+	return str
+}
+
 func (o *options) MarshalJSON() ([]byte, error) {
 	cfg := map[string]string{
 		"Level":  o.Level.String(),
@@ -255,6 +329,9 @@ func (o *options) MarshalJSON() ([]byte, error) {
 	if o.Debug != nil {
 		cfg["Debug"] = o.Debug.String()
 	}
+	if len(o.Levels) > 0 {
+		cfg["Levels"] = o.Levels.String()
+	}
 
 	return json.Marshal(cfg)
 }
@@ -266,23 +343,27 @@ func (o *options) UnmarshalJSON(raw []byte) error {
 		return loggerError("failed to unmarshal logger configuration: %v", err)
 	}
 
-	*o = *defaults
+	n := *defaults
 
 	for key, value := range cfg {
 		switch key {
 		case "Level":
-			if err := o.Level.Set(value); err != nil {
+			if err := n.Level.Set(value); err != nil {
 				return err
 			}
 		case "Logger":
-			o.Logger = backendName(value)
+			n.Logger = backendName(value)
 			activateBackend(value)
 		case "Enable":
-			if err := o.Enable.Set(value); err != nil {
+			if err := n.Enable.Set(value); err != nil {
 				return err
 			}
 		case "Debug":
-			if err := o.Debug.Set(value); err != nil {
+			if err := n.Debug.Set(value); err != nil {
+				return err
+			}
+		case "Levels":
+			if err := n.Levels.Set(value); err != nil {
 				return err
 			}
 		default:
@@ -290,6 +371,12 @@ func (o *options) UnmarshalJSON(raw []byte) error {
 		}
 	}
 
+	if o == opt {
+		configure(func(o *options) { *o = n })
+	} else {
+		*o = n
+	}
+
 	return nil
 }
 
@@ -298,9 +385,23 @@ func (o *options) sourceEnabled(source string) bool {
 }
 
 func (o *options) debugEnabled(source string) bool {
+	if level, ok := o.Levels[source]; ok && level == LevelDebug {
+		return true
+	}
 	return o.Debug.isEnabled(source)
 }
 
+// sourceLevel returns the lowest unsuppressed severity for the given source.
+func (o *options) sourceLevel(source string) Level {
+	if level, ok := o.Levels[source]; ok {
+		return level
+	}
+	if level, ok := o.Levels["*"]; ok {
+		return level
+	}
+	return o.Level
+}
+
 func loggerError(format string, args ...interface{}) error {
 	return fmt.Errorf("log: "+format, args...)
 }
@@ -312,6 +413,7 @@ func defaultOptions() interface{} {
 		Logger: defaults.Logger,
 		Enable: make(stateMap),
 		Debug:  make(stateMap),
+		Levels: make(levelMap),
 	}
 	for key, value := range defaults.Enable {
 		o.Enable[key] = value
@@ -319,6 +421,9 @@ func defaultOptions() interface{} {
 	for key, value := range defaults.Debug {
 		o.Debug[key] = value
 	}
+	for key, value := range defaults.Levels {
+		o.Levels[key] = value
+	}
 	return o
 }
 
@@ -327,14 +432,21 @@ func (o *options) configNotify(event config.Event, source config.Source) error {
 	defLogger.Info("logger configuration %v", event)
 
 	opt.updateLoggers()
-
-	defLogger.Info(" * log level: %v", opt.Level)
-	defLogger.Info(" * enabled sources: %v", opt.Enable.String())
-	defLogger.Info(" * enabled debugging: %v", opt.Debug.String())
+	currentOptions().dump()
 
 	return nil
 }
 
+// dump logs our runtime configuration.
+func (o *options) dump() {
+	defLogger.Info(" * log level: %v", o.Level)
+	defLogger.Info(" * enabled sources: %v", o.Enable.String())
+	defLogger.Info(" * enabled debugging: %v", o.Debug.String())
+	if len(o.Levels) > 0 {
+		defLogger.Info(" * per-source log levels: %v", o.Levels.String())
+	}
+}
+
 // Register us for command line parsing and configuration handling.
 func init() {
 	cfglog := NewLogger("config")
@@ -357,8 +469,12 @@ func init() {
 	flag.Var(&defaults.Debug, optionDebug,
 		"value is a comma-separated logger source names to enable debug for.\n"+
 			"Specify '*' or all for enabling debugging for all sources.")
+	flag.Var(&defaults.Levels, optionLevels,
+		"value is a comma-separated list of logger source names, each optionally\n"+
+			"prefixed by the least severity to pass through for it, for instance\n"+
+			"'warn:cache,resource-manager,debug:topology-aware'.")
 	flag.Var(&defaults.Logger, optionLogger,
-		"select logging backend to use")
+		"select logging backend to use ('fmt' or 'json')")
 
 	config.Register("logger", configHelp, opt, defaultOptions,
 		config.WithNotify(opt.configNotify))
@@ -0,0 +1,92 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// HTTPHandler returns an HTTP handler for inspecting and changing the runtime logger configuration.
+//
+// A GET request returns the current configuration. A PUT or POST request updates
+// the configuration with a JSON object, using the same keys as the configuration
+// fragment for the logger, for instance {"Levels": "debug:topology-aware"}. Keys
+// omitted from the object keep their current value. Changes made this way last
+// until the next configuration update.
+func HTTPHandler() http.Handler {
+	return http.HandlerFunc(serveHTTP)
+}
+
+func serveHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := updateOptions(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "unsupported method "+req.Method, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(currentOptions()); err != nil {
+		defLogger.Error("failed to serve logger configuration: %v", err)
+	}
+}
+
+// updateOptions updates the runtime configuration with the given JSON-encoded entries.
+func updateOptions(raw []byte) error {
+	current, err := json.Marshal(currentOptions())
+	if err != nil {
+		return loggerError("failed to marshal logger configuration: %v", err)
+	}
+
+	cfg := map[string]string{}
+	if err := json.Unmarshal(current, &cfg); err != nil {
+		return loggerError("failed to unmarshal logger configuration: %v", err)
+	}
+	update := map[string]string{}
+	if err := json.Unmarshal(raw, &update); err != nil {
+		return loggerError("invalid logger configuration update: %v", err)
+	}
+	for key, value := range update {
+		cfg[key] = value
+	}
+
+	merged, err := json.Marshal(cfg)
+	if err != nil {
+		return loggerError("failed to marshal logger configuration: %v", err)
+	}
+
+	o := &options{}
+	if err := o.UnmarshalJSON(merged); err != nil {
+		return err
+	}
+	configure(func(opt *options) { *opt = *o })
+
+	defLogger.Info("logger configuration updated over HTTP")
+	o.dump()
+
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// restoreOptions returns a function to restore the current runtime configuration.
+func restoreOptions() func() {
+	saved := currentOptions()
+	return func() {
+		configure(func(o *options) { *o = *saved })
+	}
+}
+
+func serve(t *testing.T, method, body string) (int, map[string]string) {
+	req := httptest.NewRequest(method, "/loggers", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	HTTPHandler().ServeHTTP(rec, req)
+
+	cfg := map[string]string{}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &cfg); err != nil {
+			t.Fatalf("failed to unmarshal response %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code, cfg
+}
+
+func TestHTTPHandler(t *testing.T) {
+	defer restoreOptions()()
+
+	l := Get("http-test")
+	defer l.Stop()
+
+	code, cfg := serve(t, http.MethodGet, "")
+	if code != http.StatusOK {
+		t.Fatalf("GET: expected status %d, got %d", http.StatusOK, code)
+	}
+	if cfg["Level"] != opt.Level.String() {
+		t.Errorf("GET: expected level %q, got %q", opt.Level.String(), cfg["Level"])
+	}
+	if l.DebugEnabled() {
+		t.Fatalf("debugging unexpectedly enabled for %s", "http-test")
+	}
+
+	code, cfg = serve(t, http.MethodPut, `{"Levels": "debug:http-test"}`)
+	if code != http.StatusOK {
+		t.Fatalf("PUT: expected status %d, got %d", http.StatusOK, code)
+	}
+	if cfg["Levels"] != "debug:http-test" {
+		t.Errorf("PUT: expected levels %q, got %q", "debug:http-test", cfg["Levels"])
+	}
+	if !l.DebugEnabled() {
+		t.Errorf("PUT: debugging not enabled for %s", "http-test")
+	}
+
+	code, _ = serve(t, http.MethodPut, `{"Level": "verbose"}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("invalid level: expected status %d, got %d", http.StatusBadRequest, code)
+	}
+	code, _ = serve(t, http.MethodPut, `{"Colors": "on"}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("unknown entry: expected status %d, got %d", http.StatusBadRequest, code)
+	}
+	code, _ = serve(t, http.MethodPut, `not json`)
+	if code != http.StatusBadRequest {
+		t.Errorf("malformed body: expected status %d, got %d", http.StatusBadRequest, code)
+	}
+	if !l.DebugEnabled() {
+		t.Errorf("failed update changed the configuration")
+	}
+
+	code, _ = serve(t, http.MethodDelete, "")
+	if code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: expected status %d, got %d", http.StatusMethodNotAllowed, code)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	defer restoreOptions()()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				level := []string{"debug", "info", "warn", "error"}[(i+j)%4]
+				body := fmt.Sprintf(`{"Levels": "%s:http-test-%d"}`, level, i)
+				if code, _ := serve(t, http.MethodPut, body); code != http.StatusOK {
+					t.Errorf("PUT %s: unexpected status %d", body, code)
+				}
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			l := Get(fmt.Sprintf("http-test-%d", i))
+			for j := 0; j < 50; j++ {
+				l.EnableDebug(j%2 == 0)
+				l.Debug("message %d", j)
+				l.DebugEnabled()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
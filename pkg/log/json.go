@@ -0,0 +1,108 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+//
+// JSON backend, emitting a JSON object per message
+//
+
+const (
+	jsonBackendName = "json"
+)
+
+type jsonBackend struct {
+	sync.Mutex
+	w io.Writer
+}
+
+var _ StructuredBackend = &jsonBackend{}
+
+func (j *jsonBackend) Name() string {
+	return jsonBackendName
+}
+
+func (j *jsonBackend) PrefixPreference() bool {
+	return false
+}
+
+func (j *jsonBackend) Info(message string) {
+	j.Log(LevelInfo, "", message, nil)
+}
+
+func (j *jsonBackend) Warn(message string) {
+	j.Log(LevelWarn, "", message, nil)
+}
+
+func (j *jsonBackend) Error(message string) {
+	j.Log(LevelError, "", message, nil)
+}
+
+func (j *jsonBackend) Debug(message string) {
+	j.Log(LevelDebug, "", message, nil)
+}
+
+func (j *jsonBackend) Enabled(l Level) bool {
+	logging.RLock()
+	defer logging.RUnlock()
+	return l >= opt.Level
+}
+
+// Log emits a message with its fields as a single JSON object.
+func (j *jsonBackend) Log(level Level, source, message string, fields []Field) {
+	entry := make(map[string]interface{}, len(fields)+4)
+	for _, f := range fields {
+		switch v := f.Value.(type) {
+		case error:
+			entry[f.Key] = v.Error()
+		case fmt.Stringer:
+			entry[f.Key] = v.String()
+		default:
+			entry[f.Key] = v
+		}
+	}
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	if source != "" {
+		entry["source"] = source
+	}
+	entry["msg"] = message
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{
+			"time":   entry["time"],
+			"level":  LevelError.String(),
+			"source": source,
+			"msg":    fmt.Sprintf("failed to marshal log message %q: %v", message, err),
+		})
+	}
+
+	j.Lock()
+	defer j.Unlock()
+	j.w.Write(append(data, '\n'))
+}
+
+func init() {
+	RegisterBackend(&jsonBackend{w: os.Stdout})
+}
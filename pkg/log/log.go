@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // Level is the log message severity level below which we suppress messages.
@@ -52,6 +54,8 @@ type Logger interface {
 	WarnBlock(prefix string, format string, args ...interface{})
 	ErrorBlock(prefix string, format string, args ...interface{})
 
+	With(keyValues ...interface{}) Logger
+
 	Stop()
 }
 
@@ -67,17 +71,36 @@ type Backend interface {
 	Debug(message string)
 }
 
+// StructuredBackend is a Backend which emits the key-value fields of messages itself.
+type StructuredBackend interface {
+	Backend
+	Log(level Level, source, message string, fields []Field)
+}
+
+// Field is a key-value pair attached to log messages.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
 // Our logger instance.
 type logger struct {
-	source  string // logger source/module name
-	enabled bool   // logger source module
-	level   Level  // first non-suppressed severity level
-	debug   bool   // debugging for this instance
-	prefix  string // message prefix
+	source  string  // logger source/module name
+	enabled bool    // logger source module
+	level   Level   // first non-suppressed severity level
+	debug   bool    // debugging for this instance
+	prefix  string  // message prefix
+	fields  []Field // key-value fields attached to messages
+	base    *logger // logger we were derived from using With()
 }
 
 // log is our runtime state.
+//
+// The lock protects the runtime configuration (opt), the registered loggers
+// and their state, and the active backend. Configuration updates take it for
+// writing, emitting messages for reading.
 type log struct {
+	sync.RWMutex
 	level    Level              // lowest unsuppressed severity
 	active   Backend            // active backend
 	loggers  map[string]*logger // running loggers (log sources)
@@ -91,7 +114,9 @@ var logging = &log{
 
 // Get an existing logger or create a new one.
 func Get(source string) Logger {
+	logging.RLock()
 	l, ok := logging.loggers[source]
+	logging.RUnlock()
 	if !ok {
 		return newLogger(source)
 	}
@@ -107,6 +132,9 @@ func NewLogger(source string) Logger {
 func newLogger(source string) Logger {
 	source = strings.Trim(source, "[] ")
 
+	logging.Lock()
+	defer logging.Unlock()
+
 	if logging.loggers == nil {
 		logging.loggers = make(map[string]*logger)
 	}
@@ -119,48 +147,132 @@ func newLogger(source string) Logger {
 		source:  source,
 		enabled: opt.sourceEnabled(source),
 		debug:   opt.debugEnabled(source),
-		level:   opt.Level,
+		level:   opt.sourceLevel(source),
 	}
 	logging.loggers[source] = l
 
+	if len(source) > logging.srcalign {
+		logging.srcalign = len(source)
+		for _, l := range logging.loggers {
+			l.prefix = ""
+		}
+	}
+	for _, l := range logging.loggers {
+		if l.prefix == "" {
+			l.prefix = sourcePrefix(l.source, logging.srcalign)
+		}
+	}
+
 	return l
 }
 
+// sourcePrefix returns the message prefix for a source, centered to the given width.
+func sourcePrefix(source string, width int) string {
+	suf := (width - len(source)) / 2
+	pre := width - (len(source) + suf)
+	return "[" + fmt.Sprintf("%-*s", pre, "") + source + fmt.Sprintf("%*s", suf, "") + "] "
+}
+
 // Optional call to stop a logger once it is not needed any more.
 func (l *logger) Stop() {
+	if l.base != nil {
+		return
+	}
+	logging.Lock()
+	defer logging.Unlock()
 	l.enabled = false
 	delete(logging.loggers, l.source)
 }
 
-func (l *logger) shouldPrefix() bool {
-	return logging.active.PrefixPreference()
+// With returns a logger which attaches the given key-value pairs to all messages.
+func (l *logger) With(keyValues ...interface{}) Logger {
+	fields := make([]Field, len(l.fields), len(l.fields)+(len(keyValues)+1)/2)
+	copy(fields, l.fields)
+	for i := 0; i < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
+		if i+1 < len(keyValues) {
+			fields = append(fields, Field{Key: key, Value: keyValues[i+1]})
+		} else {
+			fields = append(fields, Field{Key: key, Value: "<missing>"})
+		}
+	}
+
+	return &logger{
+		source: l.source,
+		fields: fields,
+		base:   l.root(),
+	}
+}
+
+// root returns the logger holding the runtime state of our source.
+func (l *logger) root() *logger {
+	if l.base != nil {
+		return l.base
+	}
+	return l
 }
 
 func (l *logger) passthrough(level Level) bool {
-	return (l.enabled && l.level <= level) || (level == LevelDebug && l.debug)
+	r := l.root()
+	logging.RLock()
+	defer logging.RUnlock()
+	return (r.enabled && r.level <= level) || (level == LevelDebug && r.debug)
 }
 
-func (l *logger) formatMessage(format string, args ...interface{}) string {
-	if len(l.source) > logging.srcalign {
-		logging.srcalign = len(l.source)
-		l.prefix = ""
-		for _, l := range logging.loggers {
-			l.prefix = ""
-		}
+func (l *logger) debugEnabled() bool {
+	logging.RLock()
+	defer logging.RUnlock()
+	return l.root().debug
+}
 
+func (l *logger) formatMessage(active Backend, format string, args ...interface{}) string {
+	prefix := ""
+	if active.PrefixPreference() {
+		logging.RLock()
+		prefix = l.root().prefix
+		logging.RUnlock()
 	}
-	if l.prefix == "" {
-		suf := (logging.srcalign - len(l.source)) / 2
-		pre := logging.srcalign - (len(l.source) + suf)
-		l.prefix = "[" + fmt.Sprintf("%-*s", pre, "") + l.source + fmt.Sprintf("%*s", suf, "") + "] "
+
+	return prefix + fmt.Sprintf(format, args...) + formatFields(l.fields)
+}
+
+// formatFields formats key-value fields for backends which don't do it themselves.
+func formatFields(fields []Field) string {
+	str := ""
+	for _, f := range fields {
+		value := fmt.Sprint(f.Value)
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		str += " " + f.Key + "=" + value
 	}
+	return str
+}
 
-	prefix := ""
-	if l.shouldPrefix() {
-		prefix = l.prefix
+// emit passes a message of the given severity to the active backend.
+func (l *logger) emit(level Level, format string, args ...interface{}) string {
+	logging.RLock()
+	active := logging.active
+	logging.RUnlock()
+
+	if b, ok := active.(StructuredBackend); ok {
+		message := fmt.Sprintf(format, args...)
+		b.Log(level, l.source, message, l.fields)
+		return message
 	}
 
-	return prefix + fmt.Sprintf(format, args...)
+	message := l.formatMessage(active, format, args...)
+	switch level {
+	case LevelDebug:
+		active.Debug(message)
+	case LevelInfo:
+		active.Info(message)
+	case LevelWarn:
+		active.Warn(message)
+	default:
+		active.Error(message)
+	}
+	return message
 }
 
 // Emit an info message (lowest priority).
@@ -168,7 +280,7 @@ func (l *logger) Info(format string, args ...interface{}) {
 	if !l.passthrough(LevelInfo) {
 		return
 	}
-	logging.active.Info(l.formatMessage(format, args...))
+	l.emit(LevelInfo, format, args...)
 }
 
 // Emit a warning message.
@@ -176,7 +288,7 @@ func (l *logger) Warn(format string, args ...interface{}) {
 	if !l.passthrough(LevelWarn) {
 		return
 	}
-	logging.active.Warn(l.formatMessage(format, args...))
+	l.emit(LevelWarn, format, args...)
 }
 
 // Emit an error message.
@@ -184,20 +296,18 @@ func (l *logger) Error(format string, args ...interface{}) {
 	if !l.passthrough(LevelError) {
 		return
 	}
-	logging.active.Error(l.formatMessage(format, args...))
+	l.emit(LevelError, format, args...)
 }
 
 // Emit a fatal error message and exit.
 func (l *logger) Fatal(format string, args ...interface{}) {
-	logging.active.Error(l.formatMessage(format, args...))
+	l.emit(LevelError, format, args...)
 	os.Exit(1)
 }
 
 // Emit a fatal error message and panic.
 func (l *logger) Panic(format string, args ...interface{}) {
-	message := l.formatMessage(format, args...)
-	logging.active.Error(message)
-	panic(message)
+	panic(l.emit(LevelError, format, args...))
 }
 
 // Default logger/source.
@@ -238,6 +348,11 @@ func Debug(format string, args ...interface{}) {
 	defLogger.Debug(format, args...)
 }
 
+// With returns a logger for the default source which attaches the given key-value pairs.
+func With(keyValues ...interface{}) Logger {
+	return defLogger.With(keyValues...)
+}
+
 // EnableDebug controls debugging for the default source.
 func EnableDebug(enable bool) bool {
 	return defLogger.EnableDebug(enable)
@@ -275,22 +390,32 @@ func ErrorBlock(prefix string, format string, args ...interface{}) {
 
 // EnableDebug controls debugging for the logger and returns the its previous debugging state.
 func (l *logger) EnableDebug(enable bool) bool {
-	previous := l.debug
-	opt.Debug.Set(l.source + ":" + map[bool]string{false: "false", true: "true"}[enable])
+	logging.Lock()
+	defer logging.Unlock()
+
+	previous := l.root().debug
+	debug := make(stateMap, len(opt.Debug)+1)
+	for source, state := range opt.Debug {
+		debug[source] = state
+	}
+	debug[l.source] = enable
+	opt.Debug = debug
+	opt.applyLocked()
+
 	return previous
 }
 
 // Check if debugging is enabled.
 func (l *logger) DebugEnabled() bool {
-	return l.debug
+	return l.debugEnabled()
 }
 
 // Emit a debug message.
 func (l *logger) Debug(format string, args ...interface{}) {
-	if !l.debug {
+	if !l.debugEnabled() {
 		return
 	}
-	logging.active.Debug(l.formatMessage(format, args...))
+	l.emit(LevelDebug, format, args...)
 }
 
 // Block emits a block of messages with using the given emitting function.
@@ -302,7 +427,7 @@ func (l *logger) Block(fn func(string, ...interface{}), prefix string, format st
 
 // Emit a block of debug messages.
 func (l *logger) DebugBlock(prefix string, format string, args ...interface{}) {
-	if !l.debug {
+	if !l.debugEnabled() {
 		return
 	}
 
@@ -328,6 +453,9 @@ func (l *logger) ErrorBlock(prefix string, format string, args ...interface{}) {
 func RegisterBackend(b Backend) {
 	name := b.Name()
 
+	logging.Lock()
+	defer logging.Unlock()
+
 	if logging.backends == nil {
 		logging.backends = make(map[string]Backend)
 	}
@@ -341,21 +469,46 @@ func RegisterBackend(b Backend) {
 
 // activateBackend selects the logger backend to activate.
 func activateBackend(name string) {
-	if b, ok := logging.backends[string(opt.Logger)]; ok {
-		logging.active = b
-	} else {
-		logging.active = &fmtBackend{}
+	logging.Lock()
+	b, ok := logging.backends[name]
+	if !ok {
+		b = &fmtBackend{}
 	}
+	logging.active = b
+	logging.Unlock()
 
-	logging.active.Info(fmt.Sprintf("activated logger backend '%s'", logging.active.Name()))
+	b.Info(fmt.Sprintf("activated logger backend '%s'", b.Name()))
 }
 
 // Update loggers when debug flags or sources change.
 func (o *options) updateLoggers() {
+	configure(nil)
+}
+
+// configure updates the runtime configuration and the loggers, with the lock held.
+func configure(update func(o *options)) {
+	logging.Lock()
+	defer logging.Unlock()
+	if update != nil {
+		update(opt)
+	}
+	opt.applyLocked()
+}
+
+// currentOptions returns a copy of the runtime configuration.
+func currentOptions() *options {
+	logging.RLock()
+	defer logging.RUnlock()
+	o := *opt
+	return &o
+}
+
+// applyLocked updates loggers to the runtime configuration, with the lock held.
+func (o *options) applyLocked() {
 	for s, l := range logging.loggers {
 		l.enabled = opt.sourceEnabled(s)
 		l.debug = opt.debugEnabled(s)
-		l.level = opt.Level
+		l.level = opt.sourceLevel(s)
 	}
 }
 
@@ -396,6 +549,8 @@ func (f *fmtBackend) Debug(message string) {
 }
 
 func (f *fmtBackend) Enabled(l Level) bool {
+	logging.RLock()
+	defer logging.RUnlock()
 	return l >= opt.Level
 }
 
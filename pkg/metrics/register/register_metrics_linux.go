@@ -0,0 +1,11 @@
+//go:build linux
+// +build linux
+
+package register
+
+import (
+	// Pull in avx collector.
+	_ "github.com/intel/cri-resource-manager/pkg/avx"
+	// Pull in perf counter collector.
+	_ "github.com/intel/cri-resource-manager/pkg/perf"
+)
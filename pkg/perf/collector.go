@@ -0,0 +1,253 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package perf
+
+import (
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// LLCMissesName is the Prometheus Counter name for per cgroup last-level cache misses.
+	LLCMissesName = "cgroup_llc_misses"
+)
+
+var descriptor = prometheus.NewDesc(
+	LLCMissesName,
+	"Number of last-level cache read misses in a particular cgroup.",
+	[]string{
+		"cgroup",
+	}, nil,
+)
+
+var (
+	// enabled is true if per cgroup perf counter sampling is enabled.
+	enabled = false
+	// perfCgroupRoot is the mount point of the perf_event cgroup hierarchy.
+	perfCgroupRoot = "/sys/fs/cgroup/perf_event"
+	// cpusetCgroupRoot is the mount point of the cgroup v1 cpuset hierarchy.
+	cpusetCgroupRoot = "/sys/fs/cgroup/cpuset"
+	// containerDirRe matches the names of cgroup directories of containers.
+	containerDirRe = regexp.MustCompile(`(?:^|[-.])[0-9a-f]{64}(?:\.scope)?$`)
+
+	// our logger instance
+	log = logger.NewLogger("perf")
+)
+
+// cgroupCounters are the per-CPU LLC miss counters opened for a single cgroup.
+//
+// Counters are only opened for the CPUs the cgroup is allowed to run on, and
+// they are reopened when that changes. base accumulates the counts of closed
+// counters, to keep the total monotonic.
+type cgroupCounters struct {
+	fds  []int
+	cpus string
+	base uint64
+}
+
+type collector struct {
+	sync.Mutex
+	cgroups map[string]*cgroupCounters // counters by cgroup path
+}
+
+// NewCollector creates a new Prometheus collector for per cgroup perf counters.
+func NewCollector() (prometheus.Collector, error) {
+	return &collector{cgroups: make(map[string]*cgroupCounters)}, nil
+}
+
+// Describe implements prometheus.Collector interface
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descriptor
+}
+
+// Collect implements prometheus.Collector interface
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	if !enabled {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	seen := map[string]struct{}{}
+	for _, path := range walkCgroups() {
+		seen[path] = struct{}{}
+		cpus := cgroupCPUs(path)
+		cc, ok := c.cgroups[path]
+		if !ok || cc.cpus != cpus {
+			base := uint64(0)
+			if ok {
+				base = cc.read()
+				cc.close()
+				delete(c.cgroups, path)
+			}
+			var err error
+			if cc, err = openCounters(path, cpus); err != nil {
+				log.Debug("failed to open LLC miss counters for cgroup %s: %v", path, err)
+				continue
+			}
+			cc.base = base
+			c.cgroups[path] = cc
+		}
+		ch <- prometheus.MustNewConstMetric(
+			descriptor,
+			prometheus.CounterValue,
+			float64(cc.read()),
+			path,
+		)
+	}
+
+	for path, cc := range c.cgroups {
+		if _, ok := seen[path]; !ok {
+			cc.close()
+			delete(c.cgroups, path)
+		}
+	}
+}
+
+// openCounters opens per-CPU LLC read miss counters for the given cgroup and CPUs.
+//
+// If the CPUs are not known, counters are opened for all CPUs.
+func openCounters(path, cpus string) (*cgroupCounters, error) {
+	dir, err := os.Open(filepath.Join(perfCgroupRoot, path))
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+
+	attr := &unix.PerfEventAttr{
+		Type: unix.PERF_TYPE_HW_CACHE,
+		Config: unix.PERF_COUNT_HW_CACHE_LL |
+			unix.PERF_COUNT_HW_CACHE_OP_READ<<8 |
+			unix.PERF_COUNT_HW_CACHE_RESULT_MISS<<16,
+		Size: uint32(unsafe.Sizeof(unix.PerfEventAttr{})),
+	}
+
+	cc := &cgroupCounters{cpus: cpus}
+	for _, cpu := range counterCPUs(cpus) {
+		fd, err := unix.PerfEventOpen(attr, int(dir.Fd()), cpu, -1,
+			unix.PERF_FLAG_PID_CGROUP|unix.PERF_FLAG_FD_CLOEXEC)
+		if err != nil {
+			// offline CPUs fail with ENODEV, anything else is fatal
+			if err == unix.ENODEV {
+				continue
+			}
+			cc.close()
+			return nil, err
+		}
+		cc.fds = append(cc.fds, fd)
+	}
+
+	return cc, nil
+}
+
+// counterCPUs returns the CPUs to open counters on for the given cpuset.
+func counterCPUs(cpus string) []int {
+	if cpus != "" {
+		if cset, err := cpuset.Parse(cpus); err == nil && !cset.IsEmpty() {
+			return cset.ToSlice()
+		}
+	}
+	all := make([]int, 0, runtime.NumCPU())
+	for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+		all = append(all, cpu)
+	}
+	return all
+}
+
+// cgroupCPUs returns the CPUs the tasks of a cgroup are allowed to run on, empty if unknown.
+func cgroupCPUs(path string) string {
+	for _, file := range []string{
+		filepath.Join(cpusetCgroupRoot, path, "cpuset.effective_cpus"),
+		filepath.Join(perfCgroupRoot, path, "cpuset.cpus.effective"),
+	} {
+		if data, err := ioutil.ReadFile(file); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// read returns the sum of the per-CPU counters.
+func (cc *cgroupCounters) read() uint64 {
+	total := cc.base
+	buf := make([]byte, 8)
+	for _, fd := range cc.fds {
+		if n, err := unix.Read(fd, buf); err == nil && n == len(buf) {
+			total += binary.LittleEndian.Uint64(buf)
+		}
+	}
+	return total
+}
+
+// close closes the per-CPU counters.
+func (cc *cgroupCounters) close() {
+	for _, fd := range cc.fds {
+		unix.Close(fd)
+	}
+	cc.fds = nil
+}
+
+// walkCgroups returns the perf_event cgroup directories of all containers.
+func walkCgroups() []string {
+	containerDirs := []string{}
+
+	for _, kubepods := range cgroups.KubepodsDirs {
+		filepath.Walk(filepath.Join(perfCgroupRoot, kubepods),
+			func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.IsDir() {
+					return nil
+				}
+				if !containerDirRe.MatchString(info.Name()) {
+					return nil
+				}
+				containerDirs = append(containerDirs, strings.TrimPrefix(path, perfCgroupRoot))
+				return filepath.SkipDir
+			})
+	}
+
+	return containerDirs
+}
+
+func init() {
+	flag.BoolVar(&enabled, "perf-llc-misses", enabled,
+		"Sample last-level cache misses of containers using per cgroup perf counters.")
+	flag.StringVar(&perfCgroupRoot, "perf-cgroup-path", perfCgroupRoot,
+		"Path to the perf_event cgroup hierarchy mountpoint")
+
+	err := metrics.RegisterCollector("perf", NewCollector)
+	if err != nil {
+		log.Error("failed register perf collector: %v", err)
+	}
+}
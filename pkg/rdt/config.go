@@ -35,8 +35,9 @@ type options struct {
 		L3Allocation rawAllocations `json:"l3Allocation"`
 		MBAllocation rawAllocations `json:"mbAllocation"`
 		Classes      map[string]struct {
-			L3Schema rawAllocations `json:"l3Schema"`
-			MBSchema rawAllocations `json:"mbSchema"`
+			L3Schema      rawAllocations `json:"l3Schema"`
+			MBSchema      rawAllocations `json:"mbSchema"`
+			MBCalibration rawAllocations `json:"mbCalibration"`
 		} `json:"classes"`
 	} `json:"partitions"`
 }
@@ -68,9 +69,10 @@ type partitionConfig struct {
 // classConfig represents configuration of one class, i.e. one CTRL group in
 // the Linux resctrl interface
 type classConfig struct {
-	Partition string
-	L3Schema  l3Schema
-	MBSchema  mbSchema
+	Partition     string
+	L3Schema      l3Schema
+	MBSchema      mbSchema
+	MBCalibration mbCalibration
 }
 
 // schemaOptions contains the common settings for all classes
@@ -87,6 +89,23 @@ type l3Options struct {
 // mbOptions contains the common settings for memory bandwidth allocation
 type mbOptions struct {
 	Optional bool
+	// Calibration is the memory bandwidth (MBps) corresponding to 100%, per
+	// cache id, used for converting between percentage and MBps allocations
+	Calibration rawAllocations `json:"calibration,omitempty"`
+	// MaxLimitGroups caps the number of resctrl groups created for per-container
+	// memory bandwidth limits, each of which uses up a CLOS ID
+	MaxLimitGroups int `json:"maxLimitGroups,omitempty"`
+}
+
+// defaultMaxMBLimitGroups is the default cap of memory bandwidth limited groups
+const defaultMaxMBLimitGroups = 4
+
+// maxLimitGroups returns the maximum number of memory bandwidth limited groups
+func (o mbOptions) maxLimitGroups() int {
+	if o.MaxLimitGroups > 0 {
+		return o.MaxLimitGroups
+	}
+	return defaultMaxMBLimitGroups
 }
 
 // l3Schema represents the L3 part of the schemata of a class (i.e. resctrl group)
@@ -95,6 +114,10 @@ type l3Schema map[uint64]l3Allocation
 // mbSchema represents the MB part of the schemata of a class (i.e. resctrl group)
 type mbSchema map[uint64]uint64
 
+// mbCalibration represents the memory bandwidth (MBps) of 100% MB allocation,
+// per cache id
+type mbCalibration map[uint64]uint64
+
 // l3Allocation describes the L3 allocation configuration for one cache id
 type l3Allocation struct {
 	Unified cacheAllocation
@@ -193,12 +216,35 @@ func (a l3Allocation) set(typ l3SchemaType, v cacheAllocation) l3Allocation {
 		a.Code = v
 	case l3SchemaTypeData:
 		a.Data = v
+	default:
+		a.Unified = v
 	}
-	a.Unified = v
 
 	return a
 }
 
+// cdp returns true if the allocation has separate code and data parts
+func (a l3Allocation) cdp() bool {
+	return a.Code != nil || a.Data != nil
+}
+
+// cdp returns true if the schema has separate code and data parts for any cache id
+func (s l3Schema) cdp() bool {
+	for _, a := range s {
+		if a.cdp() {
+			return true
+		}
+	}
+	return false
+}
+
+// unified drops the separate code and data parts from the schema
+func (s l3Schema) unified() {
+	for id, a := range s {
+		s[id] = l3Allocation{Unified: a.Unified}
+	}
+}
+
 func (a l3Allocation) getEffective(typ l3SchemaType) cacheAllocation {
 	switch typ {
 	case l3SchemaTypeCode:
@@ -402,9 +448,54 @@ func (raw options) resolve() (config, error) {
 		return conf, err
 	}
 
+	if err = conf.checkCDP(); err != nil {
+		return conf, err
+	}
+
 	return conf, nil
 }
 
+// checkCDP checks that separate code and data allocations are only used if
+// the system has CDP (Code and Data Prioritization) enabled. If L3 allocation
+// is optional, code and data allocations are downgraded to unified ones.
+func (c config) checkCDP() error {
+	if rdtInfo.cdpEnabled() || !rdtInfo.l3.Supported() {
+		return nil
+	}
+
+	users := []string{}
+	for name, partition := range c.Partitions {
+		if partition.L3.cdp() {
+			users = append(users, "partition "+name)
+		}
+	}
+	for name, class := range c.Classes {
+		if class.L3Schema.cdp() {
+			users = append(users, "class "+name)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	sort.Strings(users)
+	if !c.Options.L3.Optional {
+		return rdtError("code/data L3 allocation specified (%s) but CDP not enabled in the system",
+			strings.Join(users, ", "))
+	}
+
+	log.Warn("CDP not enabled in the system, using unified L3 allocation for %s",
+		strings.Join(users, ", "))
+	for _, partition := range c.Partitions {
+		partition.L3.unified()
+	}
+	for _, class := range c.Classes {
+		class.L3Schema.unified()
+	}
+
+	return nil
+}
+
 // resolvePartitions tries to resolve the requested resource allocations of
 // partitions
 func (raw options) resolvePartitions() (partitionSet, error) {
@@ -632,9 +723,14 @@ func (s partitionSet) resolveCacheIDAbsolute(id uint64, partitions []l3Partition
 
 // resolveMBPartitions tries to resolve requested MB allocations between partitions
 func (raw options) resolveMBPartitions(conf partitionSet) error {
+	calibration, err := raw.Options.MB.Calibration.parseMBCalibration(nil)
+	if err != nil {
+		return fmt.Errorf("failed to resolve MB calibration: %v", err)
+	}
+
 	// We use percentage values directly from the raw conf
 	for name, partition := range raw.Partitions {
-		allocations, err := partition.MBAllocation.parseMB()
+		allocations, err := partition.MBAllocation.parseMB(calibration)
 		if err != nil {
 			return fmt.Errorf("failed to resolve MB allocation for partition %q: %v", name, err)
 		}
@@ -654,6 +750,11 @@ func (raw options) resolveMBPartitions(conf partitionSet) error {
 func (raw options) resolveClasses() (classSet, error) {
 	classes := make(classSet)
 
+	calibration, err := raw.Options.MB.Calibration.parseMBCalibration(nil)
+	if err != nil {
+		return classes, fmt.Errorf("failed to resolve MB calibration: %v", err)
+	}
+
 	for bname, partition := range raw.Partitions {
 		for gname, class := range partition.Classes {
 			if _, ok := classes[gname]; ok {
@@ -671,7 +772,12 @@ func (raw options) resolveClasses() (classSet, error) {
 				return classes, fmt.Errorf("L3 allocation missing from partition %q but class %q specifies L3 schema", bname, gname)
 			}
 
-			gc.MBSchema, err = class.MBSchema.parseMB()
+			gc.MBCalibration, err = class.MBCalibration.parseMBCalibration(calibration)
+			if err != nil {
+				return classes, fmt.Errorf("failed to resolve MB calibration for class %q: %v", gname, err)
+			}
+
+			gc.MBSchema, err = class.MBSchema.parseMB(gc.MBCalibration)
 			if err != nil {
 				return classes, fmt.Errorf("failed to resolve MB allocation for class %q: %v", gname, err)
 			}
@@ -727,7 +833,7 @@ func (raw rawAllocations) parseL3() (l3Schema, error) {
 }
 
 // parseMB parses a raw MB allocation
-func (raw rawAllocations) parseMB() (mbSchema, error) {
+func (raw rawAllocations) parseMB(calibration mbCalibration) (mbSchema, error) {
 	rawValues, err := raw.rawParse(map[string]interface{}{}, false)
 	if err != nil || rawValues == nil {
 		return nil, err
@@ -739,7 +845,7 @@ func (raw rawAllocations) parseMB() (mbSchema, error) {
 		if !ok {
 			return nil, fmt.Errorf("not a string value %q", rawVal)
 		}
-		allocations[id], err = parseMBAllocation(strList)
+		allocations[id], err = parseMBAllocation(strList, calibration[id])
 		if err != nil {
 			return nil, err
 		}
@@ -748,6 +854,41 @@ func (raw rawAllocations) parseMB() (mbSchema, error) {
 	return allocations, nil
 }
 
+// parseMBCalibration parses a raw MB calibration, overriding the given defaults
+func (raw rawAllocations) parseMBCalibration(defaults mbCalibration) (mbCalibration, error) {
+	rawValues, err := raw.rawParse("", false)
+	if err != nil || rawValues == nil {
+		return defaults, err
+	}
+
+	calibration := make(mbCalibration, len(rawValues))
+	for id, value := range defaults {
+		calibration[id] = value
+	}
+	for id, rawVal := range rawValues {
+		strVal, ok := rawVal.(string)
+		if !ok {
+			return nil, fmt.Errorf("not a string value %q", rawVal)
+		}
+		if strVal == "" {
+			continue
+		}
+		if !strings.HasSuffix(strVal, mbSuffixMbps) {
+			return nil, fmt.Errorf("%q not a MBps value", strVal)
+		}
+		value, err := strconv.ParseUint(strings.TrimSuffix(strVal, mbSuffixMbps), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		if value == 0 {
+			return nil, fmt.Errorf("invalid zero MB calibration %q", strVal)
+		}
+		calibration[id] = value
+	}
+
+	return calibration, nil
+}
+
 // rawParse "pre-parses" the rawAllocations per each cache id. I.e. it assigns
 // a raw (string) allocation for each cache id
 func (raw rawAllocations) rawParse(defaultVal interface{}, initEmpty bool) (map[uint64]interface{}, error) {
@@ -911,38 +1052,59 @@ func parseCacheAllocation(data string) (cacheAllocation, error) {
 	return l3AbsoluteAllocation(value), nil
 }
 
-// parseMBAllocation parses a generic string map into MB allocation value
-func parseMBAllocation(raw []interface{}) (uint64, error) {
+// parseMBAllocation parses a generic string map into MB allocation value. If
+// no value for the active MBA mode is given, a value for the other mode is
+// converted using the given calibration (MBps of 100%), if any.
+func parseMBAllocation(raw []interface{}, calibration uint64) (uint64, error) {
+	var pct, mbps uint64
+	var havePct, haveMbps bool
+
 	for _, v := range raw {
 		strVal, ok := v.(string)
 		if !ok {
 			return 0, fmt.Errorf("not a string value %q", v)
 		}
 		if strings.HasSuffix(strVal, mbSuffixPct) {
-			if !rdtInfo.mb.mbpsEnabled {
-				value, err := strconv.ParseUint(strings.TrimSuffix(strVal, mbSuffixPct), 10, 7)
-				if err != nil {
-					return 0, err
-				}
-				return value, nil
+			value, err := strconv.ParseUint(strings.TrimSuffix(strVal, mbSuffixPct), 10, 7)
+			if err != nil {
+				return 0, err
 			}
+			pct, havePct = value, true
 		} else if strings.HasSuffix(strVal, mbSuffixMbps) {
-			if rdtInfo.mb.mbpsEnabled {
-				value, err := strconv.ParseUint(strings.TrimSuffix(strVal, mbSuffixMbps), 10, 32)
-				if err != nil {
-					return 0, err
-				}
-				return value, nil
+			value, err := strconv.ParseUint(strings.TrimSuffix(strVal, mbSuffixMbps), 10, 32)
+			if err != nil {
+				return 0, err
 			}
+			mbps, haveMbps = value, true
 		} else {
 			log.Warn("unrecognized MBA allocation format in %q", strVal)
 		}
 	}
 
-	// No value for the active mode was specified
 	if rdtInfo.mb.mbpsEnabled {
+		switch {
+		case haveMbps:
+			return mbps, nil
+		case havePct && calibration > 0:
+			return pct * calibration / 100, nil
+		}
 		return 0, fmt.Errorf("missing 'MBps' value from mbSchema; required because 'mba_MBps' is enabled in the system")
 	}
+
+	switch {
+	case havePct:
+		return pct, nil
+	case haveMbps && calibration > 0:
+		// Round up, never going below 1% or above 100%
+		value := (mbps*100 + calibration - 1) / calibration
+		if value < 1 {
+			value = 1
+		}
+		if value > 100 {
+			value = 100
+		}
+		return value, nil
+	}
 	return 0, fmt.Errorf("missing '%%' value from mbSchema; required because percentage-based MBA allocation is enabled in the system")
 }
 
@@ -954,7 +1116,20 @@ func defaultOptions() interface{} {
 	return &options{}
 }
 
+// validateOptions checks that the given options resolve to a working configuration.
+func validateOptions(o interface{}) error {
+	// without discovered RDT info we can't tell what's valid for this system
+	if rdtControl == nil {
+		return nil
+	}
+	if _, err := o.(*options).resolve(); err != nil {
+		return fmt.Errorf("failed to resolve RDT configuration: %v", err)
+	}
+	return nil
+}
+
 // Register us for configuration handling.
 func init() {
-	pkgcfg.Register("rdt", "RDT control", opt, defaultOptions)
+	pkgcfg.Register("rdt", "RDT control", opt, defaultOptions,
+		pkgcfg.WithValidator(validateOptions))
 }
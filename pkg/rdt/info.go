@@ -35,6 +35,7 @@ type Info struct {
 	l3code      l3Info
 	l3data      l3Info
 	mb          mbInfo
+	l3mon       l3MonInfo
 }
 
 type l3Info struct {
@@ -50,6 +51,11 @@ type mbInfo struct {
 	mbpsEnabled   bool // true if MBA_MBps is enabled
 }
 
+type l3MonInfo struct {
+	numRmids    uint64
+	monFeatures []string
+}
+
 // l3Info is a helper method for a "unified API" for getting L3 information
 func (i Info) l3Info() l3Info {
 	switch {
@@ -61,6 +67,11 @@ func (i Info) l3Info() l3Info {
 	return rdtInfo.l3
 }
 
+// cdpEnabled returns true if L3 CDP (Code and Data Prioritization) is enabled
+func (i Info) cdpEnabled() bool {
+	return i.l3code.Supported() && i.l3data.Supported()
+}
+
 func (i Info) l3CbmMask() Bitmask {
 	mask := i.l3Info().cbmMask
 	if mask != 0 {
@@ -115,6 +126,14 @@ func getRdtInfo(resctrlpath string) (Info, error) {
 		}
 	}
 
+	l3monpath := filepath.Join(infopath, "L3_MON")
+	if _, err = os.Stat(l3monpath); err == nil {
+		info.l3mon, err = getL3MonInfo(l3monpath)
+		if err != nil {
+			return info, rdtError("failed to get L3_MON info from %q: %v", l3monpath, err)
+		}
+	}
+
 	info.cacheIds, err = getCacheIds(resctrlpath)
 	if err != nil {
 		return info, rdtError("failed to get cache IDs: %v", err)
@@ -193,6 +212,29 @@ func (i mbInfo) Supported() bool {
 	return i.minBandwidth != 0
 }
 
+func getL3MonInfo(basepath string) (l3MonInfo, error) {
+	var err error
+	info := l3MonInfo{}
+
+	info.numRmids, err = readFileUint64(filepath.Join(basepath, "num_rmids"))
+	if err != nil {
+		return info, err
+	}
+
+	features, err := readFileString(filepath.Join(basepath, "mon_features"))
+	if err != nil {
+		return info, err
+	}
+	info.monFeatures = strings.Fields(features)
+
+	return info, nil
+}
+
+// Supported returns true if L3 monitoring is supported and enabled in the system
+func (i l3MonInfo) Supported() bool {
+	return i.numRmids != 0 && len(i.monFeatures) > 0
+}
+
 func getCacheIds(basepath string) ([]uint64, error) {
 	var ids []uint64
 
@@ -0,0 +1,439 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rdt
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+const (
+	// monGroupsDir is the directory of the monitoring groups of a resctrl group
+	monGroupsDir = "mon_groups"
+)
+
+const (
+	// MonPriorityLow is the monitoring group priority of best-effort workloads
+	MonPriorityLow = iota
+	// MonPriorityMedium is the monitoring group priority of burstable workloads
+	MonPriorityMedium
+	// MonPriorityHigh is the monitoring group priority of guaranteed workloads
+	MonPriorityHigh
+)
+
+// errNoRMIDs is returned when a monitoring group can't be created for lack of RMIDs
+var errNoRMIDs = errors.New("out of RMIDs")
+
+// makeMonGroupDir creates a monitoring group directory, overridable for testing
+var makeMonGroupDir = os.Mkdir
+
+// removeMonGroupDir removes a monitoring group directory, overridable for testing
+var removeMonGroupDir = os.Remove
+
+// MonGroup describes a monitoring group, typically the processes of a pod.
+// A monitoring group is created under each RDT class it has processes in,
+// and its monitoring data is aggregated over all of them.
+type MonGroup struct {
+	// ID identifies the group, it is also used to name the resctrl directories
+	ID string
+	// Namespace is the namespace of the pod, used to label monitoring data
+	Namespace string
+	// Pod is the name of the pod, used to label monitoring data
+	Pod string
+	// Priority decides which groups keep their RMIDs when running out of them
+	Priority int
+}
+
+// monGroup is the runtime state of a monitoring group
+type monGroup struct {
+	MonGroup
+	// parents are the resctrl groups the group exists in
+	parents map[string]struct{}
+	// evicted are the resctrl groups the group is waiting for an RMID in, with
+	// the processes to put in the group once it is restored
+	evicted map[string]pidSet
+}
+
+// pidSet is a set of processes assigned to a monitoring group
+type pidSet map[string]struct{}
+
+// add adds the given processes to the set
+func (s pidSet) add(pids ...string) {
+	for _, pid := range pids {
+		s[pid] = struct{}{}
+	}
+}
+
+// list returns the processes in the set
+func (s pidSet) list() []string {
+	pids := make([]string, 0, len(s))
+	for pid := range s {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+	return pids
+}
+
+func newMonGroup(group MonGroup) *monGroup {
+	return &monGroup{
+		MonGroup: group,
+		parents:  make(map[string]struct{}),
+		evicted:  make(map[string]pidSet),
+	}
+}
+
+// SetProcessMonGroup assigns a set of processes of a RDT class, optionally
+// with a memory bandwidth limit, to a monitoring group
+func (r *control) SetProcessMonGroup(class, limit string, group MonGroup, pids ...string) error {
+	if !r.MonSupported() {
+		return rdtError("RDT monitoring not supported")
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if _, ok := r.conf.Classes[class]; !ok {
+		return rdtError("unknown RDT class %q", class)
+	}
+	parent := class
+	if limit != "" {
+		// processes are left in the class itself if their limit group could not be set up
+		name := mbLimitGroupName(class, limit)
+		if _, ok := r.mbLimits[name]; ok {
+			parent = name
+		}
+	}
+
+	r.monLock.Lock()
+	defer r.monLock.Unlock()
+
+	r.restoreEvictedMonGroups()
+
+	g, ok := r.monGroups[group.ID]
+	if !ok {
+		g = newMonGroup(group)
+		r.monGroups[group.ID] = g
+	} else {
+		g.MonGroup = group
+	}
+
+	if _, ok := g.parents[parent]; !ok {
+		// a group still waiting for an RMID just collects processes
+		if evicted, ok := g.evicted[parent]; ok {
+			evicted.add(pids...)
+			return nil
+		}
+		err := r.createMonGroup(g, parent)
+		if err == errNoRMIDs {
+			g.evicted[parent] = pidSet{}
+			g.evicted[parent].add(pids...)
+			r.Warn("%s, processes of monitoring group %s in class %q not monitored for now",
+				err, g.name(), parent)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		g.parents[parent] = struct{}{}
+	}
+
+	if err := r.writeTasks(r.monGroupPath(parent, g.ID), pids...); err != nil {
+		return rdtError("failed to assign processes %v to monitoring group %s in class %q: %v",
+			pids, g.name(), parent, err)
+	}
+
+	// Release RMIDs of groups left empty by processes moving between classes
+	for other := range g.parents {
+		if other == parent {
+			continue
+		}
+		tasks, err := r.getTasks(r.monGroupDirName(other, g.ID))
+		if err != nil || len(tasks) > 0 {
+			continue
+		}
+		if err := r.removeMonGroup(g, other); err != nil {
+			r.Warn("%v", err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteMonGroup deletes a monitoring group, releasing its RMIDs
+func (r *control) DeleteMonGroup(id string) error {
+	r.monLock.Lock()
+	defer r.monLock.Unlock()
+
+	g, ok := r.monGroups[id]
+	if !ok {
+		return nil
+	}
+	for parent := range g.parents {
+		if err := r.removeMonGroup(g, parent); err != nil {
+			return err
+		}
+	}
+	delete(r.monGroups, id)
+
+	r.restoreEvictedMonGroups()
+
+	return nil
+}
+
+// GetMonGroups returns the IDs of existing monitoring groups
+func (r *control) GetMonGroups() []string {
+	r.monLock.Lock()
+	defer r.monLock.Unlock()
+
+	ids := make([]string, 0, len(r.monGroups))
+	for id := range r.monGroups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GetMonGroupData reads the current monitoring data of a monitoring group,
+// aggregated over all the RDT classes the group has processes in
+func (r *control) GetMonGroupData(id string) (MonData, error) {
+	data := MonData{L3: make(map[uint64]map[string]uint64)}
+
+	if !r.MonSupported() {
+		return data, rdtError("RDT monitoring not supported")
+	}
+
+	r.monLock.Lock()
+	defer r.monLock.Unlock()
+
+	g, ok := r.monGroups[id]
+	if !ok {
+		return data, rdtError("unknown monitoring group %q", id)
+	}
+	for parent := range g.parents {
+		monDir := filepath.Join(r.monGroupPath(parent, id), "mon_data")
+		if err := readMonData(monDir, data); err != nil {
+			return data, rdtError("failed to read monitoring data of group %s in class %q: %v",
+				g.name(), parent, err)
+		}
+	}
+
+	return data, nil
+}
+
+// monGroupSnapshot returns a copy of the descriptions of all monitoring groups
+func (r *control) monGroupSnapshot() []MonGroup {
+	r.monLock.Lock()
+	defer r.monLock.Unlock()
+
+	groups := make([]MonGroup, 0, len(r.monGroups))
+	for _, g := range r.monGroups {
+		if len(g.parents) > 0 {
+			groups = append(groups, g.MonGroup)
+		}
+	}
+	return groups
+}
+
+// createMonGroup creates a monitoring group within a resctrl group. If we are
+// out of RMIDs, a single group of lower priority is evicted and errNoRMIDs is
+// still returned. The kernel keeps a released RMID in limbo until the cache
+// occupancy it tracked drains, so retrying right away would only fail again,
+// and evict another group. Instead the group waits to be restored, along with
+// the other evicted groups, by restoreEvictedMonGroups.
+func (r *control) createMonGroup(g *monGroup, parent string) error {
+	err := r.mkdirMonGroup(parent, g.ID)
+	if err == errNoRMIDs {
+		r.evictMonGroup(g.Priority)
+	}
+	return err
+}
+
+// mkdirMonGroup creates the resctrl directory of a monitoring group
+func (r *control) mkdirMonGroup(parent, id string) error {
+	path := r.monGroupPath(parent, id)
+	err := makeMonGroupDir(path, 0755)
+	switch {
+	case err == nil || os.IsExist(err):
+		return nil
+	case errors.Is(err, syscall.ENOSPC):
+		return errNoRMIDs
+	}
+	return rdtError("failed to create monitoring group %q: %v", path, r.cmdError(err))
+}
+
+// removeMonGroup removes a monitoring group from a resctrl group, moving any
+// remaining processes to the resctrl group itself
+func (r *control) removeMonGroup(g *monGroup, parent string) error {
+	path := r.monGroupPath(parent, g.ID)
+	if err := removeMonGroupDir(path); err != nil && !os.IsNotExist(err) {
+		return rdtError("failed to remove monitoring group %q: %v", path, err)
+	}
+	delete(g.parents, parent)
+	return nil
+}
+
+// evictMonGroup releases the RMID of the lowest priority monitoring group below
+// the given priority, if there is one
+func (r *control) evictMonGroup(priority int) {
+	g, parent := pickMonGroupVictim(r.monGroups, priority)
+	if g == nil {
+		return
+	}
+
+	// the group may have more processes than we put in it, for instance forked workers
+	tasks, err := r.getTasks(r.monGroupDirName(parent, g.ID))
+	if err != nil {
+		r.Warn("failed to read processes of monitoring group %s in class %q: %v",
+			g.name(), parent, err)
+	}
+	if err := r.removeMonGroup(g, parent); err != nil {
+		r.Error("failed to evict monitoring group %s: %v", g.name(), err)
+		return
+	}
+	pids := pidSet{}
+	pids.add(tasks...)
+	g.evicted[parent] = pids
+
+	r.Warn("out of RMIDs, evicted monitoring group %s in class %q", g.name(), parent)
+}
+
+// pickMonGroupVictim picks the monitoring group to evict to free an RMID for a
+// group of the given priority, preferring the lowest priority groups
+func pickMonGroupVictim(groups map[string]*monGroup, priority int) (*monGroup, string) {
+	var victim *monGroup
+	var victimParent string
+
+	for _, g := range groups {
+		if g.Priority >= priority {
+			continue
+		}
+		for parent := range g.parents {
+			switch {
+			case victim == nil:
+			case g.Priority < victim.Priority:
+			case g.Priority == victim.Priority && g.ID < victim.ID:
+			case g == victim && parent < victimParent:
+			default:
+				continue
+			}
+			victim, victimParent = g, parent
+		}
+	}
+
+	return victim, victimParent
+}
+
+// restoreEvictedMonGroups recreates evicted monitoring groups, in order of
+// priority, for as long as there are RMIDs available
+func (r *control) restoreEvictedMonGroups() {
+	evicted := make([]*monGroup, 0)
+	for _, g := range r.monGroups {
+		if len(g.evicted) > 0 {
+			evicted = append(evicted, g)
+		}
+	}
+	sort.Slice(evicted, func(i, j int) bool {
+		if evicted[i].Priority != evicted[j].Priority {
+			return evicted[i].Priority > evicted[j].Priority
+		}
+		return evicted[i].ID < evicted[j].ID
+	})
+
+	for _, g := range evicted {
+		for parent, pids := range g.evicted {
+			if err := r.mkdirMonGroup(parent, g.ID); err != nil {
+				if err == errNoRMIDs {
+					return
+				}
+				r.Error("%v", err)
+				continue
+			}
+			delete(g.evicted, parent)
+			g.parents[parent] = struct{}{}
+			if err := r.writeTasks(r.monGroupPath(parent, g.ID), pids.list()...); err != nil {
+				r.Warn("failed to restore processes of monitoring group %s in class %q: %v",
+					g.name(), parent, err)
+			}
+			r.Info("restored evicted monitoring group %s in class %q", g.name(), parent)
+		}
+	}
+}
+
+// dropMonGroupParent forgets the monitoring groups of a removed resctrl group
+func (r *control) dropMonGroupParent(parent string) {
+	r.monLock.Lock()
+	defer r.monLock.Unlock()
+
+	for _, g := range r.monGroups {
+		delete(g.parents, parent)
+		delete(g.evicted, parent)
+	}
+}
+
+// recoverMonGroups picks up existing monitoring groups left over from a previous run
+func (r *control) recoverMonGroups() error {
+	groups, err := r.getClasses()
+	if err != nil {
+		return err
+	}
+	groups = append(groups, rootClassName)
+
+	for _, parent := range groups {
+		dir := filepath.Join(r.resctrlGroupPath(parent), monGroupsDir)
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return rdtError("failed to read monitoring groups of %q: %v", parent, err)
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), resctrlGroupPrefix) {
+				continue
+			}
+			id := strings.TrimPrefix(entry.Name(), resctrlGroupPrefix)
+			g, ok := r.monGroups[id]
+			if !ok {
+				g = newMonGroup(MonGroup{ID: id, Priority: MonPriorityLow})
+				r.monGroups[id] = g
+			}
+			g.parents[parent] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// name returns a human-readable name for the monitoring group
+func (g *monGroup) name() string {
+	if g.Pod == "" {
+		return g.ID
+	}
+	return g.Namespace + "/" + g.Pod
+}
+
+func (r *control) monGroupDirName(parent, id string) string {
+	return filepath.Join(r.resctrlGroupDirName(parent), monGroupsDir, resctrlGroupPrefix+id)
+}
+
+func (r *control) monGroupPath(parent, id string) string {
+	return filepath.Join(rdtInfo.resctrlPath, r.monGroupDirName(parent, id))
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2020 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rdt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/intel/cri-resource-manager/pkg/metrics"
+)
+
+const (
+	// MonFeatureLLCOccupancy is the L3 cache occupancy (bytes) monitoring feature
+	MonFeatureLLCOccupancy = "llc_occupancy"
+	// MonFeatureMBMTotal is the total memory bandwidth (bytes) monitoring feature
+	MonFeatureMBMTotal = "mbm_total_bytes"
+	// MonFeatureMBMLocal is the local memory bandwidth (bytes) monitoring feature
+	MonFeatureMBMLocal = "mbm_local_bytes"
+)
+
+// MonData contains monitoring data of a RDT class
+type MonData struct {
+	// L3 contains L3 monitoring data, indexed by cache id and feature name
+	L3 map[uint64]map[string]uint64
+}
+
+// MonSupported returns true if RDT monitoring is supported and enabled
+func (r *control) MonSupported() bool {
+	return rdtInfo.l3mon.Supported()
+}
+
+// GetClassMonData reads the current monitoring data of a RDT class
+func (r *control) GetClassMonData(class string) (MonData, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.classMonData(class)
+}
+
+// classMonData reads the current monitoring data of a RDT class, without locking
+func (r *control) classMonData(class string) (MonData, error) {
+	data := MonData{L3: make(map[uint64]map[string]uint64)}
+
+	if !r.MonSupported() {
+		return data, rdtError("RDT monitoring not supported")
+	}
+	if _, ok := r.conf.Classes[class]; !ok {
+		return data, rdtError("unknown RDT class %q", class)
+	}
+
+	monDir := filepath.Join(r.resctrlGroupPath(class), "mon_data")
+	if err := readMonData(monDir, data); err != nil {
+		return data, rdtError("failed to read monitoring data of class %q: %v", class, err)
+	}
+
+	return data, nil
+}
+
+// readMonData reads the monitoring data in a mon_data directory, adding it to data
+func readMonData(monDir string, data MonData) error {
+	domains, err := ioutil.ReadDir(monDir)
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range domains {
+		if !strings.HasPrefix(domain.Name(), "mon_L3_") {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimPrefix(domain.Name(), "mon_L3_"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid monitoring domain %q: %v", domain.Name(), err)
+		}
+
+		values, ok := data.L3[id]
+		if !ok {
+			values = make(map[string]uint64, len(rdtInfo.l3mon.monFeatures))
+			data.L3[id] = values
+		}
+		for _, feature := range rdtInfo.l3mon.monFeatures {
+			path := filepath.Join(monDir, domain.Name(), feature)
+			value, err := readFileUint64(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %q: %v", path, err)
+			}
+			values[feature] += value
+		}
+	}
+
+	return nil
+}
+
+// Prometheus metric descriptors for RDT monitoring data, by monitoring feature
+var monDescriptors = map[string]*prometheus.Desc{
+	MonFeatureLLCOccupancy: prometheus.NewDesc(
+		"rdt_class_llc_occupancy",
+		"L3 cache occupancy of a RDT class, in bytes.",
+		[]string{"class", "cache_id"}, nil,
+	),
+	MonFeatureMBMTotal: prometheus.NewDesc(
+		"rdt_class_mbm_total_bytes",
+		"Total memory bandwidth used by a RDT class, in bytes.",
+		[]string{"class", "cache_id"}, nil,
+	),
+	MonFeatureMBMLocal: prometheus.NewDesc(
+		"rdt_class_mbm_local_bytes",
+		"Local memory bandwidth used by a RDT class, in bytes.",
+		[]string{"class", "cache_id"}, nil,
+	),
+}
+
+// Prometheus metric descriptors for pod monitoring group data, by monitoring feature
+var podMonDescriptors = map[string]*prometheus.Desc{
+	MonFeatureLLCOccupancy: prometheus.NewDesc(
+		"rdt_pod_llc_occupancy",
+		"L3 cache occupancy of a pod, in bytes.",
+		[]string{"namespace", "pod", "cache_id"}, nil,
+	),
+	MonFeatureMBMTotal: prometheus.NewDesc(
+		"rdt_pod_mbm_total_bytes",
+		"Total memory bandwidth used by a pod, in bytes.",
+		[]string{"namespace", "pod", "cache_id"}, nil,
+	),
+	MonFeatureMBMLocal: prometheus.NewDesc(
+		"rdt_pod_mbm_local_bytes",
+		"Local memory bandwidth used by a pod, in bytes.",
+		[]string{"namespace", "pod", "cache_id"}, nil,
+	),
+}
+
+// monCollector collects RDT monitoring data of the active control instance
+type monCollector struct{}
+
+// newMonCollector creates a new prometheus collector for RDT monitoring data
+func newMonCollector() (prometheus.Collector, error) {
+	return &monCollector{}, nil
+}
+
+// Describe implements prometheus.Collector interface
+func (c *monCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range monDescriptors {
+		ch <- d
+	}
+	for _, d := range podMonDescriptors {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector interface
+func (c *monCollector) Collect(ch chan<- prometheus.Metric) {
+	r := rdtControl
+	if r == nil || !r.MonSupported() {
+		return
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, class := range r.classNames() {
+		data, err := r.classMonData(class)
+		if err != nil {
+			log.Error("failed to collect monitoring data of class %q: %v", class, err)
+			continue
+		}
+		collectMonData(ch, monDescriptors, data, class)
+	}
+
+	for _, g := range r.monGroupSnapshot() {
+		if g.Pod == "" {
+			continue
+		}
+		data, err := r.GetMonGroupData(g.ID)
+		if err != nil {
+			log.Error("failed to collect monitoring data of pod %s/%s: %v", g.Namespace, g.Pod, err)
+			continue
+		}
+		collectMonData(ch, podMonDescriptors, data, g.Namespace, g.Pod)
+	}
+}
+
+// collectMonData sends monitoring data as metrics, with the given leading label values
+func collectMonData(ch chan<- prometheus.Metric, descriptors map[string]*prometheus.Desc,
+	data MonData, labels ...string) {
+	for id, values := range data.L3 {
+		cacheID := strconv.FormatUint(id, 10)
+		for feature, value := range values {
+			desc, ok := descriptors[feature]
+			if !ok {
+				continue
+			}
+			valueType := prometheus.CounterValue
+			if feature == MonFeatureLLCOccupancy {
+				valueType = prometheus.GaugeValue
+			}
+			ch <- prometheus.MustNewConstMetric(desc, valueType, float64(value),
+				append(labels, cacheID)...)
+		}
+	}
+}
+
+func init() {
+	if err := metrics.RegisterCollector("rdt", newMonCollector); err != nil {
+		log.Error("failed to register RDT monitoring collector: %v", err)
+	}
+}
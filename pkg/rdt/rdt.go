@@ -17,12 +17,14 @@ limitations under the License.
 package rdt
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 
 	pkgcfg "github.com/intel/cri-resource-manager/pkg/config"
@@ -35,6 +37,9 @@ const (
 	// rootClassName is the name we use in our config for the special class
 	// that configures the "root" resctrl group of the system
 	rootClassName = "SYSTEM_DEFAULT"
+	// mbLimitSeparator separates the class and the limit in the names of
+	// resctrl groups for memory bandwidth limited processes of a class
+	mbLimitSeparator = ".mb-"
 )
 
 // Control is the interface managing Intel RDT resources
@@ -45,22 +50,75 @@ type Control interface {
 
 	// SetProcessClass assigns a set of processes to a RDT class
 	SetProcessClass(string, ...string) error
+
+	// SetProcessMBLimit assigns a set of processes to a RDT class with a
+	// memory bandwidth limit, given as a percentage or in MBps
+	SetProcessMBLimit(string, string, ...string) error
+
+	// GetClassTasks returns the processes in a RDT class, including those
+	// in its memory bandwidth limited groups
+	GetClassTasks(string) ([]string, error)
+
+	// MonSupported returns true if RDT monitoring is supported and enabled
+	MonSupported() bool
+
+	// GetClassMonData reads the current monitoring data of a RDT class
+	GetClassMonData(string) (MonData, error)
+
+	// SetProcessMonGroup assigns a set of processes of a RDT class, with an
+	// optional memory bandwidth limit, to a monitoring group
+	SetProcessMonGroup(string, string, MonGroup, ...string) error
+
+	// DeleteMonGroup deletes a monitoring group, releasing its RMIDs
+	DeleteMonGroup(string) error
+
+	// GetMonGroups returns the IDs of existing monitoring groups
+	GetMonGroups() []string
+
+	// GetMonGroupData reads the current monitoring data of a monitoring group
+	GetMonGroupData(string) (MonData, error)
 }
 
 var rdtInfo Info
 
+// ErrTooManyMBLimits is returned when no more memory bandwidth limited groups
+// can be created
+var ErrTooManyMBLimits = errors.New("too many memory bandwidth limited groups")
+
+// rdtControl is the active Control instance, if any
+var rdtControl *control
+
 var log logger.Logger = logger.NewLogger("rdt")
 
 type control struct {
 	logger.Logger
 
-	conf config
+	lock     sync.RWMutex // protects conf and mbLimits
+	conf     config
+	mbLimits map[string]mbLimit
+
+	monLock   sync.Mutex
+	monGroups map[string]*monGroup
+}
+
+// mbLimit is a memory bandwidth limit within a class, i.e. a derived resctrl group
+type mbLimit struct {
+	class string
+	limit string
 }
 
 // NewControl returns new instance of the RDT Control interface
 func NewControl(resctrlpath string) (Control, error) {
+	if err := checkPlatform(); err != nil {
+		return nil, err
+	}
+
 	var err error
-	r := &control{Logger: log}
+	r := &control{
+		Logger:    log,
+		mbLimits:  make(map[string]mbLimit),
+		monGroups: make(map[string]*monGroup),
+	}
 
 	// Get info from the resctrl filesystem
 	rdtInfo, err = getRdtInfo(resctrlpath)
@@ -74,16 +132,36 @@ func NewControl(resctrlpath string) (Control, error) {
 		return nil, rdtError("invalid configuration: %v", err)
 	}
 
+	// Pick up memory bandwidth limited groups left over from a previous run
+	if err := r.recoverMBLimits(); err != nil {
+		return nil, err
+	}
+
+	// Pick up monitoring groups left over from a previous run
+	if err := r.recoverMonGroups(); err != nil {
+		return nil, err
+	}
+
 	if err := r.configureResctrl(r.conf); err != nil {
 		return nil, rdtError("configuration failed: %v", err)
 	}
 
 	pkgcfg.GetModule("rdt").AddNotify(r.configNotify)
 
+	rdtControl = r
+
 	return r, nil
 }
 
 func (r *control) GetClasses() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.classNames()
+}
+
+// classNames returns the sorted names of configured classes, without locking
+func (r *control) classNames() []string {
 	ret := make([]string, len(r.conf.Classes))
 
 	i := 0
@@ -96,12 +174,79 @@ func (r *control) GetClasses() []string {
 }
 
 func (r *control) SetProcessClass(class string, pids ...string) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if _, ok := r.conf.Classes[class]; !ok {
+		return rdtError("unknown RDT class %q", class)
+	}
+
+	return r.assignTasks(class, pids...)
+}
+
+func (r *control) SetProcessMBLimit(class, limit string, pids ...string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	if _, ok := r.conf.Classes[class]; !ok {
 		return rdtError("unknown RDT class %q", class)
 	}
 
-	path := filepath.Join(r.resctrlGroupPath(class), "tasks")
-	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	name := mbLimitGroupName(class, limit)
+	if _, ok := r.mbLimits[name]; !ok {
+		if max := r.conf.Options.MB.maxLimitGroups(); len(r.mbLimits) >= max {
+			return rdtError("can't set up memory bandwidth limit %q of class %q, %d in use: %w",
+				limit, class, max, ErrTooManyMBLimits)
+		}
+		l := mbLimit{class: class, limit: limit}
+		if err := r.configureMBLimit(name, l, r.conf); err != nil {
+			return rdtError("failed to set up memory bandwidth limit %q of class %q: %v",
+				limit, class, err)
+		}
+		r.mbLimits[name] = l
+	}
+
+	return r.assignTasks(name, pids...)
+}
+
+func (r *control) GetClassTasks(class string) ([]string, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if _, ok := r.conf.Classes[class]; !ok {
+		return nil, rdtError("unknown RDT class %q", class)
+	}
+
+	tasks, err := r.getClassTasks(class)
+	if err != nil {
+		return nil, rdtError("failed to get tasks of class %q: %v", class, err)
+	}
+	for name, l := range r.mbLimits {
+		if l.class != class {
+			continue
+		}
+		limited, err := r.getClassTasks(name)
+		if err != nil {
+			return nil, rdtError("failed to get tasks of class %q with memory bandwidth limit %q: %v",
+				class, l.limit, err)
+		}
+		tasks = append(tasks, limited...)
+	}
+
+	return tasks, nil
+}
+
+// assignTasks assigns a set of processes to a resctrl group
+func (r *control) assignTasks(class string, pids ...string) error {
+	if err := r.writeTasks(r.resctrlGroupPath(class), pids...); err != nil {
+		return rdtError("failed to assign processes %v to class %q: %v", pids, class, err)
+	}
+	return nil
+}
+
+// writeTasks writes a set of processes to the tasks file of a resctrl directory
+func (r *control) writeTasks(dir string, pids ...string) error {
+	f, err := os.OpenFile(filepath.Join(dir, "tasks"), os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
@@ -116,7 +261,7 @@ func (r *control) SetProcessClass(class string, pids ...string) error {
 			if unwrapped == syscall.ESRCH {
 				r.Debug("no task %s", pid)
 			} else {
-				return rdtError("failed to assign processes %v to class %q: %v", pids, class, r.cmdError(err))
+				return r.cmdError(err)
 			}
 		}
 	}
@@ -126,6 +271,9 @@ func (r *control) SetProcessClass(class string, pids ...string) error {
 func (r *control) configNotify(event pkgcfg.Event, source pkgcfg.Source) error {
 	r.Info("configuration %s", event)
 
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	conf, err := opt.resolve()
 	if err != nil {
 		return rdtError("invalid configuration: %v", err)
@@ -152,6 +300,12 @@ func (r *control) configureResctrl(conf config) error {
 	}
 
 	for _, name := range existingClasses {
+		if l, ok := r.mbLimits[name]; ok {
+			if _, ok := conf.Classes[l.class]; ok {
+				continue
+			}
+			delete(r.mbLimits, name)
+		}
 		if _, ok := conf.Classes[name]; !ok {
 			tasks, err := r.getClassTasks(name)
 			if err != nil {
@@ -165,6 +319,7 @@ func (r *control) configureResctrl(conf config) error {
 			if err != nil {
 				return rdtError("failed to remove resctrl group %q: %v", path, err)
 			}
+			r.dropMonGroupParent(name)
 		}
 	}
 
@@ -177,9 +332,75 @@ func (r *control) configureResctrl(conf config) error {
 		}
 	}
 
+	// Reapply memory bandwidth limits, dropping unused ones
+	for name, l := range r.mbLimits {
+		tasks, err := r.getClassTasks(name)
+		if err != nil {
+			return rdtError("failed to get resctrl group tasks: %v", err)
+		}
+		if len(tasks) == 0 {
+			path := r.resctrlGroupPath(name)
+			if err := os.Remove(path); err != nil {
+				return rdtError("failed to remove resctrl group %q: %v", path, err)
+			}
+			delete(r.mbLimits, name)
+			r.dropMonGroupParent(name)
+			continue
+		}
+		if err := r.configureMBLimit(name, l, conf); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// configureMBLimit configures a memory bandwidth limited group of a class
+func (r *control) configureMBLimit(name string, l mbLimit, conf config) error {
+	class := conf.Classes[l.class]
+	if !rdtInfo.mb.Supported() {
+		return rdtError("memory bandwidth allocation not supported by system")
+	}
+
+	schema := make(mbSchema, len(rdtInfo.cacheIds))
+	for _, id := range rdtInfo.cacheIds {
+		value, err := parseMBAllocation([]interface{}{l.limit}, class.MBCalibration[id])
+		if err != nil {
+			return rdtError("invalid memory bandwidth limit %q: %v", l.limit, err)
+		}
+		// Never exceed the allocation of the class itself
+		if class.MBSchema != nil && class.MBSchema[id] < value {
+			value = class.MBSchema[id]
+		}
+		schema[id] = value
+	}
+	class.MBSchema = schema
+
+	return r.configureResctrlGroup(name, class, conf.Partitions[class.Partition], conf.Options)
+}
+
+// recoverMBLimits picks up existing memory bandwidth limited groups
+func (r *control) recoverMBLimits() error {
+	groups, err := r.getClasses()
+	if err != nil {
+		return err
+	}
+	for _, name := range groups {
+		idx := strings.LastIndex(name, mbLimitSeparator)
+		if idx < 1 {
+			continue
+		}
+		limit := strings.Replace(name[idx+len(mbLimitSeparator):], "pct", mbSuffixPct, 1)
+		r.mbLimits[name] = mbLimit{class: name[:idx], limit: limit}
+	}
+	return nil
+}
+
+// mbLimitGroupName returns the name of the memory bandwidth limited group of a class
+func mbLimitGroupName(class, limit string) string {
+	return class + mbLimitSeparator + strings.Replace(limit, mbSuffixPct, "pct", 1)
+}
+
 func (r *control) configureResctrlGroup(name string, class classConfig,
 	partition partitionConfig, options schemaOptions) error {
 	if err :=
@@ -266,7 +487,12 @@ func (r *control) getClasses() ([]string, error) {
 }
 
 func (r *control) getClassTasks(name string) ([]string, error) {
-	data, err := r.readRdtFile(filepath.Join(r.resctrlGroupDirName(name), "tasks"))
+	return r.getTasks(r.resctrlGroupDirName(name))
+}
+
+// getTasks returns the processes in the tasks file of a resctrl directory
+func (r *control) getTasks(dirName string) ([]string, error) {
+	data, err := r.readRdtFile(filepath.Join(dirName, "tasks"))
 	if err != nil {
 		return []string{}, err
 	}
@@ -17,6 +17,13 @@ limitations under the License.
 package rdt
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -120,3 +127,346 @@ func TestListStrToArray(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckCDP(t *testing.T) {
+	defer func(saved Info) { rdtInfo = saved }(rdtInfo)
+
+	unified := l3Info{cbmMask: 0xfff, minCbmBits: 1}
+	cdpConfig := func(optional bool) config {
+		return config{
+			Options: schemaOptions{L3: l3Options{Optional: optional}},
+			Partitions: partitionSet{
+				"default": partitionConfig{
+					L3: l3Schema{0: l3Allocation{Unified: l3AbsoluteAllocation(0xfff)}},
+				},
+			},
+			Classes: classSet{
+				"cdp": classConfig{
+					Partition: "default",
+					L3Schema: l3Schema{0: l3Allocation{
+						Unified: l3PctAllocation(100),
+						Code:    l3PctAllocation(50),
+						Data:    l3PctAllocation(100),
+					}},
+				},
+			},
+		}
+	}
+
+	// CDP enabled in the system
+	rdtInfo = Info{l3code: unified, l3data: unified}
+	if err := cdpConfig(false).checkCDP(); err != nil {
+		t.Errorf("unexpected error with CDP enabled: %v", err)
+	}
+
+	// CDP not enabled in the system, L3 allocation mandatory
+	rdtInfo = Info{l3: unified}
+	if err := cdpConfig(false).checkCDP(); err == nil {
+		t.Errorf("expected error with CDP not enabled")
+	}
+
+	// CDP not enabled in the system, L3 allocation optional
+	conf := cdpConfig(true)
+	if err := conf.checkCDP(); err != nil {
+		t.Errorf("unexpected error with optional L3 allocation: %v", err)
+	}
+	if conf.Classes["cdp"].L3Schema.cdp() {
+		t.Errorf("expected code/data allocations to be downgraded to unified")
+	}
+
+	// setting code/data parts must not touch the unified part
+	a := l3Allocation{Unified: l3AbsoluteAllocation(0xf)}
+	a = a.set(l3SchemaTypeCode, l3AbsoluteAllocation(0x3))
+	if a.Unified != l3AbsoluteAllocation(0xf) || a.Code != l3AbsoluteAllocation(0x3) {
+		t.Errorf("unexpected allocation after setting code part: %+v", a)
+	}
+}
+
+func TestParseMBAllocation(t *testing.T) {
+	defer func(saved Info) { rdtInfo = saved }(rdtInfo)
+
+	tcs := []struct {
+		name        string
+		mbps        bool
+		raw         []interface{}
+		calibration uint64
+		expected    uint64
+		fail        bool
+	}{
+		{name: "percentage", raw: []interface{}{"50%", "1000MBps"}, expected: 50},
+		{name: "MBps", mbps: true, raw: []interface{}{"50%", "1000MBps"}, expected: 1000},
+		{name: "missing percentage", raw: []interface{}{"1000MBps"}, fail: true},
+		{name: "missing MBps", mbps: true, raw: []interface{}{"50%"}, fail: true},
+		{name: "calibrated percentage", raw: []interface{}{"1000MBps"}, calibration: 3000, expected: 34},
+		{name: "calibrated MBps", mbps: true, raw: []interface{}{"50%"}, calibration: 3000, expected: 1500},
+		{name: "calibrated percentage above max", raw: []interface{}{"5000MBps"}, calibration: 3000, expected: 100},
+		{name: "invalid value", raw: []interface{}{"x%"}, fail: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			rdtInfo = Info{mb: mbInfo{mbpsEnabled: tc.mbps}}
+			value, err := parseMBAllocation(tc.raw, tc.calibration)
+			if tc.fail {
+				if err == nil {
+					t.Errorf("expected error, got %d", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if value != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, value)
+			}
+		})
+	}
+
+	if name := mbLimitGroupName("Guaranteed", "50%"); name != "Guaranteed.mb-50pct" {
+		t.Errorf("unexpected memory bandwidth limited group name %q", name)
+	}
+}
+
+func TestMBLimitGroupCap(t *testing.T) {
+	if max := (mbOptions{}).maxLimitGroups(); max != defaultMaxMBLimitGroups {
+		t.Errorf("expected default cap %d, got %d", defaultMaxMBLimitGroups, max)
+	}
+
+	r := &control{
+		Logger:   log,
+		mbLimits: make(map[string]mbLimit),
+		conf: config{
+			Options: schemaOptions{MB: mbOptions{MaxLimitGroups: 2}},
+			Classes: classSet{"Guaranteed": classConfig{}},
+		},
+	}
+	for _, limit := range []string{"10%", "20%"} {
+		r.mbLimits[mbLimitGroupName("Guaranteed", limit)] = mbLimit{class: "Guaranteed", limit: limit}
+	}
+
+	err := r.SetProcessMBLimit("Guaranteed", "30%", "1")
+	if !errors.Is(err, ErrTooManyMBLimits) {
+		t.Errorf("expected %v, got %v", ErrTooManyMBLimits, err)
+	}
+	if len(r.mbLimits) != 2 {
+		t.Errorf("expected no new memory bandwidth limited groups, got %d", len(r.mbLimits))
+	}
+}
+
+func TestPickMonGroupVictim(t *testing.T) {
+	group := func(id string, priority int, parents ...string) *monGroup {
+		g := newMonGroup(MonGroup{ID: id, Priority: priority})
+		for _, parent := range parents {
+			g.parents[parent] = struct{}{}
+		}
+		return g
+	}
+	groups := map[string]*monGroup{
+		"a": group("a", MonPriorityMedium, "Burstable"),
+		"b": group("b", MonPriorityLow, "BestEffort", "Burstable"),
+		"c": group("c", MonPriorityLow, "BestEffort"),
+		"d": group("d", MonPriorityHigh, "Guaranteed"),
+	}
+
+	tcs := []struct {
+		name     string
+		priority int
+		victim   string
+		parent   string
+	}{
+		{name: "high priority", priority: MonPriorityHigh, victim: "b", parent: "BestEffort"},
+		{name: "medium priority", priority: MonPriorityMedium, victim: "b", parent: "BestEffort"},
+		{name: "low priority", priority: MonPriorityLow},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			g, parent := pickMonGroupVictim(groups, tc.priority)
+			switch {
+			case tc.victim == "" && g != nil:
+				t.Errorf("expected no victim, got %s in %q", g.ID, parent)
+			case tc.victim != "" && g == nil:
+				t.Errorf("expected victim %s, got none", tc.victim)
+			case g != nil && (g.ID != tc.victim || parent != tc.parent):
+				t.Errorf("expected victim %s in %q, got %s in %q", tc.victim, tc.parent, g.ID, parent)
+			}
+		})
+	}
+}
+
+func TestGetClassTasks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rdt-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	saved := rdtInfo.resctrlPath
+	defer func() { rdtInfo.resctrlPath = saved }()
+	rdtInfo.resctrlPath = dir
+
+	limited := mbLimitGroupName("Guaranteed", "10%")
+	groups := map[string]string{
+		resctrlGroupPrefix + "Guaranteed": "1\n2\n",
+		resctrlGroupPrefix + limited:      "3\n",
+		resctrlGroupPrefix + "Burstable":  "4\n",
+		resctrlGroupPrefix + "BestEffort": "",
+	}
+	for group, tasks := range groups {
+		if err := os.MkdirAll(filepath.Join(dir, group), 0755); err != nil {
+			t.Fatalf("failed to create resctrl group %s: %v", group, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, group, "tasks"), []byte(tasks), 0644); err != nil {
+			t.Fatalf("failed to write tasks of %s: %v", group, err)
+		}
+	}
+
+	r := &control{
+		Logger:   log,
+		mbLimits: map[string]mbLimit{limited: {class: "Guaranteed", limit: "10%"}},
+		conf: config{
+			Classes: classSet{"Guaranteed": classConfig{}, "Burstable": classConfig{}, "BestEffort": classConfig{}},
+		},
+	}
+
+	tcs := []struct {
+		class    string
+		expected []string
+		invalid  bool
+	}{
+		{class: "Guaranteed", expected: []string{"1", "2", "3"}},
+		{class: "Burstable", expected: []string{"4"}},
+		{class: "BestEffort", expected: []string{}},
+		{class: "Unknown", invalid: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.class, func(t *testing.T) {
+			tasks, err := r.GetClassTasks(tc.class)
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("expected error for class %s, got tasks %v", tc.class, tasks)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get tasks of class %s: %v", tc.class, err)
+			}
+			sort.Strings(tasks)
+			if !cmp.Equal(tasks, tc.expected) {
+				t.Errorf("expected tasks %v, got %v", tc.expected, tasks)
+			}
+		})
+	}
+}
+
+func TestMonGroupEviction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rdt-test")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(saved Info) { rdtInfo = saved }(rdtInfo)
+	rdtInfo = Info{
+		resctrlPath: dir,
+		l3mon:       l3MonInfo{numRmids: 2, monFeatures: []string{"llc_occupancy"}},
+	}
+	for _, class := range []string{"BestEffort", "Guaranteed"} {
+		if err := os.MkdirAll(filepath.Join(dir, resctrlGroupPrefix+class, monGroupsDir), 0755); err != nil {
+			t.Fatalf("failed to create resctrl group %s: %v", class, err)
+		}
+	}
+
+	// Fake RMIDs: creating a monitoring group fails with ENOSPC while all RMIDs
+	// are either used or in limbo after the removal of their group.
+	used, limbo := 0, 0
+	defer func(mkdir func(string, os.FileMode) error, rmdir func(string) error) {
+		makeMonGroupDir, removeMonGroupDir = mkdir, rmdir
+	}(makeMonGroupDir, removeMonGroupDir)
+	makeMonGroupDir = func(path string, perm os.FileMode) error {
+		if used+limbo >= int(rdtInfo.l3mon.numRmids) {
+			return &os.PathError{Op: "mkdir", Path: path, Err: syscall.ENOSPC}
+		}
+		if err := os.Mkdir(path, perm); err != nil {
+			return err
+		}
+		used++
+		return ioutil.WriteFile(filepath.Join(path, "tasks"), nil, 0644)
+	}
+	removeMonGroupDir = func(path string) error {
+		used--
+		limbo++
+		return os.RemoveAll(path)
+	}
+
+	r := &control{
+		Logger:    log,
+		mbLimits:  make(map[string]mbLimit),
+		monGroups: make(map[string]*monGroup),
+		conf: config{
+			Classes: classSet{"BestEffort": classConfig{}, "Guaranteed": classConfig{}},
+		},
+	}
+	tasks := func(class, id string) string {
+		data, err := ioutil.ReadFile(filepath.Join(r.monGroupPath(class, id), "tasks"))
+		if err != nil {
+			return "<" + err.Error() + ">"
+		}
+		return strings.Join(strings.Fields(string(data)), ",")
+	}
+	state := func(id string) string {
+		g := r.monGroups[id]
+		parents, evicted := []string{}, []string{}
+		for parent := range g.parents {
+			parents = append(parents, parent)
+		}
+		for parent, pids := range g.evicted {
+			evicted = append(evicted, parent+":"+strings.Join(pids.list(), ","))
+		}
+		return "parents=" + strings.Join(parents, ",") + " evicted=" + strings.Join(evicted, ",")
+	}
+	check := func(id, expected string) {
+		if s := state(id); s != expected {
+			t.Errorf("expected monitoring group %s to be %q, got %q", id, expected, s)
+		}
+	}
+
+	for _, id := range []string{"low1", "low2"} {
+		group := MonGroup{ID: id, Priority: MonPriorityLow}
+		if err := r.SetProcessMonGroup("BestEffort", "", group, "1"); err != nil {
+			t.Fatalf("failed to create monitoring group %s: %v", id, err)
+		}
+	}
+	// a process forked after the group was set up
+	if err := ioutil.WriteFile(filepath.Join(r.monGroupPath("BestEffort", "low1"), "tasks"), []byte("1\n3\n"), 0644); err != nil {
+		t.Fatalf("failed to write tasks: %v", err)
+	}
+
+	// out of RMIDs, a single group is evicted and the new group waits for its RMID
+	high := MonGroup{ID: "high", Priority: MonPriorityHigh}
+	for _, pid := range []string{"10", "11"} {
+		if err := r.SetProcessMonGroup("Guaranteed", "", high, pid); err != nil {
+			t.Fatalf("failed to set monitoring group of %s: %v", pid, err)
+		}
+	}
+	check("low1", "parents= evicted=BestEffort:1,3")
+	check("low2", "parents=BestEffort evicted=")
+	check("high", "parents= evicted=Guaranteed:10,11")
+
+	// the waiting group of the highest priority gets the first RMID out of limbo
+	limbo = 0
+	r.restoreEvictedMonGroups()
+	check("low1", "parents= evicted=BestEffort:1,3")
+	check("high", "parents=Guaranteed evicted=")
+	if pids := tasks("Guaranteed", "high"); pids != "10,11" {
+		t.Errorf("expected processes 10,11 in restored group, got %s", pids)
+	}
+
+	if err := r.DeleteMonGroup("high"); err != nil {
+		t.Fatalf("failed to delete monitoring group: %v", err)
+	}
+	check("low1", "parents= evicted=BestEffort:1,3")
+	limbo = 0
+	r.restoreEvictedMonGroups()
+	check("low1", "parents=BestEffort evicted=")
+	if pids := tasks("BestEffort", "low1"); pids != "1,3" {
+		t.Errorf("expected processes 1,3 in restored group, got %s", pids)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// sysfs DSA bus devices subdirectory path, for DSA and IAA work queues
+	sysfsDSADevicePath = "bus/dsa/devices"
+	// sysfs vfio-pci driver subdirectory path, for QAT virtual functions
+	sysfsVFIOPCIPath = "bus/pci/drivers/vfio-pci"
+	// Intel PCI vendor ID
+	pciVendorIntel = "0x8086"
+)
+
+// AcceleratorType is the type of an accelerator.
+type AcceleratorType string
+
+const (
+	// AcceleratorDSA is a Data Streaming Accelerator.
+	AcceleratorDSA AcceleratorType = "dsa"
+	// AcceleratorIAA is an In-Memory Analytics Accelerator.
+	AcceleratorIAA AcceleratorType = "iaa"
+	// AcceleratorQAT is a QuickAssist Technology virtual function.
+	AcceleratorQAT AcceleratorType = "qat"
+)
+
+// WorkQueueMode is the mode of an accelerator work queue.
+type WorkQueueMode string
+
+const (
+	// WorkQueueDedicated is a work queue used by a single client at a time.
+	WorkQueueDedicated WorkQueueMode = "dedicated"
+	// WorkQueueShared is a work queue shared by several clients.
+	WorkQueueShared WorkQueueMode = "shared"
+)
+
+// PCI device IDs of QAT virtual functions.
+var qatVFDeviceIDs = map[string]struct{}{
+	"0x0443": {}, // DH895xCC
+	"0x19e3": {}, // C3xxx
+	"0x37c9": {}, // C62x
+	"0x6f55": {}, // D15xx
+	"0x4941": {}, // 4xxx
+	"0x4943": {}, // 401xx
+	"0x4945": {}, // 402xx
+}
+
+// WorkQueue is an enabled user work queue of an accelerator.
+type WorkQueue struct {
+	Name    string          // work queue name, for instance wq0.1 or a QAT VF PCI address
+	Device  string          // name of the accelerator device, for instance dsa0
+	Type    AcceleratorType // accelerator type
+	Mode    WorkQueueMode   // work queue mode
+	Size    int             // number of work queue entries, 0 if unknown
+	Node    ID              // closest NUMA node, -1 if unknown
+	DevPath string          // device node for accessing the work queue
+}
+
+// ParseAcceleratorType parses an accelerator type.
+func ParseAcceleratorType(name string) (AcceleratorType, error) {
+	switch t := AcceleratorType(strings.ToLower(strings.TrimSpace(name))); t {
+	case AcceleratorDSA, AcceleratorIAA, AcceleratorQAT:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown accelerator type %q", name)
+}
+
+// ParseWorkQueueMode parses a work queue mode.
+func ParseWorkQueueMode(name string) (WorkQueueMode, error) {
+	switch m := WorkQueueMode(strings.ToLower(strings.TrimSpace(name))); m {
+	case WorkQueueDedicated, WorkQueueShared:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown work queue mode %q", name)
+}
+
+// String returns the work queue as a string.
+func (wq *WorkQueue) String() string {
+	return fmt.Sprintf("%s/%s (%s, %s, size %d, node #%d)",
+		wq.Device, wq.Name, wq.DevPath, wq.Mode, wq.Size, wq.Node)
+}
+
+// WorkQueues returns the discovered accelerator work queues, sorted by device and name.
+func (sys *system) WorkQueues() []*WorkQueue {
+	queues := make([]*WorkQueue, len(sys.workqueues))
+	copy(queues, sys.workqueues)
+	return queues
+}
+
+// discoverWorkQueues discovers the enabled user work queues of accelerators.
+func (sys *system) discoverWorkQueues() error {
+	if sys.workqueues != nil {
+		return nil
+	}
+
+	sys.workqueues = []*WorkQueue{}
+
+	entries, _ := filepath.Glob(filepath.Join(sys.path, sysfsDSADevicePath, "wq*"))
+	for _, entry := range entries {
+		if err := sys.discoverDSAWorkQueue(entry); err != nil {
+			return fmt.Errorf("failed to discover work queue %s: %v", entry, err)
+		}
+	}
+
+	entries, _ = filepath.Glob(filepath.Join(sys.path, sysfsVFIOPCIPath, "*:*"))
+	for _, entry := range entries {
+		if err := sys.discoverQATWorkQueue(entry); err != nil {
+			return fmt.Errorf("failed to discover QAT device %s: %v", entry, err)
+		}
+	}
+
+	sort.Slice(sys.workqueues, func(i, j int) bool {
+		wi, wj := sys.workqueues[i], sys.workqueues[j]
+		if wi.Device != wj.Device {
+			return wi.Device < wj.Device
+		}
+		return wi.Name < wj.Name
+	})
+
+	return nil
+}
+
+// discoverDSAWorkQueue discovers the details of a DSA or IAA work queue.
+func (sys *system) discoverDSAWorkQueue(path string) error {
+	devPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	state, wqType := "", ""
+	readSysfsEntry(path, "state", &state)
+	readSysfsEntry(path, "type", &wqType)
+	if strings.TrimSpace(state) != "enabled" || strings.TrimSpace(wqType) != "user" {
+		return nil
+	}
+
+	wq := &WorkQueue{
+		Name:   filepath.Base(path),
+		Device: filepath.Base(filepath.Dir(devPath)),
+		Node:   ID(-1),
+	}
+
+	switch {
+	case strings.HasPrefix(wq.Device, "dsa"):
+		wq.Type = AcceleratorDSA
+		wq.DevPath = filepath.Join("/dev/dsa", wq.Name)
+	case strings.HasPrefix(wq.Device, "iax"):
+		wq.Type = AcceleratorIAA
+		wq.DevPath = filepath.Join("/dev/iax", wq.Name)
+	default:
+		return nil
+	}
+
+	mode := ""
+	if _, err := readSysfsEntry(path, "mode", &mode); err != nil {
+		return err
+	}
+	if wq.Mode, err = ParseWorkQueueMode(mode); err != nil {
+		return sysfsError(path, "%v", err)
+	}
+	readSysfsEntry(path, "size", &wq.Size)
+
+	wq.Node = deviceNode(sys.path, devPath)
+
+	sys.workqueues = append(sys.workqueues, wq)
+
+	return nil
+}
+
+// discoverQATWorkQueue discovers a QAT virtual function bound to vfio-pci.
+//
+// Each virtual function is handed out as a whole, so it is treated as a
+// single dedicated work queue accessed through its VFIO group.
+func (sys *system) discoverQATWorkQueue(path string) error {
+	vendor, device := "", ""
+	readSysfsEntry(path, "vendor", &vendor)
+	readSysfsEntry(path, "device", &device)
+	if strings.TrimSpace(vendor) != pciVendorIntel {
+		return nil
+	}
+	if _, ok := qatVFDeviceIDs[strings.TrimSpace(device)]; !ok {
+		return nil
+	}
+
+	devPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	group, err := filepath.EvalSymlinks(filepath.Join(path, "iommu_group"))
+	if err != nil {
+		return err
+	}
+
+	// the virtual function is the PCI device itself, with its own NUMA node
+	node := ID(-1)
+	if _, err := readSysfsEntry(devPath, "numa_node", &node); err != nil || node < 0 {
+		node = ID(-1)
+	}
+
+	wq := &WorkQueue{
+		Name:    filepath.Base(path),
+		Device:  filepath.Base(path),
+		Type:    AcceleratorQAT,
+		Mode:    WorkQueueDedicated,
+		Node:    node,
+		DevPath: filepath.Join("/dev/vfio", filepath.Base(group)),
+	}
+
+	sys.workqueues = append(sys.workqueues, wq)
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSysfsFiles creates the given files, relative to the sysfs root.
+func writeSysfsFiles(t *testing.T, root string, files map[string]string) {
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory for %s: %v", file, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+}
+
+// linkSysfsEntries creates the given symbolic links, relative to the sysfs root.
+func linkSysfsEntries(t *testing.T, root string, links map[string]string) {
+	for link, target := range links {
+		path := filepath.Join(root, link)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory for %s: %v", link, err)
+		}
+		if err := os.Symlink(filepath.Join(root, target), path); err != nil {
+			t.Fatalf("failed to link %s: %v", link, err)
+		}
+	}
+}
+
+func TestDiscoverWorkQueues(t *testing.T) {
+	root, err := ioutil.TempDir("", "accel-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	const (
+		dsa = "devices/pci0000:00/0000:00:01.0"
+		iax = "devices/pci0000:80/0000:80:02.0"
+		qat = "devices/pci0000:00/0000:6b:00.1"
+		nic = "devices/pci0000:00/0000:6c:00.1"
+	)
+
+	writeSysfsFiles(t, root, map[string]string{
+		dsa + "/numa_node":            "0\n",
+		dsa + "/dsa0/wq0.0/state":     "enabled\n",
+		dsa + "/dsa0/wq0.0/type":      "user\n",
+		dsa + "/dsa0/wq0.0/mode":      "dedicated\n",
+		dsa + "/dsa0/wq0.0/size":      "16\n",
+		dsa + "/dsa0/wq0.1/state":     "enabled\n",
+		dsa + "/dsa0/wq0.1/type":      "kernel\n",
+		dsa + "/dsa0/wq0.1/mode":      "shared\n",
+		dsa + "/dsa0/wq0.2/state":     "disabled\n",
+		dsa + "/dsa0/wq0.2/type":      "user\n",
+		dsa + "/dsa0/wq0.2/mode":      "shared\n",
+		iax + "/numa_node":            "1\n",
+		iax + "/iax1/wq1.0/state":     "enabled\n",
+		iax + "/iax1/wq1.0/type":      "user\n",
+		iax + "/iax1/wq1.0/mode":      "shared\n",
+		iax + "/iax1/wq1.0/size":      "32\n",
+		qat + "/vendor":               "0x8086\n",
+		qat + "/device":               "0x4941\n",
+		qat + "/numa_node":            "1\n",
+		nic + "/vendor":               "0x8086\n",
+		nic + "/device":               "0x1889\n",
+		"kernel/iommu_groups/42/type": "DMA\n",
+	})
+	linkSysfsEntries(t, root, map[string]string{
+		sysfsDSADevicePath + "/wq0.0":      dsa + "/dsa0/wq0.0",
+		sysfsDSADevicePath + "/wq0.1":      dsa + "/dsa0/wq0.1",
+		sysfsDSADevicePath + "/wq0.2":      dsa + "/dsa0/wq0.2",
+		sysfsDSADevicePath + "/wq1.0":      iax + "/iax1/wq1.0",
+		sysfsVFIOPCIPath + "/0000:6b:00.1": qat,
+		sysfsVFIOPCIPath + "/0000:6c:00.1": nic,
+		qat + "/iommu_group":               "kernel/iommu_groups/42",
+	})
+
+	sys, err := DiscoverSystemAt(root, DiscoverWorkQueues)
+	if err != nil {
+		t.Fatalf("failed to discover work queues: %v", err)
+	}
+
+	expected := []WorkQueue{
+		{Name: "0000:6b:00.1", Device: "0000:6b:00.1", Type: AcceleratorQAT, Mode: WorkQueueDedicated,
+			Node: 1, DevPath: "/dev/vfio/42"},
+		{Name: "wq0.0", Device: "dsa0", Type: AcceleratorDSA, Mode: WorkQueueDedicated,
+			Size: 16, Node: 0, DevPath: "/dev/dsa/wq0.0"},
+		{Name: "wq1.0", Device: "iax1", Type: AcceleratorIAA, Mode: WorkQueueShared,
+			Size: 32, Node: 1, DevPath: "/dev/iax/wq1.0"},
+	}
+	queues := sys.WorkQueues()
+	if len(queues) != len(expected) {
+		t.Fatalf("expected %d work queues, got %v", len(expected), queues)
+	}
+	for i, wq := range queues {
+		if *wq != expected[i] {
+			t.Errorf("expected work queue %s, got %s", &expected[i], wq)
+		}
+	}
+}
+
+func TestParseWorkQueueMode(t *testing.T) {
+	for _, value := range []string{"dedicated", " Shared\n"} {
+		if _, err := ParseWorkQueueMode(value); err != nil {
+			t.Errorf("failed to parse work queue mode %q: %v", value, err)
+		}
+	}
+	if _, err := ParseWorkQueueMode("exclusive"); err == nil {
+		t.Errorf("work queue mode exclusive parsed, expected an error")
+	}
+	for _, value := range []string{"dsa", "IAA", " qat "} {
+		if _, err := ParseAcceleratorType(value); err != nil {
+			t.Errorf("failed to parse accelerator type %q: %v", value, err)
+		}
+	}
+	if _, err := ParseAcceleratorType("gpu"); err == nil {
+		t.Errorf("accelerator type gpu parsed, expected an error")
+	}
+}
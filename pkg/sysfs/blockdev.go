@@ -0,0 +1,179 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// sysfs block device subdirectory path
+	sysfsBlockPath = "block"
+)
+
+// BlockDeviceType is the type of a block device, by the bus or protocol used to access it.
+type BlockDeviceType string
+
+const (
+	// BlockDeviceNVMe is an NVMe namespace.
+	BlockDeviceNVMe BlockDeviceType = "nvme"
+	// BlockDeviceSATA is a SATA disk.
+	BlockDeviceSATA BlockDeviceType = "sata"
+	// BlockDeviceSCSI is a SCSI or SAS disk.
+	BlockDeviceSCSI BlockDeviceType = "scsi"
+	// BlockDeviceVirtio is a virtio block device.
+	BlockDeviceVirtio BlockDeviceType = "virtio"
+	// BlockDeviceOther is any other type of block device.
+	BlockDeviceOther BlockDeviceType = "other"
+)
+
+// BlockDevice is a (non-virtual) block device present in the system.
+type BlockDevice struct {
+	Name       string          // kernel device name, for instance nvme0n1
+	Major      int64           // device major number
+	Minor      int64           // device minor number
+	Node       ID              // closest NUMA node, -1 if unknown
+	Type       BlockDeviceType // device type
+	Model      string          // device model, if known
+	Rotational bool            // whether the device is rotational
+}
+
+// DevNode returns the major:minor device number of the block device.
+func (dev *BlockDevice) DevNode() string {
+	return fmt.Sprintf("%d:%d", dev.Major, dev.Minor)
+}
+
+// String returns the block device as a string.
+func (dev *BlockDevice) String() string {
+	return fmt.Sprintf("%s (%s, %s, model %q, node #%d)",
+		dev.Name, dev.DevNode(), dev.Type, dev.Model, dev.Node)
+}
+
+// BlockDevices returns the discovered block devices, sorted by name.
+func (sys *system) BlockDevices() []*BlockDevice {
+	devices := make([]*BlockDevice, len(sys.blockdevs))
+	copy(devices, sys.blockdevs)
+	return devices
+}
+
+// discoverBlockDevices discovers the block devices present in the system.
+func (sys *system) discoverBlockDevices() error {
+	if sys.blockdevs != nil {
+		return nil
+	}
+
+	sys.blockdevs = []*BlockDevice{}
+
+	entries, _ := filepath.Glob(filepath.Join(sys.path, sysfsBlockPath, "*"))
+	for _, entry := range entries {
+		if err := sys.discoverBlockDevice(entry); err != nil {
+			return fmt.Errorf("failed to discover block device %s: %v", entry, err)
+		}
+	}
+
+	sort.Slice(sys.blockdevs, func(i, j int) bool {
+		return sys.blockdevs[i].Name < sys.blockdevs[j].Name
+	})
+
+	return nil
+}
+
+// discoverBlockDevice discovers the details of the given block device.
+func (sys *system) discoverBlockDevice(path string) error {
+	devPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	// loop, ram, device-mapper, md, zram, etc. devices are all virtual, but
+	// so are multipath NVMe namespaces which we don't want to leave out
+	if strings.Contains(devPath, "/devices/virtual/") && !strings.Contains(devPath, "/nvme-subsystem/") {
+		return nil
+	}
+
+	dev := &BlockDevice{
+		Name: filepath.Base(path),
+		Node: ID(-1),
+	}
+
+	devno := ""
+	if _, err := readSysfsEntry(path, "dev", &devno); err != nil {
+		return err
+	}
+	if _, err := fmt.Sscanf(devno, "%d:%d", &dev.Major, &dev.Minor); err != nil {
+		return sysfsError(path, "invalid device number %q: %v", devno, err)
+	}
+
+	rotational := 0
+	if _, err := readSysfsEntry(path, "queue/rotational", &rotational); err == nil {
+		dev.Rotational = rotational != 0
+	}
+
+	dev.Type = blockDeviceType(path, devPath)
+	dev.Model = blockDeviceModel(path)
+	dev.Node = deviceNode(sys.path, devPath)
+
+	sys.blockdevs = append(sys.blockdevs, dev)
+
+	return nil
+}
+
+// blockDeviceType determines the type of a block device.
+func blockDeviceType(path, devPath string) BlockDeviceType {
+	name := filepath.Base(path)
+
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return BlockDeviceNVMe
+	case strings.HasPrefix(name, "vd"):
+		return BlockDeviceVirtio
+	case strings.HasPrefix(name, "sd"):
+		vendor := ""
+		readSysfsEntry(path, "device/vendor", &vendor)
+		if strings.TrimSpace(vendor) == "ATA" || strings.Contains(devPath, "/ata") {
+			return BlockDeviceSATA
+		}
+		return BlockDeviceSCSI
+	}
+
+	return BlockDeviceOther
+}
+
+// blockDeviceModel determines the model of a block device.
+func blockDeviceModel(path string) string {
+	model := ""
+	readSysfsEntry(path, "device/model", &model)
+	return strings.TrimSpace(model)
+}
+
+// deviceNode determines the NUMA node closest to a device.
+//
+// The node is taken from the closest parent device (usually the PCI device of
+// the controller) which has a known NUMA node.
+func deviceNode(root, devPath string) ID {
+	for dir := filepath.Dir(devPath); strings.HasPrefix(dir, root) && dir != root; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "numa_node")); err != nil {
+			continue
+		}
+		node := ID(-1)
+		if _, err := readSysfsEntry(dir, "numa_node", &node); err == nil && node >= 0 {
+			return node
+		}
+	}
+	return ID(-1)
+}
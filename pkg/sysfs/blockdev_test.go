@@ -0,0 +1,149 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBlockDevice describes a block device to create in a fake sysfs tree.
+type testBlockDevice struct {
+	path   string            // device path, relative to the sysfs root
+	files  map[string]string // files of the device, relative to path
+	parent map[string]string // files of the parent devices, relative to the sysfs root
+}
+
+func TestDiscoverBlockDevices(t *testing.T) {
+	devices := map[string]*testBlockDevice{
+		"nvme0n1": {
+			path: "devices/pci0000:00/0000:00:01.0/nvme/nvme0/nvme0n1",
+			files: map[string]string{
+				"dev":              "259:0\n",
+				"queue/rotational": "0\n",
+				"device/model":     "Fast NVMe 1TB   \n",
+			},
+			parent: map[string]string{
+				"devices/pci0000:00/0000:00:01.0/numa_node": "1\n",
+			},
+		},
+		"sda": {
+			path: "devices/pci0000:00/0000:00:1f.2/ata1/host0/target0:0:0/0:0:0:0/block/sda",
+			files: map[string]string{
+				"dev":              "8:0\n",
+				"queue/rotational": "1\n",
+				"device/vendor":    "ATA     \n",
+				"device/model":     "ST1000DM003\n",
+			},
+			parent: map[string]string{
+				"devices/pci0000:00/0000:00:1f.2/numa_node": "-1\n",
+			},
+		},
+		"sdb": {
+			path: "devices/pci0000:00/0000:00:02.0/host1/target1:0:0/1:0:0:0/block/sdb",
+			files: map[string]string{
+				"dev":           "8:16\n",
+				"device/vendor": "SEAGATE \n",
+				"device/model":  "ST600MM0006\n",
+			},
+			parent: map[string]string{
+				"devices/pci0000:00/0000:00:02.0/numa_node": "0\n",
+			},
+		},
+		"vda": {
+			path: "devices/pci0000:00/0000:00:05.0/virtio1/block/vda",
+			files: map[string]string{
+				"dev": "252:0\n",
+			},
+		},
+		"loop0": {
+			path: "devices/virtual/block/loop0",
+			files: map[string]string{
+				"dev": "7:0\n",
+			},
+		},
+		"nvme1n1": {
+			path: "devices/virtual/nvme-subsystem/nvme-subsys1/nvme1n1",
+			files: map[string]string{
+				"dev": "259:1\n",
+			},
+		},
+	}
+
+	tmp, err := ioutil.TempDir("", "blockdev-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	root, err := filepath.EvalSymlinks(tmp)
+	if err != nil {
+		t.Fatalf("failed to resolve temporary directory: %v", err)
+	}
+
+	for name, dev := range devices {
+		files := map[string]string{}
+		for file, content := range dev.files {
+			files[filepath.Join(dev.path, file)] = content
+		}
+		for file, content := range dev.parent {
+			files[file] = content
+		}
+		for file, content := range files {
+			path := filepath.Join(root, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create directory for %s: %v", file, err)
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", file, err)
+			}
+		}
+		link := filepath.Join(root, sysfsBlockPath, name)
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			t.Fatalf("failed to create block directory: %v", err)
+		}
+		target, _ := filepath.Rel(filepath.Dir(link), filepath.Join(root, dev.path))
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatalf("failed to create symlink for %s: %v", name, err)
+		}
+	}
+
+	sys := &system{path: root}
+	if err := sys.discoverBlockDevices(); err != nil {
+		t.Fatalf("failed to discover block devices: %v", err)
+	}
+
+	expected := []BlockDevice{
+		{Name: "nvme0n1", Major: 259, Minor: 0, Node: 1, Type: BlockDeviceNVMe, Model: "Fast NVMe 1TB"},
+		{Name: "nvme1n1", Major: 259, Minor: 1, Node: -1, Type: BlockDeviceNVMe},
+		{Name: "sda", Major: 8, Minor: 0, Node: -1, Type: BlockDeviceSATA, Model: "ST1000DM003", Rotational: true},
+		{Name: "sdb", Major: 8, Minor: 16, Node: 0, Type: BlockDeviceSCSI, Model: "ST600MM0006"},
+		{Name: "vda", Major: 252, Minor: 0, Node: -1, Type: BlockDeviceVirtio},
+	}
+
+	discovered := sys.BlockDevices()
+	if len(discovered) != len(expected) {
+		t.Fatalf("expected %d block devices, got %d: %v", len(expected), len(discovered), discovered)
+	}
+	for i, dev := range discovered {
+		if *dev != expected[i] {
+			t.Errorf("expected block device %s, got %s", &expected[i], dev)
+		}
+	}
+	if devno := discovered[2].DevNode(); devno != "8:0" {
+		t.Errorf("expected device number 8:0 for sda, got %s", devno)
+	}
+}
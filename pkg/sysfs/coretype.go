@@ -0,0 +1,118 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+const (
+	// sysfs hybrid performance core PMU cpus entry
+	sysfsCoreCPUsPath = "devices/cpu_core/cpus"
+	// sysfs hybrid efficient core PMU cpus entry
+	sysfsAtomCPUsPath = "devices/cpu_atom/cpus"
+)
+
+// CoreType is the type of a CPU core in a hybrid (heterogeneous) system.
+type CoreType string
+
+const (
+	// CoreTypePerformance is a high-performance (P) core.
+	CoreTypePerformance CoreType = "performance"
+	// CoreTypeEfficient is a power-efficient (E) core.
+	CoreTypeEfficient CoreType = "efficient"
+)
+
+// ParseCoreType parses a core type, also accepting the short forms p and e.
+func ParseCoreType(value string) (CoreType, error) {
+	switch t := CoreType(strings.ToLower(strings.TrimSpace(value))); t {
+	case CoreTypePerformance, "p", "p-core":
+		return CoreTypePerformance, nil
+	case CoreTypeEfficient, "e", "e-core":
+		return CoreTypeEfficient, nil
+	}
+	return "", fmt.Errorf("invalid core type %q", value)
+}
+
+// discoverCoreTypes discovers the types of CPU cores in a hybrid system.
+//
+// Core types are taken from the CPUs of the hybrid core and atom PMUs if the
+// kernel exposes them. Otherwise, if CPUs have differing cpu_capacity, CPUs
+// with the highest capacity are considered performance cores and the rest
+// efficient ones. On systems with a single type of cores no CPU gets a type.
+func (sys *system) discoverCoreTypes() {
+	sys.coretypes = map[CoreType]IDSet{}
+
+	core, atom := NewIDSet(), NewIDSet()
+	_, errCore := readSysfsEntry(sys.path, sysfsCoreCPUsPath, &core, ",")
+	_, errAtom := readSysfsEntry(sys.path, sysfsAtomCPUsPath, &atom, ",")
+	if errCore == nil && errAtom == nil && core.Size() > 0 && atom.Size() > 0 {
+		sys.coretypes[CoreTypePerformance] = core
+		sys.coretypes[CoreTypeEfficient] = atom
+	} else {
+		capacity := map[ID]int{}
+		max := 0
+		for id, cpu := range sys.cpus {
+			c := 0
+			if _, err := readSysfsEntry(cpu.path, "cpu_capacity", &c); err != nil {
+				return
+			}
+			capacity[id] = c
+			if c > max {
+				max = c
+			}
+		}
+		core, atom = NewIDSet(), NewIDSet()
+		for id, c := range capacity {
+			if c == max {
+				core.Add(id)
+			} else {
+				atom.Add(id)
+			}
+		}
+		if core.Size() == 0 || atom.Size() == 0 {
+			return
+		}
+		sys.coretypes[CoreTypePerformance] = core
+		sys.coretypes[CoreTypeEfficient] = atom
+	}
+
+	for t, cpus := range sys.coretypes {
+		for _, id := range cpus.Members() {
+			if cpu, ok := sys.cpus[id]; ok {
+				cpu.coretype = t
+			}
+		}
+		sys.Info("%s cores: %s", t, cpus)
+	}
+}
+
+// CoreTypeCPUs gets the set of CPUs of the given core type.
+//
+// On systems with a single type of cores the set is empty for all types.
+func (sys *system) CoreTypeCPUs(t CoreType) cpuset.CPUSet {
+	if cpus, ok := sys.coretypes[t]; ok {
+		return cpus.CPUSet()
+	}
+	return cpuset.NewCPUSet()
+}
+
+// CoreType returns the type of the core of this CPU, or "" for non-hybrid systems.
+func (c *cpu) CoreType() CoreType {
+	return c.coretype
+}
@@ -18,7 +18,7 @@ import (
 	"sort"
 	"strconv"
 
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 )
 
 const (
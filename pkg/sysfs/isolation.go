@@ -0,0 +1,117 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+)
+
+// CPUIsolation is a kernel mechanism for isolating CPUs from general use.
+type CPUIsolation string
+
+const (
+	// IsolCPUs marks CPUs isolated from the scheduler using isolcpus.
+	IsolCPUs CPUIsolation = "isolcpus"
+	// NohzFull marks adaptive-tick (nohz_full) CPUs.
+	NohzFull CPUIsolation = "nohz_full"
+	// RCUNoCBs marks CPUs with RCU callbacks offloaded (rcu_nocbs).
+	RCUNoCBs CPUIsolation = "rcu_nocbs"
+)
+
+// procCmdline is the path to the kernel command line.
+var procCmdline = "/proc/cmdline"
+
+// discoverIsolation discovers CPUs isolated by kernel command line parameters.
+func (sys *system) discoverIsolation() {
+	sys.isolation = map[CPUIsolation]IDSet{
+		IsolCPUs: sys.isolated.Clone(),
+		NohzFull: NewIDSet(),
+		RCUNoCBs: NewIDSet(),
+	}
+
+	nohz := NewIDSet()
+	if _, err := readSysfsEntry(sys.path, filepath.Join(sysfsCPUPath, "nohz_full"), &nohz, ","); err == nil {
+		sys.isolation[NohzFull].Add(nohz.Members()...)
+	}
+
+	// The kernel command line is only relevant for the running system.
+	if sys.path != SysfsRootPath {
+		return
+	}
+
+	blob, err := ioutil.ReadFile(procCmdline)
+	if err != nil {
+		sys.Error("failed to read kernel command line: %v", err)
+		return
+	}
+
+	for kind, cpus := range sys.parseIsolationParams(string(blob)) {
+		sys.isolation[kind].Add(FromCPUSet(cpus).Members()...)
+	}
+
+	for kind, cpus := range sys.isolation {
+		if cpus.Size() > 0 {
+			sys.Info("%s CPUs: %s", kind, cpus)
+		}
+	}
+}
+
+// parseIsolationParams parses CPU isolation parameters from a kernel command line.
+func (sys *system) parseIsolationParams(cmdline string) map[CPUIsolation]cpuset.CPUSet {
+	params := map[CPUIsolation]cpuset.CPUSet{}
+
+	for _, arg := range strings.Fields(cmdline) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		kind := CPUIsolation(kv[0])
+		switch kind {
+		case IsolCPUs, NohzFull, RCUNoCBs:
+		default:
+			continue
+		}
+
+		// isolcpus can be prefixed with flags, like isolcpus=domain,managed_irq,1-3
+		cpus := []string{}
+		for _, item := range strings.Split(kv[1], ",") {
+			if item != "" && unicode.IsDigit(rune(item[0])) {
+				cpus = append(cpus, item)
+			}
+		}
+
+		cset, err := cpuset.Parse(strings.Join(cpus, ","))
+		if err != nil {
+			sys.Error("invalid kernel parameter %s: %v", arg, err)
+			continue
+		}
+		params[kind] = cset
+	}
+
+	return params
+}
+
+// IsolatedBy gets the set of CPUs isolated by the given mechanism.
+func (sys *system) IsolatedBy(kind CPUIsolation) cpuset.CPUSet {
+	if cpus, ok := sys.isolation[kind]; ok {
+		return cpus.CPUSet()
+	}
+	return cpuset.NewCPUSet()
+}
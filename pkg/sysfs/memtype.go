@@ -0,0 +1,191 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// sysfs CXL bus devices subdirectory path
+	sysfsCXLDevicePath = "bus/cxl/devices"
+	// sysfs DAX bus devices subdirectory path
+	sysfsDAXDevicePath = "bus/dax/devices"
+	// HMAT read bandwidth from the closest initiators, relative to a node
+	sysfsNodeReadBandwidth = "access0/initiators/read_bandwidth"
+)
+
+// MemoryType is the type of memory of a NUMA node.
+type MemoryType string
+
+const (
+	// MemoryTypeDRAM is ordinary memory, attached to a node with CPUs.
+	MemoryTypeDRAM MemoryType = "dram"
+	// MemoryTypeHBM is high-bandwidth memory of a memory-only node.
+	MemoryTypeHBM MemoryType = "hbm"
+	// MemoryTypeCXL is memory of a CXL.mem device, in a memory-only node.
+	MemoryTypeCXL MemoryType = "cxl"
+	// MemoryTypePMEM is persistent memory used as system RAM, in a memory-only node.
+	MemoryTypePMEM MemoryType = "pmem"
+	// MemoryTypeUnknown is memory of a memory-only node of undetermined type.
+	MemoryTypeUnknown MemoryType = "unknown"
+)
+
+// Tier returns the memory tier of the memory type, 0 being the fastest.
+//
+// DRAM and HBM are in the top tier, CXL memory and memory of unknown type in
+// the tier below them, and PMEM in the tier below that.
+func (t MemoryType) Tier() int {
+	switch t {
+	case MemoryTypeDRAM, MemoryTypeHBM:
+		return 0
+	case MemoryTypeCXL, MemoryTypeUnknown:
+		return 1
+	case MemoryTypePMEM:
+		return 2
+	}
+	return 0
+}
+
+// ParseMemoryType parses a memory type.
+func ParseMemoryType(value string) (MemoryType, error) {
+	switch t := MemoryType(strings.ToLower(strings.TrimSpace(value))); t {
+	case MemoryTypeDRAM, MemoryTypeHBM, MemoryTypeCXL, MemoryTypePMEM:
+		return t, nil
+	}
+	return "", fmt.Errorf("invalid memory type %q", value)
+}
+
+// CXLMemDevice is a CXL.mem device present in the system.
+type CXLMemDevice struct {
+	Name     string // device name, for instance mem0
+	Node     ID     // NUMA node the device is attached to, -1 if unknown
+	RAMSize  uint64 // volatile capacity in bytes
+	PMEMSize uint64 // persistent capacity in bytes
+	Serial   string // device serial number
+}
+
+// String returns the CXL.mem device as a string.
+func (dev *CXLMemDevice) String() string {
+	return fmt.Sprintf("%s (serial %s, node #%d, ram %d, pmem %d)",
+		dev.Name, dev.Serial, dev.Node, dev.RAMSize, dev.PMEMSize)
+}
+
+// CXLMemDevices returns the discovered CXL.mem devices, sorted by name.
+func (sys *system) CXLMemDevices() []*CXLMemDevice {
+	devices := make([]*CXLMemDevice, len(sys.cxlmems))
+	copy(devices, sys.cxlmems)
+	return devices
+}
+
+// discoverMemoryTypes discovers CXL.mem devices and the memory type of all nodes.
+//
+// Memory-only nodes onlined from a DAX device of a CXL region are CXL memory,
+// those onlined from any other DAX device are PMEM. Other memory-only nodes are
+// HBM only if their HMAT read bandwidth exceeds that of every node with CPUs.
+// Without such evidence, for instance for CXL memory onlined by the firmware,
+// their type is unknown. Nodes with CPUs are DRAM.
+func (sys *system) discoverMemoryTypes() error {
+	if err := sys.discoverCXLMemDevices(); err != nil {
+		return err
+	}
+
+	cxl := NewIDSet()
+	entries, _ := filepath.Glob(filepath.Join(sys.path, sysfsCXLDevicePath,
+		"region*", "dax_region*", "dax*", "target_node"))
+	for _, entry := range entries {
+		node := ID(-1)
+		if _, err := readSysfsEntry(filepath.Dir(entry), "target_node", &node); err == nil {
+			cxl.Add(node)
+		}
+	}
+
+	dax := NewIDSet()
+	entries, _ = filepath.Glob(filepath.Join(sys.path, sysfsDAXDevicePath, "dax*", "target_node"))
+	for _, entry := range entries {
+		node := ID(-1)
+		if _, err := readSysfsEntry(filepath.Dir(entry), "target_node", &node); err == nil {
+			dax.Add(node)
+		}
+	}
+
+	dramBandwidth := uint64(0)
+	for _, n := range sys.nodes {
+		if !n.IsMemoryOnly() {
+			if bw := n.readBandwidth(); bw > dramBandwidth {
+				dramBandwidth = bw
+			}
+		}
+	}
+
+	for id, n := range sys.nodes {
+		switch {
+		case !n.IsMemoryOnly():
+			n.memtype = MemoryTypeDRAM
+		case cxl.Has(id):
+			n.memtype = MemoryTypeCXL
+		case dax.Has(id):
+			n.memtype = MemoryTypePMEM
+		case dramBandwidth > 0 && n.readBandwidth() > dramBandwidth:
+			n.memtype = MemoryTypeHBM
+		default:
+			n.memtype = MemoryTypeUnknown
+		}
+	}
+
+	return nil
+}
+
+// readBandwidth returns the HMAT read bandwidth of the node in MB/s, 0 if unknown.
+func (n *node) readBandwidth() uint64 {
+	bw := uint64(0)
+	if _, err := readSysfsEntry(n.path, sysfsNodeReadBandwidth, &bw); err != nil {
+		return 0
+	}
+	return bw
+}
+
+// discoverCXLMemDevices discovers the CXL.mem devices present in the system.
+func (sys *system) discoverCXLMemDevices() error {
+	if sys.cxlmems != nil {
+		return nil
+	}
+
+	sys.cxlmems = []*CXLMemDevice{}
+
+	entries, _ := filepath.Glob(filepath.Join(sys.path, sysfsCXLDevicePath, "mem[0-9]*"))
+	for _, path := range entries {
+		dev := &CXLMemDevice{
+			Name: filepath.Base(path),
+			Node: ID(-1),
+		}
+		if _, err := readSysfsEntry(path, "numa_node", &dev.Node); err != nil {
+			return err
+		}
+		readSysfsEntry(path, "serial", &dev.Serial)
+		readSysfsEntry(path, "ram/size", &dev.RAMSize)
+		readSysfsEntry(path, "pmem/size", &dev.PMEMSize)
+		sys.cxlmems = append(sys.cxlmems, dev)
+	}
+
+	sort.Slice(sys.cxlmems, func(i, j int) bool {
+		return sys.cxlmems[i].Name < sys.cxlmems[j].Name
+	})
+
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDiscoverMemoryTypes(t *testing.T) {
+	tcases := []struct {
+		name     string
+		files    map[string]string // files to create, relative to the sysfs root
+		memOnly  []ID              // memory-only nodes, the rest have CPUs
+		expected map[ID]MemoryType // expected memory type by node
+	}{
+		{
+			name:    "no evidence",
+			memOnly: []ID{2},
+			expected: map[ID]MemoryType{
+				0: MemoryTypeDRAM,
+				1: MemoryTypeDRAM,
+				2: MemoryTypeUnknown,
+			},
+		},
+		{
+			name: "HMAT bandwidth above DRAM",
+			files: map[string]string{
+				"devices/system/node/node0/" + sysfsNodeReadBandwidth: "100000\n",
+				"devices/system/node/node1/" + sysfsNodeReadBandwidth: "120000\n",
+				"devices/system/node/node2/" + sysfsNodeReadBandwidth: "400000\n",
+				"devices/system/node/node3/" + sysfsNodeReadBandwidth: "120000\n",
+			},
+			memOnly: []ID{2, 3},
+			expected: map[ID]MemoryType{
+				0: MemoryTypeDRAM,
+				1: MemoryTypeDRAM,
+				2: MemoryTypeHBM,
+				3: MemoryTypeUnknown,
+			},
+		},
+		{
+			name: "HMAT bandwidth only for memory-only node",
+			files: map[string]string{
+				"devices/system/node/node2/" + sysfsNodeReadBandwidth: "400000\n",
+			},
+			memOnly: []ID{2},
+			expected: map[ID]MemoryType{
+				0: MemoryTypeDRAM,
+				1: MemoryTypeDRAM,
+				2: MemoryTypeUnknown,
+			},
+		},
+		{
+			name: "CXL region and DAX device",
+			files: map[string]string{
+				"devices/system/node/node0/" + sysfsNodeReadBandwidth:          "100000\n",
+				"devices/system/node/node2/" + sysfsNodeReadBandwidth:          "400000\n",
+				"devices/system/node/node3/" + sysfsNodeReadBandwidth:          "400000\n",
+				sysfsCXLDevicePath + "/region0/dax_region0/dax0.0/target_node": "2\n",
+				sysfsDAXDevicePath + "/dax1.0/target_node":                     "3\n",
+			},
+			memOnly: []ID{2, 3},
+			expected: map[ID]MemoryType{
+				0: MemoryTypeDRAM,
+				1: MemoryTypeDRAM,
+				2: MemoryTypeCXL,
+				3: MemoryTypePMEM,
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "memtype-test")
+			if err != nil {
+				t.Fatalf("failed to create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(root)
+
+			for file, content := range tc.files {
+				path := filepath.Join(root, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("failed to create directory for %s: %v", file, err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("failed to write %s: %v", file, err)
+				}
+			}
+
+			memOnly := NewIDSet(tc.memOnly...)
+			sys := &system{path: root, nodes: map[ID]*node{}}
+			for id := range tc.expected {
+				n := &node{
+					path: filepath.Join(root, sysfsNumaNodePath, "node"+strconv.Itoa(int(id))),
+					id:   id,
+					cpus: NewIDSet(),
+				}
+				if !memOnly.Has(id) {
+					n.cpus.Add(ID(2 * id))
+				}
+				sys.nodes[id] = n
+			}
+
+			if err := sys.discoverMemoryTypes(); err != nil {
+				t.Fatalf("failed to discover memory types: %v", err)
+			}
+			for id, expected := range tc.expected {
+				if memtype := sys.nodes[id].memtype; memtype != expected {
+					t.Errorf("node #%d: expected memory type %q, got %q", id, expected, memtype)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMemoryType(t *testing.T) {
+	for _, value := range []string{"dram", "HBM", " cxl ", "pmem"} {
+		if _, err := ParseMemoryType(value); err != nil {
+			t.Errorf("failed to parse memory type %q: %v", value, err)
+		}
+	}
+	for _, value := range []string{"", "unknown", "nvdimm"} {
+		if _, err := ParseMemoryType(value); err == nil {
+			t.Errorf("memory type %q parsed, expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sysfs powercap subdirectory path of RAPL package zones
+	sysfsRAPLPath = "class/powercap"
+	// samples more than this many intervals apart are too old to average over
+	maxSampleIntervals = 4
+)
+
+// PowerZone is the RAPL power zone of a physical package.
+//
+// Power is given in microwatts and energy in microjoules, like in sysfs. The
+// power limit of a zone is its long-term limit (constraint 0).
+type PowerZone struct {
+	sync.Mutex
+	Name       string    // zone name, for instance intel-rapl:0
+	Package    ID        // physical package of the zone
+	path       string    // sysfs directory of the zone
+	maxEnergy  uint64    // range of the energy counter
+	lastEnergy uint64    // energy counter at last sample
+	lastSample time.Time // time of the last sample
+	power      uint64    // average power between the last two samples
+}
+
+// DiscoverPowerZones discovers the RAPL package power zones of the system.
+func DiscoverPowerZones() ([]*PowerZone, error) {
+	return DiscoverPowerZonesAt(SysfsRootPath)
+}
+
+// DiscoverPowerZonesAt discovers the RAPL package power zones from sysfs mounted at path.
+func DiscoverPowerZonesAt(path string) ([]*PowerZone, error) {
+	zones := []*PowerZone{}
+
+	entries, _ := filepath.Glob(filepath.Join(path, sysfsRAPLPath, "intel-rapl:[0-9]*"))
+	for _, entry := range entries {
+		name := filepath.Base(entry)
+		if strings.Count(name, ":") != 1 {
+			continue // subzone, for instance core or dram
+		}
+
+		zoneName := ""
+		if _, err := readSysfsEntry(entry, "name", &zoneName); err != nil {
+			return nil, err
+		}
+		pkg := ID(-1)
+		if _, err := fmt.Sscanf(zoneName, "package-%d", &pkg); err != nil {
+			continue
+		}
+
+		z := &PowerZone{
+			Name:    name,
+			Package: pkg,
+			path:    entry,
+		}
+		if _, err := readSysfsEntry(entry, "max_energy_range_uj", &z.maxEnergy); err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Package < zones[j].Package
+	})
+
+	return zones, nil
+}
+
+// String returns the power zone as a string.
+func (z *PowerZone) String() string {
+	return fmt.Sprintf("%s (package #%d)", z.Name, z.Package)
+}
+
+// PowerLimit returns the current power limit of the zone.
+func (z *PowerZone) PowerLimit() (uint64, error) {
+	limit := uint64(0)
+	_, err := readSysfsEntry(z.path, "constraint_0_power_limit_uw", &limit)
+	return limit, err
+}
+
+// SetPowerLimit sets the power limit of the zone, returning the previous limit.
+func (z *PowerZone) SetPowerLimit(limit uint64) (uint64, error) {
+	old := uint64(0)
+	_, err := writeSysfsEntry(z.path, "constraint_0_power_limit_uw", limit, &old)
+	return old, err
+}
+
+// MaxPower returns the highest power limit the zone can be set to, 0 if unknown.
+func (z *PowerZone) MaxPower() uint64 {
+	max := uint64(0)
+	readSysfsEntry(z.path, "constraint_0_max_power_uw", &max)
+	return max
+}
+
+// Power samples the energy counter and returns the average power since the last sample.
+//
+// Samples less than interval apart return the last calculated power. A sample
+// more than a few intervals after the previous one only starts a new baseline,
+// instead of averaging over a long, possibly idle, period. False is returned
+// until two samples have been taken close enough to each other.
+func (z *PowerZone) Power(interval time.Duration) (uint64, bool) {
+	z.Lock()
+	defer z.Unlock()
+
+	now := time.Now()
+	if !z.lastSample.IsZero() && now.Sub(z.lastSample) < interval {
+		return z.power, z.power != 0
+	}
+
+	energy := uint64(0)
+	if _, err := readSysfsEntry(z.path, "energy_uj", &energy); err != nil {
+		return z.power, z.power != 0
+	}
+
+	if !z.lastSample.IsZero() && now.Sub(z.lastSample) > maxSampleIntervals*interval {
+		z.power = 0
+	} else if !z.lastSample.IsZero() {
+		used := energy - z.lastEnergy
+		if energy < z.lastEnergy {
+			used = z.maxEnergy - z.lastEnergy + energy
+		}
+		if elapsed := now.Sub(z.lastSample).Seconds(); elapsed > 0 {
+			z.power = uint64(float64(used) / elapsed)
+		}
+	}
+	z.lastEnergy = energy
+	z.lastSample = now
+
+	return z.power, z.power != 0
+}
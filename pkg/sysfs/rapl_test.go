@@ -0,0 +1,136 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeZoneEntry writes an entry of a power zone in a fake powercap tree.
+func writeZoneEntry(t *testing.T, dir, entry, value string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, entry), []byte(value+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s of %s: %v", entry, dir, err)
+	}
+}
+
+// newFakePowerZones creates a fake powercap tree with two packages and a subzone.
+func newFakePowerZones(t *testing.T) (string, func()) {
+	root, err := ioutil.TempDir("", "rapl-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+
+	base := filepath.Join(root, sysfsRAPLPath)
+	for _, pkg := range []int{1, 0} {
+		dir := filepath.Join(base, "intel-rapl:"+strconv.Itoa(pkg))
+		writeZoneEntry(t, dir, "name", "package-"+strconv.Itoa(pkg))
+		writeZoneEntry(t, dir, "max_energy_range_uj", "1000000")
+		writeZoneEntry(t, dir, "energy_uj", "0")
+		writeZoneEntry(t, dir, "constraint_0_power_limit_uw", "150000000")
+		writeZoneEntry(t, dir, "constraint_0_max_power_uw", "200000000")
+	}
+	writeZoneEntry(t, filepath.Join(base, "intel-rapl:0:0"), "name", "core")
+	writeZoneEntry(t, filepath.Join(base, "intel-rapl-mmio:0"), "name", "package-0")
+
+	return root, func() { os.RemoveAll(root) }
+}
+
+func TestDiscoverPowerZones(t *testing.T) {
+	root, cleanup := newFakePowerZones(t)
+	defer cleanup()
+
+	zones, err := DiscoverPowerZonesAt(root)
+	if err != nil {
+		t.Fatalf("failed to discover power zones: %v", err)
+	}
+	if len(zones) != 2 {
+		t.Fatalf("expected 2 package power zones, got %d", len(zones))
+	}
+	for i, z := range zones {
+		if z.Package != ID(i) || z.Name != "intel-rapl:"+strconv.Itoa(i) {
+			t.Errorf("expected zone #%d to be intel-rapl:%d of package #%d, got %s", i, i, i, z)
+		}
+		if z.maxEnergy != 1000000 {
+			t.Errorf("%s: expected energy range 1000000, got %d", z, z.maxEnergy)
+		}
+	}
+
+	z := zones[0]
+	if max := z.MaxPower(); max != 200000000 {
+		t.Errorf("%s: expected max power 200000000, got %d", z, max)
+	}
+	old, err := z.SetPowerLimit(100000000)
+	if err != nil || old != 150000000 {
+		t.Errorf("%s: expected previous limit 150000000, got %d (error %v)", z, old, err)
+	}
+	if limit, err := z.PowerLimit(); err != nil || limit != 100000000 {
+		t.Errorf("%s: expected limit 100000000, got %d (error %v)", z, limit, err)
+	}
+}
+
+func TestPower(t *testing.T) {
+	root, cleanup := newFakePowerZones(t)
+	defer cleanup()
+
+	zones, err := DiscoverPowerZonesAt(root)
+	if err != nil || len(zones) == 0 {
+		t.Fatalf("failed to discover power zones: %v", err)
+	}
+	z := zones[0]
+	interval := time.Second
+
+	if _, ok := z.Power(interval); ok {
+		t.Errorf("expected no power from a single sample")
+	}
+
+	// counter advancing 500000 uJ in 2 s, with and without wraparound
+	for _, tc := range []struct {
+		name   string
+		energy string
+	}{
+		{name: "counting up", energy: "500000"},
+		{name: "wraparound", energy: "0"},
+	} {
+		z.lastSample = time.Now().Add(-2 * interval)
+		writeZoneEntry(t, z.path, "energy_uj", tc.energy)
+		power, ok := z.Power(interval)
+		if !ok || power < 240000 || power > 250000 {
+			t.Errorf("%s: expected about 250000 uW, got %d (ok %v)", tc.name, power, ok)
+		}
+	}
+
+	// samples closer than interval return the last calculated power
+	writeZoneEntry(t, z.path, "energy_uj", "900000")
+	if power, ok := z.Power(interval); !ok || power < 240000 || power > 250000 {
+		t.Errorf("expected last power for a recent sample, got %d (ok %v)", power, ok)
+	}
+
+	// a stale sample starts a new baseline, instead of averaging over it
+	z.lastSample = time.Now().Add(-10 * maxSampleIntervals * interval)
+	if power, ok := z.Power(interval); ok {
+		t.Errorf("expected no power after a stale sample, got %d", power)
+	}
+	if z.lastEnergy != 900000 {
+		t.Errorf("expected a new baseline 900000, got %d", z.lastEnergy)
+	}
+}
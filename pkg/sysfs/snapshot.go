@@ -0,0 +1,233 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/intel/cri-resource-manager/pkg/log"
+)
+
+// topologyGlobs are the sysfs entries needed to reconstruct the hardware topology.
+var topologyGlobs = []string{
+	"devices/system/cpu/online",
+	"devices/system/cpu/present",
+	"devices/system/cpu/possible",
+	"devices/system/cpu/isolated",
+	"devices/system/cpu/nohz_full",
+	"devices/system/cpu/cpu[0-9]*/online",
+	"devices/system/cpu/cpu[0-9]*/node[0-9]*",
+	"devices/system/cpu/cpu[0-9]*/topology/*",
+	"devices/system/cpu/cpu[0-9]*/cpu_capacity",
+	"devices/system/cpu/cpu[0-9]*/cpufreq/base_frequency",
+	"devices/system/cpu/cpu[0-9]*/cpufreq/cpuinfo_*_freq",
+	"devices/system/cpu/cpu[0-9]*/cpufreq/energy_performance_preference",
+	"devices/system/cpu/cpu[0-9]*/cache/index[0-9]*/*",
+	"devices/system/node/online",
+	"devices/system/node/possible",
+	"devices/system/node/node[0-9]*/cpulist",
+	"devices/system/node/node[0-9]*/distance",
+	"devices/system/node/node[0-9]*/meminfo",
+	"devices/system/node/node[0-9]*/hugepages/hugepages-*kB/*_hugepages",
+	"devices/cpu_core/cpus",
+	"devices/cpu_atom/cpus",
+	"bus/cxl/devices/mem[0-9]*/numa_node",
+	"bus/cxl/devices/mem[0-9]*/serial",
+	"bus/cxl/devices/mem[0-9]*/ram/size",
+	"bus/cxl/devices/mem[0-9]*/pmem/size",
+	"bus/cxl/devices/region*/dax_region*/dax*/target_node",
+	"bus/dax/devices/dax*/target_node",
+}
+
+// fakeTopology is a topology snapshot to discover instead of the running system.
+var fakeTopology string
+
+// fakeRoot is the sysfs tree of fakeTopology, once it has been unpacked.
+var fakeRoot = struct {
+	sync.Once
+	path string
+	err  error
+}{}
+
+// SnapshotEntry is a sysfs entry captured in a topology snapshot.
+type SnapshotEntry struct {
+	Path string // path relative to the sysfs root
+	Dir  bool   // whether the entry is a directory, which only matters by its presence
+	Data []byte // content of the entry, if it is a file
+}
+
+// CaptureSnapshot captures the sysfs entries describing the hardware topology.
+//
+// Entries which can't be read, like write-only ones, are omitted. Directories,
+// like the NUMA node links of CPUs, are captured without their content.
+func CaptureSnapshot(path string) []*SnapshotEntry {
+	snapshot := []*SnapshotEntry{}
+	for _, glob := range topologyGlobs {
+		entries, _ := filepath.Glob(filepath.Join(path, glob))
+		for _, entry := range entries {
+			rel, _ := filepath.Rel(path, entry)
+			if info, err := os.Stat(entry); err == nil && info.IsDir() {
+				snapshot = append(snapshot, &SnapshotEntry{Path: rel, Dir: true})
+				continue
+			}
+			data, err := ioutil.ReadFile(entry)
+			if err != nil {
+				continue
+			}
+			snapshot = append(snapshot, &SnapshotEntry{Path: rel, Data: data})
+		}
+	}
+	return snapshot
+}
+
+// WriteSnapshot writes a gzipped tarball of the hardware topology of the sysfs tree at path.
+func WriteSnapshot(path, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return sysfsError(file, "failed to create snapshot: %v", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	for _, entry := range CaptureSnapshot(path) {
+		hdr := &tar.Header{
+			Name:    entry.Path,
+			Mode:    0600,
+			Size:    int64(len(entry.Data)),
+			ModTime: now,
+		}
+		if entry.Dir {
+			hdr.Name += "/"
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return sysfsError(file, "failed to add %s to snapshot: %v", entry.Path, err)
+		}
+		if _, err := tw.Write(entry.Data); err != nil {
+			return sysfsError(file, "failed to add %s to snapshot: %v", entry.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return sysfsError(file, "failed to finish snapshot: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		return sysfsError(file, "failed to finish snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// ExtractSnapshot unpacks a topology snapshot into a sysfs tree at dir.
+func ExtractSnapshot(file, dir string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return sysfsError(file, "failed to open snapshot: %v", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return sysfsError(file, "failed to read snapshot: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sysfsError(file, "failed to read snapshot: %v", err)
+		}
+
+		name := filepath.Clean(hdr.Name)
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			return sysfsError(file, "invalid snapshot entry %q", hdr.Name)
+		}
+		path := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return sysfsError(path, "failed to create directory: %v", err)
+			}
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return sysfsError(path, "failed to create directory: %v", err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return sysfsError(file, "failed to read snapshot entry %s: %v", name, err)
+		}
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return sysfsError(path, "failed to write: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// fakeSysfsRoot returns the sysfs tree of the fake topology, unpacking it if necessary.
+//
+// A snapshot is unpacked only once, into a temporary directory which is left in
+// place for inspection. A directory, for instance the sysfs tree of an unpacked
+// support bundle, is used as such.
+func fakeSysfsRoot() (string, error) {
+	fakeRoot.Do(func() {
+		log := logger.Get("sysfs")
+		if info, err := os.Stat(fakeTopology); err == nil && info.IsDir() {
+			log.Warn("using fake topology from sysfs tree %s", fakeTopology)
+			fakeRoot.path = fakeTopology
+			return
+		}
+		dir, err := ioutil.TempDir("", "cri-resmgr-sysfs.")
+		if err != nil {
+			fakeRoot.err = fmt.Errorf("failed to create directory for fake topology: %v", err)
+			return
+		}
+		if err := ExtractSnapshot(fakeTopology, dir); err != nil {
+			fakeRoot.err = err
+			return
+		}
+		log.Warn("using fake topology from snapshot %s, unpacked to %s", fakeTopology, dir)
+		fakeRoot.path = dir
+	})
+	return fakeRoot.path, fakeRoot.err
+}
+
+func init() {
+	flag.StringVar(&fakeTopology, "fake-topology", "",
+		"Topology snapshot, or sysfs tree, to use instead of discovering the hardware. For debugging only.")
+}
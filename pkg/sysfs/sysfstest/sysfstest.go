@@ -0,0 +1,361 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysfstest provides synthetic system topologies for unit testing.
+//
+// A Topology describes the CPU packages, NUMA nodes, cores and memory of a
+// system. It can be written out as a sysfs tree, which is then discovered by
+// the sysfs package like the sysfs of a real system, so tests get realistic
+// System, CPU, Node and CPUPackage objects without touching the host.
+package sysfstest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+// Topology describes a synthetic system.
+//
+// CPUs are enumerated like the kernel usually does: the first hyperthreads of
+// all cores first, then the second ones, and so on. Cores are enumerated by
+// NUMA node, NUMA nodes by package. The cores of a package are split evenly
+// among its dies, so a die consists of whole NUMA nodes or of a part of one,
+// depending on the number of dies and NUMA nodes. Memory-only nodes, for instance HBM or CXL
+// memory, are enumerated after all the NUMA nodes with CPUs.
+type Topology struct {
+	// Packages is the number of CPU packages (sockets).
+	Packages int
+	// NodesPerPackage is the number of NUMA nodes with CPUs per package.
+	NodesPerPackage int
+	// DiesPerPackage is the number of dies per package, 0 for a single one.
+	DiesPerPackage int
+	// CoresPerNode is the number of physical cores per NUMA node.
+	CoresPerNode int
+	// ThreadsPerCore is the number of hyperthreads per core.
+	ThreadsPerCore int
+	// MemoryPerNode is the amount of memory per NUMA node, in bytes.
+	MemoryPerNode uint64
+	// MemoryOnlyNodesPerPackage is the number of memory-only nodes per package.
+	MemoryOnlyNodesPerPackage int
+	// MemoryPerMemoryOnlyNode is the amount of memory per memory-only node, in bytes.
+	MemoryPerMemoryOnlyNode uint64
+	// LLCSize is the size of the last-level cache of each package, in bytes.
+	LLCSize uint64
+	// BaseFrequency is the base frequency of all CPUs, in kHz.
+	BaseFrequency uint64
+	// Isolated is the set of isolated CPUs.
+	Isolated cpuset.CPUSet
+}
+
+// Default NUMA node distances.
+const (
+	distanceLocal       = 10
+	distanceSamePackage = 11
+	distanceRemote      = 21
+	distanceMemOnly     = 17
+)
+
+// DefaultTopology returns a two-socket, four NUMA node system with 32 CPUs.
+func DefaultTopology() *Topology {
+	return &Topology{
+		Packages:        2,
+		NodesPerPackage: 2,
+		CoresPerNode:    4,
+		ThreadsPerCore:  2,
+		MemoryPerNode:   16 << 30,
+		LLCSize:         32 << 20,
+		BaseFrequency:   2000000,
+	}
+}
+
+// CPUCount returns the number of CPUs in the topology.
+func (t *Topology) CPUCount() int {
+	return t.coreCount() * t.threads()
+}
+
+// NodeCount returns the number of NUMA nodes, including memory-only ones.
+func (t *Topology) NodeCount() int {
+	return t.Packages * (t.NodesPerPackage + t.MemoryOnlyNodesPerPackage)
+}
+
+// NodeCPUs returns the CPUs of a NUMA node.
+func (t *Topology) NodeCPUs(node int) cpuset.CPUSet {
+	b := cpuset.NewBuilder()
+	if node < t.Packages*t.NodesPerPackage {
+		for core := node * t.CoresPerNode; core < (node+1)*t.CoresPerNode; core++ {
+			for thread := 0; thread < t.threads(); thread++ {
+				b.Add(t.cpuID(core, thread))
+			}
+		}
+	}
+	return b.Result()
+}
+
+// PackageCPUs returns the CPUs of a package.
+func (t *Topology) PackageCPUs(pkg int) cpuset.CPUSet {
+	cpus := cpuset.NewCPUSet()
+	for node := pkg * t.NodesPerPackage; node < (pkg+1)*t.NodesPerPackage; node++ {
+		cpus = cpus.Union(t.NodeCPUs(node))
+	}
+	return cpus
+}
+
+// DieCPUs returns the CPUs of a die of a package.
+func (t *Topology) DieCPUs(pkg, die int) cpuset.CPUSet {
+	b := cpuset.NewBuilder()
+	for core := pkg * t.coresPerPackage(); core < (pkg+1)*t.coresPerPackage(); core++ {
+		if t.coreDie(core) == die {
+			for thread := 0; thread < t.threads(); thread++ {
+				b.Add(t.cpuID(core, thread))
+			}
+		}
+	}
+	return b.Result()
+}
+
+// Write writes the topology as a sysfs tree under the given directory.
+func (t *Topology) Write(dir string) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+
+	cpuDir := filepath.Join(dir, "devices/system/cpu")
+	nodeDir := filepath.Join(dir, "devices/system/node")
+	all := cpuset.NewCPUSet()
+
+	for core := 0; core < t.coreCount(); core++ {
+		node := core / t.CoresPerNode
+		pkg := node / t.NodesPerPackage
+		siblings := cpuset.NewBuilder()
+		for thread := 0; thread < t.threads(); thread++ {
+			siblings.Add(t.cpuID(core, thread))
+		}
+		threads := siblings.Result()
+		all = all.Union(threads)
+
+		for _, id := range threads.ToSlice() {
+			cpu := filepath.Join(cpuDir, "cpu"+strconv.Itoa(id))
+			files := map[string]string{
+				"online":                          "1",
+				"topology/physical_package_id":    strconv.Itoa(pkg),
+				"topology/die_id":                 strconv.Itoa(t.coreDie(core)),
+				"topology/core_id":                strconv.Itoa(core % t.coresPerPackage()),
+				"topology/thread_siblings_list":   threads.String(),
+				"topology/core_siblings_list":     t.PackageCPUs(pkg).String(),
+				"node" + strconv.Itoa(node) + "/": "",
+			}
+			if t.LLCSize > 0 {
+				files["cache/index3/id"] = strconv.Itoa(pkg)
+				files["cache/index3/level"] = "3"
+				files["cache/index3/type"] = "Unified"
+				files["cache/index3/size"] = strconv.FormatUint(t.LLCSize>>10, 10) + "K"
+				files["cache/index3/shared_cpu_list"] = t.PackageCPUs(pkg).String()
+			}
+			if t.BaseFrequency > 0 {
+				freq := strconv.FormatUint(t.BaseFrequency, 10)
+				files["cpufreq/base_frequency"] = freq
+				files["cpufreq/cpuinfo_min_freq"] = strconv.FormatUint(t.BaseFrequency/2, 10)
+				files["cpufreq/cpuinfo_max_freq"] = strconv.FormatUint(t.BaseFrequency*2, 10)
+			}
+			if err := writeFiles(cpu, files); err != nil {
+				return err
+			}
+		}
+	}
+
+	files := map[string]string{
+		"online":   all.String(),
+		"possible": all.String(),
+		"present":  all.String(),
+		"offline":  "",
+		"isolated": t.Isolated.String(),
+	}
+	if err := writeFiles(cpuDir, files); err != nil {
+		return err
+	}
+
+	for node := 0; node < t.NodeCount(); node++ {
+		memory := t.MemoryPerNode
+		if t.isMemoryOnly(node) {
+			memory = t.MemoryPerMemoryOnlyNode
+		}
+		distance := []string{}
+		for other := 0; other < t.NodeCount(); other++ {
+			distance = append(distance, strconv.Itoa(t.distance(node, other)))
+		}
+		files := map[string]string{
+			"cpulist":  t.NodeCPUs(node).String(),
+			"distance": strings.Join(distance, " "),
+			"meminfo":  meminfo(node, memory),
+		}
+		if err := writeFiles(filepath.Join(nodeDir, "node"+strconv.Itoa(node)), files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Discover writes the topology under the given directory and discovers it.
+func (t *Topology) Discover(dir string, flags ...system.DiscoveryFlag) (system.System, error) {
+	if err := t.Write(dir); err != nil {
+		return nil, err
+	}
+	return system.DiscoverSystemAt(dir, flags...)
+}
+
+// NewSystem discovers the topology from a temporary sysfs tree.
+//
+// The returned function removes the temporary tree. It should be called once
+// the system is no longer used.
+func NewSystem(t *Topology, flags ...system.DiscoveryFlag) (system.System, func(), error) {
+	dir, err := ioutil.TempDir("", "sysfstest")
+	if err != nil {
+		return nil, nil, fmt.Errorf("sysfstest: failed to create sysfs directory: %v", err)
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+	sys, err := t.Discover(dir, flags...)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return sys, cleanup, nil
+}
+
+// validate checks that the topology is sane.
+func (t *Topology) validate() error {
+	if t.Packages < 1 || t.NodesPerPackage < 1 || t.CoresPerNode < 1 {
+		return fmt.Errorf("sysfstest: invalid topology, need at least one package, node and core")
+	}
+	if t.ThreadsPerCore < 0 || t.MemoryOnlyNodesPerPackage < 0 || t.DiesPerPackage < 0 {
+		return fmt.Errorf("sysfstest: invalid topology, negative thread, node or die count")
+	}
+	if t.coresPerPackage()%t.dies() != 0 {
+		return fmt.Errorf("sysfstest: invalid topology, %d cores can't be split evenly among %d dies",
+			t.coresPerPackage(), t.dies())
+	}
+	for _, id := range t.Isolated.ToSlice() {
+		if id >= t.CPUCount() {
+			return fmt.Errorf("sysfstest: isolated CPU %d not in topology", id)
+		}
+	}
+	return nil
+}
+
+// threads returns the number of threads per core, at least one.
+func (t *Topology) threads() int {
+	if t.ThreadsPerCore < 1 {
+		return 1
+	}
+	return t.ThreadsPerCore
+}
+
+// dies returns the number of dies per package, at least one.
+func (t *Topology) dies() int {
+	if t.DiesPerPackage < 1 {
+		return 1
+	}
+	return t.DiesPerPackage
+}
+
+// coreDie returns the die of a physical core, within its package.
+func (t *Topology) coreDie(core int) int {
+	return (core % t.coresPerPackage()) / (t.coresPerPackage() / t.dies())
+}
+
+// coresPerPackage returns the number of physical cores per package.
+func (t *Topology) coresPerPackage() int {
+	return t.NodesPerPackage * t.CoresPerNode
+}
+
+// coreCount returns the number of physical cores.
+func (t *Topology) coreCount() int {
+	return t.Packages * t.coresPerPackage()
+}
+
+// cpuID returns the id of the given thread of the given core.
+func (t *Topology) cpuID(core, thread int) int {
+	return thread*t.coreCount() + core
+}
+
+// isMemoryOnly checks if a node is a memory-only one.
+func (t *Topology) isMemoryOnly(node int) bool {
+	return node >= t.Packages*t.NodesPerPackage
+}
+
+// nodePackage returns the package of a node.
+func (t *Topology) nodePackage(node int) int {
+	if t.isMemoryOnly(node) {
+		return (node - t.Packages*t.NodesPerPackage) / t.MemoryOnlyNodesPerPackage
+	}
+	return node / t.NodesPerPackage
+}
+
+// distance returns the distance between two nodes.
+func (t *Topology) distance(from, to int) int {
+	switch {
+	case from == to:
+		return distanceLocal
+	case t.nodePackage(from) != t.nodePackage(to):
+		return distanceRemote + 10*btoi(t.isMemoryOnly(from) || t.isMemoryOnly(to))
+	case t.isMemoryOnly(from) || t.isMemoryOnly(to):
+		return distanceMemOnly
+	}
+	return distanceSamePackage
+}
+
+// btoi converts a boolean to an integer.
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// meminfo returns the meminfo of a node with the given amount of memory.
+func meminfo(node int, memory uint64) string {
+	kB := memory >> 10
+	return fmt.Sprintf("Node %d MemTotal:       %d kB\n", node, kB) +
+		fmt.Sprintf("Node %d MemFree:        %d kB\n", node, kB) +
+		fmt.Sprintf("Node %d MemUsed:        %d kB\n", node, 0)
+}
+
+// writeFiles writes a set of files, or directories for names ending in '/'.
+func writeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("sysfstest: failed to create %s: %v", path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("sysfstest: failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			return fmt.Errorf("sysfstest: failed to write %s: %v", path, err)
+		}
+	}
+	return nil
+}
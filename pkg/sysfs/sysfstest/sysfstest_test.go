@@ -0,0 +1,235 @@
+// Copyright 2020 Intel Corporation. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfstest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
+	system "github.com/intel/cri-resource-manager/pkg/sysfs"
+)
+
+func TestDiscoverTopology(t *testing.T) {
+	topology := DefaultTopology()
+	topology.MemoryOnlyNodesPerPackage = 1
+	topology.MemoryPerMemoryOnlyNode = 64 << 30
+	topology.Isolated = cpuset.NewCPUSet(0, 16)
+
+	sys, cleanup, err := NewSystem(topology)
+	if err != nil {
+		t.Fatalf("failed to discover topology: %v", err)
+	}
+	defer cleanup()
+
+	if cnt := sys.CPUCount(); cnt != 32 {
+		t.Errorf("expected 32 CPUs, got %d", cnt)
+	}
+	if cnt := sys.PackageCount(); cnt != 2 {
+		t.Errorf("expected 2 packages, got %d", cnt)
+	}
+	if cnt := sys.NUMANodeCount(); cnt != 6 {
+		t.Errorf("expected 6 NUMA nodes, got %d", cnt)
+	}
+	if cpus := sys.Isolated(); !cpus.Equals(topology.Isolated) {
+		t.Errorf("expected isolated CPUs %s, got %s", topology.Isolated, cpus)
+	}
+
+	for id := 0; id < 4; id++ {
+		node := sys.Node(system.ID(id))
+		if cpus := node.CPUSet(); !cpus.Equals(topology.NodeCPUs(id)) {
+			t.Errorf("expected node #%d CPUs %s, got %s", id, topology.NodeCPUs(id), cpus)
+		}
+		if pkg := node.PackageID(); pkg != system.ID(id/2) {
+			t.Errorf("expected node #%d in package %d, got %d", id, id/2, pkg)
+		}
+		info, err := node.MemoryInfo()
+		if err != nil {
+			t.Errorf("failed to get memory info of node #%d: %v", id, err)
+		} else if info.MemTotal != topology.MemoryPerNode {
+			t.Errorf("expected %d bytes of memory in node #%d, got %d",
+				topology.MemoryPerNode, id, info.MemTotal)
+		}
+	}
+
+	for _, id := range []system.ID{4, 5} {
+		node := sys.Node(id)
+		if !node.IsMemoryOnly() {
+			t.Errorf("expected node #%d to be memory-only", id)
+		}
+		if pkg := node.PackageID(); pkg != id-4 {
+			t.Errorf("expected memory-only node #%d in package %d, got %d", id, id-4, pkg)
+		}
+	}
+
+	cpu := sys.CPU(1)
+	if threads := cpu.ThreadCPUSet(); !threads.Equals(cpuset.NewCPUSet(1, 17)) {
+		t.Errorf("expected CPU #1 hyperthreads 1,17, got %s", threads)
+	}
+	if freq := cpu.BaseFrequency(); freq != topology.BaseFrequency {
+		t.Errorf("expected CPU #1 base frequency %d, got %d", topology.BaseFrequency, freq)
+	}
+	if llc := sys.LLC(0); llc == nil || !llc.CPUSet().Equals(topology.PackageCPUs(0)) {
+		t.Errorf("expected LLC #0 shared by CPUs %s, got %v", topology.PackageCPUs(0), llc)
+	}
+}
+
+func TestDiscoverDies(t *testing.T) {
+	topology := DefaultTopology()
+	topology.NodesPerPackage = 4
+	topology.DiesPerPackage = 2
+
+	sys, cleanup, err := NewSystem(topology)
+	if err != nil {
+		t.Fatalf("failed to discover topology: %v", err)
+	}
+	defer cleanup()
+
+	for _, pkgID := range sys.PackageIDs() {
+		pkg := sys.Package(pkgID)
+		dies := pkg.DieIDs()
+		if len(dies) != 2 {
+			t.Fatalf("expected 2 dies in package %d, got %v", pkgID, dies)
+		}
+		for _, die := range dies {
+			expected := topology.DieCPUs(int(pkgID), int(die))
+			if cpus := pkg.DieCPUSet(die); !cpus.Equals(expected) {
+				t.Errorf("expected package %d die %d CPUs %s, got %s", pkgID, die, expected, cpus)
+			}
+			nodes := pkg.DieNodeIDs(die)
+			if len(nodes) != 2 {
+				t.Errorf("expected 2 nodes in package %d die %d, got %v", pkgID, die, nodes)
+			}
+			for _, id := range nodes {
+				for _, cpu := range sys.Node(id).CPUSet().ToSlice() {
+					if d := sys.CPU(system.ID(cpu)).DieID(); d != die {
+						t.Errorf("expected CPU #%d on die %d, got %d", cpu, die, d)
+					}
+				}
+			}
+		}
+	}
+
+	topology.DiesPerPackage = 3
+	if _, _, err := NewSystem(topology); err == nil {
+		t.Errorf("expected failure splitting 16 cores among 3 dies")
+	}
+}
+
+func TestDiscoverDiesWithinNode(t *testing.T) {
+	topology := DefaultTopology()
+	topology.NodesPerPackage = 1
+	topology.DiesPerPackage = 2
+
+	sys, cleanup, err := NewSystem(topology)
+	if err != nil {
+		t.Fatalf("failed to discover topology: %v", err)
+	}
+	defer cleanup()
+
+	for _, pkgID := range sys.PackageIDs() {
+		pkg := sys.Package(pkgID)
+		dies := pkg.DieIDs()
+		if len(dies) != 2 {
+			t.Fatalf("expected 2 dies in package %d, got %v", pkgID, dies)
+		}
+		all := cpuset.NewCPUSet()
+		for _, die := range dies {
+			expected := topology.DieCPUs(int(pkgID), int(die))
+			if cpus := pkg.DieCPUSet(die); !cpus.Equals(expected) || cpus.Size() != 4 {
+				t.Errorf("expected package %d die %d CPUs %s, got %s", pkgID, die, expected, cpus)
+			}
+			if nodes := pkg.DieNodeIDs(die); len(nodes) != 1 {
+				t.Errorf("expected 1 node in package %d die %d, got %v", pkgID, die, nodes)
+			}
+			all = all.Union(expected)
+		}
+		if !all.Equals(topology.PackageCPUs(int(pkgID))) {
+			t.Errorf("expected dies of package %d to cover CPUs %s, got %s",
+				pkgID, topology.PackageCPUs(int(pkgID)), all)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	topology := DefaultTopology()
+	topology.NodesPerPackage = 4
+	topology.DiesPerPackage = 2
+	topology.MemoryOnlyNodesPerPackage = 1
+	topology.Isolated = cpuset.NewCPUSet(3, 19)
+
+	dir, err := ioutil.TempDir("", "sysfstest")
+	if err != nil {
+		t.Fatalf("failed to create test directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	live, snapshot, fake := filepath.Join(dir, "live"), filepath.Join(dir, "snapshot.tgz"), filepath.Join(dir, "fake")
+	orig, err := topology.Discover(live)
+	if err != nil {
+		t.Fatalf("failed to discover topology: %v", err)
+	}
+	if err := system.WriteSnapshot(live, snapshot); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	if err := system.ExtractSnapshot(snapshot, fake); err != nil {
+		t.Fatalf("failed to extract snapshot: %v", err)
+	}
+	sys, err := system.DiscoverSystemAt(fake)
+	if err != nil {
+		t.Fatalf("failed to discover snapshot: %v", err)
+	}
+
+	if cpus, expected := sys.CPUSet(), orig.CPUSet(); !cpus.Equals(expected) {
+		t.Errorf("expected CPUs %s, got %s", expected, cpus)
+	}
+	if cpus, expected := sys.Isolated(), orig.Isolated(); !cpus.Equals(expected) {
+		t.Errorf("expected isolated CPUs %s, got %s", expected, cpus)
+	}
+	if cnt, expected := sys.NUMANodeCount(), orig.NUMANodeCount(); cnt != expected {
+		t.Errorf("expected %d NUMA nodes, got %d", expected, cnt)
+	}
+	for _, id := range orig.NodeIDs() {
+		node, expected := sys.Node(id), orig.Node(id)
+		if !node.CPUSet().Equals(expected.CPUSet()) {
+			t.Errorf("expected node #%d CPUs %s, got %s", id, expected.CPUSet(), node.CPUSet())
+		}
+		if node.PackageID() != expected.PackageID() {
+			t.Errorf("expected node #%d in package %d, got %d", id, expected.PackageID(), node.PackageID())
+		}
+		if node.MemoryType() != expected.MemoryType() {
+			t.Errorf("expected node #%d memory %s, got %s", id, expected.MemoryType(), node.MemoryType())
+		}
+	}
+	for _, id := range orig.PackageIDs() {
+		pkg, expected := sys.Package(id), orig.Package(id)
+		for _, die := range expected.DieIDs() {
+			if cpus := pkg.DieCPUSet(die); !cpus.Equals(expected.DieCPUSet(die)) {
+				t.Errorf("expected package %d die %d CPUs %s, got %s", id, die, expected.DieCPUSet(die), cpus)
+			}
+		}
+	}
+	for _, id := range orig.CPUIDs() {
+		cpu, expected := sys.CPU(id), orig.CPU(id)
+		if !cpu.ThreadCPUSet().Equals(expected.ThreadCPUSet()) {
+			t.Errorf("expected CPU #%d hyperthreads %s, got %s", id, expected.ThreadCPUSet(), cpu.ThreadCPUSet())
+		}
+		if cpu.BaseFrequency() != expected.BaseFrequency() {
+			t.Errorf("expected CPU #%d base frequency %d, got %d", id, expected.BaseFrequency(), cpu.BaseFrequency())
+		}
+	}
+}
@@ -21,8 +21,7 @@ import (
 	"strconv"
 	"strings"
 
-	"k8s.io/kubernetes/pkg/kubelet/cm/cpuset"
-
+	"github.com/intel/cri-resource-manager/pkg/cpuset"
 	logger "github.com/intel/cri-resource-manager/pkg/log"
 )
 
@@ -45,6 +44,10 @@ const (
 	DiscoverMemTopology
 	// DiscoverCache requests discovering CPU cache details.
 	DiscoverCache
+	// DiscoverBlockDevices requests discovering block devices.
+	DiscoverBlockDevices
+	// DiscoverWorkQueues requests discovering accelerator work queues.
+	DiscoverWorkQueues
 	// DiscoverNone is the zero value for discovery flags.
 	DiscoverNone DiscoveryFlag = 0
 	// DiscoverAll requests full supported discovery.
@@ -72,20 +75,33 @@ type System interface {
 	CPU(id ID) CPU
 	Offlined() cpuset.CPUSet
 	Isolated() cpuset.CPUSet
+	IsolatedBy(kind CPUIsolation) cpuset.CPUSet
+	CoreTypeCPUs(t CoreType) cpuset.CPUSet
+	LLCIDs() []ID
+	LLC(id ID) *Cache
+	BlockDevices() []*BlockDevice
+	CXLMemDevices() []*CXLMemDevice
+	WorkQueues() []*WorkQueue
 }
 
 // System devices
 type system struct {
-	logger.Logger                    // our logger instance
-	flags         DiscoveryFlag      // system discovery flags
-	path          string             // sysfs mount point
-	packages      map[ID]*cpuPackage // physical packages
-	nodes         map[ID]*node       // NUMA nodes
-	cpus          map[ID]*cpu        // CPUs
-	cache         map[ID]*Cache      // Cache
-	offline       IDSet              // offlined CPUs
-	isolated      IDSet              // isolated CPUs
-	threads       int                // hyperthreads per core
+	logger.Logger                        // our logger instance
+	flags         DiscoveryFlag          // system discovery flags
+	path          string                 // sysfs mount point
+	packages      map[ID]*cpuPackage     // physical packages
+	nodes         map[ID]*node           // NUMA nodes
+	cpus          map[ID]*cpu            // CPUs
+	cache         map[ID]*Cache          // Cache
+	llcs          map[ID]*Cache          // last-level caches
+	offline       IDSet                  // offlined CPUs
+	isolated      IDSet                  // isolated CPUs
+	isolation     map[CPUIsolation]IDSet // CPUs by kernel isolation mechanism
+	coretypes     map[CoreType]IDSet     // CPUs by core type, on hybrid systems
+	threads       int                    // hyperthreads per core
+	blockdevs     []*BlockDevice         // block devices
+	cxlmems       []*CXLMemDevice        // CXL.mem devices
+	workqueues    []*WorkQueue           // accelerator work queues
 }
 
 // CPUPackage is a physical package (a collection of CPUs).
@@ -93,12 +109,18 @@ type CPUPackage interface {
 	ID() ID
 	CPUSet() cpuset.CPUSet
 	NodeIDs() []ID
+	DieIDs() []ID
+	DieCPUSet(id ID) cpuset.CPUSet
+	DieNodeIDs(id ID) []ID
 }
 
 type cpuPackage struct {
-	id    ID    // package id
-	cpus  IDSet // CPUs in this package
-	nodes IDSet // nodes in this package
+	id       ID           // package id
+	cpus     IDSet        // CPUs in this package
+	nodes    IDSet        // nodes in this package
+	dies     IDSet        // dies in this package
+	dieCPUs  map[ID]IDSet // CPUs by die
+	dieNodes map[ID]IDSet // nodes by die
 }
 
 // Node represents a NUMA node.
@@ -109,21 +131,28 @@ type Node interface {
 	Distance() []int
 	DistanceFrom(id ID) int
 	MemoryInfo() (*MemInfo, error)
+	HugePageInfo() (map[uint64]*HugePageInfo, error)
+	IsMemoryOnly() bool
+	CPUNodeIDs() []ID
+	MemoryType() MemoryType
 }
 
 // Node is a NUMA node.
 type node struct {
-	path     string // sysfs path
-	id       ID     // node id
-	pkg      ID     // package id
-	cpus     IDSet  // cpus in this node
-	distance []int  // distance/cost to other NUMA nodes
+	path     string     // sysfs path
+	id       ID         // node id
+	pkg      ID         // package id
+	cpus     IDSet      // cpus in this node
+	distance []int      // distance/cost to other NUMA nodes
+	cpunodes IDSet      // closest nodes with CPUs, for memory-only nodes
+	memtype  MemoryType // type of memory in this node
 }
 
 // CPU is a CPU core.
 type CPU interface {
 	ID() ID
 	PackageID() ID
+	DieID() ID
 	NodeID() ID
 	CoreID() ID
 	ThreadCPUSet() cpuset.CPUSet
@@ -131,20 +160,26 @@ type CPU interface {
 	FrequencyRange() CPUFreq
 	Online() bool
 	Isolated() bool
+	CoreType() CoreType
 	SetFrequencyLimits(min, max uint64) error
+	EnergyPerformancePreference() string
+	SetEnergyPerformancePreference(epp string) error
 }
 
 type cpu struct {
-	path     string  // sysfs path
-	id       ID      // CPU id
-	pkg      ID      // package id
-	node     ID      // node id
-	core     ID      // core id
-	threads  IDSet   // sibling/hyper-threads
-	baseFreq uint64  // CPU base frequency
-	freq     CPUFreq // CPU frequencies
-	online   bool    // whether this CPU is online
-	isolated bool    // whether this CPU is isolated
+	path     string   // sysfs path
+	id       ID       // CPU id
+	pkg      ID       // package id
+	die      ID       // die id, within the package
+	node     ID       // node id
+	core     ID       // core id
+	threads  IDSet    // sibling/hyper-threads
+	baseFreq uint64   // CPU base frequency
+	freq     CPUFreq  // CPU frequencies
+	epp      string   // energy-performance preference, as discovered
+	online   bool     // whether this CPU is online
+	isolated bool     // whether this CPU is isolated
+	coretype CoreType // type of the core, on hybrid systems
 }
 
 // CPUFreq is a CPU frequency scaling range
@@ -161,6 +196,13 @@ type MemInfo struct {
 	MemUsed  uint64
 }
 
+// HugePageInfo contains the hugepage counters of a NUMA node for a single page size.
+type HugePageInfo struct {
+	Size  uint64 // page size in bytes
+	Total uint64 // total number of pages
+	Free  uint64 // number of free pages
+}
+
 // CPU cache.
 //   Notes: cache-discovery is forced off now (by forcibly clearing the related discovery bit)
 //      Can't seem to make sense of the cache information exposed under sysfs. The cache ids
@@ -188,7 +230,19 @@ type Cache struct {
 }
 
 // DiscoverSystem performs discovery of the running systems details.
+//
+// If a fake topology is given on the command line, it is discovered instead.
 func DiscoverSystem(args ...DiscoveryFlag) (System, error) {
+	if fakeTopology != "" {
+		path, err := fakeSysfsRoot()
+		if err != nil {
+			return nil, err
+		}
+		return DiscoverSystemAt(path, args...)
+	}
+	if err := checkPlatform(SysfsRootPath); err != nil {
+		return nil, err
+	}
 	return DiscoverSystemAt(SysfsRootPath, args...)
 }
 
@@ -240,6 +294,18 @@ func (sys *system) Discover(flags DiscoveryFlag) error {
 		}
 	}
 
+	if (sys.flags & DiscoverBlockDevices) != 0 {
+		if err := sys.discoverBlockDevices(); err != nil {
+			return err
+		}
+	}
+
+	if (sys.flags & DiscoverWorkQueues) != 0 {
+		if err := sys.discoverWorkQueues(); err != nil {
+			return err
+		}
+	}
+
 	if len(sys.nodes) > 0 {
 		for _, pkg := range sys.packages {
 			for _, nodeID := range pkg.nodes.SortedMembers() {
@@ -248,6 +314,10 @@ func (sys *system) Discover(flags DiscoveryFlag) error {
 				}
 			}
 		}
+		sys.attachMemoryOnlyNodes()
+		if err := sys.discoverMemoryTypes(); err != nil {
+			return err
+		}
 	}
 
 	if sys.DebugEnabled() {
@@ -261,6 +331,10 @@ func (sys *system) Discover(flags DiscoveryFlag) error {
 			sys.Debug("node #%d:", id)
 			sys.Debug("      cpus: %s", node.cpus)
 			sys.Debug("  distance: %v", node.distance)
+			sys.Debug("    memory: %s", node.memtype)
+			if node.IsMemoryOnly() {
+				sys.Debug("  attached: %s", node.cpunodes)
+			}
 		}
 
 		for id, cpu := range sys.cpus {
@@ -276,6 +350,12 @@ func (sys *system) Discover(flags DiscoveryFlag) error {
 		sys.Debug("offline CPUs: %s", sys.offline)
 		sys.Debug("isolated CPUs: %s", sys.isolated)
 
+		for id, llc := range sys.llcs {
+			sys.Debug("L%d cache #%d:", llc.level, id)
+			sys.Debug("   size: %d", llc.size)
+			sys.Debug("   CPUs: %s", llc.cpus)
+		}
+
 		for id, cch := range sys.cache {
 			sys.Debug("cache #%d:", id)
 			sys.Debug("   type: %v", cch.kind)
@@ -283,6 +363,10 @@ func (sys *system) Discover(flags DiscoveryFlag) error {
 			sys.Debug("  level: %d", cch.level)
 			sys.Debug("   CPUs: %s", cch.cpus)
 		}
+
+		for _, dev := range sys.BlockDevices() {
+			sys.Debug("block device %s", dev)
+		}
 	}
 
 	return nil
@@ -382,6 +466,25 @@ func (sys *system) NodeIDs() []ID {
 	return ids
 }
 
+// LLCIDs gets the ids of all last-level caches present in the system.
+func (sys *system) LLCIDs() []ID {
+	ids := make([]ID, 0, len(sys.llcs))
+	for id := range sys.llcs {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return int(ids[i]) < int(ids[j])
+	})
+
+	return ids
+}
+
+// LLC gets the last-level cache corresponding to the given id.
+func (sys *system) LLC(id ID) *Cache {
+	return sys.llcs[id]
+}
+
 // CPUIDs gets the ids of all CPUs present in the system.
 func (sys *system) CPUIDs() []ID {
 	ids := make([]ID, len(sys.cpus))
@@ -475,6 +578,14 @@ func (sys *system) discoverCPUs() error {
 	if err != nil {
 		sys.Error("failed to get set of isolated cpus: %v", err)
 	}
+	if sys.isolated == nil {
+		sys.isolated = NewIDSet()
+	}
+
+	sys.discoverIsolation()
+	for _, cpus := range sys.isolation {
+		sys.isolated.Add(cpus.Members()...)
+	}
 
 	entries, _ := filepath.Glob(filepath.Join(sys.path, sysfsCPUPath, "cpu[0-9]*"))
 	for _, entry := range entries {
@@ -483,6 +594,8 @@ func (sys *system) discoverCPUs() error {
 		}
 	}
 
+	sys.discoverCoreTypes()
+
 	return nil
 }
 
@@ -495,6 +608,9 @@ func (sys *system) discoverCPU(path string) error {
 	if _, err := readSysfsEntry(path, "topology/physical_package_id", &cpu.pkg); err != nil {
 		return err
 	}
+	if _, err := readSysfsEntry(path, "topology/die_id", &cpu.die); err != nil {
+		cpu.die = 0
+	}
 	if _, err := readSysfsEntry(path, "topology/core_id", &cpu.core); err != nil {
 		return err
 	}
@@ -510,6 +626,9 @@ func (sys *system) discoverCPU(path string) error {
 	if _, err := readSysfsEntry(path, "cpufreq/cpuinfo_max_freq", &cpu.freq.max); err != nil {
 		cpu.freq.max = 0
 	}
+	if _, err := readSysfsEntry(path, "cpufreq/energy_performance_preference", &cpu.epp); err != nil {
+		cpu.epp = ""
+	}
 	if node, _ := filepath.Glob(filepath.Join(path, "node[0-9]*")); len(node) == 1 {
 		cpu.node = getEnumeratedID(node[0])
 	}
@@ -523,6 +642,10 @@ func (sys *system) discoverCPU(path string) error {
 
 	sys.cpus[cpu.id] = cpu
 
+	if err := sys.discoverLLC(path); err != nil {
+		sys.Debug("%v", err)
+	}
+
 	if (sys.flags & DiscoverCache) != 0 {
 		entries, _ := filepath.Glob(filepath.Join(path, "cache/index[0-9]*"))
 		for _, entry := range entries {
@@ -545,6 +668,11 @@ func (c *cpu) PackageID() ID {
 	return c.pkg
 }
 
+// DieID returns the die id of this CPU, within its package.
+func (c *cpu) DieID() ID {
+	return c.die
+}
+
 // NodeID returns the node id of this CPU.
 func (c *cpu) NodeID() ID {
 	return c.node
@@ -611,6 +739,34 @@ func (c *cpu) SetFrequencyLimits(min, max uint64) error {
 	return nil
 }
 
+// EnergyPerformancePreference returns the energy-performance preference discovered for this CPU.
+func (c *cpu) EnergyPerformancePreference() string {
+	return c.epp
+}
+
+// SetEnergyPerformancePreference sets the energy-performance preference for this CPU.
+func (c *cpu) SetEnergyPerformancePreference(epp string) error {
+	if c.epp == "" {
+		return nil
+	}
+
+	if _, err := writeSysfsEntry(c.path, "cpufreq/energy_performance_preference", epp, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Min returns the minimum frequency of the range (kHz).
+func (f CPUFreq) Min() uint64 {
+	return f.min
+}
+
+// Max returns the maximum frequency of the range (kHz).
+func (f CPUFreq) Max() uint64 {
+	return f.max
+}
+
 // Discover NUMA nodes present in the system.
 func (sys *system) discoverNodes() error {
 	if sys.nodes != nil {
@@ -645,6 +801,40 @@ func (sys *system) discoverNode(path string) error {
 	return nil
 }
 
+// attachMemoryOnlyNodes attaches memory-only (CPU-less) nodes to the closest nodes with CPUs.
+//
+// Memory-only nodes, for instance HBM or CXL memory, don't belong to any package
+// by their CPUs. We attach them to the nodes with CPUs at the shortest distance
+// and put them in the package of the first of those.
+func (sys *system) attachMemoryOnlyNodes() {
+	for _, n := range sys.nodes {
+		if !n.IsMemoryOnly() {
+			continue
+		}
+		n.cpunodes = NewIDSet()
+		closest := -1
+		for id, cn := range sys.nodes {
+			if cn.IsMemoryOnly() {
+				continue
+			}
+			d := n.DistanceFrom(id)
+			if d < 0 {
+				continue
+			}
+			switch {
+			case closest < 0 || d < closest:
+				closest = d
+				n.cpunodes = NewIDSet(id)
+			case d == closest:
+				n.cpunodes.Add(id)
+			}
+		}
+		if ids := n.cpunodes.SortedMembers(); len(ids) > 0 {
+			n.pkg = sys.nodes[ids[0]].pkg
+		}
+	}
+}
+
 // ID returns id of this node.
 func (n *node) ID() ID {
 	return n.id
@@ -674,6 +864,24 @@ func (n *node) DistanceFrom(id ID) int {
 	return -1
 }
 
+// IsMemoryOnly returns true if the node has memory but no CPUs.
+func (n *node) IsMemoryOnly() bool {
+	return n.cpus.Size() == 0
+}
+
+// CPUNodeIDs returns the closest nodes with CPUs, which is the node itself unless it is memory-only.
+func (n *node) CPUNodeIDs() []ID {
+	if !n.IsMemoryOnly() {
+		return []ID{n.id}
+	}
+	return n.cpunodes.SortedMembers()
+}
+
+// MemoryType returns the type of memory in the node.
+func (n *node) MemoryType() MemoryType {
+	return n.memtype
+}
+
 // MemoryInfo memory info for the node (partial content from the meminfo sysfs entry).
 func (n *node) MemoryInfo() (*MemInfo, error) {
 	meminfo := filepath.Join(n.path, "meminfo")
@@ -704,6 +912,31 @@ func (n *node) MemoryInfo() (*MemInfo, error) {
 	return buf, nil
 }
 
+// HugePageInfo returns the hugepage counters of the node, by page size in bytes.
+func (n *node) HugePageInfo() (map[uint64]*HugePageInfo, error) {
+	info := make(map[uint64]*HugePageInfo)
+
+	entries, _ := filepath.Glob(filepath.Join(n.path, "hugepages", "hugepages-*kB"))
+	for _, entry := range entries {
+		kB := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(entry), "hugepages-"), "kB")
+		size, err := strconv.ParseUint(kB, 10, 0)
+		if err != nil {
+			return nil, sysfsError(entry, "invalid hugepage size '%s': %v", kB, err)
+		}
+
+		hp := &HugePageInfo{Size: size * 1024}
+		if _, err := readSysfsEntry(entry, "nr_hugepages", &hp.Total); err != nil {
+			return nil, err
+		}
+		if _, err := readSysfsEntry(entry, "free_hugepages", &hp.Free); err != nil {
+			return nil, err
+		}
+		info[hp.Size] = hp
+	}
+
+	return info, nil
+}
+
 // Discover physical packages (CPU sockets) present in the system.
 func (sys *system) discoverPackages() error {
 	if sys.packages != nil {
@@ -716,14 +949,24 @@ func (sys *system) discoverPackages() error {
 		pkg, found := sys.packages[cpu.pkg]
 		if !found {
 			pkg = &cpuPackage{
-				id:    cpu.pkg,
-				cpus:  NewIDSet(),
-				nodes: NewIDSet(),
+				id:       cpu.pkg,
+				cpus:     NewIDSet(),
+				nodes:    NewIDSet(),
+				dies:     NewIDSet(),
+				dieCPUs:  make(map[ID]IDSet),
+				dieNodes: make(map[ID]IDSet),
 			}
 			sys.packages[cpu.pkg] = pkg
 		}
 		pkg.cpus.Add(cpu.id)
 		pkg.nodes.Add(cpu.node)
+		if !pkg.dies.Has(cpu.die) {
+			pkg.dies.Add(cpu.die)
+			pkg.dieCPUs[cpu.die] = NewIDSet()
+			pkg.dieNodes[cpu.die] = NewIDSet()
+		}
+		pkg.dieCPUs[cpu.die].Add(cpu.id)
+		pkg.dieNodes[cpu.die].Add(cpu.node)
 	}
 
 	return nil
@@ -744,6 +987,27 @@ func (p *cpuPackage) NodeIDs() []ID {
 	return p.nodes.SortedMembers()
 }
 
+// DieIDs returns the ids of the dies in this package.
+func (p *cpuPackage) DieIDs() []ID {
+	return p.dies.SortedMembers()
+}
+
+// DieCPUSet returns the CPUSet for all cores/threads in the given die of this package.
+func (p *cpuPackage) DieCPUSet(id ID) cpuset.CPUSet {
+	if cpus, ok := p.dieCPUs[id]; ok {
+		return cpus.CPUSet()
+	}
+	return cpuset.NewCPUSet()
+}
+
+// DieNodeIDs returns the NUMA node ids for the given die of this package.
+func (p *cpuPackage) DieNodeIDs(id ID) []ID {
+	if nodes, ok := p.dieNodes[id]; ok {
+		return nodes.SortedMembers()
+	}
+	return []ID{}
+}
+
 // Discover cache associated with the given CPU.
 // Notes:
 //     I'm not sure how to interpret the cache information under sysfs. This code is now effectively
@@ -791,22 +1055,119 @@ func (sys *system) discoverCache(path string) error {
 		return sysfsError(path, "can't read cache size: %v", err)
 	}
 
-	base := size[0 : len(size)-1]
-	suff := size[len(size)-1]
+	var err error
+	if c.size, err = parseCacheSize(size); err != nil {
+		return sysfsError(path, "%v", err)
+	}
+
+	sys.cache[c.id] = c
+
+	return nil
+}
+
+// Discover the last-level cache of the given CPU.
+//
+// Cache ids are only unique among caches of the same level, so we only look at
+// the unified or data cache of the highest level here. On systems supporting
+// RDT the id of this cache is also the id of the corresponding CAT domain.
+func (sys *system) discoverLLC(path string) error {
+	var (
+		llcPath  string
+		llcLevel uint8
+	)
+
+	entries, _ := filepath.Glob(filepath.Join(path, "cache/index[0-9]*"))
+	for _, entry := range entries {
+		var level uint8
+		kind := ""
+		if _, err := readSysfsEntry(entry, "level", &level); err != nil {
+			return sysfsError(entry, "can't read cache level: %v", err)
+		}
+		if _, err := readSysfsEntry(entry, "type", &kind); err != nil {
+			return sysfsError(entry, "can't read cache type: %v", err)
+		}
+		if CacheType(kind) == InstructionCache {
+			continue
+		}
+		if level > llcLevel {
+			llcPath, llcLevel = entry, level
+		}
+	}
+
+	if llcPath == "" {
+		return nil
+	}
+
+	var id ID
+	if _, err := readSysfsEntry(llcPath, "id", &id); err != nil {
+		return sysfsError(llcPath, "can't read cache id: %v", err)
+	}
+
+	if sys.llcs == nil {
+		sys.llcs = make(map[ID]*Cache)
+	}
+	if _, found := sys.llcs[id]; found {
+		return nil
+	}
+
+	c := &Cache{id: id, kind: UnifiedCache, level: llcLevel}
+	if _, err := readSysfsEntry(llcPath, "shared_cpu_list", &c.cpus, ","); err != nil {
+		return sysfsError(llcPath, "can't read shared CPUs: %v", err)
+	}
+	size := ""
+	if _, err := readSysfsEntry(llcPath, "size", &size); err != nil {
+		return sysfsError(llcPath, "can't read cache size: %v", err)
+	}
+	var err error
+	if c.size, err = parseCacheSize(size); err != nil {
+		return sysfsError(llcPath, "%v", err)
+	}
+
+	sys.llcs[id] = c
+
+	return nil
+}
+
+// parseCacheSize parses a cache size with an optional K, M, or G unit suffix.
+func parseCacheSize(size string) (uint64, error) {
 	unit := map[byte]uint64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}
 
+	base, mult := size, uint64(1)
+	if len(size) > 0 {
+		if u, ok := unit[size[len(size)-1]]; ok {
+			base, mult = size[0:len(size)-1], u
+		}
+	}
+
 	val, err := strconv.ParseUint(base, 10, 0)
 	if err != nil {
-		return sysfsError(path, "can't parse cache size '%s': %v", size, err)
+		return 0, fmt.Errorf("can't parse cache size '%s': %v", size, err)
 	}
 
-	if u, ok := unit[suff]; ok {
-		c.size = val * u
-	} else {
-		c.size = val*1000 + u - '0'
-	}
+	return val * mult, nil
+}
 
-	sys.cache[c.id] = c
+// ID returns the id of this cache.
+func (c *Cache) ID() ID {
+	return c.id
+}
 
-	return nil
+// Kind returns the type of this cache.
+func (c *Cache) Kind() CacheType {
+	return c.kind
+}
+
+// Level returns the level of this cache.
+func (c *Cache) Level() uint8 {
+	return c.level
+}
+
+// Size returns the size of this cache in bytes.
+func (c *Cache) Size() uint64 {
+	return c.size
+}
+
+// CPUSet returns the set of CPUs sharing this cache.
+func (c *Cache) CPUSet() cpuset.CPUSet {
+	return c.cpus.CPUSet()
 }
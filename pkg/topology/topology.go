@@ -20,10 +20,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"syscall"
 
 	"github.com/pkg/errors"
-	"golang.org/x/sys/unix"
 )
 
 // to mock in tests
@@ -150,6 +148,38 @@ func NewTopologyHints(devPath string) (hints Hints, err error) {
 	return
 }
 
+// NewNetworkInterfaceHints returns the hints for the device of a network interface.
+//
+// For virtual interfaces without a device of their own, like VLANs or bonds,
+// the hints of their lower interfaces are returned.
+func NewNetworkInterfaceHints(name string) (Hints, error) {
+	netDir := filepath.Join(mockRoot, "/sys/class/net", name)
+	if _, err := os.Stat(netDir); err != nil {
+		return nil, errors.Wrapf(err, "failed to find network interface %s", name)
+	}
+
+	devPath := filepath.Join(netDir, "device")
+	if _, err := os.Stat(devPath); err == nil {
+		return NewTopologyHints(devPath)
+	}
+
+	hints := make(Hints)
+	lowers, _ := filepath.Glob(filepath.Join(netDir, "lower_*"))
+	for _, lower := range lowers {
+		lowerHints, err := NewNetworkInterfaceHints(strings.TrimPrefix(filepath.Base(lower), "lower_"))
+		if err != nil {
+			return nil, err
+		}
+		hints = MergeTopologyHints(hints, lowerHints)
+	}
+	return hints, nil
+}
+
+// NewPCIDeviceHints returns the hints for a PCI device, given its address.
+func NewPCIDeviceHints(address string) (Hints, error) {
+	return NewTopologyHints(filepath.Join(mockRoot, "/sys/bus/pci/devices", address))
+}
+
 // MergeTopologyHints combines org and hints.
 func MergeTopologyHints(org, hints Hints) (res Hints) {
 	if org != nil {
@@ -200,16 +230,11 @@ func FindSysFsDevice(dev string) (string, error) {
 	}
 
 	devType := "block"
-	rdev := fi.Sys().(*syscall.Stat_t).Dev
-	if mode := fi.Mode(); mode&os.ModeDevice != 0 {
-		rdev = fi.Sys().(*syscall.Stat_t).Rdev
-		if mode&os.ModeCharDevice != 0 {
-			devType = "char"
-		}
+	if mode := fi.Mode(); mode&os.ModeDevice != 0 && mode&os.ModeCharDevice != 0 {
+		devType = "char"
 	}
 
-	major := unix.Major(rdev)
-	minor := unix.Minor(rdev)
+	major, minor := deviceNumber(fi)
 	if major == 0 {
 		return "", errors.Errorf("%s is a virtual device node", dev)
 	}
@@ -306,3 +306,74 @@ func TestNewTopologyHints(t *testing.T) {
 		})
 	}
 }
+
+func TestNewNetworkInterfaceHints(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	teardown := setupTestEnv(t)
+	defer teardown()
+	pciHints := Hints{
+		mockRoot + "/sys/devices/pci0000:00/0000:00:02.0": Hint{
+			Provider: mockRoot + "/sys/devices/pci0000:00/0000:00:02.0",
+			CPUs:     "0-7",
+			NUMAs:    "",
+			Sockets:  ""},
+	}
+	cases := []struct {
+		name        string
+		input       string
+		output      Hints
+		expectedErr bool
+	}{
+		{
+			name:        "non-existing",
+			input:       "eth42",
+			output:      nil,
+			expectedErr: true,
+		},
+		{
+			name:        "physical interface",
+			input:       "eth0",
+			output:      pciHints,
+			expectedErr: false,
+		},
+		{
+			name:        "virtual interface on top of physical one",
+			input:       "vlan100",
+			output:      pciHints,
+			expectedErr: false,
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := NewNetworkInterfaceHints(test.input)
+			switch {
+			case err != nil && !test.expectedErr:
+				t.Fatalf("unexpected error returned: %+v", err)
+			case err == nil && test.expectedErr:
+				t.Fatalf("unexpected success: %+v", output)
+			case !reflect.DeepEqual(output, test.output):
+				t.Fatalf("expected: %q got: %q", test.output, output)
+			}
+		})
+	}
+}
+
+func TestNewPCIDeviceHints(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	teardown := setupTestEnv(t)
+	defer teardown()
+	output, err := NewPCIDeviceHints("0000:00:02.0")
+	if err != nil {
+		t.Fatalf("unexpected error returned: %+v", err)
+	}
+	if hint, ok := output[mockRoot+"/sys/devices/pci0000:00/0000:00:02.0"]; !ok || hint.CPUs != "0-7" {
+		t.Fatalf("unexpected hints: %q", output)
+	}
+	if _, err := NewPCIDeviceHints("0000:ff:00.0"); err == nil {
+		t.Fatalf("unexpected success for non-existing PCI device")
+	}
+}
@@ -17,67 +17,101 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/intel/cri-resource-manager/pkg/cgroups"
 )
 
 const (
 	cgroupTasks     = "tasks"
+	cgroupRootDir   = "/sys/fs/cgroup/"
 	cpusetCgroupDir = "/sys/fs/cgroup/cpuset/"
+	memoryCgroupDir = "/sys/fs/cgroup/memory/"
 )
 
-// GetContainerCgroupDir finds container path in one specified subsystem directory
-func GetContainerCgroupDir(subsystemDir, containerID string) string {
-	var containerDir string
+// findContainerCpusetDir finds the cpuset cgroup directory of the container.
+func findContainerCpusetDir(containerID string) (string, error) {
+	return FindContainerDir(cpusetCgroupDir, containerID)
+}
 
-	filepath.Walk(subsystemDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if !info.IsDir() {
-			return nil
+// FindContainerDir finds the cgroup directory of the container in the given hierarchy.
+func FindContainerDir(subsystemDir, containerID string) (string, error) {
+	containerDir, err := cgroups.ResolveCgroupPath(subsystemDir, containerID)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(containerDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("failed to find cgroups directory %s for container %s",
+			containerDir, containerID)
+	}
+
+	return containerDir, nil
+}
+
+// GetContainerOOMKills gets the number of OOM kills in the container.
+//
+// The count is read from the oom_kill entry of memory.events with cgroup v2,
+// and of memory.oom_control with cgroup v1.
+func GetContainerOOMKills(containerID string) (int, error) {
+	subsystemDir, entry := memoryCgroupDir, "memory.oom_control"
+	if _, err := os.Stat(filepath.Join(cgroupRootDir, "cgroup.controllers")); err == nil {
+		subsystemDir, entry = cgroupRootDir, "memory.events"
+	}
+
+	containerDir, err := FindContainerDir(subsystemDir, containerID)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Open(path.Join(containerDir, entry))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %v", path.Join(containerDir, entry), err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.Atoi(fields[1])
 		}
-		// Assume directory name contains containerID is what we want
-		if strings.Contains(filepath.Base(path), containerID) {
-			containerDir = path
+	}
+	return 0, nil
+}
+
+// GetContainerCpuset gets the current cpuset.cpus and cpuset.mems of the container.
+func GetContainerCpuset(containerID string) (string, string, error) {
+	containerDir, err := findContainerCpusetDir(containerID)
+	if err != nil {
+		return "", "", err
+	}
+
+	values := []string{}
+	for _, entry := range []string{"cpuset.cpus", "cpuset.mems"} {
+		blob, err := ioutil.ReadFile(path.Join(containerDir, entry))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read %s of container %s: %v",
+				entry, containerID, err)
 		}
-		return nil
-	})
+		values = append(values, strings.TrimSpace(string(blob)))
+	}
 
-	return containerDir
+	return values[0], values[1], nil
 }
 
 // GetProcessInContainer gets the IDs of all processes in the container.
-func GetProcessInContainer(cgroupParentDir, containerID string) ([]string, error) {
+func GetProcessInContainer(containerID string) ([]string, error) {
 	var entries []string
 
 	// Find Cpuset sub-cgroup directory of this container
-	containerDir := ""
-	// Probe known per-container directories
-	if cgroupParentDir != "" {
-		dirs := []string{
-			filepath.Join(cpusetCgroupDir, cgroupParentDir, "cri-containerd-"+containerID+".scope"),
-			filepath.Join(cpusetCgroupDir, cgroupParentDir, "crio-"+containerID+".scope"),
-			filepath.Join(cpusetCgroupDir, cgroupParentDir, "docker-"+containerID+".scope"),
-			filepath.Join(cpusetCgroupDir, cgroupParentDir, containerID),
-		}
-		for _, d := range dirs {
-			info, err := os.Stat(d)
-			if err == nil && info.IsDir() {
-				containerDir = d
-				break
-			}
-		}
-	}
-
-	// Try generic way to search container directory under one cgroups subsytem directory
-	if containerDir == "" {
-		containerDir = GetContainerCgroupDir(cpusetCgroupDir, containerID)
-		if containerDir == "" {
-			return nil, fmt.Errorf("failed to find corresponding cgroups directory for container %s", containerID)
-		}
+	containerDir, err := findContainerCpusetDir(containerID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Find all processes listed in cgroup tasks file and apply to RDT CLOS